@@ -1,6 +1,10 @@
 package updater
 
-import "io"
+import (
+	"context"
+	"io"
+	"time"
+)
 
 // App is a generic Go application capapble of querying update
 // information and updating itself.
@@ -13,6 +17,53 @@ type App interface {
 	LatestRelease() Release
 }
 
+// ContextApp is an App that can query information with a context.
+//
+// The updater will prefer QueryContext over Query when it is available.
+type ContextApp interface {
+	App
+
+	// QueryContext should query application information from a remote source
+	// and return early when ctx is cancelled.
+	QueryContext(ctx context.Context) error
+}
+
+// ReleasesApp is an App that can list all of its releases.
+//
+// Apps that do not implement it only expose their latest release.
+type ReleasesApp interface {
+	App
+
+	// Releases should return all releases that are available, the most
+	// recent one first.
+	Releases() []Release
+}
+
+// NamedReleaseApp is an App that can look up a release by its version name,
+// e.g. a release that is too old to be listed by Releases.
+//
+// The updater uses it to find the release to update to with UpdateToVersion.
+type NamedReleaseApp interface {
+	App
+
+	// ReleaseByName should return the release with the given version name,
+	// or ErrReleaseNotFound if there is no such release.
+	ReleaseByName(name string) (Release, error)
+}
+
+// ContextNamedReleaseApp is a NamedReleaseApp that can look up a release with
+// a context.
+//
+// The updater will prefer ReleaseByNameContext over ReleaseByName when it is
+// available.
+type ContextNamedReleaseApp interface {
+	NamedReleaseApp
+
+	// ReleaseByNameContext should be like ReleaseByName and return early when
+	// ctx is cancelled.
+	ReleaseByNameContext(ctx context.Context, name string) (Release, error)
+}
+
 // Release represents an application release.
 type Release interface {
 	// Name should return the version name of this release.
@@ -29,6 +80,83 @@ type Release interface {
 	Assets() []Asset
 }
 
+// MandatoryRelease is a Release that knows whether it must be applied.
+//
+// Use IsMandatory to check whether any release is mandatory.
+type MandatoryRelease interface {
+	Release
+
+	// Mandatory should return true if the release must be applied, e.g.
+	// because it fixes a critical security issue.
+	Mandatory() bool
+}
+
+// RolloutRelease is a Release that is rolled out to a part of the clients
+// only.
+//
+// Use RolloutPercentage to find out to which part of the clients any release
+// is rolled out.
+type RolloutRelease interface {
+	Release
+
+	// RolloutPercentage should return the percentage of clients that should
+	// update to the release, from 0 to 100.
+	RolloutPercentage() int
+}
+
+// GatedRelease is a Release that older versions of the application cannot
+// update to directly, e.g. because a newer version cannot migrate their data.
+//
+// Use UpgradeRequirement to find out which versions can update to any
+// release.
+type GatedRelease interface {
+	Release
+
+	// MinimumVersion should return the oldest version of the application
+	// that can update to the release, or an empty string if all versions can.
+	MinimumVersion() string
+
+	// UpgradeVia should return the version name of the release that older
+	// versions must update to first, or an empty string to update to the
+	// newest release that they can update to.
+	UpgradeVia() string
+}
+
+// FormattedRelease is a Release whose Information is formatted, e.g. the
+// Markdown release notes of GitHub.
+//
+// Use InformationHTML and InformationText to display the information of any
+// release.
+type FormattedRelease interface {
+	Release
+
+	// InformationHTML should return the information rendered to HTML, without
+	// scripts or other markup that is unsafe to display.
+	InformationHTML() string
+
+	// InformationText should return the information as plain text, without
+	// any markup.
+	InformationText() string
+}
+
+// ReleaseMeta is a Release that exposes metadata, e.g. to show in a user
+// interface.
+type ReleaseMeta interface {
+	Release
+
+	// PublishedAt should return when the release was published, or the zero
+	// time if it is not known.
+	PublishedAt() time.Time
+
+	// URL should return the address of a web page describing the release, or
+	// an empty string if there is none.
+	URL() string
+
+	// Prerelease should return true if the release is not ready for
+	// production, e.g. a beta release.
+	Prerelease() bool
+}
+
 // Asset represents a downloadable asset.
 type Asset interface {
 	// Name should return the file name of the asset.
@@ -37,3 +165,57 @@ type Asset interface {
 	// Write should write the contents of the asset.
 	Write(w io.Writer) error
 }
+
+// ChecksumAsset is an Asset whose SHA-256 checksum is known before it is
+// downloaded, like the assets of a manifest.
+type ChecksumAsset interface {
+	Asset
+
+	// SHA256 should return the hexadecimal SHA-256 checksum of the asset, or
+	// an empty string if it is not known.
+	SHA256() string
+}
+
+// ContextAsset is an Asset that can be written with a context.
+//
+// The updater will prefer WriteContext over Write when it is available.
+type ContextAsset interface {
+	Asset
+
+	// WriteContext should write the contents of the asset and return early
+	// when ctx is cancelled.
+	WriteContext(ctx context.Context, w io.Writer) error
+}
+
+// AssetReader is an Asset whose contents can be read, e.g. to limit, hash or
+// inspect the stream without a custom writer.
+//
+// The updater will prefer Open over Write and WriteContext when it is
+// available, unless the asset is downloaded over multiple connections. Use
+// OpenAsset to read any asset.
+type AssetReader interface {
+	Asset
+
+	// Open should return a reader of the contents of the asset and the size
+	// of the asset in bytes, or -1 if it is not known. Reading should fail
+	// when ctx is cancelled. The reader is closed by the caller.
+	Open(ctx context.Context) (io.ReadCloser, int64, error)
+}
+
+// AssetMeta is an Asset that exposes metadata, e.g. to decide whether it
+// should be downloaded or to show in a user interface.
+type AssetMeta interface {
+	Asset
+
+	// Size should return the size of the asset in bytes, or -1 if it is not
+	// known.
+	Size() int64
+
+	// ContentType should return the media type of the asset, or an empty
+	// string if it is not known.
+	ContentType() string
+
+	// DownloadCount should return how many times the asset was downloaded,
+	// or -1 if it is not known.
+	DownloadCount() int
+}
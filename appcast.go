@@ -0,0 +1,197 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+)
+
+type appcastApp struct {
+	url      string
+	releases []Release
+}
+
+type appcastRelease struct {
+	Item appcastItemXML
+
+	assets []Asset
+}
+
+type appcastAsset struct {
+	Enclosure appcastEnclosureXML
+
+	url string
+}
+
+type appcastXML struct {
+	Items []appcastItemXML `xml:"channel>item"`
+}
+
+type appcastItemXML struct {
+	Title              string                `xml:"title"`
+	Description        string                `xml:"description"`
+	Version            string                `xml:"http://www.andymatuschak.org/xml-namespaces/sparkle version"`
+	ShortVersionString string                `xml:"http://www.andymatuschak.org/xml-namespaces/sparkle shortVersionString"`
+	Enclosures         []appcastEnclosureXML `xml:"enclosure"`
+	CriticalUpdate     *struct{}             `xml:"http://www.andymatuschak.org/xml-namespaces/sparkle criticalUpdate"`
+}
+
+type appcastEnclosureXML struct {
+	URL                string `xml:"url,attr"`
+	Length             int64  `xml:"length,attr"`
+	Type               string `xml:"type,attr"`
+	Version            string `xml:"http://www.andymatuschak.org/xml-namespaces/sparkle version,attr"`
+	ShortVersionString string `xml:"http://www.andymatuschak.org/xml-namespaces/sparkle shortVersionString,attr"`
+	OS                 string `xml:"http://www.andymatuschak.org/xml-namespaces/sparkle os,attr"`
+}
+
+// NewAppcast creates an Application whose releases are published in a Sparkle
+// appcast, an RSS feed with Sparkle extensions.
+//
+// Every item of the feed is a release, the first item being the latest one.
+// The enclosures of an item are its assets, named after the last element of
+// their URL. The identifier of a release is its sparkle:version, and its name
+// is the sparkle:shortVersionString if there is one. Items with a
+// sparkle:criticalUpdate element are mandatory releases.
+func NewAppcast(url string) App {
+	return &appcastApp{
+		url: url,
+	}
+}
+
+func (app *appcastApp) Query() error {
+	return app.QueryContext(context.Background())
+}
+
+func (app *appcastApp) QueryContext(ctx context.Context) error {
+	base, err := url.Parse(app.url)
+	if err != nil {
+		return err
+	}
+
+	buf := bytes.NewBuffer(nil)
+	err = download(ctx, nil, app.url, buf)
+	if err != nil {
+		return err
+	}
+
+	var feed appcastXML
+	err = xml.Unmarshal(buf.Bytes(), &feed)
+	if err != nil {
+		return fmt.Errorf("Invalid appcast: %v", err)
+	}
+
+	s := make([]Release, len(feed.Items))
+	for i, item := range feed.Items {
+		r, err := newAppcastRelease(item, base)
+		if err != nil {
+			return err
+		}
+		s[i] = r
+	}
+	app.releases = s
+
+	return nil
+}
+
+func (app *appcastApp) LatestRelease() Release {
+	if len(app.releases) == 0 {
+		return nil
+	}
+
+	return app.releases[0]
+}
+
+func (app *appcastApp) Releases() []Release {
+	return app.releases
+}
+
+func newAppcastRelease(item appcastItemXML, base *url.URL) (*appcastRelease, error) {
+	s := make([]Asset, len(item.Enclosures))
+	for i, e := range item.Enclosures {
+		u, err := base.Parse(strings.TrimSpace(e.URL))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid enclosure URL %v: %v", e.URL, err)
+		}
+
+		s[i] = &appcastAsset{
+			Enclosure: e,
+			url:       u.String(),
+		}
+	}
+
+	// Older appcasts only put the version on the enclosure
+	if len(item.Enclosures) != 0 {
+		if item.Version == "" {
+			item.Version = item.Enclosures[0].Version
+		}
+		if item.ShortVersionString == "" {
+			item.ShortVersionString = item.Enclosures[0].ShortVersionString
+		}
+	}
+
+	return &appcastRelease{
+		Item:   item,
+		assets: s,
+	}, nil
+}
+
+func (r *appcastRelease) Name() string {
+	if r.Item.ShortVersionString != "" {
+		return r.Item.ShortVersionString
+	} else if r.Item.Version != "" {
+		return r.Item.Version
+	}
+	return r.Item.Title
+}
+
+func (r *appcastRelease) Information() string {
+	return strings.TrimSpace(r.Item.Description)
+}
+
+func (r *appcastRelease) Identifier() string {
+	return r.Item.Version
+}
+
+func (r *appcastRelease) Assets() []Asset {
+	return r.assets
+}
+
+func (r *appcastRelease) Mandatory() bool {
+	return r.Item.CriticalUpdate != nil || hasMandatoryToken(r.Item.Description)
+}
+
+func (r *appcastAsset) Name() string {
+	u, err := url.Parse(r.url)
+	if err != nil {
+		return ""
+	}
+	return path.Base(u.Path)
+}
+
+func (r *appcastAsset) Write(w io.Writer) error {
+	return r.WriteContext(context.Background(), w)
+}
+
+func (r *appcastAsset) WriteContext(ctx context.Context, w io.Writer) error {
+	if r.url == "" {
+		return errors.New("No download URL available.")
+	}
+
+	return download(ctx, nil, r.url, w)
+}
+
+// Open requests the asset.
+func (r *appcastAsset) Open(ctx context.Context) (io.ReadCloser, int64, error) {
+	if r.url == "" {
+		return nil, 0, errors.New("No download URL available.")
+	}
+
+	return openURL(ctx, nil, r.url)
+}
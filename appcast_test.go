@@ -0,0 +1,103 @@
+package updater
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppcast(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/appcast.xml":
+			w.Write([]byte(validAppcastXML))
+		case "/downloads/MyApp-2.0.zip":
+			w.Write([]byte("Hello World!"))
+		case "/invalid.xml":
+			w.Write([]byte("<rss><channel>"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	// Valid appcast
+	{
+		app := NewAppcast(ts.URL + "/appcast.xml")
+		assert.Nil(t, app.LatestRelease())
+
+		err := app.Query()
+		require.Nil(t, err, "Unexpected query error: %v", err)
+
+		releases := app.(ReleasesApp).Releases()
+		require.Equal(t, 2, len(releases))
+
+		r := releases[0]
+		assert.Equal(t, r, app.LatestRelease())
+		assert.Equal(t, "2.0", r.Name())
+		assert.Equal(t, "200", r.Identifier())
+		assert.Equal(t, "<p>Bug fixes.</p>", r.Information())
+		assert.True(t, IsMandatory(r))
+		require.Equal(t, 1, len(r.Assets()))
+		assert.Equal(t, "MyApp-2.0.zip", r.Assets()[0].Name())
+
+		buf := bytes.NewBuffer(nil)
+		err = r.Assets()[0].Write(buf)
+		assert.Nil(t, err, "Unexpected write error: %v", err)
+		assert.Equal(t, "Hello World!", buf.String())
+
+		// Version on the enclosure
+		r = releases[1]
+		assert.Equal(t, "1.0", r.Name())
+		assert.Equal(t, "100", r.Identifier())
+		assert.False(t, IsMandatory(r))
+		require.Equal(t, 1, len(r.Assets()))
+		assert.Equal(t, "MyApp-1.0.zip", r.Assets()[0].Name())
+	}
+
+	// Invalid appcast
+	{
+		err := NewAppcast(ts.URL + "/invalid.xml").Query()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "Invalid appcast")
+	}
+
+	// Missing appcast
+	{
+		err := NewAppcast(ts.URL + "/missing.xml").Query()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "404")
+	}
+
+	// Without download URL
+	{
+		err := (&appcastAsset{}).Write(nil)
+		assert.Error(t, err)
+	}
+}
+
+var validAppcastXML = `<?xml version="1.0" encoding="utf-8"?>
+<rss version="2.0" xmlns:sparkle="http://www.andymatuschak.org/xml-namespaces/sparkle">
+  <channel>
+    <title>MyApp Changelog</title>
+    <item>
+      <title>Version 2.0</title>
+      <description><![CDATA[
+        <p>Bug fixes.</p>
+      ]]></description>
+      <sparkle:version>200</sparkle:version>
+      <sparkle:shortVersionString>2.0</sparkle:shortVersionString>
+      <sparkle:criticalUpdate />
+      <enclosure url="downloads/MyApp-2.0.zip" length="12" type="application/octet-stream" />
+    </item>
+    <item>
+      <title>Version 1.0</title>
+      <enclosure url="https://example.com/downloads/MyApp-1.0.zip" sparkle:version="100" sparkle:shortVersionString="1.0" length="12" type="application/octet-stream" />
+    </item>
+  </channel>
+</rss>
+`
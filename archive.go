@@ -0,0 +1,181 @@
+package updater
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+)
+
+// ArchiveWriter is a writer that extracts a single file from an archive.
+//
+// All writes to an archive writer go to a temporary file. When the writer is
+// closed, the first file in the archive matching the pattern is extracted to
+// the destination writer, which is closed afterwards if it is an io.Closer.
+//
+// The pattern is matched using path.Match against both the full path of a file
+// in the archive and its base name, e.g. "myapp" or "myapp-*/bin/myapp".
+type ArchiveWriter struct {
+	// Limits applied while looking for the file and extracting it: the
+	// MaxFiles entries of the archive that are searched, and the MaxSize of
+	// the file after decompression. By default, there are no limits.
+	Extractor *SecureExtractor
+
+	pattern string
+	dest    AbortWriter
+	extract func(x *extraction, f *os.File, size int64, match func(string) bool, w io.Writer) error
+
+	buffer  FileBuffer
+	aborted bool
+}
+
+// NewZipExtractor creates a writer that extracts the file matching pattern from
+// a zip archive to dest.
+func NewZipExtractor(pattern string, dest AbortWriter) *ArchiveWriter {
+	return &ArchiveWriter{
+		pattern: pattern,
+		dest:    dest,
+		extract: extractZip,
+	}
+}
+
+// NewTarGzExtractor creates a writer that extracts the file matching pattern
+// from a gzip compressed tar archive to dest.
+func NewTarGzExtractor(pattern string, dest AbortWriter) *ArchiveWriter {
+	return &ArchiveWriter{
+		pattern: pattern,
+		dest:    dest,
+		extract: extractTarGz,
+	}
+}
+
+// Write data to the temporary file.
+func (a *ArchiveWriter) Write(b []byte) (int, error) {
+	if a.aborted {
+		return 0, errors.New("Write operations aborted.")
+	}
+
+	return a.buffer.Write(b)
+}
+
+// Abort writing. The destination writer is aborted as well.
+func (a *ArchiveWriter) Abort() {
+	a.aborted = true
+	a.dest.Abort()
+}
+
+// Close will extract the file from the archive to the destination writer and
+// delete the temporary file.
+//
+// If the archive is invalid or no file matches, the destination writer is
+// aborted and an error is returned.
+func (a *ArchiveWriter) Close() error {
+	err := a.close()
+	if err != nil {
+		a.dest.Abort()
+	}
+
+	if c, ok := a.dest.(io.Closer); ok {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+
+	return err
+}
+
+func (a *ArchiveWriter) close() error {
+	// Delete the temporary file
+	defer os.Remove(a.buffer.Path)
+
+	// Close the temporary file
+	err := a.buffer.Close()
+	if err != nil || a.aborted {
+		return err
+	}
+
+	f, err := os.Open(a.buffer.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	match := func(name string) bool {
+		full, _ := path.Match(a.pattern, name)
+		base, _ := path.Match(a.pattern, path.Base(name))
+		return full || base
+	}
+
+	e := a.Extractor
+	if e == nil {
+		e = &SecureExtractor{}
+	}
+	return a.extract(&extraction{e: e}, f, info.Size(), match, a.dest)
+}
+
+func extractZip(x *extraction, f *os.File, size int64, match func(string) bool, w io.Writer) error {
+	r, err := zip.NewReader(f, size)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range r.File {
+		if err := x.count(); err != nil {
+			return err
+		}
+		if !file.Mode().IsRegular() || !match(file.Name) {
+			continue
+		}
+
+		if file.UncompressedSize64 > uint64(x.remaining()) {
+			return x.sizeError()
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+
+		return x.copy(w, rc)
+	}
+
+	return errors.New("No matching file found in zip archive.")
+}
+
+func extractTarGz(x *extraction, f *os.File, size int64, match func(string) bool, w io.Writer) error {
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	r := tar.NewReader(gz)
+	for {
+		hdr, err := r.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("Invalid tar archive: %v", err)
+		}
+		if err := x.count(); err != nil {
+			return err
+		}
+
+		if hdr.Typeflag == tar.TypeLink || !hdr.FileInfo().Mode().IsRegular() || !match(hdr.Name) {
+			continue
+		}
+
+		return x.copy(w, r)
+	}
+
+	return errors.New("No matching file found in tar archive.")
+}
@@ -0,0 +1,118 @@
+package updater
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestZip(t *testing.T, files map[string]string) []byte {
+	buf := bytes.NewBuffer(nil)
+	w := zip.NewWriter(buf)
+	for name, contents := range files {
+		f, err := w.Create(name)
+		require.Nil(t, err)
+		f.Write([]byte(contents))
+	}
+	require.Nil(t, w.Close())
+	return buf.Bytes()
+}
+
+func newTestTarGz(t *testing.T, files map[string]string) []byte {
+	buf := bytes.NewBuffer(nil)
+	gz := gzip.NewWriter(buf)
+	w := tar.NewWriter(gz)
+	for name, contents := range files {
+		err := w.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0755,
+			Size:     int64(len(contents)),
+			Typeflag: tar.TypeReg,
+		})
+		require.Nil(t, err)
+		w.Write([]byte(contents))
+	}
+	require.Nil(t, w.Close())
+	require.Nil(t, gz.Close())
+	return buf.Bytes()
+}
+
+func TestArchiveWriter(t *testing.T) {
+	files := map[string]string{
+		"myapp-1.0/README.md": "Read me",
+		"myapp-1.0/bin/myapp": "Hello World!",
+	}
+	archives := map[string]func(string, AbortWriter) *ArchiveWriter{
+		string(newTestZip(t, files)):   NewZipExtractor,
+		string(newTestTarGz(t, files)): NewTarGzExtractor,
+	}
+
+	for archive, newExtractor := range archives {
+		// Base name
+		{
+			dest := NewAbortBuffer(nil)
+			w := newExtractor("myapp", dest)
+			_, err := w.Write([]byte(archive))
+			assert.Nil(t, err)
+
+			err = w.Close()
+			assert.Nil(t, err, "Could not extract: %v", err)
+			assert.Equal(t, "Hello World!", dest.Buffer.String())
+		}
+
+		// Full path pattern
+		{
+			dest := NewAbortBuffer(nil)
+			w := newExtractor("myapp-*/README.md", dest)
+			w.Write([]byte(archive))
+
+			err := w.Close()
+			assert.Nil(t, err, "Could not extract: %v", err)
+			assert.Equal(t, "Read me", dest.Buffer.String())
+		}
+
+		// No matching file
+		{
+			dest := NewAbortBuffer(nil)
+			w := newExtractor("otherapp", dest)
+			w.Write([]byte(archive))
+
+			err := w.Close()
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), "No matching file")
+			assert.True(t, dest.aborted)
+		}
+
+		// Aborted
+		{
+			dest := NewAbortBuffer(nil)
+			w := newExtractor("myapp", dest)
+			w.Write([]byte(archive))
+			w.Abort()
+
+			_, err := w.Write([]byte("more data"))
+			assert.Error(t, err)
+
+			err = w.Close()
+			assert.Nil(t, err)
+			assert.True(t, dest.aborted)
+			assert.Equal(t, 0, dest.Buffer.Len())
+		}
+	}
+
+	// Invalid archives
+	for _, newExtractor := range archives {
+		dest := NewAbortBuffer(nil)
+		w := newExtractor("myapp", dest)
+		w.Write([]byte("invalid archive"))
+
+		err := w.Close()
+		assert.Error(t, err)
+		assert.True(t, dest.aborted)
+	}
+}
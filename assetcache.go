@@ -0,0 +1,170 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// AssetCache keeps verified assets on disk, by their SHA-256 checksum, so
+// that writing an asset again does not download it again, e.g. when a
+// release is applied again after a rollback.
+//
+// Only assets whose checksum is known before they are written are cached:
+// assets listed in the checksum file of ChecksumAssetName, and assets that
+// implement ChecksumAsset. Cached assets are verified against their checksum
+// before they are used, and the verifications of the updater still apply.
+type AssetCache struct {
+	// Directory in which the assets are kept. It is created when needed.
+	Dir string
+}
+
+// NewAssetCache creates a cache that keeps assets in dir, e.g. a directory
+// in the one returned by os.UserCacheDir.
+func NewAssetCache(dir string) *AssetCache {
+	return &AssetCache{Dir: dir}
+}
+
+// path returns the path of the asset with checksum sum.
+func (c *AssetCache) path(sum []byte) string {
+	return filepath.Join(c.Dir, hex.EncodeToString(sum))
+}
+
+// open returns the cached asset with checksum sum, or nil if it is not cached.
+// A cached asset with another checksum is removed.
+func (c *AssetCache) open(sum []byte) *os.File {
+	f, err := os.Open(c.path(sum))
+	if err != nil {
+		return nil
+	}
+
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	if err == nil && bytes.Equal(h.Sum(nil), sum) {
+		_, err = f.Seek(0, io.SeekStart)
+		if err == nil {
+			return f
+		}
+	}
+
+	f.Close()
+	os.Remove(c.path(sum))
+	return nil
+}
+
+// Remove removes all cached assets.
+func (c *AssetCache) Remove() error {
+	return os.RemoveAll(c.Dir)
+}
+
+// create returns a writer that adds the asset with checksum sum to the cache
+// when it is committed.
+func (c *AssetCache) create(sum []byte) (*cacheWriter, error) {
+	err := os.MkdirAll(c.Dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := ioutil.TempFile(c.Dir, ".tmp-")
+	if err != nil {
+		return nil, err
+	}
+	return &cacheWriter{cache: c, f: f, h: sha256.New(), sum: sum}, nil
+}
+
+// cacheWriter writes an asset to a temporary file of the cache. It never
+// fails, so that a full disk does not fail the download; the asset is not
+// cached instead.
+type cacheWriter struct {
+	cache *AssetCache
+	f     *os.File
+	h     hash.Hash
+	sum   []byte
+	err   error
+}
+
+func (w *cacheWriter) Write(b []byte) (int, error) {
+	if w.err == nil {
+		_, w.err = w.f.Write(b)
+		w.h.Write(b)
+	}
+	return len(b), nil
+}
+
+// commit adds the asset to the cache if it was written completely and has
+// the expected checksum, and removes the temporary file otherwise.
+func (w *cacheWriter) commit() error {
+	err := w.f.Close()
+	if err == nil {
+		err = w.err
+	}
+	if err == nil && bytes.Equal(w.h.Sum(nil), w.sum) {
+		err = os.Rename(w.f.Name(), w.cache.path(w.sum))
+	}
+	os.Remove(w.f.Name())
+	return err
+}
+
+// discard removes the temporary file.
+func (w *cacheWriter) discard() {
+	w.f.Close()
+	os.Remove(w.f.Name())
+}
+
+// expectedChecksum returns the SHA-256 checksum that asset a should have, or
+// nil if it is not known before the asset is written.
+func expectedChecksum(checksums map[string][]byte, a Asset) []byte {
+	if sum, ok := checksums[a.Name()]; ok && len(sum) == sha256.Size {
+		return sum
+	}
+	if c, ok := a.(ChecksumAsset); ok {
+		if sum, err := hex.DecodeString(c.SHA256()); err == nil && len(sum) == sha256.Size {
+			return sum
+		}
+	}
+	return nil
+}
+
+// writeCached writes asset a to w from the AssetCache of the updater if it
+// is cached, or else writes it with writeAsset and adds it to the cache when
+// commit is called with the result of all verifications.
+func (u *Updater) writeCached(ctx context.Context, checksums map[string][]byte, a Asset, w io.Writer) (commit func(error), err error) {
+	commit = func(error) {}
+	sum := expectedChecksum(checksums, a)
+	if u.AssetCache == nil || sum == nil {
+		return commit, u.writeAsset(ctx, a, w)
+	}
+
+	if f := u.AssetCache.open(sum); f != nil {
+		defer f.Close()
+		u.logf("Writing asset %v from the cache", a.Name())
+		if t, ok := w.(totalSetter); ok {
+			if info, err := f.Stat(); err == nil {
+				t.setTotal(info.Size())
+			}
+		}
+		_, err := io.Copy(w, f)
+		return commit, err
+	}
+
+	cw, err := u.AssetCache.create(sum)
+	if err != nil {
+		u.logf("Could not cache asset %v: %v", a.Name(), err)
+		return commit, u.writeAsset(ctx, a, w)
+	}
+
+	commit = func(err error) {
+		if err != nil {
+			cw.discard()
+		} else if err := cw.commit(); err != nil {
+			u.logf("Could not cache asset %v: %v", a.Name(), err)
+		}
+	}
+	return commit, u.writeAsset(ctx, a, teeWriter(w, cw))
+}
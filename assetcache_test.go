@@ -0,0 +1,114 @@
+package updater
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdaterAssetCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testing-")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	contents := "Hello World!"
+	sum := sha256.Sum256([]byte(contents))
+	downloads := 0
+	var downloadErr error
+	asset := &testAsset{
+		name: "myapp",
+		write: func(w io.Writer) error {
+			downloads++
+			if downloadErr != nil {
+				io.WriteString(w, contents[:5])
+				return downloadErr
+			}
+			_, err := io.WriteString(w, contents)
+			return err
+		},
+	}
+	sums := &testAsset{
+		name: "SHA256SUMS",
+		write: func(w io.Writer) error {
+			_, err := fmt.Fprintf(w, "%x  myapp\n", sum)
+			return err
+		},
+	}
+	release := &testRelease{name: "v2", assets: []Asset{asset, sums}}
+
+	cache := NewAssetCache(filepath.Join(dir, "cache"))
+	update := func() (string, error) {
+		w := NewAbortBuffer(nil)
+		u := &Updater{
+			ChecksumAssetName: "SHA256SUMS",
+			AssetCache:        cache,
+			AssetFilter:       func(a Asset) bool { return a.Name() == "myapp" },
+			WriterForAsset:    func(Asset) (AbortWriter, error) { return w, nil },
+		}
+		err := u.UpdateTo(release)
+		return w.Buffer.String(), err
+	}
+
+	// Failed downloads are not cached
+	{
+		downloadErr = errors.New("Connection reset.")
+		_, err := update()
+		require.Error(t, err)
+		downloadErr = nil
+
+		files, err := ioutil.ReadDir(cache.Dir)
+		require.Nil(t, err)
+		assert.Empty(t, files)
+	}
+
+	// Downloaded and cached
+	{
+		downloads = 0
+		s, err := update()
+		require.Nil(t, err, "Could not update: %v", err)
+		assert.Equal(t, contents, s)
+		assert.Equal(t, 1, downloads)
+		assert.FileExists(t, filepath.Join(cache.Dir, hex.EncodeToString(sum[:])))
+	}
+
+	// Written from the cache
+	{
+		s, err := update()
+		require.Nil(t, err, "Could not update: %v", err)
+		assert.Equal(t, contents, s)
+		assert.Equal(t, 1, downloads)
+	}
+
+	// Corrupt cache is downloaded again
+	{
+		err := ioutil.WriteFile(filepath.Join(cache.Dir, hex.EncodeToString(sum[:])), []byte("Corrupt"), 0644)
+		require.Nil(t, err)
+
+		s, err := update()
+		require.Nil(t, err, "Could not update: %v", err)
+		assert.Equal(t, contents, s)
+		assert.Equal(t, 2, downloads)
+	}
+
+	// Assets without a known checksum are not cached
+	{
+		require.Nil(t, cache.Remove())
+		u := &Updater{
+			AssetCache:     cache,
+			WriterForAsset: func(Asset) (AbortWriter, error) { return NewAbortBuffer(nil), nil },
+		}
+		err := u.UpdateTo(&testRelease{name: "v2", assets: []Asset{asset}})
+		require.Nil(t, err, "Could not update: %v", err)
+		_, err = os.Stat(cache.Dir)
+		assert.True(t, os.IsNotExist(err))
+	}
+}
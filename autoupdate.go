@@ -0,0 +1,263 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Default time between two checks of an AutoUpdater.
+const DefaultAutoUpdateInterval = time.Hour
+
+// AutoUpdater periodically checks for updates in the background and applies
+// them.
+//
+// The first check is performed as soon as the auto updater is started.
+type AutoUpdater struct {
+	// Updater used to check for and apply updates.
+	//
+	// After an update is applied, its CurrentReleaseIdentifier is set to the
+	// identifier of the applied release.
+	Updater *Updater
+
+	// Time between two checks. Defaults to DefaultAutoUpdateInterval when
+	// zero or negative.
+	Interval time.Duration
+
+	// Maximum random delay added to every interval.
+	//
+	// Use jitter to prevent a fleet of applications started at the same time
+	// from checking for updates simultaneously.
+	Jitter time.Duration
+
+	// Function called when an update is available.
+	//
+	// The update is only applied if it returns true. When not set, every
+	// update is applied.
+	OnUpdateAvailable func(Release) bool
+
+	// Function called after an update was applied successfully.
+	OnUpdateApplied func(Release)
+
+	// Function called when checking for or applying an update fails.
+	OnError func(error)
+
+	// Maintenance windows in which updates are applied, e.g. windows parsed
+	// with ParseMaintenanceWindow.
+	//
+	// When set, updates are only applied within one of the windows. Outside
+	// the windows, the auto updater still checks for updates and downloads
+	// them to a temporary directory, and applies the downloaded update when
+	// the next window opens. By default, updates are applied at any time.
+	Windows []MaintenanceWindow
+
+	mutex  sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	// Update downloaded outside the maintenance windows.
+	pending *downloadedRelease
+}
+
+// Start runs the auto updater in a new goroutine.
+//
+// The auto updater stops when Stop is called or when ctx is cancelled. An
+// error is returned if the auto updater is already running.
+func (a *AutoUpdater) Start(ctx context.Context) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.done != nil {
+		return errors.New("The auto updater is already running.")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	a.cancel = cancel
+	a.done = done
+
+	go func() {
+		defer close(done)
+		a.Run(ctx)
+
+		// Allow starting again when ctx was cancelled instead of Stop
+		a.mutex.Lock()
+		if a.done == done {
+			a.cancel, a.done = nil, nil
+			cancel()
+		}
+		a.mutex.Unlock()
+	}()
+
+	return nil
+}
+
+// Stop stops the auto updater and waits until it has stopped.
+//
+// An update that is being applied is cancelled. Stop does nothing if the auto
+// updater is not running.
+func (a *AutoUpdater) Stop() {
+	a.mutex.Lock()
+	cancel, done := a.cancel, a.done
+	a.cancel, a.done = nil, nil
+	a.mutex.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+// Run checks for updates until ctx is cancelled.
+//
+// Run blocks, use Start to run the auto updater in the background.
+func (a *AutoUpdater) Run(ctx context.Context) {
+	defer a.discardPending()
+
+	for {
+		err := a.checkAndApply(ctx)
+
+		// Check again when the next window opens to apply a pending update
+		d := a.nextInterval()
+		if n := a.nextWindow(time.Now()); a.pending != nil && !n.IsZero() {
+			if w := time.Until(n); w < d {
+				d = w
+			}
+		}
+
+		// Don't check again while the rate limit is exceeded
+		var rateErr *RateLimitError
+		if errors.As(err, &rateErr) && time.Until(rateErr.Reset) > d {
+			d = time.Until(rateErr.Reset)
+		}
+
+		t := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// checkAndApply performs a single check and applies the update if needed. The
+// error that was reported, if any, is returned.
+func (a *AutoUpdater) checkAndApply(ctx context.Context) error {
+	r, err := a.Updater.CheckContext(ctx)
+	if err != nil {
+		a.error(ctx, err)
+		return err
+	} else if r == nil {
+		return nil
+	}
+
+	if a.OnUpdateAvailable != nil && !a.OnUpdateAvailable(r) {
+		return nil
+	}
+
+	// Only download the update outside the maintenance windows
+	if !a.inWindow(time.Now()) {
+		return a.download(ctx, r)
+	}
+
+	var release Release = r
+	if a.pending != nil && a.pending.Identifier() == r.Identifier() {
+		release = a.pending
+	}
+	err = a.Updater.UpdateToContext(ctx, release)
+	a.discardPending()
+	if err != nil {
+		a.error(ctx, err)
+		return err
+	}
+
+	a.Updater.setCurrentIdentifier(r.Identifier())
+	if a.OnUpdateApplied != nil {
+		a.OnUpdateApplied(r)
+	}
+	return nil
+}
+
+// download downloads release to apply it in the next maintenance window,
+// unless it was downloaded already. The error that was reported, if any, is
+// returned.
+func (a *AutoUpdater) download(ctx context.Context, release Release) error {
+	if a.pending != nil && a.pending.Identifier() == release.Identifier() {
+		return nil
+	}
+	a.discardPending()
+
+	dir, err := ioutil.TempDir("", downloadPrefix)
+	if err == nil {
+		a.pending, err = a.Updater.predownload(ctx, release, dir)
+	}
+	if err != nil {
+		a.error(ctx, err)
+		return err
+	}
+	return nil
+}
+
+// discardPending removes the update that was downloaded, if any.
+func (a *AutoUpdater) discardPending() {
+	if a.pending != nil {
+		a.pending.remove()
+		a.pending = nil
+	}
+}
+
+// inWindow reports whether updates may be applied at t.
+func (a *AutoUpdater) inWindow(t time.Time) bool {
+	if len(a.Windows) == 0 {
+		return true
+	}
+	for _, w := range a.Windows {
+		if w.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// nextWindow returns the time at which the next maintenance window opens, or
+// t if updates may be applied at t.
+func (a *AutoUpdater) nextWindow(t time.Time) time.Time {
+	if a.inWindow(t) {
+		return t
+	}
+
+	var next time.Time
+	for _, w := range a.Windows {
+		if n := w.next(t); !n.IsZero() && (next.IsZero() || n.Before(next)) {
+			next = n
+		}
+	}
+	return next
+}
+
+// error reports err, unless it was caused by stopping the auto updater.
+func (a *AutoUpdater) error(ctx context.Context, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+
+	if a.OnError != nil {
+		a.OnError(err)
+	}
+}
+
+// nextInterval returns the interval with a random jitter.
+func (a *AutoUpdater) nextInterval() time.Duration {
+	d := a.Interval
+	if d <= 0 {
+		d = DefaultAutoUpdateInterval
+	}
+	if a.Jitter > 0 {
+		d += time.Duration(rand.Int63n(int64(a.Jitter)))
+	}
+	return d
+}
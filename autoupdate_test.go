@@ -0,0 +1,234 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAutoUpdater(t *testing.T) {
+	// Apply update
+	{
+		release := &testRelease{identifier: "new-release"}
+		app := &testApp{
+			FLatestRelease: func() Release { return release },
+		}
+
+		applied := make(chan Release, 1)
+		a := &AutoUpdater{
+			Updater: &Updater{
+				App:                      app,
+				CurrentReleaseIdentifier: "old-release",
+			},
+			Interval: time.Hour,
+			OnUpdateApplied: func(r Release) {
+				applied <- r
+			},
+		}
+
+		err := a.Start(context.Background())
+		assert.Nil(t, err)
+
+		err = a.Start(context.Background())
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "already running")
+
+		// Check concurrently with the update
+		checked := make(chan struct{})
+		go func() {
+			defer close(checked)
+			a.Updater.Check()
+		}()
+
+		select {
+		case r := <-applied:
+			assert.Equal(t, release, r)
+		case <-time.After(time.Second):
+			assert.Fail(t, "Update was not applied.")
+		}
+		<-checked
+
+		a.Stop()
+		assert.Equal(t, "new-release", a.Updater.CurrentReleaseIdentifier)
+	}
+
+	// Declined update and errors
+	{
+		checks := 0
+		app := &testApp{
+			FQuery: func() error {
+				checks++
+				if checks == 1 {
+					return errors.New("Test query error")
+				}
+				return nil
+			},
+			FLatestRelease: func() Release {
+				return &testRelease{identifier: "new-release"}
+			},
+		}
+
+		errs := make(chan error, 1)
+		available := make(chan Release, 1)
+		a := &AutoUpdater{
+			Updater: &Updater{
+				App:                      app,
+				CurrentReleaseIdentifier: "old-release",
+			},
+			Interval: time.Millisecond,
+			Jitter:   time.Millisecond,
+			OnUpdateAvailable: func(r Release) bool {
+				select {
+				case available <- r:
+				default:
+				}
+				return false
+			},
+			OnUpdateApplied: func(r Release) {
+				assert.Fail(t, "Declined update was applied.")
+			},
+			OnError: func(err error) {
+				errs <- err
+			},
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		err := a.Start(ctx)
+		assert.Nil(t, err)
+
+		select {
+		case err := <-errs:
+			assert.Contains(t, err.Error(), "Test query error")
+		case <-time.After(time.Second):
+			assert.Fail(t, "Error was not reported.")
+		}
+
+		select {
+		case <-available:
+		case <-time.After(time.Second):
+			assert.Fail(t, "Update was not reported.")
+		}
+
+		cancel()
+		a.Stop()
+		assert.Equal(t, "old-release", a.Updater.CurrentReleaseIdentifier)
+	}
+
+	// Wait for the rate limit to reset
+	{
+		var checks int32
+		app := &testApp{
+			FQuery: func() error {
+				atomic.AddInt32(&checks, 1)
+				return &RateLimitError{Reset: time.Now().Add(time.Hour)}
+			},
+		}
+
+		a := &AutoUpdater{
+			Updater:  &Updater{App: app},
+			Interval: time.Millisecond,
+		}
+
+		err := a.Start(context.Background())
+		assert.Nil(t, err)
+		time.Sleep(50 * time.Millisecond)
+		a.Stop()
+		assert.Equal(t, int32(1), atomic.LoadInt32(&checks))
+	}
+
+	// Default interval and restart after the context is cancelled
+	{
+		var checks int32
+		app := &testApp{
+			FQuery: func() error {
+				atomic.AddInt32(&checks, 1)
+				return nil
+			},
+			FLatestRelease: func() Release { return &testRelease{identifier: "v1"} },
+		}
+
+		a := &AutoUpdater{Updater: &Updater{App: app, CurrentReleaseIdentifier: "v1"}}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		err := a.Start(ctx)
+		assert.Nil(t, err)
+		time.Sleep(50 * time.Millisecond)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&checks))
+
+		cancel()
+		require.Eventually(t, func() bool {
+			return a.Start(context.Background()) == nil
+		}, time.Second, time.Millisecond)
+		a.Stop()
+	}
+
+	// Download outside the maintenance windows
+	{
+		downloads := 0
+		release := &testRelease{identifier: "new-release", assets: []Asset{&testAsset{
+			name: "myapp",
+			write: func(w io.Writer) error {
+				downloads++
+				_, err := io.WriteString(w, "Hello World!")
+				return err
+			},
+		}}}
+		app := &testApp{
+			FLatestRelease: func() Release { return release },
+		}
+
+		var b *AbortBuffer
+		var applied Release
+		now := time.Now()
+		a := &AutoUpdater{
+			Updater: &Updater{
+				App:                      app,
+				CurrentReleaseIdentifier: "old-release",
+				WriterForAsset: func(Asset) (AbortWriter, error) {
+					b = NewAbortBuffer(nil)
+					return b, nil
+				},
+			},
+			Windows: []MaintenanceWindow{{
+				Days:  []time.Weekday{(now.Weekday() + 3) % 7},
+				Start: 0,
+				End:   24 * time.Hour,
+			}},
+			OnUpdateApplied: func(r Release) {
+				applied = r
+			},
+		}
+		ctx := context.Background()
+
+		require.Nil(t, a.checkAndApply(ctx))
+		require.Nil(t, a.checkAndApply(ctx))
+		assert.Nil(t, b)
+		assert.Equal(t, 1, downloads)
+		require.NotNil(t, a.pending)
+		dir := a.pending.dir
+		assert.True(t, a.nextWindow(now).After(now))
+
+		a.Windows[0].Days = nil
+		require.Nil(t, a.checkAndApply(ctx))
+		require.NotNil(t, b)
+		assert.Equal(t, "Hello World!", b.Buffer.String())
+		assert.Equal(t, 1, downloads)
+		assert.Equal(t, release, applied)
+		assert.Nil(t, a.pending)
+		_, err := os.Stat(dir)
+		assert.True(t, os.IsNotExist(err))
+	}
+
+	// Stop when not running
+	{
+		a := &AutoUpdater{}
+		a.Stop()
+	}
+}
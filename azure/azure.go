@@ -0,0 +1,85 @@
+// Package azure fetches the releases of an application from an Azure Blob
+// Storage container:
+//
+//	client, err := azblob.NewClient(serviceURL, credential, nil)
+//	if err != nil {
+//		return err
+//	}
+//	app := azure.New(client, "releases", "myapp/")
+//	u := &updater.Updater{App: app}
+//
+// See updater.NewObjectStore for the layout of the blobs under the prefix.
+package azure
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	updater "github.com/hverr/go-updater"
+)
+
+// Client is the part of the Azure Blob Storage API used to fetch releases.
+//
+// It is implemented by *azblob.Client from the Azure SDK, which is created
+// with the credentials of the storage account.
+type Client interface {
+	DownloadStream(ctx context.Context, containerName, blobName string, o *azblob.DownloadStreamOptions) (azblob.DownloadStreamResponse, error)
+	NewListBlobsFlatPager(containerName string, o *azblob.ListBlobsFlatOptions) *runtime.Pager[azblob.ListBlobsFlatResponse]
+}
+
+type store struct {
+	client    Client
+	container string
+}
+
+// New creates an Application whose releases are stored in an Azure Blob
+// Storage container.
+//
+// See updater.NewObjectStore for the layout of the blobs under prefix.
+func New(client Client, container, prefix string) updater.App {
+	return updater.NewObjectStore(&store{
+		client:    client,
+		container: container,
+	}, prefix)
+}
+
+func (s *store) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	pager := s.client.NewListBlobsFlatPager(s.container, &azblob.ListBlobsFlatOptions{
+		Prefix: &prefix,
+	})
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if page.Segment == nil {
+			continue
+		}
+		for _, b := range page.Segment.BlobItems {
+			if b != nil && b.Name != nil {
+				keys = append(keys, *b.Name)
+			}
+		}
+	}
+
+	return keys, nil
+}
+
+func (s *store) Get(ctx context.Context, key string, w io.Writer) error {
+	resp, err := s.client.DownloadStream(ctx, s.container, key, nil)
+	if err != nil {
+		return fmt.Errorf("Could not download blob %v/%v: %v", s.container, key, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.ContentLength != nil {
+		updater.SetTotalSize(w, *resp.ContentLength)
+	}
+
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
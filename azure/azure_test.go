@@ -0,0 +1,103 @@
+package azure
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testClient serves blobs from memory in pages of one blob.
+type testClient struct {
+	container string
+	blobs     map[string]string
+}
+
+func (c *testClient) DownloadStream(ctx context.Context, containerName, blobName string, o *azblob.DownloadStreamOptions) (azblob.DownloadStreamResponse, error) {
+	data, ok := c.blobs[blobName]
+	if containerName != c.container || !ok {
+		return azblob.DownloadStreamResponse{}, errors.New("BlobNotFound")
+	}
+
+	return azblob.DownloadStreamResponse{
+		DownloadResponse: blob.DownloadResponse{
+			Body:          ioutil.NopCloser(strings.NewReader(data)),
+			ContentLength: to.Ptr(int64(len(data))),
+		},
+	}, nil
+}
+
+func (c *testClient) NewListBlobsFlatPager(containerName string, o *azblob.ListBlobsFlatOptions) *runtime.Pager[azblob.ListBlobsFlatResponse] {
+	var keys []string
+	for k := range c.blobs {
+		if containerName == c.container && strings.HasPrefix(k, *o.Prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	i := 0
+	return runtime.NewPager(runtime.PagingHandler[azblob.ListBlobsFlatResponse]{
+		More: func(azblob.ListBlobsFlatResponse) bool {
+			return i < len(keys)
+		},
+		Fetcher: func(context.Context, *azblob.ListBlobsFlatResponse) (azblob.ListBlobsFlatResponse, error) {
+			var page azblob.ListBlobsFlatResponse
+			page.Segment = &container.BlobFlatListSegment{}
+			if i < len(keys) {
+				page.Segment.BlobItems = []*container.BlobItem{{Name: to.Ptr(keys[i])}}
+				i++
+			}
+			return page, nil
+		},
+	})
+}
+
+func TestAzureBlob(t *testing.T) {
+	client := &testClient{
+		container: "releases",
+		blobs: map[string]string{
+			"myapp/latest":                         "v1.0.0",
+			"myapp/releases/v1.0.0/myapp.exe":      "Hello Windows!",
+			"myapp/releases/v1.0.0/myapp_linux_64": "Hello Linux!",
+		},
+	}
+
+	// Valid container
+	{
+		app := New(client, "releases", "myapp/")
+		err := app.Query()
+		assert.Nil(t, err, "Unexpected query error: %v", err)
+
+		r := app.LatestRelease()
+		require.NotNil(t, r)
+		assert.Equal(t, "v1.0.0", r.Name())
+		assert.Equal(t, 2, len(r.Assets()))
+
+		for _, a := range r.Assets() {
+			buf := bytes.NewBuffer(nil)
+			err := a.Write(buf)
+			assert.Nil(t, err)
+			assert.Equal(t, client.blobs["myapp/releases/v1.0.0/"+a.Name()], buf.String())
+		}
+	}
+
+	// Invalid container
+	{
+		app := New(client, "other", "myapp/")
+		err := app.Query()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "other/myapp/latest")
+	}
+}
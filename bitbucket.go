@@ -0,0 +1,262 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Root of the Bitbucket Cloud API.
+const bitbucketAPI = "https://api.bitbucket.org/2.0/"
+
+// Number of items requested per page from the Bitbucket API.
+const bitbucketPageLength = 100
+
+type bitbucketApp struct {
+	baseURL    string
+	workspace  string
+	repository string
+	token      string
+	releases   []Release
+}
+
+type bitbucketRelease struct {
+	Tag bitbucketTagJSON
+
+	assets []Asset
+}
+
+type bitbucketAsset struct {
+	Download bitbucketDownloadJSON
+
+	app *bitbucketApp
+}
+
+type bitbucketTagJSON struct {
+	Name    string `json:"name"`
+	Message string `json:"message"`
+	Target  struct {
+		Hash string `json:"hash"`
+		Date string `json:"date"`
+	} `json:"target"`
+}
+
+type bitbucketDownloadJSON struct {
+	Name  string `json:"name"`
+	Size  int64  `json:"size"`
+	Links struct {
+		Self struct {
+			Href string `json:"href"`
+		} `json:"self"`
+	} `json:"links"`
+}
+
+// NewBitbucket creates an Application that is hosted on Bitbucket Cloud.
+//
+// Every tag of the repository is a release, the most recent one being the
+// latest. The assets of a release are the repository downloads that contain
+// the tag name as a separate word, e.g. myapp-v1.2.0-linux-amd64.tar.gz for
+// tag v1.2.0.
+//
+// Set token to an access token to update from a private repository, or leave
+// it empty.
+func NewBitbucket(workspace, repository, token string) App {
+	return &bitbucketApp{
+		baseURL:    bitbucketAPI,
+		workspace:  workspace,
+		repository: repository,
+		token:      token,
+	}
+}
+
+func (app *bitbucketApp) Query() error {
+	return app.QueryContext(context.Background())
+}
+
+func (app *bitbucketApp) QueryContext(ctx context.Context) error {
+	repo := fmt.Sprintf(
+		"repositories/%v/%v/",
+		url.PathEscape(app.workspace), url.PathEscape(app.repository),
+	)
+
+	// Get all tags, most recent first
+	var tags []bitbucketTagJSON
+	u := fmt.Sprintf("%vrefs/tags?sort=-target.date&pagelen=%v", repo, bitbucketPageLength)
+	err := app.list(ctx, u, func(m json.RawMessage) error {
+		var t bitbucketTagJSON
+		err := json.Unmarshal(m, &t)
+		tags = append(tags, t)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	// Get all downloads
+	var downloads []bitbucketDownloadJSON
+	u = fmt.Sprintf("%vdownloads?pagelen=%v", repo, bitbucketPageLength)
+	err = app.list(ctx, u, func(m json.RawMessage) error {
+		var d bitbucketDownloadJSON
+		err := json.Unmarshal(m, &d)
+		downloads = append(downloads, d)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	s := make([]Release, len(tags))
+	for i, t := range tags {
+		s[i] = newBitbucketRelease(app, t, downloads)
+	}
+	app.releases = s
+
+	return nil
+}
+
+func (app *bitbucketApp) LatestRelease() Release {
+	if len(app.releases) == 0 {
+		return nil
+	}
+
+	return app.releases[0]
+}
+
+func (app *bitbucketApp) Releases() []Release {
+	return app.releases
+}
+
+// list calls f for every value of the paginated collection at u.
+func (app *bitbucketApp) list(ctx context.Context, u string, f func(json.RawMessage) error) error {
+	for u != "" {
+		var page struct {
+			Values []json.RawMessage `json:"values"`
+			Next   string            `json:"next"`
+		}
+		err := app.get(ctx, u, &page)
+		if err != nil {
+			return err
+		}
+
+		for _, v := range page.Values {
+			err = f(v)
+			if err != nil {
+				return err
+			}
+		}
+
+		u = page.Next
+	}
+
+	return nil
+}
+
+// newRequest creates a GET request for the URL u, relative to the API root if
+// it is not absolute.
+//
+// The token is only sent to the Bitbucket API.
+func (app *bitbucketApp) newRequest(u string) (*http.Request, error) {
+	if !strings.Contains(u, "://") {
+		u = app.baseURL + u
+	}
+
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if app.token != "" && strings.HasPrefix(u, app.baseURL) {
+		req.Header.Set("Authorization", "Bearer "+app.token)
+	}
+	return req, nil
+}
+
+// get performs a GET request to the Bitbucket API and decodes the response in
+// v.
+func (app *bitbucketApp) get(ctx context.Context, u string, v interface{}) error {
+	req, err := app.newRequest(u)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	buf := bytes.NewBuffer(nil)
+	err = downloadRequest(ctx, nil, req, buf)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(buf.Bytes(), v)
+}
+
+func newBitbucketRelease(app *bitbucketApp, t bitbucketTagJSON, downloads []bitbucketDownloadJSON) *bitbucketRelease {
+	var s []Asset
+	for _, d := range downloads {
+		if t.Name != "" && containsWord(d.Name, t.Name) {
+			s = append(s, &bitbucketAsset{Download: d, app: app})
+		}
+	}
+
+	return &bitbucketRelease{
+		Tag:    t,
+		assets: s,
+	}
+}
+
+func (r *bitbucketRelease) Name() string {
+	return r.Tag.Name
+}
+
+func (r *bitbucketRelease) Information() string {
+	return strings.TrimSpace(r.Tag.Message)
+}
+
+func (r *bitbucketRelease) Identifier() string {
+	return r.Tag.Target.Hash
+}
+
+func (r *bitbucketRelease) Assets() []Asset {
+	return r.assets
+}
+
+func (r *bitbucketAsset) Name() string {
+	return r.Download.Name
+}
+
+func (r *bitbucketAsset) Write(w io.Writer) error {
+	return r.WriteContext(context.Background(), w)
+}
+
+func (r *bitbucketAsset) WriteContext(ctx context.Context, w io.Writer) error {
+	req, err := r.request()
+	if err != nil {
+		return err
+	}
+
+	return downloadRequest(ctx, nil, req, w)
+}
+
+// Open requests the asset.
+func (r *bitbucketAsset) Open(ctx context.Context) (io.ReadCloser, int64, error) {
+	req, err := r.request()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return openRequest(ctx, nil, req)
+}
+
+// request returns the request that downloads the asset.
+func (r *bitbucketAsset) request() (*http.Request, error) {
+	if r.Download.Links.Self.Href == "" {
+		return nil, errors.New("No download URL available.")
+	}
+
+	return r.app.newRequest(r.Download.Links.Self.Href)
+}
@@ -0,0 +1,170 @@
+package updater
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBitbucketQuery(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+
+		var json string
+		switch r.URL.Path {
+		case "/2.0/repositories/hverr/reponame/refs/tags":
+			assert.Equal(t, "-target.date", r.URL.Query().Get("sort"))
+			if r.URL.Query().Get("page") == "2" {
+				json = validBitbucketTagsPage2JSON
+			} else {
+				json = validBitbucketTagsJSON
+			}
+		case "/2.0/repositories/hverr/reponame/downloads":
+			json = validBitbucketDownloadsJSON
+		case "/2.0/repositories/hverr/reponame/downloads/example-v1.0.0.zip":
+			w.Write([]byte("Hello World!"))
+			return
+		default:
+			require.True(t, false, "Unexpected URL path: %v", r.URL.Path)
+		}
+		w.Write([]byte(strings.Replace(json, "BASE_URL", ts.URL, -1)))
+	}))
+	defer ts.Close()
+
+	// Valid releases
+	{
+		app := NewBitbucket("hverr", "reponame", "secret")
+		app.(*bitbucketApp).baseURL = ts.URL + "/2.0/"
+		assert.Nil(t, app.LatestRelease())
+
+		err := app.Query()
+		assert.Nil(t, err, "Unexpected query error: %v", err)
+		require.Equal(t, 3, len(app.(ReleasesApp).Releases()))
+
+		release := app.LatestRelease()
+		require.NotNil(t, release)
+		assert.Equal(t, "v1.0.0", release.Name())
+		assert.Equal(t, "Description of the release", release.Information())
+		assert.Equal(t, "aa218f56b14c9653891f9e74264a383fa43fefbd", release.Identifier())
+		require.Equal(t, 1, len(release.Assets()))
+		assert.Equal(t, "example-v1.0.0.zip", release.Assets()[0].Name())
+
+		buf := bytes.NewBuffer(nil)
+		err = release.Assets()[0].Write(buf)
+		assert.Nil(t, err, "Unexpected write error: %v", err)
+		assert.Equal(t, "Hello World!", buf.String())
+
+		older := app.(*bitbucketApp).releases[1]
+		assert.Equal(t, "v0.9.0", older.Name())
+		assert.Equal(t, 1, len(older.Assets()))
+		assert.Equal(t, 0, len(app.(*bitbucketApp).releases[2].Assets()))
+	}
+
+	// Without download URL
+	{
+		a := &bitbucketAsset{}
+		err := a.Write(nil)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "No download URL")
+	}
+}
+
+func TestBitbucketQueryErrors(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/2.0/repositories/hverr/invalid/refs/tags":
+			w.Write([]byte("invalid json"))
+		case "/2.0/repositories/hverr/reponame/refs/tags":
+			w.Write([]byte(`{"values": [{"name": "v1.0.0"}]}`))
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	defer ts.Close()
+
+	// Invalid JSON
+	{
+		app := NewBitbucket("hverr", "invalid", "")
+		app.(*bitbucketApp).baseURL = ts.URL + "/2.0/"
+		assert.Error(t, app.Query())
+	}
+
+	// Missing downloads
+	{
+		app := NewBitbucket("hverr", "reponame", "")
+		app.(*bitbucketApp).baseURL = ts.URL + "/2.0/"
+		err := app.Query()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "404")
+	}
+}
+
+var validBitbucketTagsJSON = `
+{
+  "pagelen": 2,
+  "values": [
+    {
+      "name": "v1.0.0",
+      "message": "Description of the release\n",
+      "target": {
+        "hash": "aa218f56b14c9653891f9e74264a383fa43fefbd",
+        "date": "2016-04-08T12:00:00+00:00"
+      }
+    },
+    {
+      "name": "v0.9.0",
+      "message": "",
+      "target": {
+        "hash": "2e2b3ac3a89a5c55e3e3ec1dfe0b0d8e02a1fa25",
+        "date": "2016-03-08T12:00:00+00:00"
+      }
+    }
+  ],
+  "next": "BASE_URL/2.0/repositories/hverr/reponame/refs/tags?sort=-target.date&page=2"
+}
+`
+
+var validBitbucketTagsPage2JSON = `
+{
+  "pagelen": 2,
+  "values": [
+    {
+      "name": "v0.1.0",
+      "target": {
+        "hash": "c1e3d5a7f2b4a6c8e0d2f4b6a8c0e2d4f6b8a0c2"
+      }
+    }
+  ]
+}
+`
+
+var validBitbucketDownloadsJSON = `
+{
+  "values": [
+    {
+      "name": "example-v1.0.0.zip",
+      "size": 12,
+      "links": {
+        "self": {
+          "href": "BASE_URL/2.0/repositories/hverr/reponame/downloads/example-v1.0.0.zip"
+        }
+      }
+    },
+    {
+      "name": "example-v0.9.0.zip",
+      "size": 12,
+      "links": {
+        "self": {
+          "href": "BASE_URL/2.0/repositories/hverr/reponame/downloads/example-v0.9.0.zip"
+        }
+      }
+    }
+  ]
+}
+`
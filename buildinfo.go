@@ -0,0 +1,35 @@
+package updater
+
+import "runtime/debug"
+
+// BuildIdentifier returns the identifier of the running executable according
+// to its build information, to use as the CurrentReleaseIdentifier without
+// setting it with -ldflags at build time.
+//
+// If the executable was built in a version control checkout, e.g. with go
+// build, the identifier is the revision it was built from, which matches the
+// default identifiers of NewGitHub. Otherwise, e.g. when it was installed with
+// go install, it is the version of the main module, e.g. v1.2.3, which matches
+// the identifiers of GitHubTagName. An empty string is returned if neither is
+// known.
+func BuildIdentifier() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	return buildIdentifier(info)
+}
+
+// buildIdentifier returns the identifier of a build, see BuildIdentifier.
+func buildIdentifier(info *debug.BuildInfo) string {
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" && s.Value != "" {
+			return s.Value
+		}
+	}
+
+	if v := info.Main.Version; v != "(devel)" {
+		return v
+	}
+	return ""
+}
@@ -0,0 +1,38 @@
+package updater
+
+import (
+	"runtime/debug"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildIdentifier(t *testing.T) {
+	// Built in a checkout
+	{
+		info := &debug.BuildInfo{
+			Main: debug.Module{Path: "example.com/myapp", Version: "(devel)"},
+			Settings: []debug.BuildSetting{
+				{Key: "vcs", Value: "git"},
+				{Key: "vcs.revision", Value: "789611aec3d4b90512577b5dad9cf1adb6b20dcc"},
+			},
+		}
+		assert.Equal(t, "789611aec3d4b90512577b5dad9cf1adb6b20dcc", buildIdentifier(info))
+	}
+
+	// Installed with go install
+	{
+		info := &debug.BuildInfo{
+			Main: debug.Module{Path: "example.com/myapp", Version: "v1.2.3"},
+		}
+		assert.Equal(t, "v1.2.3", buildIdentifier(info))
+	}
+
+	// Unknown
+	{
+		info := &debug.BuildInfo{
+			Main: debug.Module{Path: "example.com/myapp", Version: "(devel)"},
+		}
+		assert.Equal(t, "", buildIdentifier(info))
+	}
+}
@@ -0,0 +1,288 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// BundleManifest lists the files of an asset bundle, e.g. the resources of an
+// application, so that only the files that changed are downloaded.
+//
+// It is published as a JSON asset of the release, like:
+//
+//	{
+//		"files": [
+//			{
+//				"path": "resources/app.js",
+//				"asset": "app.js",
+//				"sha256": "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"
+//			}
+//		]
+//	}
+//
+// Use SyncBundle to bring a directory up to date with the bundle of a release.
+type BundleManifest struct {
+	// Files of the bundle.
+	Files []BundleFile `json:"files"`
+}
+
+// BundleFile is a file of an asset bundle.
+type BundleFile struct {
+	// Slash-separated path of the file, relative to the bundle directory.
+	Path string `json:"path"`
+
+	// Name of the release asset with the contents of the file. Defaults to
+	// the path.
+	Asset string `json:"asset,omitempty"`
+
+	// Hexadecimal SHA-256 checksum of the file.
+	SHA256 string `json:"sha256"`
+
+	// Whether the file should be executable.
+	Executable bool `json:"executable,omitempty"`
+}
+
+// SyncBundle updates the files in dir to the asset bundle of release, which is
+// described by the BundleManifest in the asset named manifestName.
+//
+// Only the files whose checksum differs from the file on disk are downloaded.
+// They are verified with the checksums of the manifest, and with the other
+// verifications of the updater, e.g. signatures. The files are committed
+// together with an AtomicGroup when all of them were written successfully.
+// Files that are no longer part of the bundle are not removed.
+//
+// The slash-separated paths of the files that were updated are returned.
+func (u *Updater) SyncBundle(release Release, manifestName, dir string) ([]string, error) {
+	return u.SyncBundleContext(context.Background(), release, manifestName, dir)
+}
+
+// SyncBundleContext is like SyncBundle but aborts when ctx is cancelled.
+func (u *Updater) SyncBundleContext(ctx context.Context, release Release, manifestName, dir string) ([]string, error) {
+	u.updating.Lock()
+	defer u.updating.Unlock()
+
+	started := time.Now()
+	paths, err := u.syncBundle(ctx, release, manifestName, dir)
+	if err != nil {
+		u.reportOutcome(ctx, OutcomeApply, started, release, nil, err)
+		return nil, u.reportError(err)
+	}
+	u.appliedRelease(release)
+	u.reportOutcome(ctx, OutcomeApply, started, release, nil, nil)
+	return paths, nil
+}
+
+func (u *Updater) syncBundle(ctx context.Context, release Release, manifestName, dir string) ([]string, error) {
+	ctx = withLogger(withHTTPClient(ctx, u.HTTPClient), u.Logger)
+
+	unlock, err := u.lock(filepath.Join(dir, lockFileName))
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	manifest, err := u.fetchBundleManifest(ctx, release, manifestName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Find the files that changed
+	group := NewAtomicGroup()
+	files := make(map[string][]*bundleFile)
+	var paths []string
+	for _, f := range manifest.Files {
+		dst, err := bundlePath(dir, f.Path)
+		if err != nil {
+			return nil, err
+		}
+		expected, err := hex.DecodeString(f.SHA256)
+		if err != nil || len(expected) != sha256.Size {
+			return nil, fmt.Errorf("Invalid checksum for %v in bundle manifest.", f.Path)
+		}
+
+		sum, err := hashFile(dst)
+		if err != nil {
+			return nil, err
+		} else if bytes.Equal(sum, expected) {
+			continue
+		}
+
+		err = os.MkdirAll(filepath.Dir(dst), 0755)
+		if err != nil {
+			return nil, err
+		}
+
+		df := NewDelayedFile(dst)
+		if f.Executable {
+			df.Mode = 0755
+		}
+		group.Add(df)
+
+		asset := f.Asset
+		if asset == "" {
+			asset = f.Path
+		}
+		files[asset] = append(files[asset], &bundleFile{
+			file:     df,
+			path:     f.Path,
+			expected: expected,
+			hash:     sha256.New(),
+		})
+		paths = append(paths, f.Path)
+	}
+
+	if len(paths) == 0 {
+		u.logf("Bundle in %v is up to date", dir)
+		return nil, nil
+	}
+	for name := range files {
+		if findAsset(release, name) == nil {
+			abortBundle(files)
+			return nil, fmt.Errorf("Bundle asset %v not found in release.", name)
+		}
+	}
+
+	// Download the changed files, writing assets shared by multiple files to
+	// all of them
+	u.logf("Updating %v files of bundle in %v", len(paths), dir)
+	writers, err := u.writeAssets(
+		ctx, release,
+		func(a Asset) bool { return files[a.Name()] != nil },
+		func(a Asset) (AbortWriter, error) {
+			var writers []AbortWriter
+			for _, f := range files[a.Name()] {
+				writers = append(writers, f.file, NopAbortWriter(f.hash))
+			}
+			return NewTeeAbortWriter(writers...), nil
+		},
+	)
+	if err == nil {
+		err = verifyBundle(files)
+		if err != nil {
+			abortWriters(writers)
+		}
+	}
+	if err == nil {
+		err = u.validate(release, writers)
+	}
+	if err != nil {
+		abortBundle(files)
+		return nil, err
+	}
+
+	// The files are committed when the last one is closed
+	u.observer().OnApply(release)
+	for _, w := range writers {
+		if c, ok := w.(io.Closer); ok {
+			if cerr := c.Close(); cerr != nil && err == nil {
+				err = cerr
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
+// fetchBundleManifest downloads and parses the bundle manifest of release.
+func (u *Updater) fetchBundleManifest(ctx context.Context, release Release, name string) (*BundleManifest, error) {
+	a := findAsset(release, name)
+	if a == nil {
+		return nil, fmt.Errorf("Bundle manifest %v not found in release.", name)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	err := u.writeAsset(ctx, a, buf)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Verifier != nil {
+		err = u.verifySignature(ctx, release, a, buf.Bytes())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	m := &BundleManifest{}
+	err = json.Unmarshal(buf.Bytes(), m)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid bundle manifest %v: %v", name, err)
+	}
+	return m, nil
+}
+
+// bundleFile is a file of a bundle that is being updated.
+type bundleFile struct {
+	file     *DelayedFile
+	path     string
+	expected []byte
+	hash     hash.Hash
+}
+
+// verifyBundle checks that all files have the expected checksum.
+func verifyBundle(files map[string][]*bundleFile) error {
+	for _, fs := range files {
+		for _, f := range fs {
+			if sum := f.hash.Sum(nil); !bytes.Equal(sum, f.expected) {
+				return fmt.Errorf(
+					"Checksum mismatch for %v: expected %x, got %x",
+					f.path, f.expected, sum,
+				)
+			}
+		}
+	}
+	return nil
+}
+
+// abortBundle aborts and closes all files, so that nothing is committed.
+func abortBundle(files map[string][]*bundleFile) {
+	for _, fs := range files {
+		for _, f := range fs {
+			f.file.Abort()
+			f.file.Close()
+		}
+	}
+}
+
+// bundlePath returns the path of the bundle file p in dir. An error is
+// returned if p is not a relative path inside dir.
+func bundlePath(dir, p string) (string, error) {
+	clean := path.Clean(p)
+	if p == "" || path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") ||
+		strings.Contains(p, "\\") || filepath.VolumeName(filepath.FromSlash(clean)) != "" {
+		return "", fmt.Errorf("Invalid path %v in bundle manifest.", p)
+	}
+	return filepath.Join(dir, filepath.FromSlash(clean)), nil
+}
+
+// hashFile returns the SHA-256 checksum of the file at path, or nil if it does
+// not exist.
+func hashFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,132 @@
+package updater
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncBundle(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bundle-")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	checksum := func(s string) string {
+		sum := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+
+	var mu sync.Mutex
+	written := make(map[string]int)
+	newAsset := func(name, contents string) Asset {
+		return &testAsset{
+			name: name,
+			write: func(w io.Writer) error {
+				mu.Lock()
+				written[name]++
+				mu.Unlock()
+				_, err := w.Write([]byte(contents))
+				return err
+			},
+		}
+	}
+	newRelease := func(m BundleManifest, assets ...Asset) Release {
+		b, err := json.Marshal(m)
+		require.Nil(t, err)
+		assets = append(assets, newAsset("bundle.json", string(b)))
+		written = make(map[string]int)
+		return &testRelease{identifier: "v2", assets: assets}
+	}
+
+	require.Nil(t, ioutil.WriteFile(filepath.Join(dir, "unchanged.txt"), []byte("Unchanged"), 0644))
+	require.Nil(t, ioutil.WriteFile(filepath.Join(dir, "changed.txt"), []byte("Old"), 0644))
+
+	// Only download changed files
+	{
+		r := newRelease(
+			BundleManifest{Files: []BundleFile{
+				{Path: "unchanged.txt", SHA256: checksum("Unchanged")},
+				{Path: "changed.txt", SHA256: checksum("New")},
+				{Path: "resources/app.js", Asset: "shared.js", SHA256: checksum("Shared")},
+				{Path: "resources/copy.js", Asset: "shared.js", SHA256: checksum("Shared")},
+				{Path: "bin/tool", Asset: "tool", SHA256: checksum("Tool"), Executable: true},
+			}},
+			newAsset("unchanged.txt", "Unchanged"),
+			newAsset("changed.txt", "New"),
+			newAsset("shared.js", "Shared"),
+			newAsset("tool", "Tool"),
+		)
+
+		u := &Updater{}
+		paths, err := u.SyncBundle(r, "bundle.json", dir)
+		require.Nil(t, err, "Could not sync bundle: %v", err)
+		assert.Equal(t, []string{"changed.txt", "resources/app.js", "resources/copy.js", "bin/tool"}, paths)
+		assert.Equal(t, map[string]int{"bundle.json": 1, "changed.txt": 1, "shared.js": 1, "tool": 1}, written)
+
+		for p, contents := range map[string]string{
+			"unchanged.txt":     "Unchanged",
+			"changed.txt":       "New",
+			"resources/app.js":  "Shared",
+			"resources/copy.js": "Shared",
+			"bin/tool":          "Tool",
+		} {
+			data, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(p)))
+			assert.Nil(t, err)
+			assert.Equal(t, contents, string(data))
+		}
+
+		// Nothing to do the second time
+		paths, err = u.SyncBundle(r, "bundle.json", dir)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(paths))
+	}
+
+	// Checksum mismatch
+	{
+		r := newRelease(
+			BundleManifest{Files: []BundleFile{
+				{Path: "changed.txt", SHA256: checksum("Newer")},
+				{Path: "other.txt", SHA256: checksum("Corrupt")},
+			}},
+			newAsset("changed.txt", "Newer"),
+			newAsset("other.txt", "Corrupted"),
+		)
+
+		_, err := (&Updater{}).SyncBundle(r, "bundle.json", dir)
+		if assert.NotNil(t, err) {
+			assert.Contains(t, err.Error(), "Checksum mismatch for other.txt")
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(dir, "changed.txt"))
+		assert.Nil(t, err)
+		assert.Equal(t, "New", string(data))
+		_, err = os.Stat(filepath.Join(dir, "other.txt"))
+		assert.True(t, os.IsNotExist(err))
+	}
+
+	// Invalid manifests
+	{
+		for _, f := range []BundleFile{
+			{Path: "../outside.txt", SHA256: checksum("")},
+			{Path: "/etc/passwd", SHA256: checksum("")},
+			{Path: "missing.txt", SHA256: checksum("")},
+			{Path: "changed.txt", SHA256: "invalid"},
+		} {
+			r := newRelease(BundleManifest{Files: []BundleFile{f}})
+			_, err := (&Updater{}).SyncBundle(r, "bundle.json", dir)
+			assert.NotNil(t, err, "No error for %v", f.Path)
+		}
+
+		_, err := (&Updater{}).SyncBundle(&testRelease{}, "bundle.json", dir)
+		assert.NotNil(t, err)
+	}
+}
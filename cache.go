@@ -0,0 +1,34 @@
+package updater
+
+import (
+	"errors"
+	"net/http"
+)
+
+// errNotModified is returned when a conditional request found that a resource
+// did not change.
+var errNotModified = errors.New("Not modified.")
+
+// cacheValidator holds the validators of a response, used to make a
+// conditional request for the same resource later.
+type cacheValidator struct {
+	etag         string
+	lastModified string
+}
+
+func newCacheValidator(resp *http.Response) cacheValidator {
+	return cacheValidator{
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+	}
+}
+
+// apply makes req conditional.
+func (c cacheValidator) apply(req *http.Request) {
+	if c.etag != "" {
+		req.Header.Set("If-None-Match", c.etag)
+	}
+	if c.lastModified != "" {
+		req.Header.Set("If-Modified-Since", c.lastModified)
+	}
+}
@@ -0,0 +1,37 @@
+package updater
+
+// ChangelogSince returns all releases that are newer than the release with the
+// given identifier, the most recent one first.
+//
+// It uses the release information of the last query, so call Check first.
+// Show the Information of every returned release to display the cumulative
+// release notes. When the release with the identifier is the latest one, an
+// empty list is returned.
+//
+// If the application does not implement ReleasesApp, only the latest release
+// is returned. If the application has no releases, ErrNoRelease is returned,
+// and if no release has the identifier, ErrReleaseNotFound is returned.
+func (u *Updater) ChangelogSince(identifier string) ([]Release, error) {
+	latest := u.App.LatestRelease()
+	if latest == nil {
+		return nil, ErrNoRelease
+	}
+
+	if latest.Identifier() == identifier {
+		return nil, nil
+	}
+
+	app, ok := u.App.(ReleasesApp)
+	if !ok {
+		return []Release{latest}, nil
+	}
+
+	releases := app.Releases()
+	for i, r := range releases {
+		if identifier != "" && r.Identifier() == identifier {
+			return releases[:i:i], nil
+		}
+	}
+
+	return nil, ErrReleaseNotFound
+}
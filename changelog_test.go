@@ -0,0 +1,65 @@
+package updater
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testReleasesApp struct {
+	testApp
+	releases []Release
+}
+
+func (a *testReleasesApp) Releases() []Release {
+	return a.releases
+}
+
+func TestUpdaterChangelogSince(t *testing.T) {
+	v3 := &testRelease{name: "v3", identifier: "c3"}
+	v2 := &testRelease{name: "v2", identifier: "c2"}
+	v1 := &testRelease{name: "v1", identifier: "c1"}
+
+	app := &testReleasesApp{releases: []Release{v3, v2, v1}}
+	app.FLatestRelease = func() Release { return v3 }
+	u := &Updater{App: app}
+
+	// Releases since an older release
+	{
+		r, err := u.ChangelogSince("c1")
+		assert.Nil(t, err)
+		assert.Equal(t, []Release{v3, v2}, r)
+	}
+
+	// Up to date
+	{
+		r, err := u.ChangelogSince("c3")
+		assert.Nil(t, err)
+		assert.Empty(t, r)
+	}
+
+	// Unknown release
+	{
+		r, err := u.ChangelogSince("c0")
+		assert.Nil(t, r)
+		assert.Equal(t, ErrReleaseNotFound, err)
+
+		_, err = u.ChangelogSince("")
+		assert.Equal(t, ErrReleaseNotFound, err)
+	}
+
+	// Without history
+	{
+		u := &Updater{App: &app.testApp}
+		r, err := u.ChangelogSince("c1")
+		assert.Nil(t, err)
+		assert.Equal(t, []Release{v3}, r)
+	}
+
+	// No releases
+	{
+		u := &Updater{App: &testApp{}}
+		_, err := u.ChangelogSince("c1")
+		assert.Equal(t, ErrNoRelease, err)
+	}
+}
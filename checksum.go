@@ -0,0 +1,92 @@
+package updater
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// maxChecksumsSize is the maximum size of a checksum asset, which is read into
+// memory.
+const maxChecksumsSize = 1 << 20
+
+// fetchChecksums downloads and parses the checksum asset of the release.
+//
+// If a verifier is set, the signature of the checksum asset is verified too.
+func (u *Updater) fetchChecksums(ctx context.Context, release Release) (map[string][]byte, error) {
+	a := findAsset(release, u.ChecksumAssetName)
+	if a == nil {
+		return nil, fmt.Errorf("Checksum asset %v not found in release.", u.ChecksumAssetName)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	err := u.writeAsset(ctx, a, newSizeLimitWriter(a, buf, maxChecksumsSize))
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Verifier != nil {
+		err = u.verifySignature(ctx, release, a, buf.Bytes())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return parseChecksums(buf)
+}
+
+// parseChecksums parses a checksum file in the format produced by sha256sum.
+//
+// Each line contains a hexadecimal checksum followed by whitespace and the
+// file name, optionally prefixed by an asterisk for binary mode. The file name
+// is the rest of the line, so it may contain spaces. Empty lines are ignored.
+func parseChecksums(r io.Reader) (map[string][]byte, error) {
+	checksums := make(map[string][]byte)
+
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" {
+			continue
+		}
+
+		i := strings.IndexAny(line, " \t")
+		if i < 0 {
+			return nil, fmt.Errorf("Invalid checksum line: %v", line)
+		}
+		name := strings.TrimPrefix(strings.TrimLeft(line[i:], " \t"), "*")
+		if name == "" {
+			return nil, fmt.Errorf("Invalid checksum line: %v", line)
+		}
+
+		sum, err := hex.DecodeString(line[:i])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid checksum line: %v", line)
+		}
+
+		checksums[name] = sum
+	}
+
+	return checksums, s.Err()
+}
+
+// verifyChecksum checks that sum is the expected checksum of asset a.
+func verifyChecksum(checksums map[string][]byte, a Asset, sum []byte) error {
+	expected, ok := checksums[a.Name()]
+	if !ok {
+		return fmt.Errorf("No checksum available for %v.", a.Name())
+	}
+
+	if !bytes.Equal(expected, sum) {
+		return fmt.Errorf(
+			"Checksum mismatch for %v: expected %x, got %x",
+			a.Name(), expected, sum,
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package updater
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseChecksums(t *testing.T) {
+	// Valid checksums
+	{
+		sums, err := parseChecksums(strings.NewReader(
+			"0a0b  myapp_linux_amd64\n\nff00 *myapp_windows_amd64.exe\n" +
+				"0c0d  My App.dmg\n0e0f *My App Setup.exe\n",
+		))
+		assert.Nil(t, err)
+		assert.Equal(t, map[string][]byte{
+			"myapp_linux_amd64":       {0x0a, 0x0b},
+			"myapp_windows_amd64.exe": {0xff, 0x00},
+			"My App.dmg":              {0x0c, 0x0d},
+			"My App Setup.exe":        {0x0e, 0x0f},
+		}, sums)
+	}
+
+	// Invalid checksums
+	{
+		_, err := parseChecksums(strings.NewReader("zz  myapp\n"))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "Invalid checksum")
+
+		_, err = parseChecksums(strings.NewReader("0a0b\n"))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "Invalid checksum")
+
+		_, err = parseChecksums(strings.NewReader("0a0b *\n"))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "Invalid checksum")
+	}
+}
+
+func TestUpdaterChecksums(t *testing.T) {
+	data := "Hello World!"
+	newAsset := func(name string) *testAsset {
+		return &testAsset{
+			name: name,
+			write: func(w io.Writer) error {
+				_, err := io.WriteString(w, data)
+				return err
+			},
+		}
+	}
+	checksumAsset := func(sums string) *testAsset {
+		return &testAsset{
+			name: "SHA256SUMS",
+			write: func(w io.Writer) error {
+				_, err := io.WriteString(w, sums)
+				return err
+			},
+		}
+	}
+	validSum := fmt.Sprintf("%x  myapp\n", sha256.Sum256([]byte(data)))
+
+	// Valid checksum
+	{
+		b := NewAbortBuffer(nil)
+		u := &Updater{
+			ChecksumAssetName: "SHA256SUMS",
+			WriterForAsset: func(a Asset) (AbortWriter, error) {
+				if a.Name() == "myapp" {
+					return b, nil
+				}
+				return nil, nil
+			},
+		}
+
+		r := &testRelease{assets: []Asset{newAsset("myapp"), checksumAsset(validSum)}}
+		err := u.UpdateTo(r)
+		assert.Nil(t, err)
+		assert.Equal(t, data, b.Buffer.String())
+		assert.False(t, b.aborted)
+	}
+
+	// Checksum mismatch
+	{
+		b := NewAbortBuffer(nil)
+		u := &Updater{
+			ChecksumAssetName: "SHA256SUMS",
+			WriterForAsset: func(a Asset) (AbortWriter, error) {
+				return b, nil
+			},
+		}
+
+		r := &testRelease{assets: []Asset{newAsset("myapp"), checksumAsset("0a0b  myapp\n")}}
+		err := u.UpdateTo(r)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "mismatch")
+		assert.True(t, b.aborted)
+	}
+
+	// Missing checksum
+	{
+		b := NewAbortBuffer(nil)
+		u := &Updater{
+			ChecksumAssetName: "SHA256SUMS",
+			WriterForAsset: func(a Asset) (AbortWriter, error) {
+				return b, nil
+			},
+		}
+
+		r := &testRelease{assets: []Asset{newAsset("otherapp"), checksumAsset(validSum)}}
+		err := u.UpdateTo(r)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "No checksum")
+		assert.True(t, b.aborted)
+	}
+
+	// Checksum asset too large
+	{
+		u := &Updater{
+			ChecksumAssetName: "SHA256SUMS",
+			WriterForAsset: func(a Asset) (AbortWriter, error) {
+				return NewAbortBuffer(nil), nil
+			},
+		}
+
+		sums := validSum + strings.Repeat("\n", maxChecksumsSize)
+		r := &testRelease{assets: []Asset{newAsset("myapp"), checksumAsset(sums)}}
+		err := u.UpdateTo(r)
+		var tooLarge *AssetTooLargeError
+		assert.True(t, errors.As(err, &tooLarge), "Unexpected error: %v", err)
+	}
+
+	// No checksum asset
+	{
+		u := &Updater{
+			ChecksumAssetName: "SHA256SUMS",
+		}
+
+		err := u.UpdateTo(&testRelease{assets: []Asset{newAsset("myapp")}})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "not found")
+	}
+}
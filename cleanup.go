@@ -0,0 +1,109 @@
+package updater
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Time after which the temporary files of an update are stale, see Cleanup.
+const staleAge = 24 * time.Hour
+
+// Prefix of the temporary files and directories of updates, so that Cleanup
+// never touches the files of other programs in the shared temporary directory.
+const tempPrefix = "go-updater-"
+
+// Prefixes of the temporary downloads and installers.
+const (
+	downloadPrefix  = tempPrefix + "download-"
+	installerPrefix = tempPrefix + "installer-"
+)
+
+// Prefixes of the temporary files and directories of updates in the
+// temporary directory.
+var tempPrefixes = []string{atomicFilePrefix, downloadPrefix, installerPrefix}
+
+// Cleanup removes the files that interrupted updates left behind, like
+// temporary files of assets, and the releases of the DirInstaller beyond the
+// number that it keeps.
+//
+// Temporary files are only removed when they were not modified for a day, so
+// that updates that are still running in other processes are not affected.
+// Call it when the application starts, e.g. next to RemoveOldExecutable.
+func (u *Updater) Cleanup() error {
+	var firstErr error
+	clean := func(dir string, prefixes ...string) {
+		if err := u.removeStale(dir, prefixes); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	clean(os.TempDir(), tempPrefixes...)
+	if u.Installer != nil && u.Installer.Dir != "" {
+		clean(u.Installer.Dir, installerPrefix)
+	}
+	if s, ok := u.State.(*FileStateStore); ok {
+		clean(filepath.Dir(s.Path), filepath.Base(s.Path)+".tmp")
+	}
+
+	// Executable of an interrupted SelfUpdate, and the temporary files of the
+	// DelayedFile that writes it
+	if exe, err := osExecutable(); err == nil {
+		if exe, err = filepath.EvalSymlinks(exe); err == nil {
+			name := filepath.Base(exe)
+			clean(filepath.Dir(exe), name+".new", "."+name+".tmp-")
+		}
+	}
+
+	if u.StageDir != "" {
+		clean(u.StageDir, ".tmp-")
+	}
+	if u.DirInstaller != nil {
+		clean(filepath.Join(u.DirInstaller.Dir, dirInstallerReleases), ".tmp-")
+		u.DirInstaller.clean()
+	}
+
+	return firstErr
+}
+
+// removeStale removes the stale files and directories in dir whose name
+// is one of the prefixes followed by a random number, or the prefix itself.
+func (u *Updater) removeStale(dir string, prefixes []string) error {
+	entries, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var firstErr error
+	for _, e := range entries {
+		if !isTempName(e.Name(), prefixes) || time.Since(e.ModTime()) < staleAge {
+			continue
+		}
+
+		p := filepath.Join(dir, e.Name())
+		u.logf("Removing stale file %v", p)
+		if err := os.RemoveAll(p); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// isTempName reports whether name is one of the prefixes followed by a
+// random number, or the prefix itself.
+func isTempName(name string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		rest := strings.TrimPrefix(name, prefix)
+		if strings.Trim(rest, "0123456789") == "" {
+			return true
+		}
+	}
+	return false
+}
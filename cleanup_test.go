@@ -0,0 +1,92 @@
+package updater
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdaterCleanup(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("The temporary directory is not set with TMPDIR on Windows.")
+	}
+
+	dir, err := ioutil.TempDir("", "testing-")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	tmp := filepath.Join(dir, "tmp")
+	require.Nil(t, os.Mkdir(tmp, 0755))
+	defer os.Setenv("TMPDIR", os.Getenv("TMPDIR"))
+	os.Setenv("TMPDIR", tmp)
+
+	old := time.Now().Add(-48 * time.Hour)
+	create := func(p string, stale bool) string {
+		p = filepath.Join(dir, filepath.FromSlash(p))
+		require.Nil(t, os.MkdirAll(filepath.Dir(p), 0755))
+		require.Nil(t, ioutil.WriteFile(p, []byte("Stale"), 0644))
+		if stale {
+			require.Nil(t, os.Chtimes(p, old, old))
+			require.Nil(t, os.Chtimes(filepath.Dir(p), old, old))
+		}
+		return p
+	}
+	exists := func(p string) bool {
+		_, err := os.Stat(p)
+		return err == nil
+	}
+
+	staleFile := create("tmp/go-updater-atomic-123", true)
+	staleDownload := create("tmp/go-updater-download-456", true)
+	staleInstaller := create("tmp/go-updater-installer-789/myapp.msi", true)
+	recentFile := create("tmp/go-updater-atomic-124", false)
+	otherFile := create("tmp/go-updater-atomic-other", true)
+	foreignDownload := create("tmp/download-457", true)
+	staleState := create("state/state.json.tmp42", true)
+	staleStaging := create("app/releases/.tmp-7/myapp", true)
+	exe := create("bin/myapp", false)
+	staleExecutable := create("bin/myapp.new", true)
+	staleDelayed := create("bin/.myapp.tmp-123", true)
+	recentDelayed := create("bin/.myapp.tmp-124", false)
+	otherDelayed := create("bin/.other.tmp-125", true)
+	for _, v := range []string{"v1", "v2", "v3"} {
+		create("app/releases/"+v+"/bin/myapp", false)
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.Nil(t, switchLink(filepath.Join(dir, "app", "current"), filepath.Join("releases", "v3")))
+
+	defer func() { osExecutable = os.Executable }()
+	osExecutable = func() (string, error) { return exe, nil }
+
+	u := &Updater{
+		State:        NewFileStateStore(filepath.Join(dir, "state", "state.json")),
+		DirInstaller: &DirInstaller{Dir: filepath.Join(dir, "app")},
+	}
+	err = u.Cleanup()
+	assert.Nil(t, err, "Could not clean up: %v", err)
+
+	assert.False(t, exists(staleFile))
+	assert.False(t, exists(staleDownload))
+	assert.False(t, exists(filepath.Dir(staleInstaller)))
+	assert.False(t, exists(staleState))
+	assert.False(t, exists(filepath.Dir(staleStaging)))
+	assert.False(t, exists(staleExecutable))
+	assert.False(t, exists(staleDelayed))
+	assert.True(t, exists(recentFile))
+	assert.True(t, exists(otherFile))
+	assert.True(t, exists(foreignDownload))
+	assert.True(t, exists(exe))
+	assert.True(t, exists(recentDelayed))
+	assert.True(t, exists(otherDelayed))
+
+	// Current and previous release kept
+	assert.False(t, exists(filepath.Join(dir, "app", "releases", "v1")))
+	assert.True(t, exists(filepath.Join(dir, "app", "releases", "v2")))
+	assert.True(t, exists(filepath.Join(dir, "app", "releases", "v3")))
+}
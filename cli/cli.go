@@ -0,0 +1,369 @@
+// Package cli implements a command line interface that checks for and applies
+// updates as described by a configuration file.
+//
+// The go-updater command runs it, so applications can shell out to it, or
+// embed it with Main or CLI. The commands are:
+//
+//	check      check whether an update is available
+//	update     download and apply the latest release
+//	rollback   restore the files replaced by the last update
+//	changelog  show the release notes of all newer releases
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	updater "github.com/hverr/go-updater"
+)
+
+// backupSuffix is the suffix of the backups kept for rollbacks.
+const backupSuffix = ".bak"
+
+// Commands of the command line interface.
+var commands = map[string]func(c *CLI) error{
+	"check":     (*CLI).Check,
+	"update":    (*CLI).Update,
+	"rollback":  (*CLI).Rollback,
+	"changelog": (*CLI).Changelog,
+}
+
+const usage = `Usage: go-updater [-config file] command
+
+Commands:
+  check      check whether an update is available
+  update     download and apply the latest release
+  rollback   restore the files replaced by the last update
+  changelog  show the release notes of all newer releases
+
+Options:
+`
+
+// Main runs the command line interface with args, the arguments without the
+// program name, and returns the exit code.
+func Main(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("go-updater", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	config := flags.String("config", "go-updater.json", "Path of the configuration `file`.")
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+
+	err := flags.Parse(args)
+	if err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 || commands[flags.Arg(0)] == nil {
+		flags.Usage()
+		return 2
+	}
+
+	cfg, err := LoadConfig(*config)
+	if err == nil {
+		c := &CLI{Config: cfg, Stdout: stdout}
+		err = c.Run(flags.Arg(0))
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "go-updater: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// CLI runs the commands of the command line interface.
+type CLI struct {
+	// Configuration of the commands.
+	Config *Config
+
+	// Output of the commands. Defaults to os.Stdout.
+	Stdout io.Writer
+
+	// Updater used by the commands. When nil, it is created from the
+	// configuration.
+	Updater *updater.Updater
+}
+
+// Run runs the command with the given name.
+func (c *CLI) Run(command string) error {
+	f := commands[command]
+	if f == nil {
+		return fmt.Errorf("Unknown command %q.", command)
+	}
+	return f(c)
+}
+
+// Check prints whether an update is available.
+func (c *CLI) Check() error {
+	u, err := c.updater()
+	if err != nil {
+		return err
+	}
+
+	release, err := u.Check()
+	if err != nil {
+		return err
+	} else if release == nil {
+		fmt.Fprintln(c.stdout(), "Up to date.")
+		return nil
+	}
+
+	fmt.Fprintf(c.stdout(), "Update available: %v\n", release.Name())
+	return nil
+}
+
+// Update applies the latest release, if it is newer.
+//
+// The files that are replaced are backed up, so the update can be rolled back
+// if the configuration has a state file.
+func (c *CLI) Update() error {
+	u, err := c.updater()
+	if err != nil {
+		return err
+	}
+
+	release, err := u.Check()
+	if err != nil {
+		return err
+	} else if release == nil {
+		fmt.Fprintln(c.stdout(), "Up to date.")
+		return nil
+	}
+
+	for _, f := range c.Config.Files {
+		if !hasAsset(release, f.Asset) {
+			return fmt.Errorf("Asset %v not found in release %v.", f.Asset, release.Name())
+		}
+	}
+
+	// Remember which files existed to be able to roll back
+	rb := &rollback{Identifier: u.CurrentReleaseIdentifier}
+	for _, f := range c.Config.Files {
+		_, err := os.Stat(f.Path)
+		rb.Files = append(rb.Files, rollbackFile{Path: f.Path, Backup: err == nil})
+	}
+
+	tx := updater.NewTransaction()
+	tx.BackupSuffix = backupSuffix
+	u.Transaction = tx
+	u.WriterForAsset = func(a updater.Asset) (updater.AbortWriter, error) {
+		f := c.file(a.Name())
+		if f == nil {
+			return nil, nil
+		}
+
+		w := tx.File(f.Path)
+		if f.Executable {
+			w.Mode = 0755
+		}
+		return w, nil
+	}
+
+	err = u.UpdateTo(release)
+	if err != nil {
+		return err
+	}
+
+	if c.Config.State != "" {
+		err = c.saveRollback(rb)
+	} else {
+		err = tx.RemoveBackups()
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.stdout(), "Updated to %v.\n", release.Name())
+	return nil
+}
+
+// Rollback restores the files replaced by the last update.
+func (c *CLI) Rollback() error {
+	if c.Config.State == "" {
+		return errors.New("A rollback needs a state file in the configuration.")
+	}
+
+	b, err := ioutil.ReadFile(c.rollbackPath())
+	if os.IsNotExist(err) {
+		return errors.New("There is no update to roll back.")
+	} else if err != nil {
+		return err
+	}
+	rb := &rollback{}
+	err = json.Unmarshal(b, rb)
+	if err != nil {
+		return fmt.Errorf("Invalid rollback file %v: %v", c.rollbackPath(), err)
+	}
+
+	for _, f := range rb.Files {
+		if f.Backup {
+			err = os.Rename(f.Path+backupSuffix, f.Path)
+		} else {
+			err = os.Remove(f.Path)
+		}
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	store := updater.NewFileStateStore(c.Config.State)
+	state, err := store.Load()
+	if err != nil {
+		return err
+	}
+	state.LastApplied = rb.Identifier
+	err = store.Save(state)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(c.rollbackPath())
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(c.stdout(), "Rolled back the last update.")
+	return nil
+}
+
+// Changelog prints the release notes of all releases newer than the installed
+// release.
+func (c *CLI) Changelog() error {
+	u, err := c.updater()
+	if err != nil {
+		return err
+	}
+
+	_, err = u.Check()
+	if err != nil {
+		return err
+	}
+
+	releases, err := u.ChangelogSince(u.CurrentReleaseIdentifier)
+	if err != nil {
+		return err
+	} else if len(releases) == 0 {
+		fmt.Fprintln(c.stdout(), "Up to date.")
+		return nil
+	}
+
+	for i, r := range releases {
+		if i > 0 {
+			fmt.Fprintln(c.stdout())
+		}
+		fmt.Fprintln(c.stdout(), r.Name())
+		if info := strings.TrimSpace(r.Information()); info != "" {
+			fmt.Fprintf(c.stdout(), "\n%v\n", info)
+		}
+	}
+	return nil
+}
+
+// updater returns the updater of the commands, creating it from the
+// configuration the first time.
+func (c *CLI) updater() (*updater.Updater, error) {
+	if c.Updater != nil {
+		return c.Updater, nil
+	}
+
+	app, err := c.Config.Source.App()
+	if err != nil {
+		return nil, err
+	}
+
+	u := &updater.Updater{
+		App:                      app,
+		CurrentReleaseIdentifier: c.Config.Current,
+		ChecksumAssetName:        c.Config.ChecksumAsset,
+		SignatureSuffix:          c.Config.SignatureSuffix,
+		Constraint:               c.Config.Constraint,
+	}
+
+	if c.Config.PublicKey != "" {
+		u.Verifier, err = updater.ParsePublicKey([]byte(c.Config.PublicKey))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if c.Config.State != "" {
+		store := updater.NewFileStateStore(c.Config.State)
+		u.State = store
+
+		// Releases applied after the configuration was written are newer
+		// than the configured one
+		state, err := store.Load()
+		if err != nil {
+			return nil, err
+		}
+		if state.LastApplied != "" &&
+			(u.CurrentReleaseIdentifier == "" || state.LastUpdate.After(c.Config.modTime)) {
+			u.CurrentReleaseIdentifier = state.LastApplied
+		}
+	}
+
+	c.Updater = u
+	return u, nil
+}
+
+// file returns the configured file of the asset with the given name, or nil.
+func (c *CLI) file(asset string) *File {
+	for i := range c.Config.Files {
+		if c.Config.Files[i].Asset == asset {
+			return &c.Config.Files[i]
+		}
+	}
+	return nil
+}
+
+func (c *CLI) stdout() io.Writer {
+	if c.Stdout != nil {
+		return c.Stdout
+	}
+	return os.Stdout
+}
+
+// rollback records how to roll back the last update.
+type rollback struct {
+	// Identifier of the release before the update.
+	Identifier string `json:"identifier"`
+
+	// Files written by the update.
+	Files []rollbackFile `json:"files"`
+}
+
+type rollbackFile struct {
+	Path string `json:"path"`
+
+	// Whether the file existed and was backed up.
+	Backup bool `json:"backup"`
+}
+
+// rollbackPath returns the path of the file recording the last update.
+func (c *CLI) rollbackPath() string {
+	return c.Config.State + ".rollback"
+}
+
+func (c *CLI) saveRollback(rb *rollback) error {
+	b, err := json.MarshalIndent(rb, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(c.rollbackPath(), b, 0644)
+}
+
+// hasAsset reports whether release has an asset with the given name.
+func hasAsset(release updater.Release, name string) bool {
+	for _, a := range release.Assets() {
+		if a.Name() == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,191 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testManifestJSON = `{
+	"version": "v1.2.0",
+	"notes": "Bug fixes.",
+	"assets": [{
+		"name": "myapp",
+		"url": "myapp",
+		"sha256": "7f83b1657ff1fc53b92dc18148a1d65dfc2d4b1fa3d677284addd200126d9069"
+	}]
+}`
+
+func TestCLI(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/latest.json":
+			w.Write([]byte(testManifestJSON))
+		case "/myapp":
+			w.Write([]byte("Hello World!"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "cli-")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "myapp")
+	require.Nil(t, ioutil.WriteFile(path, []byte("Old version"), 0644))
+
+	newCLI := func(out *bytes.Buffer) *CLI {
+		return &CLI{
+			Config: &Config{
+				Source: Source{Type: "manifest", URL: ts.URL + "/latest.json"},
+				Files:  []File{{Asset: "myapp", Path: path, Executable: true}},
+				State:  filepath.Join(dir, "state.json"),
+			},
+			Stdout: out,
+		}
+	}
+
+	// Check and show the changelog
+	{
+		out := bytes.NewBuffer(nil)
+		require.Nil(t, newCLI(out).Run("check"))
+		assert.Equal(t, "Update available: v1.2.0\n", out.String())
+
+		out.Reset()
+		require.Nil(t, newCLI(out).Run("changelog"))
+		assert.Equal(t, "v1.2.0\n\nBug fixes.\n", out.String())
+	}
+
+	// Update
+	{
+		out := bytes.NewBuffer(nil)
+		err := newCLI(out).Run("update")
+		require.Nil(t, err, "Could not update: %v", err)
+		assert.Equal(t, "Updated to v1.2.0.\n", out.String())
+
+		data, err := ioutil.ReadFile(path)
+		assert.Nil(t, err)
+		assert.Equal(t, "Hello World!", string(data))
+		info, err := os.Stat(path)
+		require.Nil(t, err)
+		assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+
+		out.Reset()
+		require.Nil(t, newCLI(out).Run("check"))
+		assert.Equal(t, "Up to date.\n", out.String())
+	}
+
+	// Rollback
+	{
+		out := bytes.NewBuffer(nil)
+		err := newCLI(out).Run("rollback")
+		require.Nil(t, err, "Could not roll back: %v", err)
+
+		data, err := ioutil.ReadFile(path)
+		assert.Nil(t, err)
+		assert.Equal(t, "Old version", string(data))
+
+		out.Reset()
+		require.Nil(t, newCLI(out).Run("check"))
+		assert.Equal(t, "Update available: v1.2.0\n", out.String())
+
+		assert.NotNil(t, newCLI(out).Run("rollback"))
+	}
+
+	// Missing asset
+	{
+		c := newCLI(bytes.NewBuffer(nil))
+		c.Config.Files = append(c.Config.Files, File{Asset: "missing", Path: path})
+		err := c.Run("update")
+		if assert.NotNil(t, err) {
+			assert.Contains(t, err.Error(), "missing")
+		}
+	}
+}
+
+func TestCLIConfiguredCurrent(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/latest.json":
+			w.Write([]byte(testManifestJSON))
+		case "/myapp":
+			w.Write([]byte("Hello World!"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "cli-")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	config := filepath.Join(dir, "config.json")
+	writeConfig := func(modified time.Time) {
+		b, err := json.Marshal(&Config{
+			Source:  Source{Type: "manifest", URL: ts.URL + "/latest.json"},
+			Files:   []File{{Asset: "myapp", Path: filepath.Join(dir, "myapp")}},
+			Current: "v1.0.0",
+			State:   filepath.Join(dir, "state.json"),
+		})
+		require.Nil(t, err)
+		require.Nil(t, ioutil.WriteFile(config, b, 0644))
+		require.Nil(t, os.Chtimes(config, modified, modified))
+	}
+	run := func(command string) string {
+		cfg, err := LoadConfig(config)
+		require.Nil(t, err)
+		out := bytes.NewBuffer(nil)
+		require.Nil(t, (&CLI{Config: cfg, Stdout: out}).Run(command))
+		return out.String()
+	}
+
+	writeConfig(time.Now().Add(-time.Minute))
+	assert.Equal(t, "Update available: v1.2.0\n", run("check"))
+	assert.Equal(t, "Updated to v1.2.0.\n", run("update"))
+
+	// The applied release is newer than the configuration
+	assert.Equal(t, "Up to date.\n", run("check"))
+
+	// The configuration changed after the update
+	writeConfig(time.Now().Add(time.Minute))
+	assert.Equal(t, "Update available: v1.2.0\n", run("check"))
+}
+
+func TestMainExitCodes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cli-")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// Usage
+	{
+		stderr := bytes.NewBuffer(nil)
+		assert.Equal(t, 2, Main(nil, nil, stderr))
+		assert.Contains(t, stderr.String(), "Usage: go-updater")
+
+		assert.Equal(t, 2, Main([]string{"unknown"}, nil, ioutil.Discard))
+	}
+
+	// Invalid configuration
+	{
+		config := filepath.Join(dir, "config.json")
+		require.Nil(t, ioutil.WriteFile(config, []byte(`{"source": {"type": "unknown"}, "files": [{"asset": "a", "path": "a"}]}`), 0644))
+
+		stderr := bytes.NewBuffer(nil)
+		assert.Equal(t, 1, Main([]string{"-config", config, "check"}, nil, stderr))
+		assert.Contains(t, stderr.String(), "Unknown source type")
+
+		assert.Equal(t, 1, Main([]string{"-config", filepath.Join(dir, "missing.json"), "check"}, nil, ioutil.Discard))
+	}
+}
@@ -0,0 +1,185 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+
+	updater "github.com/hverr/go-updater"
+)
+
+// Config configures the command line interface. It is usually read from a
+// JSON file like:
+//
+//	{
+//		"source": {
+//			"type": "github",
+//			"owner": "hverr",
+//			"repository": "myapp"
+//		},
+//		"files": [
+//			{
+//				"asset": "myapp_linux_amd64",
+//				"path": "/opt/myapp/bin/myapp",
+//				"executable": true
+//			}
+//		],
+//		"checksum_asset": "SHA256SUMS",
+//		"public_key": "RWQf6LRCGA9i53mlYecO4IzT51TGPpvWucNSCh1CBM0QTaLn73Y7GFO3",
+//		"state": "/var/lib/myapp/updater.json"
+//	}
+type Config struct {
+	// Where the releases are published.
+	Source Source `json:"source"`
+
+	// Files to write, by asset name.
+	Files []File `json:"files"`
+
+	// Identifier of the installed release. Releases that were applied after
+	// the configuration file was last modified, according to the state file,
+	// take precedence. Defaults to the last release that was applied.
+	Current string `json:"current,omitempty"`
+
+	// Path of the state file, which records the applied release and is
+	// needed for rollbacks.
+	State string `json:"state,omitempty"`
+
+	// Name of the checksum asset, see ChecksumAssetName of the Updater.
+	ChecksumAsset string `json:"checksum_asset,omitempty"`
+
+	// Public key verifying detached signatures of assets, either a base64
+	// encoded ed25519 key or a minisign key.
+	PublicKey string `json:"public_key,omitempty"`
+
+	// Suffix of signature assets, see SignatureSuffix of the Updater.
+	SignatureSuffix string `json:"signature_suffix,omitempty"`
+
+	// Range of versions to update to, see Constraint of the Updater.
+	Constraint string `json:"constraint,omitempty"`
+
+	// Modification time of the configuration file, if it was loaded from a
+	// file.
+	modTime time.Time
+}
+
+// Source describes where the releases are published.
+type Source struct {
+	// Type of the source: github, github-tags, gitea, bitbucket, manifest,
+	// appcast, filesystem or oci.
+	Type string `json:"type"`
+
+	// Owner or workspace of the repository, or the user to log in to the
+	// registry of an oci source.
+	Owner string `json:"owner,omitempty"`
+
+	// Name of the repository, including the registry for an oci source, e.g.
+	// ghcr.io/owner/myapp.
+	Repository string `json:"repository,omitempty"`
+
+	// URL of the manifest or appcast, or of the GitHub Enterprise Server or
+	// Gitea instance.
+	URL string `json:"url,omitempty"`
+
+	// Directory of a filesystem source.
+	Path string `json:"path,omitempty"`
+
+	// Access token for private repositories. When it starts with a $, it is
+	// read from the environment variable with that name.
+	Token string `json:"token,omitempty"`
+}
+
+// File maps an asset to the file it is written to.
+type File struct {
+	// Name of the asset.
+	Asset string `json:"asset"`
+
+	// Path of the destination file.
+	Path string `json:"path"`
+
+	// Whether the file should be executable.
+	Executable bool `json:"executable,omitempty"`
+}
+
+// LoadConfig reads the configuration from the JSON file at path.
+func LoadConfig(path string) (*Config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Config{modTime: info.ModTime()}
+	err = json.Unmarshal(b, c)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid configuration %v: %v", path, err)
+	}
+
+	if len(c.Files) == 0 {
+		return nil, fmt.Errorf("Invalid configuration %v: no files configured", path)
+	}
+	return c, nil
+}
+
+// App returns the application described by the source.
+func (s *Source) App() (updater.App, error) {
+	token := s.Token
+	if len(token) > 1 && token[0] == '$' {
+		token = os.Getenv(token[1:])
+	}
+
+	switch s.Type {
+	case "github", "github-tags":
+		opts := updater.GitHubOptions{
+			BaseURL: s.URL,
+			Tags:    s.Type == "github-tags",
+		}
+		if token != "" {
+			opts.HTTPClient = &http.Client{Transport: &tokenTransport{token: token}}
+		}
+		return updater.NewGitHubWithOptions(s.Owner, s.Repository, opts)
+	case "gitea":
+		return updater.NewGitea(s.URL, s.Owner, s.Repository, token), nil
+	case "bitbucket":
+		return updater.NewBitbucket(s.Owner, s.Repository, token), nil
+	case "manifest":
+		if s.URL == "" {
+			return nil, errors.New("The manifest source needs a URL.")
+		}
+		return updater.NewHTTPManifest(s.URL, nil), nil
+	case "appcast":
+		if s.URL == "" {
+			return nil, errors.New("The appcast source needs a URL.")
+		}
+		return updater.NewAppcast(s.URL), nil
+	case "filesystem":
+		if s.Path == "" {
+			return nil, errors.New("The filesystem source needs a path.")
+		}
+		return updater.NewFileSystem(s.Path), nil
+	case "oci":
+		return updater.NewOCI(s.Repository, updater.OCIOptions{
+			Username: s.Owner,
+			Password: token,
+		})
+	default:
+		return nil, fmt.Errorf("Unknown source type %q.", s.Type)
+	}
+}
+
+// tokenTransport authenticates requests to the GitHub API with a token.
+type tokenTransport struct {
+	token string
+}
+
+func (t *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "token "+t.token)
+	return http.DefaultTransport.RoundTrip(req)
+}
@@ -0,0 +1,13 @@
+// Command go-updater checks for and applies updates as described by a
+// configuration file, see the cli package.
+package main
+
+import (
+	"os"
+
+	"github.com/hverr/go-updater/cli"
+)
+
+func main() {
+	os.Exit(cli.Main(os.Args[1:], os.Stdout, os.Stderr))
+}
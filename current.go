@@ -0,0 +1,126 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// CurrentChecksum returns the hexadecimal SHA-256 checksum of the running
+// executable.
+func CurrentChecksum() (string, error) {
+	exe, err := osExecutable()
+	if err != nil {
+		return "", err
+	}
+	exe, err = filepath.EvalSymlinks(exe)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.Open(exe)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// currentIdentifier returns the identifier of the current release: the
+// release identified by the checksum of the running executable, or the
+// CurrentReleaseIdentifier.
+func (u *Updater) currentIdentifier() string {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	if u.checksumIdentifier != "" {
+		return u.checksumIdentifier
+	}
+	return u.CurrentReleaseIdentifier
+}
+
+// setCurrentIdentifier sets the CurrentReleaseIdentifier, while other
+// goroutines may be checking for updates.
+func (u *Updater) setCurrentIdentifier(identifier string) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	u.CurrentReleaseIdentifier = identifier
+}
+
+// identifyCurrent looks for the release whose executable asset has the
+// checksum of the running executable, see IdentifyByChecksum.
+func (u *Updater) identifyCurrent(ctx context.Context) error {
+	if !u.IdentifyByChecksum {
+		return nil
+	}
+
+	s, err := CurrentChecksum()
+	if err != nil {
+		return err
+	}
+	sum, _ := hex.DecodeString(s)
+
+	releases := []Release{u.App.LatestRelease()}
+	if app, ok := u.App.(ReleasesApp); ok {
+		releases = app.Releases()
+	}
+
+	identifier := ""
+	for i, r := range releases {
+		if r == nil {
+			continue
+		}
+		expected, err := u.executableChecksum(ctx, r, i == 0)
+		if err != nil {
+			return err
+		}
+		if expected != nil && bytes.Equal(expected, sum) {
+			identifier = r.Identifier()
+			break
+		}
+	}
+
+	if identifier != "" {
+		u.logf("Running executable %v belongs to release %v", s, identifier)
+	} else {
+		u.logf("Running executable %v does not belong to a known release", s)
+	}
+	u.mutex.Lock()
+	u.checksumIdentifier = identifier
+	u.mutex.Unlock()
+	return nil
+}
+
+// executableChecksum returns the SHA-256 checksum of the executable asset of
+// release, or nil if it is not known.
+//
+// The checksum asset is only downloaded for the latest release, the checksums
+// of other releases must be known from their assets.
+func (u *Updater) executableChecksum(ctx context.Context, release Release, latest bool) ([]byte, error) {
+	a := u.executableAsset(release)
+	if a == nil {
+		return nil, nil
+	}
+
+	if c, ok := a.(ChecksumAsset); ok && c.SHA256() != "" {
+		return hex.DecodeString(c.SHA256())
+	}
+
+	if latest && u.ChecksumAssetName != "" && findAsset(release, u.ChecksumAssetName) != nil {
+		checksums, err := u.fetchChecksums(ctx, release)
+		if err != nil {
+			return nil, err
+		}
+		return checksums[a.Name()], nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,96 @@
+package updater
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testChecksumAsset struct {
+	testAsset
+	sha256 string
+}
+
+func (a *testChecksumAsset) SHA256() string {
+	return a.sha256
+}
+
+func TestIdentifyByChecksum(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testing-")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	exe := filepath.Join(dir, "myapp")
+	require.Nil(t, ioutil.WriteFile(exe, []byte("Version 1"), 0755))
+	defer func() { osExecutable = os.Executable }()
+	osExecutable = func() (string, error) { return exe, nil }
+
+	checksum := func(s string) string {
+		sum := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+	newRelease := func(name, contents string) *testRelease {
+		return &testRelease{
+			name:       name,
+			identifier: name,
+			assets: []Asset{&testChecksumAsset{
+				testAsset: testAsset{name: "myapp"},
+				sha256:    checksum(contents),
+			}},
+		}
+	}
+
+	// Checksum of the running executable
+	{
+		s, err := CurrentChecksum()
+		require.Nil(t, err)
+		assert.Equal(t, checksum("Version 1"), s)
+	}
+
+	v2 := newRelease("v2", "Version 2")
+	app := &testReleasesApp{releases: []Release{v2, newRelease("v1", "Version 1")}}
+	app.FLatestRelease = func() Release { return v2 }
+	u := &Updater{
+		App:                app,
+		AssetFilter:        func(Asset) bool { return true },
+		IdentifyByChecksum: true,
+	}
+
+	// Older release identified
+	{
+		r, err := u.Check()
+		require.Nil(t, err, "Could not check: %v", err)
+		require.NotNil(t, r)
+		assert.Equal(t, "v2", r.Name())
+		assert.Equal(t, "v1", u.currentIdentifier())
+	}
+
+	// Latest release identified
+	{
+		require.Nil(t, ioutil.WriteFile(exe, []byte("Version 2"), 0755))
+		r, err := u.Check()
+		require.Nil(t, err, "Could not check: %v", err)
+		assert.Nil(t, r)
+	}
+
+	// Unknown executable
+	{
+		require.Nil(t, ioutil.WriteFile(exe, []byte("Development build"), 0755))
+		u.CurrentReleaseIdentifier = "v2"
+		r, err := u.Check()
+		require.Nil(t, err, "Could not check: %v", err)
+		assert.Nil(t, r)
+
+		u.CurrentReleaseIdentifier = ""
+		r, err = u.Check()
+		require.Nil(t, err, "Could not check: %v", err)
+		require.NotNil(t, r)
+		assert.Equal(t, "v2", r.Name())
+	}
+}
@@ -0,0 +1,154 @@
+package updater
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// DecompressWriter is a writer that decompresses a single compressed file
+// while it is written.
+//
+// The decompressed data is streamed to the destination writer, so the
+// compressed file is never stored. When the writer is closed, the destination
+// writer is closed as well if it is an io.Closer. If the data is not valid, the
+// destination writer is aborted and an error is returned.
+type DecompressWriter struct {
+	dest AbortWriter
+	pw   *io.PipeWriter
+
+	// Closed when all data is decompressed, err is set before.
+	done chan struct{}
+	err  error
+
+	aborted bool
+}
+
+// NewGzipDecompressor creates a writer that decompresses gzip data to dest.
+func NewGzipDecompressor(dest AbortWriter) *DecompressWriter {
+	return newDecompressWriter(dest, func(r io.Reader) (io.Reader, func(), error) {
+		zr, err := gzip.NewReader(r)
+		return zr, func() {}, err
+	})
+}
+
+// NewXzDecompressor creates a writer that decompresses xz data to dest.
+func NewXzDecompressor(dest AbortWriter) *DecompressWriter {
+	return newDecompressWriter(dest, func(r io.Reader) (io.Reader, func(), error) {
+		zr, err := xz.NewReader(r)
+		return zr, func() {}, err
+	})
+}
+
+// NewZstdDecompressor creates a writer that decompresses Zstandard data to
+// dest.
+func NewZstdDecompressor(dest AbortWriter) *DecompressWriter {
+	return newDecompressWriter(dest, func(r io.Reader) (io.Reader, func(), error) {
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		return zr, zr.Close, nil
+	})
+}
+
+// NewDecompressorForAsset creates a writer that decompresses asset a to dest,
+// based on the extension of its name: .gz, .xz or .zst.
+//
+// If the asset is not compressed, dest is returned.
+func NewDecompressorForAsset(a Asset, dest AbortWriter) AbortWriter {
+	name := a.Name()
+	switch {
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return dest
+	case strings.HasSuffix(name, ".gz"):
+		return NewGzipDecompressor(dest)
+	case strings.HasSuffix(name, ".xz"):
+		return NewXzDecompressor(dest)
+	case strings.HasSuffix(name, ".zst"):
+		return NewZstdDecompressor(dest)
+	}
+	return dest
+}
+
+func newDecompressWriter(dest AbortWriter, newReader func(io.Reader) (io.Reader, func(), error)) *DecompressWriter {
+	pr, pw := io.Pipe()
+	d := &DecompressWriter{
+		dest: dest,
+		pw:   pw,
+		done: make(chan struct{}),
+	}
+
+	go func() {
+		d.err = decompress(pr, dest, newReader)
+		pr.CloseWithError(d.err)
+		close(d.done)
+	}()
+
+	return d
+}
+
+// decompress copies the data decompressed from r to w.
+func decompress(r io.Reader, w io.Writer, newReader func(io.Reader) (io.Reader, func(), error)) error {
+	zr, closeReader, err := newReader(r)
+	if err != nil {
+		return err
+	}
+	defer closeReader()
+
+	_, err = io.Copy(w, zr)
+	if err != nil {
+		return err
+	}
+
+	// Drain trailing data so the writer does not block
+	_, err = io.Copy(io.Discard, r)
+	return err
+}
+
+// Write compressed data.
+//
+// Writing fails as soon as the data turns out to be invalid.
+func (d *DecompressWriter) Write(b []byte) (int, error) {
+	if d.aborted {
+		return 0, errors.New("Write operations aborted.")
+	}
+
+	return d.pw.Write(b)
+}
+
+// Abort writing. The destination writer is aborted as well.
+func (d *DecompressWriter) Abort() {
+	d.aborted = true
+	d.pw.CloseWithError(errors.New("Write operations aborted."))
+	<-d.done
+	d.dest.Abort()
+}
+
+// Close waits until all data is decompressed and closes the destination
+// writer.
+//
+// If the data is invalid or incomplete, the destination writer is aborted and
+// an error is returned.
+func (d *DecompressWriter) Close() error {
+	d.pw.Close()
+	<-d.done
+
+	var err error
+	if !d.aborted && d.err != nil {
+		err = d.err
+		d.dest.Abort()
+	}
+
+	if c, ok := d.dest.(io.Closer); ok {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+
+	return err
+}
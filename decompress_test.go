@@ -0,0 +1,134 @@
+package updater
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ulikunitz/xz"
+)
+
+func newTestCompressed(t *testing.T, newWriter func(io.Writer) (io.WriteCloser, error), contents string) []byte {
+	buf := bytes.NewBuffer(nil)
+	w, err := newWriter(buf)
+	require.Nil(t, err)
+	w.Write([]byte(contents))
+	require.Nil(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestDecompressWriter(t *testing.T) {
+	contents := string(bytes.Repeat([]byte("Hello World!\n"), 10000))
+	formats := map[string]struct {
+		newDecompressor func(AbortWriter) *DecompressWriter
+		data            []byte
+	}{
+		"gzip": {NewGzipDecompressor, newTestCompressed(t, func(w io.Writer) (io.WriteCloser, error) {
+			return gzip.NewWriter(w), nil
+		}, contents)},
+		"xz": {NewXzDecompressor, newTestCompressed(t, func(w io.Writer) (io.WriteCloser, error) {
+			return xz.NewWriter(w)
+		}, contents)},
+		"zstd": {NewZstdDecompressor, newTestCompressed(t, func(w io.Writer) (io.WriteCloser, error) {
+			return zstd.NewWriter(w)
+		}, contents)},
+	}
+
+	for name, f := range formats {
+		// Decompress in small writes
+		{
+			dest := NewAbortBuffer(nil)
+			w := f.newDecompressor(dest)
+			for b := f.data; len(b) > 0; b = b[1:] {
+				_, err := w.Write(b[:1])
+				require.Nil(t, err, "%v: could not write: %v", name, err)
+			}
+
+			err := w.Close()
+			assert.Nil(t, err, "%v: could not decompress: %v", name, err)
+			assert.Equal(t, contents, dest.Buffer.String(), name)
+			assert.False(t, dest.aborted, name)
+		}
+
+		// Truncated data
+		{
+			dest := NewAbortBuffer(nil)
+			w := f.newDecompressor(dest)
+			w.Write(f.data[:len(f.data)/2])
+
+			err := w.Close()
+			assert.Error(t, err, name)
+			assert.True(t, dest.aborted, name)
+		}
+
+		// Invalid data
+		{
+			dest := NewAbortBuffer(nil)
+			w := f.newDecompressor(dest)
+			w.Write([]byte("invalid data"))
+
+			err := w.Close()
+			assert.Error(t, err, name)
+			assert.True(t, dest.aborted, name)
+		}
+
+		// Aborted
+		{
+			dest := NewAbortBuffer(nil)
+			w := f.newDecompressor(dest)
+			w.Write(f.data[:len(f.data)/2])
+			w.Abort()
+
+			_, err := w.Write([]byte("more data"))
+			assert.Error(t, err, name)
+
+			err = w.Close()
+			assert.Nil(t, err, name)
+			assert.True(t, dest.aborted, name)
+		}
+	}
+
+	// Delayed file
+	{
+		dir, err := ioutil.TempDir("", "go-updater-")
+		require.Nil(t, err)
+		defer os.RemoveAll(dir)
+
+		path := filepath.Join(dir, "myapp")
+		w := NewGzipDecompressor(NewDelayedFile(path))
+		_, err = w.Write(formats["gzip"].data)
+		require.Nil(t, err)
+		require.Nil(t, w.Close())
+
+		b, err := ioutil.ReadFile(path)
+		require.Nil(t, err)
+		assert.Equal(t, contents, string(b))
+	}
+}
+
+func TestNewDecompressorForAsset(t *testing.T) {
+	dest := NewAbortBuffer(nil)
+	for name, ok := range map[string]bool{
+		"myapp.gz":     true,
+		"myapp.xz":     true,
+		"myapp.zst":    true,
+		"myapp":        false,
+		"myapp.tar.gz": false,
+		"myapp.tgz":    false,
+		"myapp.zip":    false,
+	} {
+		w := NewDecompressorForAsset(&testAsset{name: name}, dest)
+		_, isDecompressor := w.(*DecompressWriter)
+		assert.Equal(t, ok, isDecompressor, name)
+		if isDecompressor {
+			w.Abort()
+		}
+	}
+}
@@ -0,0 +1,371 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Name of the link to the current release of a DirInstaller.
+const dirInstallerCurrent = "current"
+
+// Name of the directory with the releases of a DirInstaller.
+const dirInstallerReleases = "releases"
+
+// DirInstaller installs releases that consist of several files, e.g. an
+// executable with its resources, in a target directory.
+//
+// Set it as the DirInstaller of an Updater. UpdateTo then writes every
+// release to a directory of its own, and switches a symbolic link named
+// current to it once all assets were written and validated:
+//
+//	/opt/myapp/current -> releases/v1.2.0
+//	/opt/myapp/releases/v1.1.0/bin/myapp
+//	/opt/myapp/releases/v1.2.0/bin/myapp
+//	/opt/myapp/releases/v1.2.0/share/icon.png
+//
+// Run the application from the current directory, see Current. Files that
+// are not part of a new release disappear with the previous release, and
+// the previous releases that are kept can be switched back to instantly with
+// Rollback. On Unix, the link is switched atomically, so the application
+// always sees a complete release. On Windows, creating the link may require
+// the privilege to create symbolic links.
+type DirInstaller struct {
+	// Target directory.
+	Dir string
+
+	// Function returning the slash-separated path of an asset in the
+	// directory of a release, e.g. "share/icon.png", or an empty string to
+	// skip the asset. Defaults to the name of the asset.
+	//
+	// Archives that are extracted are extracted in the directory at the
+	// path, "." for the directory of the release, which is their default.
+	Path func(a Asset) string
+
+	// Whether assets that are archives are extracted, based on the extension
+	// of their name: .tar.gz, .tgz or .zip. Only directories and regular
+	// files are extracted, unless the Extractor allows symbolic links.
+	Extract bool
+
+	// Extractor used to extract archives, to limit their size and number of
+	// files, or to extract symbolic links. Its Mode is replaced by the mode
+	// determined by Executables. Defaults to a SecureExtractor without
+	// limits.
+	Extractor *SecureExtractor
+
+	// Patterns of the paths of the executable files, matched with
+	// path.Match, e.g. "bin/*". Executables are created with mode 0755,
+	// other files with mode 0644. Files extracted from archives are also
+	// executable if they are in the archive.
+	Executables []string
+
+	// Number of previous releases that are kept for Rollback. Defaults to 1.
+	Keep int
+}
+
+// Current returns the path of the link to the current release.
+func (i *DirInstaller) Current() string {
+	return filepath.Join(i.Dir, dirInstallerCurrent)
+}
+
+// Rollback switches back to the most recently installed release that was
+// kept before the current one.
+func (i *DirInstaller) Rollback() error {
+	current := i.current()
+	releases, err := i.releases()
+	if err != nil {
+		return err
+	}
+
+	for _, r := range releases {
+		if r != current {
+			return switchLink(i.Current(), filepath.Join(dirInstallerReleases, r))
+		}
+	}
+	return errors.New("No previous release to roll back to.")
+}
+
+// current returns the name of the directory of the current release, or an
+// empty string if there is none.
+func (i *DirInstaller) current() string {
+	target, err := os.Readlink(i.Current())
+	if err != nil {
+		return ""
+	}
+	return filepath.Base(target)
+}
+
+// releases returns the names of the directories of the installed releases,
+// the most recently installed one first.
+func (i *DirInstaller) releases() ([]string, error) {
+	entries, err := ioutil.ReadDir(filepath.Join(i.Dir, dirInstallerReleases))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var dirs []os.FileInfo
+	for _, e := range entries {
+		if e.IsDir() && !strings.HasPrefix(e.Name(), ".") {
+			dirs = append(dirs, e)
+		}
+	}
+	sort.SliceStable(dirs, func(a, b int) bool {
+		return dirs[a].ModTime().After(dirs[b].ModTime())
+	})
+
+	names := make([]string, len(dirs))
+	for j, d := range dirs {
+		names[j] = d.Name()
+	}
+	return names, nil
+}
+
+// clean removes the releases that are no longer kept.
+func (i *DirInstaller) clean() {
+	keep := i.Keep
+	if keep < 1 {
+		keep = 1
+	}
+
+	current := i.current()
+	releases, err := i.releases()
+	if err != nil {
+		return
+	}
+	for _, r := range releases {
+		if r == current {
+			continue
+		} else if keep > 0 {
+			keep--
+			continue
+		}
+		os.RemoveAll(filepath.Join(i.Dir, dirInstallerReleases, r))
+	}
+}
+
+// path returns the path of asset a in the directory dir of a release, or an
+// empty string if it is skipped. Archives are extracted in the directory at
+// the path.
+func (i *DirInstaller) path(dir string, a Asset, archive bool) (string, error) {
+	p := a.Name()
+	if archive {
+		p = "."
+	}
+	if i.Path != nil {
+		p = i.Path(a)
+	}
+	if p == "" {
+		return "", nil
+	} else if archive && path.Clean(p) == "." {
+		return dir, nil
+	}
+
+	dst, err := bundlePath(dir, p)
+	if err != nil || dst == dir {
+		return "", fmt.Errorf("Invalid path %v for asset %v.", p, a.Name())
+	}
+	return dst, nil
+}
+
+// mode returns the mode of the file at the slash-separated path p, which is
+// executable if executable is set.
+func (i *DirInstaller) mode(p string, executable bool) os.FileMode {
+	for _, pattern := range i.Executables {
+		if ok, _ := path.Match(pattern, p); ok {
+			executable = true
+		}
+	}
+	if executable {
+		return 0755
+	}
+	return 0644
+}
+
+// isArchive reports whether the asset with the given name is an archive that
+// is extracted.
+func (i *DirInstaller) isArchive(name string) bool {
+	if !i.Extract {
+		return false
+	}
+	name = strings.ToLower(name)
+	return strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz") || strings.HasSuffix(name, ".zip")
+}
+
+// releaseDir returns the name of the directory of release, its sanitized
+// name.
+func releaseDir(release Release) string {
+	name := []byte(release.Name())
+	for j, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '.' || c == '-' || c == '_') {
+			name[j] = '_'
+		}
+	}
+
+	s := strings.TrimLeft(string(name), ".")
+	if s == "" {
+		s = "_"
+	}
+	return s
+}
+
+// dirFile is a file of a release written by a DirInstaller.
+type dirFile struct {
+	file    *os.File
+	aborted bool
+
+	// Directory in which the file is extracted if it is an archive.
+	extract string
+	archive string
+}
+
+// Write data to the file.
+func (f *dirFile) Write(b []byte) (int, error) {
+	if f.aborted {
+		return 0, errors.New("Write operations aborted.")
+	}
+	return f.file.Write(b)
+}
+
+// Abort writing, the file is removed with the directory of the release.
+func (f *dirFile) Abort() {
+	if !f.aborted {
+		f.aborted = true
+		f.file.Close()
+	}
+}
+
+// TempPath returns the path of the file, or the directory in which it was
+// extracted, for the validation of the updater.
+func (f *dirFile) TempPath() string {
+	if f.extract != "" {
+		return f.extract
+	}
+	return f.file.Name()
+}
+
+// installDir installs release with inst, see DirInstaller.
+func (u *Updater) installDir(ctx context.Context, release Release, inst *DirInstaller) error {
+	name := releaseDir(release)
+	if inst.current() == name {
+		return fmt.Errorf("Release %v is already installed.", release.Name())
+	}
+
+	releases := filepath.Join(inst.Dir, dirInstallerReleases)
+	err := os.MkdirAll(releases, 0755)
+	if err != nil {
+		return err
+	}
+	staging, err := ioutil.TempDir(releases, ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+	err = os.Chmod(staging, 0755)
+	if err != nil {
+		return err
+	}
+
+	// Write the assets to the directory of the release
+	writerFor := func(a Asset) (AbortWriter, error) {
+		archive := inst.isArchive(a.Name())
+		dst, err := inst.path(staging, a, archive)
+		if err != nil || dst == "" {
+			return nil, err
+		}
+
+		if archive {
+			f, err := ioutil.TempFile(staging, ".archive-")
+			if err != nil {
+				return nil, err
+			}
+			return &dirFile{file: f, extract: dst, archive: a.Name()}, nil
+		}
+
+		err = os.MkdirAll(filepath.Dir(dst), 0755)
+		if err != nil {
+			return nil, err
+		}
+		rel, _ := filepath.Rel(staging, dst)
+		f, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, inst.mode(filepath.ToSlash(rel), false))
+		if err != nil {
+			return nil, err
+		}
+		return &dirFile{file: f}, nil
+	}
+	writers, err := u.writeAssets(ctx, release, u.AssetFilter, writerFor)
+	if err == nil {
+		for _, w := range writers {
+			if cerr := w.(*dirFile).file.Close(); cerr != nil && err == nil {
+				err = cerr
+			}
+		}
+	}
+	if err == nil {
+		err = u.extractArchives(staging, inst, writers)
+	}
+	if err == nil {
+		err = u.validate(release, writers)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Switch to the new release
+	u.observer().OnApply(release)
+	target := filepath.Join(releases, name)
+	err = os.RemoveAll(target)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(staging, target)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	os.Chtimes(target, now, now)
+
+	u.logf("Switching %v to %v", inst.Current(), name)
+	err = switchLink(inst.Current(), filepath.Join(dirInstallerReleases, name))
+	if err != nil {
+		return err
+	}
+
+	inst.clean()
+	return nil
+}
+
+// extractArchives extracts the archives among writers in the directory
+// staging of a release, and removes them.
+func (u *Updater) extractArchives(staging string, inst *DirInstaller, writers []AbortWriter) error {
+	for _, w := range writers {
+		f := w.(*dirFile)
+		if f.extract == "" {
+			continue
+		}
+
+		u.logf("Extracting %v", f.archive)
+		var e SecureExtractor
+		if inst.Extractor != nil {
+			e = *inst.Extractor
+		}
+		e.Mode = func(p string, executable bool) os.FileMode {
+			rel, _ := filepath.Rel(staging, filepath.Join(f.extract, filepath.FromSlash(p)))
+			return inst.mode(filepath.ToSlash(rel), executable)
+		}
+		err := e.extract(f.file.Name(), f.archive, f.extract)
+		os.Remove(f.file.Name())
+		if err != nil {
+			return fmt.Errorf("Could not extract %v: %v", f.archive, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package updater
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirInstaller(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Symbolic links require privileges on Windows.")
+	}
+
+	dir, err := ioutil.TempDir("", "testing-")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	newAsset := func(name, contents string) Asset {
+		return &testAsset{
+			name: name,
+			write: func(w io.Writer) error {
+				_, err := io.WriteString(w, contents)
+				return err
+			},
+		}
+	}
+	read := func(p string) string {
+		b, err := ioutil.ReadFile(filepath.Join(dir, "current", filepath.FromSlash(p)))
+		if err != nil {
+			return ""
+		}
+		return string(b)
+	}
+	releases := func() int {
+		entries, err := ioutil.ReadDir(filepath.Join(dir, "releases"))
+		require.Nil(t, err)
+		return len(entries)
+	}
+	inst := &DirInstaller{
+		Dir: dir,
+		Path: func(a Asset) string {
+			if strings.HasSuffix(a.Name(), ".png") {
+				return "share/" + a.Name()
+			} else if strings.HasPrefix(a.Name(), "myapp") {
+				return "bin/myapp"
+			}
+			return ""
+		},
+		Executables: []string{"bin/*"},
+	}
+	u := &Updater{DirInstaller: inst}
+	v1 := &testRelease{name: "v1.0.0", identifier: "1", assets: []Asset{
+		newAsset("myapp_linux_amd64", "Version 1"),
+		newAsset("icon.png", "Icon"),
+		newAsset("README.md", "Skipped"),
+	}}
+	v2 := &testRelease{name: "v2.0.0", identifier: "2", assets: []Asset{
+		newAsset("myapp_linux_amd64", "Version 2"),
+	}}
+
+	// First release
+	{
+		err := u.UpdateTo(v1)
+		require.Nil(t, err, "Could not install: %v", err)
+		assert.Equal(t, "Version 1", read("bin/myapp"))
+		assert.Equal(t, "Icon", read("share/icon.png"))
+		assert.Equal(t, "", read("README.md"))
+
+		target, err := os.Readlink(inst.Current())
+		require.Nil(t, err)
+		assert.Equal(t, filepath.Join("releases", "v1.0.0"), target)
+
+		fi, err := os.Stat(filepath.Join(inst.Current(), "bin", "myapp"))
+		require.Nil(t, err)
+		assert.Equal(t, os.FileMode(0755), fi.Mode().Perm())
+
+		assert.NotNil(t, u.UpdateTo(v1))
+	}
+
+	// Files removed by a new release
+	{
+		err := u.UpdateTo(v2)
+		require.Nil(t, err, "Could not install: %v", err)
+		assert.Equal(t, "Version 2", read("bin/myapp"))
+		assert.Equal(t, "", read("share/icon.png"))
+	}
+
+	// Failed release
+	{
+		broken := &testRelease{name: "v3.0.0", identifier: "3", assets: []Asset{
+			&testAsset{name: "myapp_linux_amd64", write: func(io.Writer) error { return errors.New("Broken.") }},
+		}}
+		assert.NotNil(t, u.UpdateTo(broken))
+		assert.Equal(t, "Version 2", read("bin/myapp"))
+		assert.Equal(t, 2, releases())
+	}
+
+	// Roll back, and previous releases removed
+	{
+		require.Nil(t, inst.Rollback())
+		assert.Equal(t, "Version 1", read("bin/myapp"))
+
+		v4 := &testRelease{name: "v4.0.0", identifier: "4", assets: []Asset{newAsset("myapp", "Version 4")}}
+		require.Nil(t, u.UpdateTo(v4))
+		assert.Equal(t, "Version 4", read("bin/myapp"))
+		assert.Equal(t, 2, releases())
+	}
+
+	// Extracted archives
+	{
+		inst := &DirInstaller{Dir: dir, Extract: true, Keep: 3}
+		u := &Updater{DirInstaller: inst}
+		tgz := newTestTarGz(t, map[string]string{"./bin/myapp": "Version 5", "share/README.md": "Read me"})
+		zip := newTestZip(t, map[string]string{"doc/index.html": "Docs"})
+		v5 := &testRelease{name: "v5.0.0", identifier: "5", assets: []Asset{
+			newAsset("myapp_linux_amd64.tar.gz", string(tgz)),
+			newAsset("docs.zip", string(zip)),
+		}}
+		err := u.UpdateTo(v5)
+		require.Nil(t, err, "Could not install: %v", err)
+		assert.Equal(t, "Version 5", read("bin/myapp"))
+		assert.Equal(t, "Read me", read("share/README.md"))
+		assert.Equal(t, "Docs", read("doc/index.html"))
+		assert.Equal(t, 3, releases())
+
+		entries, err := ioutil.ReadDir(inst.Current() + "/")
+		require.Nil(t, err)
+		assert.Equal(t, 3, len(entries))
+	}
+
+	// Invalid paths
+	{
+		inst := &DirInstaller{Dir: dir, Path: func(Asset) string { return "../evil" }}
+		u := &Updater{DirInstaller: inst}
+		err := u.UpdateTo(&testRelease{name: "v6.0.0", identifier: "6", assets: []Asset{newAsset("evil", "Evil")}})
+		assert.NotNil(t, err)
+		assert.Equal(t, "Version 5", read("bin/myapp"))
+
+		inst.Path = nil
+		inst.Extract = true
+		tgz := newTestTarGz(t, map[string]string{"../evil": "Evil"})
+		err = u.UpdateTo(&testRelease{name: "v6.0.0", identifier: "6", assets: []Asset{newAsset("evil.tgz", string(tgz))}})
+		assert.NotNil(t, err)
+		_, err = os.Stat(filepath.Join(dir, "releases", "evil"))
+		assert.True(t, os.IsNotExist(err))
+	}
+}
+
+func TestReleaseDir(t *testing.T) {
+	assert.Equal(t, "v1.0.0", releaseDir(&testRelease{name: "v1.0.0"}))
+	assert.Equal(t, "v1_0", releaseDir(&testRelease{name: "v1/0"}))
+	assert.Equal(t, "_", releaseDir(&testRelease{name: ".."}))
+}
@@ -0,0 +1,22 @@
+//go:build !windows
+// +build !windows
+
+package updater
+
+import "os"
+
+// switchLink atomically points the symbolic link at link to target.
+func switchLink(link, target string) error {
+	tmp := link + ".tmp"
+	os.Remove(tmp)
+
+	err := os.Symlink(target, tmp)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmp, link)
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
@@ -0,0 +1,13 @@
+package updater
+
+import "os"
+
+// switchLink points the symbolic link at link to target. The link is
+// replaced, which is not atomic on Windows.
+func switchLink(link, target string) error {
+	err := os.Remove(link)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Symlink(target, link)
+}
@@ -0,0 +1,108 @@
+package updater
+
+import (
+	"io"
+	"path/filepath"
+)
+
+// checkSizes checks the declared sizes of assets before they are written to
+// writers, against the MaxAssetSize and the free space of the file systems
+// that the writers write to.
+func (u *Updater) checkSizes(assets []Asset, writers []AbortWriter) error {
+	if u.MaxAssetSize <= 0 && !u.CheckDiskSpace {
+		return nil
+	}
+
+	var dirs []string
+	required := make(map[string]int64)
+	for i, a := range assets {
+		m, ok := a.(AssetMeta)
+		if !ok || m.Size() < 0 {
+			continue
+		}
+
+		if u.MaxAssetSize > 0 && m.Size() > u.MaxAssetSize {
+			return &AssetTooLargeError{Asset: a, Size: m.Size(), Limit: u.MaxAssetSize}
+		}
+
+		if dir := writerDir(writers[i]); dir != "" && u.CheckDiskSpace {
+			if _, ok := required[dir]; !ok {
+				dirs = append(dirs, dir)
+			}
+			required[dir] += m.Size()
+		}
+	}
+
+	for _, dir := range dirs {
+		available, err := freeSpace(dir)
+		if err != nil {
+			u.logf("Could not determine free space in %v: %v", dir, err)
+			continue
+		} else if available < 0 {
+			continue
+		}
+
+		if required[dir] > available {
+			return &InsufficientSpaceError{Dir: dir, Required: required[dir], Available: available}
+		}
+	}
+	return nil
+}
+
+// writerDir returns the directory that w stores its data in, or an empty
+// string if it is not known.
+func writerDir(w AbortWriter) string {
+	switch w := w.(type) {
+	case *DelayedFile:
+		return w.tempDir()
+	case *installerWriter:
+		return w.dir
+	case *dirFile:
+		return filepath.Dir(w.file.Name())
+	case *ArchiveWriter:
+		return writerDir(w.dest)
+	case *DecompressWriter:
+		return writerDir(w.dest)
+	}
+	return ""
+}
+
+// sizeLimitWriter is a writer that fails once more than limit bytes of an
+// asset are written, or as soon as the total size of the asset is known to
+// exceed the limit.
+type sizeLimitWriter struct {
+	asset Asset
+	w     io.Writer
+	limit int64
+
+	written int64
+	total   int64
+}
+
+func newSizeLimitWriter(a Asset, w io.Writer, limit int64) *sizeLimitWriter {
+	return &sizeLimitWriter{
+		asset: a,
+		w:     w,
+		limit: limit,
+		total: -1,
+	}
+}
+
+func (s *sizeLimitWriter) Write(b []byte) (int, error) {
+	if s.total > s.limit {
+		return 0, &AssetTooLargeError{Asset: s.asset, Size: s.total, Limit: s.limit}
+	} else if s.written+int64(len(b)) > s.limit {
+		return 0, &AssetTooLargeError{Asset: s.asset, Size: s.written + int64(len(b)), Limit: s.limit}
+	}
+
+	n, err := s.w.Write(b)
+	s.written += int64(n)
+	return n, err
+}
+
+func (s *sizeLimitWriter) setTotal(total int64) {
+	s.total = total
+	if t, ok := s.w.(totalSetter); ok {
+		t.setTotal(total)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !windows
+// +build !darwin,!dragonfly,!freebsd,!linux,!windows
+
+package updater
+
+// freeSpace returns -1, the free space of file systems is not known on this
+// platform.
+func freeSpace(dir string) (int64, error) {
+	return -1, nil
+}
@@ -0,0 +1,147 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdaterMaxAssetSize(t *testing.T) {
+	// Declared size too large
+	{
+		written := false
+		a := &testSizedAsset{
+			testAsset: testAsset{name: "myapp", write: func(io.Writer) error {
+				written = true
+				return nil
+			}},
+			size: 100,
+		}
+		w := NewAbortBuffer(nil)
+		u := &Updater{
+			MaxAssetSize: 10,
+			WriterForAsset: func(Asset) (AbortWriter, error) {
+				return w, nil
+			},
+		}
+
+		err := u.UpdateTo(&testRelease{assets: []Asset{a}})
+		assert.Equal(t, &AssetTooLargeError{Asset: a, Size: 100, Limit: 10}, err)
+		assert.False(t, written)
+		assert.True(t, w.aborted)
+	}
+
+	// Written data too large
+	{
+		a := &testAsset{name: "myapp", write: func(w io.Writer) error {
+			for i := 0; i < 4; i++ {
+				if _, err := io.WriteString(w, "Hello"); err != nil {
+					return err
+				}
+			}
+			return nil
+		}}
+		w := NewAbortBuffer(nil)
+		u := &Updater{
+			MaxAssetSize: 10,
+			WriterForAsset: func(Asset) (AbortWriter, error) {
+				return w, nil
+			},
+		}
+
+		err := u.UpdateTo(&testRelease{assets: []Asset{a}})
+		var tooLarge *AssetTooLargeError
+		require.True(t, errors.As(err, &tooLarge), "Unexpected error: %v", err)
+		assert.Equal(t, int64(15), tooLarge.Size)
+		assert.Equal(t, "HelloHello", w.Buffer.String())
+		assert.True(t, w.aborted)
+	}
+
+	// Content-Length too large
+	{
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, strings.Repeat("x", 100))
+		}))
+		defer server.Close()
+
+		a := &testAsset{name: "myapp", write: func(w io.Writer) error {
+			return download(context.Background(), nil, server.URL, w)
+		}}
+		w := NewAbortBuffer(nil)
+		u := &Updater{
+			MaxAssetSize: 10,
+			WriterForAsset: func(Asset) (AbortWriter, error) {
+				return w, nil
+			},
+		}
+
+		err := u.UpdateTo(&testRelease{assets: []Asset{a}})
+		var tooLarge *AssetTooLargeError
+		require.True(t, errors.As(err, &tooLarge), "Unexpected error: %v", err)
+		assert.Equal(t, int64(100), tooLarge.Size)
+		assert.Equal(t, 0, w.Buffer.Len())
+	}
+}
+
+func TestUpdaterCheckDiskSpace(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-updater-test")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	available, err := freeSpace(dir)
+	require.Nil(t, err)
+	if available < 0 {
+		t.Skip("Free space is not known on this platform.")
+	}
+	assert.True(t, available > 0)
+
+	a := &testSizedAsset{
+		testAsset: testAsset{name: "myapp", write: func(w io.Writer) error {
+			_, err := io.WriteString(w, "Hello World!")
+			return err
+		}},
+	}
+	newUpdater := func(f *DelayedFile) *Updater {
+		return &Updater{
+			CheckDiskSpace: true,
+			WriterForAsset: func(Asset) (AbortWriter, error) {
+				return f, nil
+			},
+		}
+	}
+
+	// Not enough space
+	{
+		a.size = 1 << 62
+		f := NewDelayedFile(filepath.Join(dir, "myapp"))
+		err := newUpdater(f).UpdateTo(&testRelease{assets: []Asset{a}})
+		var insufficient *InsufficientSpaceError
+		require.True(t, errors.As(err, &insufficient), "Unexpected error: %v", err)
+		assert.Equal(t, dir, insufficient.Dir)
+		assert.Equal(t, int64(1<<62), insufficient.Required)
+		assert.True(t, f.aborted)
+	}
+
+	// Enough space
+	{
+		a.size = 12
+		f := NewDelayedFile(filepath.Join(dir, "myapp"))
+		err := newUpdater(f).UpdateTo(&testRelease{assets: []Asset{a}})
+		assert.Nil(t, err)
+		assert.Nil(t, f.Close())
+
+		b, err := ioutil.ReadFile(filepath.Join(dir, "myapp"))
+		assert.Nil(t, err)
+		assert.Equal(t, "Hello World!", string(b))
+	}
+}
@@ -0,0 +1,17 @@
+//go:build darwin || dragonfly || freebsd || linux
+// +build darwin dragonfly freebsd linux
+
+package updater
+
+import "syscall"
+
+// freeSpace returns the number of bytes available to the process on the file
+// system of dir, or -1 if it is not known.
+func freeSpace(dir string) (int64, error) {
+	var st syscall.Statfs_t
+	err := syscall.Statfs(dir, &st)
+	if err != nil {
+		return 0, err
+	}
+	return int64(st.Bavail) * int64(st.Bsize), nil
+}
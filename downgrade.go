@@ -0,0 +1,109 @@
+package updater
+
+import "context"
+
+// UpdateToVersion updates the application to the release with the given
+// version name or identifier.
+//
+// Unlike UpdateTo, the release does not have to be the latest one. If it is
+// older than the current release, ErrDowngrade is returned unless
+// AllowDowngrade is set. Versions are compared by the names of the releases;
+// releases whose name is not a version are applied as is.
+//
+// The release is found with ReleaseByName. If no release has the version,
+// ErrReleaseNotFound is returned, and if it is the current release,
+// ErrUpToDate is returned.
+func (u *Updater) UpdateToVersion(version string) error {
+	return u.UpdateToVersionContext(context.Background(), version)
+}
+
+// UpdateToVersionContext is like UpdateToVersion but aborts when ctx is
+// cancelled.
+func (u *Updater) UpdateToVersionContext(ctx context.Context, version string) error {
+	r, err := u.versionRelease(ctx, version)
+	if err != nil {
+		return u.reportError(err)
+	}
+	return u.UpdateToContext(ctx, r)
+}
+
+// ReleaseByName returns the release with the given version name or
+// identifier, e.g. to deploy an exact version with UpdateTo rather than the
+// latest one.
+//
+// The application is queried first. If it implements NamedReleaseApp, it
+// looks up the release by name; otherwise the releases of ReleasesApp, or
+// only the latest release, are searched. If no release has the name,
+// ErrReleaseNotFound is returned.
+func (u *Updater) ReleaseByName(name string) (Release, error) {
+	return u.ReleaseByNameContext(context.Background(), name)
+}
+
+// ReleaseByNameContext is like ReleaseByName but aborts when ctx is cancelled.
+func (u *Updater) ReleaseByNameContext(ctx context.Context, name string) (Release, error) {
+	ctx = withLogger(withHTTPClient(ctx, u.HTTPClient), u.Logger)
+	ctx, err := u.withInstallationID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	u.logf("Querying releases")
+	err = queryApp(ctx, u.App)
+	if err != nil {
+		u.logf("Could not query releases: %v", err)
+		return nil, err
+	}
+
+	return u.lookupRelease(ctx, name)
+}
+
+// lookupRelease returns the release with the given version name or identifier
+// from the last query, see ReleaseByName.
+func (u *Updater) lookupRelease(ctx context.Context, name string) (Release, error) {
+	if r := u.findRelease(name); r != nil {
+		return r, nil
+	}
+	if latest := u.App.LatestRelease(); latest != nil && (latest.Name() == name || latest.Identifier() == name) {
+		return latest, nil
+	}
+	if app, ok := u.App.(ContextNamedReleaseApp); ok {
+		return app.ReleaseByNameContext(ctx, name)
+	} else if app, ok := u.App.(NamedReleaseApp); ok {
+		return app.ReleaseByName(name)
+	}
+	return nil, ErrReleaseNotFound
+}
+
+// versionRelease returns the release with the given version name or
+// identifier if it may be applied, see UpdateToVersionContext.
+func (u *Updater) versionRelease(ctx context.Context, name string) (Release, error) {
+	err := u.checkManaged()
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := u.ReleaseByNameContext(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	err = u.identifyCurrent(withLogger(ctx, u.Logger))
+	if err != nil {
+		return nil, err
+	}
+	current := u.currentIdentifier()
+	if r.Identifier() == current {
+		return nil, ErrUpToDate
+	}
+
+	// Only go back to an older version when explicitly allowed
+	target, err := parseVersion(r.Name())
+	if v, currentName, ok := u.currentVersion(); ok && err == nil && target.compare(v) < 0 {
+		if !u.AllowDowngrade {
+			u.logf("Release %v is older than the current release %v", r.Name(), currentName)
+			return nil, ErrDowngrade
+		}
+		u.logf("Downgrading from %v to %v", currentName, r.Name())
+	}
+	return r, nil
+}
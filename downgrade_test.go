@@ -0,0 +1,143 @@
+package updater
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdaterUpdateToVersion(t *testing.T) {
+	release := func(name, identifier string) *testRelease {
+		return &testRelease{name: name, identifier: identifier, assets: []Asset{
+			&testAsset{name: "myapp", write: func(w io.Writer) error {
+				_, err := io.WriteString(w, name)
+				return err
+			}},
+		}}
+	}
+	v3 := release("v3.0.0", "c3")
+	v2 := release("v2.0.0", "c2")
+	v1 := release("v1.0.0", "c1")
+
+	app := &testReleasesApp{releases: []Release{v3, v2, v1}}
+	app.FLatestRelease = func() Release { return v3 }
+
+	newUpdater := func(current string, w *AbortBuffer) *Updater {
+		return &Updater{
+			App:                      app,
+			CurrentReleaseIdentifier: current,
+			WriterForAsset: func(Asset) (AbortWriter, error) {
+				return w, nil
+			},
+		}
+	}
+
+	// Update to a release that is not the latest one
+	{
+		w := NewAbortBuffer(nil)
+		err := newUpdater("c1", w).UpdateToVersion("v2.0.0")
+		require.Nil(t, err, "Unexpected error: %v", err)
+		assert.Equal(t, "v2.0.0", w.Buffer.String())
+	}
+
+	// Downgrades are refused by default
+	{
+		w := NewAbortBuffer(nil)
+		err := newUpdater("c3", w).UpdateToVersion("v1.0.0")
+		assert.Equal(t, ErrDowngrade, err)
+		assert.Equal(t, "", w.Buffer.String())
+	}
+
+	// Downgrades by identifier when allowed
+	{
+		w := NewAbortBuffer(nil)
+		u := newUpdater("v3.0.0", w)
+		u.AllowDowngrade = true
+		err := u.UpdateToVersion("c1")
+		require.Nil(t, err, "Unexpected error: %v", err)
+		assert.Equal(t, "v1.0.0", w.Buffer.String())
+	}
+
+	// Current release
+	{
+		err := newUpdater("c2", NewAbortBuffer(nil)).UpdateToVersion("v2.0.0")
+		assert.Equal(t, ErrUpToDate, err)
+	}
+
+	// Unknown release
+	{
+		err := newUpdater("c2", NewAbortBuffer(nil)).UpdateToVersion("v4.0.0")
+		assert.Equal(t, ErrReleaseNotFound, err)
+	}
+
+	// Latest release of an application without history
+	{
+		w := NewAbortBuffer(nil)
+		u := newUpdater("c1", w)
+		u.App = &testApp{FLatestRelease: func() Release { return v3 }}
+		err := u.UpdateToVersion("v3.0.0")
+		require.Nil(t, err, "Unexpected error: %v", err)
+		assert.Equal(t, "v3.0.0", w.Buffer.String())
+	}
+
+	// Release of an application that looks it up by name
+	{
+		w := NewAbortBuffer(nil)
+		u := newUpdater("c3", w)
+		u.AllowDowngrade = true
+		u.App = &testNamedReleaseApp{
+			testApp:  testApp{FLatestRelease: func() Release { return v3 }},
+			releases: map[string]Release{"v1.0.0": v1},
+		}
+		err := u.UpdateToVersion("v1.0.0")
+		require.Nil(t, err, "Unexpected error: %v", err)
+		assert.Equal(t, "v1.0.0", w.Buffer.String())
+
+		_, err = u.ReleaseByName("v2.0.0")
+		assert.Equal(t, ErrReleaseNotFound, err)
+	}
+
+	// Release looked up with the context of the update
+	{
+		type key struct{}
+		w := NewAbortBuffer(nil)
+		u := newUpdater("c3", w)
+		u.AllowDowngrade = true
+		app := &testContextNamedReleaseApp{testNamedReleaseApp: testNamedReleaseApp{
+			testApp:  testApp{FLatestRelease: func() Release { return v3 }},
+			releases: map[string]Release{"v1.0.0": v1},
+		}}
+		u.App = app
+		ctx := context.WithValue(context.Background(), key{}, "value")
+		err := u.UpdateToVersionContext(ctx, "v1.0.0")
+		require.Nil(t, err, "Unexpected error: %v", err)
+		assert.Equal(t, "v1.0.0", w.Buffer.String())
+		require.NotNil(t, app.ctx)
+		assert.Equal(t, "value", app.ctx.Value(key{}))
+	}
+}
+
+type testNamedReleaseApp struct {
+	testApp
+	releases map[string]Release
+}
+
+func (app *testNamedReleaseApp) ReleaseByName(name string) (Release, error) {
+	if r, ok := app.releases[name]; ok {
+		return r, nil
+	}
+	return nil, ErrReleaseNotFound
+}
+
+type testContextNamedReleaseApp struct {
+	testNamedReleaseApp
+	ctx context.Context
+}
+
+func (app *testContextNamedReleaseApp) ReleaseByNameContext(ctx context.Context, name string) (Release, error) {
+	app.ctx = ctx
+	return app.ReleaseByName(name)
+}
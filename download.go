@@ -0,0 +1,202 @@
+package updater
+
+import (
+	"context"
+	"io"
+	"net/http"
+)
+
+// httpClientKey is the context key of the HTTP client used for downloads.
+type httpClientKey struct{}
+
+// withHTTPClient returns a context in which downloads without a client of
+// their own use client.
+func withHTTPClient(ctx context.Context, client *http.Client) context.Context {
+	if client == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, httpClientKey{}, client)
+}
+
+// contextTransport sends requests with the transport of the HTTP client of
+// their context, or the default transport. It is used by API clients that are
+// created before the HTTP client of the Updater is known.
+type contextTransport struct{}
+
+func (contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t := httpClient(req.Context(), nil).Transport
+	if t == nil {
+		t = http.DefaultTransport
+	}
+	return t.RoundTrip(req)
+}
+
+// DownloadURL fetches url with client and writes the response body to w.
+// Assets of Applications in other packages use it in WriteContext, so that
+// they are downloaded like the assets of this package, e.g. with the
+// HTTPClient of the Updater and over multiple connections.
+//
+// If client is nil, the client of ctx or the default HTTP client is used. The
+// request is cancelled when ctx is done.
+func DownloadURL(ctx context.Context, client *http.Client, url string, w io.Writer) error {
+	return download(ctx, client, url, w)
+}
+
+// OpenURL requests url with client and returns the response body and its
+// length, or -1 if it is not known. Assets of Applications in other packages
+// use it to implement AssetReader.
+//
+// If client is nil, the client of ctx or the default HTTP client is used.
+func OpenURL(ctx context.Context, client *http.Client, url string) (io.ReadCloser, int64, error) {
+	return openURL(ctx, client, url)
+}
+
+// download fetches url with client and writes the response body to w.
+//
+// If client is nil, the client of ctx or the default HTTP client is used. The
+// request is cancelled when ctx is done.
+func download(ctx context.Context, client *http.Client, url string, w io.Writer) error {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+
+	return downloadRequest(ctx, client, req, w)
+}
+
+// downloadRequest performs req with client and writes the response body to w.
+//
+// If client is nil, the client of ctx or the default HTTP client is used. The
+// request is cancelled when ctx is done. If the server reports the length of
+// the response and w wants to know the total size, it is informed before any
+// data is written.
+//
+// GET requests are downloaded over multiple connections if ctx asks for
+// parallel downloads.
+func downloadRequest(ctx context.Context, client *http.Client, req *http.Request, w io.Writer) error {
+	setInstallationID(ctx, req)
+	if p, ok := ctx.Value(parallelDownloadKey{}).(parallelDownload); ok && req.Method == "GET" {
+		return downloadParallel(ctx, client, req, w, p)
+	}
+
+	_, err := downloadResponse(ctx, client, req, w)
+	return err
+}
+
+// openURL requests url with client and returns the response body and its
+// length, or -1 if it is not known, see openRequest.
+func openURL(ctx context.Context, client *http.Client, url string) (io.ReadCloser, int64, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return openRequest(ctx, client, req)
+}
+
+// openRequest performs req with client and returns the response body and its
+// length, or -1 if it is not known.
+//
+// If client is nil, the client of ctx or the default HTTP client is used. The
+// request is cancelled when ctx is done. A *DownloadError is returned if the
+// status code is not 200 OK.
+func openRequest(ctx context.Context, client *http.Client, req *http.Request) (io.ReadCloser, int64, error) {
+	setInstallationID(ctx, req)
+	logf(ctx, "%v %v", req.Method, redactURL(req.URL))
+	resp, err := httpClient(ctx, client).Do(req.WithContext(ctx))
+	if err != nil {
+		logf(ctx, "%v %v failed: %v", req.Method, redactURL(req.URL), err)
+		return nil, 0, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		logf(ctx, "%v %v: %v", req.Method, redactURL(req.URL), resp.Status)
+		return nil, 0, newDownloadError(req, resp)
+	}
+	return &loggedBody{ReadCloser: resp.Body, ctx: ctx, req: req}, resp.ContentLength, nil
+}
+
+// loggedBody is a response body that logs when it was read completely or
+// reading failed, like writeResponse.
+type loggedBody struct {
+	io.ReadCloser
+
+	ctx    context.Context
+	req    *http.Request
+	n      int64
+	logged bool
+}
+
+func (b *loggedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	if err != nil && !b.logged {
+		b.logged = true
+		if err == io.EOF {
+			logf(b.ctx, "Downloaded %v bytes from %v", b.n, redactURL(b.req.URL))
+		} else {
+			logf(b.ctx, "Download of %v failed after %v bytes: %v", redactURL(b.req.URL), b.n, err)
+		}
+	}
+	return n, err
+}
+
+// httpClient returns client, or if it is nil, the client of ctx or the
+// default HTTP client.
+func httpClient(ctx context.Context, client *http.Client) *http.Client {
+	if client == nil {
+		client, _ = ctx.Value(httpClientKey{}).(*http.Client)
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client
+}
+
+// downloadResponse is like downloadRequest, but also returns the response,
+// whose body has been consumed. The response is returned for unexpected status
+// codes too.
+func downloadResponse(ctx context.Context, client *http.Client, req *http.Request, w io.Writer) (*http.Response, error) {
+	setInstallationID(ctx, req)
+	logf(ctx, "%v %v", req.Method, redactURL(req.URL))
+	resp, err := httpClient(ctx, client).Do(req.WithContext(ctx))
+	if err != nil {
+		logf(ctx, "%v %v failed: %v", req.Method, redactURL(req.URL), err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return resp, writeResponse(ctx, req, resp, w)
+}
+
+// writeResponse writes the body of resp, the response to req, to w. A
+// *DownloadError is returned if the status code is not 200 OK.
+//
+// If the server reports the length of the response, no more than that is
+// written, and a shorter body fails with io.ErrUnexpectedEOF.
+func writeResponse(ctx context.Context, req *http.Request, resp *http.Response, w io.Writer) error {
+	if resp.StatusCode != http.StatusOK {
+		logf(ctx, "%v %v: %v", req.Method, redactURL(req.URL), resp.Status)
+		return newDownloadError(req, resp)
+	}
+
+	var body io.Reader = resp.Body
+	if resp.ContentLength >= 0 {
+		if t, ok := w.(totalSetter); ok {
+			t.setTotal(resp.ContentLength)
+		}
+		body = io.LimitReader(resp.Body, resp.ContentLength)
+	}
+
+	n, err := io.Copy(w, body)
+	if err == nil && resp.ContentLength >= 0 && n < resp.ContentLength {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		logf(ctx, "Download of %v failed after %v bytes: %v", redactURL(req.URL), n, err)
+	} else {
+		logf(ctx, "Downloaded %v bytes from %v", n, redactURL(req.URL))
+	}
+	return err
+}
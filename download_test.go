@@ -0,0 +1,95 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type countingTransport struct {
+	requests int
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.requests++
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestDownloadHTTPClient(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("Hello World!"))
+	}))
+	defer ts.Close()
+
+	// Default client
+	{
+		buf := bytes.NewBuffer(nil)
+		err := download(context.Background(), nil, ts.URL, buf)
+		assert.Nil(t, err, "Unexpected download error: %v", err)
+		assert.Equal(t, "Hello World!", buf.String())
+	}
+
+	// Client of the context
+	{
+		transport := &countingTransport{}
+		ctx := withHTTPClient(context.Background(), &http.Client{Transport: transport})
+		err := download(ctx, nil, ts.URL, bytes.NewBuffer(nil))
+		assert.Nil(t, err, "Unexpected download error: %v", err)
+		assert.Equal(t, 1, transport.requests)
+
+		// Explicit client
+		explicit := &countingTransport{}
+		err = download(ctx, &http.Client{Transport: explicit}, ts.URL, bytes.NewBuffer(nil))
+		assert.Nil(t, err, "Unexpected download error: %v", err)
+		assert.Equal(t, 1, transport.requests)
+		assert.Equal(t, 1, explicit.requests)
+	}
+
+	// Exported for applications in other packages
+	{
+		buf := bytes.NewBuffer(nil)
+		err := DownloadURL(context.Background(), nil, ts.URL, buf)
+		assert.Nil(t, err, "Unexpected download error: %v", err)
+		assert.Equal(t, "Hello World!", buf.String())
+
+		r, size, err := OpenURL(context.Background(), nil, ts.URL)
+		require.Nil(t, err, "Unexpected open error: %v", err)
+		defer r.Close()
+		data, err := ioutil.ReadAll(r)
+		assert.Nil(t, err)
+		assert.Equal(t, "Hello World!", string(data))
+		assert.Equal(t, int64(12), size)
+	}
+}
+
+func TestUpdaterHTTPClient(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/manifest.json" {
+			w.Write([]byte(`{"version": "v1.0.0", "assets": [{"name": "myapp", "url": "myapp"}]}`))
+		} else {
+			w.Write([]byte("Hello World!"))
+		}
+	}))
+	defer ts.Close()
+
+	transport := &countingTransport{}
+	buf := NewAbortBuffer(nil)
+	u := &Updater{
+		App:        NewHTTPManifest(ts.URL+"/manifest.json", nil),
+		HTTPClient: &http.Client{Transport: transport},
+		WriterForAsset: func(Asset) (AbortWriter, error) {
+			return buf, nil
+		},
+	}
+
+	err := u.UpdateTo(nil)
+	require.Nil(t, err, "Unexpected update error: %v", err)
+	assert.Equal(t, "Hello World!", buf.Buffer.String())
+	assert.Equal(t, 2, transport.requests)
+}
@@ -0,0 +1,37 @@
+package updater
+
+import (
+	"io/ioutil"
+	"os"
+)
+
+// Elevator installs files at locations the current user cannot write to,
+// e.g. /usr/local/bin or C:\Program Files, with elevated rights.
+//
+// Set it on a DelayedFile, or on the Updater for SelfUpdate. The file is
+// then staged in a temporary directory, and the elevator is only used when
+// the destination directory is not writable.
+type Elevator interface {
+	// Install should move the file at src to dst with elevated rights and
+	// set its permissions to mode.
+	Install(src, dst string, mode os.FileMode) error
+}
+
+// DefaultElevator returns the elevator of the current platform: a
+// SudoElevator on Unix and a UACElevator on Windows. It returns nil on other
+// platforms.
+func DefaultElevator() Elevator {
+	return defaultElevator()
+}
+
+// dirWritable reports whether the current user can create files in dir.
+func dirWritable(dir string) bool {
+	f, err := ioutil.TempFile(dir, ".write-test-")
+	if err != nil {
+		return false
+	}
+
+	f.Close()
+	os.Remove(f.Name())
+	return true
+}
@@ -0,0 +1,9 @@
+//go:build plan9
+// +build plan9
+
+package updater
+
+// defaultElevator returns nil, elevation is not supported on this platform.
+func defaultElevator() Elevator {
+	return nil
+}
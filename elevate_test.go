@@ -0,0 +1,67 @@
+package updater
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testElevator struct {
+	src, dst string
+	mode     os.FileMode
+	data     string
+}
+
+func (e *testElevator) Install(src, dst string, mode os.FileMode) error {
+	data, err := ioutil.ReadFile(src)
+	e.src, e.dst, e.mode, e.data = src, dst, mode, string(data)
+	return err
+}
+
+func TestDelayedFileElevator(t *testing.T) {
+	dir, err := ioutil.TempDir("", "elevate-")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// Writable destination is not elevated
+	{
+		e := &testElevator{}
+		path := filepath.Join(dir, "myapp")
+		f := NewExecutableFile(path)
+		f.Elevator = e
+		f.Write([]byte("Hello World!"))
+		require.Nil(t, f.Close())
+
+		data, err := ioutil.ReadFile(path)
+		assert.Nil(t, err)
+		assert.Equal(t, "Hello World!", string(data))
+		assert.Equal(t, "", e.dst)
+	}
+
+	// Unwritable destination is elevated
+	{
+		e := &testElevator{}
+		path := filepath.Join(dir, "missing", "myapp")
+		f := NewExecutableFile(path)
+		f.Elevator = e
+		f.Write([]byte("Hello World!"))
+		require.Nil(t, f.Close())
+
+		assert.Equal(t, path, e.dst)
+		assert.Equal(t, os.FileMode(0755), e.mode)
+		assert.Equal(t, "Hello World!", e.data)
+		_, err := os.Stat(e.src)
+		assert.True(t, os.IsNotExist(err), "Temporary file was not removed")
+	}
+
+	// No elevator
+	{
+		f := NewDelayedFile(filepath.Join(dir, "missing", "myapp"))
+		f.Write([]byte("Hello World!"))
+		assert.NotNil(t, f.Close())
+	}
+}
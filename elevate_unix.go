@@ -0,0 +1,47 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package updater
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// SudoElevator is an Elevator that installs files with sudo.
+//
+// The file is copied with install(1), so the installed file is owned by the
+// user sudo runs as, usually root. The user may be asked for a password on
+// the terminal.
+type SudoElevator struct {
+	// Command that runs its arguments with elevated rights. Defaults to
+	// sudo, e.g. use []string{"pkexec"} on desktops without a terminal.
+	Command []string
+}
+
+func defaultElevator() Elevator {
+	return &SudoElevator{}
+}
+
+// Install copies the file at src to dst with install(1) and elevated rights.
+func (e *SudoElevator) Install(src, dst string, mode os.FileMode) error {
+	command := e.Command
+	if len(command) == 0 {
+		command = []string{"sudo", "--"}
+	}
+
+	args := append(command[1:len(command):len(command)],
+		"install", "-m", fmt.Sprintf("%o", mode.Perm()), src, dst,
+	)
+	cmd := exec.Command(command[0], args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	err := cmd.Run()
+	if err != nil {
+		return fmt.Errorf("Could not install %v with %v: %v", dst, command[0], err)
+	}
+	return nil
+}
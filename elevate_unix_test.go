@@ -0,0 +1,44 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package updater
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSudoElevator(t *testing.T) {
+	dir, err := ioutil.TempDir("", "elevate-")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "src")
+	dst := filepath.Join(dir, "dst")
+	require.Nil(t, ioutil.WriteFile(src, []byte("Hello World!"), 0600))
+
+	// Install with a command that does not elevate
+	{
+		e := &SudoElevator{Command: []string{"env"}}
+		err := e.Install(src, dst, 0751)
+		require.Nil(t, err, "Could not install: %v", err)
+
+		data, err := ioutil.ReadFile(dst)
+		assert.Nil(t, err)
+		assert.Equal(t, "Hello World!", string(data))
+		info, err := os.Stat(dst)
+		require.Nil(t, err)
+		assert.Equal(t, os.FileMode(0751), info.Mode().Perm())
+	}
+
+	// Failing command
+	{
+		e := &SudoElevator{Command: []string{"false"}}
+		assert.NotNil(t, e.Install(src, dst, 0755))
+	}
+}
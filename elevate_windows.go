@@ -0,0 +1,120 @@
+package updater
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+var procShellExecuteExW = syscall.NewLazyDLL("shell32.dll").NewProc("ShellExecuteExW")
+
+// Characters that cmd.exe interprets in quoted arguments, like %VAR%
+// expansions, or that end the quoting and separate commands.
+const cmdUnsafeChars = "%^&|<>\"!\r\n"
+
+const (
+	seeMaskNoCloseProcess = 0x00000040
+	seeMaskNoAsync        = 0x00000100
+	swHide                = 0
+)
+
+// shellExecuteInfo is the SHELLEXECUTEINFOW structure.
+type shellExecuteInfo struct {
+	cbSize         uint32
+	fMask          uint32
+	hwnd           uintptr
+	lpVerb         *uint16
+	lpFile         *uint16
+	lpParameters   *uint16
+	lpDirectory    *uint16
+	nShow          int32
+	hInstApp       uintptr
+	lpIDList       uintptr
+	lpClass        *uint16
+	hkeyClass      uintptr
+	dwHotKey       uint32
+	hIconOrMonitor uintptr
+	hProcess       syscall.Handle
+}
+
+// UACElevator is an Elevator that moves files with administrator rights,
+// which the user is asked to grant with a User Account Control prompt.
+//
+// Like NewExecutableFile, the destination file is moved aside to a file with
+// the .old extension if it cannot be overwritten because it is running.
+type UACElevator struct{}
+
+func defaultElevator() Elevator {
+	return &UACElevator{}
+}
+
+// Install moves the file at src to dst with administrator rights. The mode is
+// ignored.
+//
+// The file is moved by cmd.exe, so paths with characters that it interprets,
+// like % and &, are rejected.
+func (e *UACElevator) Install(src, dst string, mode os.FileMode) error {
+	for _, p := range []string{src, dst} {
+		if err := checkCmdPath(p); err != nil {
+			return err
+		}
+	}
+
+	params := fmt.Sprintf(
+		`/D /C move /Y "%[1]v" "%[2]v" >NUL 2>&1 || (move /Y "%[2]v" "%[2]v.old" >NUL 2>&1 & move /Y "%[1]v" "%[2]v")`,
+		src, dst,
+	)
+
+	verb, err := syscall.UTF16PtrFromString("runas")
+	if err != nil {
+		return err
+	}
+	file, err := syscall.UTF16PtrFromString("cmd.exe")
+	if err != nil {
+		return err
+	}
+	args, err := syscall.UTF16PtrFromString(params)
+	if err != nil {
+		return err
+	}
+
+	info := &shellExecuteInfo{
+		fMask:        seeMaskNoCloseProcess | seeMaskNoAsync,
+		lpVerb:       verb,
+		lpFile:       file,
+		lpParameters: args,
+		nShow:        swHide,
+	}
+	info.cbSize = uint32(unsafe.Sizeof(*info))
+
+	ok, _, err := procShellExecuteExW.Call(uintptr(unsafe.Pointer(info)))
+	if ok == 0 {
+		return fmt.Errorf("Could not install %v with administrator rights: %v", dst, err)
+	}
+	defer syscall.CloseHandle(info.hProcess)
+
+	_, err = syscall.WaitForSingleObject(info.hProcess, syscall.INFINITE)
+	if err != nil {
+		return err
+	}
+
+	var code uint32
+	err = syscall.GetExitCodeProcess(info.hProcess, &code)
+	if err != nil {
+		return err
+	} else if code != 0 {
+		return fmt.Errorf("Could not install %v with administrator rights: exit status %v", dst, code)
+	}
+	return nil
+}
+
+// checkCmdPath checks that path can be passed to cmd.exe as a quoted argument
+// without being interpreted.
+func checkCmdPath(path string) error {
+	if strings.ContainsAny(path, cmdUnsafeChars) {
+		return fmt.Errorf("Cannot install %v with administrator rights: the path contains one of %q.", path, cmdUnsafeChars)
+	}
+	return nil
+}
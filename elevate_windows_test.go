@@ -0,0 +1,21 @@
+package updater
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckCmdPath(t *testing.T) {
+	assert.Nil(t, checkCmdPath(`C:\Program Files (x86)\My App\myapp.exe`))
+
+	for _, p := range []string{
+		`C:\Users\%USERNAME%\myapp.exe`,
+		`C:\My App & Co\myapp.exe`,
+		`C:\My^App\myapp.exe`,
+		`C:\My"App\myapp.exe`,
+		`C:\My App\myapp.exe" & calc & "`,
+	} {
+		assert.Error(t, checkCmdPath(p), p)
+	}
+}
@@ -0,0 +1,251 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"runtime"
+)
+
+// URL of the check endpoint of equinox.io.
+const equinoxCheckURL = "https://update.equinox.io/check"
+
+// EquinoxOptions configures an Application that is updated with the check
+// protocol of equinox.io.
+type EquinoxOptions struct {
+	// URL of the check endpoint. Defaults to the one of equinox.io.
+	URL string
+
+	// Identifier of the application.
+	AppID string
+
+	// Release channel. Defaults to "stable".
+	Channel string
+
+	// Version of the running application. Set the CurrentReleaseIdentifier of
+	// the Updater to the same version.
+	CurrentVersion string
+
+	// Public key used to verify the signatures of the checksums of new
+	// releases. When nil, signatures are not verified.
+	PublicKey *ecdsa.PublicKey
+}
+
+type equinoxApp struct {
+	opts   EquinoxOptions
+	latest Release
+}
+
+type equinoxRelease struct {
+	Response equinoxResponseJSON
+
+	assets []Asset
+}
+
+type equinoxAsset struct {
+	url       string
+	checksum  string
+	signature string
+	publicKey *ecdsa.PublicKey
+}
+
+type equinoxRequestJSON struct {
+	AppID          string `json:"app_id"`
+	Channel        string `json:"channel"`
+	CurrentSHA256  string `json:"current_sha256"`
+	CurrentVersion string `json:"current_version"`
+	GoARM          string `json:"goarm"`
+	OS             string `json:"os"`
+	Arch           string `json:"arch"`
+	TargetVersion  string `json:"target_version"`
+}
+
+type equinoxResponseJSON struct {
+	Available   bool   `json:"available"`
+	DownloadURL string `json:"download_url"`
+	Checksum    string `json:"checksum"`
+	Signature   string `json:"signature"`
+	Release     struct {
+		Title       string `json:"title"`
+		Version     string `json:"version"`
+		Description string `json:"description"`
+	} `json:"release"`
+}
+
+// NewEquinox creates an Application that is updated with the check protocol
+// of equinox.io, by equinox.io itself or a compatible server.
+//
+// The application asks the server whether an update is available for the
+// current version and platform. If there is one, the latest release has a
+// single asset, named after the last element of its download URL. Its
+// checksum, and its signature if a public key is set, are verified while it
+// is written. If there is no update, the latest release is the current
+// version, without assets.
+func NewEquinox(opts EquinoxOptions) App {
+	if opts.URL == "" {
+		opts.URL = equinoxCheckURL
+	}
+	if opts.Channel == "" {
+		opts.Channel = "stable"
+	}
+
+	return &equinoxApp{
+		opts: opts,
+	}
+}
+
+func (app *equinoxApp) Query() error {
+	return app.QueryContext(context.Background())
+}
+
+func (app *equinoxApp) QueryContext(ctx context.Context) error {
+	body, err := json.Marshal(&equinoxRequestJSON{
+		AppID:          app.opts.AppID,
+		Channel:        app.opts.Channel,
+		CurrentVersion: app.opts.CurrentVersion,
+		OS:             runtime.GOOS,
+		Arch:           runtime.GOARCH,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", app.opts.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json; q=1; version=1; charset=utf-8")
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	buf := bytes.NewBuffer(nil)
+	err = downloadRequest(ctx, nil, req, buf)
+	if err != nil {
+		return err
+	}
+
+	var resp equinoxResponseJSON
+	err = json.Unmarshal(buf.Bytes(), &resp)
+	if err != nil {
+		return fmt.Errorf("Invalid check response: %v", err)
+	}
+
+	if !resp.Available {
+		resp.Release.Version = app.opts.CurrentVersion
+		app.latest = &equinoxRelease{Response: resp}
+		return nil
+	}
+
+	r, err := newEquinoxRelease(resp, app.opts.URL, app.opts.PublicKey)
+	if err != nil {
+		return err
+	}
+	app.latest = r
+
+	return nil
+}
+
+func (app *equinoxApp) LatestRelease() Release {
+	return app.latest
+}
+
+func newEquinoxRelease(resp equinoxResponseJSON, base string, key *ecdsa.PublicKey) (*equinoxRelease, error) {
+	if resp.DownloadURL == "" || resp.Checksum == "" {
+		return nil, errors.New("No download URL or checksum available.")
+	}
+
+	b, err := url.Parse(base)
+	if err != nil {
+		return nil, err
+	}
+	u, err := b.Parse(resp.DownloadURL)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid download URL %v: %v", resp.DownloadURL, err)
+	}
+
+	return &equinoxRelease{
+		Response: resp,
+		assets: []Asset{&equinoxAsset{
+			url:       u.String(),
+			checksum:  resp.Checksum,
+			signature: resp.Signature,
+			publicKey: key,
+		}},
+	}, nil
+}
+
+func (r *equinoxRelease) Name() string {
+	return r.Response.Release.Version
+}
+
+func (r *equinoxRelease) Information() string {
+	return r.Response.Release.Description
+}
+
+func (r *equinoxRelease) Identifier() string {
+	return r.Response.Release.Version
+}
+
+func (r *equinoxRelease) Assets() []Asset {
+	return r.assets
+}
+
+func (r *equinoxAsset) Name() string {
+	u, err := url.Parse(r.url)
+	if err != nil {
+		return ""
+	}
+	return path.Base(u.Path)
+}
+
+func (r *equinoxAsset) Write(w io.Writer) error {
+	return r.WriteContext(context.Background(), w)
+}
+
+func (r *equinoxAsset) WriteContext(ctx context.Context, w io.Writer) error {
+	expected, err := hex.DecodeString(r.checksum)
+	if err != nil {
+		return fmt.Errorf("Invalid checksum for %v: %v", r.Name(), err)
+	}
+
+	if r.publicKey != nil {
+		err = r.verifySignature(expected)
+		if err != nil {
+			return err
+		}
+	}
+
+	h := sha256.New()
+	err = download(ctx, nil, r.url, teeWriter(w, h))
+	if err != nil {
+		return err
+	}
+
+	return verifyChecksum(map[string][]byte{r.Name(): expected}, r, h.Sum(nil))
+}
+
+// verifySignature verifies the ECDSA signature of the checksum, which is
+// encoded in hexadecimal or base64.
+func (r *equinoxAsset) verifySignature(checksum []byte) error {
+	sig, err := hex.DecodeString(r.signature)
+	if err != nil {
+		sig, err = base64.StdEncoding.DecodeString(r.signature)
+	}
+	if err != nil || len(sig) == 0 {
+		return fmt.Errorf("Invalid signature for %v.", r.Name())
+	}
+
+	if !ecdsa.VerifyASN1(r.publicKey, checksum, sig) {
+		return fmt.Errorf("Invalid signature for %v.", r.Name())
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package updater
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEquinox(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+
+	sum := sha256.Sum256([]byte("Hello World!"))
+	sig, err := ecdsa.SignASN1(rand.Reader, key, sum[:])
+	require.Nil(t, err)
+
+	var response map[string]interface{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/check":
+			assert.Equal(t, "POST", r.Method)
+
+			var req equinoxRequestJSON
+			err := json.NewDecoder(r.Body).Decode(&req)
+			assert.Nil(t, err, "Invalid request: %v", err)
+			assert.Equal(t, "app_123", req.AppID)
+			assert.Equal(t, "stable", req.Channel)
+			assert.Equal(t, "1.0.0", req.CurrentVersion)
+			assert.Equal(t, runtime.GOOS, req.OS)
+			assert.Equal(t, runtime.GOARCH, req.Arch)
+
+			json.NewEncoder(w).Encode(response)
+		case "/bin/myapp":
+			w.Write([]byte("Hello World!"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	newApp := func(key *ecdsa.PublicKey) App {
+		return NewEquinox(EquinoxOptions{
+			URL:            ts.URL + "/check",
+			AppID:          "app_123",
+			CurrentVersion: "1.0.0",
+			PublicKey:      key,
+		})
+	}
+	newResponse := func(checksum []byte, signature string) map[string]interface{} {
+		return map[string]interface{}{
+			"available":    true,
+			"download_url": "/bin/myapp",
+			"checksum":     hex.EncodeToString(checksum),
+			"signature":    signature,
+			"release": map[string]interface{}{
+				"version":     "1.1.0",
+				"description": "Bug fixes.",
+			},
+		}
+	}
+
+	// Update available
+	{
+		response = newResponse(sum[:], hex.EncodeToString(sig))
+		app := newApp(&key.PublicKey)
+		err := app.Query()
+		require.Nil(t, err, "Unexpected query error: %v", err)
+
+		r := app.LatestRelease()
+		require.NotNil(t, r)
+		assert.Equal(t, "1.1.0", r.Name())
+		assert.Equal(t, "1.1.0", r.Identifier())
+		assert.Equal(t, "Bug fixes.", r.Information())
+		require.Equal(t, 1, len(r.Assets()))
+		assert.Equal(t, "myapp", r.Assets()[0].Name())
+
+		buf := bytes.NewBuffer(nil)
+		err = r.Assets()[0].Write(buf)
+		assert.Nil(t, err, "Unexpected write error: %v", err)
+		assert.Equal(t, "Hello World!", buf.String())
+	}
+
+	// Base64 signature
+	{
+		response = newResponse(sum[:], base64.StdEncoding.EncodeToString(sig))
+		app := newApp(&key.PublicKey)
+		require.Nil(t, app.Query())
+		err := app.LatestRelease().Assets()[0].Write(bytes.NewBuffer(nil))
+		assert.Nil(t, err, "Unexpected write error: %v", err)
+	}
+
+	// Invalid signature
+	{
+		other := sha256.Sum256([]byte("Other"))
+		response = newResponse(other[:], hex.EncodeToString(sig))
+		app := newApp(&key.PublicKey)
+		require.Nil(t, app.Query())
+		err := app.LatestRelease().Assets()[0].Write(bytes.NewBuffer(nil))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "Invalid signature")
+	}
+
+	// Checksum mismatch
+	{
+		other := sha256.Sum256([]byte("Other"))
+		response = newResponse(other[:], "")
+		app := newApp(nil)
+		require.Nil(t, app.Query())
+		err := app.LatestRelease().Assets()[0].Write(bytes.NewBuffer(nil))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "Checksum mismatch")
+	}
+
+	// No update available
+	{
+		response = map[string]interface{}{"available": false}
+		app := newApp(nil)
+		err := app.Query()
+		require.Nil(t, err, "Unexpected query error: %v", err)
+
+		u := &Updater{App: app, CurrentReleaseIdentifier: "1.0.0"}
+		r, err := u.Check()
+		assert.Nil(t, err)
+		assert.Nil(t, r)
+	}
+
+	// Missing download URL
+	{
+		response = map[string]interface{}{"available": true}
+		err := newApp(nil).Query()
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,190 @@
+package updater
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	// ErrUpToDate is returned when updating an application that is already
+	// up to date.
+	ErrUpToDate = errors.New("The application is already up to date.")
+
+	// ErrNoRelease is returned when the application has no releases.
+	ErrNoRelease = errors.New("No release information was found.")
+
+	// ErrReleaseNotFound is returned when a release with a given identifier
+	// does not exist.
+	ErrReleaseNotFound = errors.New("The release was not found.")
+
+	// ErrDowngrade is returned when updating to a release that is older than
+	// the current release without allowing downgrades.
+	ErrDowngrade = errors.New("The release is older than the current release.")
+
+	// ErrUpdateInProgress is returned when another process is applying an
+	// update to the same destination, see LockFile.
+	ErrUpdateInProgress = errors.New("Another update is in progress.")
+)
+
+// AssetDownloadError is returned when an asset could not be downloaded or
+// written.
+type AssetDownloadError struct {
+	// Asset that failed.
+	Asset Asset
+
+	// Error that caused the failure.
+	Cause error
+}
+
+func (e *AssetDownloadError) Error() string {
+	return fmt.Sprintf("Could not write asset %v: %v", e.Asset.Name(), e.Cause)
+}
+
+// Unwrap returns the cause of the error.
+func (e *AssetDownloadError) Unwrap() error {
+	return e.Cause
+}
+
+// RateLimitError is returned when a query is refused because the rate limit of
+// an API was exceeded.
+//
+// The AutoUpdater does not check again before the limit resets.
+type RateLimitError struct {
+	// Time at which the rate limit resets, or the zero time if it is not
+	// known.
+	Reset time.Time
+
+	// Error reported by the API.
+	Cause error
+}
+
+func (e *RateLimitError) Error() string {
+	if e.Reset.IsZero() {
+		return "The API rate limit was exceeded."
+	}
+	return fmt.Sprintf("The API rate limit was exceeded until %v.", e.Reset.Format(time.RFC3339))
+}
+
+// Unwrap returns the cause of the error.
+func (e *RateLimitError) Unwrap() error {
+	return e.Cause
+}
+
+// Maximum number of bytes of a response body kept in a DownloadError.
+const downloadErrorBodySize = 512
+
+// DownloadError is returned when a server responds to a download with an
+// unexpected status code.
+//
+// Use the status code to tell a missing asset (404 Not Found) from a rate
+// limit (403 Forbidden or 429 Too Many Requests) or a server error (5xx).
+type DownloadError struct {
+	// URL that was downloaded, without credentials or query.
+	URL string
+
+	// Status code of the response, e.g. 404.
+	StatusCode int
+
+	// Status of the response, e.g. "404 Not Found".
+	Status string
+
+	// Headers of the response, e.g. the rate limit headers.
+	Header http.Header
+
+	// Beginning of the response body, which often explains the error.
+	Body string
+}
+
+func (e *DownloadError) Error() string {
+	return "Could not download " + e.URL + ": " + e.Status
+}
+
+// RateLimited reports whether the server refused the download because of a
+// rate limit.
+func (e *DownloadError) RateLimited() bool {
+	return e.StatusCode == http.StatusTooManyRequests ||
+		(e.StatusCode == http.StatusForbidden && e.Header.Get("X-RateLimit-Remaining") == "0")
+}
+
+// newDownloadError returns the error of resp, the unexpected response to req.
+// The beginning of the response body is read.
+func newDownloadError(req *http.Request, resp *http.Response) *DownloadError {
+	b := make([]byte, downloadErrorBodySize)
+	n, _ := io.ReadFull(resp.Body, b)
+
+	return &DownloadError{
+		URL:        redactURL(req.URL),
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Header:     resp.Header,
+		Body:       excerpt(b[:n]),
+	}
+}
+
+// excerpt returns b as a string without trailing whitespace and incomplete
+// UTF-8 sequences.
+func excerpt(b []byte) string {
+	for len(b) > 0 && !utf8.Valid(b) {
+		b = b[:len(b)-1]
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// AssetTooLargeError is returned when an asset is larger than the
+// MaxAssetSize of the updater.
+type AssetTooLargeError struct {
+	// Asset that is too large.
+	Asset Asset
+
+	// Size of the asset in bytes, as declared by the source of the asset, or
+	// the number of bytes received when the limit was exceeded.
+	Size int64
+
+	// Maximum size of an asset in bytes.
+	Limit int64
+}
+
+func (e *AssetTooLargeError) Error() string {
+	return fmt.Sprintf("Asset %v of %v bytes exceeds the limit of %v bytes.", e.Asset.Name(), e.Size, e.Limit)
+}
+
+// InsufficientSpaceError is returned when the file system of a destination
+// does not have enough free space for the assets of an update, see
+// CheckDiskSpace.
+type InsufficientSpaceError struct {
+	// Directory the assets are written to.
+	Dir string
+
+	// Number of bytes the assets require.
+	Required int64
+
+	// Number of bytes available to the process.
+	Available int64
+}
+
+func (e *InsufficientSpaceError) Error() string {
+	return fmt.Sprintf("Not enough space in %v: %v bytes required, %v bytes available.", e.Dir, e.Required, e.Available)
+}
+
+// UnsupportedVersionError is returned when the running version of the
+// application is too old to update to the latest release, see
+// SignedManifest.
+type UnsupportedVersionError struct {
+	// Version name of the release.
+	Release string
+
+	// Version of the running application.
+	CurrentVersion string
+
+	// Oldest version that can update to the release.
+	MinimumVersion string
+}
+
+func (e *UnsupportedVersionError) Error() string {
+	return fmt.Sprintf("Version %v cannot update to %v, which requires at least version %v.", e.CurrentVersion, e.Release, e.MinimumVersion)
+}
@@ -0,0 +1,88 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssetDownloadError(t *testing.T) {
+	a := &testAsset{
+		name: "myapp",
+		write: func(w io.Writer) error {
+			return io.ErrUnexpectedEOF
+		},
+	}
+	u := &Updater{
+		WriterForAsset: func(Asset) (AbortWriter, error) {
+			return NewAbortBuffer(nil), nil
+		},
+	}
+
+	err := u.UpdateTo(&testRelease{assets: []Asset{a}})
+	require.Error(t, err)
+	assert.Equal(t, "Could not write asset myapp: unexpected EOF", err.Error())
+	assert.True(t, errors.Is(err, io.ErrUnexpectedEOF))
+
+	var downloadErr *AssetDownloadError
+	require.True(t, errors.As(err, &downloadErr))
+	assert.Equal(t, a, downloadErr.Asset)
+}
+
+func TestDownloadError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rate-limited":
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte("API rate limit exceeded\n"))
+		case "/large":
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write(bytes.Repeat([]byte("é"), downloadErrorBodySize))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	// Missing asset
+	{
+		err := download(context.Background(), nil, ts.URL+"/missing?token=secret", ioutil.Discard)
+		var downloadErr *DownloadError
+		require.True(t, errors.As(err, &downloadErr))
+		assert.Equal(t, ts.URL+"/missing?...", downloadErr.URL)
+		assert.Equal(t, http.StatusNotFound, downloadErr.StatusCode)
+		assert.Equal(t, "404 Not Found", downloadErr.Status)
+		assert.False(t, downloadErr.RateLimited())
+		assert.Equal(t, "Could not download "+ts.URL+"/missing?...: 404 Not Found", err.Error())
+	}
+
+	// Rate limited
+	{
+		err := download(context.Background(), nil, ts.URL+"/rate-limited", ioutil.Discard)
+		var downloadErr *DownloadError
+		require.True(t, errors.As(err, &downloadErr))
+		assert.Equal(t, http.StatusForbidden, downloadErr.StatusCode)
+		assert.Equal(t, "0", downloadErr.Header.Get("X-RateLimit-Remaining"))
+		assert.Equal(t, "API rate limit exceeded", downloadErr.Body)
+		assert.True(t, downloadErr.RateLimited())
+	}
+
+	// Long body
+	{
+		err := download(context.Background(), nil, ts.URL+"/large", ioutil.Discard)
+		var downloadErr *DownloadError
+		require.True(t, errors.As(err, &downloadErr))
+		assert.Equal(t, strings.Repeat("é", downloadErrorBodySize/2), downloadErr.Body)
+		assert.True(t, isRetryable(err))
+	}
+}
@@ -0,0 +1,400 @@
+package updater
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// SymlinkPolicy determines how a SecureExtractor handles symbolic links in
+// archives.
+type SymlinkPolicy int
+
+const (
+	// SkipSymlinks skips symbolic links. This is the default.
+	SkipSymlinks SymlinkPolicy = iota
+
+	// RejectSymlinks fails the extraction of archives that contain
+	// symbolic links.
+	RejectSymlinks
+
+	// ContainedSymlinks creates symbolic links that resolve to a file or
+	// directory within the directory the archive is extracted in, and fails
+	// the extraction for other links. The links are created after all other
+	// files, so no file is written through a link, and links within other
+	// links fail the extraction.
+	ContainedSymlinks
+)
+
+// SecureExtractor extracts zip and gzip compressed tar archives in a
+// directory, protecting against malicious archives.
+//
+// Files whose path is absolute or leaves the directory, like
+// "../../etc/passwd", fail the extraction, which is known as zip-slip
+// protection. The total size and number of files can be limited to protect
+// against archives that decompress to much more data than their size, known
+// as zip bombs. Only directories, regular files and, depending on the
+// Symlinks policy, symbolic links are extracted.
+//
+// The zero value extracts archives without limits and skips symbolic links.
+// It is used by DirInstaller and, for its limits, by ArchiveWriter.
+type SecureExtractor struct {
+	// Maximum number of bytes of all files together after decompression. By
+	// default, the size is not limited.
+	MaxSize int64
+
+	// Maximum number of entries in the archive, including directories and
+	// skipped entries. By default, the number is not limited.
+	MaxFiles int
+
+	// How symbolic links are handled, see SymlinkPolicy.
+	Symlinks SymlinkPolicy
+
+	// Function returning the mode of the file at the slash-separated path p
+	// in the archive, which is executable in the archive if executable is
+	// set. Defaults to 0755 for executables and 0644 for other files.
+	Mode func(p string, executable bool) os.FileMode
+}
+
+// Extract extracts the archive at archivePath in dir, which is created if it
+// does not exist. The format is determined by the extension of archivePath:
+// .zip for zip archives, and gzip compressed tar archives otherwise.
+//
+// When the extraction fails, the files that were already extracted are not
+// removed, so extract in an empty directory that can be removed.
+func (e *SecureExtractor) Extract(archivePath, dir string) error {
+	return e.extract(archivePath, filepath.Base(archivePath), dir)
+}
+
+// extract extracts the archive at archivePath, whose format is determined by
+// name, in dir.
+func (e *SecureExtractor) extract(archivePath, name, dir string) error {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	x := &extraction{e: e, dir: dir}
+	if strings.HasSuffix(strings.ToLower(name), ".zip") {
+		err = x.zip(f)
+	} else {
+		err = x.tarGz(f)
+	}
+	if err != nil {
+		return err
+	}
+	return x.links()
+}
+
+// extraction is the state of an archive that is extracted.
+type extraction struct {
+	e   *SecureExtractor
+	dir string
+
+	files   int
+	size    int64
+	symlink []extractedLink
+}
+
+// extractedLink is a symbolic link that is created when all other files were
+// extracted.
+type extractedLink struct {
+	name   string
+	target string
+}
+
+func (x *extraction) zip(f *os.File) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	r, err := zip.NewReader(f, info.Size())
+	if err != nil {
+		return err
+	}
+
+	for _, file := range r.File {
+		if err := x.count(); err != nil {
+			return err
+		}
+
+		m := file.Mode()
+		if m&os.ModeSymlink != 0 {
+			err = x.zipLink(file)
+		} else if m.IsDir() || m.IsRegular() {
+			if file.UncompressedSize64 > uint64(x.remaining()) {
+				return x.sizeError()
+			}
+			var rc io.ReadCloser
+			rc, err = file.Open()
+			if err != nil {
+				return err
+			}
+			err = x.create(file.Name, m, rc)
+			rc.Close()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (x *extraction) zipLink(file *zip.File) error {
+	if x.e.Symlinks != ContainedSymlinks {
+		return x.link(file.Name, "")
+	}
+
+	rc, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	// The target of a link is its content
+	target, err := ioutil.ReadAll(io.LimitReader(rc, 4096))
+	if err != nil {
+		return err
+	}
+	return x.link(file.Name, string(target))
+}
+
+func (x *extraction) tarGz(f *os.File) error {
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	r := tar.NewReader(gz)
+	for {
+		hdr, err := r.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("Invalid tar archive: %v", err)
+		}
+		if err := x.count(); err != nil {
+			return err
+		}
+
+		m := hdr.FileInfo().Mode()
+		if hdr.Typeflag == tar.TypeSymlink {
+			err = x.link(hdr.Name, hdr.Linkname)
+		} else if hdr.Typeflag != tar.TypeLink && (m.IsDir() || m.IsRegular()) {
+			err = x.create(hdr.Name, m, r)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// count counts an entry of the archive against the MaxFiles limit.
+func (x *extraction) count() error {
+	x.files++
+	if x.e.MaxFiles > 0 && x.files > x.e.MaxFiles {
+		return fmt.Errorf("Archive has more than %v files.", x.e.MaxFiles)
+	}
+	return nil
+}
+
+// remaining returns the number of bytes that can still be extracted.
+func (x *extraction) remaining() int64 {
+	if x.e.MaxSize <= 0 {
+		return 1<<63 - 1
+	}
+	return x.e.MaxSize - x.size
+}
+
+func (x *extraction) sizeError() error {
+	return fmt.Errorf("Archive exceeds the limit of %v bytes.", x.e.MaxSize)
+}
+
+// path returns the destination of the slash-separated path p in the archive.
+func (x *extraction) path(p string) (string, error) {
+	dst, err := bundlePath(x.dir, p)
+	if err != nil {
+		return "", fmt.Errorf("Invalid path %v in archive.", p)
+	}
+	return dst, nil
+}
+
+// create creates the directory or regular file at path p in the archive, with
+// the contents read from r.
+func (x *extraction) create(p string, m os.FileMode, r io.Reader) error {
+	dst, err := x.path(p)
+	if err != nil {
+		return err
+	}
+	if m.IsDir() {
+		return os.MkdirAll(dst, 0755)
+	}
+
+	err = os.MkdirAll(filepath.Dir(dst), 0755)
+	if err != nil {
+		return err
+	}
+
+	mode := os.FileMode(0644)
+	if x.e.Mode != nil {
+		mode = x.e.Mode(path.Clean(p), m&0111 != 0)
+	} else if m&0111 != 0 {
+		mode = 0755
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+
+	err = x.copy(out, r)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// copy copies r to w, failing when this exceeds the MaxSize of all files.
+func (x *extraction) copy(w io.Writer, r io.Reader) error {
+	n, err := io.CopyN(w, r, x.remaining())
+	x.size += n
+	if err == io.EOF {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// Check whether there is more than the remaining size
+	var b [1]byte
+	if k, _ := r.Read(b[:]); k > 0 {
+		return x.sizeError()
+	}
+	return nil
+}
+
+// link handles the symbolic link at path p in the archive, pointing to
+// target, according to the policy of the extractor.
+func (x *extraction) link(p, target string) error {
+	switch x.e.Symlinks {
+	case RejectSymlinks:
+		return fmt.Errorf("Symbolic link %v in archive is not allowed.", p)
+	case ContainedSymlinks:
+		if _, err := x.path(p); err != nil {
+			return err
+		}
+		x.symlink = append(x.symlink, extractedLink{name: p, target: target})
+	}
+	return nil
+}
+
+// links creates the symbolic links of the archive, and removes them again
+// if one of them does not resolve to a file or directory within the
+// directory.
+//
+// The targets are checked before a link is created, and no link is created
+// in a directory that is reached through another link, so that a link never
+// ends up outside of the directory.
+func (x *extraction) links() error {
+	if len(x.symlink) == 0 {
+		return nil
+	}
+
+	root, err := filepath.EvalSymlinks(x.dir)
+	if err != nil {
+		return err
+	}
+
+	// Create all links first, as they may point to each other
+	var created []string
+	defer func() {
+		if err != nil {
+			for _, dst := range created {
+				os.Remove(dst)
+			}
+		}
+	}()
+	for _, l := range x.symlink {
+		dst, _ := x.path(l.name)
+		if !containedTarget(l.name, l.target) {
+			err = fmt.Errorf("Symbolic link %v points outside of the directory.", l.name)
+			return err
+		}
+
+		err = x.linkParents(l.name)
+		if err != nil {
+			return err
+		}
+		err = os.Symlink(filepath.FromSlash(l.target), dst)
+		if err != nil {
+			return err
+		}
+		created = append(created, dst)
+	}
+
+	for i, dst := range created {
+		resolved, rerr := filepath.EvalSymlinks(dst)
+		if rerr != nil || !within(root, resolved) {
+			err = fmt.Errorf("Symbolic link %v points outside of the directory.", x.symlink[i].name)
+			return err
+		}
+	}
+	return nil
+}
+
+// containedTarget reports whether the target of the link at the
+// slash-separated path name in the archive stays within the archive, without
+// following other links.
+func containedTarget(name, target string) bool {
+	if target == "" || path.IsAbs(target) || filepath.IsAbs(target) || strings.Contains(target, "\\") {
+		return false
+	}
+	p := path.Join(path.Dir(path.Clean(name)), target)
+	return p != ".." && !strings.HasPrefix(p, "../")
+}
+
+// linkParents creates the missing parent directories of the link at the
+// slash-separated path name in the archive, one at a time, and fails if one
+// of them is a link or no directory.
+func (x *extraction) linkParents(name string) error {
+	dir := x.dir
+	parts := strings.Split(path.Clean("/"+name), "/")
+	for _, part := range parts[1 : len(parts)-1] {
+		dir = filepath.Join(dir, part)
+		info, err := os.Lstat(dir)
+		if os.IsNotExist(err) {
+			err = os.Mkdir(dir, 0755)
+			if err != nil {
+				return err
+			}
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("Symbolic link %v is inside another symbolic link.", name)
+		} else if !info.IsDir() {
+			return fmt.Errorf("Symbolic link %v is inside a file.", name)
+		}
+	}
+	return nil
+}
+
+// within reports whether path p is root or inside it.
+func within(root, p string) bool {
+	rel, err := filepath.Rel(root, p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,209 @@
+package updater
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testEntry is an entry of a test archive, a symbolic link if link is set.
+type testEntry struct {
+	name     string
+	contents string
+	link     string
+}
+
+func newTestTarGzEntries(t *testing.T, entries []testEntry) []byte {
+	buf := bytes.NewBuffer(nil)
+	gz := gzip.NewWriter(buf)
+	w := tar.NewWriter(gz)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: 0644, Size: int64(len(e.contents)), Typeflag: tar.TypeReg}
+		if e.link != "" {
+			hdr = &tar.Header{Name: e.name, Mode: 0777, Linkname: e.link, Typeflag: tar.TypeSymlink}
+		}
+		require.Nil(t, w.WriteHeader(hdr))
+		w.Write([]byte(e.contents))
+	}
+	require.Nil(t, w.Close())
+	require.Nil(t, gz.Close())
+	return buf.Bytes()
+}
+
+func newTestZipEntries(t *testing.T, entries []testEntry) []byte {
+	buf := bytes.NewBuffer(nil)
+	w := zip.NewWriter(buf)
+	for _, e := range entries {
+		hdr := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		hdr.SetMode(0644)
+		contents := e.contents
+		if e.link != "" {
+			hdr.SetMode(os.ModeSymlink | 0777)
+			contents = e.link
+		}
+		f, err := w.CreateHeader(hdr)
+		require.Nil(t, err)
+		f.Write([]byte(contents))
+	}
+	require.Nil(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestSecureExtractor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-updater-test")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	n := 0
+	extract := func(e *SecureExtractor, entries []testEntry, format string) (string, error) {
+		n++
+		var archive []byte
+		if format == ".zip" {
+			archive = newTestZipEntries(t, entries)
+		} else {
+			archive = newTestTarGzEntries(t, entries)
+		}
+		p := filepath.Join(dir, "archive"+strings.Repeat("x", n)+format)
+		require.Nil(t, ioutil.WriteFile(p, archive, 0644))
+
+		dst := filepath.Join(dir, "dst"+strings.Repeat("x", n), "sub")
+		return dst, e.Extract(p, dst)
+	}
+
+	for _, format := range []string{".zip", ".tar.gz"} {
+		// Regular files
+		{
+			dst, err := extract(&SecureExtractor{}, []testEntry{
+				{name: "bin/myapp", contents: "Hello World!"},
+				{name: "README.md", contents: "Read me"},
+			}, format)
+			require.Nil(t, err, "Could not extract %v: %v", format, err)
+
+			b, err := ioutil.ReadFile(filepath.Join(dst, "bin", "myapp"))
+			assert.Nil(t, err)
+			assert.Equal(t, "Hello World!", string(b))
+		}
+
+		// Zip slip
+		for _, name := range []string{"../evil", "a/../../evil", "/evil"} {
+			_, err := extract(&SecureExtractor{}, []testEntry{
+				{name: name, contents: "Evil"},
+			}, format)
+			assert.Error(t, err, "Extracted %v from %v", name, format)
+			_, err = os.Stat(filepath.Join(dir, "evil"))
+			assert.True(t, os.IsNotExist(err))
+		}
+
+		// Size limit
+		{
+			entries := []testEntry{
+				{name: "a", contents: "Hello"},
+				{name: "b", contents: "World!"},
+			}
+			_, err := extract(&SecureExtractor{MaxSize: 10}, entries, format)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "limit of 10 bytes")
+
+			_, err = extract(&SecureExtractor{MaxSize: 11}, entries, format)
+			assert.Nil(t, err)
+		}
+
+		// File limit
+		{
+			entries := []testEntry{{name: "a"}, {name: "b"}, {name: "c"}}
+			_, err := extract(&SecureExtractor{MaxFiles: 2}, entries, format)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "more than 2 files")
+		}
+
+		// Symbolic links
+		{
+			entries := []testEntry{
+				{name: "bin/myapp", contents: "Hello World!"},
+				{name: "myapp", link: "current"},
+				{name: "current", link: "bin/myapp"},
+			}
+			dst, err := extract(&SecureExtractor{}, entries, format)
+			assert.Nil(t, err)
+			_, err = os.Lstat(filepath.Join(dst, "myapp"))
+			assert.True(t, os.IsNotExist(err))
+
+			_, err = extract(&SecureExtractor{Symlinks: RejectSymlinks}, entries, format)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "not allowed")
+
+			if runtime.GOOS == "windows" {
+				continue
+			}
+			dst, err = extract(&SecureExtractor{Symlinks: ContainedSymlinks}, entries, format)
+			require.Nil(t, err, "Could not extract %v: %v", format, err)
+			b, err := ioutil.ReadFile(filepath.Join(dst, "myapp"))
+			assert.Nil(t, err)
+			assert.Equal(t, "Hello World!", string(b))
+
+			for _, target := range []string{"/etc/passwd", "../../evil", "up/..", "missing"} {
+				dst, err := extract(&SecureExtractor{Symlinks: ContainedSymlinks}, []testEntry{
+					{name: "up", link: "."},
+					{name: "link", link: target},
+				}, format)
+				assert.Error(t, err, "Extracted link to %v from %v", target, format)
+				_, err = os.Lstat(filepath.Join(dst, "link"))
+				assert.True(t, os.IsNotExist(err))
+			}
+
+			// Link created through another link
+			outside := filepath.Join(dir, "outside")
+			require.Nil(t, os.MkdirAll(outside, 0755))
+			dst, err = extract(&SecureExtractor{Symlinks: ContainedSymlinks}, []testEntry{
+				{name: "d", link: "../../outside"},
+				{name: "d/sub/evil", link: "target"},
+			}, format)
+			assert.Error(t, err)
+			_, err = os.Lstat(filepath.Join(outside, "sub"))
+			assert.True(t, os.IsNotExist(err))
+			_, err = os.Lstat(filepath.Join(dst, "d"))
+			assert.True(t, os.IsNotExist(err))
+
+			// Link inside a link within the directory
+			dst, err = extract(&SecureExtractor{Symlinks: ContainedSymlinks}, []testEntry{
+				{name: "bin/myapp", contents: "Hello World!"},
+				{name: "d", link: "bin"},
+				{name: "d/evil", link: "myapp"},
+			}, format)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "inside another symbolic link")
+			_, err = os.Lstat(filepath.Join(dst, "bin", "evil"))
+			assert.True(t, os.IsNotExist(err))
+		}
+	}
+}
+
+func TestArchiveWriterLimits(t *testing.T) {
+	files := map[string]string{"myapp": "Hello World!"}
+	archives := map[string]func(string, AbortWriter) *ArchiveWriter{
+		string(newTestZip(t, files)):   NewZipExtractor,
+		string(newTestTarGz(t, files)): NewTarGzExtractor,
+	}
+
+	for archive, newExtractor := range archives {
+		dest := NewAbortBuffer(nil)
+		w := newExtractor("myapp", dest)
+		w.Extractor = &SecureExtractor{MaxSize: 5}
+		w.Write([]byte(archive))
+
+		err := w.Close()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "limit of 5 bytes")
+		assert.True(t, dest.aborted)
+	}
+}
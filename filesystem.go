@@ -0,0 +1,310 @@
+package updater
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Name of the metadata file of a release in a file system application.
+const fileSystemMetadataName = "manifest.json"
+
+// ReleaseTree is a directory tree with releases, see NewReleaseTree. Paths are
+// slash-separated and relative to the root of the tree.
+type ReleaseTree interface {
+	// ReadDir should return the entries of the directory at path.
+	ReadDir(ctx context.Context, path string) ([]os.FileInfo, error)
+
+	// Open should open the file at path.
+	Open(ctx context.Context, path string) (io.ReadCloser, error)
+}
+
+type fileSystemApp struct {
+	tree     ReleaseTree
+	releases []Release
+}
+
+type fileSystemRelease struct {
+	Manifest Manifest
+
+	name    string
+	version version
+	assets  []Asset
+}
+
+type fileSystemAsset struct {
+	name   string
+	path   string
+	size   int64
+	sha256 string
+
+	tree ReleaseTree
+}
+
+// NewFileSystem creates an Application whose releases are stored in a local
+// directory, e.g. on a network share or a USB drive, for air-gapped
+// environments that receive updates out-of-band.
+//
+// Every release is a directory in the releases directory of dir, named after
+// its version, and its assets are the files in it:
+//
+//	releases/
+//		v1.1.0/
+//			myapp_linux_amd64
+//		v1.2.0/
+//			manifest.json
+//			myapp_linux_amd64
+//			SHA256SUMS
+//
+// Directories that are not a semantic version are ignored. Releases are
+// ordered by version, the highest first, and the latest release is the
+// highest version that is not a pre-release.
+//
+// A release can have a metadata file named manifest.json, in the format of
+// Manifest, e.g. as written by the publisher package. Its notes, identifier
+// and mandatory flag are used, and the assets with a checksum in the manifest
+// are verified while they are written. The URLs of the manifest are ignored.
+// The identifier defaults to the name of the directory.
+func NewFileSystem(dir string) App {
+	return NewReleaseTree(localTree(dir))
+}
+
+// NewReleaseTree creates an Application whose releases are stored in tree,
+// with the layout of NewFileSystem, e.g. on a remote file system. The
+// application implements io.Closer if tree does.
+func NewReleaseTree(tree ReleaseTree) App {
+	return &fileSystemApp{tree: tree}
+}
+
+func (app *fileSystemApp) Query() error {
+	return app.QueryContext(context.Background())
+}
+
+// QueryContext reads the releases from the directory.
+func (app *fileSystemApp) QueryContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	entries, err := app.tree.ReadDir(ctx, "releases")
+	if err != nil {
+		return err
+	}
+
+	var releases []*fileSystemRelease
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		v, err := parseVersion(e.Name())
+		if err != nil {
+			continue
+		}
+
+		r, err := newFileSystemRelease(ctx, app.tree, path.Join("releases", e.Name()), v)
+		if err != nil {
+			return err
+		}
+		releases = append(releases, r)
+	}
+
+	sort.SliceStable(releases, func(i, j int) bool {
+		return releases[i].version.compare(releases[j].version) > 0
+	})
+
+	s := make([]Release, len(releases))
+	for i, r := range releases {
+		s[i] = r
+	}
+	app.releases = s
+	return nil
+}
+
+// Close closes the connection to the server of a remote application, see
+// NewFTP.
+func (app *fileSystemApp) Close() error {
+	if c, ok := app.tree.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+func (app *fileSystemApp) LatestRelease() Release {
+	for _, r := range app.releases {
+		if len(r.(*fileSystemRelease).version.pre) == 0 {
+			return r
+		}
+	}
+	return nil
+}
+
+func (app *fileSystemApp) Releases() []Release {
+	return app.releases
+}
+
+// newFileSystemRelease reads the release in directory dir of tree.
+func newFileSystemRelease(ctx context.Context, tree ReleaseTree, dir string, v version) (*fileSystemRelease, error) {
+	r := &fileSystemRelease{name: path.Base(dir), version: v}
+
+	entries, err := tree.ReadDir(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []os.FileInfo
+	for _, e := range entries {
+		name := e.Name()
+		if !e.Mode().IsRegular() || strings.HasPrefix(name, ".") {
+			continue
+		} else if name != fileSystemMetadataName {
+			files = append(files, e)
+			continue
+		}
+
+		err := readFileSystemManifest(ctx, tree, path.Join(dir, name), &r.Manifest)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid manifest for release %v: %v", r.name, err)
+		}
+	}
+
+	checksums := make(map[string]string)
+	for _, a := range r.Manifest.Assets {
+		checksums[a.Name] = a.SHA256
+	}
+
+	for _, e := range files {
+		r.assets = append(r.assets, &fileSystemAsset{
+			name:   e.Name(),
+			path:   path.Join(dir, e.Name()),
+			size:   e.Size(),
+			sha256: checksums[e.Name()],
+			tree:   tree,
+		})
+	}
+
+	return r, nil
+}
+
+// readFileSystemManifest reads the metadata file at path of tree into m.
+func readFileSystemManifest(ctx context.Context, tree ReleaseTree, path string, m *Manifest) error {
+	f, err := tree.Open(ctx, path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return json.NewDecoder(f).Decode(m)
+}
+
+func (r *fileSystemRelease) Name() string {
+	if r.Manifest.Version != "" {
+		return r.Manifest.Version
+	}
+	return r.name
+}
+
+func (r *fileSystemRelease) Information() string {
+	return r.Manifest.Notes
+}
+
+func (r *fileSystemRelease) Identifier() string {
+	if r.Manifest.Identifier != "" {
+		return r.Manifest.Identifier
+	}
+	return r.name
+}
+
+func (r *fileSystemRelease) Assets() []Asset {
+	return r.assets
+}
+
+func (r *fileSystemRelease) Mandatory() bool {
+	return r.Manifest.Mandatory || hasMandatoryToken(r.Manifest.Notes)
+}
+
+func (r *fileSystemRelease) RolloutPercentage() int {
+	return manifestRollout(r.Manifest)
+}
+
+func (r *fileSystemAsset) Name() string {
+	return r.name
+}
+
+func (r *fileSystemAsset) Write(w io.Writer) error {
+	return r.WriteContext(context.Background(), w)
+}
+
+// WriteContext copies the file of the asset to w, and verifies its checksum
+// if the release has one.
+func (r *fileSystemAsset) WriteContext(ctx context.Context, w io.Writer) error {
+	f, err := r.tree.Open(ctx, r.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if r.sha256 == "" {
+		_, err = io.Copy(w, f)
+		return err
+	}
+
+	expected, err := hex.DecodeString(r.sha256)
+	if err != nil {
+		return fmt.Errorf("Invalid checksum for %v: %v", r.Name(), err)
+	}
+
+	h := sha256.New()
+	_, err = io.Copy(teeWriter(w, h), f)
+	if err != nil {
+		return err
+	}
+
+	return verifyChecksum(map[string][]byte{r.Name(): expected}, r, h.Sum(nil))
+}
+
+// Open opens the file of the asset. Reading fails at the end of the file if
+// its checksum does not match the checksum of the release.
+func (r *fileSystemAsset) Open(ctx context.Context) (io.ReadCloser, int64, error) {
+	f, err := r.tree.Open(ctx, r.path)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if r.sha256 == "" {
+		return f, r.size, nil
+	}
+	rc, err := newChecksumReader(f, r, r.sha256)
+	return rc, r.size, err
+}
+
+func (r *fileSystemAsset) Size() int64 {
+	return r.size
+}
+
+func (r *fileSystemAsset) ContentType() string {
+	return ""
+}
+
+func (r *fileSystemAsset) DownloadCount() int {
+	return -1
+}
+
+// localTree is a ReleaseTree in a local directory.
+type localTree string
+
+func (t localTree) ReadDir(ctx context.Context, path string) ([]os.FileInfo, error) {
+	return ioutil.ReadDir(filepath.Join(string(t), filepath.FromSlash(path)))
+}
+
+func (t localTree) Open(ctx context.Context, path string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(string(t), filepath.FromSlash(path)))
+}
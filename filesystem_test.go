@@ -0,0 +1,157 @@
+package updater
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileSystem(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filesystem-")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	write := func(path, contents string) {
+		path = filepath.Join(dir, "releases", filepath.FromSlash(path))
+		require.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.Nil(t, ioutil.WriteFile(path, []byte(contents), 0644))
+	}
+
+	// Missing directory
+	{
+		app := NewFileSystem(dir)
+		assert.NotNil(t, app.Query())
+		assert.Nil(t, app.LatestRelease())
+	}
+
+	write("v1.1.0/myapp", "Old")
+	write("v1.2.0/myapp", "Hello World!")
+	write("v1.2.0/README", "Corrupted")
+	write("v1.2.0/.DS_Store", "")
+	write("v1.2.0/manifest.json", `{
+		"version": "v1.2.0",
+		"notes": "Bug fixes.",
+		"identifier": "1.2.0-build.5",
+		"mandatory": true,
+		"assets": [
+			{"name": "myapp", "url": "https://example.com/myapp", "sha256": "7f83b1657ff1fc53b92dc18148a1d65dfc2d4b1fa3d677284addd200126d9069"},
+			{"name": "README", "sha256": "7f83b1657ff1fc53b92dc18148a1d65dfc2d4b1fa3d677284addd200126d9069"}
+		]
+	}`)
+	write("v1.3.0-beta.1/myapp", "Beta")
+	write("latest/myapp", "Not a release")
+
+	// Releases ordered by version
+	{
+		app := NewFileSystem(dir)
+		err := app.Query()
+		require.Nil(t, err, "Unexpected query error: %v", err)
+
+		var names []string
+		for _, r := range app.(ReleasesApp).Releases() {
+			names = append(names, r.Name())
+		}
+		assert.Equal(t, []string{"v1.3.0-beta.1", "v1.2.0", "v1.1.0"}, names)
+
+		r := app.LatestRelease()
+		require.NotNil(t, r)
+		assert.Equal(t, "v1.2.0", r.Name())
+		assert.Equal(t, "Bug fixes.", r.Information())
+		assert.Equal(t, "1.2.0-build.5", r.Identifier())
+		assert.True(t, IsMandatory(r))
+		require.Equal(t, 2, len(r.Assets()))
+		assert.Equal(t, "README", r.Assets()[0].Name())
+		assert.Equal(t, "myapp", r.Assets()[1].Name())
+		assert.Equal(t, int64(12), r.Assets()[1].(AssetMeta).Size())
+
+		// Valid checksum
+		buf := bytes.NewBuffer(nil)
+		err = r.Assets()[1].Write(buf)
+		assert.Nil(t, err, "Unexpected write error: %v", err)
+		assert.Equal(t, "Hello World!", buf.String())
+
+		// Invalid checksum
+		err = r.Assets()[0].Write(bytes.NewBuffer(nil))
+		if assert.NotNil(t, err) {
+			assert.Contains(t, err.Error(), "mismatch")
+		}
+
+		// Release without metadata
+		old := app.(ReleasesApp).Releases()[2]
+		assert.Equal(t, "v1.1.0", old.Identifier())
+		assert.Equal(t, "", old.Information())
+		assert.False(t, IsMandatory(old))
+
+		buf.Reset()
+		assert.Nil(t, old.Assets()[0].Write(buf))
+		assert.Equal(t, "Old", buf.String())
+	}
+
+	// Update from the directory
+	{
+		b := NewAbortBuffer(nil)
+		u := &Updater{
+			App:                      NewFileSystem(dir),
+			CurrentReleaseIdentifier: "v1.1.0",
+			AssetFilter:              func(a Asset) bool { return a.Name() == "myapp" },
+			WriterForAsset:           func(Asset) (AbortWriter, error) { return b, nil },
+		}
+		err := u.UpdateTo(nil)
+		assert.Nil(t, err, "Could not update: %v", err)
+		assert.Equal(t, "Hello World!", b.Buffer.String())
+	}
+
+	// Invalid metadata
+	{
+		write("v1.2.0/manifest.json", "invalid json")
+		err := NewFileSystem(dir).Query()
+		if assert.NotNil(t, err) {
+			assert.Contains(t, err.Error(), "v1.2.0")
+		}
+	}
+}
+
+// testClosingTree is a release tree that records whether it was closed.
+type testClosingTree struct {
+	ReleaseTree
+	closed bool
+}
+
+func (t *testClosingTree) Close() error {
+	t.closed = true
+	return nil
+}
+
+func TestReleaseTree(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filesystem-")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "releases", "v1.0.0", "myapp")
+	require.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.Nil(t, ioutil.WriteFile(path, []byte("Hello World!"), 0644))
+
+	tree := &testClosingTree{ReleaseTree: localTree(dir)}
+	app := NewReleaseTree(tree)
+	err = app.Query()
+	require.Nil(t, err, "Unexpected query error: %v", err)
+
+	r := app.LatestRelease()
+	require.NotNil(t, r)
+	assert.Equal(t, "v1.0.0", r.Name())
+	require.Equal(t, 1, len(r.Assets()))
+
+	buf := bytes.NewBuffer(nil)
+	assert.Nil(t, r.Assets()[0].Write(buf))
+	assert.Equal(t, "Hello World!", buf.String())
+
+	// The tree is closed with the application
+	assert.Nil(t, app.(io.Closer).Close())
+	assert.True(t, tree.closed)
+}
@@ -0,0 +1,92 @@
+package updater
+
+import (
+	"runtime"
+	"strings"
+)
+
+// Common alternative spellings of GOARCH values in asset names.
+var archAliases = map[string][]string{
+	"amd64": {"x86_64", "x64"},
+	"386":   {"i386", "i686"},
+	"arm64": {"aarch64"},
+}
+
+// PlatformAsset is an Asset that declares the platform it was built for, e.g.
+// an asset of a SignedManifest.
+type PlatformAsset interface {
+	Asset
+
+	// Platform should return the operating system and architecture of the
+	// asset, as GOOS and GOARCH, or empty strings if the asset is not
+	// specific to an operating system or architecture.
+	Platform() (goos, goarch string)
+}
+
+// DefaultPlatformFilter returns an asset filter that selects assets built for
+// the current operating system and architecture.
+//
+// See PlatformFilter for the matching rules.
+func DefaultPlatformFilter() func(Asset) bool {
+	return PlatformFilter(runtime.GOOS, runtime.GOARCH)
+}
+
+// PlatformFilter returns an asset filter that selects assets built for the
+// given operating system and architecture.
+//
+// An asset name matches if it contains both goos and goarch as separate
+// words, e.g. myapp_linux_amd64.tar.gz or myapp-darwin-arm64.zip. Common
+// aliases for architectures, like x86_64 for amd64, are recognized as well.
+// Matching is case-insensitive.
+//
+// Assets that implement PlatformAsset and declare a platform are matched by
+// that platform instead of their name. An empty operating system or
+// architecture matches any.
+func PlatformFilter(goos, goarch string) func(Asset) bool {
+	goos = strings.ToLower(goos)
+	goarch = strings.ToLower(goarch)
+	arches := append([]string{goarch}, archAliases[goarch]...)
+
+	return func(a Asset) bool {
+		if p, ok := a.(PlatformAsset); ok {
+			os, arch := p.Platform()
+			if os != "" || arch != "" {
+				return (os == "" || strings.ToLower(os) == goos) &&
+					(arch == "" || strings.ToLower(arch) == goarch)
+			}
+		}
+
+		name := strings.ToLower(a.Name())
+		if !containsWord(name, goos) {
+			return false
+		}
+		for _, arch := range arches {
+			if containsWord(name, arch) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// containsWord reports whether word occurs in s delimited by separators or
+// the boundaries of s.
+func containsWord(s, word string) bool {
+	for i := 0; i <= len(s)-len(word); i++ {
+		if s[i:i+len(word)] != word {
+			continue
+		}
+		if i > 0 && !isNameSeparator(s[i-1]) {
+			continue
+		}
+		if j := i + len(word); j < len(s) && !isNameSeparator(s[j]) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+func isNameSeparator(c byte) bool {
+	return c == '_' || c == '-' || c == '.' || c == ' '
+}
@@ -0,0 +1,27 @@
+package updater
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlatformFilter(t *testing.T) {
+	f := PlatformFilter("linux", "amd64")
+
+	assert.True(t, f(&testAsset{name: "myapp_linux_amd64.tar.gz"}))
+	assert.True(t, f(&testAsset{name: "myapp-Linux-x86_64.zip"}))
+	assert.False(t, f(&testAsset{name: "myapp_darwin_amd64.tar.gz"}))
+	assert.False(t, f(&testAsset{name: "myapp_linux_arm64.tar.gz"}))
+	assert.False(t, f(&testAsset{name: "myapp_linuxamd64"}))
+	assert.False(t, f(&testAsset{name: "checksums.txt"}))
+}
+
+func TestDefaultPlatformFilter(t *testing.T) {
+	f := DefaultPlatformFilter()
+
+	name := "myapp_" + runtime.GOOS + "_" + runtime.GOARCH
+	assert.True(t, f(&testAsset{name: name}))
+	assert.False(t, f(&testAsset{name: "myapp_plan10_z80"}))
+}
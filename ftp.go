@@ -0,0 +1,509 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FTPOptions are the options of an FTP application.
+type FTPOptions struct {
+	// User to log in with. The anonymous user is used if it is empty.
+	User string
+
+	// Password of the user.
+	Password string
+}
+
+type ftpTree struct {
+	address string
+	dir     string
+	opts    FTPOptions
+
+	// mutex guards conn, and is held while a file is downloaded.
+	mutex sync.Mutex
+	conn  *ftpConn
+}
+
+// NewFTP creates an Application whose releases are stored on an FTP server,
+// in directory dir and with the layout of NewFileSystem. The address is the
+// host of the server, with an optional port that defaults to 21. A relative
+// dir is relative to the home directory of the user.
+//
+// A single connection is opened when the releases are queried, and it is
+// reused to download the assets. It is reopened if the server closed it in
+// the meantime. Assets are downloaded one at a time, and the application
+// implements io.Closer to close the connection.
+//
+// FTP is not encrypted, so add a checksum to the manifest of a release or
+// verify its assets with a signature.
+func NewFTP(address, dir string, opts FTPOptions) App {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, "21")
+	}
+	return &fileSystemApp{tree: &ftpTree{
+		address: address,
+		dir:     dir,
+		opts:    opts,
+	}}
+}
+
+func (t *ftpTree) ReadDir(ctx context.Context, p string) ([]os.FileInfo, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	var entries []os.FileInfo
+	err := t.do(ctx, func(c *ftpConn) (err error) {
+		entries, err = c.list(ctx, t.path(p))
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Could not list %v on %v: %v", t.path(p), t.address, err)
+	}
+	return entries, nil
+}
+
+func (t *ftpTree) Open(ctx context.Context, p string) (io.ReadCloser, error) {
+	t.mutex.Lock()
+
+	var data *ftpData
+	err := t.do(ctx, func(c *ftpConn) (err error) {
+		data, err = c.transfer(ctx, "RETR %v", t.path(p))
+		return err
+	})
+	if err != nil {
+		t.mutex.Unlock()
+		return nil, fmt.Errorf("Could not download %v from %v: %v", t.path(p), t.address, err)
+	}
+	return &ftpFile{tree: t, data: data}, nil
+}
+
+// Close closes the connection to the server, if it is open.
+func (t *ftpTree) Close() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.conn == nil {
+		return nil
+	}
+	err := t.conn.quit()
+	t.conn = nil
+	return err
+}
+
+// path returns the path of p on the server.
+func (t *ftpTree) path(p string) string {
+	if t.dir == "" {
+		return p
+	}
+	return path.Join(t.dir, p)
+}
+
+// do calls f with the connection to the server, and opens it first if needed.
+// A reused connection that fails with anything else than an FTP error is
+// assumed to be closed by the server, and f is retried once on a new one.
+//
+// The mutex must be held.
+func (t *ftpTree) do(ctx context.Context, f func(*ftpConn) error) error {
+	for {
+		reused := t.conn != nil
+		if !reused {
+			c, err := dialFTP(ctx, t.address, t.opts)
+			if err != nil {
+				return err
+			}
+			t.conn = c
+		}
+
+		err := f(t.conn)
+		if err == nil || isFTPError(err) {
+			return err
+		}
+
+		t.conn.close()
+		t.conn = nil
+		if ctx.Err() != nil {
+			return ctx.Err()
+		} else if !reused {
+			return err
+		}
+	}
+}
+
+// ftpFile is a file that is being downloaded from an FTP server. The mutex of
+// the tree is held until it is closed.
+type ftpFile struct {
+	tree   *ftpTree
+	data   *ftpData
+	closed bool
+}
+
+func (f *ftpFile) Read(b []byte) (int, error) {
+	return f.data.Read(b)
+}
+
+func (f *ftpFile) Close() error {
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+	defer f.tree.mutex.Unlock()
+
+	err := f.data.Close()
+	if err != nil && !isFTPError(err) && f.tree.conn != nil {
+		f.tree.conn.close()
+		f.tree.conn = nil
+	}
+	return err
+}
+
+// ftpConn is a control connection to an FTP server.
+type ftpConn struct {
+	conn net.Conn
+	text *textproto.Conn
+}
+
+// dialFTP connects to the FTP server at address, and logs in.
+func dialFTP(ctx context.Context, address string, opts FTPOptions) (*ftpConn, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	c := &ftpConn{conn: conn, text: textproto.NewConn(conn)}
+
+	err = c.login(ctx, opts)
+	if err != nil {
+		c.close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *ftpConn) login(ctx context.Context, opts FTPOptions) error {
+	defer watchConn(ctx, c.conn)()
+
+	_, _, err := c.text.ReadResponse(2)
+	if err != nil {
+		return err
+	}
+
+	user, password := opts.User, opts.Password
+	if user == "" {
+		user, password = "anonymous", "anonymous@"
+	}
+
+	code, msg, err := c.cmd(0, "USER %v", user)
+	if err != nil {
+		return err
+	}
+	if code == 331 {
+		code, msg, err = c.cmd(0, "PASS %v", password)
+		if err != nil {
+			return err
+		}
+	}
+	if code != 230 && code != 202 {
+		return &textproto.Error{Code: code, Msg: msg}
+	}
+
+	_, _, err = c.cmd(2, "TYPE I")
+	return err
+}
+
+// cmd sends a command and reads the response, which should have expectCode
+// as prefix, see textproto.Reader.ReadResponse.
+func (c *ftpConn) cmd(expectCode int, format string, args ...interface{}) (int, string, error) {
+	line := fmt.Sprintf(format, args...)
+	if strings.ContainsAny(line, "\r\n") {
+		return 0, "", errors.New("Invalid line break in FTP command.")
+	}
+
+	err := c.text.PrintfLine("%s", line)
+	if err != nil {
+		return 0, "", err
+	}
+	return c.text.ReadResponse(expectCode)
+}
+
+// list returns the entries of directory dir, sorted by name.
+func (c *ftpConn) list(ctx context.Context, dir string) ([]os.FileInfo, error) {
+	parse := parseMLSDLine
+	data, err := c.transfer(ctx, "MLSD %v", dir)
+	if e, ok := err.(*textproto.Error); ok && (e.Code == 500 || e.Code == 502) {
+		parse = parseListLine
+		data, err = c.transfer(ctx, "LIST %v", dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := ioutil.ReadAll(data)
+	if cerr := data.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []os.FileInfo
+	for _, line := range strings.Split(string(b), "\n") {
+		e := parse(strings.TrimRight(line, "\r"))
+		if e != nil && e.name != "." && e.name != ".." {
+			entries = append(entries, e)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, nil
+}
+
+// transfer opens a passive data connection and sends a command that transfers
+// data over it.
+func (c *ftpConn) transfer(ctx context.Context, format string, args ...interface{}) (*ftpData, error) {
+	stop := watchConn(ctx, c.conn)
+
+	conn, err := c.passive(ctx)
+	if err != nil {
+		stop()
+		return nil, err
+	}
+
+	_, _, err = c.cmd(1, format, args...)
+	if err != nil {
+		conn.Close()
+		stop()
+		return nil, err
+	}
+
+	return &ftpData{
+		conn:     c,
+		data:     conn,
+		stop:     stop,
+		stopData: watchConn(ctx, conn),
+	}, nil
+}
+
+// passive opens a data connection in extended or regular passive mode. The
+// data connection is opened to the host of the control connection, and not
+// to the one in the response, which is often wrong behind NAT.
+func (c *ftpConn) passive(ctx context.Context) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(c.conn.RemoteAddr().String())
+	if err != nil {
+		return nil, err
+	}
+
+	var port int
+	code, msg, err := c.cmd(2, "EPSV")
+	if err == nil {
+		port, err = parseEPSV(msg)
+	} else if isFTPError(err) {
+		code, msg, err = c.cmd(2, "PASV")
+		if err == nil {
+			port, err = parsePASV(msg)
+		}
+	}
+	if err != nil {
+		return nil, err
+	} else if port <= 0 || port > 65535 {
+		return nil, fmt.Errorf("Invalid passive mode response: %v %v", code, msg)
+	}
+
+	var d net.Dialer
+	return d.DialContext(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+}
+
+// quit logs out and closes the connection.
+func (c *ftpConn) quit() error {
+	c.conn.SetDeadline(time.Now().Add(5 * time.Second))
+	c.cmd(0, "QUIT")
+	return c.close()
+}
+
+func (c *ftpConn) close() error {
+	return c.text.Close()
+}
+
+// ftpData is a data connection of a transfer.
+type ftpData struct {
+	conn     *ftpConn
+	data     net.Conn
+	stop     func()
+	stopData func()
+}
+
+func (d *ftpData) Read(b []byte) (int, error) {
+	return d.data.Read(b)
+}
+
+// Close closes the data connection and reads the result of the transfer.
+func (d *ftpData) Close() error {
+	d.data.Close()
+	d.stopData()
+	defer d.stop()
+
+	_, _, err := d.conn.text.ReadResponse(2)
+	return err
+}
+
+// ftpFileInfo is an entry of a directory listing.
+type ftpFileInfo struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func (e *ftpFileInfo) Name() string       { return e.name }
+func (e *ftpFileInfo) Size() int64        { return e.size }
+func (e *ftpFileInfo) ModTime() time.Time { return time.Time{} }
+func (e *ftpFileInfo) IsDir() bool        { return e.dir }
+func (e *ftpFileInfo) Sys() interface{}   { return nil }
+
+func (e *ftpFileInfo) Mode() os.FileMode {
+	if e.dir {
+		return os.ModeDir | 0755
+	}
+	return 0644
+}
+
+// parseMLSDLine parses a line of a machine-readable listing (RFC 3659), like
+// "type=file;size=12; myapp". It returns nil for anything else than a file or
+// directory.
+func parseMLSDLine(line string) *ftpFileInfo {
+	i := strings.Index(line, " ")
+	if i < 0 {
+		return nil
+	}
+
+	e := &ftpFileInfo{name: line[i+1:]}
+	isFile := false
+	for _, fact := range strings.Split(line[:i], ";") {
+		kv := strings.SplitN(fact, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch strings.ToLower(kv[0]) {
+		case "type":
+			e.dir = strings.EqualFold(kv[1], "dir")
+			isFile = strings.EqualFold(kv[1], "file")
+		case "size":
+			e.size, _ = strconv.ParseInt(kv[1], 10, 64)
+		}
+	}
+
+	if !e.dir && !isFile {
+		return nil
+	}
+	return e
+}
+
+// parseListLine parses a line of a Unix-style listing, like
+// "-rw-r--r-- 1 ftp ftp 12 Jan 02 15:04 myapp". It returns nil for anything
+// else than a file or directory.
+func parseListLine(line string) *ftpFileInfo {
+	fields := strings.Fields(line)
+	if len(fields) < 9 || (line[0] != '-' && line[0] != 'd') {
+		return nil
+	}
+	size, err := strconv.ParseInt(fields[4], 10, 64)
+	if err != nil {
+		return nil
+	}
+
+	// The name is what follows the eighth field, and may contain spaces
+	rest := line
+	for i := 0; i < 8; i++ {
+		rest = strings.TrimLeft(rest, " \t")
+		rest = rest[strings.IndexAny(rest, " \t"):]
+	}
+
+	return &ftpFileInfo{
+		name: strings.TrimLeft(rest, " \t"),
+		size: size,
+		dir:  line[0] == 'd',
+	}
+}
+
+// parseEPSV returns the port of an extended passive mode response, like
+// "Entering Extended Passive Mode (|||6446|)".
+func parseEPSV(msg string) (int, error) {
+	start, end := strings.Index(msg, "("), strings.LastIndex(msg, ")")
+	if start < 0 || end < start {
+		return 0, fmt.Errorf("Invalid passive mode response: %v", msg)
+	}
+
+	parts := strings.Split(msg[start+1:end], "|")
+	if len(parts) != 5 {
+		return 0, fmt.Errorf("Invalid passive mode response: %v", msg)
+	}
+	return strconv.Atoi(parts[3])
+}
+
+// parsePASV returns the port of a passive mode response, like
+// "Entering Passive Mode (192,168,1,2,25,46)".
+func parsePASV(msg string) (int, error) {
+	start, end := strings.Index(msg, "("), strings.LastIndex(msg, ")")
+	if start < 0 || end < start {
+		return 0, fmt.Errorf("Invalid passive mode response: %v", msg)
+	}
+
+	parts := strings.Split(msg[start+1:end], ",")
+	if len(parts) != 6 {
+		return 0, fmt.Errorf("Invalid passive mode response: %v", msg)
+	}
+	hi, err1 := strconv.Atoi(parts[4])
+	lo, err2 := strconv.Atoi(parts[5])
+	if err1 != nil || err2 != nil {
+		return 0, fmt.Errorf("Invalid passive mode response: %v", msg)
+	}
+	return hi<<8 | lo, nil
+}
+
+// isFTPError returns whether err is an error response of the server, after
+// which the connection can still be used.
+func isFTPError(err error) bool {
+	_, ok := err.(*textproto.Error)
+	return ok
+}
+
+// watchConn interrupts conn when ctx is done, until the returned function is
+// called.
+func watchConn(ctx context.Context, conn net.Conn) func() {
+	if d, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(d)
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Unix(1, 0))
+		case <-done:
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+		if ctx.Err() == nil {
+			conn.SetDeadline(time.Time{})
+		}
+	}
+}
@@ -0,0 +1,395 @@
+package updater
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFTP(t *testing.T) {
+	s := newTestFTPServer(t, map[string]string{
+		"/srv/myapp/releases/v1.1.0/myapp":         "Old",
+		"/srv/myapp/releases/v1.2.0/myapp":         "Hello World!",
+		"/srv/myapp/releases/v1.2.0/my app.txt":    "Notes",
+		"/srv/myapp/releases/v1.2.0/manifest.json": `{"notes": "Bug fixes.", "assets": [{"name": "myapp", "sha256": "7f83b1657ff1fc53b92dc18148a1d65dfc2d4b1fa3d677284addd200126d9069"}]}`,
+		"/srv/myapp/releases/latest/myapp":         "Not a release",
+	})
+	defer s.Close()
+	opts := FTPOptions{User: "deploy", Password: "secret"}
+
+	// Releases and assets over a single connection
+	{
+		app := NewFTP(s.Addr(), "/srv/myapp", opts)
+		defer app.(io.Closer).Close()
+
+		err := app.Query()
+		require.Nil(t, err, "Unexpected query error: %v", err)
+
+		var names []string
+		for _, r := range app.(ReleasesApp).Releases() {
+			names = append(names, r.Name())
+		}
+		assert.Equal(t, []string{"v1.2.0", "v1.1.0"}, names)
+
+		r := app.LatestRelease()
+		require.NotNil(t, r)
+		assert.Equal(t, "Bug fixes.", r.Information())
+		require.Equal(t, 2, len(r.Assets()))
+		assert.Equal(t, "my app.txt", r.Assets()[0].Name())
+		assert.Equal(t, "myapp", r.Assets()[1].Name())
+		assert.Equal(t, int64(12), r.Assets()[1].(AssetMeta).Size())
+
+		b := NewAbortBuffer(nil)
+		u := &Updater{
+			App:                      app,
+			CurrentReleaseIdentifier: "v1.1.0",
+			AssetFilter:              func(a Asset) bool { return a.Name() == "myapp" },
+			WriterForAsset:           func(Asset) (AbortWriter, error) { return b, nil },
+		}
+		err = u.UpdateTo(nil)
+		assert.Nil(t, err, "Could not update: %v", err)
+		assert.Equal(t, "Hello World!", b.Buffer.String())
+		assert.Equal(t, 1, s.Logins())
+	}
+
+	// Unix listings in regular passive mode
+	{
+		s.legacy = true
+		defer func() { s.legacy = false }()
+
+		app := NewFTP(s.Addr(), "/srv/myapp", opts)
+		defer app.(io.Closer).Close()
+
+		err := app.Query()
+		require.Nil(t, err, "Unexpected query error: %v", err)
+		r := app.LatestRelease()
+		require.NotNil(t, r)
+		require.Equal(t, 2, len(r.Assets()))
+		assert.Equal(t, "my app.txt", r.Assets()[0].Name())
+
+		buf := bytes.NewBuffer(nil)
+		assert.Nil(t, r.Assets()[0].Write(buf))
+		assert.Equal(t, "Notes", buf.String())
+	}
+
+	// Reconnect when the server closed the connection
+	{
+		app := NewFTP(s.Addr(), "/srv/myapp", opts)
+		defer app.(io.Closer).Close()
+		require.Nil(t, app.Query())
+		logins := s.Logins()
+
+		s.Disconnect()
+		buf := bytes.NewBuffer(nil)
+		err := app.LatestRelease().Assets()[1].Write(buf)
+		assert.Nil(t, err, "Unexpected write error: %v", err)
+		assert.Equal(t, "Hello World!", buf.String())
+		assert.Equal(t, logins+1, s.Logins())
+	}
+
+	// Missing file
+	{
+		app := NewFTP(s.Addr(), "/srv/other", opts)
+		defer app.(io.Closer).Close()
+		err := app.Query()
+		if assert.NotNil(t, err) {
+			assert.Contains(t, err.Error(), "/srv/other/releases")
+		}
+	}
+
+	// Invalid credentials
+	{
+		app := NewFTP(s.Addr(), "/srv/myapp", FTPOptions{User: "deploy", Password: "wrong"})
+		err := app.Query()
+		if assert.NotNil(t, err) {
+			assert.Contains(t, err.Error(), "530")
+		}
+	}
+
+	// Cancelled download
+	{
+		app := NewFTP(s.Addr(), "/srv/myapp", opts)
+		defer app.(io.Closer).Close()
+		require.Nil(t, app.Query())
+
+		s.stall = true
+		defer func() { s.stall = false }()
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		err := app.LatestRelease().Assets()[1].(ContextAsset).WriteContext(ctx, bytes.NewBuffer(nil))
+		assert.NotNil(t, err)
+	}
+}
+
+func TestParseFTPListings(t *testing.T) {
+	// Machine-readable listings
+	{
+		e := parseMLSDLine("type=file;size=12;modify=20240102150405; my app")
+		require.NotNil(t, e)
+		assert.Equal(t, "my app", e.Name())
+		assert.Equal(t, int64(12), e.Size())
+		assert.False(t, e.IsDir())
+
+		e = parseMLSDLine("Type=dir;Modify=20240102150405; v1.2.0")
+		require.NotNil(t, e)
+		assert.True(t, e.IsDir())
+
+		assert.Nil(t, parseMLSDLine("type=cdir; ."))
+		assert.Nil(t, parseMLSDLine("type=OS.unix=symlink; link"))
+		assert.Nil(t, parseMLSDLine(""))
+	}
+
+	// Unix listings
+	{
+		e := parseListLine("-rw-r--r--    1 ftp      ftp            12 Jan 02 15:04 my  app")
+		require.NotNil(t, e)
+		assert.Equal(t, "my  app", e.Name())
+		assert.Equal(t, int64(12), e.Size())
+		assert.False(t, e.IsDir())
+
+		e = parseListLine("drwxr-xr-x 2 ftp ftp 4096 Jan 02  2024 v1.2.0")
+		require.NotNil(t, e)
+		assert.True(t, e.IsDir())
+
+		assert.Nil(t, parseListLine("total 8"))
+		assert.Nil(t, parseListLine("lrwxrwxrwx 1 ftp ftp 5 Jan 02 15:04 link -> myapp"))
+	}
+
+	// Passive mode responses
+	{
+		port, err := parseEPSV("Entering Extended Passive Mode (|||6446|)")
+		assert.Nil(t, err)
+		assert.Equal(t, 6446, port)
+
+		port, err = parsePASV("Entering Passive Mode (192,168,1,2,25,46)")
+		assert.Nil(t, err)
+		assert.Equal(t, 6446, port)
+
+		_, err = parsePASV("Entering Passive Mode")
+		assert.NotNil(t, err)
+	}
+}
+
+// testFTPServer is a minimal FTP server that serves files from memory.
+type testFTPServer struct {
+	t     *testing.T
+	l     net.Listener
+	files map[string]string
+
+	// legacy disables EPSV and MLSD, and stall stops downloads halfway.
+	legacy bool
+	stall  bool
+
+	mutex  sync.Mutex
+	logins int
+	conns  []net.Conn
+}
+
+func newTestFTPServer(t *testing.T, files map[string]string) *testFTPServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+
+	s := &testFTPServer{t: t, l: l, files: files}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			s.mutex.Lock()
+			s.conns = append(s.conns, conn)
+			s.mutex.Unlock()
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *testFTPServer) Addr() string {
+	return s.l.Addr().String()
+}
+
+func (s *testFTPServer) Logins() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.logins
+}
+
+// Disconnect closes all control connections.
+func (s *testFTPServer) Disconnect() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, c := range s.conns {
+		c.Close()
+	}
+	s.conns = nil
+}
+
+func (s *testFTPServer) Close() {
+	s.l.Close()
+	s.Disconnect()
+}
+
+func (s *testFTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 Welcome")
+
+	var user string
+	var data net.Listener
+	defer func() {
+		if data != nil {
+			data.Close()
+		}
+	}()
+
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		parts := strings.SplitN(line, " ", 2)
+		arg := ""
+		if len(parts) == 2 {
+			arg = parts[1]
+		}
+
+		switch strings.ToUpper(parts[0]) {
+		case "USER":
+			user = arg
+			text.PrintfLine("331 Password required")
+		case "PASS":
+			if user != "deploy" || arg != "secret" {
+				text.PrintfLine("530 Login incorrect")
+				continue
+			}
+			s.mutex.Lock()
+			s.logins++
+			s.mutex.Unlock()
+			text.PrintfLine("230 Logged in")
+		case "TYPE":
+			text.PrintfLine("200 Binary mode")
+		case "EPSV", "PASV":
+			if s.legacy && parts[0] == "EPSV" {
+				text.PrintfLine("502 Not implemented")
+				continue
+			}
+			data, err = net.Listen("tcp", "127.0.0.1:0")
+			require.Nil(s.t, err)
+			port := data.Addr().(*net.TCPAddr).Port
+			if parts[0] == "EPSV" {
+				text.PrintfLine("229 Entering Extended Passive Mode (|||%v|)", port)
+			} else {
+				text.PrintfLine("227 Entering Passive Mode (10,0,0,1,%v,%v)", port>>8, port&0xff)
+			}
+		case "MLSD", "LIST":
+			if s.legacy && parts[0] == "MLSD" {
+				text.PrintfLine("500 Unknown command")
+				continue
+			}
+			listing, ok := s.list(arg, parts[0] == "MLSD")
+			if !ok {
+				text.PrintfLine("550 No such directory")
+				continue
+			}
+			s.send(text, data, listing, false)
+		case "RETR":
+			contents, ok := s.files[arg]
+			if !ok {
+				text.PrintfLine("550 No such file")
+				continue
+			}
+			s.send(text, data, contents, s.stall)
+		case "QUIT":
+			text.PrintfLine("221 Bye")
+			return
+		default:
+			text.PrintfLine("500 Unknown command")
+		}
+	}
+}
+
+// list returns the listing of directory dir.
+func (s *testFTPServer) list(dir string, mlsd bool) (string, bool) {
+	entries := make(map[string]string)
+	for p, contents := range s.files {
+		if !strings.HasPrefix(p, dir+"/") {
+			continue
+		}
+		rest := strings.TrimPrefix(p, dir+"/")
+		if i := strings.Index(rest, "/"); i >= 0 {
+			entries[rest[:i]] = ""
+		} else if _, ok := entries[rest]; !ok {
+			entries[rest] = contents
+		}
+	}
+	if len(entries) == 0 {
+		return "", false
+	}
+
+	var names []string
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	buf := bytes.NewBuffer(nil)
+	if mlsd {
+		fmt.Fprintf(buf, "type=cdir; %v\r\n", dir)
+	} else {
+		fmt.Fprintf(buf, "total %v\r\n", len(names))
+	}
+	for _, name := range names {
+		isDir := strings.HasPrefix(name, "v") || name == "latest"
+		switch {
+		case mlsd && isDir:
+			fmt.Fprintf(buf, "type=dir;modify=20240102150405; %v\r\n", name)
+		case mlsd:
+			fmt.Fprintf(buf, "type=file;size=%v; %v\r\n", len(entries[name]), name)
+		case isDir:
+			fmt.Fprintf(buf, "drwxr-xr-x 2 ftp ftp 4096 Jan 02 15:04 %v\r\n", name)
+		default:
+			fmt.Fprintf(buf, "-rw-r--r-- 1 ftp ftp %v Jan 02 15:04 %v\r\n", len(entries[name]), path.Base(name))
+		}
+	}
+	return buf.String(), true
+}
+
+// send writes contents over the passive data connection.
+func (s *testFTPServer) send(text *textproto.Conn, data net.Listener, contents string, stall bool) {
+	if data == nil {
+		text.PrintfLine("425 Use PASV first")
+		return
+	}
+	text.PrintfLine("150 Opening data connection")
+	conn, err := data.Accept()
+	if err != nil {
+		return
+	}
+	defer data.Close()
+
+	w := bufio.NewWriter(conn)
+	if stall {
+		w.WriteString(contents[:len(contents)/2])
+		w.Flush()
+		time.Sleep(500 * time.Millisecond)
+	} else {
+		w.WriteString(contents)
+		w.Flush()
+	}
+	conn.Close()
+	text.PrintfLine("226 Transfer complete")
+}
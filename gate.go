@@ -0,0 +1,178 @@
+package updater
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Token that sets the oldest version that can update to a release in its
+// release notes, optionally with the release that older versions must update
+// to first, e.g. [requires: v1.0.0 via v1.0.5].
+const RequiresToken = "[requires: VERSION via VERSION]"
+
+var requiresTokenRegexp = regexp.MustCompile(`(?i)\[requires:?\s*([^\s\]]+)(?:\s+via\s+([^\s\]]+))?\s*\]`)
+
+// Maximum number of intermediate releases that are followed to find the
+// release to update to.
+const maxUpgradeSteps = 8
+
+// UpgradeRequirement returns the oldest version of the application that can
+// update to release, and the version name of the release that older versions
+// must update to first, if any. The minimum version is empty if all versions
+// can update to the release.
+//
+// Releases that implement GatedRelease decide for themselves. Other releases
+// take the requirement from a RequiresToken in their release notes.
+//
+// Check returns the release to update to first when the current version is
+// older than the minimum version, so that an application can migrate in
+// several steps. The current version is the current release identifier if it
+// is a version, or the name of the release with that identifier, which is
+// also remembered in the StateStore after an update.
+func UpgradeRequirement(release Release) (minimum, via string) {
+	if release == nil {
+		return "", ""
+	}
+
+	if g, ok := release.(GatedRelease); ok {
+		return g.MinimumVersion(), g.UpgradeVia()
+	}
+	return parseRequiresToken(release.Information())
+}
+
+// parseRequiresToken returns the versions of the RequiresToken in the release
+// notes.
+func parseRequiresToken(notes string) (minimum, via string) {
+	m := requiresTokenRegexp.FindStringSubmatch(notes)
+	if m == nil {
+		return "", ""
+	}
+	return m[1], m[2]
+}
+
+// upgradePath returns the release to update to on the way to r, which is r
+// itself unless the current version is older than its minimum version.
+//
+// The release to update to first is the release named by the requirement of
+// r, or the newest release that the current version can update to directly.
+// It is looked up among the releases of applications that implement
+// ReleasesApp. An *UnsupportedVersionError is returned if there is no such
+// release.
+func (u *Updater) upgradePath(r Release) (Release, error) {
+	for step := 0; ; step++ {
+		minimum, via := UpgradeRequirement(r)
+		if minimum == "" {
+			return r, nil
+		}
+		min, err := parseVersion(minimum)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid minimum version of %v: %v", r.Name(), err)
+		}
+
+		current, currentName, ok := u.currentVersion()
+		if !ok {
+			u.logf("Cannot check whether %v can update to %v", currentName, r.Name())
+			return r, nil
+		} else if current.compare(min) >= 0 {
+			return r, nil
+		}
+
+		unsupported := &UnsupportedVersionError{
+			Release:        r.Name(),
+			CurrentVersion: currentName,
+			MinimumVersion: minimum,
+		}
+		if step >= maxUpgradeSteps {
+			return nil, unsupported
+		}
+
+		var next Release
+		if via != "" {
+			next = u.findRelease(via)
+		} else {
+			next = u.newestReachable(current, r)
+		}
+		if next == nil {
+			return nil, unsupported
+		}
+		u.logf("Version %v must update to %v before %v", currentName, next.Name(), r.Name())
+		r = next
+	}
+}
+
+// currentVersion returns the version of the current release, and its name.
+//
+// The version is parsed from the current identifier, from the name of the
+// release with that identifier, or from the name of the last applied release
+// in the state if that is the current release.
+func (u *Updater) currentVersion() (version, string, bool) {
+	current := u.currentIdentifier()
+	if v, err := parseVersion(current); err == nil {
+		return v, current, true
+	}
+
+	if app, ok := u.App.(ReleasesApp); ok {
+		for _, r := range app.Releases() {
+			if r.Identifier() != current {
+				continue
+			}
+			if v, err := parseVersion(r.Name()); err == nil {
+				return v, r.Name(), true
+			}
+		}
+	}
+
+	if s := u.loadState(); s != nil && current != "" && s.LastApplied == current {
+		if v, err := parseVersion(s.LastAppliedName); err == nil {
+			return v, s.LastAppliedName, true
+		}
+	}
+	return version{}, current, false
+}
+
+// findRelease returns the release with the given version name or identifier,
+// or nil if the application does not have it.
+func (u *Updater) findRelease(name string) Release {
+	app, ok := u.App.(ReleasesApp)
+	if !ok {
+		return nil
+	}
+
+	for _, r := range app.Releases() {
+		if r.Name() == name || r.Identifier() == name {
+			return r
+		}
+	}
+	return nil
+}
+
+// newestReachable returns the newest release between current and target that
+// the current version can update to directly, or nil if there is none.
+func (u *Updater) newestReachable(current version, target Release) Release {
+	app, ok := u.App.(ReleasesApp)
+	if !ok {
+		return nil
+	}
+	targetVersion, err := parseVersion(target.Name())
+	if err != nil {
+		return nil
+	}
+
+	var best Release
+	var bestVersion version
+	for _, r := range app.Releases() {
+		v, err := parseVersion(r.Name())
+		if err != nil || v.compare(current) <= 0 || v.compare(targetVersion) >= 0 {
+			continue
+		}
+		if minimum, _ := UpgradeRequirement(r); minimum != "" {
+			if min, err := parseVersion(minimum); err != nil || current.compare(min) < 0 {
+				continue
+			}
+		}
+		if best == nil || v.compare(bestVersion) > 0 {
+			best, bestVersion = r, v
+		}
+	}
+	return best
+}
@@ -0,0 +1,97 @@
+package updater
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpgradeRequirement(t *testing.T) {
+	minimum, via := UpgradeRequirement(&testRelease{information: "Migrates the database. [requires: v2.0.0 via v2.3.1]"})
+	assert.Equal(t, "v2.0.0", minimum)
+	assert.Equal(t, "v2.3.1", via)
+
+	minimum, via = UpgradeRequirement(&testRelease{information: "[Requires v2.0.0]"})
+	assert.Equal(t, "v2.0.0", minimum)
+	assert.Equal(t, "", via)
+
+	minimum, via = UpgradeRequirement(&testRelease{information: "Bug fixes."})
+	assert.Equal(t, "", minimum)
+	assert.Equal(t, "", via)
+
+	minimum, via = UpgradeRequirement(&manifestRelease{Manifest: Manifest{MinimumVersion: "v1.0.0", UpgradeVia: "v1.5.0"}})
+	assert.Equal(t, "v1.0.0", minimum)
+	assert.Equal(t, "v1.5.0", via)
+}
+
+func TestUpdaterUpgradePath(t *testing.T) {
+	v3 := &testRelease{name: "v3.0.0", identifier: "c3", information: "[requires: v2.0.0 via v2.1.0]"}
+	v22 := &testRelease{name: "v2.2.0", identifier: "c22", information: "[requires: v1.5.0]"}
+	v21 := &testRelease{name: "v2.1.0", identifier: "c21", information: "[requires: v1.5.0]"}
+	v2 := &testRelease{name: "v2.0.0", identifier: "c2", information: "[requires: v1.5.0]"}
+	v15 := &testRelease{name: "v1.5.0", identifier: "c15"}
+	v1 := &testRelease{name: "v1.0.0", identifier: "c1"}
+
+	app := &testReleasesApp{releases: []Release{v3, v22, v21, v2, v15, v1}}
+	app.FLatestRelease = func() Release { return v3 }
+
+	// Current version can update directly
+	{
+		u := &Updater{App: app, CurrentReleaseIdentifier: "v2.0.0"}
+		r, err := u.Check()
+		assert.Nil(t, err)
+		assert.Equal(t, v3, r)
+	}
+
+	// Update via the named release first
+	{
+		u := &Updater{App: app, CurrentReleaseIdentifier: "c15"}
+		r, err := u.Check()
+		assert.Nil(t, err)
+		assert.Equal(t, v21, r)
+	}
+
+	// Update via the newest reachable release of the named release
+	{
+		u := &Updater{App: app, CurrentReleaseIdentifier: "v1.0.0"}
+		r, err := u.Check()
+		assert.Nil(t, err)
+		assert.Equal(t, v15, r)
+	}
+
+	// Newest reachable release
+	{
+		v3.information = "[requires: v2.0.0]"
+		u := &Updater{App: app, CurrentReleaseIdentifier: "c15"}
+		r, err := u.Check()
+		assert.Nil(t, err)
+		assert.Equal(t, v22, r)
+
+		u.CurrentReleaseIdentifier = "c22"
+		r, err = u.Check()
+		assert.Nil(t, err)
+		assert.Equal(t, v3, r)
+	}
+
+	// No release to update to first
+	{
+		only := &testApp{FLatestRelease: func() Release { return v3 }}
+		u := &Updater{App: only, CurrentReleaseIdentifier: "v1.0.0"}
+		r, err := u.Check()
+		assert.Nil(t, r)
+		var unsupported *UnsupportedVersionError
+		require.True(t, errors.As(err, &unsupported), "Unexpected error: %v", err)
+		assert.Equal(t, "v3.0.0", unsupported.Release)
+		assert.Equal(t, "v2.0.0", unsupported.MinimumVersion)
+	}
+
+	// Unknown current version
+	{
+		u := &Updater{App: app, CurrentReleaseIdentifier: "unknown"}
+		r, err := u.Check()
+		assert.Nil(t, err)
+		assert.Equal(t, v3, r)
+	}
+}
@@ -0,0 +1,65 @@
+// Package gcs fetches the releases of an application from a Google Cloud
+// Storage bucket:
+//
+//	client, err := storage.NewClient(ctx)
+//	if err != nil {
+//		return err
+//	}
+//	app := gcs.New(client.Bucket("releases"), "myapp/")
+//	u := &updater.Updater{App: app}
+//
+// See updater.NewObjectStore for the layout of the objects under the prefix.
+package gcs
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"cloud.google.com/go/storage"
+	updater "github.com/hverr/go-updater"
+	"google.golang.org/api/iterator"
+)
+
+type store struct {
+	bucket *storage.BucketHandle
+}
+
+// New creates an Application whose releases are stored in a Google Cloud
+// Storage bucket.
+//
+// Get the bucket from a client that is created with the credentials to read
+// it, e.g. client.Bucket("releases"). See updater.NewObjectStore for the
+// layout of the objects under prefix.
+func New(bucket *storage.BucketHandle, prefix string) updater.App {
+	return updater.NewObjectStore(&store{bucket: bucket}, prefix)
+}
+
+func (s *store) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	it := s.bucket.Objects(ctx, &storage.Query{Prefix: prefix})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		keys = append(keys, attrs.Name)
+	}
+
+	return keys, nil
+}
+
+func (s *store) Get(ctx context.Context, key string, w io.Writer) error {
+	r, err := s.bucket.Object(key).NewReader(ctx)
+	if err != nil {
+		return fmt.Errorf("Could not download gs://%v/%v: %v", s.bucket.BucketName(), key, err)
+	}
+	defer r.Close()
+
+	updater.SetTotalSize(w, r.Attrs.Size)
+
+	_, err = io.Copy(w, r)
+	return err
+}
@@ -0,0 +1,116 @@
+package gcs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/option"
+)
+
+func TestGCS(t *testing.T) {
+	objects := map[string]string{
+		"myapp/latest":                         "v1.0.0",
+		"myapp/releases/v1.0.0/myapp.exe":      "Hello Windows!",
+		"myapp/releases/v1.0.0/myapp_linux_64": "Hello Linux!",
+	}
+
+	// Serve the objects of the releases bucket with the JSON API, listed in
+	// pages of one object, and with the XML API for downloads
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serve := func(name string) {
+			data, ok := objects[name]
+			if !ok {
+				w.WriteHeader(404)
+				return
+			}
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			w.Write([]byte(data))
+		}
+
+		const bucket = "/b/releases/o"
+		i := strings.Index(r.URL.Path, bucket)
+		if i < 0 && strings.HasPrefix(r.URL.Path, "/releases/") {
+			serve(strings.TrimPrefix(r.URL.Path, "/releases/"))
+			return
+		} else if i < 0 {
+			w.WriteHeader(404)
+			return
+		}
+
+		name := strings.TrimPrefix(r.URL.Path[i+len(bucket):], "/")
+		if name != "" && r.URL.Query().Get("alt") == "media" {
+			serve(name)
+			return
+		} else if name != "" {
+			w.WriteHeader(404)
+			return
+		}
+
+		var keys []string
+		for k := range objects {
+			if strings.HasPrefix(k, r.URL.Query().Get("prefix")) {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		page, _ := strconv.Atoi(r.URL.Query().Get("pageToken"))
+		resp := map[string]interface{}{"kind": "storage#objects"}
+		if page < len(keys) {
+			resp["items"] = []map[string]string{{
+				"name":   keys[page],
+				"bucket": "releases",
+				"size":   strconv.Itoa(len(objects[keys[page]])),
+			}}
+		}
+		if page+1 < len(keys) {
+			resp["nextPageToken"] = strconv.Itoa(page + 1)
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer ts.Close()
+
+	client, err := storage.NewClient(
+		context.Background(),
+		option.WithEndpoint(ts.URL+"/storage/v1/"),
+		option.WithoutAuthentication(),
+	)
+	require.Nil(t, err)
+	defer client.Close()
+
+	// Valid bucket
+	{
+		app := New(client.Bucket("releases"), "myapp/")
+		err := app.Query()
+		assert.Nil(t, err, "Unexpected query error: %v", err)
+
+		r := app.LatestRelease()
+		require.NotNil(t, r)
+		assert.Equal(t, "v1.0.0", r.Name())
+		assert.Equal(t, 2, len(r.Assets()))
+
+		for _, a := range r.Assets() {
+			buf := bytes.NewBuffer(nil)
+			err := a.Write(buf)
+			assert.Nil(t, err)
+			assert.Equal(t, objects["myapp/releases/v1.0.0/"+a.Name()], buf.String())
+		}
+	}
+
+	// Invalid bucket
+	{
+		app := New(client.Bucket("other"), "myapp/")
+		err := app.Query()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "gs://other/myapp/latest")
+	}
+}
@@ -0,0 +1,303 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Number of releases requested per page from the Gitea API.
+const giteaReleasesPerPage = 50
+
+type giteaApp struct {
+	baseURL    string
+	owner      string
+	repository string
+	token      string
+	releases   []Release
+}
+
+type giteaRelease struct {
+	Release giteaReleaseJSON
+	Commit  string
+
+	assets []Asset
+}
+
+type giteaAsset struct {
+	Asset giteaAssetJSON
+
+	app *giteaApp
+}
+
+type giteaReleaseJSON struct {
+	ID         int64            `json:"id"`
+	TagName    string           `json:"tag_name"`
+	Name       string           `json:"name"`
+	Body       string           `json:"body"`
+	Draft      bool             `json:"draft"`
+	Prerelease bool             `json:"prerelease"`
+	Assets     []giteaAssetJSON `json:"assets"`
+}
+
+type giteaAssetJSON struct {
+	ID                 int64  `json:"id"`
+	Name               string `json:"name"`
+	Size               int64  `json:"size"`
+	BrowserDownloadURL string `json:"browser_download_url"`
+}
+
+type giteaTagJSON struct {
+	Name   string `json:"name"`
+	Commit struct {
+		SHA string `json:"sha"`
+	} `json:"commit"`
+}
+
+// NewGitea creates an Application that is hosted on a Gitea or Forgejo
+// instance, like Codeberg.
+//
+// The base URL is the URL of the instance, e.g. https://codeberg.org. Set
+// token to an access token to update from a private repository, or leave it
+// empty.
+func NewGitea(baseURL, owner, repository, token string) App {
+	return &giteaApp{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		owner:      owner,
+		repository: repository,
+		token:      token,
+	}
+}
+
+func (app *giteaApp) Query() error {
+	return app.QueryContext(context.Background())
+}
+
+func (app *giteaApp) QueryContext(ctx context.Context) error {
+	// Get all available releases
+	var releases []giteaReleaseJSON
+	for page := 1; ; page++ {
+		var s []giteaReleaseJSON
+		u := fmt.Sprintf(
+			"repos/%v/%v/releases?limit=%v&page=%v",
+			url.PathEscape(app.owner), url.PathEscape(app.repository),
+			giteaReleasesPerPage, page,
+		)
+		err := app.get(ctx, u, &s)
+		if err != nil {
+			return err
+		}
+
+		releases = append(releases, s...)
+		if len(s) < giteaReleasesPerPage {
+			break
+		}
+	}
+
+	s := make([]Release, len(releases))
+	for i, r := range releases {
+		s[i] = newGiteaRelease(app, r)
+	}
+	app.releases = s
+
+	// Get the commit sha for the releases
+	if len(s) == 1 {
+		e := s[0].(*giteaRelease).queryCommit(ctx, app)
+		if e != nil {
+			return e
+		}
+	} else if len(s) > 1 {
+		e := app.queryCommits(ctx)
+		if e != nil {
+			return e
+		}
+	}
+
+	return nil
+}
+
+func (app *giteaApp) LatestRelease() Release {
+	if len(app.releases) == 0 {
+		return nil
+	}
+
+	return app.releases[0]
+}
+
+func (app *giteaApp) Releases() []Release {
+	return app.releases
+}
+
+// queryCommits fetches the commits of all tags and assigns them to the
+// releases.
+func (app *giteaApp) queryCommits(ctx context.Context) error {
+	commits := make(map[string]string)
+	for page := 1; ; page++ {
+		var s []giteaTagJSON
+		u := fmt.Sprintf(
+			"repos/%v/%v/tags?limit=%v&page=%v",
+			url.PathEscape(app.owner), url.PathEscape(app.repository),
+			giteaReleasesPerPage, page,
+		)
+		err := app.get(ctx, u, &s)
+		if err != nil {
+			return err
+		}
+
+		for _, t := range s {
+			commits[t.Name] = t.Commit.SHA
+		}
+		if len(s) < giteaReleasesPerPage {
+			break
+		}
+	}
+
+	for _, r := range app.releases {
+		r := r.(*giteaRelease)
+		r.Commit = commits[r.Release.TagName]
+	}
+
+	// The latest release must have an identifier
+	if latest := app.releases[0]; latest.Identifier() == "" {
+		return fmt.Errorf("No commit found for release %v.", latest.Name())
+	}
+
+	return nil
+}
+
+// newRequest creates a GET request for the URL u, relative to the API root if
+// it is not absolute.
+//
+// The token is only sent to the Gitea instance itself.
+func (app *giteaApp) newRequest(u string) (*http.Request, error) {
+	if !strings.Contains(u, "://") {
+		u = app.baseURL + "/api/v1/" + u
+	}
+
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if app.token != "" && strings.HasPrefix(u, app.baseURL+"/") {
+		req.Header.Set("Authorization", "token "+app.token)
+	}
+	return req, nil
+}
+
+// get performs a GET request to the Gitea API and decodes the response in v.
+func (app *giteaApp) get(ctx context.Context, u string, v interface{}) error {
+	req, err := app.newRequest(u)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	buf := bytes.NewBuffer(nil)
+	err = downloadRequest(ctx, nil, req, buf)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(buf.Bytes(), v)
+}
+
+func newGiteaRelease(app *giteaApp, r giteaReleaseJSON) *giteaRelease {
+	s := make([]Asset, len(r.Assets))
+	for i, a := range r.Assets {
+		s[i] = &giteaAsset{Asset: a, app: app}
+	}
+
+	return &giteaRelease{
+		Release: r,
+		assets:  s,
+	}
+}
+
+func (r *giteaRelease) Name() string {
+	return r.Release.TagName
+}
+
+func (r *giteaRelease) Information() string {
+	return r.Release.Body
+}
+
+// InformationHTML renders the Markdown release notes to HTML.
+func (r *giteaRelease) InformationHTML() string {
+	return MarkdownHTML(r.Information())
+}
+
+// InformationText returns the release notes without Markdown markup.
+func (r *giteaRelease) InformationText() string {
+	return MarkdownText(r.Information())
+}
+
+func (r *giteaRelease) Identifier() string {
+	return r.Commit
+}
+
+func (r *giteaRelease) Assets() []Asset {
+	return r.assets
+}
+
+func (r *giteaRelease) queryCommit(ctx context.Context, app *giteaApp) error {
+	if r.Release.TagName == "" {
+		return errors.New("No tag name available.")
+	}
+
+	var tag giteaTagJSON
+	u := fmt.Sprintf(
+		"repos/%v/%v/tags/%v",
+		url.PathEscape(app.owner), url.PathEscape(app.repository),
+		url.PathEscape(r.Release.TagName),
+	)
+	err := app.get(ctx, u, &tag)
+	if err != nil {
+		return err
+	}
+
+	r.Commit = tag.Commit.SHA
+	return nil
+}
+
+func (r *giteaAsset) Name() string {
+	return r.Asset.Name
+}
+
+func (r *giteaAsset) Write(w io.Writer) error {
+	return r.WriteContext(context.Background(), w)
+}
+
+func (r *giteaAsset) WriteContext(ctx context.Context, w io.Writer) error {
+	req, err := r.request()
+	if err != nil {
+		return err
+	}
+
+	return downloadRequest(ctx, nil, req, w)
+}
+
+// Open requests the asset.
+func (r *giteaAsset) Open(ctx context.Context) (io.ReadCloser, int64, error) {
+	req, err := r.request()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return openRequest(ctx, nil, req)
+}
+
+// request returns the request that downloads the asset.
+func (r *giteaAsset) request() (*http.Request, error) {
+	if r.Asset.BrowserDownloadURL == "" {
+		return nil, errors.New("No download URL available.")
+	}
+
+	return r.app.newRequest(r.Asset.BrowserDownloadURL)
+}
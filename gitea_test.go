@@ -0,0 +1,169 @@
+package updater
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGiteaQuery(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "token secret", r.Header.Get("Authorization"))
+
+		switch r.URL.Path {
+		case "/api/v1/repos/hverr/reponame/releases":
+			assert.Equal(t, "1", r.URL.Query().Get("page"))
+			json := strings.Replace(validGiteaReleasesJSON, "BASE_URL", ts.URL, -1)
+			w.Write([]byte(json))
+		case "/api/v1/repos/hverr/reponame/tags/v1.0.0":
+			w.Write([]byte(validGiteaTagJSON))
+		case "/hverr/reponame/releases/download/v1.0.0/example.zip":
+			w.Write([]byte("Hello World!"))
+		default:
+			require.True(t, false, "Unexpected URL path: %v", r.URL.Path)
+		}
+	}))
+	defer ts.Close()
+
+	// Valid releases
+	{
+		app := NewGitea(ts.URL+"/", "hverr", "reponame", "secret")
+		assert.Nil(t, app.LatestRelease())
+
+		err := app.Query()
+		assert.Nil(t, err, "Unexpected query error: %v", err)
+
+		release := app.LatestRelease()
+		require.NotNil(t, release)
+		assert.Equal(t, "v1.0.0", release.Name())
+		assert.Equal(t, "Description of the release", release.Information())
+		assert.Equal(t, "aa218f56b14c9653891f9e74264a383fa43fefbd", release.Identifier())
+		require.Equal(t, 1, len(release.Assets()))
+		assert.Equal(t, "example.zip", release.Assets()[0].Name())
+
+		buf := bytes.NewBuffer(nil)
+		err = release.Assets()[0].Write(buf)
+		assert.Nil(t, err, "Unexpected write error: %v", err)
+		assert.Equal(t, "Hello World!", buf.String())
+	}
+
+	// Without download URL
+	{
+		a := &giteaAsset{}
+		err := a.Write(nil)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "No download URL")
+	}
+}
+
+func TestGiteaQueryHistory(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/repos/hverr/reponame/releases":
+			w.Write([]byte(`[{"tag_name": "v1.0.0"}, {"tag_name": "v0.9.0"}, {"tag_name": "v0.8.0"}]`))
+		case "/api/v1/repos/hverr/reponame/tags":
+			assert.Equal(t, "1", r.URL.Query().Get("page"))
+			w.Write([]byte(`[
+				{"name": "v1.0.0", "commit": {"sha": "aa218f56b14c9653891f9e74264a383fa43fefbd"}},
+				{"name": "v0.9.0", "commit": {"sha": "2e2b3ac3a89a5c55e3e3ec1dfe0b0d8e02a1fa25"}}
+			]`))
+		case "/api/v1/repos/hverr/missing/releases":
+			w.Write([]byte(`[{"tag_name": "v1.1.0"}, {"tag_name": "v1.0.0"}]`))
+		case "/api/v1/repos/hverr/missing/tags":
+			w.Write([]byte(`[{"name": "v1.0.0", "commit": {"sha": "aa218f56b14c9653891f9e74264a383fa43fefbd"}}]`))
+		default:
+			require.True(t, false, "Unexpected URL path: %v", r.URL.Path)
+		}
+	}))
+	defer ts.Close()
+
+	// Valid history
+	{
+		app := NewGitea(ts.URL, "hverr", "reponame", "").(ReleasesApp)
+		err := app.Query()
+		assert.Nil(t, err, "Unexpected query error: %v", err)
+
+		var identifiers []string
+		for _, r := range app.Releases() {
+			identifiers = append(identifiers, r.Identifier())
+		}
+		assert.Equal(t, []string{
+			"aa218f56b14c9653891f9e74264a383fa43fefbd",
+			"2e2b3ac3a89a5c55e3e3ec1dfe0b0d8e02a1fa25",
+			"",
+		}, identifiers)
+	}
+
+	// Latest release without tag
+	{
+		err := NewGitea(ts.URL, "hverr", "missing", "").Query()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "v1.1.0")
+	}
+}
+
+func TestGiteaQueryErrors(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/repos/hverr/reponame/releases" {
+			w.Write([]byte(`[{"tag_name": "v1.0.0"}]`))
+		} else if r.URL.Path == "/api/v1/repos/hverr/invalid/releases" {
+			w.Write([]byte("invalid json"))
+		} else {
+			w.WriteHeader(404)
+		}
+	}))
+	defer ts.Close()
+
+	// Invalid JSON
+	{
+		app := NewGitea(ts.URL, "hverr", "invalid", "")
+		assert.Error(t, app.Query())
+	}
+
+	// Missing tag
+	{
+		app := NewGitea(ts.URL, "hverr", "reponame", "")
+		err := app.Query()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "404")
+	}
+}
+
+var validGiteaReleasesJSON = `
+[
+  {
+    "id": 1,
+    "tag_name": "v1.0.0",
+    "target_commitish": "main",
+    "name": "v1.0.0",
+    "body": "Description of the release",
+    "draft": false,
+    "prerelease": false,
+    "assets": [
+      {
+        "id": 1,
+        "name": "example.zip",
+        "size": 12,
+        "download_count": 42,
+        "browser_download_url": "BASE_URL/hverr/reponame/releases/download/v1.0.0/example.zip"
+      }
+    ]
+  }
+]
+`
+
+var validGiteaTagJSON = `
+{
+  "name": "v1.0.0",
+  "id": "aa218f56b14c9653891f9e74264a383fa43fefbd",
+  "commit": {
+    "sha": "aa218f56b14c9653891f9e74264a383fa43fefbd"
+  }
+}
+`
@@ -1,38 +1,74 @@
 package updater
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/google/go-github/github"
 )
 
+// Number of releases requested per page from the GitHub API.
+const githubReleasesPerPage = 100
+
 type githubApp struct {
 	owner      string
 	repository string
 	client     *github.Client
-	releases   []Release
+	httpClient *http.Client
+
+	// mutex guards releases and validator, which are replaced by queries.
+	mutex    sync.Mutex
+	releases []Release
+
+	// Validator of the first page of releases of the last successful query.
+	validator cacheValidator
+
+	// Maximum time to wait for the rate limit to reset.
+	rateLimitWait time.Duration
+
+	// Function returning the identifier of a release, if the commit SHA is
+	// not used.
+	identifierFunc func(github.RepositoryRelease) string
+
+	// Function that sorts the releases, SortReleases if it is nil.
+	sorter ReleaseSorter
+
+	// References of the tags of the releases of the last query.
+	references *githubReferences
 }
 
 type githubRelease struct {
 	RepositoryRelease github.RepositoryRelease
 	Reference         *github.Reference
 
-	assets []Asset
+	assets         []Asset
+	identifierFunc func(github.RepositoryRelease) string
+	references     *githubReferences
 }
 
 type githubAsset struct {
 	Asset github.ReleaseAsset
+
+	app *githubApp
 }
 
 // NewGitHub creates an Application that is hosted on GitHub.
 //
-// Set client to nil to use the default one.
+// Set client to nil to use the default one, which sends requests with the
+// HTTPClient of the Updater. Use an authenticated client to update from a
+// private repository. Use NewGitHubWithOptions for GitHub
+// Enterprise Server.
 func NewGitHub(owner, repository string, client *github.Client) App {
 	if client == nil {
-		client = github.NewClient(nil)
+		client = github.NewClient(&http.Client{Transport: contextTransport{}})
 	}
 
 	return &githubApp{
@@ -43,47 +79,464 @@ func NewGitHub(owner, repository string, client *github.Client) App {
 	}
 }
 
+// GitHubOptions configures an Application hosted on GitHub or GitHub
+// Enterprise Server.
+type GitHubOptions struct {
+	// Client used to talk to the API. Set it to use a preconfigured client,
+	// e.g. one created for GitHub Enterprise. It cannot be combined with
+	// BaseURL, UploadURL or HTTPClient.
+	Client *github.Client
+
+	// URL of the GitHub Enterprise Server instance, e.g.
+	// https://github.example.com/. The API path api/v3/ is added if it is
+	// missing. Defaults to GitHub.com.
+	BaseURL string
+
+	// Upload URL of the GitHub Enterprise Server instance. Defaults to the
+	// api/uploads/ path of the instance.
+	UploadURL string
+
+	// HTTP client used to talk to the API and download assets, e.g. one that
+	// authenticates requests. Defaults to the transport of the HTTPClient of
+	// the Updater, or the default transport.
+	HTTPClient *http.Client
+
+	// Use the tags of the repository as releases instead of GitHub releases,
+	// see NewGitHubTags.
+	Tags bool
+
+	// Maximum time to wait for the rate limit of the API to reset.
+	//
+	// When a query exceeds the rate limit, a *RateLimitError with the time at
+	// which the limit resets is returned. If the limit resets within this
+	// time, the query waits and tries again instead. By default, queries do
+	// not wait.
+	RateLimitWait time.Duration
+
+	// Function returning the identifier of a release, e.g. GitHubTagName to
+	// compare releases with a version baked into the binary at build time.
+	//
+	// By default, the identifier is the SHA of the tagged commit, which
+	// needs extra API requests to look up the tags. They are skipped when
+	// IdentifierFunc is set. It is not used for Tags.
+	IdentifierFunc func(github.RepositoryRelease) string
+
+	// Options of the signed manifests attached to releases. When set, the
+	// latest release is described by its signed manifest asset instead of
+	// the GitHub release, see NewReleaseManifest. It cannot be combined with
+	// Tags.
+	Manifest *SignedManifestOptions
+
+	// Function that sorts the releases after every query, the newest first.
+	// Defaults to SortReleases, which sorts them by the semantic versions of
+	// their tag names and the time they were published.
+	ReleaseSorter ReleaseSorter
+}
+
+// GitHubTagName returns the tag name of a GitHub release, e.g. v1.2.3. Use it
+// as the IdentifierFunc of GitHubOptions.
+func GitHubTagName(r github.RepositoryRelease) string {
+	if s := r.TagName; s != nil {
+		return *s
+	}
+	return ""
+}
+
+// NewGitHubWithOptions creates an Application that is hosted on GitHub or
+// GitHub Enterprise Server.
+//
+// Assets are downloaded from the same host as the API, so they are also
+// available when the instance is not reachable from the public internet.
+func NewGitHubWithOptions(owner, repository string, opts GitHubOptions) (App, error) {
+	if opts.Manifest != nil && opts.Tags {
+		return nil, errors.New("Signed manifests cannot be combined with tags.")
+	}
+
+	client := opts.Client
+	if client != nil {
+		if opts.BaseURL != "" || opts.UploadURL != "" || opts.HTTPClient != nil {
+			return nil, errors.New("A GitHub client cannot be combined with other options.")
+		}
+	} else if opts.HTTPClient != nil {
+		client = github.NewClient(opts.HTTPClient)
+	} else {
+		client = github.NewClient(&http.Client{Transport: contextTransport{}})
+	}
+
+	if opts.BaseURL != "" {
+		u, err := enterpriseURL(opts.BaseURL, "api/v3/")
+		if err != nil {
+			return nil, err
+		}
+		client.BaseURL = u
+
+		if opts.UploadURL == "" {
+			client.UploadURL, _ = enterpriseURL(opts.BaseURL, "api/uploads/")
+		}
+	}
+
+	if opts.UploadURL != "" {
+		u, err := enterpriseURL(opts.UploadURL, "api/uploads/")
+		if err != nil {
+			return nil, err
+		}
+		client.UploadURL = u
+	}
+
+	app := &githubApp{
+		owner:      owner,
+		repository: repository,
+
+		client:         client,
+		httpClient:     opts.HTTPClient,
+		rateLimitWait:  opts.RateLimitWait,
+		identifierFunc: opts.IdentifierFunc,
+		sorter:         opts.ReleaseSorter,
+	}
+	if opts.Manifest != nil {
+		return NewReleaseManifest(app, *opts.Manifest), nil
+	}
+	if opts.Tags {
+		return &githubTagsApp{app}, nil
+	}
+	return app, nil
+}
+
+// enterpriseURL parses the URL of a GitHub Enterprise Server instance, and
+// replaces its API path, if any, with path.
+func enterpriseURL(s, path string) (*url.URL, error) {
+	if !strings.HasSuffix(s, "/") {
+		s += "/"
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid GitHub URL %v: %v", s, err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("Invalid GitHub URL %v: missing scheme or host", s)
+	}
+
+	for _, suffix := range []string{"api/v3/", "api/uploads/", "api/"} {
+		if strings.HasSuffix(u.Path, "/"+suffix) {
+			u.Path = strings.TrimSuffix(u.Path, suffix)
+			break
+		}
+	}
+	u.Path += path
+	return u, nil
+}
+
 func (app *githubApp) Query() error {
+	return app.QueryContext(context.Background())
+}
+
+// QueryContext queries the releases.
+//
+// Repeated queries are conditional requests, which return quickly and do not
+// count against the rate limit of GitHub when the releases did not change.
+//
+// Only the tag of the latest release is looked up to find its identifier. The
+// tags of the other releases are looked up all at once, the first time the
+// identifier of one of them is needed. This lookup uses the context of the
+// last query, and is tried again if it fails.
+func (app *githubApp) QueryContext(ctx context.Context) error {
 	// Get all available releases
-	releases, _, err := app.client.Repositories.ListReleases(app.owner, app.repository, nil)
-	if err != nil {
+	app.mutex.Lock()
+	previous := app.validator
+	if app.releases == nil {
+		previous = cacheValidator{}
+	}
+	app.mutex.Unlock()
+
+	releases, validator, err := app.listReleases(ctx, previous)
+	if err == errNotModified {
+		app.mutex.Lock()
+		if app.references != nil {
+			app.references.setContext(ctx)
+		}
+		app.mutex.Unlock()
+		return nil
+	} else if err != nil {
 		return err
 	}
 
 	s := make([]Release, len(releases))
 	for i, r := range releases {
-		s[i] = newGithubRelease(r)
+		s[i] = newGithubRelease(app, r)
+	}
+	if app.sorter != nil {
+		app.sorter(s)
+	} else {
+		SortReleases(s)
 	}
+	app.mutex.Lock()
 	app.releases = s
+	app.validator = cacheValidator{}
+	app.mutex.Unlock()
 
-	// Get the commit sha for the latest release
-	if len(s) != 0 {
-		e := s[0].(*githubRelease).queryReference(app)
+	// Get the commit sha of the latest release, unless the releases have
+	// another identifier. The other releases get theirs when needed.
+	if app.identifierFunc != nil {
+		if len(s) > 0 && s[0].Identifier() == "" {
+			return fmt.Errorf("No identifier for release %v.", s[0].Name())
+		}
+	} else if len(s) > 0 {
+		e := s[0].(*githubRelease).queryReference(ctx, app)
 		if e != nil {
 			return e
 		}
+
+		refs := &githubReferences{app: app, ctx: ctx}
+		for _, r := range s[1:] {
+			r.(*githubRelease).references = refs
+		}
+		app.mutex.Lock()
+		app.references = refs
+		app.mutex.Unlock()
 	}
 
+	app.mutex.Lock()
+	app.validator = validator
+	app.mutex.Unlock()
 	return nil
 }
 
 func (app *githubApp) LatestRelease() Release {
-	if app.releases == nil {
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	if len(app.releases) == 0 {
 		return nil
 	}
 
 	return app.releases[0]
 }
 
-func newGithubRelease(r github.RepositoryRelease) *githubRelease {
+func (app *githubApp) Releases() []Release {
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	return app.releases
+}
+
+// ReleaseByName returns the release with the given tag name.
+//
+// Releases of the last query are returned as is. Other releases are looked up
+// by their tag, so they can be found without querying all releases first.
+func (app *githubApp) ReleaseByName(name string) (Release, error) {
+	return app.ReleaseByNameContext(context.Background(), name)
+}
+
+// ReleaseByNameContext is like ReleaseByName but aborts when ctx is
+// cancelled.
+func (app *githubApp) ReleaseByNameContext(ctx context.Context, name string) (Release, error) {
+	for _, r := range app.Releases() {
+		if r.Name() == name {
+			return r, nil
+		}
+	}
+
+	var release github.RepositoryRelease
+	u := fmt.Sprintf(
+		"repos/%v/%v/releases/tags/%v",
+		app.owner, app.repository, url.PathEscape(name),
+	)
+	_, err := app.get(ctx, u, &release)
+	var githubErr *github.ErrorResponse
+	if errors.As(err, &githubErr) && githubErr.Response != nil && githubErr.Response.StatusCode == http.StatusNotFound {
+		return nil, ErrReleaseNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	r := newGithubRelease(app, release)
+	if app.identifierFunc == nil {
+		err = r.queryReference(ctx, app)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// githubReferences looks up the references of all tags at once, the first
+// time one of them is needed.
+type githubReferences struct {
+	app *githubApp
+
+	mutex sync.Mutex
+	ctx   context.Context
+	refs  map[string]*github.Reference
+}
+
+// setContext sets the context of the lookup, e.g. of a query that found that
+// the releases did not change.
+func (g *githubReferences) setContext(ctx context.Context) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.ctx = ctx
+}
+
+// get returns the reference of the tag with the given name, or nil if it
+// does not exist or the references could not be fetched. A failed lookup is
+// logged and tried again the next time.
+func (g *githubReferences) get(tag string) *github.Reference {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if g.refs == nil {
+		refs, err := g.app.listReferences(g.ctx)
+		if err != nil {
+			logf(g.ctx, "Could not look up the tags of %v/%v: %v", g.app.owner, g.app.repository, err)
+			return nil
+		}
+		g.refs = refs
+	}
+	return g.refs[tag]
+}
+
+// listReferences fetches the references of all tags, by tag name.
+func (app *githubApp) listReferences(ctx context.Context) (map[string]*github.Reference, error) {
+	refs := make(map[string]*github.Reference)
+	for page := 1; page != 0; {
+		var s []*github.Reference
+		u := fmt.Sprintf(
+			"repos/%v/%v/git/refs/tags?per_page=%v&page=%v",
+			app.owner, app.repository, githubReleasesPerPage, page,
+		)
+		resp, err := app.get(ctx, u, &s)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, ref := range s {
+			if ref != nil && ref.Ref != nil {
+				refs[strings.TrimPrefix(*ref.Ref, "refs/tags/")] = ref
+			}
+		}
+		page = resp.NextPage
+	}
+	return refs, nil
+}
+
+// listReleases fetches all pages of releases, in the order returned by GitHub.
+//
+// The first page is requested conditionally with the validator of the previous
+// query. If it did not change, errNotModified is returned.
+func (app *githubApp) listReleases(ctx context.Context, previous cacheValidator) ([]github.RepositoryRelease, cacheValidator, error) {
+	var all []github.RepositoryRelease
+	var validator cacheValidator
+	for page := 1; page != 0; {
+		var releases []github.RepositoryRelease
+		u := fmt.Sprintf(
+			"repos/%v/%v/releases?per_page=%v&page=%v",
+			app.owner, app.repository, githubReleasesPerPage, page,
+		)
+		req, err := app.client.NewRequest("GET", u, nil)
+		if err != nil {
+			return nil, validator, err
+		}
+		if page == 1 {
+			previous.apply(req)
+		}
+
+		resp, err := app.do(ctx, req, &releases)
+		if resp != nil && resp.StatusCode == http.StatusNotModified {
+			return nil, validator, errNotModified
+		} else if err != nil {
+			return nil, validator, err
+		}
+		if page == 1 {
+			validator = newCacheValidator(resp.Response)
+		}
+
+		all = append(all, releases...)
+		page = resp.NextPage
+	}
+
+	return all, validator, nil
+}
+
+// get performs a GET request to the GitHub API and decodes the response in v.
+func (app *githubApp) get(ctx context.Context, u string, v interface{}) (*github.Response, error) {
+	req, err := app.client.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return app.do(ctx, req, v)
+}
+
+// do performs req with the GitHub client and decodes the response in v.
+//
+// When the rate limit is exceeded, it waits for the limit to reset and tries
+// again once, if the limit resets soon enough. Otherwise a *RateLimitError is
+// returned.
+func (app *githubApp) do(ctx context.Context, req *http.Request, v interface{}) (*github.Response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := app.client.Do(req.WithContext(ctx), v)
+		reset, limited := githubRateLimitReset(err)
+		if !limited {
+			return resp, err
+		}
+
+		wait := time.Until(reset)
+		if attempt > 1 || reset.IsZero() || wait > app.rateLimitWait {
+			return resp, &RateLimitError{Reset: reset, Cause: err}
+		}
+
+		logf(ctx, "GitHub API rate limit exceeded, waiting %v for it to reset", wait)
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return resp, ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// githubRateLimitReset reports whether err is caused by an exceeded rate limit
+// of the GitHub API, and returns when the limit resets if it is known.
+func githubRateLimitReset(err error) (time.Time, bool) {
+	var rateErr *github.RateLimitError
+	if errors.As(err, &rateErr) {
+		return rateErr.Rate.Reset.Time, true
+	}
+
+	var githubErr *github.ErrorResponse
+	if !errors.As(err, &githubErr) || githubErr.Response == nil {
+		return time.Time{}, false
+	}
+	resp := githubErr.Response
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return time.Time{}, false
+	}
+
+	// Secondary rate limits ask to retry after a number of seconds
+	if n, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		return time.Now().Add(time.Duration(n) * time.Second), true
+	}
+
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		var reset time.Time
+		if n, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			reset = time.Unix(n, 0)
+		}
+		return reset, true
+	}
+
+	return time.Time{}, false
+}
+
+func newGithubRelease(app *githubApp, r github.RepositoryRelease) *githubRelease {
 	s := make([]Asset, len(r.Assets))
 	for i, a := range r.Assets {
-		s[i] = &githubAsset{a}
+		s[i] = &githubAsset{Asset: a, app: app}
 	}
 
 	return &githubRelease{
 		RepositoryRelease: r,
 		assets:            s,
+		identifierFunc:    app.identifierFunc,
 	}
 }
 
@@ -101,25 +554,71 @@ func (r *githubRelease) Information() string {
 	return ""
 }
 
+// InformationHTML renders the Markdown release notes to HTML.
+func (r *githubRelease) InformationHTML() string {
+	return MarkdownHTML(r.Information())
+}
+
+// InformationText returns the release notes without Markdown markup.
+func (r *githubRelease) InformationText() string {
+	return MarkdownText(r.Information())
+}
+
 func (r *githubRelease) Identifier() string {
-	if r.Reference == nil || r.Reference.Object == nil || r.Reference.Object.SHA == nil {
+	if r.identifierFunc != nil {
+		return r.identifierFunc(r.RepositoryRelease)
+	}
+
+	ref := r.Reference
+	if ref == nil && r.references != nil && r.RepositoryRelease.TagName != nil {
+		ref = r.references.get(*r.RepositoryRelease.TagName)
+	}
+	if ref == nil || ref.Object == nil || ref.Object.SHA == nil {
 		return ""
 	}
-	return *r.Reference.Object.SHA
+	return *ref.Object.SHA
 }
 
 func (r *githubRelease) Assets() []Asset {
 	return r.assets
 }
 
-func (r *githubRelease) queryReference(app *githubApp) error {
+func (r *githubRelease) PublishedAt() time.Time {
+	if t := r.RepositoryRelease.PublishedAt; t != nil {
+		return t.Time
+	}
+	return time.Time{}
+}
+
+func (r *githubRelease) URL() string {
+	if s := r.RepositoryRelease.HTMLURL; s != nil {
+		return *s
+	}
+	return ""
+}
+
+func (r *githubRelease) Prerelease() bool {
+	if b := r.RepositoryRelease.Prerelease; b != nil {
+		return *b
+	}
+	return false
+}
+
+func (r *githubRelease) queryReference(ctx context.Context, app *githubApp) error {
 	if r.RepositoryRelease.TagName == nil {
 		return errors.New("No tag name available.")
 	}
 
-	tag := "tags/" + *r.RepositoryRelease.TagName
-	ref, _, err := app.client.Git.GetRef(app.owner, app.repository, tag)
-	if err != nil {
+	ref := new(github.Reference)
+	u := fmt.Sprintf(
+		"repos/%v/%v/git/refs/tags/%v",
+		app.owner, app.repository, url.PathEscape(*r.RepositoryRelease.TagName),
+	)
+	_, err := app.get(ctx, u, ref)
+	var githubErr *github.ErrorResponse
+	if errors.As(err, &githubErr) && githubErr.Response != nil && githubErr.Response.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("No reference found for release %v.", r.Name())
+	} else if err != nil {
 		return err
 	}
 
@@ -134,22 +633,119 @@ func (r *githubAsset) Name() string {
 	return ""
 }
 
+func (r *githubAsset) Size() int64 {
+	if n := r.Asset.Size; n != nil {
+		return int64(*n)
+	}
+	return -1
+}
+
+func (r *githubAsset) ContentType() string {
+	if s := r.Asset.ContentType; s != nil {
+		return *s
+	}
+	return ""
+}
+
+func (r *githubAsset) DownloadCount() int {
+	if n := r.Asset.DownloadCount; n != nil {
+		return *n
+	}
+	return -1
+}
+
 func (r *githubAsset) Write(w io.Writer) error {
+	return r.WriteContext(context.Background(), w)
+}
+
+// WriteContext downloads the asset.
+//
+// Assets of a queried release are downloaded through the GitHub API with the
+// client of the application, so that authenticated clients can download assets
+// of private repositories. Other assets are downloaded from their browser
+// download URL.
+func (r *githubAsset) WriteContext(ctx context.Context, w io.Writer) error {
+	if r.app != nil && r.Asset.ID != nil {
+		return r.downloadFromAPI(ctx, w)
+	}
+
 	if r.Asset.BrowserDownloadURL == nil {
 		return errors.New("No download URL available.")
 	}
 
-	resp, err := http.Get(*r.Asset.BrowserDownloadURL)
+	var client *http.Client
+	if r.app != nil {
+		client = r.app.httpClient
+	}
+	return download(ctx, client, *r.Asset.BrowserDownloadURL, w)
+}
+
+func (r *githubAsset) downloadFromAPI(ctx context.Context, w io.Writer) error {
+	u := fmt.Sprintf(
+		"repos/%v/%v/releases/assets/%v",
+		r.app.owner, r.app.repository, *r.Asset.ID,
+	)
+	req, err := r.app.client.NewRequest("GET", u, nil)
 	if err != nil {
 		return err
-	} else if resp.StatusCode != http.StatusOK {
+	}
+	req.Header.Set("Accept", "application/octet-stream")
+
+	if t, ok := w.(totalSetter); ok && r.Asset.Size != nil {
+		t.setTotal(int64(*r.Asset.Size))
+	}
+
+	// The client does not report errors while copying the body, so keep
+	// track of them ourselves.
+	cw := &countingWriter{w: w}
+	logf(ctx, "GET %v", redactURL(req.URL))
+	_, err = r.app.client.Do(req.WithContext(ctx), cw)
+	logf(ctx, "Downloaded %v bytes from %v", cw.n, redactURL(req.URL))
+	if err != nil {
+		return githubDownloadError(req, err)
+	} else if cw.err != nil {
+		return cw.err
+	} else if r.Asset.Size != nil && cw.n != int64(*r.Asset.Size) {
 		return fmt.Errorf(
-			"Could not download %v: %v",
-			*r.Asset.BrowserDownloadURL, resp.Status,
+			"Incomplete download of %v: got %v of %v bytes",
+			r.Name(), cw.n, *r.Asset.Size,
 		)
 	}
-	defer resp.Body.Close()
 
-	_, err = io.Copy(w, resp.Body)
-	return err
+	return nil
+}
+
+// githubDownloadError returns a *DownloadError for an error response of the
+// GitHub API to req, or err itself for other errors.
+func githubDownloadError(req *http.Request, err error) error {
+	var githubErr *github.ErrorResponse
+	if !errors.As(err, &githubErr) || githubErr.Response == nil {
+		return err
+	}
+
+	return &DownloadError{
+		URL:        redactURL(req.URL),
+		StatusCode: githubErr.Response.StatusCode,
+		Status:     githubErr.Response.Status,
+		Header:     githubErr.Response.Header,
+		Body:       githubErr.Message,
+	}
+}
+
+// countingWriter counts the bytes written and remembers the first error.
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	c.err = err
+	return n, err
 }
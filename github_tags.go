@@ -0,0 +1,198 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+// githubTagsApp is a GitHub application whose releases are the tags of the
+// repository.
+type githubTagsApp struct {
+	*githubApp
+}
+
+type githubTagRelease struct {
+	Tag github.RepositoryTag
+
+	version version
+	assets  []Asset
+}
+
+// githubArchiveAsset is a source archive of a tag, generated by GitHub.
+type githubArchiveAsset struct {
+	name   string
+	tag    string
+	format string
+
+	app *githubApp
+}
+
+// NewGitHubTags creates an Application that is hosted on GitHub, for
+// repositories that only push tags and do not publish GitHub releases.
+//
+// Every tag that is a semantic version, e.g. v1.2.3, is a release. Other tags
+// are ignored. Releases are ordered by version, the highest first, and the
+// latest release is the highest version that is not a pre-release. The
+// identifier of a release is the SHA of the tagged commit.
+//
+// The assets of a release are the source archives generated by GitHub, named
+// after the repository and the version, e.g. myapp-1.2.3.tar.gz and
+// myapp-1.2.3.zip.
+//
+// Set client to nil to use the default one. Use an authenticated client to
+// update from a private repository. Set Tags of GitHubOptions to use tags with
+// NewGitHubWithOptions.
+func NewGitHubTags(owner, repository string, client *github.Client) App {
+	return &githubTagsApp{NewGitHub(owner, repository, client).(*githubApp)}
+}
+
+func (app *githubTagsApp) Query() error {
+	return app.QueryContext(context.Background())
+}
+
+// QueryContext queries the tags.
+func (app *githubTagsApp) QueryContext(ctx context.Context) error {
+	var releases []*githubTagRelease
+	for page := 1; page != 0; {
+		var tags []github.RepositoryTag
+		u := fmt.Sprintf(
+			"repos/%v/%v/tags?per_page=%v&page=%v",
+			app.owner, app.repository, githubReleasesPerPage, page,
+		)
+		resp, err := app.get(ctx, u, &tags)
+		if err != nil {
+			return err
+		}
+
+		for _, tag := range tags {
+			if r := newGithubTagRelease(app.githubApp, tag); r != nil {
+				releases = append(releases, r)
+			}
+		}
+		page = resp.NextPage
+	}
+
+	sort.SliceStable(releases, func(i, j int) bool {
+		return releases[i].version.compare(releases[j].version) > 0
+	})
+
+	s := make([]Release, len(releases))
+	for i, r := range releases {
+		s[i] = r
+	}
+	app.mutex.Lock()
+	app.releases = s
+	app.mutex.Unlock()
+	return nil
+}
+
+func (app *githubTagsApp) LatestRelease() Release {
+	for _, r := range app.Releases() {
+		if !r.(*githubTagRelease).Prerelease() {
+			return r
+		}
+	}
+	return nil
+}
+
+// ReleaseByName returns the release of the tag with the given name from the
+// last query.
+func (app *githubTagsApp) ReleaseByName(name string) (Release, error) {
+	return app.ReleaseByNameContext(context.Background(), name)
+}
+
+// ReleaseByNameContext is like ReleaseByName. The releases of the last query
+// are searched, so ctx is not used.
+func (app *githubTagsApp) ReleaseByNameContext(ctx context.Context, name string) (Release, error) {
+	for _, r := range app.Releases() {
+		if r.Name() == name {
+			return r, nil
+		}
+	}
+	return nil, ErrReleaseNotFound
+}
+
+// newGithubTagRelease returns the release of tag, or nil if the tag is not a
+// semantic version.
+func newGithubTagRelease(app *githubApp, tag github.RepositoryTag) *githubTagRelease {
+	if tag.Name == nil {
+		return nil
+	}
+	v, err := parseVersion(*tag.Name)
+	if err != nil {
+		return nil
+	}
+
+	r := &githubTagRelease{Tag: tag, version: v}
+	prefix := app.repository + "-" + strings.TrimPrefix(*tag.Name, "v")
+	r.assets = []Asset{
+		&githubArchiveAsset{name: prefix + ".tar.gz", tag: *tag.Name, format: "tarball", app: app},
+		&githubArchiveAsset{name: prefix + ".zip", tag: *tag.Name, format: "zipball", app: app},
+	}
+	return r
+}
+
+func (r *githubTagRelease) Name() string {
+	if s := r.Tag.Name; s != nil {
+		return *s
+	}
+	return ""
+}
+
+func (r *githubTagRelease) Information() string {
+	return ""
+}
+
+func (r *githubTagRelease) Identifier() string {
+	if r.Tag.Commit == nil || r.Tag.Commit.SHA == nil {
+		return ""
+	}
+	return *r.Tag.Commit.SHA
+}
+
+func (r *githubTagRelease) Assets() []Asset {
+	return r.assets
+}
+
+// Prerelease returns true if the tag is a pre-release version, e.g.
+// v1.2.3-beta.1.
+func (r *githubTagRelease) Prerelease() bool {
+	return len(r.version.pre) > 0
+}
+
+func (r *githubArchiveAsset) Name() string {
+	return r.name
+}
+
+func (r *githubArchiveAsset) Write(w io.Writer) error {
+	return r.WriteContext(context.Background(), w)
+}
+
+// WriteContext downloads the source archive through the GitHub API.
+func (r *githubArchiveAsset) WriteContext(ctx context.Context, w io.Writer) error {
+	u := fmt.Sprintf(
+		"repos/%v/%v/%v/%v",
+		r.app.owner, r.app.repository, r.format, url.PathEscape(r.tag),
+	)
+	req, err := r.app.client.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+
+	// The client does not report errors while copying the body, so keep
+	// track of them ourselves.
+	cw := &countingWriter{w: w}
+	logf(ctx, "GET %v", redactURL(req.URL))
+	_, err = r.app.client.Do(req.WithContext(ctx), cw)
+	logf(ctx, "Downloaded %v bytes from %v", cw.n, redactURL(req.URL))
+	if err != nil {
+		return githubDownloadError(req, err)
+	}
+	return cw.err
+}
@@ -0,0 +1,98 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const validTagsJSON = `[
+	{"name": "v1.2.0-beta.1", "commit": {"sha": "c3"}},
+	{"name": "latest", "commit": {"sha": "c4"}},
+	{"name": "v1.10.0", "commit": {"sha": "c2"}},
+	{"name": "v1.9.1", "commit": {"sha": "c1"}}
+]`
+
+func TestGitHubTagsQuery(t *testing.T) {
+	// With valid JSON
+	{
+		ts, cl := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/repos/hverr/reponame/tags":
+				strings.NewReader(validTagsJSON).WriteTo(w)
+			case "/repos/hverr/reponame/tarball/v1.10.0":
+				w.Write([]byte("tarball"))
+			case "/repos/hverr/reponame/zipball/v1.10.0":
+				w.Write([]byte("zipball"))
+			default:
+				require.True(t, false, "Unexpected URL path: %v", r.URL.Path)
+			}
+		})
+		defer ts.Close()
+
+		app := NewGitHubTags("hverr", "reponame", cl)
+		err := app.Query()
+		require.Nil(t, err, "Unexpected query error: %v", err)
+
+		var names []string
+		for _, r := range app.(ReleasesApp).Releases() {
+			names = append(names, r.Name())
+		}
+		assert.Equal(t, []string{"v1.10.0", "v1.9.1", "v1.2.0-beta.1"}, names)
+
+		release := app.LatestRelease()
+		require.NotNil(t, release)
+		assert.Equal(t, "v1.10.0", release.Name())
+		assert.Equal(t, "c2", release.Identifier())
+		require.Equal(t, 2, len(release.Assets()))
+
+		tarball, zipball := release.Assets()[0], release.Assets()[1]
+		assert.Equal(t, "reponame-1.10.0.tar.gz", tarball.Name())
+		assert.Equal(t, "reponame-1.10.0.zip", zipball.Name())
+
+		buf := bytes.NewBuffer(nil)
+		assert.Nil(t, tarball.Write(buf))
+		assert.Equal(t, "tarball", buf.String())
+		buf.Reset()
+		assert.Nil(t, zipball.Write(buf))
+		assert.Equal(t, "zipball", buf.String())
+
+		// Tags are not looked up as releases
+		_, err = app.(ContextNamedReleaseApp).ReleaseByNameContext(context.Background(), "v0.1.0")
+		assert.Equal(t, ErrReleaseNotFound, err)
+	}
+
+	// Only pre-releases
+	{
+		ts, cl := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`[{"name": "v2.0.0-rc.1", "commit": {"sha": "c1"}}]`))
+		})
+		defer ts.Close()
+
+		app, err := NewGitHubWithOptions("hverr", "reponame", GitHubOptions{
+			Client: cl,
+			Tags:   true,
+		})
+		require.Nil(t, err)
+		err = app.Query()
+		require.Nil(t, err, "Unexpected query error: %v", err)
+		assert.Nil(t, app.LatestRelease())
+		assert.Equal(t, 1, len(app.(ReleasesApp).Releases()))
+	}
+
+	// Invalid JSON response
+	{
+		ts, cl := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("invalid json"))
+		})
+		defer ts.Close()
+
+		app := NewGitHubTags("hverr", "reponame", cl)
+		assert.Error(t, app.Query())
+	}
+}
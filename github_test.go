@@ -2,11 +2,16 @@ package updater
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/google/go-github/github"
 	"github.com/stretchr/testify/assert"
@@ -90,6 +95,209 @@ func TestGitHubQuery(t *testing.T) {
 	}
 }
 
+func TestGitHubQueryPagination(t *testing.T) {
+	var listed, failing int32
+	ts, cl := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/repos/hverr/reponame/releases" {
+			assert.Equal(t, "100", r.URL.Query().Get("per_page"))
+			switch r.URL.Query().Get("page") {
+			case "1":
+				w.Header().Set("Link", `<http://localhost/repos/hverr/reponame/releases?per_page=100&page=2>; rel="next"`)
+				w.Write([]byte(`[{"tag_name": "v1.0.0"}, {"tag_name": "v0.9.0"}]`))
+			case "2":
+				w.Write([]byte(`[{"tag_name": "v0.8.0"}]`))
+			default:
+				require.True(t, false, "Unexpected page: %v", r.URL.RawQuery)
+			}
+		} else if r.URL.Path == "/repos/hverr/reponame/git/refs/tags/v1.0.0" {
+			strings.NewReader(validReferenceJSON).WriteTo(w)
+		} else if r.URL.Path == "/repos/hverr/reponame/git/refs/tags" {
+			atomic.AddInt32(&listed, 1)
+			if atomic.LoadInt32(&failing) != 0 {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			strings.NewReader(validReferencesJSON).WriteTo(w)
+		} else {
+			require.True(t, false, "Unexpected URL path: %v", r.URL.Path)
+		}
+	})
+	defer ts.Close()
+
+	app := NewGitHub("hverr", "reponame", cl).(*githubApp)
+	ctx, cancel := context.WithCancel(context.Background())
+	err := app.QueryContext(ctx)
+	assert.Nil(t, err, "Unexpected query error: %v", err)
+
+	// Only the latest release is resolved by the query
+	assert.Equal(t, "aa218f56b14c9653891f9e74264a383fa43fefbd", app.LatestRelease().Identifier())
+	assert.Equal(t, int32(0), atomic.LoadInt32(&listed))
+
+	// Lookups with the cancelled context of the query fail
+	cancel()
+	assert.Equal(t, "", app.Releases()[1].Identifier())
+	assert.Equal(t, int32(0), atomic.LoadInt32(&listed))
+	err = app.Query()
+	assert.Nil(t, err, "Unexpected query error: %v", err)
+
+	// Failed lookups are tried again
+	atomic.StoreInt32(&failing, 1)
+	assert.Equal(t, "", app.Releases()[1].Identifier())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&listed))
+	atomic.StoreInt32(&failing, 0)
+	atomic.StoreInt32(&listed, 0)
+
+	// The other releases are resolved together, once
+	var names, identifiers []string
+	for _, r := range app.Releases() {
+		names = append(names, r.Name())
+		identifiers = append(identifiers, r.Identifier())
+	}
+	assert.Equal(t, []string{"v1.0.0", "v0.9.0", "v0.8.0"}, names)
+	assert.Equal(t, []string{
+		"aa218f56b14c9653891f9e74264a383fa43fefbd",
+		"2e2b3ac3a89a5c55e3e3ec1dfe0b0d8e02a1fa25",
+		"",
+	}, identifiers)
+	assert.Equal(t, "2e2b3ac3a89a5c55e3e3ec1dfe0b0d8e02a1fa25", app.Releases()[1].Identifier())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&listed))
+}
+
+func TestGitHubQueryReferences(t *testing.T) {
+	// Latest release without reference
+	{
+		ts, cl := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/repos/hverr/reponame/releases" {
+				w.Write([]byte(`[{"tag_name": "v1.1.0"}, {"tag_name": "v1.0.0"}]`))
+			} else if r.URL.Path == "/repos/hverr/reponame/git/refs/tags/v1.1.0" {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"message": "Not Found"}`))
+			} else {
+				require.True(t, false, "Unexpected URL path: %v", r.URL.Path)
+			}
+		})
+		defer ts.Close()
+
+		err := NewGitHub("hverr", "reponame", cl).Query()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "v1.1.0")
+	}
+
+	// Tag names with special characters
+	{
+		ts, cl := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/repos/hverr/reponame/releases" {
+				w.Write([]byte(`[{"tag_name": "release/1.0#1?"}]`))
+			} else if r.URL.EscapedPath() == "/repos/hverr/reponame/git/refs/tags/release%2F1.0%231%3F" {
+				strings.NewReader(validReferenceJSON).WriteTo(w)
+			} else {
+				require.True(t, false, "Unexpected URL path: %v", r.URL.EscapedPath())
+			}
+		})
+		defer ts.Close()
+
+		app := NewGitHub("hverr", "reponame", cl)
+		err := app.Query()
+		require.Nil(t, err, "Unexpected query error: %v", err)
+		assert.Equal(t, "aa218f56b14c9653891f9e74264a383fa43fefbd", app.LatestRelease().Identifier())
+	}
+
+	// Invalid JSON
+	{
+		ts, cl := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/repos/hverr/reponame/releases" {
+				w.Write([]byte(`[{"tag_name": "v1.0.0"}, {"tag_name": "v0.9.0"}]`))
+			} else {
+				w.Write([]byte("invalid json"))
+			}
+		})
+		defer ts.Close()
+
+		err := NewGitHub("hverr", "reponame", cl).Query()
+		assert.Error(t, err)
+	}
+
+	// Other releases cannot be resolved
+	{
+		ts, cl := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/repos/hverr/reponame/releases" {
+				w.Write([]byte(`[{"tag_name": "v1.0.0"}, {"tag_name": "v0.9.0"}]`))
+			} else if r.URL.Path == "/repos/hverr/reponame/git/refs/tags/v1.0.0" {
+				strings.NewReader(validReferenceJSON).WriteTo(w)
+			} else {
+				w.Write([]byte("invalid json"))
+			}
+		})
+		defer ts.Close()
+
+		app := NewGitHub("hverr", "reponame", cl).(*githubApp)
+		err := app.Query()
+		assert.Nil(t, err, "Unexpected query error: %v", err)
+		assert.Equal(t, "", app.Releases()[1].Identifier())
+	}
+}
+
+func TestGitHubIdentifierFunc(t *testing.T) {
+	ts, cl := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/repos/hverr/reponame/releases" {
+			w.Write([]byte(`[{"tag_name": "v1.0.0", "name": "First"}, {"tag_name": "v0.9.0"}]`))
+		} else {
+			require.True(t, false, "Unexpected URL path: %v", r.URL.Path)
+		}
+	})
+	defer ts.Close()
+
+	// Tag names without looking up references
+	{
+		app, err := NewGitHubWithOptions("hverr", "reponame", GitHubOptions{
+			Client:         cl,
+			IdentifierFunc: GitHubTagName,
+		})
+		require.Nil(t, err)
+		err = app.Query()
+		require.Nil(t, err, "Unexpected query error: %v", err)
+
+		var identifiers []string
+		for _, r := range app.(ReleasesApp).Releases() {
+			identifiers = append(identifiers, r.Identifier())
+		}
+		assert.Equal(t, []string{"v1.0.0", "v0.9.0"}, identifiers)
+	}
+
+	// Latest release without identifier
+	{
+		app, err := NewGitHubWithOptions("hverr", "reponame", GitHubOptions{
+			Client: cl,
+			IdentifierFunc: func(r github.RepositoryRelease) string {
+				if r.Name != nil {
+					return ""
+				}
+				return GitHubTagName(r)
+			},
+		})
+		require.Nil(t, err)
+		err = app.Query()
+		if assert.NotNil(t, err) {
+			assert.Contains(t, err.Error(), "v1.0.0")
+		}
+	}
+}
+
+func TestGitHubQueryContext(t *testing.T) {
+	ts, cl := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		strings.NewReader(validReleasesJSON).WriteTo(w)
+	})
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	app := NewGitHub("hverr", "reponame", cl).(ContextApp)
+	err := app.QueryContext(ctx)
+	assert.Error(t, err)
+	assert.Nil(t, app.LatestRelease())
+}
+
 func TestGitHubLatestRelease(t *testing.T) {
 	// No information available
 	{
@@ -97,6 +305,14 @@ func TestGitHubLatestRelease(t *testing.T) {
 		assert.Nil(t, app.LatestRelease())
 	}
 
+	// No releases
+	{
+		app := githubApp{
+			releases: []Release{},
+		}
+		assert.Nil(t, app.LatestRelease())
+	}
+
 	// Valid releases
 	{
 		r := &githubRelease{}
@@ -131,6 +347,25 @@ func TestGitHubRelease(t *testing.T) {
 	assert.Equal(t, sha, r.Identifier())
 }
 
+func TestGitHubReleaseMeta(t *testing.T) {
+	var r ReleaseMeta = &githubRelease{}
+
+	assert.True(t, r.PublishedAt().IsZero())
+	assert.Equal(t, "", r.URL())
+	assert.False(t, r.Prerelease())
+
+	published := time.Date(2016, 5, 1, 12, 0, 0, 0, time.UTC)
+	htmlURL := "https://github.com/hverr/reponame/releases/tag/v1.0.1"
+	prerelease := true
+	r.(*githubRelease).RepositoryRelease.PublishedAt = &github.Timestamp{Time: published}
+	r.(*githubRelease).RepositoryRelease.HTMLURL = &htmlURL
+	r.(*githubRelease).RepositoryRelease.Prerelease = &prerelease
+
+	assert.Equal(t, published, r.PublishedAt())
+	assert.Equal(t, htmlURL, r.URL())
+	assert.True(t, r.Prerelease())
+}
+
 func TestQueryReference(t *testing.T) {
 	// With valid JSON
 	{
@@ -143,7 +378,7 @@ func TestQueryReference(t *testing.T) {
 		r := &githubRelease{}
 		tag := "v1.0.0"
 		r.RepositoryRelease.TagName = &tag
-		err := r.queryReference(app.(*githubApp))
+		err := r.queryReference(context.Background(), app.(*githubApp))
 
 		assert.Nil(t, err, "Unexpected query error: %v", err)
 		assert.NotNil(t, r.Reference)
@@ -153,7 +388,7 @@ func TestQueryReference(t *testing.T) {
 	// Without tag name
 	{
 		r := &githubRelease{}
-		err := r.queryReference(nil)
+		err := r.queryReference(context.Background(), nil)
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "No tag name")
 	}
@@ -169,11 +404,61 @@ func TestQueryReference(t *testing.T) {
 		r := &githubRelease{}
 		tag := "v1.0.0"
 		r.RepositoryRelease.TagName = &tag
-		err := r.queryReference(app.(*githubApp))
+		err := r.queryReference(context.Background(), app.(*githubApp))
 		assert.Error(t, err)
 	}
 }
 
+func TestGitHubReleaseByName(t *testing.T) {
+	ts, cl := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/repos/hverr/reponame/releases" {
+			w.Write([]byte(`[{"tag_name": "v1.0.0"}]`))
+		} else if r.URL.Path == "/repos/hverr/reponame/releases/tags/v0.9.0" {
+			w.Write([]byte(`{"tag_name": "v0.9.0", "assets": [{"name": "myapp"}]}`))
+		} else if strings.HasPrefix(r.URL.Path, "/repos/hverr/reponame/git/refs/tags/v") {
+			strings.NewReader(validReferenceJSON).WriteTo(w)
+		} else {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message": "Not Found"}`))
+		}
+	})
+	defer ts.Close()
+
+	app := NewGitHub("hverr", "reponame", cl).(*githubApp)
+	require.Nil(t, app.Query())
+
+	// Release of the last query
+	{
+		r, err := app.ReleaseByName("v1.0.0")
+		require.Nil(t, err, "Unexpected error: %v", err)
+		assert.Equal(t, app.LatestRelease(), r)
+	}
+
+	// Release looked up by tag
+	{
+		r, err := app.ReleaseByName("v0.9.0")
+		require.Nil(t, err, "Unexpected error: %v", err)
+		assert.Equal(t, "v0.9.0", r.Name())
+		assert.Equal(t, "aa218f56b14c9653891f9e74264a383fa43fefbd", r.Identifier())
+		require.Equal(t, 1, len(r.Assets()))
+		assert.Equal(t, "myapp", r.Assets()[0].Name())
+	}
+
+	// Unknown release
+	{
+		_, err := app.ReleaseByName("v0.1.0")
+		assert.Equal(t, ErrReleaseNotFound, err)
+	}
+
+	// Cancelled lookup
+	{
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := app.ReleaseByNameContext(ctx, "v0.9.0")
+		assert.True(t, errors.Is(err, context.Canceled), "Unexpected error: %v", err)
+	}
+}
+
 func TestGithubAsset(t *testing.T) {
 	a := &githubAsset{}
 
@@ -184,6 +469,25 @@ func TestGithubAsset(t *testing.T) {
 	assert.Equal(t, "assetname", a.Name())
 }
 
+func TestGithubAssetMeta(t *testing.T) {
+	var a AssetMeta = &githubAsset{}
+
+	assert.Equal(t, int64(-1), a.Size())
+	assert.Equal(t, "", a.ContentType())
+	assert.Equal(t, -1, a.DownloadCount())
+
+	size := 1024
+	contentType := "application/gzip"
+	downloads := 42
+	a.(*githubAsset).Asset.Size = &size
+	a.(*githubAsset).Asset.ContentType = &contentType
+	a.(*githubAsset).Asset.DownloadCount = &downloads
+
+	assert.Equal(t, int64(1024), a.Size())
+	assert.Equal(t, "application/gzip", a.ContentType())
+	assert.Equal(t, 42, a.DownloadCount())
+}
+
 func TestGithubAssetWrite(t *testing.T) {
 	// Valid contents
 	{
@@ -247,6 +551,70 @@ func TestGithubAssetWrite(t *testing.T) {
 
 }
 
+func TestGithubAssetWriteFromAPI(t *testing.T) {
+	newAsset := func(cl *github.Client, size int) *githubAsset {
+		app := NewGitHub("hverr", "reponame", cl).(*githubApp)
+		id := 1
+		a := &githubAsset{app: app}
+		a.Asset.ID = &id
+		a.Asset.Size = &size
+		return a
+	}
+
+	// Valid contents
+	{
+		ts, cl := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/repos/hverr/reponame/releases/assets/1", r.URL.Path)
+			assert.Equal(t, "application/octet-stream", r.Header.Get("Accept"))
+			w.Write([]byte("Hello World!"))
+		})
+		defer ts.Close()
+
+		buf := bytes.NewBuffer(nil)
+		err := newAsset(cl, 12).Write(buf)
+		assert.Nil(t, err, "Unexepected error %v:", err)
+		assert.Equal(t, "Hello World!", buf.String())
+	}
+
+	// Incomplete contents
+	{
+		ts, cl := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("Hello"))
+		})
+		defer ts.Close()
+
+		err := newAsset(cl, 12).Write(bytes.NewBuffer(nil))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "Incomplete download")
+	}
+
+	// Write error
+	{
+		ts, cl := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("Hello World!"))
+		})
+		defer ts.Close()
+
+		b := NewAbortBuffer(nil)
+		b.Abort()
+		err := newAsset(cl, 12).Write(b)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "abort")
+	}
+
+	// Not found
+	{
+		ts, cl := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(404)
+		})
+		defer ts.Close()
+
+		err := newAsset(cl, 12).Write(bytes.NewBuffer(nil))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "404")
+	}
+}
+
 var validReleasesJSON = `
 
 [
@@ -334,3 +702,214 @@ var validReferenceJSON = `
   }
 }
 `
+
+var validReferencesJSON = `
+[
+  {
+    "ref": "refs/tags/v1.0.0",
+    "object": {
+      "type": "commit",
+      "sha": "aa218f56b14c9653891f9e74264a383fa43fefbd"
+    }
+  },
+  {
+    "ref": "refs/tags/v0.9.0",
+    "object": {
+      "type": "commit",
+      "sha": "2e2b3ac3a89a5c55e3e3ec1dfe0b0d8e02a1fa25"
+    }
+  }
+]
+`
+
+func TestGitHubQueryConditional(t *testing.T) {
+	releaseRequests, referenceRequests := 0, 0
+	ts, cl := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/repos/hverr/reponame/releases" {
+			releaseRequests++
+			if r.Header.Get("If-None-Match") == `"abc"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"abc"`)
+			strings.NewReader(validReleasesJSON).WriteTo(w)
+		} else if r.URL.Path == "/repos/hverr/reponame/git/refs/tags/v1.0.0" {
+			referenceRequests++
+			strings.NewReader(validReferenceJSON).WriteTo(w)
+		} else {
+			require.True(t, false, "Unexpected URL path: %v", r.URL.Path)
+		}
+	})
+	defer ts.Close()
+
+	app := NewGitHub("hverr", "reponame", cl)
+	for i := 0; i < 2; i++ {
+		err := app.Query()
+		assert.Nil(t, err, "Unexpected query error: %v", err)
+
+		release := app.LatestRelease()
+		require.NotNil(t, release)
+		assert.Equal(t, "v1.0.0", release.Name())
+		assert.Equal(t, "aa218f56b14c9653891f9e74264a383fa43fefbd", release.Identifier())
+	}
+	assert.Equal(t, 2, releaseRequests)
+	assert.Equal(t, 1, referenceRequests)
+}
+
+func TestNewGitHubWithOptions(t *testing.T) {
+	// Enterprise URLs
+	{
+		for _, base := range []string{
+			"https://github.example.com",
+			"https://github.example.com/",
+			"https://github.example.com/api/",
+			"https://github.example.com/api/v3",
+		} {
+			app, err := NewGitHubWithOptions("hverr", "reponame", GitHubOptions{BaseURL: base})
+			require.Nil(t, err, "Unexpected error: %v", err)
+
+			client := app.(*githubApp).client
+			assert.Equal(t, "https://github.example.com/api/v3/", client.BaseURL.String())
+			assert.Equal(t, "https://github.example.com/api/uploads/", client.UploadURL.String())
+		}
+	}
+
+	// Invalid options
+	{
+		_, err := NewGitHubWithOptions("hverr", "reponame", GitHubOptions{BaseURL: "github.example.com"})
+		assert.Error(t, err)
+
+		_, err = NewGitHubWithOptions("hverr", "reponame", GitHubOptions{
+			Client:  github.NewClient(nil),
+			BaseURL: "https://github.example.com/",
+		})
+		assert.Error(t, err)
+	}
+
+	// Query and download from the enterprise host
+	{
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/api/v3/repos/hverr/reponame/releases":
+				strings.NewReader(validReleasesJSON).WriteTo(w)
+			case "/api/v3/repos/hverr/reponame/git/refs/tags/v1.0.0":
+				strings.NewReader(validReferenceJSON).WriteTo(w)
+			case "/api/v3/repos/hverr/reponame/releases/assets/1":
+				assert.Equal(t, "application/octet-stream", r.Header.Get("Accept"))
+				w.Write(bytes.Repeat([]byte("a"), 1024))
+			default:
+				require.True(t, false, "Unexpected URL path: %v", r.URL.Path)
+			}
+		}))
+		defer ts.Close()
+
+		app, err := NewGitHubWithOptions("hverr", "reponame", GitHubOptions{BaseURL: ts.URL})
+		require.Nil(t, err, "Unexpected error: %v", err)
+
+		err = app.Query()
+		require.Nil(t, err, "Unexpected query error: %v", err)
+
+		release := app.LatestRelease()
+		require.NotNil(t, release)
+		require.Equal(t, 1, len(release.Assets()))
+
+		buf := bytes.NewBuffer(nil)
+		err = release.Assets()[0].Write(buf)
+		assert.Nil(t, err, "Unexpected write error: %v", err)
+		assert.Equal(t, 1024, buf.Len())
+	}
+
+	// API requests use the HTTP client of the updater
+	{
+		var requests, authorized int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			if r.Header.Get("Authorization") == "token secret" {
+				atomic.AddInt32(&authorized, 1)
+			}
+			switch r.URL.Path {
+			case "/api/v3/repos/hverr/reponame/releases":
+				strings.NewReader(validReleasesJSON).WriteTo(w)
+			default:
+				strings.NewReader(validReferenceJSON).WriteTo(w)
+			}
+		}))
+		defer ts.Close()
+
+		app, err := NewGitHubWithOptions("hverr", "reponame", GitHubOptions{BaseURL: ts.URL})
+		require.Nil(t, err, "Unexpected error: %v", err)
+		u := &Updater{
+			App:        app,
+			HTTPClient: &http.Client{Transport: &testHeaderTransport{"Authorization", "token secret"}},
+		}
+
+		_, err = u.Check()
+		require.Nil(t, err, "Unexpected check error: %v", err)
+		assert.True(t, atomic.LoadInt32(&requests) > 0)
+		assert.Equal(t, atomic.LoadInt32(&requests), atomic.LoadInt32(&authorized))
+	}
+}
+
+// testHeaderTransport sets a header on every request.
+type testHeaderTransport struct {
+	name, value string
+}
+
+func (t *testHeaderTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set(t.name, t.value)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestGitHubRateLimit(t *testing.T) {
+	var requests int32
+	newApp := func(reset time.Time, wait time.Duration) (*httptest.Server, App) {
+		atomic.StoreInt32(&requests, 0)
+		ts, cl := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&requests, 1) == 1 {
+				w.Header().Set("X-RateLimit-Limit", "60")
+				w.Header().Set("X-RateLimit-Remaining", "0")
+				w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte(`{"message": "API rate limit exceeded for 127.0.0.1."}`))
+			} else if r.URL.Path == "/repos/hverr/reponame/releases" {
+				strings.NewReader(validReleasesJSON).WriteTo(w)
+			} else {
+				strings.NewReader(validReferenceJSON).WriteTo(w)
+			}
+		})
+
+		app, err := NewGitHubWithOptions("hverr", "reponame", GitHubOptions{
+			Client:        cl,
+			RateLimitWait: wait,
+		})
+		require.Nil(t, err)
+		return ts, app
+	}
+
+	// Rate limit resets too late
+	{
+		reset := time.Now().Add(time.Hour).Truncate(time.Second)
+		ts, app := newApp(reset, time.Minute)
+		defer ts.Close()
+
+		err := app.Query()
+		var rateErr *RateLimitError
+		require.True(t, errors.As(err, &rateErr), "Unexpected error: %v", err)
+		assert.True(t, reset.Equal(rateErr.Reset))
+		assert.Contains(t, err.Error(), "rate limit")
+		assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+	}
+
+	// Wait for the rate limit to reset
+	{
+		reset := time.Now().Add(time.Second).Truncate(time.Second).Add(time.Second)
+		ts, app := newApp(reset, time.Minute)
+		defer ts.Close()
+
+		err := app.Query()
+		require.Nil(t, err, "Unexpected query error: %v", err)
+		assert.False(t, time.Now().Before(reset))
+		assert.Equal(t, "v1.0.0", app.LatestRelease().Name())
+	}
+}
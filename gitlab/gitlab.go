@@ -0,0 +1,175 @@
+// Package gitlab fetches the releases of an application from GitLab.com or a
+// self-hosted GitLab instance:
+//
+//	u := &updater.Updater{App: gitlab.New("owner", "myapp", nil)}
+package gitlab
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	updater "github.com/hverr/go-updater"
+	"github.com/xanzy/go-gitlab"
+)
+
+// Number of releases requested per page from the GitLab API.
+const gitlabReleasesPerPage = 100
+
+type gitlabApp struct {
+	owner      string
+	repository string
+	client     *gitlab.Client
+	releases   []updater.Release
+}
+
+type gitlabRelease struct {
+	Release gitlab.Release
+
+	assets []updater.Asset
+}
+
+type gitlabAsset struct {
+	Link gitlab.ReleaseLink
+}
+
+// New creates an Application that is hosted on GitLab.
+//
+// Set client to nil to use the default one, which talks to GitLab.com. Pass a
+// client with a custom base URL to use a self-hosted GitLab instance.
+func New(owner, repository string, client *gitlab.Client) updater.App {
+	if client == nil {
+		// Without options, creating a client cannot fail.
+		client, _ = gitlab.NewClient("")
+	}
+
+	return &gitlabApp{
+		owner:      owner,
+		repository: repository,
+
+		client: client,
+	}
+}
+
+func (app *gitlabApp) Query() error {
+	return app.QueryContext(context.Background())
+}
+
+func (app *gitlabApp) QueryContext(ctx context.Context) error {
+	// Get all available releases
+	pid := app.owner + "/" + app.repository
+	var releases []*gitlab.Release
+	opt := &gitlab.ListReleasesOptions{
+		ListOptions: gitlab.ListOptions{PerPage: gitlabReleasesPerPage, Page: 1},
+	}
+	for opt.Page != 0 {
+		s, resp, err := app.client.Releases.ListReleases(
+			pid, opt, gitlab.WithContext(ctx),
+		)
+		if err != nil {
+			return err
+		}
+
+		releases = append(releases, s...)
+		opt.Page = resp.NextPage
+	}
+
+	s := make([]updater.Release, len(releases))
+	for i, r := range releases {
+		s[i] = newGitlabRelease(r)
+	}
+	app.releases = s
+
+	return nil
+}
+
+func (app *gitlabApp) LatestRelease() updater.Release {
+	if len(app.releases) == 0 {
+		return nil
+	}
+
+	return app.releases[0]
+}
+
+func (app *gitlabApp) Releases() []updater.Release {
+	return app.releases
+}
+
+func newGitlabRelease(r *gitlab.Release) *gitlabRelease {
+	s := make([]updater.Asset, 0, len(r.Assets.Links))
+	for _, l := range r.Assets.Links {
+		if l != nil {
+			s = append(s, &gitlabAsset{*l})
+		}
+	}
+
+	return &gitlabRelease{
+		Release: *r,
+		assets:  s,
+	}
+}
+
+func (r *gitlabRelease) Name() string {
+	return r.Release.TagName
+}
+
+func (r *gitlabRelease) Information() string {
+	return r.Release.Description
+}
+
+// InformationHTML renders the Markdown release notes to HTML.
+func (r *gitlabRelease) InformationHTML() string {
+	return updater.MarkdownHTML(r.Information())
+}
+
+// InformationText returns the release notes without Markdown markup.
+func (r *gitlabRelease) InformationText() string {
+	return updater.MarkdownText(r.Information())
+}
+
+func (r *gitlabRelease) Identifier() string {
+	return r.Release.Commit.ID
+}
+
+func (r *gitlabRelease) Assets() []updater.Asset {
+	return r.assets
+}
+
+func (r *gitlabAsset) Name() string {
+	return r.Link.Name
+}
+
+func (r *gitlabAsset) Write(w io.Writer) error {
+	return r.WriteContext(context.Background(), w)
+}
+
+func (r *gitlabAsset) WriteContext(ctx context.Context, w io.Writer) error {
+	url, err := r.url()
+	if err != nil {
+		return err
+	}
+
+	return updater.DownloadURL(ctx, nil, url, w)
+}
+
+// Open requests the asset.
+func (r *gitlabAsset) Open(ctx context.Context) (io.ReadCloser, int64, error) {
+	url, err := r.url()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return updater.OpenURL(ctx, nil, url)
+}
+
+// url returns the download URL of the asset.
+func (r *gitlabAsset) url() (string, error) {
+	url := r.Link.DirectAssetURL
+	if url == "" {
+		url = r.Link.URL
+	}
+	if url == "" {
+		return "", errors.New("No download URL available.")
+	}
+	return url, nil
+}
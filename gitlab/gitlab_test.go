@@ -0,0 +1,165 @@
+package gitlab
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	updater "github.com/hverr/go-updater"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xanzy/go-gitlab"
+)
+
+func newTestGitLabClient(t *testing.T, f func(w http.ResponseWriter, r *http.Request)) (*httptest.Server, *gitlab.Client) {
+	ts := httptest.NewServer(http.HandlerFunc(f))
+	client, err := gitlab.NewClient("", gitlab.WithBaseURL(ts.URL))
+	require.Nil(t, err, "Could not create client: %v", err)
+
+	return ts, client
+}
+
+func TestGitLabQuery(t *testing.T) {
+	// With valid JSON
+	{
+		ts, cl := newTestGitLabClient(t, func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.EscapedPath() == "/api/v4/projects/hverr%2Freponame/releases" {
+				strings.NewReader(validGitLabReleasesJSON).WriteTo(w)
+			} else {
+				require.True(t, false, "Unexpected URL path: %v", r.URL.EscapedPath())
+			}
+		})
+		defer ts.Close()
+
+		app := New("hverr", "reponame", cl)
+		err := app.Query()
+
+		assert.Nil(t, err, "Unexpected query error: %v", err)
+
+		release := app.LatestRelease()
+		assert.NotNil(t, release)
+		if release != nil {
+			assert.Equal(t, "v1.0.0", release.Name())
+			assert.Equal(t, "Description of the release", release.Information())
+			assert.Equal(t, "2695effb5807a22ff3d138d593fd856244e155e7", release.Identifier())
+			assert.Equal(t, 1, len(release.Assets()))
+			if len(release.Assets()) != 0 {
+				assert.Equal(t, "example.zip", release.Assets()[0].Name())
+			}
+		}
+	}
+
+	// With invalid JSON
+	{
+		ts, cl := newTestGitLabClient(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("invalid json"))
+		})
+		defer ts.Close()
+
+		app := New("hverr", "reponame", cl)
+		err := app.Query()
+		assert.Error(t, err)
+	}
+}
+
+func TestGitLabQueryPagination(t *testing.T) {
+	ts, cl := newTestGitLabClient(t, func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/v4/projects/hverr%2Freponame/releases", r.URL.EscapedPath())
+		assert.Equal(t, "100", r.URL.Query().Get("per_page"))
+		switch r.URL.Query().Get("page") {
+		case "1":
+			w.Header().Set("X-Next-Page", "2")
+			w.Write([]byte(`[{"tag_name": "v1.0.0"}, {"tag_name": "v0.9.0"}]`))
+		case "2":
+			w.Write([]byte(`[{"tag_name": "v0.8.0"}]`))
+		default:
+			require.True(t, false, "Unexpected page: %v", r.URL.RawQuery)
+		}
+	})
+	defer ts.Close()
+
+	app := New("hverr", "reponame", cl).(updater.ReleasesApp)
+	err := app.Query()
+	assert.Nil(t, err, "Unexpected query error: %v", err)
+
+	var names []string
+	for _, r := range app.Releases() {
+		names = append(names, r.Name())
+	}
+	assert.Equal(t, []string{"v1.0.0", "v0.9.0", "v0.8.0"}, names)
+}
+
+func TestGitLabLatestRelease(t *testing.T) {
+	// No information available
+	{
+		app := New("hverr", "reponame", nil)
+		assert.Nil(t, app.LatestRelease())
+	}
+
+	// Valid releases
+	{
+		r := &gitlabRelease{}
+		app := gitlabApp{
+			releases: []updater.Release{r},
+		}
+
+		assert.Equal(t, r, app.LatestRelease())
+	}
+}
+
+func TestGitLabAssetWrite(t *testing.T) {
+	// Valid contents
+	{
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("Hello World!"))
+		}))
+		defer ts.Close()
+
+		asset := &gitlabAsset{}
+		asset.Link.URL = ts.URL
+		buf := bytes.NewBuffer(nil)
+
+		err := asset.Write(buf)
+		assert.Nil(t, err, "Unexepected error %v:", err)
+		assert.Equal(t, "Hello World!", buf.String())
+	}
+
+	// No URL
+	{
+		asset := &gitlabAsset{}
+		err := asset.Write(nil)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "No download URL")
+	}
+}
+
+var validGitLabReleasesJSON = `
+[
+  {
+    "tag_name": "v1.0.0",
+    "description": "Description of the release",
+    "name": "v1.0.0",
+    "created_at": "2019-01-03T01:56:19.539Z",
+    "released_at": "2019-01-03T01:56:19.539Z",
+    "commit": {
+      "id": "2695effb5807a22ff3d138d593fd856244e155e7",
+      "short_id": "2695effb"
+    },
+    "assets": {
+      "count": 1,
+      "sources": [],
+      "links": [
+        {
+          "id": 2,
+          "name": "example.zip",
+          "url": "https://gitlab.example.com/root/awesome-app/-/tags/v1.0.0/example.zip",
+          "external": true,
+          "link_type": "other"
+        }
+      ]
+    }
+  }
+]
+`
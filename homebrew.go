@@ -0,0 +1,403 @@
+package updater
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// homebrewAPIURL is the URL of the formula API of Homebrew.
+var homebrewAPIURL = "https://formulae.brew.sh/api/formula/"
+
+// homebrewFormula is the stable version of a Homebrew formula.
+type homebrewFormula struct {
+	name     string
+	version  string
+	revision int
+	sources  []homebrewFile
+	bottles  []homebrewFile
+}
+
+// homebrewFile is a downloadable file of a formula.
+type homebrewFile struct {
+	name   string
+	url    string
+	sha256 string
+}
+
+type homebrewApp struct {
+	url    string
+	name   string
+	tap    bool
+	client *http.Client
+	latest Release
+
+	// Validator of the formula of the last successful query.
+	validator cacheValidator
+}
+
+type homebrewRelease struct {
+	formula *homebrewFormula
+	assets  []Asset
+}
+
+type homebrewAsset struct {
+	file   homebrewFile
+	client *http.Client
+}
+
+// NewHomebrew creates an Application whose latest release is the stable
+// version of a formula of homebrew-core, e.g. "myapp", as reported by the
+// Homebrew API at formulae.brew.sh.
+//
+// Use it for applications installed with brew, to detect updates and ask the
+// user to run brew upgrade. The name and identifier of the release is the
+// version of the formula, with the revision if any, e.g. 1.2.3_1, and its
+// information is the command to upgrade the formula.
+//
+// The assets of the release are the source archive, named after the last
+// element of its URL, and the bottles, named like
+// myapp-1.2.3.arm64_sonoma.bottle.tar.gz. They are verified with the checksums
+// of the formula while they are written.
+//
+// Set client to nil to use the HTTPClient of the Updater, or the default HTTP
+// client.
+func NewHomebrew(formula string, client *http.Client) App {
+	return &homebrewApp{
+		url:    homebrewAPIURL + url.PathEscape(formula) + ".json",
+		name:   formula,
+		client: client,
+	}
+}
+
+// NewHomebrewTap is like NewHomebrew, but reads the Ruby formula of a tap at
+// the given URL, e.g.
+// https://raw.githubusercontent.com/owner/homebrew-tap/HEAD/Formula/myapp.rb.
+//
+// The version, revision, URLs and checksums of the formula are read from its
+// top-level statements, so formulas generated by tools like GoReleaser, with
+// a URL per platform, are supported. Bottles are only available when the
+// bottle block has a root_url.
+func NewHomebrewTap(formulaURL string, client *http.Client) App {
+	return &homebrewApp{
+		url:    formulaURL,
+		name:   strings.TrimSuffix(urlFileName(formulaURL), ".rb"),
+		tap:    true,
+		client: client,
+	}
+}
+
+func (app *homebrewApp) Query() error {
+	return app.QueryContext(context.Background())
+}
+
+func (app *homebrewApp) QueryContext(ctx context.Context) error {
+	req, err := http.NewRequest("GET", app.url, nil)
+	if err != nil {
+		return err
+	}
+	if app.latest != nil {
+		app.validator.apply(req)
+	}
+
+	// Keep the latest release if the formula did not change
+	buf := bytes.NewBuffer(nil)
+	resp, err := downloadResponse(ctx, app.client, req, buf)
+	if resp != nil && resp.StatusCode == http.StatusNotModified {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var f *homebrewFormula
+	if app.tap {
+		f, err = parseHomebrewRuby(app.name, buf.Bytes())
+	} else {
+		f, err = parseHomebrewJSON(buf.Bytes())
+	}
+	if err != nil {
+		return err
+	}
+
+	app.latest = newHomebrewRelease(f, app.client)
+	app.validator = newCacheValidator(resp)
+	return nil
+}
+
+func (app *homebrewApp) LatestRelease() Release {
+	return app.latest
+}
+
+// homebrewFormulaJSON is a formula returned by the Homebrew API.
+type homebrewFormulaJSON struct {
+	Name     string `json:"name"`
+	Revision int    `json:"revision"`
+	Versions struct {
+		Stable string `json:"stable"`
+	} `json:"versions"`
+	URLs struct {
+		Stable struct {
+			URL      string `json:"url"`
+			Checksum string `json:"checksum"`
+		} `json:"stable"`
+	} `json:"urls"`
+	Bottle struct {
+		Stable struct {
+			Rebuild int `json:"rebuild"`
+			Files   map[string]struct {
+				URL    string `json:"url"`
+				SHA256 string `json:"sha256"`
+			} `json:"files"`
+		} `json:"stable"`
+	} `json:"bottle"`
+}
+
+// parseHomebrewJSON parses a formula returned by the Homebrew API.
+func parseHomebrewJSON(data []byte) (*homebrewFormula, error) {
+	var j homebrewFormulaJSON
+	err := json.Unmarshal(data, &j)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid Homebrew formula: %v", err)
+	}
+	if j.Name == "" || j.Versions.Stable == "" {
+		return nil, fmt.Errorf("Homebrew formula %v has no stable version.", j.Name)
+	}
+
+	f := &homebrewFormula{
+		name:     j.Name,
+		version:  j.Versions.Stable,
+		revision: j.Revision,
+	}
+	if s := j.URLs.Stable; s.URL != "" {
+		f.sources = append(f.sources, homebrewFile{
+			name:   urlFileName(s.URL),
+			url:    s.URL,
+			sha256: s.Checksum,
+		})
+	}
+	for tag, b := range j.Bottle.Stable.Files {
+		f.bottles = append(f.bottles, homebrewFile{
+			name:   f.bottleName(tag, j.Bottle.Stable.Rebuild),
+			url:    b.URL,
+			sha256: b.SHA256,
+		})
+	}
+	sort.Slice(f.bottles, func(i, j int) bool { return f.bottles[i].name < f.bottles[j].name })
+	return f, nil
+}
+
+var (
+	homebrewStringRegexp    = regexp.MustCompile(`^(url|sha256|version|root_url)\s+"([^"]*)"`)
+	homebrewIntegerRegexp   = regexp.MustCompile(`^(revision|rebuild)\s+(\d+)`)
+	homebrewBottleRegexp    = regexp.MustCompile(`(\w+):\s*"([0-9a-f]{64})"`)
+	homebrewOldBottleRegexp = regexp.MustCompile(`"([0-9a-f]{64})"\s*=>\s*:(\w+)`)
+	homebrewVersionRegexp   = regexp.MustCompile(`\d+(\.\d+)+`)
+)
+
+// parseHomebrewRuby parses the Ruby formula of a tap.
+func parseHomebrewRuby(name string, data []byte) (*homebrewFormula, error) {
+	f := &homebrewFormula{name: name}
+
+	var (
+		inBottle bool
+		skip     bool
+		rootURL  string
+		rebuild  int
+		bottles  = make(map[string]string)
+	)
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+
+		if skip {
+			// Resources, patches and head sources are not the formula
+			skip = line != "end"
+			continue
+		} else if inBottle {
+			switch {
+			case line == "end":
+				inBottle = false
+			case strings.HasPrefix(line, "root_url "):
+				if m := homebrewStringRegexp.FindStringSubmatch(line); m != nil {
+					rootURL = m[2]
+				}
+			case strings.HasPrefix(line, "rebuild "):
+				if m := homebrewIntegerRegexp.FindStringSubmatch(line); m != nil {
+					rebuild, _ = strconv.Atoi(m[2])
+				}
+			case strings.HasPrefix(line, "sha256 "):
+				for _, m := range homebrewBottleRegexp.FindAllStringSubmatch(line, -1) {
+					if m[1] != "cellar" {
+						bottles[m[1]] = m[2]
+					}
+				}
+				if m := homebrewOldBottleRegexp.FindStringSubmatch(line); m != nil {
+					bottles[m[2]] = m[1]
+				}
+			}
+			continue
+		}
+
+		if strings.HasPrefix(line, "bottle do") {
+			inBottle = true
+		} else if strings.HasPrefix(line, "resource ") || line == "patch do" || line == "head do" {
+			skip = true
+		} else if m := homebrewStringRegexp.FindStringSubmatch(line); m != nil {
+			switch m[1] {
+			case "url":
+				f.sources = append(f.sources, homebrewFile{url: m[2]})
+			case "sha256":
+				// The checksum belongs to the preceding URL
+				if n := len(f.sources); n > 0 && f.sources[n-1].sha256 == "" {
+					f.sources[n-1].sha256 = m[2]
+				}
+			case "version":
+				f.version = m[2]
+			}
+		} else if m := homebrewIntegerRegexp.FindStringSubmatch(line); m != nil && m[1] == "revision" {
+			f.revision, _ = strconv.Atoi(m[2])
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	// The version is usually part of the URL
+	if f.version == "" && len(f.sources) > 0 {
+		f.version = homebrewVersionRegexp.FindString(urlFileName(f.sources[0].url))
+	}
+	if f.version == "" {
+		return nil, fmt.Errorf("Homebrew formula %v has no version.", name)
+	}
+
+	for i := range f.sources {
+		f.sources[i].url = strings.Replace(f.sources[i].url, "#{version}", f.version, -1)
+		f.sources[i].name = urlFileName(f.sources[i].url)
+	}
+
+	if rootURL != "" {
+		for tag, sum := range bottles {
+			f.bottles = append(f.bottles, homebrewFile{
+				name:   f.bottleName(tag, rebuild),
+				url:    f.bottleURL(rootURL, tag, rebuild, sum),
+				sha256: sum,
+			})
+		}
+		sort.Slice(f.bottles, func(i, j int) bool { return f.bottles[i].name < f.bottles[j].name })
+	}
+
+	return f, nil
+}
+
+// pkgVersion returns the version of the formula with its revision, if any,
+// e.g. 1.2.3_1.
+func (f *homebrewFormula) pkgVersion() string {
+	if f.revision > 0 {
+		return fmt.Sprintf("%v_%v", f.version, f.revision)
+	}
+	return f.version
+}
+
+// bottleName returns the file name of the bottle for tag.
+func (f *homebrewFormula) bottleName(tag string, rebuild int) string {
+	ext := "bottle.tar.gz"
+	if rebuild > 0 {
+		ext = fmt.Sprintf("bottle.%v.tar.gz", rebuild)
+	}
+	return fmt.Sprintf("%v-%v.%v.%v", f.name, f.pkgVersion(), tag, ext)
+}
+
+// bottleURL returns the URL of the bottle for tag in the bottle repository at
+// rootURL. Bottles in the GitHub container registry are addressed by their
+// checksum.
+func (f *homebrewFormula) bottleURL(rootURL, tag string, rebuild int, sum string) string {
+	rootURL = strings.TrimSuffix(rootURL, "/")
+	if u, err := url.Parse(rootURL); err == nil && u.Host == "ghcr.io" {
+		return fmt.Sprintf("%v/%v/blobs/sha256:%v", rootURL, f.name, sum)
+	}
+	return rootURL + "/" + url.PathEscape(f.bottleName(tag, rebuild))
+}
+
+// urlFileName returns the last element of the path of a URL.
+func urlFileName(s string) string {
+	if u, err := url.Parse(s); err == nil {
+		return path.Base(u.Path)
+	}
+	return path.Base(s)
+}
+
+func newHomebrewRelease(f *homebrewFormula, client *http.Client) *homebrewRelease {
+	r := &homebrewRelease{formula: f}
+	for _, files := range [][]homebrewFile{f.sources, f.bottles} {
+		for _, file := range files {
+			r.assets = append(r.assets, &homebrewAsset{file: file, client: client})
+		}
+	}
+	return r
+}
+
+func (r *homebrewRelease) Name() string {
+	return r.formula.pkgVersion()
+}
+
+// Information returns the command to upgrade the formula.
+func (r *homebrewRelease) Information() string {
+	return fmt.Sprintf("Update with brew upgrade %v.", r.formula.name)
+}
+
+func (r *homebrewRelease) Identifier() string {
+	return r.formula.pkgVersion()
+}
+
+func (r *homebrewRelease) Assets() []Asset {
+	return r.assets
+}
+
+func (r *homebrewAsset) Name() string {
+	return r.file.name
+}
+
+func (r *homebrewAsset) Write(w io.Writer) error {
+	return r.WriteContext(context.Background(), w)
+}
+
+// WriteContext downloads the file and verifies its checksum.
+func (r *homebrewAsset) WriteContext(ctx context.Context, w io.Writer) error {
+	req, err := http.NewRequest("GET", r.file.url, nil)
+	if err != nil {
+		return err
+	}
+	if req.URL.Host == "ghcr.io" {
+		// Anonymous token of the GitHub container registry, like brew
+		req.Header.Set("Authorization", "Bearer QQ==")
+	}
+
+	if r.file.sha256 == "" {
+		return downloadRequest(ctx, r.client, req, w)
+	}
+
+	expected, err := hex.DecodeString(r.file.sha256)
+	if err != nil {
+		return fmt.Errorf("Invalid checksum for %v: %v", r.Name(), err)
+	}
+
+	h := sha256.New()
+	err = downloadRequest(ctx, r.client, req, teeWriter(w, h))
+	if err != nil {
+		return err
+	}
+
+	return verifyChecksum(map[string][]byte{r.Name(): expected}, r, h.Sum(nil))
+}
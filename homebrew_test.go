@@ -0,0 +1,194 @@
+package updater
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHomebrew(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/formula/myapp.json":
+			w.Write([]byte(strings.Replace(homebrewJSON, "SERVER", ts.URL, -1)))
+		case "/api/formula/invalid.json":
+			w.Write([]byte(`{"name": "invalid", "versions": {}}`))
+		case "/Formula/myapp.rb":
+			w.Write([]byte(strings.Replace(homebrewRuby, "SERVER", ts.URL, -1)))
+		case "/Formula/noversion.rb":
+			w.Write([]byte("class Noversion < Formula\n  url \"SERVER/myapp.tar.gz\"\nend\n"))
+		case "/myapp-1.2.3.tar.gz", "/downloads/v1.2.3/myapp_Darwin_arm64.tar.gz":
+			w.Write([]byte("Hello World!"))
+		case "/bottles/myapp-1.2.3_1.arm64_sonoma.bottle.2.tar.gz":
+			w.Write([]byte("Corrupted"))
+		case "/v2/homebrew/core/myapp/blobs/sha256:7f83b1657ff1fc53b92dc18148a1d65dfc2d4b1fa3d677284addd200126d9069":
+			w.Write([]byte("Hello World!"))
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	defer ts.Close()
+
+	defer func(u string) { homebrewAPIURL = u }(homebrewAPIURL)
+	homebrewAPIURL = ts.URL + "/api/formula/"
+
+	names := func(r Release) []string {
+		var s []string
+		for _, a := range r.Assets() {
+			s = append(s, a.Name())
+		}
+		return s
+	}
+
+	// Homebrew API
+	{
+		app := NewHomebrew("myapp", nil)
+		err := app.Query()
+		require.Nil(t, err, "Unexpected query error: %v", err)
+
+		r := app.LatestRelease()
+		require.NotNil(t, r)
+		assert.Equal(t, "1.2.3", r.Name())
+		assert.Equal(t, "1.2.3", r.Identifier())
+		assert.Equal(t, "Update with brew upgrade myapp.", r.Information())
+		assert.Equal(t, []string{
+			"myapp-1.2.3.tar.gz",
+			"myapp-1.2.3.arm64_sonoma.bottle.tar.gz",
+			"myapp-1.2.3.sonoma.bottle.tar.gz",
+		}, names(r))
+
+		buf := bytes.NewBuffer(nil)
+		err = r.Assets()[0].Write(buf)
+		assert.Nil(t, err, "Unexpected write error: %v", err)
+		assert.Equal(t, "Hello World!", buf.String())
+
+		buf.Reset()
+		err = r.Assets()[1].Write(buf)
+		assert.Nil(t, err, "Unexpected write error: %v", err)
+		assert.Equal(t, "Hello World!", buf.String())
+
+		// Missing bottle
+		err = r.Assets()[2].Write(bytes.NewBuffer(nil))
+		assert.NotNil(t, err)
+	}
+
+	// Tap formula
+	{
+		app := NewHomebrewTap(ts.URL+"/Formula/myapp.rb", nil)
+		err := app.Query()
+		require.Nil(t, err, "Unexpected query error: %v", err)
+
+		r := app.LatestRelease()
+		require.NotNil(t, r)
+		assert.Equal(t, "1.2.3_1", r.Name())
+		assert.Equal(t, "1.2.3_1", r.Identifier())
+		assert.Equal(t, []string{
+			"myapp_Darwin_arm64.tar.gz",
+			"myapp_Linux_x86_64.tar.gz",
+			"myapp-1.2.3_1.arm64_sonoma.bottle.2.tar.gz",
+			"myapp-1.2.3_1.monterey.bottle.2.tar.gz",
+		}, names(r))
+
+		// Version interpolated in the URL, with a valid checksum
+		buf := bytes.NewBuffer(nil)
+		err = r.Assets()[0].Write(buf)
+		assert.Nil(t, err, "Unexpected write error: %v", err)
+		assert.Equal(t, "Hello World!", buf.String())
+
+		// Invalid checksum
+		err = r.Assets()[2].Write(bytes.NewBuffer(nil))
+		if assert.NotNil(t, err) {
+			assert.Contains(t, err.Error(), "mismatch")
+		}
+	}
+
+	// Invalid formulas
+	{
+		assert.NotNil(t, NewHomebrew("invalid", nil).Query())
+		assert.NotNil(t, NewHomebrew("missing", nil).Query())
+		assert.NotNil(t, NewHomebrewTap(ts.URL+"/Formula/noversion.rb", nil).Query())
+	}
+}
+
+func TestHomebrewBottleURL(t *testing.T) {
+	f := &homebrewFormula{name: "myapp", version: "1.2.3"}
+	sum := "7f83b1657ff1fc53b92dc18148a1d65dfc2d4b1fa3d677284addd200126d9069"
+
+	assert.Equal(t,
+		"https://example.com/bottles/myapp-1.2.3.sonoma.bottle.tar.gz",
+		f.bottleURL("https://example.com/bottles/", "sonoma", 0, sum),
+	)
+	assert.Equal(t,
+		"https://ghcr.io/v2/owner/tap/myapp/blobs/sha256:"+sum,
+		f.bottleURL("https://ghcr.io/v2/owner/tap", "sonoma", 1, sum),
+	)
+}
+
+const homebrewJSON = `{
+	"name": "myapp",
+	"revision": 0,
+	"versions": {"stable": "1.2.3", "head": "HEAD"},
+	"urls": {
+		"stable": {
+			"url": "SERVER/myapp-1.2.3.tar.gz",
+			"checksum": "7f83b1657ff1fc53b92dc18148a1d65dfc2d4b1fa3d677284addd200126d9069"
+		}
+	},
+	"bottle": {
+		"stable": {
+			"rebuild": 0,
+			"root_url": "https://ghcr.io/v2/homebrew/core",
+			"files": {
+				"sonoma": {
+					"cellar": "/opt/homebrew/Cellar",
+					"url": "SERVER/missing",
+					"sha256": "7f83b1657ff1fc53b92dc18148a1d65dfc2d4b1fa3d677284addd200126d9069"
+				},
+				"arm64_sonoma": {
+					"cellar": ":any_skip_relocation",
+					"url": "SERVER/v2/homebrew/core/myapp/blobs/sha256:7f83b1657ff1fc53b92dc18148a1d65dfc2d4b1fa3d677284addd200126d9069",
+					"sha256": "7f83b1657ff1fc53b92dc18148a1d65dfc2d4b1fa3d677284addd200126d9069"
+				}
+			}
+		}
+	}
+}`
+
+const homebrewRuby = `class Myapp < Formula
+  desc "My application"
+  homepage "https://example.com"
+  version "1.2.3"
+  revision 1
+
+  on_macos do
+    url "SERVER/downloads/v#{version}/myapp_Darwin_arm64.tar.gz"
+    sha256 "7f83b1657ff1fc53b92dc18148a1d65dfc2d4b1fa3d677284addd200126d9069"
+  end
+
+  on_linux do
+    url "SERVER/downloads/v#{version}/myapp_Linux_x86_64.tar.gz"
+  end
+
+  bottle do
+    root_url "SERVER/bottles"
+    rebuild 2
+    sha256 cellar: :any_skip_relocation, arm64_sonoma: "7f83b1657ff1fc53b92dc18148a1d65dfc2d4b1fa3d677284addd200126d9069"
+    sha256 "a591a6d40bf420404a011733cfb7b190d62c65bf0bcda32b57b277d9ad9f146e" => :monterey
+  end
+
+  resource "extra" do
+    url "SERVER/extra.tar.gz"
+    sha256 "a591a6d40bf420404a011733cfb7b190d62c65bf0bcda32b57b277d9ad9f146e"
+  end
+
+  def install
+    bin.install "myapp"
+  end
+end
+`
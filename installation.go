@@ -0,0 +1,95 @@
+package updater
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+)
+
+// InstallationIDHeader is the HTTP header in which the installation
+// identifier is sent, see SendInstallationID of the Updater.
+const InstallationIDHeader = "X-Installation-ID"
+
+// installationIDKey is the context key of the installation identifier.
+type installationIDKey struct{}
+
+// LoadInstallationID returns the anonymous identifier of this installation
+// kept in store. A random identifier is generated and saved when the store
+// has none yet, so the identifier is stable as long as the state is kept.
+//
+// Use any StateStore to keep the identifier elsewhere, e.g. in the registry
+// or in the configuration of the application.
+func LoadInstallationID(store StateStore) (string, error) {
+	s, err := store.Load()
+	if err != nil {
+		return "", err
+	}
+	if s.MachineID != "" {
+		return s.MachineID, nil
+	}
+
+	s.MachineID, err = newInstallationID()
+	if err != nil {
+		return "", err
+	}
+	err = store.Save(s)
+	if err != nil {
+		return "", err
+	}
+	return s.MachineID, nil
+}
+
+// InstallationID returns the anonymous identifier of this installation: the
+// MachineID of the updater, or else the identifier kept in its State, see
+// LoadInstallationID.
+func (u *Updater) InstallationID() (string, error) {
+	if u.MachineID != "" {
+		return u.MachineID, nil
+	}
+	if u.State == nil {
+		return "", errors.New("No machine identifier or state store for an installation identifier.")
+	}
+	return LoadInstallationID(u.State)
+}
+
+// InstallationIDFromContext returns the installation identifier that the
+// updater passes to applications and assets, or an empty string if it does
+// not send one, see SendInstallationID of the Updater. Applications use it to
+// send the identifier in another way, e.g. as a query parameter.
+func InstallationIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(installationIDKey{}).(string)
+	return id
+}
+
+// withInstallationID returns a context in which requests send the
+// installation identifier, if the updater should send it.
+func (u *Updater) withInstallationID(ctx context.Context) (context.Context, error) {
+	if !u.SendInstallationID || InstallationIDFromContext(ctx) != "" {
+		return ctx, nil
+	}
+
+	id, err := u.InstallationID()
+	if err != nil {
+		return nil, err
+	}
+	return context.WithValue(ctx, installationIDKey{}, id), nil
+}
+
+// setInstallationID adds the installation identifier of ctx to req.
+func setInstallationID(ctx context.Context, req *http.Request) {
+	if id := InstallationIDFromContext(ctx); id != "" && req.Header.Get(InstallationIDHeader) == "" {
+		req.Header.Set(InstallationIDHeader, id)
+	}
+}
+
+// newInstallationID returns a random installation identifier.
+func newInstallationID() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,94 @@
+package updater
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadInstallationID(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testing-")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// Generated once
+	store := NewFileStateStore(filepath.Join(dir, "state.json"))
+	id, err := LoadInstallationID(store)
+	require.Nil(t, err, "Could not load identifier: %v", err)
+	assert.Equal(t, 32, len(id))
+
+	id2, err := LoadInstallationID(NewFileStateStore(filepath.Join(dir, "state.json")))
+	require.Nil(t, err)
+	assert.Equal(t, id, id2)
+
+	// Identifier of the updater
+	u := &Updater{State: store}
+	id3, err := u.InstallationID()
+	require.Nil(t, err)
+	assert.Equal(t, id, id3)
+
+	u.MachineID = "machine"
+	id3, err = u.InstallationID()
+	require.Nil(t, err)
+	assert.Equal(t, "machine", id3)
+
+	_, err = (&Updater{}).InstallationID()
+	assert.NotNil(t, err)
+}
+
+func TestUpdaterSendInstallationID(t *testing.T) {
+	var mutex sync.Mutex
+	headers := make(map[string]string)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		headers[r.URL.Path] = r.Header.Get(InstallationIDHeader)
+		mutex.Unlock()
+
+		switch r.URL.Path {
+		case "/manifest.json":
+			w.Write([]byte(`{"version": "v2", "assets": [{"name": "myapp", "url": "myapp"}]}`))
+		case "/myapp":
+			w.Write([]byte("Hello World!"))
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	defer ts.Close()
+
+	// Sent when enabled
+	{
+		b := NewAbortBuffer(nil)
+		u := &Updater{
+			App:                NewHTTPManifest(ts.URL+"/manifest.json", nil),
+			MachineID:          "abc",
+			SendInstallationID: true,
+			WriterForAsset:     func(Asset) (AbortWriter, error) { return b, nil },
+		}
+		err := u.UpdateTo(nil)
+		require.Nil(t, err, "Could not update: %v", err)
+		assert.Equal(t, "abc", headers["/manifest.json"])
+		assert.Equal(t, "abc", headers["/myapp"])
+	}
+
+	// Not sent by default
+	{
+		u := &Updater{App: NewHTTPManifest(ts.URL+"/manifest.json", nil), MachineID: "abc"}
+		_, err := u.Check()
+		require.Nil(t, err)
+		assert.Equal(t, "", headers["/manifest.json"])
+	}
+
+	// Missing identifier
+	{
+		u := &Updater{App: NewHTTPManifest(ts.URL+"/manifest.json", nil), SendInstallationID: true}
+		_, err := u.Check()
+		assert.NotNil(t, err)
+	}
+}
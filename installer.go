@@ -0,0 +1,171 @@
+package updater
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Installer applies updates of installer-based distributions: instead of
+// overwriting a file, the installer asset is downloaded to a temporary
+// directory and launched when it was written and validated successfully.
+//
+// Set it as the Installer of an Updater instead of WriterForAsset, with an
+// AssetFilter that selects the installer of the platform:
+//
+//	u := &updater.Updater{
+//		App:       app,
+//		Installer: &updater.Installer{Silent: true},
+//		AssetFilter: func(a updater.Asset) bool {
+//			return a.Name() == "myapp_windows_amd64.msi"
+//		},
+//	}
+//
+// The installer is launched based on the extension of the asset:
+//
+//	.msi  msiexec /i, with /qn /norestart when silent
+//	.exe  the installer itself, with Args
+//	.pkg  installer -pkg -target / when silent, otherwise open
+//	.dmg  open, silent installation is not supported
+//
+// Installers usually replace the running application, so the application
+// should exit after the update was applied unless Wait is set.
+type Installer struct {
+	// Whether the installer runs without user interaction.
+	//
+	// Executable installers have no standard silent switch, add it to Args,
+	// e.g. /S for NSIS or /VERYSILENT for Inno Setup installers.
+	Silent bool
+
+	// Additional arguments passed to the installer.
+	Args []string
+
+	// Whether closing the writer waits for the installer to exit.
+	//
+	// An error is returned if the installer fails. The downloaded installer
+	// is then removed, otherwise it is left for the installer to use.
+	Wait bool
+
+	// Directory in which the installers are downloaded. Defaults to the
+	// temporary directory.
+	Dir string
+}
+
+// WriterForAsset returns a writer that stores the asset in a new temporary
+// directory and launches it when the writer is closed. The asset is removed
+// when the writer is aborted.
+//
+// The Updater closes the writers of its Installer when the update is applied.
+func (i *Installer) WriterForAsset(a Asset) (AbortWriter, error) {
+	// Check that the installer can be launched before downloading it
+	if _, err := i.command(a.Name()); err != nil {
+		return nil, err
+	}
+
+	dir, err := ioutil.TempDir(i.Dir, installerPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(dir, filepath.Base(a.Name()))
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0755)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	return &installerWriter{installer: i, dir: dir, file: f}, nil
+}
+
+// command returns the command that launches the installer at path.
+func (i *Installer) command(path string) (*exec.Cmd, error) {
+	var args []string
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".msi":
+		args = []string{"msiexec", "/i", path}
+		if i.Silent {
+			args = append(args, "/qn", "/norestart")
+		}
+	case ".exe":
+		args = []string{path}
+	case ".pkg":
+		if i.Silent {
+			args = []string{"installer", "-pkg", path, "-target", "/"}
+		} else {
+			args = []string{"open", "-W", path}
+		}
+	case ".dmg":
+		if i.Silent {
+			return nil, errors.New("Silent installation of disk images is not supported.")
+		}
+		args = []string{"open", path}
+	default:
+		return nil, fmt.Errorf("Unsupported installer %v.", filepath.Base(path))
+	}
+
+	args = append(args, i.Args...)
+	return exec.Command(args[0], args[1:]...), nil
+}
+
+// installerWriter writes an installer and launches it when closed.
+type installerWriter struct {
+	installer *Installer
+	dir       string
+	file      *os.File
+	aborted   bool
+}
+
+// Write data to the installer.
+func (w *installerWriter) Write(b []byte) (int, error) {
+	if w.aborted {
+		return 0, errors.New("The installer was aborted.")
+	}
+	return w.file.Write(b)
+}
+
+// Abort and remove the installer, it is not launched when closed.
+func (w *installerWriter) Abort() {
+	if !w.aborted {
+		w.aborted = true
+		w.file.Close()
+		os.RemoveAll(w.dir)
+	}
+}
+
+// Close the installer and launch it, unless it was aborted.
+func (w *installerWriter) Close() error {
+	if w.aborted {
+		return nil
+	}
+
+	err := w.file.Close()
+	if err != nil {
+		os.RemoveAll(w.dir)
+		return err
+	}
+
+	cmd, err := w.installer.command(w.file.Name())
+	if err != nil {
+		os.RemoveAll(w.dir)
+		return err
+	}
+
+	if w.installer.Wait {
+		defer os.RemoveAll(w.dir)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("Installer %v failed: %v: %s", filepath.Base(w.file.Name()), err, out)
+		}
+		return nil
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		os.RemoveAll(w.dir)
+		return fmt.Errorf("Could not launch installer %v: %v", filepath.Base(w.file.Name()), err)
+	}
+	return cmd.Process.Release()
+}
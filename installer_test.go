@@ -0,0 +1,149 @@
+package updater
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstaller(t *testing.T) {
+	dir, err := ioutil.TempDir("", "installer-test-")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	entries := func() int {
+		files, err := ioutil.ReadDir(dir)
+		require.Nil(t, err)
+		return len(files)
+	}
+
+	// Commands
+	{
+		i := &Installer{}
+		cmd, err := i.command(`C:\Temp\MyApp.MSI`)
+		require.Nil(t, err)
+		assert.Equal(t, []string{"msiexec", "/i", `C:\Temp\MyApp.MSI`}, cmd.Args)
+
+		i = &Installer{Silent: true, Args: []string{"/S"}}
+		cmd, err = i.command("myapp.msi")
+		require.Nil(t, err)
+		assert.Equal(t, []string{"msiexec", "/i", "myapp.msi", "/qn", "/norestart", "/S"}, cmd.Args)
+
+		cmd, err = i.command("myapp.exe")
+		require.Nil(t, err)
+		assert.Equal(t, []string{"/S"}, cmd.Args[1:])
+
+		cmd, err = i.command("myapp.pkg")
+		require.Nil(t, err)
+		assert.Equal(t, []string{"installer", "-pkg", "myapp.pkg", "-target", "/", "/S"}, cmd.Args)
+
+		_, err = i.command("myapp.dmg")
+		assert.NotNil(t, err)
+
+		cmd, err = (&Installer{}).command("myapp.dmg")
+		require.Nil(t, err)
+		assert.Equal(t, []string{"open", "myapp.dmg"}, cmd.Args)
+	}
+
+	// Unsupported installers are not downloaded
+	{
+		i := &Installer{Dir: dir}
+		_, err := i.WriterForAsset(&testAsset{name: "myapp.tar.gz"})
+		assert.NotNil(t, err)
+		assert.Equal(t, 0, entries())
+	}
+
+	// Aborted installers are removed
+	{
+		i := &Installer{Dir: dir}
+		w, err := i.WriterForAsset(&testAsset{name: "myapp.msi"})
+		require.Nil(t, err)
+		_, err = w.Write([]byte("Installer"))
+		assert.Nil(t, err)
+		assert.Equal(t, 1, entries())
+
+		w.Abort()
+		assert.Equal(t, 0, entries())
+		_, err = w.Write([]byte("Installer"))
+		assert.NotNil(t, err)
+		assert.Nil(t, w.(io.Closer).Close())
+	}
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+
+	// Launch the installer when updating
+	{
+		out := filepath.Join(dir, "installed")
+		script := "#!/bin/sh\necho \"$@\" > " + out + "\n"
+		release := &testRelease{
+			identifier: "2",
+			assets: []Asset{&testAsset{
+				name: "myapp_setup.exe",
+				write: func(w io.Writer) error {
+					_, err := io.WriteString(w, script)
+					return err
+				},
+			}},
+		}
+
+		i := &Installer{Args: []string{"/S"}, Wait: true, Dir: dir}
+		u := &Updater{Installer: i}
+		err := u.UpdateTo(release)
+		require.Nil(t, err, "Could not update: %v", err)
+
+		data, err := ioutil.ReadFile(out)
+		assert.Nil(t, err)
+		assert.Equal(t, "/S\n", string(data))
+		assert.Nil(t, os.Remove(out))
+		assert.Equal(t, 0, entries())
+	}
+
+	// Failing installer
+	{
+		release := &testRelease{
+			identifier: "2",
+			assets: []Asset{&testAsset{
+				name: "myapp_setup.exe",
+				write: func(w io.Writer) error {
+					_, err := io.WriteString(w, "#!/bin/sh\necho Failed\nexit 1\n")
+					return err
+				},
+			}},
+		}
+
+		i := &Installer{Wait: true, Dir: dir}
+		u := &Updater{Installer: i}
+		err := u.UpdateTo(release)
+		if assert.NotNil(t, err) {
+			assert.Contains(t, err.Error(), "Failed")
+		}
+		assert.Equal(t, 0, entries())
+	}
+
+	// Failing download
+	{
+		release := &testRelease{
+			identifier: "2",
+			assets: []Asset{&testAsset{
+				name: "myapp_setup.exe",
+				write: func(w io.Writer) error {
+					return errors.New("Download failed.")
+				},
+			}},
+		}
+
+		i := &Installer{Wait: true, Dir: dir}
+		u := &Updater{Installer: i}
+		assert.NotNil(t, u.UpdateTo(release))
+		assert.Equal(t, 0, entries())
+	}
+}
@@ -0,0 +1,151 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Keyring is a Verifier that trusts multiple keys, and supports rotating
+// them.
+//
+// A signature is valid if it was made with any of the trusted keys. New keys
+// can be introduced with a signature made by a key that is already trusted,
+// so applications that only know an old key can verify releases signed with
+// its successor.
+type Keyring struct {
+	// Function to parse an introduced public key. Defaults to
+	// ParsePublicKey.
+	ParseKey func(key []byte) (Verifier, error)
+
+	mu         sync.Mutex
+	keys       []Verifier
+	introduced map[string]bool
+}
+
+// NewKeyring creates a keyring that trusts the given keys.
+func NewKeyring(trusted ...Verifier) *Keyring {
+	return &Keyring{
+		keys: trusted,
+	}
+}
+
+// Verify returns nil if signature is a valid signature of message made with
+// any of the trusted keys.
+func (k *Keyring) Verify(message, signature []byte) error {
+	k.mu.Lock()
+	keys := k.keys
+	k.mu.Unlock()
+
+	if len(keys) == 0 {
+		return errors.New("No trusted keys.")
+	}
+
+	var firstErr error
+	for _, v := range keys {
+		err := v.Verify(message, signature)
+		if err == nil {
+			return nil
+		} else if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	if len(keys) == 1 {
+		return firstErr
+	}
+	return errors.New("Signature was not made with a trusted key.")
+}
+
+// Introduce adds a new key to the keyring.
+//
+// The signature must be a valid signature of the key, made with a key that is
+// already trusted. The key is parsed with ParseKey. Introducing a key again
+// does nothing.
+func (k *Keyring) Introduce(key, signature []byte) error {
+	err := k.Verify(key, signature)
+	if err != nil {
+		return fmt.Errorf("Could not verify key introduction: %v", err)
+	}
+
+	k.mu.Lock()
+	known := k.introduced[string(key)]
+	k.mu.Unlock()
+	if known {
+		return nil
+	}
+
+	parse := k.ParseKey
+	if parse == nil {
+		parse = ParsePublicKey
+	}
+	v, err := parse(key)
+	if err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.introduced == nil {
+		k.introduced = make(map[string]bool)
+	}
+	k.introduced[string(key)] = true
+	k.keys = append(k.keys[:len(k.keys):len(k.keys)], v)
+	return nil
+}
+
+// ParsePublicKey parses a public key and returns a verifier for it.
+//
+// Both base64 encoded ed25519 keys, verified with NewEd25519Verifier, and
+// minisign public keys, verified with NewMinisignVerifier, are supported.
+func ParsePublicKey(key []byte) (Verifier, error) {
+	s := strings.TrimSpace(string(key))
+	if b, err := base64.StdEncoding.DecodeString(s); err == nil && len(b) == ed25519.PublicKeySize {
+		return NewEd25519Verifier(ed25519.PublicKey(b)), nil
+	}
+
+	v, err := NewMinisignVerifier(s)
+	if err != nil {
+		return nil, errors.New("Invalid public key.")
+	}
+	return v, nil
+}
+
+// introduceKey adds the key introduced by the key asset of release, if any,
+// to the keyring of the updater.
+func (u *Updater) introduceKey(ctx context.Context, release Release) error {
+	a := findAsset(release, u.KeyAssetName)
+	if a == nil {
+		return nil
+	}
+
+	k, ok := u.Verifier.(*Keyring)
+	if !ok {
+		return fmt.Errorf("Cannot introduce key %v, the verifier is not a keyring.", a.Name())
+	}
+
+	name := a.Name() + u.signatureSuffix()
+	s := findAsset(release, name)
+	if s == nil {
+		return fmt.Errorf("No signature %v available for %v.", name, a.Name())
+	}
+
+	key := bytes.NewBuffer(nil)
+	err := u.writeAsset(ctx, a, key)
+	if err != nil {
+		return err
+	}
+
+	sig := bytes.NewBuffer(nil)
+	err = u.writeAsset(ctx, s, sig)
+	if err != nil {
+		return err
+	}
+
+	return k.Introduce(key.Bytes(), sig.Bytes())
+}
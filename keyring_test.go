@@ -0,0 +1,157 @@
+package updater
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyring(t *testing.T) {
+	oldPub, oldPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.Nil(t, err)
+	newPub, newPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.Nil(t, err)
+	otherPub, otherPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.Nil(t, err)
+
+	message := []byte("Hello World!")
+	newKey := []byte(base64.StdEncoding.EncodeToString(newPub) + "\n")
+
+	// Multiple trusted keys
+	{
+		k := NewKeyring(NewEd25519Verifier(oldPub), NewEd25519Verifier(otherPub))
+		assert.Nil(t, k.Verify(message, ed25519.Sign(oldPriv, message)))
+		assert.Nil(t, k.Verify(message, ed25519.Sign(otherPriv, message)))
+		assert.Error(t, k.Verify(message, ed25519.Sign(newPriv, message)))
+		assert.Error(t, NewKeyring().Verify(message, ed25519.Sign(oldPriv, message)))
+	}
+
+	// Introduce a key signed by a trusted key
+	{
+		k := NewKeyring(NewEd25519Verifier(oldPub))
+		err := k.Introduce(newKey, ed25519.Sign(oldPriv, newKey))
+		assert.Nil(t, err, "Could not introduce key: %v", err)
+		assert.Nil(t, k.Verify(message, ed25519.Sign(newPriv, message)))
+		assert.Nil(t, k.Verify(message, ed25519.Sign(oldPriv, message)))
+
+		// Again
+		err = k.Introduce(newKey, ed25519.Sign(oldPriv, newKey))
+		assert.Nil(t, err)
+		assert.Len(t, k.keys, 2)
+	}
+
+	// Introduce a key signed by an untrusted key
+	{
+		k := NewKeyring(NewEd25519Verifier(oldPub))
+		err := k.Introduce(newKey, ed25519.Sign(otherPriv, newKey))
+		assert.Error(t, err)
+		assert.Error(t, k.Verify(message, ed25519.Sign(newPriv, message)))
+	}
+
+	// Invalid key
+	{
+		k := NewKeyring(NewEd25519Verifier(oldPub))
+		key := []byte("not a key")
+		err := k.Introduce(key, ed25519.Sign(oldPriv, key))
+		assert.Error(t, err)
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	_, err := ParsePublicKey([]byte("RWQf6LRCGA9i53mlYecO4IzT51TGPpvWucNSCh1CBM0QTaLn73Y7GFO3"))
+	assert.Nil(t, err)
+
+	_, err = ParsePublicKey([]byte("aGVsbG8="))
+	assert.Error(t, err)
+}
+
+func TestUpdaterKeyRotation(t *testing.T) {
+	oldPub, oldPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.Nil(t, err)
+	newPub, newPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.Nil(t, err)
+
+	data := []byte("Hello World!")
+	newKey := []byte(base64.StdEncoding.EncodeToString(newPub))
+	newAsset := func(name string, contents []byte) *testAsset {
+		return &testAsset{
+			name: name,
+			write: func(w io.Writer) error {
+				_, err := w.Write(contents)
+				return err
+			},
+		}
+	}
+	newUpdater := func(b *AbortBuffer) *Updater {
+		return &Updater{
+			Verifier:     NewKeyring(NewEd25519Verifier(oldPub)),
+			KeyAssetName: "signing-key.pub",
+			WriterForAsset: func(a Asset) (AbortWriter, error) {
+				if a.Name() == "myapp" {
+					return b, nil
+				}
+				return nil, nil
+			},
+		}
+	}
+
+	// Release signed with an introduced key
+	{
+		b := NewAbortBuffer(nil)
+		r := &testRelease{assets: []Asset{
+			newAsset("myapp", data),
+			newAsset("myapp.sig", ed25519.Sign(newPriv, data)),
+			newAsset("signing-key.pub", newKey),
+			newAsset("signing-key.pub.sig", ed25519.Sign(oldPriv, newKey)),
+		}}
+		err := newUpdater(b).UpdateTo(r)
+		assert.Nil(t, err, "Could not update: %v", err)
+		assert.False(t, b.aborted)
+	}
+
+	// Key introduced by itself
+	{
+		b := NewAbortBuffer(nil)
+		r := &testRelease{assets: []Asset{
+			newAsset("myapp", data),
+			newAsset("myapp.sig", ed25519.Sign(newPriv, data)),
+			newAsset("signing-key.pub", newKey),
+			newAsset("signing-key.pub.sig", ed25519.Sign(newPriv, newKey)),
+		}}
+		err := newUpdater(b).UpdateTo(r)
+		assert.Error(t, err)
+	}
+
+	// Missing key signature
+	{
+		b := NewAbortBuffer(nil)
+		r := &testRelease{assets: []Asset{
+			newAsset("myapp", data),
+			newAsset("myapp.sig", ed25519.Sign(newPriv, data)),
+			newAsset("signing-key.pub", newKey),
+		}}
+		err := newUpdater(b).UpdateTo(r)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "No signature")
+	}
+
+	// Verifier without keyring
+	{
+		b := NewAbortBuffer(nil)
+		u := newUpdater(b)
+		u.Verifier = NewEd25519Verifier(oldPub)
+		r := &testRelease{assets: []Asset{
+			newAsset("myapp", data),
+			newAsset("myapp.sig", ed25519.Sign(oldPriv, data)),
+			newAsset("signing-key.pub", newKey),
+			newAsset("signing-key.pub.sig", ed25519.Sign(oldPriv, newKey)),
+		}}
+		err := u.UpdateTo(r)
+		assert.Error(t, err)
+	}
+}
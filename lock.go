@@ -0,0 +1,184 @@
+package updater
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// lockFileName is the name of the lock file in directories that are updated,
+// like the directory of a DirInstaller.
+const lockFileName = ".update.lock"
+
+// errLocked is returned by tryLock if another process holds the lock.
+var errLocked = errors.New("The file is locked.")
+
+// fileLock is an advisory lock on a file, which is held by at most one
+// process at a time.
+type fileLock struct {
+	f    *os.File
+	path string
+}
+
+// lockFile locks the file at path, which is created if needed.
+//
+// If the file cannot be created, e.g. because the current user cannot write to
+// its directory or the directory does not exist, a file in a directory private to the current user is locked
+// instead, see lockFallbackDir. ErrUpdateInProgress is returned if another
+// process holds the lock.
+func lockFile(path string) (*fileLock, error) {
+	l, err := lockPath(path)
+	if os.IsPermission(err) || os.IsNotExist(err) {
+		dir, derr := lockFallbackDir()
+		if derr != nil {
+			return nil, derr
+		}
+		sum := sha256.Sum256([]byte(path))
+		l, err = lockPath(filepath.Join(dir, hex.EncodeToString(sum[:8])+".lock"))
+	}
+	return l, err
+}
+
+// lockPath locks the file at path, which is created if needed.
+func lockPath(path string) (*fileLock, error) {
+	for {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, err
+		}
+
+		err = tryLock(f)
+		if err != nil {
+			f.Close()
+			if err == errLocked {
+				return nil, ErrUpdateInProgress
+			}
+			return nil, err
+		}
+
+		// The previous holder removes the file before it releases the lock,
+		// so the lock is only valid if the file is still in place
+		info, ferr := f.Stat()
+		pinfo, perr := os.Stat(path)
+		if ferr == nil && perr == nil && os.SameFile(info, pinfo) {
+			return &fileLock{f: f, path: path}, nil
+		}
+		unlockFile(f)
+		f.Close()
+	}
+}
+
+// unlock removes the lock file and releases the lock.
+func (l *fileLock) unlock() {
+	os.Remove(l.path)
+	unlockFile(l.f)
+	l.f.Close()
+}
+
+// lockFallbackDir returns a directory that only the current user can write
+// to, for lock files that cannot be created next to the files they protect.
+//
+// It is a directory in the user cache directory, or a directory in the
+// temporary directory named after the user ID when there is none. The latter
+// is created exclusively, and must be owned by the current user and not be
+// accessible to others, so that other users cannot hold the lock.
+func lockFallbackDir() (string, error) {
+	if dir, err := os.UserCacheDir(); err == nil {
+		dir = filepath.Join(dir, "go-updater")
+		if err := os.MkdirAll(dir, 0700); err == nil {
+			return dir, nil
+		}
+	}
+
+	dir := filepath.Join(os.TempDir(), fmt.Sprintf("go-updater-%d", os.Getuid()))
+	err := os.Mkdir(dir, 0700)
+	if os.IsExist(err) {
+		err = checkPrivateDir(dir)
+	}
+	if err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// lock locks the lock file of an update to a destination, see LockFile, and
+// returns the function that releases it.
+//
+// The lock file of the updater takes precedence over path. Nothing is locked
+// if both are empty.
+func (u *Updater) lock(path string) (func(), error) {
+	if u.LockFile != "" {
+		path = u.LockFile
+	}
+	if path == "" {
+		return func() {}, nil
+	}
+
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		u.logf("Could not create directory of lock file %v: %v", path, err)
+		return nil, err
+	}
+	l, err := lockFile(path)
+	if err != nil {
+		u.logf("Could not lock %v: %v", path, err)
+		return nil, err
+	}
+	return l.unlock, nil
+}
+
+// destinationLocks locks the destinations of the DelayedFile writers of an
+// update, when the updater has no LockFile. A destination is locked with a
+// file next to it with the .lock extension, like the executable of
+// SelfUpdate.
+type destinationLocks struct {
+	u     *Updater
+	mutex sync.Mutex
+	locks map[string]*fileLock
+}
+
+// writerFor returns a function that returns the writers of writerFor, after
+// locking their destinations.
+func (d *destinationLocks) writerFor(writerFor func(Asset) (AbortWriter, error)) func(Asset) (AbortWriter, error) {
+	return func(a Asset) (AbortWriter, error) {
+		w, err := writerFor(a)
+		if f, ok := w.(*DelayedFile); ok && err == nil && f.path != "" {
+			err = d.lock(f.path + ".lock")
+		}
+		return w, err
+	}
+}
+
+// lock locks the file at path, unless it is locked already.
+func (d *destinationLocks) lock(path string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if _, ok := d.locks[path]; ok {
+		return nil
+	}
+
+	l, err := lockFile(path)
+	if err != nil {
+		d.u.logf("Could not lock %v: %v", path, err)
+		return err
+	}
+	if d.locks == nil {
+		d.locks = make(map[string]*fileLock)
+	}
+	d.locks[path] = l
+	return nil
+}
+
+// unlock releases all locks.
+func (d *destinationLocks) unlock() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	for _, l := range d.locks {
+		l.unlock()
+	}
+	d.locks = nil
+}
@@ -0,0 +1,34 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !windows
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!windows
+
+package updater
+
+import (
+	"fmt"
+	"os"
+)
+
+// fileLocking is whether files can be locked on this platform.
+const fileLocking = false
+
+// tryLock does nothing, files cannot be locked on this platform.
+func tryLock(f *os.File) error {
+	return nil
+}
+
+// unlockFile does nothing, files cannot be locked on this platform.
+func unlockFile(f *os.File) error {
+	return nil
+}
+
+// checkPrivateDir checks that dir is a directory. Owners are not checked on
+// this platform.
+func checkPrivateDir(dir string) error {
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	} else if !info.IsDir() {
+		return fmt.Errorf("%v is not a directory.", dir)
+	}
+	return nil
+}
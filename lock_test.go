@@ -0,0 +1,174 @@
+package updater
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockFile(t *testing.T) {
+	if !fileLocking {
+		t.Skip("Files cannot be locked on this platform.")
+	}
+
+	dir, err := ioutil.TempDir("", "testing-")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "myapp.lock")
+
+	// Locked once
+	{
+		l, err := lockFile(path)
+		require.Nil(t, err, "Could not lock: %v", err)
+
+		_, err = lockFile(path)
+		assert.Equal(t, ErrUpdateInProgress, err)
+
+		l.unlock()
+		l, err = lockFile(path)
+		require.Nil(t, err, "Could not lock again: %v", err)
+		l.unlock()
+	}
+
+	// Update refused while locked
+	{
+		written := false
+		u := &Updater{
+			LockFile: path,
+			WriterForAsset: func(Asset) (AbortWriter, error) {
+				written = true
+				return NewAbortBuffer(nil), nil
+			},
+		}
+		release := &testRelease{name: "v2", assets: []Asset{&testAsset{name: "myapp"}}}
+
+		l, err := lockFile(path)
+		require.Nil(t, err)
+		err = u.UpdateTo(release)
+		assert.Equal(t, ErrUpdateInProgress, err)
+		assert.False(t, written)
+
+		l.unlock()
+		err = u.UpdateTo(release)
+		assert.Nil(t, err, "Could not update: %v", err)
+		assert.True(t, written)
+	}
+
+	// Directory installs locked in their directory
+	{
+		inst := &DirInstaller{Dir: filepath.Join(dir, "app")}
+		u := &Updater{DirInstaller: inst}
+		require.Nil(t, os.Mkdir(inst.Dir, 0755))
+
+		l, err := lockFile(filepath.Join(inst.Dir, lockFileName))
+		require.Nil(t, err)
+		defer l.unlock()
+		err = u.UpdateTo(&testRelease{name: "v2"})
+		assert.Equal(t, ErrUpdateInProgress, err)
+	}
+
+	// Delayed files locked next to their destination
+	{
+		dst := filepath.Join(dir, "myapp")
+		var f *DelayedFile
+		u := &Updater{
+			WriterForAsset: func(Asset) (AbortWriter, error) {
+				f = NewDelayedFile(dst)
+				return f, nil
+			},
+		}
+		asset := &testAsset{
+			name: "myapp",
+			write: func(w io.Writer) error {
+				_, err := w.Write([]byte("new"))
+				return err
+			},
+		}
+		release := &testRelease{name: "v2", assets: []Asset{asset}}
+
+		l, err := lockFile(dst + ".lock")
+		require.Nil(t, err)
+		err = u.UpdateTo(release)
+		assert.Equal(t, ErrUpdateInProgress, err)
+		assert.True(t, f.aborted)
+
+		l.unlock()
+		err = u.UpdateTo(release)
+		assert.Nil(t, err, "Could not update: %v", err)
+		require.Nil(t, f.Close())
+		_, err = os.Stat(dst + ".lock")
+		assert.True(t, os.IsNotExist(err))
+	}
+
+	// Lock file in a directory that cannot be created
+	{
+		u := &Updater{LockFile: filepath.Join(dir, "myapp", "sub", "myapp.lock")}
+		err := u.UpdateTo(&testRelease{name: "v2"})
+		assert.Error(t, err)
+		assert.NotEqual(t, ErrUpdateInProgress, err)
+	}
+}
+
+func TestLockFileRemoved(t *testing.T) {
+	if !fileLocking || runtime.GOOS == "windows" {
+		t.Skip("Lock files cannot be removed while they are locked on this platform.")
+	}
+
+	dir, err := ioutil.TempDir("", "testing-")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "myapp.lock")
+
+	l, err := lockFile(path)
+	require.Nil(t, err, "Could not lock: %v", err)
+	l.unlock()
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+
+	// A lock on a file that was removed meanwhile is not valid
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	require.Nil(t, err)
+	defer f.Close()
+	require.Nil(t, os.Remove(path))
+	require.Nil(t, tryLock(f))
+	l, err = lockFile(path)
+	require.Nil(t, err, "Could not lock: %v", err)
+	assert.NotEqual(t, f, l.f)
+	l.unlock()
+}
+
+func TestLockFallbackDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("The user cache directory always exists on Windows.")
+	}
+
+	dir, err := ioutil.TempDir("", "testing-")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, env := range []string{"HOME", "XDG_CACHE_HOME", "TMPDIR"} {
+		defer os.Setenv(env, os.Getenv(env))
+	}
+	os.Setenv("XDG_CACHE_HOME", "")
+	os.Setenv("HOME", "")
+	os.Setenv("TMPDIR", dir)
+
+	// Created for the current user
+	fallback, err := lockFallbackDir()
+	require.Nil(t, err, "Could not create fallback directory: %v", err)
+	assert.Equal(t, dir, filepath.Dir(fallback))
+	info, err := os.Stat(fallback)
+	require.Nil(t, err)
+	assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+
+	// Rejected when others can access it
+	require.Nil(t, os.Chmod(fallback, 0777))
+	_, err = lockFallbackDir()
+	assert.Error(t, err)
+}
@@ -0,0 +1,41 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package updater
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// fileLocking is whether files can be locked on this platform.
+const fileLocking = true
+
+// tryLock locks f with flock, without waiting for other processes.
+func tryLock(f *os.File) error {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		return errLocked
+	}
+	return err
+}
+
+// unlockFile releases the lock on f.
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
+
+// checkPrivateDir checks that dir is a directory owned by the current user,
+// that other users cannot access.
+func checkPrivateDir(dir string) error {
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !info.IsDir() || !ok || int(st.Uid) != os.Getuid() || info.Mode().Perm()&0077 != 0 {
+		return fmt.Errorf("Directory %v is not private to the current user.", dir)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package updater
+
+import (
+	"context"
+	"net/url"
+)
+
+// Logger receives debug messages about checking for and applying updates,
+// e.g. the URLs that are downloaded, the number of bytes written and retries.
+//
+// A *log.Logger can be used as a Logger. Use LoggerFunc to log to another
+// logging package.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// LoggerFunc is a function that can be used as a Logger, e.g. to log debug
+// messages with a *slog.Logger:
+//
+//	LoggerFunc(func(format string, v ...interface{}) {
+//		logger.Debug(fmt.Sprintf(format, v...))
+//	})
+type LoggerFunc func(format string, v ...interface{})
+
+// Printf calls f.
+func (f LoggerFunc) Printf(format string, v ...interface{}) {
+	f(format, v...)
+}
+
+// loggerKey is the context key of the logger.
+type loggerKey struct{}
+
+// withLogger returns a context in which debug messages are logged to logger.
+func withLogger(ctx context.Context, logger Logger) context.Context {
+	if logger == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// logf logs a debug message to the logger of ctx, if there is one.
+func logf(ctx context.Context, format string, v ...interface{}) {
+	if l, ok := ctx.Value(loggerKey{}).(Logger); ok {
+		l.Printf(format, v...)
+	}
+}
+
+// logf logs a debug message to the logger of the updater, if there is one.
+func (u *Updater) logf(format string, v ...interface{}) {
+	if u.Logger != nil {
+		u.Logger.Printf(format, v...)
+	}
+}
+
+// redactURL returns u without credentials and query parameters, which may
+// contain secrets like signatures or tokens.
+func redactURL(u *url.URL) string {
+	r := *u
+	r.User = nil
+	if r.RawQuery != "" {
+		r.RawQuery = "..."
+	}
+	return r.String()
+}
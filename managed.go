@@ -0,0 +1,186 @@
+package updater
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// PackageManager is a package manager that can own the installation of an
+// application.
+type PackageManager string
+
+// Package managers detected by DetectPackageManager.
+const (
+	Dpkg     PackageManager = "dpkg"
+	RPM      PackageManager = "rpm"
+	Homebrew PackageManager = "homebrew"
+	Snap     PackageManager = "snap"
+	Flatpak  PackageManager = "flatpak"
+)
+
+// UpgradeCommand returns the command users run to upgrade applications
+// installed by the package manager, e.g. "sudo apt upgrade".
+func (m PackageManager) UpgradeCommand() string {
+	switch m {
+	case Dpkg:
+		return "sudo apt upgrade"
+	case RPM:
+		return "sudo dnf upgrade"
+	case Homebrew:
+		return "brew upgrade"
+	case Snap:
+		return "sudo snap refresh"
+	case Flatpak:
+		return "flatpak update"
+	}
+	return ""
+}
+
+// ErrExternallyManaged is returned when the application was installed by a
+// package manager, and should be updated with that package manager instead of
+// overwriting its files.
+//
+// It is only returned when DetectPackageManager of the updater is set.
+type ErrExternallyManaged struct {
+	// Package manager that installed the application.
+	Manager PackageManager
+
+	// Path of the executable of the application.
+	Path string
+}
+
+func (e *ErrExternallyManaged) Error() string {
+	if cmd := e.Manager.UpgradeCommand(); cmd != "" {
+		return fmt.Sprintf("%v is managed by %v, update it with %v.", e.Path, e.Manager, cmd)
+	}
+	return fmt.Sprintf("%v is managed by %v.", e.Path, e.Manager)
+}
+
+var (
+	// Directory with the file lists of the installed deb packages.
+	dpkgInfoDir = "/var/lib/dpkg/info"
+
+	// File present in every Flatpak sandbox.
+	flatpakInfoPath = "/.flatpak-info"
+
+	// Command used to query the owner of a file in the rpm database.
+	rpmCommand = "rpm"
+)
+
+// DetectPackageManager returns the package manager that installed the
+// executable at path, or an empty string if the executable is not managed by
+// a package manager.
+//
+// Snap and Flatpak sandboxes are detected from the environment, Homebrew from
+// the Cellar in the path of the executable. On Linux, executables installed in
+// system directories are looked up in the dpkg and rpm databases.
+func DetectPackageManager(path string) PackageManager {
+	if os.Getenv("SNAP") != "" || strings.HasPrefix(path, "/snap/") {
+		return Snap
+	}
+	if os.Getenv("FLATPAK_ID") != "" || fileExists(flatpakInfoPath) {
+		return Flatpak
+	}
+
+	slashed := filepath.ToSlash(path)
+	if strings.Contains(slashed, "/Cellar/") || strings.Contains(slashed, "/Caskroom/") {
+		return Homebrew
+	}
+
+	if runtime.GOOS != "linux" || !systemPath(path) {
+		return ""
+	}
+	if dpkgOwns(path) {
+		return Dpkg
+	}
+	if rpmOwns(path) {
+		return RPM
+	}
+	return ""
+}
+
+// checkManaged returns an ErrExternallyManaged if DetectPackageManager is set
+// and the running executable is managed by a package manager.
+func (u *Updater) checkManaged() error {
+	if !u.DetectPackageManager {
+		return nil
+	}
+
+	exe, err := osExecutable()
+	if err != nil {
+		return err
+	}
+	exe, err = filepath.EvalSymlinks(exe)
+	if err != nil {
+		return err
+	}
+
+	if m := DetectPackageManager(exe); m != "" {
+		u.logf("%v is managed by %v", exe, m)
+		return &ErrExternallyManaged{Manager: m, Path: exe}
+	}
+	return nil
+}
+
+// systemPath returns true if path is in a directory where package managers
+// install files.
+func systemPath(path string) bool {
+	for _, dir := range []string{"/bin/", "/sbin/", "/usr/", "/lib/", "/lib64/", "/opt/"} {
+		if strings.HasPrefix(path, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// dpkgOwns returns true if path is listed in the files of an installed deb
+// package.
+func dpkgOwns(path string) bool {
+	lists, err := filepath.Glob(filepath.Join(dpkgInfoDir, "*.list"))
+	if err != nil {
+		return false
+	}
+	for _, list := range lists {
+		if listContains(list, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// listContains returns true if the file at list has a line equal to path.
+func listContains(list, path string) bool {
+	f, err := os.Open(list)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if s.Text() == path {
+			return true
+		}
+	}
+	return false
+}
+
+// rpmOwns returns true if path belongs to an installed rpm package.
+func rpmOwns(path string) bool {
+	cmd, err := exec.LookPath(rpmCommand)
+	if err != nil {
+		return false
+	}
+	return exec.Command(cmd, "-qf", "--quiet", path).Run() == nil
+}
+
+// fileExists returns true if a file exists at path.
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
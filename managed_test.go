@@ -0,0 +1,133 @@
+package updater
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectPackageManager(t *testing.T) {
+	dir, err := ioutil.TempDir("", "managed-")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	defer func(snap, flatpak string) {
+		os.Setenv("SNAP", snap)
+		os.Setenv("FLATPAK_ID", flatpak)
+	}(os.Getenv("SNAP"), os.Getenv("FLATPAK_ID"))
+	os.Unsetenv("SNAP")
+	os.Unsetenv("FLATPAK_ID")
+
+	defer func(path string) { flatpakInfoPath = path }(flatpakInfoPath)
+	flatpakInfoPath = filepath.Join(dir, ".flatpak-info")
+
+	// Not managed
+	{
+		assert.Equal(t, PackageManager(""), DetectPackageManager(filepath.Join(dir, "myapp")))
+	}
+
+	// Homebrew
+	{
+		exe := "/usr/local/Cellar/myapp/1.0.0/bin/myapp"
+		assert.Equal(t, Homebrew, DetectPackageManager(exe))
+	}
+
+	// Snap
+	{
+		assert.Equal(t, Snap, DetectPackageManager("/snap/myapp/12/bin/myapp"))
+
+		os.Setenv("SNAP", "/snap/myapp/12")
+		assert.Equal(t, Snap, DetectPackageManager(filepath.Join(dir, "myapp")))
+		os.Unsetenv("SNAP")
+	}
+
+	// Flatpak
+	{
+		os.Setenv("FLATPAK_ID", "com.example.MyApp")
+		assert.Equal(t, Flatpak, DetectPackageManager("/app/bin/myapp"))
+		os.Unsetenv("FLATPAK_ID")
+
+		require.Nil(t, ioutil.WriteFile(flatpakInfoPath, nil, 0644))
+		assert.Equal(t, Flatpak, DetectPackageManager("/app/bin/myapp"))
+		os.Remove(flatpakInfoPath)
+	}
+
+	// Deb packages
+	{
+		defer func(dir string) { dpkgInfoDir = dir }(dpkgInfoDir)
+		dpkgInfoDir = dir
+
+		list := "/.\n/usr\n/usr/bin\n/usr/bin/myapp\n"
+		require.Nil(t, ioutil.WriteFile(filepath.Join(dir, "myapp.list"), []byte(list), 0644))
+		assert.True(t, dpkgOwns("/usr/bin/myapp"))
+		assert.False(t, dpkgOwns("/usr/bin/other"))
+		assert.False(t, dpkgOwns("/usr/bin/my"))
+	}
+
+	// System paths
+	{
+		assert.True(t, systemPath("/usr/bin/myapp"))
+		assert.True(t, systemPath("/opt/myapp/myapp"))
+		assert.False(t, systemPath("/home/user/bin/myapp"))
+		assert.False(t, systemPath("/usrlocal/myapp"))
+	}
+}
+
+func TestUpdaterDetectPackageManager(t *testing.T) {
+	dir, err := ioutil.TempDir("", "managed-")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// Fake executable installed by Homebrew
+	exe := filepath.Join(dir, "Cellar", "myapp", "1.0.0", "bin", "myapp")
+	require.Nil(t, os.MkdirAll(filepath.Dir(exe), 0755))
+	require.Nil(t, ioutil.WriteFile(exe, nil, 0755))
+	exe, err = filepath.EvalSymlinks(exe)
+	require.Nil(t, err)
+
+	defer func() { osExecutable = os.Executable }()
+	osExecutable = func() (string, error) { return exe, nil }
+
+	queried := false
+	app := &testApp{
+		FQuery: func() error {
+			queried = true
+			return nil
+		},
+		FLatestRelease: func() Release {
+			return &testRelease{identifier: "2"}
+		},
+	}
+
+	// Refuse to update a managed installation
+	{
+		u := &Updater{App: app, CurrentReleaseIdentifier: "1", DetectPackageManager: true}
+
+		_, err := u.Check()
+		var managed *ErrExternallyManaged
+		if assert.True(t, errors.As(err, &managed)) {
+			assert.Equal(t, Homebrew, managed.Manager)
+			assert.Equal(t, exe, managed.Path)
+			assert.Contains(t, err.Error(), "brew upgrade")
+		}
+		assert.False(t, queried)
+
+		err = u.UpdateTo(&testRelease{identifier: "2"})
+		assert.True(t, errors.As(err, &managed))
+	}
+
+	// Detection is disabled by default
+	{
+		u := &Updater{App: app, CurrentReleaseIdentifier: "1"}
+
+		r, err := u.Check()
+		assert.Nil(t, err)
+		assert.NotNil(t, r)
+		assert.True(t, queried)
+	}
+}
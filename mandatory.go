@@ -0,0 +1,32 @@
+package updater
+
+import "strings"
+
+// Token that marks a release as mandatory in its release notes.
+const MandatoryToken = "[mandatory]"
+
+// IsMandatory reports whether release must be applied, e.g. because it fixes
+// a critical security issue.
+//
+// Releases that implement MandatoryRelease decide for themselves. Other
+// releases are mandatory if their release notes contain MandatoryToken, in
+// any case, so that releases of e.g. GitHub can be marked as mandatory by
+// adding [mandatory] to their description.
+//
+// Applications can use it to apply an update without asking the user.
+// Mandatory releases cannot be skipped with SkipRelease.
+func IsMandatory(release Release) bool {
+	if release == nil {
+		return false
+	}
+
+	if m, ok := release.(MandatoryRelease); ok {
+		return m.Mandatory()
+	}
+	return hasMandatoryToken(release.Information())
+}
+
+// hasMandatoryToken reports whether the release notes contain MandatoryToken.
+func hasMandatoryToken(notes string) bool {
+	return strings.Contains(strings.ToLower(notes), MandatoryToken)
+}
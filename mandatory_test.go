@@ -0,0 +1,42 @@
+package updater
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsMandatory(t *testing.T) {
+	assert.False(t, IsMandatory(nil))
+	assert.False(t, IsMandatory(&testRelease{information: "Bug fixes."}))
+	assert.True(t, IsMandatory(&testRelease{information: "Security fix.\n\n[Mandatory]"}))
+
+	// Manifest field
+	assert.True(t, IsMandatory(&manifestRelease{Manifest: Manifest{Mandatory: true}}))
+	assert.False(t, IsMandatory(&manifestRelease{Manifest: Manifest{Notes: "Bug fixes."}}))
+	assert.True(t, IsMandatory(&manifestRelease{Manifest: Manifest{Notes: "[mandatory]"}}))
+}
+
+func TestUpdaterSkipMandatory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testing-")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	r := &testRelease{name: "v2", identifier: "2", information: "[mandatory]"}
+	u := &Updater{
+		App:                      &testApp{FLatestRelease: func() Release { return r }},
+		CurrentReleaseIdentifier: "1",
+		State:                    NewFileStateStore(filepath.Join(dir, "state.json")),
+	}
+
+	err = u.SkipRelease(r)
+	assert.Nil(t, err, "Could not skip: %v", err)
+
+	latest, err := u.Check()
+	assert.Nil(t, err)
+	assert.Equal(t, r, latest)
+}
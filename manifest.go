@@ -0,0 +1,302 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// Manifest describes the latest release of an application hosted on a plain
+// HTTP server.
+//
+// A manifest is a JSON document like:
+//
+//	{
+//		"version": "v1.2.0",
+//		"notes": "Bug fixes and improvements.",
+//		"identifier": "789611aec3d4b90512577b5dad9cf1adb6b20dcc",
+//		"mandatory": false,
+//		"rollout": 25,
+//		"assets": [
+//			{
+//				"name": "myapp_linux_amd64",
+//				"url": "myapp_linux_amd64",
+//				"mirrors": ["https://cdn.example.com/myapp_linux_amd64"],
+//				"sha256": "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"
+//			}
+//		]
+//	}
+//
+// Asset URLs may be relative to the URL of the manifest.
+type Manifest struct {
+	// Version name of the release.
+	Version string `json:"version"`
+
+	// Human-readable release notes.
+	Notes string `json:"notes,omitempty"`
+
+	// Identifier of the release. Defaults to the version.
+	Identifier string `json:"identifier,omitempty"`
+
+	// Whether the release must be applied, e.g. because it fixes a critical
+	// security issue.
+	Mandatory bool `json:"mandatory,omitempty"`
+
+	// Percentage of the clients that should update to the release, see
+	// RolloutPercentage. The release is rolled out to all clients if it is
+	// not set.
+	Rollout *int `json:"rollout,omitempty"`
+
+	// Oldest version of the application that can update to the release, see
+	// UpgradeRequirement.
+	MinimumVersion string `json:"minimum_version,omitempty"`
+
+	// Version name of the release that versions older than MinimumVersion
+	// must update to first.
+	UpgradeVia string `json:"upgrade_via,omitempty"`
+
+	// Assets attached to the release.
+	Assets []ManifestAsset `json:"assets"`
+}
+
+// ManifestAsset describes a downloadable asset in a manifest.
+type ManifestAsset struct {
+	// File name of the asset.
+	Name string `json:"name"`
+
+	// URL of the asset, absolute or relative to the manifest URL.
+	URL string `json:"url"`
+
+	// URLs of mirrors of the asset, absolute or relative to the manifest URL.
+	// They are tried in order when the asset cannot be downloaded from URL.
+	Mirrors []string `json:"mirrors,omitempty"`
+
+	// Hexadecimal SHA-256 checksum of the asset, optional.
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+type manifestApp struct {
+	url    string
+	client *http.Client
+	latest Release
+
+	// Options of a signed manifest, nil for plain manifests.
+	signed *SignedManifestOptions
+
+	// Validator of the manifest of the last successful query.
+	validator cacheValidator
+}
+
+type manifestRelease struct {
+	Manifest Manifest
+
+	assets []Asset
+}
+
+type manifestAsset struct {
+	Asset ManifestAsset
+
+	url     string
+	mirrors []string
+	client  *http.Client
+}
+
+// NewHTTPManifest creates an Application whose latest release is described by
+// a manifest at the given URL. See Manifest for the format.
+//
+// Set client to nil to use the HTTPClient of the Updater, or the default HTTP
+// client.
+//
+// Assets with a checksum in the manifest are verified while they are written.
+// Repeated queries are conditional requests, so an unchanged manifest is not
+// downloaded again when the server supports ETag or Last-Modified.
+func NewHTTPManifest(url string, client *http.Client) App {
+	return &manifestApp{
+		url:    url,
+		client: client,
+	}
+}
+
+func (app *manifestApp) Query() error {
+	return app.QueryContext(context.Background())
+}
+
+func (app *manifestApp) QueryContext(ctx context.Context) error {
+	base, err := url.Parse(app.url)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("GET", app.url, nil)
+	if err != nil {
+		return err
+	}
+	if app.latest != nil {
+		app.validator.apply(req)
+	}
+
+	// Keep the latest release if the manifest did not change
+	buf := bytes.NewBuffer(nil)
+	resp, err := downloadResponse(ctx, app.client, req, buf)
+	if resp != nil && resp.StatusCode == http.StatusNotModified {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if app.signed != nil {
+		r, err := app.parseSigned(buf.Bytes(), base)
+		if err != nil {
+			return err
+		}
+		app.latest = r
+		app.validator = newCacheValidator(resp)
+		return nil
+	}
+
+	var m Manifest
+	err = json.Unmarshal(buf.Bytes(), &m)
+	if err != nil {
+		return fmt.Errorf("Invalid manifest: %v", err)
+	}
+
+	r, err := newManifestRelease(m, base, app.client)
+	if err != nil {
+		return err
+	}
+	app.latest = r
+	app.validator = newCacheValidator(resp)
+
+	return nil
+}
+
+func (app *manifestApp) LatestRelease() Release {
+	return app.latest
+}
+
+func newManifestRelease(m Manifest, base *url.URL, client *http.Client) (*manifestRelease, error) {
+	s := make([]Asset, len(m.Assets))
+	for i, a := range m.Assets {
+		asset, err := newManifestAsset(a, base, client)
+		if err != nil {
+			return nil, err
+		}
+		s[i] = asset
+	}
+
+	return &manifestRelease{
+		Manifest: m,
+		assets:   s,
+	}, nil
+}
+
+// newManifestAsset returns the asset a of a manifest, whose URLs are resolved
+// against base.
+func newManifestAsset(a ManifestAsset, base *url.URL, client *http.Client) (*manifestAsset, error) {
+	u, err := base.Parse(a.URL)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid URL for asset %v: %v", a.Name, err)
+	}
+
+	mirrors := make([]string, len(a.Mirrors))
+	for j, m := range a.Mirrors {
+		mu, err := base.Parse(m)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid mirror URL for asset %v: %v", a.Name, err)
+		}
+		mirrors[j] = mu.String()
+	}
+
+	return &manifestAsset{
+		Asset:   a,
+		url:     u.String(),
+		mirrors: mirrors,
+		client:  client,
+	}, nil
+}
+
+func (r *manifestRelease) Name() string {
+	return r.Manifest.Version
+}
+
+func (r *manifestRelease) Information() string {
+	return r.Manifest.Notes
+}
+
+func (r *manifestRelease) Identifier() string {
+	if r.Manifest.Identifier != "" {
+		return r.Manifest.Identifier
+	}
+	return r.Manifest.Version
+}
+
+func (r *manifestRelease) Assets() []Asset {
+	return r.assets
+}
+
+func (r *manifestRelease) Mandatory() bool {
+	return r.Manifest.Mandatory || hasMandatoryToken(r.Manifest.Notes)
+}
+
+func (r *manifestRelease) RolloutPercentage() int {
+	return manifestRollout(r.Manifest)
+}
+
+func (r *manifestRelease) MinimumVersion() string {
+	if r.Manifest.MinimumVersion == "" {
+		minimum, _ := parseRequiresToken(r.Manifest.Notes)
+		return minimum
+	}
+	return r.Manifest.MinimumVersion
+}
+
+func (r *manifestRelease) UpgradeVia() string {
+	if r.Manifest.MinimumVersion == "" {
+		_, via := parseRequiresToken(r.Manifest.Notes)
+		return via
+	}
+	return r.Manifest.UpgradeVia
+}
+
+func (r *manifestAsset) Name() string {
+	return r.Asset.Name
+}
+
+func (r *manifestAsset) SHA256() string {
+	return r.Asset.SHA256
+}
+
+func (r *manifestAsset) Write(w io.Writer) error {
+	return r.WriteContext(context.Background(), w)
+}
+
+func (r *manifestAsset) WriteContext(ctx context.Context, w io.Writer) error {
+	if r.url == "" {
+		return errors.New("No download URL available.")
+	}
+
+	urls := append([]string{r.url}, r.mirrors...)
+	if r.Asset.SHA256 == "" {
+		return downloadFirst(ctx, r.client, urls, w)
+	}
+
+	expected, err := hex.DecodeString(r.Asset.SHA256)
+	if err != nil {
+		return fmt.Errorf("Invalid checksum for %v: %v", r.Name(), err)
+	}
+
+	h := sha256.New()
+	err = downloadFirst(ctx, r.client, urls, teeWriter(w, h))
+	if err != nil {
+		return err
+	}
+
+	return verifyChecksum(map[string][]byte{r.Name(): expected}, r, h.Sum(nil))
+}
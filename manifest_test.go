@@ -0,0 +1,121 @@
+package updater
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPManifest(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/releases/latest.json":
+			w.Write([]byte(validManifestJSON))
+		case "/releases/myapp_linux_amd64":
+			w.Write([]byte("Hello World!"))
+		case "/invalid.json":
+			w.Write([]byte("invalid json"))
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	defer ts.Close()
+
+	// Valid manifest
+	{
+		app := NewHTTPManifest(ts.URL+"/releases/latest.json", nil)
+		assert.Nil(t, app.LatestRelease())
+
+		err := app.Query()
+		assert.Nil(t, err, "Unexpected query error: %v", err)
+
+		r := app.LatestRelease()
+		require.NotNil(t, r)
+		assert.Equal(t, "v1.2.0", r.Name())
+		assert.Equal(t, "Bug fixes.", r.Information())
+		assert.Equal(t, "v1.2.0", r.Identifier())
+		require.Equal(t, 3, len(r.Assets()))
+
+		// Relative URL with valid checksum
+		buf := bytes.NewBuffer(nil)
+		err = r.Assets()[0].Write(buf)
+		assert.Nil(t, err, "Unexpected write error: %v", err)
+		assert.Equal(t, "Hello World!", buf.String())
+
+		// Invalid checksum
+		err = r.Assets()[1].Write(bytes.NewBuffer(nil))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "mismatch")
+
+		// Missing asset
+		err = r.Assets()[2].Write(bytes.NewBuffer(nil))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "Not Found")
+	}
+
+	// Invalid manifest
+	{
+		app := NewHTTPManifest(ts.URL+"/invalid.json", nil)
+		err := app.Query()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "Invalid manifest")
+	}
+
+	// Missing manifest
+	{
+		app := NewHTTPManifest(ts.URL+"/missing.json", nil)
+		err := app.Query()
+		assert.Error(t, err)
+	}
+}
+
+func TestHTTPManifestConditional(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		lastModified := "Sat, 09 Apr 2016 12:00:00 GMT"
+		if r.Header.Get("If-Modified-Since") == lastModified {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Last-Modified", lastModified)
+		w.Write([]byte(validManifestJSON))
+	}))
+	defer ts.Close()
+
+	app := NewHTTPManifest(ts.URL+"/releases/latest.json", nil)
+	for i := 0; i < 2; i++ {
+		err := app.Query()
+		assert.Nil(t, err, "Unexpected query error: %v", err)
+		require.NotNil(t, app.LatestRelease())
+		assert.Equal(t, "v1.2.0", app.LatestRelease().Name())
+	}
+	assert.Equal(t, 2, requests)
+}
+
+var validManifestJSON = `
+{
+  "version": "v1.2.0",
+  "notes": "Bug fixes.",
+  "assets": [
+    {
+      "name": "myapp_linux_amd64",
+      "url": "myapp_linux_amd64",
+      "sha256": "7f83b1657ff1fc53b92dc18148a1d65dfc2d4b1fa3d677284addd200126d9069"
+    },
+    {
+      "name": "myapp_darwin_amd64",
+      "url": "/releases/myapp_linux_amd64",
+      "sha256": "0000000000000000000000000000000000000000000000000000000000000000"
+    },
+    {
+      "name": "myapp_windows_amd64.exe",
+      "url": "myapp_windows_amd64.exe"
+    }
+  ]
+}
+`
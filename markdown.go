@@ -0,0 +1,337 @@
+package updater
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// InformationHTML returns the information of release as HTML, e.g. to
+// display the release notes in a graphical user interface.
+//
+// The information of a FormattedRelease is rendered by the release, other
+// information is treated as plain text: it is escaped, and blank lines
+// separate paragraphs.
+func InformationHTML(release Release) string {
+	if release == nil {
+		return ""
+	}
+
+	if f, ok := release.(FormattedRelease); ok {
+		return f.InformationHTML()
+	}
+	return renderMarkdownBlocks(textBlocks(release.Information()), true, false)
+}
+
+// InformationText returns the information of release as plain text, without
+// any markup.
+func InformationText(release Release) string {
+	if release == nil {
+		return ""
+	}
+
+	if f, ok := release.(FormattedRelease); ok {
+		return f.InformationText()
+	}
+	return strings.TrimSpace(release.Information())
+}
+
+// MarkdownHTML renders the Markdown document s to HTML.
+//
+// Paragraphs, headings, lists, block quotes, code blocks, rules, links,
+// images and emphasis are supported. Line breaks in paragraphs are kept, like
+// in GitHub release notes. Raw HTML is escaped and only http, https, mailto and
+// relative URLs are linked, so the result is safe to display.
+func MarkdownHTML(s string) string {
+	return renderMarkdownBlocks(parseMarkdown(s), true, true)
+}
+
+// MarkdownText returns the text of the Markdown document s without its
+// markup. The URLs of links are kept after their text.
+func MarkdownText(s string) string {
+	return renderMarkdownBlocks(parseMarkdown(s), false, true)
+}
+
+// Kinds of Markdown blocks.
+const (
+	markdownParagraph = iota
+	markdownHeading
+	markdownCode
+	markdownList
+	markdownQuote
+	markdownRule
+)
+
+// markdownBlock is a block of a Markdown document.
+type markdownBlock struct {
+	kind int
+
+	// Level of a heading.
+	level int
+
+	// Whether a list is ordered.
+	ordered bool
+
+	// Lines of the block, or items of a list.
+	lines []string
+}
+
+var (
+	markdownHeadingRegexp = regexp.MustCompile(`^(#{1,6})\s+(.*?)(?:\s+#+)?$`)
+	markdownListRegexp    = regexp.MustCompile(`^([-*+]|\d+[.)])\s+(.*)$`)
+	markdownRuleRegexp    = regexp.MustCompile(`^(?:(?:-\s*){3,}|(?:\*\s*){3,}|(?:_\s*){3,})$`)
+)
+
+// parseMarkdown splits the Markdown document s into blocks.
+func parseMarkdown(s string) []markdownBlock {
+	lines := strings.Split(strings.Replace(s, "\r\n", "\n", -1), "\n")
+
+	var blocks []markdownBlock
+	var cur *markdownBlock
+	flush := func() {
+		if cur != nil {
+			blocks = append(blocks, *cur)
+			cur = nil
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+
+		if strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~") {
+			flush()
+			fence := line[:3]
+			b := markdownBlock{kind: markdownCode}
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), fence); i++ {
+				b.lines = append(b.lines, strings.TrimRight(lines[i], " \t"))
+			}
+			blocks = append(blocks, b)
+			continue
+		}
+
+		if line == "" {
+			flush()
+		} else if markdownRuleRegexp.MatchString(line) {
+			flush()
+			blocks = append(blocks, markdownBlock{kind: markdownRule})
+		} else if m := markdownHeadingRegexp.FindStringSubmatch(line); m != nil {
+			flush()
+			blocks = append(blocks, markdownBlock{
+				kind:  markdownHeading,
+				level: len(m[1]),
+				lines: []string{m[2]},
+			})
+		} else if m := markdownListRegexp.FindStringSubmatch(line); m != nil {
+			ordered := !strings.ContainsAny(m[1], "-*+")
+			if cur == nil || cur.kind != markdownList || cur.ordered != ordered {
+				flush()
+				cur = &markdownBlock{kind: markdownList, ordered: ordered}
+			}
+			cur.lines = append(cur.lines, m[2])
+		} else if strings.HasPrefix(line, ">") {
+			if cur == nil || cur.kind != markdownQuote {
+				flush()
+				cur = &markdownBlock{kind: markdownQuote}
+			}
+			cur.lines = append(cur.lines, strings.TrimSpace(strings.TrimPrefix(line, ">")))
+		} else if cur != nil && cur.kind == markdownList {
+			// Continuation of the last item
+			cur.lines[len(cur.lines)-1] += "\n" + line
+		} else if cur != nil {
+			cur.lines = append(cur.lines, line)
+		} else {
+			cur = &markdownBlock{kind: markdownParagraph, lines: []string{line}}
+		}
+	}
+	flush()
+
+	return blocks
+}
+
+// textBlocks splits the plain text s into paragraphs.
+func textBlocks(s string) []markdownBlock {
+	var blocks []markdownBlock
+	for _, p := range strings.Split(strings.Replace(s, "\r\n", "\n", -1), "\n\n") {
+		if p = strings.TrimSpace(p); p != "" {
+			blocks = append(blocks, markdownBlock{
+				kind:  markdownParagraph,
+				lines: strings.Split(p, "\n"),
+			})
+		}
+	}
+	return blocks
+}
+
+// renderMarkdownBlocks renders blocks to HTML or plain text. Inline markup is
+// only rendered if inline is true, otherwise the text is escaped as is.
+func renderMarkdownBlocks(blocks []markdownBlock, asHTML, inline bool) string {
+	render := func(s string) string {
+		if !inline && asHTML {
+			return html.EscapeString(s)
+		} else if !inline {
+			return s
+		}
+		return renderMarkdownInline(s, asHTML)
+	}
+	lines := func(s []string, sep string) string {
+		r := make([]string, len(s))
+		for i, l := range s {
+			r[i] = render(l)
+		}
+		return strings.Join(r, sep)
+	}
+
+	var out []string
+	for _, b := range blocks {
+		if !asHTML {
+			switch b.kind {
+			case markdownCode:
+				out = append(out, strings.Join(b.lines, "\n"))
+			case markdownList:
+				items := make([]string, len(b.lines))
+				for i, item := range b.lines {
+					marker := "-"
+					if b.ordered {
+						marker = fmt.Sprintf("%v.", i+1)
+					}
+					items[i] = marker + " " + render(item)
+				}
+				out = append(out, strings.Join(items, "\n"))
+			case markdownRule:
+			default:
+				out = append(out, lines(b.lines, "\n"))
+			}
+			continue
+		}
+
+		switch b.kind {
+		case markdownParagraph:
+			out = append(out, "<p>"+lines(b.lines, "<br>\n")+"</p>")
+		case markdownHeading:
+			out = append(out, fmt.Sprintf("<h%[1]v>%[2]v</h%[1]v>", b.level, render(b.lines[0])))
+		case markdownCode:
+			out = append(out, "<pre><code>"+html.EscapeString(strings.Join(b.lines, "\n"))+"</code></pre>")
+		case markdownList:
+			tag := "ul"
+			if b.ordered {
+				tag = "ol"
+			}
+			items := make([]string, len(b.lines))
+			for i, item := range b.lines {
+				items[i] = "<li>" + strings.Replace(render(item), "\n", "<br>\n", -1) + "</li>"
+			}
+			out = append(out, "<"+tag+">\n"+strings.Join(items, "\n")+"\n</"+tag+">")
+		case markdownQuote:
+			out = append(out, "<blockquote><p>"+lines(b.lines, "<br>\n")+"</p></blockquote>")
+		case markdownRule:
+			out = append(out, "<hr>")
+		}
+	}
+
+	if asHTML {
+		return strings.Join(out, "\n")
+	}
+	return strings.Join(out, "\n\n")
+}
+
+var (
+	// Code spans, links, images and URLs
+	markdownInlineRegexp = regexp.MustCompile(
+		"`[^`]+`" + `|!?\[([^\]]*)\]\(([^)\s]*)\)|<(https?://[^>\s]+)>|https?://[^\s<>()]+`,
+	)
+
+	markdownEmphasis = []struct {
+		re  *regexp.Regexp
+		tag string
+	}{
+		{regexp.MustCompile(`\*\*(\S(?:.*?\S)?)\*\*`), "strong"},
+		{regexp.MustCompile(`\b__(\S(?:.*?\S)?)__\b`), "strong"},
+		{regexp.MustCompile(`~~(\S(?:.*?\S)?)~~`), "del"},
+		{regexp.MustCompile(`\*(\S(?:.*?\S)?)\*`), "em"},
+		{regexp.MustCompile(`\b_(\S(?:.*?\S)?)_\b`), "em"},
+	}
+)
+
+// renderMarkdownInline renders the inline markup of s to HTML or plain text.
+func renderMarkdownInline(s string, asHTML bool) string {
+	var b strings.Builder
+	last := 0
+	for _, m := range markdownInlineRegexp.FindAllStringSubmatchIndex(s, -1) {
+		if m[0] < last {
+			continue
+		}
+		b.WriteString(renderMarkdownEmphasis(s[last:m[0]], asHTML))
+		token := s[m[0]:m[1]]
+		last = m[1]
+
+		switch {
+		case token[0] == '`':
+			code := strings.Trim(token, "`")
+			if asHTML {
+				code = "<code>" + html.EscapeString(code) + "</code>"
+			}
+			b.WriteString(code)
+		case m[2] >= 0:
+			label, u := s[m[2]:m[3]], s[m[4]:m[5]]
+			b.WriteString(renderMarkdownLink(label, u, token[0] == '!', asHTML))
+		case m[6] >= 0:
+			u := s[m[6]:m[7]]
+			b.WriteString(renderMarkdownLink(u, u, false, asHTML))
+		default:
+			// Trailing punctuation is not part of a URL
+			u := strings.TrimRight(token, ".,;:!?'\"")
+			last = m[0] + len(u)
+			b.WriteString(renderMarkdownLink(u, u, false, asHTML))
+		}
+	}
+	b.WriteString(renderMarkdownEmphasis(s[last:], asHTML))
+	return b.String()
+}
+
+// renderMarkdownLink renders a link or image to HTML or plain text. Links with
+// unsafe URLs are rendered as their label.
+func renderMarkdownLink(label, u string, image, asHTML bool) string {
+	if !asHTML {
+		if image || u == label || u == "" {
+			return label
+		}
+		return renderMarkdownEmphasis(label, false) + " (" + u + ")"
+	}
+
+	if !safeURL(u) {
+		return renderMarkdownEmphasis(label, true)
+	} else if image {
+		return fmt.Sprintf(`<img src="%v" alt="%v">`, html.EscapeString(u), html.EscapeString(label))
+	}
+	return fmt.Sprintf(`<a href="%v">%v</a>`, html.EscapeString(u), renderMarkdownEmphasis(label, true))
+}
+
+// renderMarkdownEmphasis renders the emphasis in s to HTML or plain text.
+func renderMarkdownEmphasis(s string, asHTML bool) string {
+	if asHTML {
+		s = html.EscapeString(s)
+	}
+	for _, e := range markdownEmphasis {
+		repl := "${1}"
+		if asHTML {
+			repl = "<" + e.tag + ">${1}</" + e.tag + ">"
+		}
+		s = e.re.ReplaceAllString(s, repl)
+	}
+	return s
+}
+
+// safeURL returns true if u is an http, https, mailto or relative URL.
+func safeURL(u string) bool {
+	p, err := url.Parse(u)
+	if err != nil {
+		return false
+	}
+	switch p.Scheme {
+	case "", "http", "https", "mailto":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package updater
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarkdownHTML(t *testing.T) {
+	// Blocks
+	{
+		md := "## What's new\n\n" +
+			"Faster downloads\nand fewer bugs.\n\n" +
+			"- Added **parallel** downloads\n" +
+			"- Fixed `Check` on Windows\n  when offline\n\n" +
+			"1. Download\n2. Install\n\n" +
+			"> Note: restart after updating\n\n" +
+			"---\n\n" +
+			"```go\nif a < b {\n}\n```"
+
+		expected := "<h2>What&#39;s new</h2>\n" +
+			"<p>Faster downloads<br>\nand fewer bugs.</p>\n" +
+			"<ul>\n<li>Added <strong>parallel</strong> downloads</li>\n" +
+			"<li>Fixed <code>Check</code> on Windows<br>\nwhen offline</li>\n</ul>\n" +
+			"<ol>\n<li>Download</li>\n<li>Install</li>\n</ol>\n" +
+			"<blockquote><p>Note: restart after updating</p></blockquote>\n" +
+			"<hr>\n" +
+			"<pre><code>if a &lt; b {\n}</code></pre>"
+		assert.Equal(t, expected, MarkdownHTML(md))
+	}
+
+	// Inline markup
+	{
+		for md, expected := range map[string]string{
+			"*a* _b_ __c__ ~~d~~":                     "<p><em>a</em> <em>b</em> <strong>c</strong> <del>d</del></p>",
+			"See [the docs](https://example.com/a_b)": `<p>See <a href="https://example.com/a_b">the docs</a></p>`,
+			"![Screenshot](img/shot.png)":             `<p><img src="img/shot.png" alt="Screenshot"></p>`,
+			"Visit https://example.com/my_app.":       `<p>Visit <a href="https://example.com/my_app">https://example.com/my_app</a>.</p>`,
+			"<https://example.com?a=1&b=2>":           `<p><a href="https://example.com?a=1&amp;b=2">https://example.com?a=1&amp;b=2</a></p>`,
+			"Keep my_app_name and `*code*`":           "<p>Keep my_app_name and <code>*code*</code></p>",
+		} {
+			assert.Equal(t, expected, MarkdownHTML(md), "Markdown %q", md)
+		}
+	}
+
+	// Unsafe markup
+	{
+		for md, expected := range map[string]string{
+			"<script>alert(1)</script>":          "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>",
+			"[click](javascript:alert(1))":       "<p>click)</p>",
+			"[click](JavaScript:alert)":          "<p>click</p>",
+			"[click](data:text/html;base64,abc)": "<p>click</p>",
+			`[x](https://a.com/"onmouseover=x)`:  `<p><a href="https://a.com/&#34;onmouseover=x">x</a></p>`,
+		} {
+			assert.Equal(t, expected, MarkdownHTML(md), "Markdown %q", md)
+		}
+	}
+}
+
+func TestMarkdownText(t *testing.T) {
+	md := "## What's new\n\n" +
+		"Read **the** [docs](https://example.com).\n\n" +
+		"- One\n- Two\n\n" +
+		"1. First\n1. Second\n\n" +
+		"---\n\n" +
+		"```\ncode *here*\n```"
+
+	expected := "What's new\n\n" +
+		"Read the docs (https://example.com).\n\n" +
+		"- One\n- Two\n\n" +
+		"1. First\n2. Second\n\n" +
+		"code *here*"
+	assert.Equal(t, expected, MarkdownText(md))
+}
+
+func TestInformationHTML(t *testing.T) {
+	// Formatted release
+	{
+		r := &githubRelease{}
+		body := "**Bold** <b>"
+		r.RepositoryRelease.Body = &body
+
+		assert.Equal(t, "<p><strong>Bold</strong> &lt;b&gt;</p>", InformationHTML(r))
+		assert.Equal(t, "Bold <b>", InformationText(r))
+	}
+
+	// Plain text release
+	{
+		r := &testRelease{information: "Fixes *all* <bugs>\nand more.\n\nThanks!\n"}
+		assert.Equal(t, "<p>Fixes *all* &lt;bugs&gt;<br>\nand more.</p>\n<p>Thanks!</p>", InformationHTML(r))
+		assert.Equal(t, "Fixes *all* <bugs>\nand more.\n\nThanks!", InformationText(r))
+	}
+
+	assert.Equal(t, "", InformationHTML(nil))
+	assert.Equal(t, "", InformationText(nil))
+}
@@ -0,0 +1,40 @@
+package updater
+
+import "time"
+
+// Metrics receives measurements of every check for updates and every update,
+// e.g. to monitor the update health of a fleet. The metrics package exports
+// them to Prometheus.
+//
+// The methods are called after the check or update finished, and may be
+// called concurrently.
+type Metrics interface {
+	// ObserveCheck is called after a check for updates, with whether a newer
+	// release was found, and the class of its error, e.g.
+	// ErrorClassNetwork, or an empty string if it succeeded.
+	ObserveCheck(duration time.Duration, found bool, errorClass string)
+
+	// ObserveUpdate is called after an update was applied or failed, with
+	// the number of bytes written to its assets, and the class of its error
+	// or an empty string if it succeeded.
+	ObserveUpdate(duration time.Duration, bytes int64, errorClass string)
+}
+
+// observeOutcome records the outcome of an event in the Metrics of the
+// updater, if there are any.
+func (u *Updater) observeOutcome(event string, duration time.Duration, release Release, report *UpdateReport, class string) {
+	if u.Metrics == nil {
+		return
+	}
+
+	switch event {
+	case OutcomeCheck:
+		u.Metrics.ObserveCheck(duration, release != nil, class)
+	case OutcomeApply:
+		var n int64
+		if report != nil {
+			n = report.BytesDownloaded
+		}
+		u.Metrics.ObserveUpdate(duration, n, class)
+	}
+}
@@ -0,0 +1,149 @@
+// Package metrics exports the checks and updates of an updater to
+// Prometheus, so that operators of a fleet can monitor its update health.
+//
+// Create a Collector, register it and use it as the Metrics of the updater:
+//
+//	c := metrics.NewCollector("myapp")
+//	prometheus.MustRegister(c)
+//	u := &updater.Updater{
+//		App:     app,
+//		Metrics: c,
+//	}
+//
+// The collector exports the following metrics, prefixed with the namespace:
+//
+//	updater_checks_total{result}              checks by result: update_available, up_to_date or failed
+//	updater_check_duration_seconds            histogram of the duration of checks
+//	updater_updates_total{result}             updates by result: applied or failed
+//	updater_update_duration_seconds           histogram of the duration of updates
+//	updater_downloaded_bytes_total            bytes written to the assets of updates
+//	updater_failures_total{event,class}       failed checks and updates by error class
+//
+// The error classes are those of an updater.Outcome, e.g. network or
+// checksum.
+package metrics
+
+import (
+	"time"
+
+	updater "github.com/hverr/go-updater"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Results of checks and updates.
+const (
+	resultUpdateAvailable = "update_available"
+	resultUpToDate        = "up_to_date"
+	resultApplied         = "applied"
+	resultFailed          = "failed"
+)
+
+// Collector is a prometheus.Collector of the metrics of one or more updaters.
+// It implements updater.Metrics.
+type Collector struct {
+	checks          *prometheus.CounterVec
+	checkDuration   prometheus.Histogram
+	updates         *prometheus.CounterVec
+	updateDuration  prometheus.Histogram
+	downloadedBytes prometheus.Counter
+	failures        *prometheus.CounterVec
+}
+
+var _ updater.Metrics = (*Collector)(nil)
+
+// NewCollector creates a collector whose metrics are prefixed with namespace,
+// e.g. the name of the application. The namespace may be empty.
+func NewCollector(namespace string) *Collector {
+	return &Collector{
+		checks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "updater",
+			Name:      "checks_total",
+			Help:      "Number of checks for updates, by result.",
+		}, []string{"result"}),
+		checkDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "updater",
+			Name:      "check_duration_seconds",
+			Help:      "Duration of checks for updates.",
+			Buckets:   prometheus.DefBuckets,
+		}),
+		updates: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "updater",
+			Name:      "updates_total",
+			Help:      "Number of updates, by result.",
+		}, []string{"result"}),
+		updateDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "updater",
+			Name:      "update_duration_seconds",
+			Help:      "Duration of updates.",
+			Buckets:   prometheus.ExponentialBuckets(0.5, 2, 10),
+		}),
+		downloadedBytes: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "updater",
+			Name:      "downloaded_bytes_total",
+			Help:      "Number of bytes written to the assets of updates.",
+		}),
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "updater",
+			Name:      "failures_total",
+			Help:      "Number of failed checks and updates, by event and error class.",
+		}, []string{"event", "class"}),
+	}
+}
+
+// ObserveCheck records a check for updates.
+func (c *Collector) ObserveCheck(duration time.Duration, found bool, errorClass string) {
+	c.checkDuration.Observe(duration.Seconds())
+	switch {
+	case errorClass != "":
+		c.checks.WithLabelValues(resultFailed).Inc()
+		c.failures.WithLabelValues(updater.OutcomeCheck, errorClass).Inc()
+	case found:
+		c.checks.WithLabelValues(resultUpdateAvailable).Inc()
+	default:
+		c.checks.WithLabelValues(resultUpToDate).Inc()
+	}
+}
+
+// ObserveUpdate records an update.
+func (c *Collector) ObserveUpdate(duration time.Duration, bytes int64, errorClass string) {
+	c.updateDuration.Observe(duration.Seconds())
+	c.downloadedBytes.Add(float64(bytes))
+	if errorClass != "" {
+		c.updates.WithLabelValues(resultFailed).Inc()
+		c.failures.WithLabelValues(updater.OutcomeApply, errorClass).Inc()
+	} else {
+		c.updates.WithLabelValues(resultApplied).Inc()
+	}
+}
+
+// Describe sends the descriptors of all metrics to ch.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, m := range c.collectors() {
+		m.Describe(ch)
+	}
+}
+
+// Collect sends all metrics to ch.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	for _, m := range c.collectors() {
+		m.Collect(ch)
+	}
+}
+
+// collectors returns the collectors of all metrics.
+func (c *Collector) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		c.checks,
+		c.checkDuration,
+		c.updates,
+		c.updateDuration,
+		c.downloadedBytes,
+		c.failures,
+	}
+}
@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	updater "github.com/hverr/go-updater"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollector(t *testing.T) {
+	c := NewCollector("myapp")
+
+	c.ObserveCheck(time.Second, true, "")
+	c.ObserveCheck(time.Second, false, "")
+	c.ObserveCheck(time.Second, false, "")
+	c.ObserveCheck(time.Second, false, updater.ErrorClassNetwork)
+	c.ObserveUpdate(time.Minute, 1024, "")
+	c.ObserveUpdate(time.Minute, 12, updater.ErrorClassChecksum)
+
+	// Checks
+	{
+		assert.Equal(t, float64(1), testutil.ToFloat64(c.checks.WithLabelValues(resultUpdateAvailable)))
+		assert.Equal(t, float64(2), testutil.ToFloat64(c.checks.WithLabelValues(resultUpToDate)))
+		assert.Equal(t, float64(1), testutil.ToFloat64(c.checks.WithLabelValues(resultFailed)))
+		assert.Equal(t, float64(1), testutil.ToFloat64(c.failures.WithLabelValues(updater.OutcomeCheck, updater.ErrorClassNetwork)))
+	}
+
+	// Updates
+	{
+		assert.Equal(t, float64(1), testutil.ToFloat64(c.updates.WithLabelValues(resultApplied)))
+		assert.Equal(t, float64(1), testutil.ToFloat64(c.updates.WithLabelValues(resultFailed)))
+		assert.Equal(t, float64(1036), testutil.ToFloat64(c.downloadedBytes))
+		assert.Equal(t, float64(1), testutil.ToFloat64(c.failures.WithLabelValues(updater.OutcomeApply, updater.ErrorClassChecksum)))
+	}
+
+	// All metrics are collected
+	{
+		assert.Equal(t, 10, testutil.CollectAndCount(c))
+	}
+}
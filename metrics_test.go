@@ -0,0 +1,85 @@
+package updater
+
+import (
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testMetrics struct {
+	observed []string
+}
+
+func (m *testMetrics) ObserveCheck(duration time.Duration, found bool, errorClass string) {
+	m.observed = append(m.observed, fmt.Sprintf("check found=%v class=%v", found, errorClass))
+}
+
+func (m *testMetrics) ObserveUpdate(duration time.Duration, bytes int64, errorClass string) {
+	m.observed = append(m.observed, fmt.Sprintf("update bytes=%v class=%v", bytes, errorClass))
+}
+
+func TestUpdaterMetrics(t *testing.T) {
+	asset := &testAsset{
+		name: "myapp",
+		write: func(w io.Writer) error {
+			_, err := io.WriteString(w, "Hello World!")
+			return err
+		},
+	}
+	r := &testRelease{name: "v2", identifier: "2", assets: []Asset{asset}}
+
+	// Check and update
+	{
+		m := &testMetrics{}
+		u := &Updater{
+			App:                      &testApp{FLatestRelease: func() Release { return r }},
+			CurrentReleaseIdentifier: "1",
+			WriterForAsset:           func(Asset) (AbortWriter, error) { return NewAbortBuffer(nil), nil },
+			Metrics:                  m,
+		}
+		err := u.UpdateTo(nil)
+		require.Nil(t, err, "Could not update: %v", err)
+		assert.Equal(t, []string{
+			"check found=true class=",
+			"update bytes=12 class=",
+		}, m.observed)
+	}
+
+	// Up to date
+	{
+		m := &testMetrics{}
+		u := &Updater{
+			App:                      &testApp{FLatestRelease: func() Release { return r }},
+			CurrentReleaseIdentifier: "2",
+			Metrics:                  m,
+		}
+		_, err := u.Check()
+		require.Nil(t, err)
+		assert.Equal(t, []string{"check found=false class="}, m.observed)
+	}
+
+	// Failures
+	{
+		m := &testMetrics{}
+		u := &Updater{
+			App:     &testApp{FQuery: func() error { return &DownloadError{StatusCode: 404} }},
+			Metrics: m,
+		}
+		_, err := u.Check()
+		require.Error(t, err)
+
+		u.WriterForAsset = func(Asset) (AbortWriter, error) { return NewAbortBuffer(nil), nil }
+		failing := &testAsset{name: "myapp", write: func(io.Writer) error { return &DownloadError{StatusCode: 500} }}
+		err = u.UpdateTo(&testRelease{name: "v3", assets: []Asset{failing}})
+		require.Error(t, err)
+
+		assert.Equal(t, []string{
+			"check found=false class=" + ErrorClassDownload,
+			"update bytes=0 class=" + ErrorClassDownload,
+		}, m.observed)
+	}
+}
@@ -0,0 +1,89 @@
+package updater
+
+import (
+	"context"
+	"io"
+	"net/http"
+)
+
+// mirroredAsset is an asset that is downloaded from mirrors first, and from
+// its own source if all mirrors fail.
+type mirroredAsset struct {
+	Asset
+
+	urls []string
+}
+
+// mirrored returns a with the mirrors returned by the MirrorResolver of the
+// updater, or a itself if there are none.
+func (u *Updater) mirrored(a Asset) Asset {
+	switch a.(type) {
+	case *downloadedAsset, *stagedAsset:
+		// Already downloaded
+		return a
+	}
+	if u.MirrorResolver == nil {
+		return a
+	}
+
+	urls := u.MirrorResolver(a)
+	if len(urls) == 0 {
+		return a
+	}
+	return &mirroredAsset{Asset: a, urls: urls}
+}
+
+func (a *mirroredAsset) Write(w io.Writer) error {
+	return a.WriteContext(context.Background(), w)
+}
+
+func (a *mirroredAsset) WriteContext(ctx context.Context, w io.Writer) error {
+	writes := make([]func(context.Context, io.Writer) error, 0, len(a.urls)+1)
+	for _, url := range a.urls {
+		url := url
+		writes = append(writes, func(ctx context.Context, w io.Writer) error {
+			return download(ctx, nil, url, w)
+		})
+	}
+	writes = append(writes, func(ctx context.Context, w io.Writer) error {
+		return writeAsset(ctx, a.Asset, w)
+	})
+
+	return writeFirst(ctx, w, writes...)
+}
+
+// downloadFirst downloads the first of urls that can be downloaded to w. See
+// writeFirst.
+func downloadFirst(ctx context.Context, client *http.Client, urls []string, w io.Writer) error {
+	writes := make([]func(context.Context, io.Writer) error, len(urls))
+	for i, url := range urls {
+		url := url
+		writes[i] = func(ctx context.Context, w io.Writer) error {
+			return download(ctx, client, url, w)
+		}
+	}
+
+	return writeFirst(ctx, w, writes...)
+}
+
+// writeFirst calls the write functions in order until one of them writes to w
+// successfully, and returns the error of the last one otherwise.
+//
+// A write function that fails may have written part of the data already. The
+// next one continues where it stopped, so the data is not written twice. If
+// w itself fails, or ctx is cancelled, the remaining functions are not called.
+func writeFirst(ctx context.Context, w io.Writer, writes ...func(context.Context, io.Writer) error) error {
+	rw := &resumeWriter{w: w}
+	var err error
+	for i, write := range writes {
+		rw.skip = rw.written
+		err = write(ctx, rw)
+		if err == nil || rw.err != nil || ctx.Err() != nil {
+			return err
+		}
+		if i < len(writes)-1 {
+			logf(ctx, "Trying next mirror after error: %v", err)
+		}
+	}
+	return err
+}
@@ -0,0 +1,118 @@
+package updater
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMirrorTestServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/mirror/myapp", "/releases/mirror/myapp":
+			w.Write([]byte("Hello World!"))
+		case "/broken/myapp":
+			// Announce the full length but stop halfway
+			w.Header().Set("Content-Length", strconv.Itoa(len("Hello World!")))
+			w.Write([]byte("Hello "))
+		case "/releases/latest.json":
+			w.Write([]byte(`{
+				"version": "v1.2.0",
+				"assets": [{
+					"name": "myapp",
+					"url": "../broken/myapp",
+					"mirrors": ["down/myapp", "mirror/myapp"],
+					"sha256": "7f83b1657ff1fc53b92dc18148a1d65dfc2d4b1fa3d677284addd200126d9069"
+				}]
+			}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestUpdaterMirrorResolver(t *testing.T) {
+	ts := newMirrorTestServer()
+	defer ts.Close()
+
+	assetErr := errors.New("Asset not available.")
+	newUpdater := func(b *AbortBuffer, urls ...string) *Updater {
+		return &Updater{
+			WriterForAsset: func(Asset) (AbortWriter, error) {
+				return b, nil
+			},
+			MirrorResolver: func(a Asset) []string {
+				return urls
+			},
+		}
+	}
+
+	// First working mirror, continuing a broken download
+	{
+		b := NewAbortBuffer(nil)
+		a := &testAsset{name: "myapp", write: func(io.Writer) error { return assetErr }}
+		u := newUpdater(b, ts.URL+"/broken/myapp", ts.URL+"/missing/myapp", ts.URL+"/mirror/myapp")
+
+		err := u.UpdateTo(&testRelease{assets: []Asset{a}})
+		assert.Nil(t, err, "Could not update: %v", err)
+		assert.Equal(t, "Hello World!", b.Buffer.String())
+	}
+
+	// Fall back to the asset itself
+	{
+		b := NewAbortBuffer(nil)
+		a := &testAsset{
+			name: "myapp",
+			write: func(w io.Writer) error {
+				_, err := w.Write([]byte("Hello World!"))
+				return err
+			},
+		}
+		u := newUpdater(b, ts.URL+"/missing/myapp")
+
+		err := u.UpdateTo(&testRelease{assets: []Asset{a}})
+		assert.Nil(t, err, "Could not update: %v", err)
+		assert.Equal(t, "Hello World!", b.Buffer.String())
+	}
+
+	// All sources fail
+	{
+		b := NewAbortBuffer(nil)
+		a := &testAsset{name: "myapp", write: func(io.Writer) error { return assetErr }}
+		u := newUpdater(b, ts.URL+"/missing/myapp")
+
+		err := u.UpdateTo(&testRelease{assets: []Asset{a}})
+		var downloadErr *AssetDownloadError
+		require.True(t, errors.As(err, &downloadErr))
+		assert.Equal(t, a, downloadErr.Asset)
+		assert.Equal(t, assetErr, downloadErr.Cause)
+		assert.True(t, b.aborted)
+	}
+}
+
+func TestHTTPManifestMirrors(t *testing.T) {
+	ts := newMirrorTestServer()
+	defer ts.Close()
+
+	app := NewHTTPManifest(ts.URL+"/releases/latest.json", nil)
+	err := app.Query()
+	require.Nil(t, err, "Unexpected query error: %v", err)
+
+	a := app.LatestRelease().Assets()[0]
+	assert.Equal(t, []string{
+		ts.URL + "/releases/down/myapp",
+		ts.URL + "/releases/mirror/myapp",
+	}, a.(*manifestAsset).mirrors)
+
+	buf := bytes.NewBuffer(nil)
+	err = a.Write(buf)
+	assert.Nil(t, err, "Unexpected write error: %v", err)
+	assert.Equal(t, "Hello World!", buf.String())
+}
@@ -0,0 +1,131 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"path"
+	"strings"
+)
+
+// ObjectStore is an object storage, like an S3 bucket, that holds releases.
+type ObjectStore interface {
+	// List should return the keys of all objects starting with prefix.
+	List(ctx context.Context, prefix string) ([]string, error)
+
+	// Get should write the contents of the object with the given key to w.
+	Get(ctx context.Context, key string, w io.Writer) error
+}
+
+type objectStoreApp struct {
+	store  ObjectStore
+	prefix string
+	latest Release
+}
+
+type objectStoreRelease struct {
+	version string
+	assets  []Asset
+}
+
+type objectStoreAsset struct {
+	store ObjectStore
+	key   string
+}
+
+// NewObjectStore creates an Application whose releases are stored in an object
+// storage.
+//
+// The objects should be laid out under prefix as follows:
+//
+//	latest                   contains the version name of the latest release
+//	releases/<version>/...   the assets of every release
+//
+// For example, with prefix "myapp/" the object "myapp/latest" could contain
+// "v1.2.3", and the assets of that release would be stored as
+// "myapp/releases/v1.2.3/myapp_linux_amd64" and so on. The version name is
+// used as the identifier of a release.
+func NewObjectStore(store ObjectStore, prefix string) App {
+	return &objectStoreApp{
+		store:  store,
+		prefix: prefix,
+	}
+}
+
+func (app *objectStoreApp) Query() error {
+	return app.QueryContext(context.Background())
+}
+
+func (app *objectStoreApp) QueryContext(ctx context.Context) error {
+	// Get the version of the latest release
+	buf := bytes.NewBuffer(nil)
+	err := app.store.Get(ctx, app.prefix+"latest", buf)
+	if err != nil {
+		return err
+	}
+
+	version := strings.TrimSpace(buf.String())
+	if version == "" || strings.Contains(version, "/") {
+		return errors.New("Invalid latest release pointer.")
+	}
+
+	// Get the assets of the latest release
+	dir := app.prefix + "releases/" + version + "/"
+	keys, err := app.store.List(ctx, dir)
+	if err != nil {
+		return err
+	}
+
+	var assets []Asset
+	for _, key := range keys {
+		name := strings.TrimPrefix(key, dir)
+		if name == "" || strings.Contains(name, "/") {
+			continue
+		}
+
+		assets = append(assets, &objectStoreAsset{
+			store: app.store,
+			key:   key,
+		})
+	}
+
+	app.latest = &objectStoreRelease{
+		version: version,
+		assets:  assets,
+	}
+
+	return nil
+}
+
+func (app *objectStoreApp) LatestRelease() Release {
+	return app.latest
+}
+
+func (r *objectStoreRelease) Name() string {
+	return r.version
+}
+
+func (r *objectStoreRelease) Information() string {
+	return ""
+}
+
+func (r *objectStoreRelease) Identifier() string {
+	return r.version
+}
+
+func (r *objectStoreRelease) Assets() []Asset {
+	return r.assets
+}
+
+func (r *objectStoreAsset) Name() string {
+	return path.Base(r.key)
+}
+
+func (r *objectStoreAsset) Write(w io.Writer) error {
+	return r.WriteContext(context.Background(), w)
+}
+
+func (r *objectStoreAsset) WriteContext(ctx context.Context, w io.Writer) error {
+	return r.store.Get(ctx, r.key, w)
+}
@@ -0,0 +1,78 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testObjectStore is an in-memory object store.
+type testObjectStore map[string]string
+
+func (s testObjectStore) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	for k := range s {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	return keys, nil
+}
+
+func (s testObjectStore) Get(ctx context.Context, key string, w io.Writer) error {
+	data, ok := s[key]
+	if !ok {
+		return errors.New("No such key")
+	}
+	_, err := io.WriteString(w, data)
+	return err
+}
+
+func TestObjectStore(t *testing.T) {
+	// Valid layout
+	{
+		store := testObjectStore{
+			"myapp/latest": "v1.2.3\n",
+			"myapp/releases/v1.2.3/myapp_linux_amd64":       "Hello World!",
+			"myapp/releases/v1.2.3/nested/ignored":          "Ignored",
+			"myapp/releases/v1.2.2/myapp_linux_amd64":       "Old release",
+			"myapp/releases/v1.2.30/myapp_linux_amd64":      "Other release",
+			"otherapp/releases/v1.2.3/otherapp_linux_amd64": "Other app",
+		}
+
+		app := NewObjectStore(store, "myapp/")
+		assert.Nil(t, app.LatestRelease())
+
+		err := app.Query()
+		assert.Nil(t, err, "Unexpected query error: %v", err)
+
+		r := app.LatestRelease()
+		require.NotNil(t, r)
+		assert.Equal(t, "v1.2.3", r.Name())
+		assert.Equal(t, "v1.2.3", r.Identifier())
+		require.Equal(t, 1, len(r.Assets()))
+		assert.Equal(t, "myapp_linux_amd64", r.Assets()[0].Name())
+
+		buf := bytes.NewBuffer(nil)
+		err = r.Assets()[0].Write(buf)
+		assert.Nil(t, err)
+		assert.Equal(t, "Hello World!", buf.String())
+	}
+
+	// Missing or invalid latest pointer
+	{
+		app := NewObjectStore(testObjectStore{}, "")
+		assert.Error(t, app.Query())
+
+		app = NewObjectStore(testObjectStore{"latest": "../v1"}, "")
+		err := app.Query()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "Invalid latest")
+	}
+}
@@ -0,0 +1,67 @@
+package updater
+
+// Observer is notified of the stages of checking for and applying updates.
+//
+// Use it to log, collect metrics or show the progress of an update in a user
+// interface. Embed NopObserver to only implement some of the methods. When
+// assets are written concurrently, OnAssetStart and OnAssetFinish may be
+// called concurrently.
+type Observer interface {
+	// OnCheckStart is called when the updater starts checking for updates.
+	OnCheckStart()
+
+	// OnReleaseFound is called when a newer release was found.
+	OnReleaseFound(release Release)
+
+	// OnAssetStart is called before an asset is written.
+	OnAssetStart(asset Asset)
+
+	// OnAssetFinish is called when an asset was written and verified, or
+	// when this failed, with the error.
+	OnAssetFinish(asset Asset, err error)
+
+	// OnApply is called when all assets of a release were written and
+	// validated, right before the update is committed.
+	OnApply(release Release)
+
+	// OnError is called when checking for or applying an update fails.
+	OnError(err error)
+}
+
+// NopObserver is an Observer that does nothing.
+type NopObserver struct{}
+
+// OnCheckStart does nothing.
+func (NopObserver) OnCheckStart() {}
+
+// OnReleaseFound does nothing.
+func (NopObserver) OnReleaseFound(Release) {}
+
+// OnAssetStart does nothing.
+func (NopObserver) OnAssetStart(Asset) {}
+
+// OnAssetFinish does nothing.
+func (NopObserver) OnAssetFinish(Asset, error) {}
+
+// OnApply does nothing.
+func (NopObserver) OnApply(Release) {}
+
+// OnError does nothing.
+func (NopObserver) OnError(error) {}
+
+// observer returns the observer of the updater, or a NopObserver if there is
+// none.
+func (u *Updater) observer() Observer {
+	if u.Observer == nil {
+		return NopObserver{}
+	}
+	return u.Observer
+}
+
+// reportError reports err to the observer if it is not nil, and returns it.
+func (u *Updater) reportError(err error) error {
+	if err != nil {
+		u.observer().OnError(err)
+	}
+	return err
+}
@@ -0,0 +1,124 @@
+package updater
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testObserver records the events it is notified of.
+type testObserver struct {
+	NopObserver
+
+	events []string
+}
+
+func (o *testObserver) OnCheckStart() {
+	o.events = append(o.events, "check")
+}
+
+func (o *testObserver) OnReleaseFound(r Release) {
+	o.events = append(o.events, "release "+r.Name())
+}
+
+func (o *testObserver) OnAssetStart(a Asset) {
+	o.events = append(o.events, "start "+a.Name())
+}
+
+func (o *testObserver) OnAssetFinish(a Asset, err error) {
+	o.events = append(o.events, fmt.Sprintf("finish %v %v", a.Name(), err))
+}
+
+func (o *testObserver) OnApply(r Release) {
+	o.events = append(o.events, "apply "+r.Name())
+}
+
+func (o *testObserver) OnError(err error) {
+	o.events = append(o.events, fmt.Sprintf("error %v", err))
+}
+
+func TestUpdaterObserver(t *testing.T) {
+	a := &testAsset{
+		name: "myapp",
+		write: func(w io.Writer) error {
+			_, err := w.Write([]byte("Hello World!"))
+			return err
+		},
+	}
+	r := &testRelease{name: "v2", identifier: "2", assets: []Asset{a}}
+	app := &testApp{FLatestRelease: func() Release { return r }}
+
+	// Successful update
+	{
+		o := &testObserver{}
+		u := &Updater{
+			App:                      app,
+			CurrentReleaseIdentifier: "1",
+			WriterForAsset: func(Asset) (AbortWriter, error) {
+				return NewAbortBuffer(nil), nil
+			},
+			Observer: o,
+		}
+
+		err := u.UpdateTo(nil)
+		assert.Nil(t, err, "Could not update: %v", err)
+		assert.Equal(t, []string{
+			"check",
+			"release v2",
+			"start myapp",
+			"finish myapp <nil>",
+			"apply v2",
+		}, o.events)
+	}
+
+	// Up to date
+	{
+		o := &testObserver{}
+		u := &Updater{App: app, CurrentReleaseIdentifier: "2", Observer: o}
+
+		err := u.UpdateTo(nil)
+		assert.Equal(t, ErrUpToDate, err)
+		assert.Equal(t, []string{"check"}, o.events)
+	}
+
+	// Failing query
+	{
+		o := &testObserver{}
+		queryErr := errors.New("Network unreachable.")
+		u := &Updater{
+			App:      &testApp{FQuery: func() error { return queryErr }},
+			Observer: o,
+		}
+
+		_, err := u.Check()
+		assert.Equal(t, queryErr, err)
+		assert.Equal(t, []string{"check", "error Network unreachable."}, o.events)
+	}
+
+	// Failing asset
+	{
+		o := &testObserver{}
+		writeErr := errors.New("Connection reset.")
+		u := &Updater{
+			WriterForAsset: func(Asset) (AbortWriter, error) {
+				return NewAbortBuffer(nil), nil
+			},
+			Observer: o,
+		}
+
+		failing := &testAsset{
+			name:  "myapp",
+			write: func(w io.Writer) error { return writeErr },
+		}
+		err := u.UpdateTo(&testRelease{name: "v3", assets: []Asset{failing}})
+		assert.Error(t, err)
+		assert.Equal(t, []string{
+			"start myapp",
+			"finish myapp Could not write asset myapp: Connection reset.",
+			"error Could not write asset myapp: Connection reset.",
+		}, o.events)
+	}
+}
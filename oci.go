@@ -0,0 +1,483 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Media types of the manifests that are accepted from a registry.
+var ociManifestTypes = []string{
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.oci.artifact.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// Annotations of OCI manifests and layers.
+const (
+	ociTitleAnnotation       = "org.opencontainers.image.title"
+	ociDescriptionAnnotation = "org.opencontainers.image.description"
+)
+
+// OCIOptions are the options of an OCI application.
+type OCIOptions struct {
+	// Username and Password to log in to the registry, e.g. a user and an
+	// access token. The registry is accessed anonymously if Username is
+	// empty.
+	Username string
+	Password string
+
+	// HTTP client to access the registry with. The HTTPClient of the Updater,
+	// or the default HTTP client, is used if it is nil.
+	Client *http.Client
+
+	// Access the registry over HTTP instead of HTTPS, e.g. for a local
+	// registry.
+	PlainHTTP bool
+}
+
+type ociApp struct {
+	host     string
+	baseURL  string
+	name     string
+	opts     OCIOptions
+	releases []Release
+
+	// mutex guards token, the bearer token for the repository.
+	mutex sync.Mutex
+	token string
+}
+
+type ociRelease struct {
+	app     *ociApp
+	tag     string
+	version version
+
+	// The manifest of the tag is fetched the first time it is needed.
+	once     sync.Once
+	manifest *ociManifest
+	digest   string
+	assets   []Asset
+}
+
+type ociAsset struct {
+	app   *ociApp
+	layer ociDescriptor
+}
+
+// ociManifest is an image or artifact manifest.
+type ociManifest struct {
+	MediaType   string            `json:"mediaType"`
+	Layers      []ociDescriptor   `json:"layers"`
+	Blobs       []ociDescriptor   `json:"blobs"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+// ociDescriptor describes a blob of a manifest.
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+// NewOCI creates an Application whose releases are OCI artifacts in a
+// container registry, like the files pushed with oras push. The repository
+// includes the registry, e.g. ghcr.io/owner/myapp.
+//
+// Every tag that is a semantic version is a release, e.g. v1.2.3, and its
+// assets are the layers of the manifest of the tag, named after their
+// org.opencontainers.image.title annotation. Layers without a title are
+// ignored. The assets are verified with their digest while they are written.
+//
+// The identifier of a release is the digest of its layer, or the digest of
+// its manifest if it has several layers, so that a release is only applied
+// again if its contents changed. Its information is the
+// org.opencontainers.image.description annotation of the manifest. Releases
+// are ordered by version, the highest first, and the latest release is the
+// highest version that is not a pre-release. The manifests of the other
+// releases are only fetched when they are needed.
+func NewOCI(repository string, opts OCIOptions) (App, error) {
+	i := strings.Index(repository, "/")
+	if i < 0 {
+		return nil, fmt.Errorf("Invalid repository %v: missing registry.", repository)
+	}
+	host, name := repository[:i], repository[i+1:]
+	if name == "" || strings.ContainsAny(host, "?#@") || strings.ContainsAny(name, ":@") {
+		return nil, fmt.Errorf("Invalid repository %v.", repository)
+	}
+
+	// Docker Hub is served by another host, and has a namespace for its
+	// official images
+	if host == "docker.io" {
+		host = "registry-1.docker.io"
+		if !strings.Contains(name, "/") {
+			name = "library/" + name
+		}
+	}
+
+	scheme := "https"
+	if opts.PlainHTTP {
+		scheme = "http"
+	}
+	return &ociApp{
+		host:    host,
+		baseURL: scheme + "://" + host,
+		name:    name,
+		opts:    opts,
+	}, nil
+}
+
+func (app *ociApp) Query() error {
+	return app.QueryContext(context.Background())
+}
+
+// QueryContext lists the tags of the repository, and fetches the manifest of
+// the latest release.
+func (app *ociApp) QueryContext(ctx context.Context) error {
+	tags, err := app.listTags(ctx)
+	if err != nil {
+		return err
+	}
+
+	var releases []*ociRelease
+	for _, tag := range tags {
+		v, err := parseVersion(tag)
+		if err != nil {
+			continue
+		}
+		releases = append(releases, &ociRelease{app: app, tag: tag, version: v})
+	}
+
+	sort.SliceStable(releases, func(i, j int) bool {
+		return releases[i].version.compare(releases[j].version) > 0
+	})
+
+	s := make([]Release, len(releases))
+	for i, r := range releases {
+		s[i] = r
+	}
+
+	for _, r := range releases {
+		if len(r.version.pre) != 0 {
+			continue
+		}
+
+		var err error
+		r.once.Do(func() { err = r.fetch(ctx) })
+		if err != nil {
+			return err
+		}
+		break
+	}
+
+	app.releases = s
+	return nil
+}
+
+func (app *ociApp) LatestRelease() Release {
+	for _, r := range app.releases {
+		if len(r.(*ociRelease).version.pre) == 0 {
+			return r
+		}
+	}
+	return nil
+}
+
+func (app *ociApp) Releases() []Release {
+	return app.releases
+}
+
+// listTags fetches all pages of tags of the repository.
+func (app *ociApp) listTags(ctx context.Context) ([]string, error) {
+	var tags []string
+	next := "/v2/" + app.name + "/tags/list"
+	for next != "" {
+		buf := bytes.NewBuffer(nil)
+		resp, err := app.get(ctx, next, "", buf)
+		if err != nil {
+			return nil, err
+		}
+
+		var page struct {
+			Tags []string `json:"tags"`
+		}
+		err = json.Unmarshal(buf.Bytes(), &page)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid tag list: %v", err)
+		}
+		tags = append(tags, page.Tags...)
+		next = ociNextLink(resp.Header.Get("Link"))
+	}
+	return tags, nil
+}
+
+// ociNextLink returns the next page of a Link header, like
+// </v2/owner/myapp/tags/list?last=v1.2.3&n=100>; rel="next".
+func ociNextLink(link string) string {
+	start, end := strings.Index(link, "<"), strings.Index(link, ">")
+	if start < 0 || end < start || !strings.Contains(link[end:], `rel="next"`) {
+		return ""
+	}
+	return link[start+1 : end]
+}
+
+// get fetches path of the registry and writes the response to w. The request
+// is authorized with a bearer token or basic authentication if the registry
+// asks for it.
+func (app *ociApp) get(ctx context.Context, path, accept string, w io.Writer) (*http.Response, error) {
+	u, err := url.Parse(app.baseURL)
+	if err != nil {
+		return nil, err
+	}
+	u, err = u.Parse(path)
+	if err != nil {
+		return nil, err
+	} else if u.Host != app.host {
+		return nil, fmt.Errorf("Unexpected registry URL: %v", redactURL(u))
+	}
+
+	for authenticated := false; ; authenticated = true {
+		req, err := http.NewRequest("GET", u.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		app.authorize(req)
+
+		resp, err := downloadResponse(ctx, app.opts.Client, req, w)
+		if resp == nil || resp.StatusCode != http.StatusUnauthorized || authenticated {
+			return resp, err
+		}
+
+		// Registries that use basic authentication already got the
+		// credentials, if any
+		scheme, params := parseOCIChallenge(resp.Header.Get("WWW-Authenticate"))
+		if !strings.EqualFold(scheme, "Bearer") || params["realm"] == "" {
+			return resp, err
+		}
+		err = app.authenticate(ctx, params)
+		if err != nil {
+			return resp, err
+		}
+	}
+}
+
+// authorize adds the credentials for the registry to req.
+func (app *ociApp) authorize(req *http.Request) {
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+
+	if app.token != "" {
+		req.Header.Set("Authorization", "Bearer "+app.token)
+	} else if app.opts.Username != "" && req.URL.Host == app.host {
+		req.SetBasicAuth(app.opts.Username, app.opts.Password)
+	}
+}
+
+// authenticate answers the bearer authentication challenge of the registry,
+// with the given parameters. A token to pull from the repository is requested
+// from the token server of the challenge.
+func (app *ociApp) authenticate(ctx context.Context, params map[string]string) error {
+	u, err := url.Parse(params["realm"])
+	if err != nil {
+		return fmt.Errorf("Invalid authentication realm: %v", err)
+	}
+	scope := params["scope"]
+	if scope == "" {
+		scope = "repository:" + app.name + ":pull"
+	}
+	q := u.Query()
+	q.Set("scope", scope)
+	if params["service"] != "" {
+		q.Set("service", params["service"])
+	}
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return err
+	}
+	if app.opts.Username != "" {
+		req.SetBasicAuth(app.opts.Username, app.opts.Password)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	_, err = downloadResponse(ctx, app.opts.Client, req, buf)
+	if err != nil {
+		return fmt.Errorf("Could not authenticate with the registry: %v", err)
+	}
+
+	var resp struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	err = json.Unmarshal(buf.Bytes(), &resp)
+	if err != nil {
+		return fmt.Errorf("Invalid token response: %v", err)
+	}
+	if resp.Token == "" {
+		resp.Token = resp.AccessToken
+	}
+	if resp.Token == "" {
+		return errors.New("The registry did not return a token.")
+	}
+
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	app.token = resp.Token
+	return nil
+}
+
+// parseOCIChallenge parses a WWW-Authenticate header, like
+// Bearer realm="https://ghcr.io/token",service="ghcr.io".
+func parseOCIChallenge(h string) (string, map[string]string) {
+	params := make(map[string]string)
+	h = strings.TrimSpace(h)
+	i := strings.Index(h, " ")
+	if i < 0 {
+		return h, params
+	}
+	scheme, s := h[:i], h[i+1:]
+
+	for {
+		s = strings.TrimLeft(s, " ,")
+		eq := strings.Index(s, "=")
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = s[eq+1:]
+
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			end := strings.Index(s[1:], `"`)
+			if end < 0 {
+				break
+			}
+			value, s = s[1:end+1], s[end+2:]
+		} else {
+			end := strings.Index(s, ",")
+			if end < 0 {
+				end = len(s)
+			}
+			value, s = strings.TrimSpace(s[:end]), s[end:]
+		}
+		params[key] = value
+	}
+	return scheme, params
+}
+
+// fetch fetches the manifest of the release.
+func (r *ociRelease) fetch(ctx context.Context) error {
+	buf := bytes.NewBuffer(nil)
+	_, err := r.app.get(ctx, "/v2/"+r.app.name+"/manifests/"+url.PathEscape(r.tag), strings.Join(ociManifestTypes, ", "), buf)
+	if err != nil {
+		return err
+	}
+
+	m := &ociManifest{}
+	err = json.Unmarshal(buf.Bytes(), m)
+	if err != nil {
+		return fmt.Errorf("Invalid manifest for %v: %v", r.tag, err)
+	}
+	if strings.Contains(m.MediaType, "index") || strings.Contains(m.MediaType, "manifest.list") {
+		return fmt.Errorf("Unsupported manifest for %v: %v", r.tag, m.MediaType)
+	}
+
+	layers := append(append([]ociDescriptor(nil), m.Layers...), m.Blobs...)
+	sum := sha256.Sum256(buf.Bytes())
+	r.digest = "sha256:" + hex.EncodeToString(sum[:])
+	if len(layers) == 1 {
+		r.digest = layers[0].Digest
+	}
+
+	for _, l := range layers {
+		if l.Annotations[ociTitleAnnotation] == "" {
+			continue
+		}
+		r.assets = append(r.assets, &ociAsset{app: r.app, layer: l})
+	}
+	r.manifest = m
+	return nil
+}
+
+// load fetches the manifest of the release if it was not fetched yet.
+func (r *ociRelease) load() {
+	r.once.Do(func() {
+		r.fetch(context.Background())
+	})
+}
+
+func (r *ociRelease) Name() string {
+	return r.tag
+}
+
+func (r *ociRelease) Information() string {
+	r.load()
+	if r.manifest == nil {
+		return ""
+	}
+	return r.manifest.Annotations[ociDescriptionAnnotation]
+}
+
+func (r *ociRelease) Identifier() string {
+	r.load()
+	return r.digest
+}
+
+func (r *ociRelease) Assets() []Asset {
+	r.load()
+	return r.assets
+}
+
+func (r *ociAsset) Name() string {
+	return r.layer.Annotations[ociTitleAnnotation]
+}
+
+func (r *ociAsset) Write(w io.Writer) error {
+	return r.WriteContext(context.Background(), w)
+}
+
+// WriteContext downloads the blob of the layer, and verifies its digest.
+func (r *ociAsset) WriteContext(ctx context.Context, w io.Writer) error {
+	if !strings.HasPrefix(r.layer.Digest, "sha256:") {
+		return fmt.Errorf("Unsupported digest for %v: %v", r.Name(), r.layer.Digest)
+	}
+	expected, err := hex.DecodeString(strings.TrimPrefix(r.layer.Digest, "sha256:"))
+	if err != nil {
+		return fmt.Errorf("Invalid digest for %v: %v", r.Name(), err)
+	}
+
+	h := sha256.New()
+	_, err = r.app.get(ctx, "/v2/"+r.app.name+"/blobs/"+r.layer.Digest, "", teeWriter(w, h))
+	if err != nil {
+		return err
+	}
+
+	return verifyChecksum(map[string][]byte{r.Name(): expected}, r, h.Sum(nil))
+}
+
+func (r *ociAsset) Size() int64 {
+	return r.layer.Size
+}
+
+func (r *ociAsset) ContentType() string {
+	return r.layer.MediaType
+}
+
+func (r *ociAsset) DownloadCount() int {
+	return -1
+}
@@ -0,0 +1,196 @@
+package updater
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOCI(t *testing.T) {
+	digest := func(s string) string {
+		sum := sha256.Sum256([]byte(s))
+		return "sha256:" + hex.EncodeToString(sum[:])
+	}
+	layer := func(title, contents string) map[string]interface{} {
+		l := map[string]interface{}{
+			"mediaType": "application/octet-stream",
+			"digest":    digest(contents),
+			"size":      len(contents),
+		}
+		if title != "" {
+			l["annotations"] = map[string]string{ociTitleAnnotation: title}
+		}
+		return l
+	}
+	manifest := func(description string, layers ...map[string]interface{}) string {
+		b, _ := json.Marshal(map[string]interface{}{
+			"schemaVersion": 2,
+			"mediaType":     "application/vnd.oci.image.manifest.v1+json",
+			"layers":        layers,
+			"annotations":   map[string]string{ociDescriptionAnnotation: description},
+		})
+		return string(b)
+	}
+
+	blobs := map[string]string{
+		digest("Hello World!"): "Hello World!",
+		digest("Old"):          "Old",
+		digest("Notes"):        "Corrupted",
+	}
+	manifests := map[string]string{
+		"v1.0.0":        manifest("First release.", layer("myapp", "Old")),
+		"v1.1.0":        manifest("Bug fixes.", layer("myapp", "Hello World!"), layer("NOTES", "Notes"), layer("", "{}")),
+		"v1.2.0-beta.1": manifest("Beta.", layer("myapp", "Beta")),
+		"latest":        manifest("Bug fixes.", layer("myapp", "Hello World!")),
+	}
+	tags := []string{"latest", "v1.0.0", "v1.1.0", "v1.2.0-beta.1"}
+
+	var mutex sync.Mutex
+	requests := make(map[string]int)
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		requests[r.URL.Path]++
+		mutex.Unlock()
+
+		if r.URL.Path == "/token" {
+			user, password, _ := r.BasicAuth()
+			if user != "deploy" || password != "secret" || r.URL.Query().Get("scope") != "repository:owner/myapp:pull" {
+				w.WriteHeader(401)
+				return
+			}
+			w.Write([]byte(`{"token": "abc"}`))
+			return
+		}
+
+		if r.Header.Get("Authorization") != "Bearer abc" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%v/token",service="registry",scope="repository:owner/myapp:pull"`, ts.URL))
+			w.WriteHeader(401)
+			return
+		}
+
+		p := strings.TrimPrefix(r.URL.Path, "/v2/owner/myapp/")
+		switch {
+		case p == "tags/list":
+			// Pages of two tags
+			i := 0
+			if r.URL.Query().Get("last") != "" {
+				i = 2
+			} else {
+				w.Header().Set("Link", `</v2/owner/myapp/tags/list?last=v1.0.0&n=2>; rel="next"`)
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"tags": tags[i : i+2]})
+		case strings.HasPrefix(p, "manifests/"):
+			m, ok := manifests[strings.TrimPrefix(p, "manifests/")]
+			if !ok || !strings.Contains(r.Header.Get("Accept"), "application/vnd.oci.image.manifest.v1+json") {
+				w.WriteHeader(404)
+				return
+			}
+			w.Write([]byte(m))
+		case strings.HasPrefix(p, "blobs/"):
+			b, ok := blobs[strings.TrimPrefix(p, "blobs/")]
+			if !ok {
+				w.WriteHeader(404)
+				return
+			}
+			w.Write([]byte(b))
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	defer ts.Close()
+	repository := strings.TrimPrefix(ts.URL, "http://") + "/owner/myapp"
+	opts := OCIOptions{Username: "deploy", Password: "secret", PlainHTTP: true}
+
+	// Releases ordered by version
+	{
+		app, err := NewOCI(repository, opts)
+		require.Nil(t, err)
+		err = app.Query()
+		require.Nil(t, err, "Unexpected query error: %v", err)
+		assert.Equal(t, 1, requests["/token"])
+		assert.Equal(t, 0, requests["/v2/owner/myapp/manifests/v1.0.0"])
+
+		var names []string
+		for _, r := range app.(ReleasesApp).Releases() {
+			names = append(names, r.Name())
+		}
+		assert.Equal(t, []string{"v1.2.0-beta.1", "v1.1.0", "v1.0.0"}, names)
+
+		r := app.LatestRelease()
+		require.NotNil(t, r)
+		assert.Equal(t, "v1.1.0", r.Name())
+		assert.Equal(t, "Bug fixes.", r.Information())
+		assert.True(t, strings.HasPrefix(r.Identifier(), "sha256:"))
+		require.Equal(t, 2, len(r.Assets()))
+		assert.Equal(t, "myapp", r.Assets()[0].Name())
+		assert.Equal(t, "NOTES", r.Assets()[1].Name())
+		assert.Equal(t, int64(12), r.Assets()[0].(AssetMeta).Size())
+
+		// Valid digest
+		buf := bytes.NewBuffer(nil)
+		err = r.Assets()[0].Write(buf)
+		assert.Nil(t, err, "Unexpected write error: %v", err)
+		assert.Equal(t, "Hello World!", buf.String())
+
+		// Invalid digest
+		err = r.Assets()[1].Write(bytes.NewBuffer(nil))
+		if assert.NotNil(t, err) {
+			assert.Contains(t, err.Error(), "mismatch")
+		}
+
+		// Manifest of an older release fetched when needed
+		old := app.(ReleasesApp).Releases()[2]
+		assert.Equal(t, digest("Old"), old.Identifier())
+		assert.Equal(t, "First release.", old.Information())
+		assert.Equal(t, 1, requests["/v2/owner/myapp/manifests/v1.0.0"])
+		assert.Equal(t, 1, requests["/token"])
+	}
+
+	// Invalid credentials
+	{
+		app, err := NewOCI(repository, OCIOptions{Username: "deploy", Password: "wrong", PlainHTTP: true})
+		require.Nil(t, err)
+		err = app.Query()
+		if assert.NotNil(t, err) {
+			assert.Contains(t, err.Error(), "authenticate")
+		}
+	}
+
+	// Invalid repositories
+	{
+		_, err := NewOCI("myapp", OCIOptions{})
+		assert.NotNil(t, err)
+		_, err = NewOCI("ghcr.io/owner/myapp:v1.0.0", OCIOptions{})
+		assert.NotNil(t, err)
+
+		app, err := NewOCI("docker.io/myapp", OCIOptions{})
+		require.Nil(t, err)
+		assert.Equal(t, "https://registry-1.docker.io", app.(*ociApp).baseURL)
+		assert.Equal(t, "library/myapp", app.(*ociApp).name)
+	}
+}
+
+func TestParseOCIChallenge(t *testing.T) {
+	scheme, params := parseOCIChallenge(`Bearer realm="https://auth.example.com/token",service="registry, example",scope=repository:owner/myapp:pull`)
+	assert.Equal(t, "Bearer", scheme)
+	assert.Equal(t, map[string]string{
+		"realm":   "https://auth.example.com/token",
+		"service": "registry, example",
+		"scope":   "repository:owner/myapp:pull",
+	}, params)
+
+	scheme, params = parseOCIChallenge(`Basic realm="Registry"`)
+	assert.Equal(t, "Basic", scheme)
+	assert.Equal(t, "Registry", params["realm"])
+}
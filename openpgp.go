@@ -0,0 +1,93 @@
+package updater
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	pgperrors "github.com/ProtonMail/go-crypto/openpgp/errors"
+)
+
+// Hash algorithms accepted in OpenPGP signatures. MD5 and SHA-1 are not
+// secure anymore.
+var openpgpHashes = map[crypto.Hash]bool{
+	crypto.SHA224: true,
+	crypto.SHA256: true,
+	crypto.SHA384: true,
+	crypto.SHA512: true,
+}
+
+type openpgpVerifier struct {
+	keyring openpgp.EntityList
+}
+
+// NewOpenPGPVerifier creates a verifier for detached OpenPGP signatures, as
+// created by gpg --detach-sign.
+//
+// The keyring contains the trusted public keys, as exported by gpg --export,
+// optionally ASCII armored. Signatures may be binary or ASCII armored, like
+// myapp.sig or SHA256SUMS.asc; set SignatureSuffix of the Updater accordingly.
+//
+// Every primary key in the keyring is trusted. Subkeys are only trusted when
+// they are bound to their primary key by a valid binding signature that allows
+// signing, which carries the cross-signature of the subkey, as created by gpg.
+// Signatures of expired or revoked keys are rejected, as are signatures using
+// a hash other than SHA-2 or with critical subpackets that are not understood.
+func NewOpenPGPVerifier(keyring io.Reader) (Verifier, error) {
+	b, err := ioutil.ReadAll(keyring)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys openpgp.EntityList
+	if isOpenPGPArmored(b) {
+		keys, err = openpgp.ReadArmoredKeyRing(bytes.NewReader(b))
+	} else {
+		keys, err = openpgp.ReadKeyRing(bytes.NewReader(b))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Could not read OpenPGP keyring: %v", err)
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("No supported OpenPGP public key found.")
+	}
+	return &openpgpVerifier{keyring: keys}, nil
+}
+
+func (v *openpgpVerifier) Verify(message, signature []byte) error {
+	var r io.Reader = bytes.NewReader(signature)
+	if isOpenPGPArmored(signature) {
+		block, err := armor.Decode(r)
+		if err != nil {
+			return fmt.Errorf("Invalid OpenPGP signature: %v", err)
+		} else if block.Type != openpgp.SignatureType {
+			return fmt.Errorf("Invalid OpenPGP signature: unexpected block %v", block.Type)
+		}
+		r = block.Body
+	}
+
+	sig, _, err := openpgp.VerifyDetachedSignature(v.keyring, bytes.NewReader(message), r, nil)
+	switch {
+	case err == pgperrors.ErrUnknownIssuer:
+		return errors.New("Signature is not made by a trusted key.")
+	case err == pgperrors.ErrKeyExpired:
+		return errors.New("Signature is made by an expired key.")
+	case err == pgperrors.ErrKeyRevoked:
+		return errors.New("Signature is made by a revoked key.")
+	case err != nil:
+		return fmt.Errorf("Invalid signature: %v", err)
+	case !openpgpHashes[sig.Hash]:
+		return fmt.Errorf("Signature uses an insecure hash algorithm %v.", sig.Hash)
+	}
+	return nil
+}
+
+func isOpenPGPArmored(b []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(b), []byte("-----BEGIN PGP "))
+}
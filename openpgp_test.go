@@ -0,0 +1,361 @@
+package updater
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenPGPVerifier(t *testing.T) {
+	v, err := NewOpenPGPVerifier(strings.NewReader(testOpenPGPKeyring))
+	require.Nil(t, err, "Could not read keyring: %v", err)
+
+	message := []byte("Hello World!\n")
+
+	// Armored signatures with Ed25519, RSA and ECDSA keys
+	for name, sig := range map[string]string{
+		"ed25519": testOpenPGPSignatureEd25519,
+		"rsa":     testOpenPGPSignatureRSA,
+		"ecdsa":   testOpenPGPSignatureECDSA,
+	} {
+		assert.Nil(t, v.Verify(message, []byte(sig)), name)
+		assert.Error(t, v.Verify([]byte("Other message"), []byte(sig)), name)
+	}
+
+	// Binary signature
+	sig, err := base64.StdEncoding.DecodeString(testOpenPGPSignatureBinary)
+	require.Nil(t, err)
+	assert.Nil(t, v.Verify(message, sig))
+
+	// Text signature with other line endings
+	text := testOpenPGPSignatureText
+	assert.Nil(t, v.Verify([]byte("Hello\nWorld\n"), []byte(text)))
+	assert.Nil(t, v.Verify([]byte("Hello\r\nWorld\r\n"), []byte(text)))
+
+	// Signature of an untrusted key
+	assert.Error(t, v.Verify(message, []byte(testOpenPGPSignatureOther)))
+
+	// Invalid signatures
+	assert.Error(t, v.Verify(message, []byte("not a signature")))
+	assert.Error(t, v.Verify(message, nil))
+}
+
+func TestOpenPGPVerifierKeyring(t *testing.T) {
+	// Binary keyring
+	b := dearmorOpenPGP(t, testOpenPGPKeyringOther)
+	v, err := NewOpenPGPVerifier(bytes.NewReader(b))
+	require.Nil(t, err)
+	assert.Nil(t, v.Verify([]byte("Hello World!\n"), []byte(testOpenPGPSignatureOther)))
+
+	// Invalid keyrings
+	_, err = NewOpenPGPVerifier(strings.NewReader(""))
+	assert.Error(t, err)
+	_, err = NewOpenPGPVerifier(strings.NewReader("-----BEGIN PGP PUBLIC KEY BLOCK-----\n\n!!!\n"))
+	assert.Error(t, err)
+}
+
+func TestOpenPGPVerifierSubkeys(t *testing.T) {
+	v, err := NewOpenPGPVerifier(strings.NewReader(testOpenPGPKeyringSubkeys))
+	require.Nil(t, err, "Could not read keyring: %v", err)
+
+	message := []byte("Hello World!\n")
+
+	// Only the primary key and the signing subkey are trusted, not the
+	// encryption subkey
+	keyring := v.(*openpgpVerifier).keyring
+	var ids []string
+	for _, e := range keyring {
+		ids = append(ids, fmt.Sprintf("%016x", e.PrimaryKey.KeyId))
+		for _, k := range e.Subkeys {
+			if len(keyring.KeysByIdUsage(k.PublicKey.KeyId, packet.KeyFlagSign)) > 0 {
+				ids = append(ids, fmt.Sprintf("%016x", k.PublicKey.KeyId))
+			}
+		}
+	}
+	assert.Equal(t, []string{"10765f6e5fcaa311", "34267c32f9c4ff1d"}, ids)
+
+	// Signature of the signing subkey
+	assert.Nil(t, v.Verify(message, []byte(testOpenPGPSignatureSubkey)))
+
+	// Signature with an unknown critical subpacket
+	err = v.Verify(message, []byte(testOpenPGPSignatureCritical))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "critical")
+
+	// Subkey appended to a trusted key without binding signature
+	b := dearmorOpenPGP(t, testOpenPGPKeyring)
+	other := dearmorOpenPGP(t, testOpenPGPKeyringOther)
+	require.Equal(t, byte(0x80|6<<2), other[0], "Expected an old format public key packet")
+	b = append(b, 0x80|14<<2, other[1])
+	b = append(b, other[2:2+int(other[1])]...)
+
+	v, err = NewOpenPGPVerifier(bytes.NewReader(b))
+	if err == nil {
+		assert.Error(t, v.Verify(message, []byte(testOpenPGPSignatureOther)))
+	}
+}
+
+func TestOpenPGPVerifierExpiredRevoked(t *testing.T) {
+	message := []byte("Hello World!\n")
+
+	// Key that expired a day after it was created
+	v, err := NewOpenPGPVerifier(strings.NewReader(testOpenPGPKeyringExpired))
+	require.Nil(t, err, "Could not read keyring: %v", err)
+	err = v.Verify(message, []byte(testOpenPGPSignatureExpired))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "expired key")
+
+	// Key with a revocation signature
+	v, err = NewOpenPGPVerifier(strings.NewReader(testOpenPGPKeyringRevoked))
+	require.Nil(t, err, "Could not read keyring: %v", err)
+	err = v.Verify(message, []byte(testOpenPGPSignatureRevoked))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "revoked key")
+}
+
+func dearmorOpenPGP(t *testing.T, s string) []byte {
+	block, err := armor.Decode(strings.NewReader(s))
+	require.Nil(t, err)
+	b, err := ioutil.ReadAll(block.Body)
+	require.Nil(t, err)
+	return b
+}
+
+func TestUpdaterOpenPGP(t *testing.T) {
+	v, err := NewOpenPGPVerifier(strings.NewReader(testOpenPGPKeyring))
+	require.Nil(t, err)
+
+	newAsset := func(name, contents string) *testAsset {
+		return &testAsset{
+			name: name,
+			write: func(w io.Writer) error {
+				_, err := w.Write([]byte(contents))
+				return err
+			},
+		}
+	}
+
+	b := NewAbortBuffer(nil)
+	u := &Updater{
+		ChecksumAssetName: "SHA256SUMS",
+		SignatureSuffix:   ".asc",
+		Verifier:          v,
+		WriterForAsset: func(a Asset) (AbortWriter, error) {
+			if a.Name() == "myapp" {
+				return b, nil
+			}
+			return nil, nil
+		},
+	}
+
+	r := &testRelease{assets: []Asset{
+		newAsset("myapp", "Hello World!\n"),
+		newAsset("myapp.asc", testOpenPGPSignatureEd25519),
+		newAsset("SHA256SUMS", testOpenPGPChecksums),
+		newAsset("SHA256SUMS.asc", testOpenPGPChecksumsSignature),
+	}}
+	err = u.UpdateTo(r)
+	assert.Nil(t, err, "Could not update: %v", err)
+	assert.Equal(t, "Hello World!\n", b.Buffer.String())
+}
+
+var testOpenPGPKeyring = `-----BEGIN PGP PUBLIC KEY BLOCK-----
+
+mDMEatF/2xYJKwYBBAHaRw8BAQdATgsRJgkH/tGkOWwLDPC793K+hWkKfavrgNYr
+1FWLTYe0GFRlc3QgRWQgPGVkQGV4YW1wbGUuY29tPoiQBBMWCAA4FiEEO8vEvmh+
+m+Zg0DpafwLPrZJnGmYFAmrRf9sCGwMFCwkIBwIGFQoJCAsCBBYCAwECHgECF4AA
+CgkQfwLPrZJnGmb6uwEA1qMet6XzsQqbrnlim2fV4TIgxhHXtfEVCKPM5035km0A
+/0S0KcEah/KdmScmlT8KbTG2WU08AHkFmQdsbm+QruAPmQENBGrRf9sBCADRiGFH
+RDOIF0Iwxm04unX7Uo6Y4kjx4uc8p1L3sUV/RzCrbOq1uFgKhsxMPwT4JRjsNd6R
+uQGFoYM9MkHqnGTxB9/beneDySIb/unsIBv+cGXQwhSonWHbQosZX8k8P/69fyw/
+Zbr1T+iQ9WApSC2wdK4epYFseChxC/4/I6wOZQtZiRObVIfZ6v1vb8paslsGP9q2
+L1B5enyKFosoXy/bBr02/97P6lQc666Z132ztaKOOdEH5KTfIzsocUlfCgyLNs4V
+yrrmnXZvFhym2EpI5vIxFcDNt4Dhvd4gD/9T6VoamhLehIeJfIi4kuSPRlOA1r1s
+ENIx+JnKwT52CaxNABEBAAG0GlRlc3QgUlNBIDxyc2FAZXhhbXBsZS5jb20+iQFO
+BBMBCgA4FiEEEmCtHeSQurcMqSh0yaqX3ZF2TwQFAmrRf9sCGwMFCwkIBwIGFQoJ
+CAsCBBYCAwECHgECF4AACgkQyaqX3ZF2TwTLNAf+NMDRSP1GtWzQl9Rjto2sjpng
+F0zuWWKIHm0ZOvLlV1soTIcq6dDzhmcdwJQ26mIi3ZQt/RJaPw2md2EHeWRvDCb6
+n6P2eEydPoaj3VFROXJlLS7w334AdJlyunzXByLgdIbE8oRyfOQwZ10v5WngI5LX
+CCjvq6m1pJgjoIvMqkYMnNjRxak6X6vn6ABoP27mrMa0hngXSjnE78X0EzKMGAvo
+J0q2WznNlL84I6SdRE39V+ZdjNSOXR5j4IjRjhTIIY47n2KhudKcbHFYQmkaW7q5
+mv3Q5OWvgoikxqBpIlZW/s8qtXc/xvTOTVg7bocP4MFisFgv+6gTURm8zTwoF5hS
+BGrRf9wTCCqGSM49AwEHAgMEm9KevJTh2tf2gv/IdKe50GqaIHKnQTmg5fWtJ/68
+WehZcGVnSksBqxxGp21eJv6G8iswWg8YfA4f74wHG1jZirQYVGVzdCBFQyA8ZWNA
+ZXhhbXBsZS5jb20+iJAEExMIADgWIQQn3F9C+I85T0xbvjfXE8ndP9LN9QUCatF/
+3AIbAwULCQgHAgYVCgkICwIEFgIDAQIeAQIXgAAKCRDXE8ndP9LN9QX1AP4zF7e6
+NNv9gJIZraALiDXvNnlfg/TgvICdETZmeqhPzAD/dcBMJLNN+7KGp+y4Vo+wPUep
+zMtLqHZf6DXOEt6EcN4=
+=pgHn
+-----END PGP PUBLIC KEY BLOCK-----
+`
+
+var testOpenPGPKeyringOther = `-----BEGIN PGP PUBLIC KEY BLOCK-----
+
+mDMEatF/3BYJKwYBBAHaRw8BAQdA8sCSpUwt5tbIdyV/G1FZ9WBLbZtm61DrUZIx
+7/4XS8+0GU90aGVyIDxvdGhlckBleGFtcGxlLmNvbT6IkAQTFggAOBYhBNTT8jwj
+BOZiIrNUIIuus5BuCpW/BQJq0X/cAhsDBQsJCAcCBhUKCQgLAgQWAgMBAh4BAheA
+AAoJEIuus5BuCpW/trcBAL1YlMcnANrxEbc1rdgSWDTC8AY0IY1Pj/7K5fBIjd6P
+AQCcC6WvCTXwPXmvXAtinpnr81AAZbtOnZJa/D8Z35/7DQ==
+=6/az
+-----END PGP PUBLIC KEY BLOCK-----
+`
+
+var testOpenPGPKeyringSubkeys = `-----BEGIN PGP PUBLIC KEY BLOCK-----
+
+mDMEatHPrxYJKwYBBAHaRw8BAQdA/wnTxESzx8/Dk11dPEpnFYTyTg73yhwo/qz6
+vZOr5X60IlRlc3QgU3Via2V5cyA8c3Via2V5c0BleGFtcGxlLmNvbT6IkAQTFggA
+OBYhBFQV4RQ2NR29yKIH4BB2X25fyqMRBQJq0c+vAhsBBQsJCAcCBhUKCQgLAgQW
+AgMBAh4BAheAAAoJEBB2X25fyqMRPWgBAPzWcAjopyV/nP1IvG9uJAij8u7oHJNT
+QZaWT/5cjrRGAQC/zCFtbDhp4ljpDjrw4XSk/7lrvn/+gMuimN7fsK7cCLgzBGrR
+z68WCSsGAQQB2kcPAQEHQMCabXl3L3SwH/ZH9SjT8Q0ykes9WzadCTD+6zWXmzCG
+iO8EGBYIACAWIQRUFeEUNjUdvciiB+AQdl9uX8qjEQUCatHPrwIbAgCBCRAQdl9u
+X8qjEXYgBBkWCAAdFiEEndM011tcOn93qgPSNCZ8MvnE/x0FAmrRz68ACgkQNCZ8
+MvnE/x287gD/X2CQ9nafA0ak+q1om3hQ7rR7EqdK1P2Xglkat7AnQIkA/3g4Pvmn
+wF6oI0UXaZywF/mAeXFCWnrx4qRqjItJzjUIgf4A/16SZQI4M1WhZ54B4C8ZQAo7
+3dfuczhvZZJ0Y4Ph1H8JAQCVb/lAbL7Poi+goP2272jzkTkfqAAzIbLGVWbixYkt
+AbkBDQRq0c+vAQgA3teNcE7PYD6mc1327FM3sm4IzUhtN8JqBCEHZO+9zcPuteAZ
+kvXL2I875fkJsO+1i/4QFeYUfl+ZBhHuI2425RuyY/+7xK8OWglv0s+JPd5KKv4x
+SCx2mE1khpTImhLsaLI/6z08f5bnZccxXvKJ7HFmVEpE3LZ5atUJcDxMgaFE03OJ
+qKNKF438XmqWVCrMwq4TS47VZycGjxiqwzR3CfNT2NxitoIvQ8nftLYQMqxyL4WZ
+5J7iHtl/rKGUledkra91OGjIFZVjm4/9uA3UMEYT1diLSboSbCqY9nABe9cnjhqx
+84lyvT1gin0H1mrL/drinG4GEJInD1BbCfAM8QARAQABiHgEGBYIACAWIQRUFeEU
+NjUdvciiB+AQdl9uX8qjEQUCatHPrwIbDAAKCRAQdl9uX8qjEQPdAP9B7koz/qH6
+PdHrzol8LhZ06Aelv1b0+3P609hQoPv42AD+Pa5msIUCc544x3/Tdy0lCekIDNMq
+SnbOekv31xn+5gw=
+=U2Gq
+-----END PGP PUBLIC KEY BLOCK-----
+`
+
+var testOpenPGPSignatureEd25519 = `-----BEGIN PGP SIGNATURE-----
+
+iHUEABYIAB0WIQQ7y8S+aH6b5mDQOlp/As+tkmcaZgUCatF/4AAKCRB/As+tkmca
+ZhEcAP4zjKRaoBr12C0pKwWKr7+MYPhujEWm1sfVaNwQR4VnTwD8D5+ozxOXQ9bR
+EKzkO0B59IOknijFycGytWD5Cf3WNQo=
+=M1Q4
+-----END PGP SIGNATURE-----
+`
+
+var testOpenPGPSignatureRSA = `-----BEGIN PGP SIGNATURE-----
+
+iQEzBAABCAAdFiEEEmCtHeSQurcMqSh0yaqX3ZF2TwQFAmrRf+AACgkQyaqX3ZF2
+TwR7fAf/XYTu1muiVtb12oNpDIGavy/BjGAq/ELlNoYe7/Lfo0LegDXdpi1WijBn
+roAGJB/uOKObHo4PvoVTOL2H59JslVbPOGa4OvPRel/kbUMFzVQavUAbGisd9AYp
+qexcDu+OlS/v5d6s4GiYp9N0NAoHe2q3zA+h5V/axNgNr/lJkoH/rX7ZiUd8HfgO
+g+O75Kn71WCeoi9AX+PY1URIy5cIfDcxllTt+tjXfZzJiuApBpLbzQEWR39AoRxI
+v0TKS0w5MKNXXINC2l4KFvIx1HGkQppCLFi+GtLK04zEHPMPZKyE603GZZH8ybpv
+WruCDqESHv+l7STFhKt5docoxCnkeQ==
+=UfZI
+-----END PGP SIGNATURE-----
+`
+
+var testOpenPGPSignatureECDSA = `-----BEGIN PGP SIGNATURE-----
+
+iHUEABMIAB0WIQQn3F9C+I85T0xbvjfXE8ndP9LN9QUCatF/4AAKCRDXE8ndP9LN
+9ePfAP0d8oE8UWYTQbpPXuP0tzbrt+U4CiHPFhYRqmJfC9B9/wEA1+Tko51Pp/3P
+EoPBTP8cFT7w75PWl2twRPg4fRQQTiU=
+=F7aV
+-----END PGP SIGNATURE-----
+`
+
+var testOpenPGPSignatureOther = `-----BEGIN PGP SIGNATURE-----
+
+iHUEABYIAB0WIQTU0/I8IwTmYiKzVCCLrrOQbgqVvwUCatF/4AAKCRCLrrOQbgqV
+v3qkAQDiCoqU6hlcDcyCNRtMYYHqmdHsGBDVOh0gW7TF2pgFagD/deJohxO/KAcS
+A55fTjNvuvVxLFK6WkoHjTSm6tGXLgk=
+=avok
+-----END PGP SIGNATURE-----
+`
+
+var testOpenPGPSignatureText = `-----BEGIN PGP SIGNATURE-----
+
+iHUEARYIAB0WIQQ7y8S+aH6b5mDQOlp/As+tkmcaZgUCatF/4AAKCRB/As+tkmca
+ZmILAP4zFgNAW8cnpi9LKaXbcjBOxb1gVkG86ImnX5WutQBK7wEA5RLoBlqWkIT1
+rP9hamMOSvyGjRiFGsTF/13Vu7OS/Ac=
+=HZ52
+-----END PGP SIGNATURE-----
+`
+
+var testOpenPGPSignatureBinary = "iHUEABYKAB0WIQQ7y8S+aH6b5mDQOlp/As+tkmcaZgUCatF/4AAKCRB/As+tkmcaZomjAQD5oCp+UYqN4uudqBb15YfqLhjujFzkWQtIsCossaswGwEAz4AZ2qqNVbiP2wJknuddsIwZp64LnfAZr7Cgt82sxg8="
+
+var testOpenPGPChecksums = "03ba204e50d126e4674c005e04d82e84c21366780af1f43bd54a37816b6ab340  myapp\n"
+
+var testOpenPGPChecksumsSignature = `-----BEGIN PGP SIGNATURE-----
+
+iHUEABYIAB0WIQQ7y8S+aH6b5mDQOlp/As+tkmcaZgUCatGAEgAKCRB/As+tkmca
+ZgyoAP4qdb+YddjWDpWWZ37gFZUSisR0fc7Ex13r6gEzEz8dwQD/b/rauCOQUsXR
+M6v6lhr57cjcBy7joUkC/Bdpsoxmvww=
+=tlrI
+-----END PGP SIGNATURE-----
+`
+
+var testOpenPGPSignatureSubkey = `-----BEGIN PGP SIGNATURE-----
+
+iHUEABYIAB0WIQSd0zTXW1w6f3eqA9I0Jnwy+cT/HQUCatHPvQAKCRA0Jnwy+cT/
+HXE8AQDeL7zB5DHSN0tywV3gzdaq2lyYhHiX+cKDrceKfD9XNAD+IbKJ5jayystT
+sXaHkG/UcFwSvx4e1FaSzJGXnvsbVQY=
+=XZX2
+-----END PGP SIGNATURE-----
+`
+
+var testOpenPGPSignatureCritical = `-----BEGIN PGP SIGNATURE-----
+
+iJYEABYIAD4WIQSd0zTXW1w6f3eqA9I0Jnwy+cT/HQUCatHPvSCUgAAAAAAUAANj
+cml0aWNhbEBleGFtcGxlLmNvbXllcwAKCRA0Jnwy+cT/HSeHAQCoCLAParVSFapb
+ssOVcYgPVCo+S2kkz1lMGmAZ+j+PdQEAwsYM5CasUOMjnSL8cpV2UGBJxa/dRiFg
+XaM5rDehVwU=
+=MXZ8
+-----END PGP SIGNATURE-----
+`
+
+var testOpenPGPKeyringExpired = `-----BEGIN PGP PUBLIC KEY BLOCK-----
+
+mDMEZZIAgBYJKwYBBAHaRw8BAQdA3t6loT2MpkCVR1ZHy8VaYIcRNWOtdZKi2s2M
+mC/2PBq0IlRlc3QgRXhwaXJlZCA8ZXhwaXJlZEBleGFtcGxlLmNvbT6IlgQTFggA
+PhYhBHX5rixCW1HQr3GXGnGKH928kk1oBQJlkgCAAhsDBQkAAVGABQsJCAcCBhUK
+CQgLAgQWAgMBAh4BAheAAAoJEHGKH928kk1oUPgBAMOlcl4JuO0rIgVfs3IpU/xI
+iQtyZEWH5doAJVduT8QuAP4s170WzxPnM+QavtGcZCP1P48dw+lu2gyPYvNX8ty8
+Ag==
+=YccF
+-----END PGP PUBLIC KEY BLOCK-----
+`
+
+var testOpenPGPSignatureExpired = `-----BEGIN PGP SIGNATURE-----
+
+iIoEABYIADIWIQR1+a4sQltR0K9xlxpxih/dvJJNaAUCZZIOkBQcZXhwaXJlZEBl
+eGFtcGxlLmNvbQAKCRBxih/dvJJNaIa1APoCNUBsMUuR1KNavLFDE4gN5Iwc5kCv
+HBq84iLUSt0a3QD/Te6JMKkn2Cgh+1ABEclWQfzsJDP/j/kxSgqPhv8PUAs=
+=xIhz
+-----END PGP SIGNATURE-----
+`
+
+var testOpenPGPKeyringRevoked = `-----BEGIN PGP PUBLIC KEY BLOCK-----
+
+mDMEatHXvRYJKwYBBAHaRw8BAQdAr49G1Q3tleLG2ZDFgI7usdQm4atuO+e/QdON
+m9ieEnGIeAQgFggAIBYhBIKyWXW2qZnpnpaM1jVeEz3jhxIiBQJq0de9Ah0AAAoJ
+EDVeEz3jhxIitOwA/29JUQqtVKlDil5lO31nLBs+X3CeioMVfOxajwFGuUy2AQDg
+OfcxP6ezEXF8ao/IafmJYskaMfSQMqv9PDx2tOzJArQiVGVzdCBSZXZva2VkIDxy
+ZXZva2VkQGV4YW1wbGUuY29tPoiQBBMWCAA4FiEEgrJZdbapmemelozWNV4TPeOH
+EiIFAmrR170CGwMFCwkIBwIGFQoJCAsCBBYCAwECHgECF4AACgkQNV4TPeOHEiKy
+iAEAkVpx14GGSE1qWIJY2BHz0gO2mWKvxB6x3mm8yYtsfaYBAPzlFhea/vPOKtYe
+HYjAC5kLCYiws8+i9H2rt5moo7oE
+=K2YO
+-----END PGP PUBLIC KEY BLOCK-----
+`
+
+var testOpenPGPSignatureRevoked = `-----BEGIN PGP SIGNATURE-----
+
+iIoEABYIADIWIQSCsll1tqmZ6Z6WjNY1XhM944cSIgUCatHXvRQccmV2b2tlZEBl
+eGFtcGxlLmNvbQAKCRA1XhM944cSIgQWAP9mdi+mTk+uSpOsQYneITqF2GtpqJyz
+DITSR2r6v0WsrgD9GI9B4GtXDxMyxm+CCXZua3R3ZYzbNz9NfZw5L03TLwc=
+=eFkB
+-----END PGP SIGNATURE-----
+`
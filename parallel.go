@@ -0,0 +1,289 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// defaultChunkSize is the default size of the chunks of parallel downloads.
+const defaultChunkSize = 8 << 20
+
+// parallelDownload configures downloads over multiple connections.
+type parallelDownload struct {
+	connections int
+	chunkSize   int64
+
+	// Asset that is downloaded and the maximum size of the download, if
+	// positive, see limitParallelDownload.
+	asset   Asset
+	maxSize int64
+}
+
+// parallelDownloadKey is the context key of the parallelDownload settings.
+type parallelDownloadKey struct{}
+
+// withParallelDownload returns a context in which GET requests are downloaded
+// in chunks of chunkSize bytes over the given number of connections.
+func withParallelDownload(ctx context.Context, connections int, chunkSize int64) context.Context {
+	if connections <= 1 {
+		return ctx
+	}
+	if chunkSize <= 0 {
+		chunkSize = defaultChunkSize
+	}
+	return context.WithValue(ctx, parallelDownloadKey{}, parallelDownload{
+		connections: connections,
+		chunkSize:   chunkSize,
+	})
+}
+
+// limitParallelDownload returns a context in which parallel downloads of
+// asset a fail if the server reports a size larger than max, if it is
+// positive, or than the size declared by the asset. This is checked before the
+// temporary file for the chunks is allocated.
+func limitParallelDownload(ctx context.Context, a Asset, max int64) context.Context {
+	p, ok := ctx.Value(parallelDownloadKey{}).(parallelDownload)
+	if !ok {
+		return ctx
+	}
+	p.asset, p.maxSize = a, max
+	return context.WithValue(ctx, parallelDownloadKey{}, p)
+}
+
+// checkTotal returns an error if the total size reported by the server
+// exceeds the limits of the download.
+func (p parallelDownload) checkTotal(total int64) error {
+	if p.asset == nil {
+		return nil
+	} else if p.maxSize > 0 && total > p.maxSize {
+		return &AssetTooLargeError{Asset: p.asset, Size: total, Limit: p.maxSize}
+	}
+
+	if m, ok := p.asset.(AssetMeta); ok && m.Size() > 0 && total > m.Size() {
+		return fmt.Errorf("Asset %v of %v bytes is larger than the declared %v bytes.", p.asset.Name(), total, m.Size())
+	}
+	return nil
+}
+
+// downloadParallel is like downloadRequest, but downloads files larger than
+// one chunk with ranged requests over multiple connections.
+//
+// The chunks are written to a sparse temporary file, which is written to w
+// once all chunks have been downloaded. If the server does not support ranged
+// requests, the file is downloaded over a single connection.
+func downloadParallel(ctx context.Context, client *http.Client, req *http.Request, w io.Writer, p parallelDownload) error {
+	client = httpClient(ctx, client)
+	url := redactURL(req.URL)
+
+	// Request the first chunk to find out whether ranges are supported
+	logf(ctx, "%v %v (bytes 0-%v)", req.Method, url, p.chunkSize-1)
+	resp, err := client.Do(rangeRequest(ctx, req, 0, p.chunkSize-1, ""))
+	if err != nil {
+		logf(ctx, "%v %v failed: %v", req.Method, url, err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	start, end, total, ok := parseContentRange(resp.Header.Get("Content-Range"))
+	if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable ||
+		(resp.StatusCode == http.StatusPartialContent && (!ok || start != 0)) {
+		// Empty file or invalid range, download it without ranges
+		resp.Body.Close()
+		_, err := downloadResponse(ctx, client, req, w)
+		return err
+	} else if resp.StatusCode != http.StatusPartialContent {
+		// Ranges are not supported
+		return writeResponse(ctx, req, resp, w)
+	}
+
+	if err := p.checkTotal(total); err != nil {
+		logf(ctx, "Download of %v failed: %v", url, err)
+		return err
+	}
+	if t, ok := w.(totalSetter); ok {
+		t.setTotal(total)
+	}
+
+	if end+1 >= total {
+		// The first chunk is the whole file
+		n, err := io.Copy(w, resp.Body)
+		if err == nil && n != total {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			logf(ctx, "Download of %v failed after %v bytes: %v", url, n, err)
+		} else {
+			logf(ctx, "Downloaded %v bytes from %v", n, url)
+		}
+		return err
+	}
+
+	f, err := ioutil.TempFile("", downloadPrefix)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	err = f.Truncate(total)
+	if err != nil {
+		return err
+	}
+
+	err = writeChunk(f, resp.Body, 0, end)
+	if err != nil {
+		logf(ctx, "Download of %v failed: %v", url, err)
+		return err
+	}
+	resp.Body.Close()
+
+	// Make sure all chunks are from the same file
+	validator := resp.Header.Get("ETag")
+	if validator == "" || strings.HasPrefix(validator, "W/") {
+		validator = resp.Header.Get("Last-Modified")
+	}
+
+	err = downloadChunks(ctx, client, req, f, end+1, total, validator, p)
+	if err != nil {
+		logf(ctx, "Download of %v failed: %v", url, err)
+		return err
+	}
+	logf(ctx, "Downloaded %v bytes from %v over %v connections", total, url, p.connections)
+
+	_, err = f.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// downloadChunks downloads the bytes of req from offset start up to total to
+// f, in chunks over multiple connections.
+func downloadChunks(
+	ctx context.Context,
+	client *http.Client,
+	req *http.Request,
+	f *os.File,
+	start, total int64,
+	validator string,
+	p parallelDownload,
+) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	chunks := make(chan int64)
+	errs := make(chan error, p.connections)
+	var wg sync.WaitGroup
+	for i := 0; i < p.connections; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for offset := range chunks {
+				end := offset + p.chunkSize - 1
+				if end >= total {
+					end = total - 1
+				}
+
+				err := downloadChunk(ctx, client, req, f, offset, end, validator)
+				if err != nil {
+					errs <- err
+					cancel()
+					return
+				}
+			}
+		}()
+	}
+
+feed:
+	for offset := start; offset < total; offset += p.chunkSize {
+		select {
+		case chunks <- offset:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(chunks)
+	wg.Wait()
+
+	select {
+	case err := <-errs:
+		return err
+	default:
+		return ctx.Err()
+	}
+}
+
+// downloadChunk downloads the bytes from start to end, inclusive, of req to
+// the same offset in f.
+func downloadChunk(ctx context.Context, client *http.Client, req *http.Request, f *os.File, start, end int64, validator string) error {
+	logf(ctx, "%v %v (bytes %v-%v)", req.Method, redactURL(req.URL), start, end)
+	resp, err := client.Do(rangeRequest(ctx, req, start, end, validator))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return fmt.Errorf("Could not download %v: the file changed during the download.", redactURL(req.URL))
+	} else if resp.StatusCode != http.StatusPartialContent {
+		return newDownloadError(req, resp)
+	}
+
+	s, e, _, ok := parseContentRange(resp.Header.Get("Content-Range"))
+	if !ok || s != start || e != end {
+		return fmt.Errorf("Could not download %v: unexpected range %q.", redactURL(req.URL), resp.Header.Get("Content-Range"))
+	}
+
+	return writeChunk(f, resp.Body, start, end)
+}
+
+// writeChunk writes the bytes from start to end, inclusive, read from r to
+// the same offset in f.
+func writeChunk(f *os.File, r io.Reader, start, end int64) error {
+	n, err := io.Copy(&offsetWriter{f: f, offset: start}, io.LimitReader(r, end-start+1))
+	if err == nil && n != end-start+1 {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// rangeRequest returns a copy of req for the bytes from start to end,
+// inclusive. If validator is not empty, the server is asked to send the whole
+// file instead if it no longer matches.
+func rangeRequest(ctx context.Context, req *http.Request, start, end int64, validator string) *http.Request {
+	r := req.Clone(ctx)
+	r.Header.Set("Range", fmt.Sprintf("bytes=%v-%v", start, end))
+	if validator != "" {
+		r.Header.Set("If-Range", validator)
+	}
+	return r
+}
+
+// parseContentRange parses a Content-Range header of a response to a ranged
+// request, e.g. "bytes 0-99/1000".
+func parseContentRange(s string) (start, end, total int64, ok bool) {
+	_, err := fmt.Sscanf(s, "bytes %d-%d/%d", &start, &end, &total)
+	if err != nil || start < 0 || end < start || total <= end {
+		return 0, 0, 0, false
+	}
+	return start, end, total, true
+}
+
+// offsetWriter writes to f sequentially, starting at offset.
+type offsetWriter struct {
+	f      *os.File
+	offset int64
+}
+
+func (w *offsetWriter) Write(p []byte) (int, error) {
+	n, err := w.f.WriteAt(p, w.offset)
+	w.offset += int64(n)
+	return n, err
+}
@@ -0,0 +1,174 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadParallel(t *testing.T) {
+	data := make([]byte, 100000)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	var ranged, plain int32
+	var changed int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" {
+			atomic.AddInt32(&ranged, 1)
+		} else {
+			atomic.AddInt32(&plain, 1)
+		}
+
+		switch r.URL.Path {
+		case "/ranges":
+			http.ServeContent(w, r, "myapp", time.Time{}, bytes.NewReader(data))
+		case "/changing":
+			etag := `"v1"`
+			if atomic.SwapInt32(&changed, 1) == 1 {
+				etag = `"v2"`
+			}
+			w.Header().Set("ETag", etag)
+			http.ServeContent(w, r, "myapp", time.Time{}, bytes.NewReader(data))
+		case "/no-ranges":
+			w.Write(data)
+		case "/empty":
+			http.ServeContent(w, r, "myapp", time.Time{}, strings.NewReader(""))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	ctx := withParallelDownload(context.Background(), 4, 10000)
+	reset := func() {
+		atomic.StoreInt32(&ranged, 0)
+		atomic.StoreInt32(&plain, 0)
+	}
+
+	// Server supporting ranges
+	{
+		reset()
+		w := &testTotalWriter{}
+		err := download(ctx, nil, ts.URL+"/ranges", w)
+		require.Nil(t, err, "Could not download: %v", err)
+		assert.Equal(t, data, w.Bytes())
+		assert.Equal(t, int64(len(data)), w.total)
+		assert.Equal(t, int32(10), atomic.LoadInt32(&ranged))
+		assert.Equal(t, int32(0), atomic.LoadInt32(&plain))
+	}
+
+	// File smaller than one chunk
+	{
+		reset()
+		buf := bytes.NewBuffer(nil)
+		err := download(withParallelDownload(context.Background(), 4, 1<<20), nil, ts.URL+"/ranges", buf)
+		require.Nil(t, err, "Could not download: %v", err)
+		assert.Equal(t, data, buf.Bytes())
+		assert.Equal(t, int32(1), atomic.LoadInt32(&ranged))
+	}
+
+	// Server not supporting ranges
+	{
+		reset()
+		buf := bytes.NewBuffer(nil)
+		err := download(ctx, nil, ts.URL+"/no-ranges", buf)
+		require.Nil(t, err, "Could not download: %v", err)
+		assert.Equal(t, data, buf.Bytes())
+		assert.Equal(t, int32(1), atomic.LoadInt32(&ranged))
+	}
+
+	// Larger than the maximum size
+	{
+		reset()
+		limited := limitParallelDownload(ctx, &testAsset{name: "myapp"}, 20000)
+		err := download(limited, nil, ts.URL+"/ranges", ioutil.Discard)
+		if assert.IsType(t, &AssetTooLargeError{}, err) {
+			assert.Equal(t, int64(len(data)), err.(*AssetTooLargeError).Size)
+		}
+		assert.Equal(t, int32(1), atomic.LoadInt32(&ranged))
+	}
+
+	// Larger than the declared size
+	{
+		reset()
+		limited := limitParallelDownload(ctx, &testSizedAsset{testAsset{name: "myapp"}, 20000}, 0)
+		err := download(limited, nil, ts.URL+"/ranges", ioutil.Discard)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "declared")
+		}
+		assert.Equal(t, int32(1), atomic.LoadInt32(&ranged))
+	}
+
+	// Empty file
+	{
+		buf := bytes.NewBuffer(nil)
+		err := download(ctx, nil, ts.URL+"/empty", buf)
+		assert.Nil(t, err, "Could not download: %v", err)
+		assert.Equal(t, 0, buf.Len())
+	}
+
+	// File changing during the download
+	{
+		err := download(ctx, nil, ts.URL+"/changing", ioutil.Discard)
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "changed")
+	}
+
+	// Missing file
+	{
+		err := download(ctx, nil, ts.URL+"/missing", ioutil.Discard)
+		if assert.IsType(t, &DownloadError{}, err) {
+			assert.Equal(t, http.StatusNotFound, err.(*DownloadError).StatusCode)
+		}
+	}
+}
+
+func TestUpdaterDownloadConnections(t *testing.T) {
+	data := make([]byte, 50000)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	var ranged int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" {
+			atomic.AddInt32(&ranged, 1)
+		}
+		http.ServeContent(w, r, "myapp", time.Time{}, bytes.NewReader(data))
+	}))
+	defer ts.Close()
+
+	b := NewAbortBuffer(nil)
+	u := &Updater{
+		WriterForAsset: func(Asset) (AbortWriter, error) {
+			return b, nil
+		},
+		MirrorResolver: func(Asset) []string {
+			return []string{ts.URL + "/myapp"}
+		},
+		DownloadConnections: 3,
+		DownloadChunkSize:   10000,
+	}
+
+	err := u.UpdateTo(&testRelease{assets: []Asset{&testAsset{name: "myapp"}}})
+	require.Nil(t, err, "Could not update: %v", err)
+	assert.Equal(t, data, b.Buffer.Bytes())
+	assert.Equal(t, int32(5), atomic.LoadInt32(&ranged))
+}
+
+type testTotalWriter struct {
+	bytes.Buffer
+	total int64
+}
+
+func (w *testTotalWriter) setTotal(total int64) {
+	w.total = total
+}
@@ -0,0 +1,177 @@
+package updater
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// Magic bytes at the start of a bsdiff patch.
+const bsdiffMagic = "BSDIFF40"
+
+// Maximum size of a patched file relative to the old file, used when the
+// updater has no MaxAssetSize. The size in the header of a patch is not
+// verified yet, so it cannot be trusted to allocate the result.
+const (
+	maxPatchGrowth    = 4
+	maxPatchExtraSize = 64 << 20
+)
+
+var errCorruptPatch = errors.New("Corrupt patch.")
+
+// patchAsset returns the patch asset of release that updates the current
+// release to release, or nil if there is none.
+//
+// Patches are only used when the result can be verified with a checksum.
+func (u *Updater) patchAsset(release Release) Asset {
+	current := u.currentIdentifier()
+	if u.PatchAssetName == "" || u.ChecksumAssetName == "" || current == "" {
+		return nil
+	}
+
+	name := strings.NewReplacer(
+		"{old}", current,
+		"{new}", release.Identifier(),
+	).Replace(u.PatchAssetName)
+	return findAsset(release, name)
+}
+
+// patchedAsset is an asset that is written by applying a patch to an old
+// version of the asset.
+type patchedAsset struct {
+	// Asset that is patched.
+	Asset
+
+	u     *Updater
+	patch Asset
+	old   string
+}
+
+// patchedRelease is a release with a single asset replaced by a patched one.
+type patchedRelease struct {
+	Release
+
+	asset *patchedAsset
+}
+
+func (r *patchedRelease) Assets() []Asset {
+	s := r.Release.Assets()
+	assets := make([]Asset, len(s))
+	for i, a := range s {
+		if a == r.asset.Asset {
+			assets[i] = r.asset
+		} else {
+			assets[i] = a
+		}
+	}
+	return assets
+}
+
+func (a *patchedAsset) Write(w io.Writer) error {
+	return a.WriteContext(context.Background(), w)
+}
+
+// WriteContext downloads the patch and writes the result of applying it to
+// the old file.
+func (a *patchedAsset) WriteContext(ctx context.Context, w io.Writer) error {
+	patch := bytes.NewBuffer(nil)
+	err := a.u.writeAsset(ctx, a.patch, patch)
+	if err != nil {
+		return err
+	}
+
+	old, err := ioutil.ReadFile(a.old)
+	if err != nil {
+		return err
+	}
+
+	limit := a.u.MaxAssetSize
+	if limit <= 0 {
+		limit = maxPatchGrowth*int64(len(old)) + maxPatchExtraSize
+	}
+
+	b, err := bspatch(old, patch.Bytes(), limit)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(b)
+	return err
+}
+
+// bspatch applies a patch in the format produced by bsdiff to old. Patches
+// that produce more than limit bytes are rejected.
+func bspatch(old, patch []byte, limit int64) ([]byte, error) {
+	if len(patch) < 32 || string(patch[:8]) != bsdiffMagic {
+		return nil, errCorruptPatch
+	}
+
+	ctrlLen := offtin(patch[8:])
+	diffLen := offtin(patch[16:])
+	newSize := offtin(patch[24:])
+	if ctrlLen < 0 || diffLen < 0 || newSize < 0 || newSize > limit ||
+		ctrlLen > int64(len(patch))-32 || diffLen > int64(len(patch))-32-ctrlLen {
+		return nil, errCorruptPatch
+	}
+
+	body := patch[32:]
+	ctrl := bzip2.NewReader(bytes.NewReader(body[:ctrlLen]))
+	diff := bzip2.NewReader(bytes.NewReader(body[ctrlLen : ctrlLen+diffLen]))
+	extra := bzip2.NewReader(bytes.NewReader(body[ctrlLen+diffLen:]))
+
+	b := make([]byte, newSize)
+	var oldPos, newPos int64
+	buf := make([]byte, 24)
+	for newPos < newSize {
+		// Read control data: bytes to add, bytes to copy and old offset
+		_, err := io.ReadFull(ctrl, buf)
+		if err != nil {
+			return nil, errCorruptPatch
+		}
+		add, extraLen, seek := offtin(buf), offtin(buf[8:]), offtin(buf[16:])
+		if add < 0 || extraLen < 0 || add > newSize-newPos {
+			return nil, errCorruptPatch
+		}
+
+		// Add old data to the diff data
+		_, err = io.ReadFull(diff, b[newPos:newPos+add])
+		if err != nil {
+			return nil, errCorruptPatch
+		}
+		for i := int64(0); i < add; i++ {
+			if p := oldPos + i; p >= 0 && p < int64(len(old)) {
+				b[newPos+i] += old[p]
+			}
+		}
+		newPos += add
+		oldPos += add
+
+		// Copy the extra data
+		if extraLen > newSize-newPos {
+			return nil, errCorruptPatch
+		}
+		_, err = io.ReadFull(extra, b[newPos:newPos+extraLen])
+		if err != nil {
+			return nil, errCorruptPatch
+		}
+		newPos += extraLen
+		oldPos += seek
+	}
+
+	return b, nil
+}
+
+// offtin decodes a signed 64-bit integer in the sign-magnitude format used by
+// bsdiff.
+func offtin(b []byte) int64 {
+	x := int64(binary.LittleEndian.Uint64(b) &^ (1 << 63))
+	if b[7]&0x80 != 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,160 @@
+package updater
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Patch from "old executable" to "patched executable", created with bsdiff.
+var testPatch, _ = base64.StdEncoding.DecodeString(
+	"QlNESUZGNDApAAAAAAAAADoAAAAAAAAAEgAAAAAAAABCWmg5MUFZJlNZ9VP5yAAABcAATAkgACGG" +
+		"gZoMVsm4u5IpwoSHqp/OQEJaaDkxQVkmU1nlKFroAAAA5AmsIMAACAAAAgACQgCgACIA0NBA0DQe" +
+		"Q+yDnaR3i7kinChIcpQtdABCWmg5MUFZJlNZhAoT1AAAAAEAMgQgACGaaDNNMLxdyRThQkIQKE9Q",
+)
+
+func TestBspatch(t *testing.T) {
+	// Valid patch
+	{
+		b, err := bspatch([]byte("old executable"), testPatch, 1024)
+		assert.Nil(t, err, "Unexpected patch error: %v", err)
+		assert.Equal(t, "patched executable", string(b))
+	}
+
+	// Invalid header
+	{
+		_, err := bspatch([]byte("old executable"), []byte("BSDIFF40"), 1024)
+		assert.Equal(t, errCorruptPatch, err)
+
+		_, err = bspatch([]byte("old executable"), append([]byte("BSDIFF41"), testPatch[8:]...), 1024)
+		assert.Equal(t, errCorruptPatch, err)
+	}
+
+	// Truncated patch
+	{
+		_, err := bspatch([]byte("old executable"), testPatch[:60], 1024)
+		assert.Equal(t, errCorruptPatch, err)
+	}
+
+	// New size above the limit
+	{
+		_, err := bspatch([]byte("old executable"), testPatch, 17)
+		assert.Equal(t, errCorruptPatch, err)
+	}
+
+	// Crafted new size that cannot be allocated
+	{
+		patch := append([]byte(nil), testPatch...)
+		binary.LittleEndian.PutUint64(patch[24:], 1<<62)
+		_, err := bspatch([]byte("old executable"), patch, maxPatchExtraSize)
+		assert.Equal(t, errCorruptPatch, err)
+	}
+}
+
+func TestUpdaterSelfUpdatePatch(t *testing.T) {
+	// Fake executable
+	f, err := ioutil.TempFile("", "testing-")
+	require.Nil(t, err)
+	exe := f.Name()
+	f.Close()
+	defer os.Remove(exe)
+
+	defer func() { osExecutable = os.Executable }()
+	osExecutable = func() (string, error) { return exe, nil }
+
+	fullWritten := false
+	full := &testAsset{
+		name: "myapp",
+		write: func(w io.Writer) error {
+			fullWritten = true
+			_, err := io.WriteString(w, "patched executable")
+			return err
+		},
+	}
+	checksums := &testAsset{
+		name: "checksums.txt",
+		write: func(w io.Writer) error {
+			_, err := io.WriteString(w, "5825ed20cd1aec803c1b7b8025f32d6c78528a1d2782e670d3a8ce838ad4f0c3  myapp\n")
+			return err
+		},
+	}
+	newPatch := func(data []byte) *testAsset {
+		return &testAsset{
+			name: "myapp_v1_v2.patch",
+			write: func(w io.Writer) error {
+				_, err := w.Write(data)
+				return err
+			},
+		}
+	}
+	newUpdater := func(assets ...Asset) *Updater {
+		release := &testRelease{identifier: "v2", assets: assets}
+		return &Updater{
+			App: &testApp{
+				FLatestRelease: func() Release { return release },
+			},
+			CurrentReleaseIdentifier: "v1",
+			AssetFilter:              func(a Asset) bool { return a.Name() == "myapp" },
+			ChecksumAssetName:        "checksums.txt",
+			PatchAssetName:           "myapp_{old}_{new}.patch",
+		}
+	}
+
+	// Apply patch
+	{
+		err := ioutil.WriteFile(exe, []byte("old executable"), 0755)
+		require.Nil(t, err)
+		fullWritten = false
+
+		u := newUpdater(full, checksums, newPatch(testPatch))
+		r, err := u.SelfUpdate()
+		assert.Nil(t, err, "Unexpected error: %v", err)
+		assert.NotNil(t, r)
+		assert.False(t, fullWritten)
+		assert.Equal(t, "patched executable", readTestFile(t, exe))
+	}
+
+	// Patch for another executable falls back to full asset
+	{
+		err := ioutil.WriteFile(exe, []byte("other executable"), 0755)
+		require.Nil(t, err)
+		fullWritten = false
+
+		u := newUpdater(full, checksums, newPatch(testPatch))
+		r, err := u.SelfUpdate()
+		assert.Nil(t, err, "Unexpected error: %v", err)
+		assert.NotNil(t, r)
+		assert.True(t, fullWritten)
+		assert.Equal(t, "patched executable", readTestFile(t, exe))
+	}
+
+	// Corrupt patch falls back to full asset
+	{
+		err := ioutil.WriteFile(exe, []byte("old executable"), 0755)
+		require.Nil(t, err)
+		fullWritten = false
+
+		u := newUpdater(full, checksums, newPatch([]byte("corrupt")))
+		_, err = u.SelfUpdate()
+		assert.Nil(t, err, "Unexpected error: %v", err)
+		assert.True(t, fullWritten)
+		assert.Equal(t, "patched executable", readTestFile(t, exe))
+	}
+
+	// Patches are not used without checksums
+	{
+		u := newUpdater(full, checksums, newPatch(testPatch))
+		release, err := u.Check()
+		require.Nil(t, err)
+		assert.NotNil(t, u.patchAsset(release))
+
+		u.ChecksumAssetName = ""
+		assert.Nil(t, u.patchAsset(release))
+	}
+}
@@ -0,0 +1,115 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"io"
+)
+
+// AssetVerification verifies an asset while it is written.
+//
+// An update is applied in stages:
+//
+//  1. Every asset is streamed to its writer, e.g. the temporary file of a
+//     DelayedFile, and to its verifications at the same time.
+//  2. Every written asset is verified: first its checksum, then its
+//     signature and finally the verifications of the Checks of the Updater,
+//     in order.
+//  3. Once all assets have been verified, the release is validated with
+//     Validate of the Updater.
+//  4. The writers are committed, e.g. by closing them or committing the
+//     Transaction of the Updater.
+//
+// When a stage fails, all writers are aborted and nothing is committed.
+type AssetVerification interface {
+	// Write receives the data of the asset. It is called with every byte of
+	// the asset exactly once, also when a failed download is retried.
+	io.Writer
+
+	// Verify is called once the asset has been written completely. If it
+	// returns an error, the update fails.
+	Verify() error
+}
+
+// AssetCheck is a custom verification step of the update pipeline, see
+// AssetVerification.
+//
+// It is called before asset a of release is written, and returns the
+// verification of the asset, or nil if the asset should not be verified.
+type AssetCheck func(release Release, a Asset) AssetVerification
+
+// BufferCheck returns an AssetCheck that buffers the assets accepted by
+// filter in memory and passes them to verify once they have been written. All
+// assets are accepted if filter is nil.
+func BufferCheck(filter func(Asset) bool, verify func(release Release, a Asset, data []byte) error) AssetCheck {
+	return func(release Release, a Asset) AssetVerification {
+		if filter != nil && !filter(a) {
+			return nil
+		}
+
+		buf := bytes.NewBuffer(nil)
+		return &verification{
+			Writer: buf,
+			verify: func() error {
+				return verify(release, a, buf.Bytes())
+			},
+		}
+	}
+}
+
+// verification is an AssetVerification calling a function to verify the
+// asset.
+type verification struct {
+	io.Writer
+	name   string
+	verify func() error
+}
+
+func (v *verification) Verify() error {
+	return v.verify()
+}
+
+// verificationName returns the kind of verification of v for reports.
+func verificationName(v AssetVerification) string {
+	if v, ok := v.(*verification); ok && v.name != "" {
+		return v.name
+	}
+	return "check"
+}
+
+// verifications returns the verifications of asset a of release, in the
+// order in which they should be verified.
+func (u *Updater) verifications(ctx context.Context, release Release, checksums map[string][]byte, a Asset) []AssetVerification {
+	var vs []AssetVerification
+
+	if checksums != nil && a.Name() != u.ChecksumAssetName {
+		h := sha256.New()
+		vs = append(vs, &verification{
+			Writer: h,
+			name:   "checksum",
+			verify: func() error {
+				return verifyChecksum(checksums, a, h.Sum(nil))
+			},
+		})
+	}
+
+	if u.Verifier != nil && !u.isSignature(a) {
+		buf := bytes.NewBuffer(nil)
+		vs = append(vs, &verification{
+			Writer: buf,
+			name:   "signature",
+			verify: func() error {
+				return u.verifySignature(ctx, release, a, buf.Bytes())
+			},
+		})
+	}
+
+	for _, check := range u.Checks {
+		if v := check(release, a); v != nil {
+			vs = append(vs, v)
+		}
+	}
+
+	return vs
+}
@@ -0,0 +1,100 @@
+package updater
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testVerification struct {
+	name  string
+	data  []byte
+	err   error
+	order *[]string
+}
+
+func (v *testVerification) Write(p []byte) (int, error) {
+	v.data = append(v.data, p...)
+	return len(p), nil
+}
+
+func (v *testVerification) Verify() error {
+	*v.order = append(*v.order, v.name)
+	return v.err
+}
+
+func TestUpdaterChecks(t *testing.T) {
+	newRelease := func() *testRelease {
+		return &testRelease{assets: []Asset{
+			&testAsset{
+				name: "myapp",
+				write: func(w io.Writer) error {
+					_, err := w.Write([]byte("Hello World!"))
+					return err
+				},
+			},
+			&testAsset{name: "README"},
+		}}
+	}
+
+	// Buffered check of a single asset
+	{
+		var checked []string
+		var data string
+		b := NewAbortBuffer(nil)
+		u := &Updater{
+			WriterForAsset: func(Asset) (AbortWriter, error) { return b, nil },
+			Checks: []AssetCheck{
+				BufferCheck(
+					func(a Asset) bool { return a.Name() == "myapp" },
+					func(r Release, a Asset, d []byte) error {
+						checked = append(checked, a.Name())
+						data = string(d)
+						return nil
+					},
+				),
+			},
+		}
+
+		err := u.UpdateTo(newRelease())
+		assert.Nil(t, err, "Could not update: %v", err)
+		assert.Equal(t, []string{"myapp"}, checked)
+		assert.Equal(t, "Hello World!", data)
+		assert.False(t, b.aborted)
+	}
+
+	// Checks are verified in order and stop at the first failure
+	{
+		checkErr := errors.New("Invalid asset.")
+		var order []string
+		var validated bool
+		b := NewAbortBuffer(nil)
+		u := &Updater{
+			WriterForAsset: func(Asset) (AbortWriter, error) { return b, nil },
+			AssetFilter:    func(a Asset) bool { return a.Name() == "myapp" },
+			Checks: []AssetCheck{
+				func(Release, Asset) AssetVerification {
+					return &testVerification{name: "first", order: &order}
+				},
+				func(Release, Asset) AssetVerification {
+					return &testVerification{name: "second", err: checkErr, order: &order}
+				},
+				func(Release, Asset) AssetVerification {
+					return &testVerification{name: "third", order: &order}
+				},
+			},
+			Validate: func(Release, []string) error {
+				validated = true
+				return nil
+			},
+		}
+
+		err := u.UpdateTo(newRelease())
+		assert.Equal(t, checkErr, err)
+		assert.Equal(t, []string{"first", "second"}, order)
+		assert.False(t, validated)
+		assert.True(t, b.aborted)
+	}
+}
@@ -0,0 +1,144 @@
+package updater
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// downloadedRelease is a release whose assets were downloaded ahead of time,
+// to apply it quickly later, see AutoUpdater.Windows.
+type downloadedRelease struct {
+	Release
+
+	dir    string
+	assets []Asset
+}
+
+// downloadedAsset is an asset that was downloaded to a file.
+type downloadedAsset struct {
+	Asset
+
+	path string
+}
+
+// predownload downloads the assets of release that UpdateTo writes to dir,
+// including checksums and signatures. The directory is removed if the
+// download fails.
+func (u *Updater) predownload(ctx context.Context, release Release, dir string) (*downloadedRelease, error) {
+	ctx = withLogger(withHTTPClient(ctx, u.HTTPClient), u.Logger)
+	ctx, err := u.withInstallationID(ctx)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	r := &downloadedRelease{Release: release, dir: dir}
+	for i, a := range release.Assets() {
+		if !u.predownloads(a) {
+			r.assets = append(r.assets, a)
+			continue
+		}
+
+		p := filepath.Join(dir, "asset-"+strconv.Itoa(i))
+		err := u.downloadTo(ctx, a, p)
+		if err != nil {
+			r.remove()
+			return nil, err
+		}
+		r.assets = append(r.assets, &downloadedAsset{Asset: a, path: p})
+	}
+
+	u.logf("Downloaded release %v to %v", release.Name(), dir)
+	return r, nil
+}
+
+// predownloads reports whether asset a is downloaded ahead of time.
+func (u *Updater) predownloads(a Asset) bool {
+	switch {
+	case a.Name() == u.ChecksumAssetName, a.Name() == u.KeyAssetName, u.isSignature(a):
+		return true
+	}
+	return u.AssetFilter == nil || u.AssetFilter(a)
+}
+
+// downloadTo writes asset a to a file at path.
+func (u *Updater) downloadTo(ctx context.Context, a Asset, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	err = u.writeAsset(ctx, a, f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (r *downloadedRelease) Assets() []Asset {
+	return r.assets
+}
+
+// remove deletes the downloaded files.
+func (r *downloadedRelease) remove() {
+	os.RemoveAll(r.dir)
+}
+
+func (a *downloadedAsset) Write(w io.Writer) error {
+	return a.WriteContext(context.Background(), w)
+}
+
+// WriteContext writes the downloaded file. If it no longer exists, e.g.
+// because it was cleaned up, the asset is downloaded again.
+func (a *downloadedAsset) WriteContext(ctx context.Context, w io.Writer) error {
+	err := writeFile(a.path, w)
+	if os.IsNotExist(err) {
+		return writeAsset(ctx, a.Asset, w)
+	}
+	return err
+}
+
+// Open opens the downloaded file.
+func (a *downloadedAsset) Open(ctx context.Context) (io.ReadCloser, int64, error) {
+	rc, size, err := openFile(a.path)
+	if os.IsNotExist(err) {
+		return OpenAsset(ctx, a.Asset)
+	}
+	return rc, size, err
+}
+
+// openFile opens the file at path and returns its size.
+func openFile(path string) (io.ReadCloser, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+	return f, info.Size(), nil
+}
+
+// writeFile writes the file at path to w, and informs w of its size.
+func writeFile(path string, w io.Writer) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if t, ok := w.(totalSetter); ok {
+		if info, err := f.Stat(); err == nil {
+			t.setTotal(info.Size())
+		}
+	}
+
+	_, err = io.Copy(w, f)
+	return err
+}
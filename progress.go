@@ -0,0 +1,70 @@
+package updater
+
+import "io"
+
+// totalSetter is implemented by writers that want to know the total size of
+// an asset before it is written.
+type totalSetter interface {
+	setTotal(total int64)
+}
+
+// SetTotalSize informs w of the total size of the asset that is written to
+// it, so that its progress can be reported. Assets of Applications in other
+// packages call it before writing, when the size is known.
+func SetTotalSize(w io.Writer, total int64) {
+	if t, ok := w.(totalSetter); ok && total >= 0 {
+		t.setTotal(total)
+	}
+}
+
+// progressWriter reports the progress of writing an asset.
+type progressWriter struct {
+	asset    Asset
+	w        io.Writer
+	progress func(asset Asset, written, total int64)
+
+	written int64
+	total   int64
+}
+
+func newProgressWriter(a Asset, w io.Writer, progress func(Asset, int64, int64)) *progressWriter {
+	return &progressWriter{
+		asset:    a,
+		w:        w,
+		progress: progress,
+		total:    -1,
+	}
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.written += int64(n)
+	p.progress(p.asset, p.written, p.total)
+	return n, err
+}
+
+func (p *progressWriter) setTotal(total int64) {
+	p.total = total
+	p.progress(p.asset, p.written, p.total)
+}
+
+// totalForwarder is a writer that forwards the total size to another writer.
+type totalForwarder struct {
+	io.Writer
+	to io.Writer
+}
+
+func (t *totalForwarder) setTotal(total int64) {
+	if s, ok := t.to.(totalSetter); ok {
+		s.setTotal(total)
+	}
+}
+
+// teeWriter returns a writer that writes to w and h, and forwards the total
+// size to w.
+func teeWriter(w, h io.Writer) io.Writer {
+	return &totalForwarder{
+		Writer: io.MultiWriter(w, h),
+		to:     w,
+	}
+}
@@ -0,0 +1,81 @@
+package updater
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdaterProgress(t *testing.T) {
+	type report struct {
+		written, total int64
+	}
+
+	// Unknown size
+	{
+		a := &testAsset{
+			name: "asset",
+			write: func(w io.Writer) error {
+				w.Write([]byte("Hello "))
+				w.Write([]byte("World!"))
+				return nil
+			},
+		}
+
+		var reports []report
+		u := &Updater{
+			WriterForAsset: func(Asset) (AbortWriter, error) {
+				return NewAbortBuffer(nil), nil
+			},
+			Progress: func(asset Asset, written, total int64) {
+				assert.Equal(t, a, asset)
+				reports = append(reports, report{written, total})
+			},
+		}
+
+		err := u.UpdateTo(&testRelease{assets: []Asset{a}})
+		assert.Nil(t, err)
+		assert.Equal(t, []report{{6, -1}, {12, -1}}, reports)
+	}
+
+	// Size reported by the server
+	{
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("Hello World!"))
+		}))
+		defer ts.Close()
+
+		a := &githubAsset{}
+		a.Asset.BrowserDownloadURL = &ts.URL
+
+		var reports []report
+		u := &Updater{
+			WriterForAsset: func(Asset) (AbortWriter, error) {
+				return NewAbortBuffer(nil), nil
+			},
+			Progress: func(asset Asset, written, total int64) {
+				reports = append(reports, report{written, total})
+			},
+		}
+
+		err := u.UpdateTo(&testRelease{assets: []Asset{a}})
+		assert.Nil(t, err)
+		assert.Equal(t, []report{{0, 12}, {12, 12}}, reports)
+	}
+}
+
+func TestSetTotalSize(t *testing.T) {
+	w := &testTotalWriter{}
+	SetTotalSize(w, 12)
+	assert.Equal(t, int64(12), w.total)
+
+	// Unknown size
+	SetTotalSize(w, -1)
+	assert.Equal(t, int64(12), w.total)
+
+	// Writer that does not want to know the size
+	SetTotalSize(io.Discard, 12)
+}
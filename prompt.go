@@ -0,0 +1,153 @@
+package updater
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// UpdatePrompt describes an available update to the user, see PromptUI.
+type UpdatePrompt struct {
+	// Release to update to.
+	Release Release
+
+	// Identifier of the current release.
+	CurrentRelease string
+
+	// Release notes as plain text, see InformationText.
+	Notes string
+
+	// Total size in bytes of the assets that are downloaded, or -1 if it is
+	// not known.
+	Size int64
+
+	// Whether the release is mandatory, see IsMandatory.
+	Mandatory bool
+}
+
+// PromptUI asks the user whether to apply an update, e.g. in a terminal with
+// TerminalPrompt or in a dialog of a graphical user interface.
+type PromptUI interface {
+	// Confirm should show the update to the user and return whether it should
+	// be applied.
+	Confirm(p *UpdatePrompt) (bool, error)
+}
+
+// CheckAndPrompt checks for updates, and if there is one, asks ui whether to
+// apply it before updating with UpdateTo.
+//
+// The release that was applied is returned. When the application is up to
+// date or the user declined the update, nil is returned.
+func (u *Updater) CheckAndPrompt(ui PromptUI) (Release, error) {
+	return u.CheckAndPromptContext(context.Background(), ui)
+}
+
+// CheckAndPromptContext is like CheckAndPrompt but aborts when ctx is
+// cancelled.
+func (u *Updater) CheckAndPromptContext(ctx context.Context, ui PromptUI) (Release, error) {
+	r, err := u.CheckContext(ctx)
+	if err != nil || r == nil {
+		return nil, err
+	}
+
+	ok, err := ui.Confirm(&UpdatePrompt{
+		Release:        r,
+		CurrentRelease: u.currentIdentifier(),
+		Notes:          InformationText(r),
+		Size:           u.downloadSize(r),
+		Mandatory:      IsMandatory(r),
+	})
+	if err != nil {
+		return nil, err
+	} else if !ok {
+		u.logf("Update to %v declined", r.Name())
+		return nil, nil
+	}
+
+	err = u.UpdateToContext(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// downloadSize returns the total size of the assets of release that are
+// written by UpdateTo, or -1 if the size of one of them is not known.
+func (u *Updater) downloadSize(release Release) int64 {
+	var total int64
+	for _, a := range release.Assets() {
+		if a.Name() == u.ChecksumAssetName || u.isSignature(a) {
+			continue
+		} else if u.AssetFilter != nil && !u.AssetFilter(a) {
+			continue
+		}
+
+		m, ok := a.(AssetMeta)
+		if !ok || m.Size() < 0 {
+			return -1
+		}
+		total += m.Size()
+	}
+	return total
+}
+
+// TerminalPrompt is a PromptUI that asks the user in a terminal.
+type TerminalPrompt struct {
+	// Input the answer is read from. Defaults to standard input.
+	In io.Reader
+
+	// Output the update is shown on. Defaults to standard output.
+	Out io.Writer
+}
+
+// Confirm shows the update and asks to answer yes or no. Anything but yes
+// declines the update.
+func (t *TerminalPrompt) Confirm(p *UpdatePrompt) (bool, error) {
+	in, out := t.In, t.Out
+	if in == nil {
+		in = os.Stdin
+	}
+	if out == nil {
+		out = os.Stdout
+	}
+
+	fmt.Fprintf(out, "Version %v is available.\n", p.Release.Name())
+	if p.Mandatory {
+		fmt.Fprintln(out, "This update is required.")
+	}
+	if p.Notes != "" {
+		fmt.Fprintf(out, "\n%v\n\n", p.Notes)
+	}
+	if p.Size >= 0 {
+		fmt.Fprintf(out, "Download size: %v\n", formatSize(p.Size))
+	}
+	fmt.Fprint(out, "Update now? [y/N] ")
+
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
+
+// formatSize formats a number of bytes for humans, e.g. 1.5 MB.
+func formatSize(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "kMGTPE"[exp])
+}
@@ -0,0 +1,137 @@
+package updater
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testSizedAsset struct {
+	testAsset
+	size int64
+}
+
+func (a *testSizedAsset) Size() int64         { return a.size }
+func (a *testSizedAsset) ContentType() string { return "" }
+func (a *testSizedAsset) DownloadCount() int  { return -1 }
+
+type testPromptUI struct {
+	prompt  *UpdatePrompt
+	confirm bool
+}
+
+func (ui *testPromptUI) Confirm(p *UpdatePrompt) (bool, error) {
+	ui.prompt = p
+	return ui.confirm, nil
+}
+
+func TestUpdaterCheckAndPrompt(t *testing.T) {
+	release := &testRelease{
+		name:        "v2",
+		identifier:  "c2",
+		information: "Bug fixes.",
+		assets: []Asset{
+			&testSizedAsset{
+				testAsset: testAsset{
+					name: "myapp",
+					write: func(w io.Writer) error {
+						_, err := io.WriteString(w, "Hello World!")
+						return err
+					},
+				},
+				size: 12,
+			},
+			&testSizedAsset{testAsset: testAsset{name: "checksums.txt"}, size: 100},
+		},
+	}
+	app := &testApp{FLatestRelease: func() Release { return release }}
+
+	var b *AbortBuffer
+	u := &Updater{
+		App:                      app,
+		CurrentReleaseIdentifier: "c1",
+		AssetFilter:              func(a Asset) bool { return a.Name() == "myapp" },
+		WriterForAsset: func(Asset) (AbortWriter, error) {
+			b = NewAbortBuffer(nil)
+			return b, nil
+		},
+	}
+
+	// Declined
+	{
+		ui := &testPromptUI{}
+		r, err := u.CheckAndPrompt(ui)
+		assert.Nil(t, err)
+		assert.Nil(t, r)
+		assert.Nil(t, b)
+
+		require.NotNil(t, ui.prompt)
+		assert.Equal(t, release, ui.prompt.Release)
+		assert.Equal(t, "c1", ui.prompt.CurrentRelease)
+		assert.Equal(t, "Bug fixes.", ui.prompt.Notes)
+		assert.Equal(t, int64(12), ui.prompt.Size)
+		assert.False(t, ui.prompt.Mandatory)
+	}
+
+	// Confirmed
+	{
+		r, err := u.CheckAndPrompt(&testPromptUI{confirm: true})
+		assert.Nil(t, err, "Could not update: %v", err)
+		assert.Equal(t, release, r)
+		require.NotNil(t, b)
+		assert.Equal(t, "Hello World!", b.Buffer.String())
+	}
+
+	// Up to date
+	{
+		u.CurrentReleaseIdentifier = "c2"
+		ui := &testPromptUI{confirm: true}
+		r, err := u.CheckAndPrompt(ui)
+		assert.Nil(t, err)
+		assert.Nil(t, r)
+		assert.Nil(t, ui.prompt)
+	}
+}
+
+func TestTerminalPrompt(t *testing.T) {
+	p := &UpdatePrompt{
+		Release:   &testRelease{name: "v2"},
+		Notes:     "Bug fixes.",
+		Size:      1500000,
+		Mandatory: true,
+	}
+
+	// Confirmed
+	{
+		out := bytes.NewBuffer(nil)
+		ok, err := (&TerminalPrompt{In: strings.NewReader("yes\n"), Out: out}).Confirm(p)
+		assert.Nil(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, "Version v2 is available.\n"+
+			"This update is required.\n"+
+			"\nBug fixes.\n\n"+
+			"Download size: 1.5 MB\n"+
+			"Update now? [y/N] ", out.String())
+	}
+
+	// Declined
+	{
+		for _, answer := range []string{"n\n", "\n", ""} {
+			ok, err := (&TerminalPrompt{In: strings.NewReader(answer), Out: ioutil.Discard}).Confirm(p)
+			assert.Nil(t, err)
+			assert.False(t, ok, "Answer %q", answer)
+		}
+	}
+
+	// Sizes
+	{
+		assert.Equal(t, "999 B", formatSize(999))
+		assert.Equal(t, "1.0 kB", formatSize(1000))
+		assert.Equal(t, "2.3 GB", formatSize(2345678901))
+	}
+}
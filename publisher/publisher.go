@@ -0,0 +1,315 @@
+// Package publisher generates the files that the updater uses to verify
+// releases: checksum files, detached signatures and manifests.
+//
+// Use it in a release pipeline to publish a release:
+//
+//	r := &publisher.Release{
+//		Version: "v1.2.0",
+//		Signer:  publisher.NewEd25519Signer(privateKey),
+//	}
+//	err := r.AddFile("dist/myapp_linux_amd64")
+//	...
+//	err = r.WriteDir("dist")
+//
+// Upload the files in the directory, and configure the Updater with the same
+// checksum asset name, signature suffix and a matching verifier.
+package publisher
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	updater "github.com/hverr/go-updater"
+)
+
+const (
+	// DefaultChecksumAssetName is the default name of the checksum file.
+	DefaultChecksumAssetName = "SHA256SUMS"
+
+	// DefaultSignatureSuffix is the default suffix of signature files, the
+	// same as the default of the Updater.
+	DefaultSignatureSuffix = ".sig"
+
+	// DefaultManifestName is the default name of the manifest file.
+	DefaultManifestName = "manifest.json"
+)
+
+// Release collects the assets of a release and generates the files that
+// describe them.
+type Release struct {
+	// Version name of the release, used in the manifest.
+	Version string
+
+	// Human-readable release notes, used in the manifest.
+	Notes string
+
+	// Identifier of the release in the manifest. Defaults to the version.
+	Identifier string
+
+	// Whether the release must be applied, used in the manifest.
+	Mandatory bool
+
+	// Signer used to create detached signatures of all assets and the
+	// checksum file. When nil, nothing is signed.
+	Signer Signer
+
+	// Name of the checksum file. Defaults to DefaultChecksumAssetName.
+	ChecksumAssetName string
+
+	// Suffix of signature files. Defaults to DefaultSignatureSuffix.
+	SignatureSuffix string
+
+	// Name of the manifest file. Defaults to DefaultManifestName.
+	ManifestName string
+
+	// Private key used to sign the manifest. When set, the manifest is a
+	// signed manifest, see updater.SignManifest, which is read with
+	// updater.NewSignedHTTPManifest, or with updater.NewReleaseManifest when
+	// the files are attached to a release. When nil, the manifest is not
+	// signed and is read with updater.NewHTTPManifest.
+	ManifestKey ed25519.PrivateKey
+
+	// URL that is prefixed to the asset names in the manifest, e.g.
+	// https://cdn.example.com/myapp/v1.2.0/. By default, the URLs are
+	// relative to the manifest, and a signed manifest has no URLs, so that
+	// its assets refer to the assets of the release that carries it.
+	BaseURL string
+
+	assets []Asset
+}
+
+// Asset is an asset of a release.
+type Asset struct {
+	// File name of the asset.
+	Name string
+
+	// SHA-256 checksum of the asset.
+	SHA256 []byte
+
+	// Size of the asset in bytes.
+	Size int64
+
+	// Detached signature of the asset, if the release has a signer.
+	Signature []byte
+}
+
+// AddFile adds the file at path to the release, named after the base name of
+// the path.
+func (r *Release) AddFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return r.Add(filepath.Base(path), f)
+}
+
+// Add reads an asset with the given name from rd and adds it to the release.
+//
+// The asset is hashed, and signed if the release has a signer.
+func (r *Release) Add(name string, rd io.Reader) error {
+	if name == "" || strings.ContainsAny(name, "/\\") {
+		return fmt.Errorf("Invalid asset name %q.", name)
+	}
+	for _, a := range r.assets {
+		if a.Name == name {
+			return fmt.Errorf("Asset %v was already added.", name)
+		}
+	}
+
+	data, err := ioutil.ReadAll(rd)
+	if err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(data)
+	a := Asset{Name: name, SHA256: sum[:], Size: int64(len(data))}
+	if r.Signer != nil {
+		a.Signature, err = r.Signer.Sign(data)
+		if err != nil {
+			return fmt.Errorf("Could not sign %v: %v", name, err)
+		}
+	}
+
+	r.assets = append(r.assets, a)
+	return nil
+}
+
+// Assets returns the assets that were added, in order.
+func (r *Release) Assets() []Asset {
+	return r.assets
+}
+
+// Checksums returns the checksum file of the assets in the format produced by
+// sha256sum, which the Updater reads with ChecksumAssetName.
+func (r *Release) Checksums() []byte {
+	assets := append([]Asset(nil), r.assets...)
+	sort.Slice(assets, func(i, j int) bool {
+		return assets[i].Name < assets[j].Name
+	})
+
+	buf := bytes.NewBuffer(nil)
+	for _, a := range assets {
+		fmt.Fprintf(buf, "%x  %v\n", a.SHA256, a.Name)
+	}
+	return buf.Bytes()
+}
+
+// Files returns all files of the release that should be published, except the
+// assets themselves: the checksum file, the signatures of the assets and the
+// checksum file, and the manifest.
+func (r *Release) Files() (map[string][]byte, error) {
+	files, all, err := r.generate()
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest []byte
+	if r.ManifestKey != nil {
+		manifest, err = updater.SignManifest(r.signedManifest(all), r.ManifestKey)
+	} else {
+		manifest, err = json.MarshalIndent(r.manifest(all), "", "\t")
+	}
+	if err != nil {
+		return nil, err
+	}
+	files[r.manifestName()] = append(manifest, '\n')
+
+	return files, nil
+}
+
+// Manifest returns the unsigned manifest of the release, which can be read
+// with NewHTTPManifest. It lists all files returned by Files, except the
+// manifest itself.
+func (r *Release) Manifest() (updater.Manifest, error) {
+	_, all, err := r.generate()
+	if err != nil {
+		return updater.Manifest{}, err
+	}
+	return r.manifest(all), nil
+}
+
+// generate returns the checksum file and signatures of the release, and the
+// assets and generated files that the manifest lists.
+func (r *Release) generate() (map[string][]byte, []Asset, error) {
+	if len(r.assets) == 0 {
+		return nil, nil, errors.New("No assets were added to the release.")
+	}
+
+	files := make(map[string][]byte)
+	checksums := r.Checksums()
+	files[r.checksumAssetName()] = checksums
+
+	// The manifest lists the assets and all generated files except itself
+	all := append([]Asset(nil), r.assets...)
+	sum := sha256.Sum256(checksums)
+	all = append(all, Asset{Name: r.checksumAssetName(), SHA256: sum[:], Size: int64(len(checksums))})
+	if r.Signer != nil {
+		sig, err := r.Signer.Sign(checksums)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Could not sign %v: %v", r.checksumAssetName(), err)
+		}
+		all[len(all)-1].Signature = sig
+
+		signed := all
+		for _, a := range signed {
+			name := a.Name + r.signatureSuffix()
+			files[name] = a.Signature
+			sum := sha256.Sum256(a.Signature)
+			all = append(all, Asset{Name: name, SHA256: sum[:], Size: int64(len(a.Signature))})
+		}
+	}
+
+	return files, all, nil
+}
+
+// WriteDir writes all files returned by Files to dir, next to the assets.
+func (r *Release) WriteDir(dir string) error {
+	files, err := r.Files()
+	if err != nil {
+		return err
+	}
+
+	for name, data := range files {
+		err := ioutil.WriteFile(filepath.Join(dir, name), data, 0644)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// manifest returns the manifest listing assets.
+func (r *Release) manifest(assets []Asset) updater.Manifest {
+	m := updater.Manifest{
+		Version:    r.Version,
+		Notes:      r.Notes,
+		Identifier: r.Identifier,
+		Mandatory:  r.Mandatory,
+		Assets:     make([]updater.ManifestAsset, len(assets)),
+	}
+
+	for i, a := range assets {
+		m.Assets[i] = updater.ManifestAsset{
+			Name:   a.Name,
+			URL:    r.BaseURL + url.PathEscape(a.Name),
+			SHA256: hex.EncodeToString(a.SHA256),
+		}
+	}
+	return m
+}
+
+// signedManifest returns the signed manifest listing assets.
+func (r *Release) signedManifest(assets []Asset) updater.SignedManifest {
+	m := updater.SignedManifest{
+		Version:    r.Version,
+		Notes:      r.Notes,
+		Identifier: r.Identifier,
+		Mandatory:  r.Mandatory,
+		Assets:     make([]updater.SignedManifestAsset, len(assets)),
+	}
+
+	for i, a := range assets {
+		m.Assets[i].Name = a.Name
+		m.Assets[i].SHA256 = hex.EncodeToString(a.SHA256)
+		m.Assets[i].Size = a.Size
+		if r.BaseURL != "" {
+			m.Assets[i].URL = r.BaseURL + url.PathEscape(a.Name)
+		}
+	}
+	return m
+}
+
+func (r *Release) checksumAssetName() string {
+	if r.ChecksumAssetName != "" {
+		return r.ChecksumAssetName
+	}
+	return DefaultChecksumAssetName
+}
+
+func (r *Release) signatureSuffix() string {
+	if r.SignatureSuffix != "" {
+		return r.SignatureSuffix
+	}
+	return DefaultSignatureSuffix
+}
+
+func (r *Release) manifestName() string {
+	if r.ManifestName != "" {
+		return r.ManifestName
+	}
+	return DefaultManifestName
+}
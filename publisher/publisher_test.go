@@ -0,0 +1,180 @@
+package publisher
+
+import (
+	"crypto/ed25519"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	updater "github.com/hverr/go-updater"
+	"github.com/hverr/go-updater/updatertest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReleaseChecksums(t *testing.T) {
+	r := &Release{Version: "v1.2.0"}
+	require.Nil(t, r.Add("myapp_linux_amd64", strings.NewReader("Hello World!")))
+	require.Nil(t, r.Add("myapp_darwin_amd64", strings.NewReader("")))
+
+	assert.Equal(t,
+		"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855  myapp_darwin_amd64\n"+
+			"7f83b1657ff1fc53b92dc18148a1d65dfc2d4b1fa3d677284addd200126d9069  myapp_linux_amd64\n",
+		string(r.Checksums()),
+	)
+
+	// Invalid or duplicate names
+	{
+		assert.NotNil(t, r.Add("myapp_linux_amd64", strings.NewReader("")))
+		assert.NotNil(t, r.Add("dist/myapp", strings.NewReader("")))
+		assert.NotNil(t, r.Add("", strings.NewReader("")))
+		assert.Equal(t, 2, len(r.Assets()))
+	}
+
+	// Without assets
+	{
+		_, err := (&Release{}).Files()
+		assert.NotNil(t, err)
+	}
+}
+
+func TestReleaseManifest(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(nil)
+	require.Nil(t, err)
+
+	r := &Release{
+		Version:   "v1.2.0",
+		Mandatory: true,
+		Signer:    NewEd25519Signer(key),
+		BaseURL:   "https://cdn.example.com/",
+	}
+	require.Nil(t, r.Add("myapp", strings.NewReader("Hello World!")))
+
+	m, err := r.Manifest()
+	require.Nil(t, err, "Could not create manifest: %v", err)
+	assert.Equal(t, "v1.2.0", m.Version)
+	assert.True(t, m.Mandatory)
+
+	var names []string
+	for _, a := range m.Assets {
+		names = append(names, a.Name)
+		assert.Equal(t, "https://cdn.example.com/"+a.Name, a.URL)
+		assert.Len(t, a.SHA256, 64)
+	}
+	assert.Equal(t, []string{"myapp", "SHA256SUMS", "myapp.sig", "SHA256SUMS.sig"}, names)
+}
+
+func TestReleaseWriteDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "publisher-")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	ed25519Public, ed25519Private, err := ed25519.GenerateKey(nil)
+	require.Nil(t, err)
+	_, minisignPrivate, err := ed25519.GenerateKey(nil)
+	require.Nil(t, err)
+	minisign := &MinisignSigner{
+		PrivateKey:     minisignPrivate,
+		KeyID:          [8]byte{1, 2, 3, 4, 5, 6, 7, 8},
+		TrustedComment: "myapp v1.2.0",
+	}
+	minisignVerifier, err := updater.NewMinisignVerifier(minisign.PublicKey())
+	require.Nil(t, err)
+
+	signers := []struct {
+		signer   Signer
+		verifier updater.Verifier
+	}{
+		{NewEd25519Signer(ed25519Private), updater.NewEd25519Verifier(ed25519Public)},
+		{minisign, minisignVerifier},
+	}
+
+	for _, s := range signers {
+		os.RemoveAll(dir)
+		require.Nil(t, os.MkdirAll(dir, 0755))
+		asset := filepath.Join(dir, "myapp_linux_amd64")
+		require.Nil(t, ioutil.WriteFile(asset, []byte("Hello World!"), 0644))
+
+		r := &Release{Version: "v1.2.0", Signer: s.signer}
+		require.Nil(t, r.AddFile(asset))
+		require.Nil(t, r.WriteDir(dir))
+
+		// The updater verifies the published release
+		ts := httptest.NewServer(http.FileServer(http.Dir(dir)))
+		b := updater.NewAbortBuffer(nil)
+		u := &updater.Updater{
+			App:               updater.NewHTTPManifest(ts.URL+"/manifest.json", nil),
+			ChecksumAssetName: DefaultChecksumAssetName,
+			Verifier:          s.verifier,
+			AssetFilter: func(a updater.Asset) bool {
+				return a.Name() == "myapp_linux_amd64"
+			},
+			WriterForAsset: func(updater.Asset) (updater.AbortWriter, error) {
+				return b, nil
+			},
+		}
+		err := u.UpdateTo(nil)
+		ts.Close()
+		assert.Nil(t, err, "Could not update: %v", err)
+		assert.Equal(t, "Hello World!", b.Buffer.String())
+
+		// A tampered asset is rejected
+		require.Nil(t, ioutil.WriteFile(asset, []byte("Hello World?"), 0644))
+		ts = httptest.NewServer(http.FileServer(http.Dir(dir)))
+		b = updater.NewAbortBuffer(nil)
+		u.App = updater.NewHTTPManifest(ts.URL+"/manifest.json", nil)
+		err = u.UpdateTo(nil)
+		ts.Close()
+		assert.NotNil(t, err)
+	}
+}
+
+func TestReleaseSignedManifest(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(nil)
+	require.Nil(t, err)
+
+	r := &Release{
+		Version:     "v1.2.0",
+		Signer:      NewEd25519Signer(private),
+		ManifestKey: private,
+	}
+	require.Nil(t, r.Add("myapp_linux_amd64", strings.NewReader("Hello World!")))
+	files, err := r.Files()
+	require.Nil(t, err, "Could not create files: %v", err)
+
+	// Attach the published files to a release
+	assets := []*updatertest.Asset{updatertest.NewAsset("myapp_linux_amd64", []byte("Hello World!"))}
+	for name, data := range files {
+		assets = append(assets, updatertest.NewAsset(name, data))
+	}
+	app := updatertest.NewApp(updatertest.NewRelease("v1.2.0", assets...))
+
+	// The updater verifies the published release
+	b := updater.NewAbortBuffer(nil)
+	u := &updater.Updater{
+		App: updater.NewReleaseManifest(app, updater.SignedManifestOptions{
+			Verifier: updater.NewEd25519Verifier(public),
+		}),
+		ChecksumAssetName: DefaultChecksumAssetName,
+		Verifier:          updater.NewEd25519Verifier(public),
+		AssetFilter: func(a updater.Asset) bool {
+			return a.Name() == "myapp_linux_amd64"
+		},
+		WriterForAsset: func(updater.Asset) (updater.AbortWriter, error) {
+			return b, nil
+		},
+	}
+	err = u.UpdateTo(nil)
+	assert.Nil(t, err, "Could not update: %v", err)
+	assert.Equal(t, "Hello World!", b.Buffer.String())
+
+	// A tampered asset is rejected
+	assets[0].Content = []byte("Hello World?")
+	b = updater.NewAbortBuffer(nil)
+	err = u.UpdateTo(nil)
+	assert.NotNil(t, err)
+}
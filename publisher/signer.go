@@ -0,0 +1,83 @@
+package publisher
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// Signer creates detached signatures.
+type Signer interface {
+	// Sign should return a detached signature of message.
+	Sign(message []byte) ([]byte, error)
+}
+
+type ed25519Signer struct {
+	privateKey ed25519.PrivateKey
+}
+
+// NewEd25519Signer creates a signer for plain ed25519 signatures, which can be
+// verified with NewEd25519Verifier of the updater.
+//
+// Signatures are base64 encoded.
+func NewEd25519Signer(privateKey ed25519.PrivateKey) Signer {
+	return &ed25519Signer{
+		privateKey: privateKey,
+	}
+}
+
+func (s *ed25519Signer) Sign(message []byte) ([]byte, error) {
+	if len(s.privateKey) != ed25519.PrivateKeySize {
+		return nil, errors.New("Invalid ed25519 private key.")
+	}
+
+	sig := ed25519.Sign(s.privateKey, message)
+	return []byte(base64.StdEncoding.EncodeToString(sig) + "\n"), nil
+}
+
+// MinisignSigner creates pre-hashed minisign signatures, which can be verified
+// with NewMinisignVerifier of the updater.
+type MinisignSigner struct {
+	// Private key used to sign.
+	PrivateKey ed25519.PrivateKey
+
+	// Key ID of the key pair.
+	KeyID [8]byte
+
+	// Trusted comment of the signatures, which is signed as well. Defaults
+	// to an empty comment.
+	TrustedComment string
+}
+
+// Sign returns a minisign signature of message.
+func (s *MinisignSigner) Sign(message []byte) ([]byte, error) {
+	if len(s.PrivateKey) != ed25519.PrivateKeySize {
+		return nil, errors.New("Invalid ed25519 private key.")
+	}
+	if strings.ContainsAny(s.TrustedComment, "\r\n") {
+		return nil, errors.New("The trusted comment cannot span multiple lines.")
+	}
+
+	h := blake2b.Sum512(message)
+	sig := append([]byte("ED"), s.KeyID[:]...)
+	sig = append(sig, ed25519.Sign(s.PrivateKey, h[:])...)
+	globalSig := ed25519.Sign(s.PrivateKey, append(sig[10:len(sig):len(sig)], s.TrustedComment...))
+
+	return []byte(
+		"untrusted comment: signature from minisign secret key\n" +
+			base64.StdEncoding.EncodeToString(sig) + "\n" +
+			"trusted comment: " + s.TrustedComment + "\n" +
+			base64.StdEncoding.EncodeToString(globalSig) + "\n",
+	), nil
+}
+
+// PublicKey returns the minisign public key of the signer, which can be
+// passed to NewMinisignVerifier.
+func (s *MinisignSigner) PublicKey() string {
+	key := append([]byte("Ed"), s.KeyID[:]...)
+	key = append(key, s.PrivateKey.Public().(ed25519.PublicKey)...)
+	return base64.StdEncoding.EncodeToString(key)
+}
@@ -0,0 +1,91 @@
+package updater
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// rateLimiter limits the combined rate of writers to a number of bytes per
+// second.
+type rateLimiter struct {
+	rate  int64
+	start time.Time
+
+	mu      sync.Mutex
+	written int64
+}
+
+func newRateLimiter(rate int64) *rateLimiter {
+	return &rateLimiter{
+		rate:  rate,
+		start: time.Now(),
+	}
+}
+
+// writer returns a writer to w that is limited by l, and stops waiting when
+// ctx is cancelled.
+func (l *rateLimiter) writer(ctx context.Context, w io.Writer) io.Writer {
+	return &rateLimitedWriter{ctx: ctx, w: w, l: l}
+}
+
+// wait records that n bytes were written and waits until writing them is
+// allowed by the rate.
+func (l *rateLimiter) wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+	l.written += int64(n)
+	d := time.Duration(float64(l.written) / float64(l.rate) * float64(time.Second))
+	l.mu.Unlock()
+
+	d -= time.Since(l.start)
+	if d <= 0 {
+		return nil
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// rateLimitedWriter is a writer that is limited by a rate limiter.
+type rateLimitedWriter struct {
+	ctx context.Context
+	w   io.Writer
+	l   *rateLimiter
+}
+
+func (r *rateLimitedWriter) Write(b []byte) (int, error) {
+	// Write in chunks of a tenth of a second to keep the rate smooth
+	size := int(r.l.rate / 10)
+	if size < 1 {
+		size = 1
+	}
+
+	var n int
+	for len(b) > 0 {
+		chunk := b
+		if len(chunk) > size {
+			chunk = chunk[:size]
+		}
+
+		m, err := r.w.Write(chunk)
+		n += m
+		if err != nil {
+			return n, err
+		}
+		b = b[m:]
+
+		err = r.l.wait(r.ctx, m)
+		if err != nil {
+			return n, err
+		}
+	}
+
+	return n, nil
+}
@@ -0,0 +1,57 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter(t *testing.T) {
+	// Limited writes
+	{
+		buf := bytes.NewBuffer(nil)
+		start := time.Now()
+		w := newRateLimiter(1000).writer(context.Background(), buf)
+		n, err := w.Write(make([]byte, 300))
+		assert.Nil(t, err)
+		assert.Equal(t, 300, n)
+		assert.Equal(t, 300, buf.Len())
+		assert.True(t, time.Since(start) >= 250*time.Millisecond, "Writing was not limited.")
+	}
+
+	// Cancelled
+	{
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		w := newRateLimiter(10).writer(ctx, bytes.NewBuffer(nil))
+		n, err := w.Write(make([]byte, 100))
+		assert.Equal(t, context.Canceled, err)
+		assert.Equal(t, 1, n)
+	}
+}
+
+func TestUpdaterRateLimit(t *testing.T) {
+	a := &testAsset{
+		write: func(w io.Writer) error {
+			_, err := w.Write(make([]byte, 200))
+			return err
+		},
+	}
+	buf := NewAbortBuffer(nil)
+	u := &Updater{
+		RateLimit: 1000,
+		WriterForAsset: func(Asset) (AbortWriter, error) {
+			return buf, nil
+		},
+	}
+
+	start := time.Now()
+	err := u.UpdateTo(&testRelease{assets: []Asset{a}})
+	assert.Nil(t, err, "Unexpected error: %v", err)
+	assert.Equal(t, 200, buf.Buffer.Len())
+	assert.True(t, time.Since(start) >= 150*time.Millisecond, "Writing was not limited.")
+}
@@ -0,0 +1,71 @@
+package updater
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+)
+
+// OpenAsset returns a reader of the contents of asset a and its size, or -1
+// if it is not known.
+//
+// Assets that implement AssetReader are opened. Other assets are written to
+// the reader in a new goroutine, which stops when the reader is closed.
+func OpenAsset(ctx context.Context, a Asset) (io.ReadCloser, int64, error) {
+	if r, ok := a.(AssetReader); ok {
+		return r.Open(ctx)
+	}
+
+	size := int64(-1)
+	if m, ok := a.(AssetMeta); ok {
+		size = m.Size()
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeAsset(ctx, a, pw))
+	}()
+	return pr, size, nil
+}
+
+// isContextAsset reports whether a implements ContextAsset.
+func isContextAsset(a Asset) bool {
+	_, ok := a.(ContextAsset)
+	return ok
+}
+
+// checksumReader verifies the SHA-256 checksum of the data read from a reader
+// when the end is reached.
+type checksumReader struct {
+	io.ReadCloser
+
+	asset    Asset
+	expected []byte
+	h        hash.Hash
+}
+
+// newChecksumReader returns a reader that fails at the end of r if the data
+// does not have the hexadecimal SHA-256 checksum sum.
+func newChecksumReader(r io.ReadCloser, a Asset, sum string) (io.ReadCloser, error) {
+	expected, err := hex.DecodeString(sum)
+	if err != nil {
+		r.Close()
+		return nil, fmt.Errorf("Invalid checksum for %v: %v", a.Name(), err)
+	}
+
+	return &checksumReader{ReadCloser: r, asset: a, expected: expected, h: sha256.New()}, nil
+}
+
+func (c *checksumReader) Read(b []byte) (int, error) {
+	n, err := c.ReadCloser.Read(b)
+	c.h.Write(b[:n])
+	if err == io.EOF {
+		if verr := verifyChecksum(map[string][]byte{c.asset.Name(): c.expected}, c.asset, c.h.Sum(nil)); verr != nil {
+			err = verr
+		}
+	}
+	return n, err
+}
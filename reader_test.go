@@ -0,0 +1,92 @@
+package updater
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testReaderAsset struct {
+	testAsset
+	contents string
+	opened   int
+}
+
+func (a *testReaderAsset) Open(ctx context.Context) (io.ReadCloser, int64, error) {
+	a.opened++
+	return ioutil.NopCloser(strings.NewReader(a.contents)), int64(len(a.contents)), nil
+}
+
+func TestOpenAsset(t *testing.T) {
+	ctx := context.Background()
+
+	// Asset reader preferred
+	{
+		a := &testReaderAsset{
+			testAsset: testAsset{name: "myapp", write: func(io.Writer) error {
+				return errors.New("Not read.")
+			}},
+			contents: "Hello World!",
+		}
+
+		var total int64
+		w := newProgressWriter(a, ioutil.Discard, func(_ Asset, _, t int64) { total = t })
+		err := writeAsset(ctx, a, w)
+		assert.Nil(t, err, "Could not write asset: %v", err)
+		assert.Equal(t, 1, a.opened)
+		assert.Equal(t, int64(12), total)
+
+		rc, size, err := OpenAsset(ctx, a)
+		require.Nil(t, err)
+		defer rc.Close()
+		b, err := ioutil.ReadAll(rc)
+		assert.Nil(t, err)
+		assert.Equal(t, "Hello World!", string(b))
+		assert.Equal(t, int64(12), size)
+	}
+
+	// Other assets written to a pipe
+	{
+		a := &testAsset{name: "myapp", write: func(w io.Writer) error {
+			_, err := io.WriteString(w, "Hello World!")
+			return err
+		}}
+		rc, size, err := OpenAsset(ctx, a)
+		require.Nil(t, err)
+		defer rc.Close()
+		b, err := ioutil.ReadAll(rc)
+		assert.Nil(t, err)
+		assert.Equal(t, "Hello World!", string(b))
+		assert.Equal(t, int64(-1), size)
+
+		a.write = func(io.Writer) error { return errors.New("Broken.") }
+		rc, _, err = OpenAsset(ctx, a)
+		require.Nil(t, err)
+		_, err = ioutil.ReadAll(rc)
+		assert.NotNil(t, err)
+	}
+
+	// Checksums verified at the end
+	{
+		sum := sha256.Sum256([]byte("Hello World!"))
+		a := &testAsset{name: "myapp"}
+		rc, err := newChecksumReader(ioutil.NopCloser(strings.NewReader("Hello World!")), a, hex.EncodeToString(sum[:]))
+		require.Nil(t, err)
+		_, err = ioutil.ReadAll(rc)
+		assert.Nil(t, err)
+
+		rc, err = newChecksumReader(ioutil.NopCloser(strings.NewReader("Corrupt")), a, hex.EncodeToString(sum[:]))
+		require.Nil(t, err)
+		_, err = ioutil.ReadAll(rc)
+		require.NotNil(t, err)
+		assert.Contains(t, err.Error(), "Checksum mismatch")
+	}
+}
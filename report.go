@@ -0,0 +1,170 @@
+package updater
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// UpdateReport describes an update applied with UpdateTo, e.g. to log or audit
+// what an automatic update did. It can be serialized to JSON.
+type UpdateReport struct {
+	// CurrentReleaseIdentifier of the updater when the update started.
+	CurrentRelease string `json:"current_release"`
+
+	// Name of the release that was applied.
+	Release string `json:"release"`
+
+	// Identifier of the release that was applied.
+	ReleaseIdentifier string `json:"release_identifier"`
+
+	// Assets that were selected, in the order of the release.
+	Assets []AssetReport `json:"assets"`
+
+	// Total number of bytes written to the assets.
+	BytesDownloaded int64 `json:"bytes_downloaded"`
+
+	// Whether the update was applied successfully.
+	Applied bool `json:"applied"`
+
+	// Error that caused the update to fail, if any.
+	Error string `json:"error,omitempty"`
+
+	// Time at which the update started.
+	StartedAt time.Time `json:"started_at"`
+
+	// Time the update took, in nanoseconds when serialized.
+	Duration time.Duration `json:"duration"`
+}
+
+// AssetReport describes how an asset was written during an update.
+type AssetReport struct {
+	// Name of the asset.
+	Name string `json:"name"`
+
+	// Number of bytes written to the asset.
+	Bytes int64 `json:"bytes"`
+
+	// Verifications of the asset, in the order in which they ran. A failed
+	// verification is the last one.
+	Verifications []VerificationReport `json:"verifications,omitempty"`
+
+	// Error that caused writing the asset to fail, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// VerificationReport is the result of a verification of an asset.
+type VerificationReport struct {
+	// Kind of verification: checksum, signature or check for the Checks of
+	// the updater.
+	Name string `json:"name"`
+
+	// Whether the verification succeeded.
+	Passed bool `json:"passed"`
+
+	// Error returned by the verification, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// Report returns the report of the last update applied with UpdateTo or
+// SelfUpdate, or nil if no update was applied yet. Failed updates are reported too.
+func (u *Updater) Report() *UpdateReport {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	return u.report
+}
+
+// setReport stores the report of the last update.
+func (u *Updater) setReport(r *UpdateReport) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	u.report = r
+}
+
+// reportKey is the context key of the reportRecorder.
+type reportKey struct{}
+
+// reportRecorder collects the report of an update. Assets may be reported
+// concurrently.
+type reportRecorder struct {
+	mutex  sync.Mutex
+	report UpdateReport
+	assets map[string]int
+}
+
+// newReportRecorder starts the report of an update to release.
+func newReportRecorder(u *Updater, release Release) *reportRecorder {
+	return &reportRecorder{
+		report: UpdateReport{
+			CurrentRelease:    u.currentIdentifier(),
+			Release:           release.Name(),
+			ReleaseIdentifier: release.Identifier(),
+			StartedAt:         time.Now(),
+		},
+		assets: make(map[string]int),
+	}
+}
+
+// withReportRecorder returns a context in which assets are reported to r.
+func withReportRecorder(ctx context.Context, r *reportRecorder) context.Context {
+	return context.WithValue(ctx, reportKey{}, r)
+}
+
+// recorder returns the reportRecorder of ctx, or nil if there is none.
+func recorder(ctx context.Context) *reportRecorder {
+	r, _ := ctx.Value(reportKey{}).(*reportRecorder)
+	return r
+}
+
+// selectAssets reports the assets that are written.
+func (r *reportRecorder) selectAssets(assets []Asset) {
+	if r == nil {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, a := range assets {
+		r.assets[a.Name()] = len(r.report.Assets)
+		r.report.Assets = append(r.report.Assets, AssetReport{Name: a.Name()})
+	}
+}
+
+// finishAsset reports that n bytes of asset a were written and verified,
+// or that this failed with err.
+func (r *reportRecorder) finishAsset(a Asset, n int64, verifications []VerificationReport, err error) {
+	if r == nil {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	i, ok := r.assets[a.Name()]
+	if !ok {
+		return
+	}
+	r.report.Assets[i].Bytes = n
+	r.report.Assets[i].Verifications = verifications
+	r.report.Assets[i].Error = errorString(err)
+	r.report.BytesDownloaded += n
+}
+
+// finish completes the report with the result of the update.
+func (r *reportRecorder) finish(err error) *UpdateReport {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	report := r.report
+	report.Applied = err == nil
+	report.Error = errorString(err)
+	report.Duration = time.Since(report.StartedAt)
+	return &report
+}
+
+// errorString returns the message of err, or an empty string if err is nil.
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,114 @@
+package updater
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdaterReport(t *testing.T) {
+	newAsset := func(name, contents string) Asset {
+		return &testAsset{
+			name: name,
+			write: func(w io.Writer) error {
+				_, err := io.WriteString(w, contents)
+				return err
+			},
+		}
+	}
+	release := &testRelease{
+		name:       "v2.0.0",
+		identifier: "2",
+		assets: []Asset{
+			newAsset("myapp", "Hello World!"),
+			newAsset("README", "Read me."),
+			newAsset("checksums.txt", "7f83b1657ff1fc53b92dc18148a1d65dfc2d4b1fa3d677284addd200126d9069  myapp\n"),
+		},
+	}
+	writerFor := func(Asset) (AbortWriter, error) { return NewAbortBuffer(nil), nil }
+
+	// No update yet
+	{
+		u := &Updater{}
+		assert.Nil(t, u.Report())
+	}
+
+	// Successful update
+	{
+		u := &Updater{
+			CurrentReleaseIdentifier: "1",
+			WriterForAsset:           writerFor,
+			AssetFilter:              func(a Asset) bool { return a.Name() == "myapp" },
+			ChecksumAssetName:        "checksums.txt",
+			Checks: []AssetCheck{
+				BufferCheck(nil, func(Release, Asset, []byte) error { return nil }),
+			},
+		}
+
+		err := u.UpdateTo(release)
+		require.Nil(t, err, "Could not update: %v", err)
+
+		r := u.Report()
+		require.NotNil(t, r)
+		assert.Equal(t, "1", r.CurrentRelease)
+		assert.Equal(t, "v2.0.0", r.Release)
+		assert.Equal(t, "2", r.ReleaseIdentifier)
+		assert.True(t, r.Applied)
+		assert.Equal(t, "", r.Error)
+		assert.Equal(t, int64(12), r.BytesDownloaded)
+		assert.False(t, r.StartedAt.IsZero())
+		assert.True(t, r.Duration > 0)
+		assert.Equal(t, []AssetReport{{
+			Name:  "myapp",
+			Bytes: 12,
+			Verifications: []VerificationReport{
+				{Name: "checksum", Passed: true},
+				{Name: "check", Passed: true},
+			},
+		}}, r.Assets)
+
+		b, err := json.Marshal(r)
+		require.Nil(t, err)
+		var m map[string]interface{}
+		require.Nil(t, json.Unmarshal(b, &m))
+		assert.Equal(t, "v2.0.0", m["release"])
+		assert.Equal(t, float64(12), m["bytes_downloaded"])
+		assert.Equal(t, true, m["applied"])
+	}
+
+	// Failed update
+	{
+		u := &Updater{
+			CurrentReleaseIdentifier: "1",
+			WriterForAsset:           writerFor,
+			AssetFilter:              func(a Asset) bool { return a.Name() != "checksums.txt" },
+			Checks: []AssetCheck{
+				BufferCheck(
+					func(a Asset) bool { return a.Name() == "README" },
+					func(Release, Asset, []byte) error { return errors.New("Invalid README.") },
+				),
+			},
+		}
+
+		err := u.UpdateTo(release)
+		require.NotNil(t, err)
+
+		r := u.Report()
+		require.NotNil(t, r)
+		assert.False(t, r.Applied)
+		assert.Equal(t, "Invalid README.", r.Error)
+		assert.Equal(t, int64(20), r.BytesDownloaded)
+		require.Equal(t, 2, len(r.Assets))
+		assert.Equal(t, AssetReport{Name: "myapp", Bytes: 12}, r.Assets[0])
+		assert.Equal(t, AssetReport{
+			Name:          "README",
+			Bytes:         8,
+			Verifications: []VerificationReport{{Name: "check", Error: "Invalid README."}},
+			Error:         "Invalid README.",
+		}, r.Assets[1])
+	}
+}
@@ -0,0 +1,251 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"runtime"
+	"time"
+)
+
+// Events of an Outcome.
+const (
+	// OutcomeCheck is the event of a check for updates.
+	OutcomeCheck = "check"
+
+	// OutcomeApply is the event of an update that was applied, or failed.
+	OutcomeApply = "apply"
+)
+
+// Classes of the errors of an Outcome.
+const (
+	// ErrorClassNetwork is the class of errors to connect to a server.
+	ErrorClassNetwork = "network"
+
+	// ErrorClassRateLimit is the class of errors caused by a rate limit of
+	// an API or server.
+	ErrorClassRateLimit = "rate_limit"
+
+	// ErrorClassDownload is the class of unexpected responses of a server,
+	// e.g. 404 Not Found.
+	ErrorClassDownload = "download"
+
+	// ErrorClassNoRelease is the class of ErrNoRelease.
+	ErrorClassNoRelease = "no_release"
+
+	// ErrorClassManaged is the class of ErrExternallyManaged.
+	ErrorClassManaged = "managed"
+
+	// ErrorClassChecksum is the class of invalid or missing checksums.
+	ErrorClassChecksum = "checksum"
+
+	// ErrorClassSignature is the class of invalid or missing signatures.
+	ErrorClassSignature = "signature"
+
+	// ErrorClassCheck is the class of failed Checks of the updater.
+	ErrorClassCheck = "check"
+
+	// ErrorClassInstall is the class of errors after all assets were
+	// written, e.g. a failed validation or commit.
+	ErrorClassInstall = "install"
+
+	// ErrorClassCancelled is the class of cancelled checks and updates.
+	ErrorClassCancelled = "cancelled"
+
+	// ErrorClassOther is the class of all other errors.
+	ErrorClassOther = "other"
+)
+
+// Outcome describes a check for updates or an update, for a Reporter. It can
+// be serialized to JSON.
+type Outcome struct {
+	// Event: OutcomeCheck or OutcomeApply.
+	Event string `json:"event"`
+
+	// Identifier of the installation, only if SendInstallationID of the
+	// updater is set.
+	InstallationID string `json:"installation_id,omitempty"`
+
+	// CurrentReleaseIdentifier of the updater.
+	CurrentRelease string `json:"current_release"`
+
+	// Name of the release that was found or applied. It is empty when a
+	// check found no update.
+	Release string `json:"release,omitempty"`
+
+	// Identifier of the release that was found or applied.
+	ReleaseIdentifier string `json:"release_identifier,omitempty"`
+
+	// Whether the check or update succeeded.
+	Success bool `json:"success"`
+
+	// Class of the error, e.g. ErrorClassChecksum, if it failed.
+	ErrorClass string `json:"error_class,omitempty"`
+
+	// Error that caused the failure, if any.
+	Error string `json:"error,omitempty"`
+
+	// Operating system and architecture of the client.
+	OS   string `json:"os"`
+	Arch string `json:"arch"`
+
+	// Time at which the check or update started.
+	StartedAt time.Time `json:"started_at"`
+
+	// Time the check or update took, in nanoseconds when serialized.
+	Duration time.Duration `json:"duration"`
+}
+
+// Reporter receives the outcome of every check for updates and every update,
+// e.g. to send it to the update server of the team, so that maintainers can
+// see the adoption and failure rate of each release.
+//
+// Reporters are called after the check or update, with the context of the
+// check or update. Their errors are logged, and do not fail the update.
+type Reporter interface {
+	Report(ctx context.Context, outcome *Outcome) error
+}
+
+// ReporterFunc is a function that can be used as a Reporter.
+type ReporterFunc func(ctx context.Context, outcome *Outcome) error
+
+// Report calls f.
+func (f ReporterFunc) Report(ctx context.Context, outcome *Outcome) error {
+	return f(ctx, outcome)
+}
+
+type httpReporter struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPReporter creates a Reporter that posts every outcome as JSON to url,
+// e.g. the report endpoint of a server.Handler.
+//
+// Set client to nil to use the HTTPClient of the Updater, or the default HTTP
+// client. Any 2xx response is a success.
+func NewHTTPReporter(url string, client *http.Client) Reporter {
+	return &httpReporter{
+		url:    url,
+		client: client,
+	}
+}
+
+func (r *httpReporter) Report(ctx context.Context, outcome *Outcome) error {
+	b, err := json.Marshal(outcome)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", r.url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := downloadResponse(ctx, r.client, req, ioutil.Discard)
+	if resp != nil && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	return err
+}
+
+// reportOutcome reports the outcome of an event to the Reporter of the
+// updater, if there is one, and records it in its Metrics. The report of an
+// update is used to classify its error.
+func (u *Updater) reportOutcome(
+	ctx context.Context,
+	event string,
+	started time.Time,
+	release Release,
+	report *UpdateReport,
+	err error,
+) {
+	duration := time.Since(started)
+	class := errorClass(err, report)
+	u.observeOutcome(event, duration, release, report, class)
+	if u.Reporter == nil {
+		return
+	}
+
+	o := &Outcome{
+		Event:          event,
+		CurrentRelease: u.currentIdentifier(),
+		Success:        err == nil,
+		ErrorClass:     class,
+		Error:          errorString(err),
+		OS:             runtime.GOOS,
+		Arch:           runtime.GOARCH,
+		StartedAt:      started,
+		Duration:       duration,
+	}
+	if release != nil {
+		o.Release = release.Name()
+		o.ReleaseIdentifier = release.Identifier()
+	}
+	if u.SendInstallationID {
+		o.InstallationID, _ = u.InstallationID()
+	}
+
+	ctx = withLogger(withHTTPClient(ctx, u.HTTPClient), u.Logger)
+	if err := u.Reporter.Report(ctx, o); err != nil {
+		u.logf("Could not report %v outcome: %v", event, err)
+	}
+}
+
+// errorClass returns the class of err, using the verifications in report if
+// it is not nil, or an empty string if err is nil.
+func errorClass(err error, report *UpdateReport) string {
+	if err == nil {
+		return ""
+	}
+
+	var rateErr *RateLimitError
+	var downloadErr *DownloadError
+	var managedErr *ErrExternallyManaged
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		return ErrorClassCancelled
+	case errors.As(err, &rateErr):
+		return ErrorClassRateLimit
+	case errors.As(err, &downloadErr) && downloadErr.RateLimited():
+		return ErrorClassRateLimit
+	case errors.As(err, &downloadErr):
+		return ErrorClassDownload
+	case errors.As(err, &netErr):
+		return ErrorClassNetwork
+	case errors.Is(err, ErrNoRelease):
+		return ErrorClassNoRelease
+	case errors.As(err, &managedErr):
+		return ErrorClassManaged
+	}
+
+	if report == nil {
+		return ErrorClassOther
+	}
+	written := len(report.Assets) > 0
+	for _, a := range report.Assets {
+		if n := len(a.Verifications); n > 0 && !a.Verifications[n-1].Passed {
+			switch a.Verifications[n-1].Name {
+			case "checksum":
+				return ErrorClassChecksum
+			case "signature":
+				return ErrorClassSignature
+			default:
+				return ErrorClassCheck
+			}
+		}
+		if a.Error != "" {
+			written = false
+		}
+	}
+	if written {
+		return ErrorClassInstall
+	}
+	return ErrorClassOther
+}
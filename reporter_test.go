@@ -0,0 +1,132 @@
+package updater
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdaterReporter(t *testing.T) {
+	var outcomes []*Outcome
+	reporter := ReporterFunc(func(ctx context.Context, o *Outcome) error {
+		outcomes = append(outcomes, o)
+		return errors.New("Ignored.")
+	})
+	asset := &testAsset{
+		name: "myapp",
+		write: func(w io.Writer) error {
+			_, err := io.WriteString(w, "Hello World!")
+			return err
+		},
+	}
+	r := &testRelease{name: "v2", identifier: "2", assets: []Asset{asset}}
+
+	// Check and update
+	{
+		u := &Updater{
+			App:                      &testApp{FLatestRelease: func() Release { return r }},
+			CurrentReleaseIdentifier: "1",
+			MachineID:                "abc",
+			SendInstallationID:       true,
+			WriterForAsset:           func(Asset) (AbortWriter, error) { return NewAbortBuffer(nil), nil },
+			Reporter:                 reporter,
+		}
+		err := u.UpdateTo(nil)
+		require.Nil(t, err, "Could not update: %v", err)
+		require.Equal(t, 2, len(outcomes))
+
+		assert.Equal(t, OutcomeCheck, outcomes[0].Event)
+		assert.Equal(t, "v2", outcomes[0].Release)
+		assert.True(t, outcomes[0].Success)
+
+		o := outcomes[1]
+		assert.Equal(t, OutcomeApply, o.Event)
+		assert.Equal(t, "abc", o.InstallationID)
+		assert.Equal(t, "1", o.CurrentRelease)
+		assert.Equal(t, "v2", o.Release)
+		assert.Equal(t, "2", o.ReleaseIdentifier)
+		assert.True(t, o.Success)
+		assert.Equal(t, "", o.ErrorClass)
+		assert.Equal(t, runtime.GOOS, o.OS)
+	}
+
+	// Checksum mismatch
+	{
+		outcomes = nil
+		sums := &testAsset{
+			name: "SHA256SUMS",
+			write: func(w io.Writer) error {
+				_, err := io.WriteString(w, strings.Repeat("0", 64)+"  myapp\n")
+				return err
+			},
+		}
+		u := &Updater{
+			ChecksumAssetName: "SHA256SUMS",
+			WriterForAsset:    func(Asset) (AbortWriter, error) { return NewAbortBuffer(nil), nil },
+			Reporter:          reporter,
+		}
+		err := u.UpdateTo(&testRelease{name: "v2", assets: []Asset{asset, sums}})
+		require.NotNil(t, err)
+		require.Equal(t, 1, len(outcomes))
+		assert.False(t, outcomes[0].Success)
+		assert.Equal(t, ErrorClassChecksum, outcomes[0].ErrorClass)
+		assert.Equal(t, err.Error(), outcomes[0].Error)
+		assert.Equal(t, "", outcomes[0].InstallationID)
+	}
+
+	// Failed check
+	{
+		outcomes = nil
+		u := &Updater{
+			App:      &testApp{FQuery: func() error { return &DownloadError{StatusCode: 404} }},
+			Reporter: reporter,
+		}
+		_, err := u.Check()
+		require.NotNil(t, err)
+		require.Equal(t, 1, len(outcomes))
+		assert.Equal(t, OutcomeCheck, outcomes[0].Event)
+		assert.Equal(t, ErrorClassDownload, outcomes[0].ErrorClass)
+
+		outcomes = nil
+		u.App = &testApp{}
+		_, err = u.Check()
+		assert.Equal(t, ErrNoRelease, err)
+		assert.Equal(t, ErrorClassNoRelease, outcomes[0].ErrorClass)
+	}
+}
+
+func TestHTTPReporter(t *testing.T) {
+	var received []Outcome
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/report" {
+			w.WriteHeader(404)
+			return
+		}
+		var o Outcome
+		if err := json.NewDecoder(r.Body).Decode(&o); err != nil {
+			w.WriteHeader(400)
+			return
+		}
+		received = append(received, o)
+		w.WriteHeader(204)
+	}))
+	defer ts.Close()
+
+	o := &Outcome{Event: OutcomeApply, Release: "v2", Success: true}
+	err := NewHTTPReporter(ts.URL+"/report", nil).Report(context.Background(), o)
+	assert.Nil(t, err, "Could not report: %v", err)
+	require.Equal(t, 1, len(received))
+	assert.Equal(t, "v2", received[0].Release)
+
+	err = NewHTTPReporter(ts.URL+"/other", nil).Report(context.Background(), o)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,214 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// restartExecutable replaces the running process with a new process of exe.
+// It is a variable so that tests can replace it.
+var restartExecutable = restart
+
+// RestartStrategy restarts the application after its executable was
+// replaced, e.g. by re-executing it, or by asking the service manager of a
+// daemon to restart it.
+type RestartStrategy interface {
+	// Restart should restart the application, whose executable is now exe.
+	// It does not need to return when the restart succeeded.
+	Restart(exe string) error
+}
+
+// ExecRestart is a RestartStrategy that re-executes the application with the
+// same arguments and environment. On Unix, the running process is replaced.
+// On Windows, the new executable is started in a new process and the current
+// process exits.
+type ExecRestart struct{}
+
+// Restart re-executes exe. It only returns if exe could not be executed.
+func (ExecRestart) Restart(exe string) error {
+	return restartExecutable(exe)
+}
+
+// SystemdRestart is a RestartStrategy for daemons managed by systemd, which
+// restarts the unit with systemctl.
+type SystemdRestart struct {
+	// Name of the unit, e.g. myapp.service.
+	Unit string
+
+	// Whether the unit is a user unit, managed with systemctl --user.
+	User bool
+}
+
+// Restart asks systemd to restart the unit, without waiting for it, as the
+// restart stops the current process.
+func (s *SystemdRestart) Restart(exe string) error {
+	return runRestartCommand(s.command())
+}
+
+func (s *SystemdRestart) command() []string {
+	args := []string{"systemctl"}
+	if s.User {
+		args = append(args, "--user")
+	}
+	return append(args, "--no-block", "restart", s.Unit)
+}
+
+// SystemdExit is a RestartStrategy for systemd units that are restarted when
+// they exit, e.g. with Restart=always. It notifies systemd that the service is
+// stopping, and exits.
+type SystemdExit struct {
+	// Exit code of the process. Use a non-zero code for units with
+	// Restart=on-failure.
+	Code int
+}
+
+// Restart notifies systemd with sd_notify, if the process was started by
+// systemd, and exits. It only returns if the notification failed.
+func (s *SystemdExit) Restart(exe string) error {
+	err := sdNotify("STOPPING=1")
+	if err != nil {
+		return err
+	}
+	os.Exit(s.Code)
+	return nil
+}
+
+// sdNotify sends state to the notification socket of systemd. Nothing is sent
+// if the process was not started by systemd.
+func sdNotify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	if strings.HasPrefix(socket, "@") {
+		// Abstract socket
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.Dial("unixgram", socket)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// LaunchdRestart is a RestartStrategy for daemons and agents managed by
+// launchd on macOS, which restarts the service with launchctl kickstart.
+type LaunchdRestart struct {
+	// Label of the service, e.g. com.example.myapp.
+	Label string
+
+	// Domain of the service, e.g. gui/501 for an agent of the user with ID
+	// 501. Defaults to system, for daemons.
+	Domain string
+}
+
+// Restart asks launchd to kill and restart the service.
+func (l *LaunchdRestart) Restart(exe string) error {
+	return runRestartCommand(l.command())
+}
+
+func (l *LaunchdRestart) command() []string {
+	domain := l.Domain
+	if domain == "" {
+		domain = "system"
+	}
+	return []string{"launchctl", "kickstart", "-k", domain + "/" + l.Label}
+}
+
+// WindowsServiceRestart is a RestartStrategy for Windows services, which
+// restarts the service with the service control manager.
+type WindowsServiceRestart struct {
+	// Name of the service.
+	Name string
+}
+
+// Restart starts a process that restarts the service, without waiting for
+// it, as the restart stops the current process.
+func (w *WindowsServiceRestart) Restart(exe string) error {
+	args := w.command()
+	return exec.Command(args[0], args[1:]...).Start()
+}
+
+func (w *WindowsServiceRestart) command() []string {
+	name := strings.Replace(w.Name, "'", "''", -1)
+	return []string{
+		"powershell.exe", "-NoProfile", "-NonInteractive", "-Command",
+		"Restart-Service -Force -Name '" + name + "'",
+	}
+}
+
+// runRestartCommand runs the command with arguments args.
+func runRestartCommand(args []string) error {
+	out, err := exec.Command(args[0], args[1:]...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("Could not restart with %v: %v: %s", args[0], err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// ApplyStagedIfAny applies the release that was staged with Download, if any,
+// and restarts the application. Call it at the start of the program, before
+// the main program logic runs, to apply updates that were downloaded in the
+// background during the previous run.
+//
+// The staged release replaces the running executable like SelfUpdate, so set
+// the AssetFilter before calling Download to only stage the executable for
+// the current platform, e.g. to DefaultPlatformFilter(). The application is
+// then restarted with the Restart strategy of the updater, by default
+// ExecRestart, and ApplyStagedIfAny does not return.
+//
+// Nil is returned if no release is staged, or if the staged release is the
+// current release. If the staged release cannot be applied, an error is
+// returned and the application can continue with the current executable.
+func (u *Updater) ApplyStagedIfAny() error {
+	ctx := context.Background()
+	r, err := u.StagedRelease()
+	if err != nil || r == nil {
+		return err
+	}
+
+	if r.Identifier() == u.currentIdentifier() {
+		u.logf("Staged release %v is the current release", r.Name())
+		return u.removeStaged()
+	}
+
+	exe, err := osExecutable()
+	if err != nil {
+		return err
+	}
+	exe, err = filepath.EvalSymlinks(exe)
+	if err != nil {
+		return err
+	}
+
+	u.updating.Lock()
+	err = u.selfUpdateReported(ctx, r, exe, nil)
+	u.updating.Unlock()
+	if err != nil {
+		if err != ErrUpdateInProgress {
+			u.removeStaged()
+		}
+		return err
+	}
+
+	err = u.removeStaged()
+	if err != nil {
+		return err
+	}
+
+	strategy := u.Restart
+	if strategy == nil {
+		strategy = ExecRestart{}
+	}
+	u.logf("Restarting %v", exe)
+	return strategy.Restart(exe)
+}
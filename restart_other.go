@@ -0,0 +1,11 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !windows
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!windows
+
+package updater
+
+import "errors"
+
+// restart fails, processes cannot be restarted on this platform.
+func restart(exe string) error {
+	return errors.New("Restarting is not supported on this platform.")
+}
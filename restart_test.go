@@ -0,0 +1,124 @@
+package updater
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdaterApplyStagedIfAny(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testing-")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	exe := filepath.Join(dir, "myapp")
+	require.Nil(t, ioutil.WriteFile(exe, []byte("old executable"), 0755))
+	defer func() { osExecutable = os.Executable }()
+	osExecutable = func() (string, error) { return exe, nil }
+
+	var restarted string
+	defer func() { restartExecutable = restart }()
+	restartExecutable = func(exe string) error {
+		restarted = exe
+		return nil
+	}
+
+	release := &testRelease{
+		name:       "v2",
+		identifier: "c2",
+		assets: []Asset{&testAsset{
+			name: "myapp_" + runtime.GOOS + "_" + runtime.GOARCH,
+			write: func(w io.Writer) error {
+				_, err := io.WriteString(w, "new executable")
+				return err
+			},
+		}},
+	}
+	newUpdater := func() *Updater {
+		return &Updater{
+			CurrentReleaseIdentifier: "c1",
+			StageDir:                 filepath.Join(dir, "staged"),
+			AssetFilter:              DefaultPlatformFilter(),
+		}
+	}
+
+	// Nothing staged
+	{
+		assert.Nil(t, newUpdater().ApplyStagedIfAny())
+		assert.Equal(t, "", restarted)
+	}
+
+	// Staged executable applied
+	{
+		require.Nil(t, newUpdater().Download(release))
+		b, _ := ioutil.ReadFile(exe)
+		assert.Equal(t, "old executable", string(b))
+
+		err := newUpdater().ApplyStagedIfAny()
+		assert.Nil(t, err, "Could not apply: %v", err)
+		assert.Equal(t, exe, restarted)
+		b, _ = ioutil.ReadFile(exe)
+		assert.Equal(t, "new executable", string(b))
+
+		r, err := newUpdater().StagedRelease()
+		assert.Nil(t, err)
+		assert.Nil(t, r)
+	}
+}
+
+func TestRestartStrategies(t *testing.T) {
+	// Commands
+	{
+		assert.Equal(t,
+			[]string{"systemctl", "--no-block", "restart", "myapp.service"},
+			(&SystemdRestart{Unit: "myapp.service"}).command(),
+		)
+		assert.Equal(t,
+			[]string{"systemctl", "--user", "--no-block", "restart", "myapp.service"},
+			(&SystemdRestart{Unit: "myapp.service", User: true}).command(),
+		)
+		assert.Equal(t,
+			[]string{"launchctl", "kickstart", "-k", "system/com.example.myapp"},
+			(&LaunchdRestart{Label: "com.example.myapp"}).command(),
+		)
+		assert.Equal(t,
+			[]string{"launchctl", "kickstart", "-k", "gui/501/com.example.myapp"},
+			(&LaunchdRestart{Label: "com.example.myapp", Domain: "gui/501"}).command(),
+		)
+		assert.Equal(t,
+			"Restart-Service -Force -Name 'My''App'",
+			(&WindowsServiceRestart{Name: "My'App"}).command()[4],
+		)
+	}
+
+	// Notify systemd
+	if runtime.GOOS == "linux" {
+		dir, err := ioutil.TempDir("", "testing-")
+		require.Nil(t, err)
+		defer os.RemoveAll(dir)
+
+		socket := filepath.Join(dir, "notify")
+		conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+		require.Nil(t, err)
+		defer conn.Close()
+
+		defer os.Setenv("NOTIFY_SOCKET", os.Getenv("NOTIFY_SOCKET"))
+		os.Setenv("NOTIFY_SOCKET", socket)
+		require.Nil(t, sdNotify("STOPPING=1"))
+
+		b := make([]byte, 64)
+		n, err := conn.Read(b)
+		require.Nil(t, err)
+		assert.Equal(t, "STOPPING=1", string(b[:n]))
+
+		os.Unsetenv("NOTIFY_SOCKET")
+		assert.Nil(t, sdNotify("STOPPING=1"))
+	}
+}
@@ -0,0 +1,15 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package updater
+
+import (
+	"os"
+	"syscall"
+)
+
+// restart replaces the running process with exe, with the same arguments and
+// environment. It only returns if exe could not be executed.
+func restart(exe string) error {
+	return syscall.Exec(exe, os.Args, os.Environ())
+}
@@ -0,0 +1,22 @@
+package updater
+
+import (
+	"os"
+	"os/exec"
+)
+
+// restart starts exe in a new process, with the same arguments and
+// environment, and exits. It only returns if exe could not be started.
+func restart(exe string) error {
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err := cmd.Start()
+	if err != nil {
+		return err
+	}
+
+	os.Exit(0)
+	return nil
+}
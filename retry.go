@@ -0,0 +1,161 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// RetryPolicy configures how failed asset downloads are retried.
+//
+// Only transient failures are retried: connection errors, interrupted
+// transfers and server errors (HTTP 5xx). Errors returned by the writer of an
+// asset are never retried.
+//
+// A retried download does not write the data that was already written again,
+// the writer simply receives the rest of the asset. This assumes the asset
+// does not change in the meantime; use ChecksumAssetName to be sure.
+type RetryPolicy struct {
+	// Maximum number of attempts to download an asset. Zero or one disables
+	// retrying.
+	MaxAttempts int
+
+	// Time to wait before the first retry. It is doubled after every attempt.
+	// Defaults to one second.
+	Backoff time.Duration
+
+	// Maximum time to wait between attempts. Defaults to 30 seconds.
+	MaxBackoff time.Duration
+}
+
+const (
+	defaultRetryBackoff    = time.Second
+	defaultRetryMaxBackoff = 30 * time.Second
+)
+
+// writeAsset writes a to w, retrying transient failures according to the
+// retry policy.
+//
+// Failures are reported as an *AssetDownloadError, unless ctx was cancelled.
+func (u *Updater) writeAsset(ctx context.Context, a Asset, w io.Writer) error {
+	err := u.writeAssetRetry(ctx, u.mirrored(a), w)
+	if err != nil && ctx.Err() == nil {
+		return &AssetDownloadError{Asset: a, Cause: err}
+	}
+	return err
+}
+
+func (u *Updater) writeAssetRetry(ctx context.Context, a Asset, w io.Writer) error {
+	if u.Retry.MaxAttempts <= 1 {
+		return writeAsset(ctx, a, w)
+	}
+
+	backoff := u.Retry.Backoff
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
+	}
+	maxBackoff := u.Retry.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultRetryMaxBackoff
+	}
+
+	rw := &resumeWriter{w: w}
+	for attempt := 1; ; attempt++ {
+		rw.skip = rw.written
+		err := writeAsset(ctx, a, rw)
+		if err == nil || rw.err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if attempt >= u.Retry.MaxAttempts || !isRetryable(err) {
+			return err
+		}
+		logf(ctx, "Retrying asset %v in %v after attempt %v failed: %v", a.Name(), backoff, attempt, err)
+
+		t := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// isRetryable reports whether err is a transient download failure.
+func isRetryable(err error) bool {
+	var downloadErr *DownloadError
+	if errors.As(err, &downloadErr) {
+		return downloadErr.StatusCode >= http.StatusInternalServerError
+	}
+
+	var githubErr *github.ErrorResponse
+	if errors.As(err, &githubErr) {
+		return githubErr.Response != nil &&
+			githubErr.Response.StatusCode >= http.StatusInternalServerError
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	return errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// resumeWriter is a writer that drops the data that was already written by a
+// previous attempt.
+type resumeWriter struct {
+	w io.Writer
+
+	// Bytes written to w.
+	written int64
+
+	// Bytes to drop before writing to w.
+	skip int64
+
+	// First error returned by w.
+	err error
+}
+
+func (r *resumeWriter) Write(b []byte) (int, error) {
+	n := len(b)
+	if r.skip > 0 {
+		k := r.skip
+		if k > int64(n) {
+			k = int64(n)
+		}
+		b = b[k:]
+		r.skip -= k
+	}
+
+	if len(b) == 0 {
+		return n, nil
+	}
+
+	m, err := r.w.Write(b)
+	r.written += int64(m)
+	if err != nil {
+		r.err = err
+		return n - len(b) + m, err
+	}
+	return n, nil
+}
+
+func (r *resumeWriter) setTotal(total int64) {
+	if s, ok := r.w.(totalSetter); ok {
+		s.setTotal(total)
+	}
+}
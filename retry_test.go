@@ -0,0 +1,161 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdaterRetry(t *testing.T) {
+	retry := RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}
+
+	// Server errors
+	{
+		attempts := 0
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+			if attempts < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte("Hello World!"))
+		}))
+
+		a := &manifestAsset{url: ts.URL}
+		buf := bytes.NewBuffer(nil)
+		u := &Updater{Retry: retry}
+		err := u.writeAsset(context.Background(), a, buf)
+		assert.Nil(t, err, "Unexpected write error: %v", err)
+		assert.Equal(t, 3, attempts)
+		assert.Equal(t, "Hello World!", buf.String())
+
+		// Too many failures
+		attempts = -10
+		buf.Reset()
+		err = u.writeAsset(context.Background(), a, buf)
+		assert.Error(t, err)
+		assert.Equal(t, -7, attempts)
+
+		// Without retry policy
+		attempts = 0
+		err = (&Updater{}).writeAsset(context.Background(), a, buf)
+		assert.Error(t, err)
+		assert.Equal(t, 1, attempts)
+
+		ts.Close()
+	}
+
+	// Client errors are not retried
+	{
+		attempts := 0
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+			w.WriteHeader(http.StatusNotFound)
+		}))
+
+		u := &Updater{Retry: retry}
+		err := u.writeAsset(context.Background(), &manifestAsset{url: ts.URL}, bytes.NewBuffer(nil))
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "404")
+		assert.Equal(t, 1, attempts)
+
+		ts.Close()
+	}
+
+	// Interrupted transfers are resumed
+	{
+		attempts := 0
+		a := &testAsset{
+			write: func(w io.Writer) error {
+				attempts++
+				if attempts == 1 {
+					w.Write([]byte("Hello "))
+					return io.ErrUnexpectedEOF
+				}
+				_, err := w.Write([]byte("Hello World!"))
+				return err
+			},
+		}
+
+		var progress []int64
+		buf := bytes.NewBuffer(nil)
+		u := &Updater{
+			Retry: retry,
+			WriterForAsset: func(Asset) (AbortWriter, error) {
+				return &bufferAbortWriter{Buffer: buf}, nil
+			},
+			Progress: func(a Asset, written, total int64) {
+				progress = append(progress, written)
+			},
+		}
+
+		err := u.UpdateTo(&testRelease{assets: []Asset{a}})
+		assert.Nil(t, err, "Unexpected update error: %v", err)
+		assert.Equal(t, 2, attempts)
+		assert.Equal(t, "Hello World!", buf.String())
+		assert.Equal(t, []int64{6, 12}, progress)
+	}
+
+	// Writer errors are not retried
+	{
+		attempts := 0
+		a := &testAsset{
+			write: func(w io.Writer) error {
+				attempts++
+				_, err := w.Write([]byte("Hello World!"))
+				return err
+			},
+		}
+
+		u := &Updater{Retry: retry}
+		err := u.writeAsset(context.Background(), a, &failingWriter{})
+		assert.Error(t, err)
+		assert.Equal(t, 1, attempts)
+	}
+
+	// Cancelled while waiting
+	{
+		ctx, cancel := context.WithCancel(context.Background())
+		a := &testAsset{
+			write: func(w io.Writer) error {
+				cancel()
+				return io.ErrUnexpectedEOF
+			},
+		}
+
+		u := &Updater{Retry: RetryPolicy{MaxAttempts: 3, Backoff: time.Hour}}
+		err := u.writeAsset(ctx, a, bytes.NewBuffer(nil))
+		assert.Equal(t, context.Canceled, err)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	assert.True(t, isRetryable(&DownloadError{StatusCode: 502}))
+	assert.False(t, isRetryable(&DownloadError{StatusCode: 403}))
+	assert.True(t, isRetryable(io.ErrUnexpectedEOF))
+	assert.False(t, isRetryable(errors.New("Other error.")))
+
+	_, err := http.Get("http://127.0.0.1:0/")
+	require.Error(t, err)
+	assert.True(t, isRetryable(err))
+}
+
+type bufferAbortWriter struct {
+	*bytes.Buffer
+}
+
+func (w *bufferAbortWriter) Abort() {}
+
+type failingWriter struct{}
+
+func (w *failingWriter) Write(b []byte) (int, error) {
+	return 0, errors.New("Disk full.")
+}
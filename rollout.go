@@ -0,0 +1,98 @@
+package updater
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"regexp"
+	"strconv"
+)
+
+// Token that sets the rollout percentage of a release in its release notes,
+// e.g. [rollout: 25%].
+const RolloutToken = "[rollout: N%]"
+
+var rolloutTokenRegexp = regexp.MustCompile(`(?i)\[rollout:?\s*(\d{1,3})\s*%\]`)
+
+// RolloutPercentage returns the percentage of clients that should update to
+// release, from 0 to 100, so that a release can be rolled out gradually.
+//
+// Releases that implement RolloutRelease decide for themselves. Other
+// releases are rolled out to the percentage in a RolloutToken in their
+// release notes, so that the rollout of e.g. a GitHub release can be
+// increased by editing its description. Releases without one are rolled out
+// to all clients.
+//
+// The updater decides whether a client is in the rollout with the MachineID
+// of the Updater.
+func RolloutPercentage(release Release) int {
+	if release == nil {
+		return 100
+	}
+
+	if r, ok := release.(RolloutRelease); ok {
+		return clampPercentage(r.RolloutPercentage())
+	}
+	if p, ok := parseRolloutToken(release.Information()); ok {
+		return p
+	}
+	return 100
+}
+
+// parseRolloutToken returns the percentage of the RolloutToken in the release
+// notes.
+func parseRolloutToken(notes string) (int, bool) {
+	m := rolloutTokenRegexp.FindStringSubmatch(notes)
+	if m == nil {
+		return 0, false
+	}
+	p, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0, false
+	}
+	return clampPercentage(p), true
+}
+
+// manifestRollout returns the rollout percentage of a release described by
+// a manifest, from the manifest field or the release notes.
+func manifestRollout(m Manifest) int {
+	if m.Rollout != nil {
+		return clampPercentage(*m.Rollout)
+	}
+	if p, ok := parseRolloutToken(m.Notes); ok {
+		return p
+	}
+	return 100
+}
+
+func clampPercentage(p int) int {
+	if p < 0 {
+		return 0
+	} else if p > 100 {
+		return 100
+	}
+	return p
+}
+
+// inRollout reports whether the client is in the rollout of release.
+func (u *Updater) inRollout(release Release) bool {
+	p := RolloutPercentage(release)
+	if p >= 100 {
+		return true
+	}
+
+	id, err := u.InstallationID()
+	if err != nil {
+		u.logf("Could not identify the client for the rollout of %v: %v", release.Name(), err)
+		return false
+	}
+	return rolloutBucket(id, release) < p
+}
+
+// rolloutBucket hashes the machine identifier and the release to a number
+// from 0 to 99. A client stays in the rollout of a release when the
+// percentage increases, and is in a different part of the clients for every
+// release.
+func rolloutBucket(machineID string, release Release) int {
+	sum := sha256.Sum256([]byte(release.Identifier() + "\x00" + machineID))
+	return int(binary.BigEndian.Uint64(sum[:8]) % 100)
+}
@@ -0,0 +1,103 @@
+package updater
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRolloutPercentage(t *testing.T) {
+	assert.Equal(t, 100, RolloutPercentage(nil))
+	assert.Equal(t, 100, RolloutPercentage(&testRelease{information: "Bug fixes."}))
+	assert.Equal(t, 25, RolloutPercentage(&testRelease{information: "Bug fixes.\n\n[Rollout: 25%]"}))
+	assert.Equal(t, 5, RolloutPercentage(&testRelease{information: "[rollout 5 %]"}))
+	assert.Equal(t, 100, RolloutPercentage(&testRelease{information: "[rollout: 250%]"}))
+
+	// Manifest field
+	p := 10
+	assert.Equal(t, 10, RolloutPercentage(&manifestRelease{Manifest: Manifest{Rollout: &p, Notes: "[rollout: 50%]"}}))
+	assert.Equal(t, 50, RolloutPercentage(&manifestRelease{Manifest: Manifest{Notes: "[rollout: 50%]"}}))
+	assert.Equal(t, 100, RolloutPercentage(&manifestRelease{Manifest: Manifest{}}))
+}
+
+func TestUpdaterRollout(t *testing.T) {
+	r := &testRelease{name: "v2", identifier: "2", information: "[rollout: 30%]"}
+	check := func(id string) bool {
+		u := &Updater{
+			App:                      &testApp{FLatestRelease: func() Release { return r }},
+			CurrentReleaseIdentifier: "1",
+			MachineID:                id,
+		}
+		latest, err := u.Check()
+		require.Nil(t, err)
+		return latest != nil
+	}
+
+	// Part of the clients
+	{
+		n := 0
+		for i := 0; i < 1000; i++ {
+			if check(fmt.Sprintf("machine-%v", i)) {
+				n++
+			}
+		}
+		assert.True(t, n > 250 && n < 350, "Clients in rollout: %v", n)
+	}
+
+	// Stable for a client, which stays in the rollout when it grows
+	{
+		var in []string
+		for i := 0; i < 100; i++ {
+			id := fmt.Sprintf("machine-%v", i)
+			if check(id) {
+				assert.True(t, check(id))
+				in = append(in, id)
+			}
+		}
+		r.information = "[rollout: 60%]"
+		for _, id := range in {
+			assert.True(t, check(id), "Client %v", id)
+		}
+	}
+
+	// Without a machine identifier
+	{
+		assert.False(t, check(""))
+		r.information = "[rollout: 100%]"
+		assert.True(t, check(""))
+		r.information = "[rollout: 0%]"
+		assert.False(t, check("machine-1"))
+	}
+
+	// Machine identifier in the state
+	{
+		dir, err := ioutil.TempDir("", "testing-")
+		require.Nil(t, err)
+		defer os.RemoveAll(dir)
+
+		r.information = "[rollout: 50%]"
+		u := &Updater{
+			App:                      &testApp{FLatestRelease: func() Release { return r }},
+			CurrentReleaseIdentifier: "1",
+			State:                    NewFileStateStore(filepath.Join(dir, "state.json")),
+		}
+		first, err := u.Check()
+		require.Nil(t, err)
+
+		s, err := u.State.Load()
+		require.Nil(t, err)
+		assert.Equal(t, 32, len(s.MachineID))
+		for i := 0; i < 5; i++ {
+			latest, err := u.Check()
+			require.Nil(t, err)
+			assert.Equal(t, first, latest)
+		}
+		s2, _ := u.State.Load()
+		assert.Equal(t, s.MachineID, s2.MachineID)
+	}
+}
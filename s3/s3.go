@@ -0,0 +1,81 @@
+// Package s3 fetches the releases of an application from an S3 bucket, or
+// from S3-compatible storage like MinIO:
+//
+//	sess := session.Must(session.NewSession())
+//	app := s3.New(awss3.New(sess), "releases", "myapp/")
+//	u := &updater.Updater{App: app}
+//
+// See updater.NewObjectStore for the layout of the objects under the prefix.
+package s3
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	awss3 "github.com/aws/aws-sdk-go/service/s3"
+	updater "github.com/hverr/go-updater"
+)
+
+// Client is the part of the S3 API used to fetch releases.
+//
+// It is implemented by *s3.S3 from the AWS SDK, which can also be configured
+// to talk to S3-compatible storage like MinIO.
+type Client interface {
+	GetObjectWithContext(aws.Context, *awss3.GetObjectInput, ...request.Option) (*awss3.GetObjectOutput, error)
+	ListObjectsV2PagesWithContext(aws.Context, *awss3.ListObjectsV2Input, func(*awss3.ListObjectsV2Output, bool) bool, ...request.Option) error
+}
+
+type store struct {
+	client Client
+	bucket string
+}
+
+// New creates an Application whose releases are stored in an S3 bucket.
+//
+// See updater.NewObjectStore for the layout of the objects under prefix.
+func New(client Client, bucket, prefix string) updater.App {
+	return updater.NewObjectStore(&store{
+		client: client,
+		bucket: bucket,
+	}, prefix)
+}
+
+func (s *store) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	input := &awss3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	}
+	err := s.client.ListObjectsV2PagesWithContext(ctx, input, func(page *awss3.ListObjectsV2Output, last bool) bool {
+		for _, o := range page.Contents {
+			keys = append(keys, aws.StringValue(o.Key))
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func (s *store) Get(ctx context.Context, key string, w io.Writer) error {
+	out, err := s.client.GetObjectWithContext(ctx, &awss3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("Could not download s3://%v/%v: %v", s.bucket, key, err)
+	}
+	defer out.Body.Close()
+
+	if out.ContentLength != nil {
+		updater.SetTotalSize(w, *out.ContentLength)
+	}
+
+	_, err = io.Copy(w, out.Body)
+	return err
+}
@@ -0,0 +1,93 @@
+package s3
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	awss3 "github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testClient serves objects from memory in pages of one object.
+type testClient struct {
+	bucket  string
+	objects map[string]string
+}
+
+func (c *testClient) GetObjectWithContext(ctx aws.Context, input *awss3.GetObjectInput, opts ...request.Option) (*awss3.GetObjectOutput, error) {
+	data, ok := c.objects[aws.StringValue(input.Key)]
+	if aws.StringValue(input.Bucket) != c.bucket || !ok {
+		return nil, errors.New("NoSuchKey")
+	}
+
+	size := int64(len(data))
+	return &awss3.GetObjectOutput{
+		Body:          ioutil.NopCloser(strings.NewReader(data)),
+		ContentLength: &size,
+	}, nil
+}
+
+func (c *testClient) ListObjectsV2PagesWithContext(ctx aws.Context, input *awss3.ListObjectsV2Input, fn func(*awss3.ListObjectsV2Output, bool) bool, opts ...request.Option) error {
+	var keys []string
+	for k := range c.objects {
+		if strings.HasPrefix(k, aws.StringValue(input.Prefix)) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for i, k := range keys {
+		page := &awss3.ListObjectsV2Output{
+			Contents: []*awss3.Object{{Key: aws.String(k)}},
+		}
+		if !fn(page, i == len(keys)-1) {
+			break
+		}
+	}
+	return nil
+}
+
+func TestS3(t *testing.T) {
+	client := &testClient{
+		bucket: "releases",
+		objects: map[string]string{
+			"latest":                         "v1.0.0",
+			"releases/v1.0.0/myapp.exe":      "Hello Windows!",
+			"releases/v1.0.0/myapp_linux_64": "Hello Linux!",
+		},
+	}
+
+	// Valid bucket
+	{
+		app := New(client, "releases", "")
+		err := app.Query()
+		assert.Nil(t, err, "Unexpected query error: %v", err)
+
+		r := app.LatestRelease()
+		require.NotNil(t, r)
+		assert.Equal(t, "v1.0.0", r.Name())
+		assert.Equal(t, 2, len(r.Assets()))
+
+		for _, a := range r.Assets() {
+			buf := bytes.NewBuffer(nil)
+			err := a.Write(buf)
+			assert.Nil(t, err)
+			assert.Equal(t, client.objects["releases/v1.0.0/"+a.Name()], buf.String())
+		}
+	}
+
+	// Invalid bucket
+	{
+		app := New(client, "other", "")
+		err := app.Query()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "s3://other/latest")
+	}
+}
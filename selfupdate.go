@@ -0,0 +1,215 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// osExecutable returns the path of the running executable.
+var osExecutable = os.Executable
+
+// SelfUpdate replaces the running executable with the latest release.
+//
+// The first asset accepted by AssetFilter is installed, or the first asset
+// accepted by DefaultPlatformFilter if no filter is set. The asset should be
+// the executable itself. WriterForAsset is not used.
+//
+// The asset is downloaded next to the running executable, and only swapped
+// in place when it was written and verified successfully. On Windows, the
+// running executable is renamed to a file with the .old extension first,
+// because it cannot be overwritten while it is running. Call
+// RemoveOldExecutable when the application starts to clean it up.
+//
+// When PatchAssetName is set and the release contains a matching patch, the
+// patch is applied to the running executable instead of downloading the full
+// asset.
+//
+// The release that was installed is returned. When the application is already
+// up to date, nil is returned.
+func (u *Updater) SelfUpdate() (Release, error) {
+	return u.SelfUpdateContext(context.Background())
+}
+
+// SelfUpdateContext is like SelfUpdate but aborts when ctx is cancelled.
+func (u *Updater) SelfUpdateContext(ctx context.Context) (Release, error) {
+	u.updating.Lock()
+	defer u.updating.Unlock()
+
+	exe, err := osExecutable()
+	if err != nil {
+		return nil, err
+	}
+	exe, err = filepath.EvalSymlinks(exe)
+	if err != nil {
+		return nil, err
+	}
+
+	release, err := u.CheckContext(ctx)
+	if err != nil || release == nil {
+		return nil, err
+	}
+
+	err = u.selfUpdateReported(ctx, release, exe, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Restart != nil {
+		u.logf("Restarting %v", exe)
+		err = u.Restart.Restart(exe)
+	}
+	return release, err
+}
+
+// selfUpdateReported is like selfUpdate, and records the report and the
+// outcome of the update.
+func (u *Updater) selfUpdateReported(ctx context.Context, release Release, exe string, installed func() error) error {
+	rec := newReportRecorder(u, release)
+	err := u.selfUpdate(withReportRecorder(ctx, rec), release, exe, installed)
+	report := rec.finish(err)
+	u.setReport(report)
+	u.reportOutcome(ctx, OutcomeApply, report.StartedAt, release, report, err)
+	return err
+}
+
+// selfUpdate replaces the executable exe with the one of release.
+//
+// If installed is not nil, it is called once the executable was replaced, and
+// the release is only recorded as applied if it succeeds.
+func (u *Updater) selfUpdate(ctx context.Context, release Release, exe string, installed func() error) error {
+	unlock, err := u.lock(exe + ".lock")
+	if err != nil {
+		return u.reportError(err)
+	}
+	defer unlock()
+
+	asset := u.executableAsset(release)
+	if asset == nil {
+		return u.reportError(fmt.Errorf(
+			"No asset for %v/%v found in release %v.",
+			runtime.GOOS, runtime.GOARCH, release.Name(),
+		))
+	}
+
+	// Try to apply a patch first
+	if patch := u.patchAsset(release); patch != nil {
+		patched := &patchedAsset{Asset: asset, u: u, patch: patch, old: exe}
+		r := &patchedRelease{Release: release, asset: patched}
+		err := u.installExecutable(ctx, r, patched, exe)
+		if err == nil {
+			return u.installedRelease(release, installed)
+		} else if ctx.Err() != nil {
+			return u.reportError(err)
+		}
+		u.logf("Could not apply patch %v, downloading %v: %v", patch.Name(), asset.Name(), err)
+	}
+
+	err = u.installExecutable(ctx, release, asset, exe)
+	if err != nil {
+		return u.reportError(err)
+	}
+
+	return u.installedRelease(release, installed)
+}
+
+// installedRelease calls installed, if it is not nil, and records that
+// release was applied if it succeeds.
+func (u *Updater) installedRelease(release Release, installed func() error) error {
+	if installed != nil {
+		if err := installed(); err != nil {
+			return u.reportError(err)
+		}
+	}
+	u.appliedRelease(release)
+	return nil
+}
+
+// installExecutable writes asset of release to a file next to exe and
+// replaces exe with it.
+func (u *Updater) installExecutable(ctx context.Context, release Release, asset Asset, exe string) error {
+	f := NewExecutableFile(exe)
+	f.Elevator = u.Elevator
+	if f.Elevator == nil || dirWritable(filepath.Dir(exe)) {
+		f.buffer.Path = exe + ".new"
+	}
+
+	writers, err := u.writeAssets(
+		ctx, release,
+		func(a Asset) bool { return a == asset },
+		func(Asset) (AbortWriter, error) { return f, nil },
+	)
+	if err == nil {
+		err = u.validate(release, writers)
+	}
+	if err != nil {
+		f.Abort()
+		f.Close()
+		return err
+	}
+
+	u.logf("Replacing executable %v", exe)
+	if p, ok := release.(*patchedRelease); ok {
+		u.observer().OnApply(p.Release)
+	} else {
+		u.observer().OnApply(release)
+	}
+	return f.Close()
+}
+
+// NewExecutableFile creates a delayed file that replaces an executable, which
+// may be running.
+//
+// On Windows, a running executable cannot be overwritten. The executable is
+// renamed to a file with the .old extension first, which can be removed with
+// RemoveOldExecutable once it is no longer running.
+//
+// New executables are created with mode 0755.
+func NewExecutableFile(path string) *DelayedFile {
+	f := NewDelayedFile(path)
+	f.rename = replaceExecutable
+	f.defaultMode = 0755
+	return f
+}
+
+// RemoveOldExecutable removes the executable that was moved aside when the
+// running executable was replaced.
+//
+// Call it when the application starts, after an update the old executable is
+// no longer running. It is safe to call when there is nothing to remove.
+func RemoveOldExecutable() error {
+	exe, err := osExecutable()
+	if err != nil {
+		return err
+	}
+	exe, err = filepath.EvalSymlinks(exe)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(exe + ".old")
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// executableAsset returns the asset of release that contains the executable.
+func (u *Updater) executableAsset(release Release) Asset {
+	filter := u.AssetFilter
+	if filter == nil {
+		filter = DefaultPlatformFilter()
+	}
+
+	for _, a := range release.Assets() {
+		if a.Name() == u.ChecksumAssetName || u.isSignature(a) {
+			continue
+		}
+		if filter(a) {
+			return a
+		}
+	}
+	return nil
+}
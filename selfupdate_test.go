@@ -0,0 +1,171 @@
+package updater
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdaterSelfUpdate(t *testing.T) {
+	// Fake executable
+	f, err := ioutil.TempFile("", "testing-")
+	require.Nil(t, err)
+	exe := f.Name()
+	f.Write([]byte("old executable"))
+	f.Close()
+	defer os.Remove(exe)
+	err = os.Chmod(exe, 0755)
+	require.Nil(t, err)
+
+	defer func() { osExecutable = os.Executable }()
+	osExecutable = func() (string, error) { return exe, nil }
+
+	newAsset := func(name string) *testAsset {
+		return &testAsset{
+			name: name,
+			write: func(w io.Writer) error {
+				_, err := io.WriteString(w, "new executable for "+name)
+				return err
+			},
+		}
+	}
+	platform := runtime.GOOS + "_" + runtime.GOARCH
+
+	// Up to date
+	{
+		app := &testApp{
+			FLatestRelease: func() Release {
+				return &testRelease{identifier: "v1"}
+			},
+		}
+		u := &Updater{App: app, CurrentReleaseIdentifier: "v1"}
+
+		r, err := u.SelfUpdate()
+		assert.Nil(t, err)
+		assert.Nil(t, r)
+	}
+
+	// No asset for this platform
+	{
+		app := &testApp{
+			FLatestRelease: func() Release {
+				return &testRelease{
+					identifier: "v2",
+					assets:     []Asset{newAsset("myapp_plan10_z80")},
+				}
+			},
+		}
+		u := &Updater{App: app, CurrentReleaseIdentifier: "v1"}
+
+		r, err := u.SelfUpdate()
+		assert.Nil(t, r)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "No asset")
+	}
+
+	// Update
+	{
+		release := &testRelease{
+			identifier: "v2",
+			assets: []Asset{
+				newAsset("myapp_plan10_z80"),
+				newAsset("myapp_" + platform),
+			},
+		}
+		app := &testApp{
+			FLatestRelease: func() Release { return release },
+		}
+		u := &Updater{App: app, CurrentReleaseIdentifier: "v1"}
+
+		r, err := u.SelfUpdate()
+		assert.Nil(t, err, "Unexpected error: %v", err)
+		assert.Equal(t, release, r)
+
+		data, err := ioutil.ReadFile(exe)
+		assert.Nil(t, err)
+		assert.Equal(t, "new executable for myapp_"+platform, string(data))
+
+		info, err := os.Stat(exe)
+		assert.Nil(t, err)
+		if info != nil {
+			assert.EqualValues(t, 0755, info.Mode())
+		}
+
+		_, err = os.Stat(exe + ".new")
+		assert.True(t, os.IsNotExist(err))
+	}
+
+	// Validate by running the new executable
+	if runtime.GOOS != "windows" {
+		release := &testRelease{
+			identifier: "v3",
+			assets: []Asset{&testAsset{
+				name: "myapp_" + platform,
+				write: func(w io.Writer) error {
+					_, err := io.WriteString(w, "#!/bin/sh\necho v3\n")
+					return err
+				},
+			}},
+		}
+		var output string
+		u := &Updater{
+			App:                      &testApp{FLatestRelease: func() Release { return release }},
+			CurrentReleaseIdentifier: "v2",
+			Validate: func(r Release, paths []string) error {
+				require.Len(t, paths, 1)
+				b, err := exec.Command(paths[0]).Output()
+				output = string(b)
+				return err
+			},
+		}
+
+		r, err := u.SelfUpdate()
+		assert.Nil(t, err, "Unexpected error: %v", err)
+		assert.Equal(t, release, r)
+		assert.Equal(t, "v3\n", output)
+	}
+}
+
+func TestExecutableFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testing-")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	exe := filepath.Join(dir, "myapp")
+	err = ioutil.WriteFile(exe, []byte("old executable"), 0755)
+	require.Nil(t, err)
+
+	defer func() { osExecutable = os.Executable }()
+	osExecutable = func() (string, error) { return exe, nil }
+
+	// Replace executable
+	{
+		f := NewExecutableFile(exe)
+		f.Write([]byte("new executable"))
+		err := f.Close()
+		assert.Nil(t, err, "Could not close: %v", err)
+		assert.Equal(t, "new executable", readTestFile(t, exe))
+	}
+
+	// Remove old executable
+	{
+		err := ioutil.WriteFile(exe+".old", []byte("old executable"), 0755)
+		require.Nil(t, err)
+
+		err = RemoveOldExecutable()
+		assert.Nil(t, err)
+		_, err = os.Stat(exe + ".old")
+		assert.True(t, os.IsNotExist(err))
+
+		// Nothing to remove
+		err = RemoveOldExecutable()
+		assert.Nil(t, err)
+	}
+}
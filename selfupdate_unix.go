@@ -0,0 +1,11 @@
+//go:build !windows
+// +build !windows
+
+package updater
+
+import "os"
+
+// replaceExecutable moves the executable at src over dst.
+func replaceExecutable(src, dst string) error {
+	return os.Rename(src, dst)
+}
@@ -0,0 +1,29 @@
+package updater
+
+import "os"
+
+// replaceExecutable moves the executable at src over dst.
+//
+// Windows does not allow overwriting a running executable, but it does allow
+// renaming it. The executable at dst is moved aside to dst.old first, which
+// can be removed once the old executable is no longer running.
+func replaceExecutable(src, dst string) error {
+	old := dst + ".old"
+
+	// Remove a leftover from a previous update
+	os.Remove(old)
+
+	err := os.Rename(dst, old)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	err = os.Rename(src, dst)
+	if err != nil {
+		// Put the original executable back
+		os.Rename(old, dst)
+		return err
+	}
+
+	return nil
+}
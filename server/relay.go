@@ -0,0 +1,476 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	updater "github.com/hverr/go-updater"
+)
+
+// DefaultSyncInterval is the default time between two syncs of a Relay.
+const DefaultSyncInterval = 5 * time.Minute
+
+// Name of the index file of a relay.
+const relayIndexName = "index.json"
+
+// Prefix of the directories in which a relay downloads a release.
+const relayTempPrefix = ".tmp-"
+
+// Relay caches the releases of an upstream application, like a GitHub
+// repository, in a local directory, and is an application whose releases are
+// the cached ones. Use it as a channel of a Handler, so that a fleet of
+// clients never reaches the upstream:
+//
+//	relay := &server.Relay{
+//		Upstream: updater.NewGitHub("owner", "myapp", nil),
+//		Dir:      "/var/cache/myapp",
+//	}
+//	go relay.Run(ctx)
+//
+//	h := &server.Handler{
+//		Channels:        map[string]updater.App{"stable": relay},
+//		RefreshInterval: time.Minute,
+//	}
+//
+// Only Sync and Run reach the upstream. A new release is downloaded completely
+// before it is served, and the files of a release that is no longer kept are
+// removed one sync later, so that clients can finish their downloads. Keep the
+// RefreshInterval of the Handler below the Interval of the relay, so that it
+// does not serve removed releases. The directory is owned by the relay, and
+// can be shared with relays in other processes that only serve it.
+type Relay struct {
+	// Application whose releases are cached.
+	Upstream updater.App
+
+	// Directory in which the releases are stored.
+	Dir string
+
+	// Number of releases to keep: the latest release, and the most recent
+	// other releases if the upstream is an updater.ReleasesApp. Defaults to
+	// 1.
+	Keep int
+
+	// Time between two syncs of Run. Defaults to DefaultSyncInterval.
+	Interval time.Duration
+
+	// Logger for failed syncs and the releases that are downloaded,
+	// optional.
+	Logger updater.Logger
+
+	// syncMutex serializes syncs.
+	syncMutex sync.Mutex
+
+	// mutex guards latest and releases, the releases of the last query.
+	mutex    sync.Mutex
+	latest   updater.Release
+	releases []updater.Release
+}
+
+// relayIndex lists the cached releases, in the order of the upstream.
+type relayIndex struct {
+	// Identifier of the latest release.
+	Latest string `json:"latest"`
+
+	Releases []relayEntry `json:"releases"`
+}
+
+// relayEntry is a cached release, whose assets are stored in Dir.
+type relayEntry struct {
+	Dir      string           `json:"dir"`
+	Manifest updater.Manifest `json:"manifest"`
+}
+
+type relayRelease struct {
+	manifest updater.Manifest
+	assets   []updater.Asset
+}
+
+type relayAsset struct {
+	name   string
+	path   string
+	size   int64
+	sha256 string
+}
+
+// Run syncs the relay every Interval, until ctx is done. Failed syncs are
+// logged and retried at the next interval.
+func (r *Relay) Run(ctx context.Context) error {
+	interval := r.Interval
+	if interval <= 0 {
+		interval = DefaultSyncInterval
+	}
+
+	for {
+		err := r.Sync(ctx)
+		if err != nil && ctx.Err() == nil {
+			r.logf("Could not sync releases to %v: %v", r.Dir, err)
+		}
+
+		t := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// Sync queries the upstream, and downloads the releases to keep that are not
+// cached yet. Cached releases are identified by their identifier, so a new
+// release is detected even if it has the name of a cached one.
+func (r *Relay) Sync(ctx context.Context) error {
+	r.syncMutex.Lock()
+	defer r.syncMutex.Unlock()
+
+	var err error
+	if a, ok := r.Upstream.(updater.ContextApp); ok {
+		err = a.QueryContext(ctx)
+	} else {
+		err = r.Upstream.Query()
+	}
+	if err != nil {
+		return err
+	}
+
+	latest := r.Upstream.LatestRelease()
+	if latest == nil {
+		return errors.New("The upstream has no latest release.")
+	}
+
+	old, err := r.readIndex()
+	if os.IsNotExist(err) {
+		old = &relayIndex{}
+	} else if err != nil {
+		return err
+	}
+	cached := make(map[string]relayEntry)
+	for _, e := range old.Releases {
+		cached[e.Manifest.Identifier] = e
+	}
+
+	index := &relayIndex{Latest: latest.Identifier()}
+	for _, release := range r.keep(latest) {
+		e, ok := cached[release.Identifier()]
+		if ok {
+			// The notes and rollout of a release can change
+			e.Manifest.Notes = release.Information()
+			e.Manifest.Mandatory = updater.IsMandatory(release)
+			e.Manifest.Rollout = rollout(release)
+		} else {
+			r.logf("Downloading release %v to %v", release.Name(), r.Dir)
+			e, err = r.download(ctx, release)
+			if err != nil {
+				return fmt.Errorf("Could not download release %v: %v", release.Name(), err)
+			}
+		}
+		index.Releases = append(index.Releases, e)
+	}
+
+	err = r.writeIndex(index)
+	if err != nil {
+		return err
+	}
+
+	r.clean(old, index)
+	return nil
+}
+
+// keep returns the upstream releases to keep, in the order of the upstream.
+func (r *Relay) keep(latest updater.Release) []updater.Release {
+	n := r.Keep
+	if n < 1 {
+		n = 1
+	}
+	a, ok := r.Upstream.(updater.ReleasesApp)
+	if !ok || n == 1 {
+		return []updater.Release{latest}
+	}
+
+	// The latest release is always kept, next to the n-1 first others
+	var releases []updater.Release
+	found, others := false, 0
+	for _, release := range a.Releases() {
+		if release.Identifier() == latest.Identifier() {
+			found = true
+		} else if others < n-1 {
+			others++
+		} else {
+			continue
+		}
+		releases = append(releases, release)
+	}
+	if !found {
+		releases = append([]updater.Release{latest}, releases...)
+	}
+	return releases
+}
+
+// download downloads the assets of release to a new directory.
+func (r *Relay) download(ctx context.Context, release updater.Release) (relayEntry, error) {
+	sum := sha256.Sum256([]byte(release.Identifier()))
+	e := relayEntry{
+		Dir: hex.EncodeToString(sum[:16]),
+		Manifest: updater.Manifest{
+			Version:    release.Name(),
+			Notes:      release.Information(),
+			Identifier: release.Identifier(),
+			Mandatory:  updater.IsMandatory(release),
+			Rollout:    rollout(release),
+			Assets:     []updater.ManifestAsset{},
+		},
+	}
+
+	err := os.MkdirAll(r.Dir, 0755)
+	if err != nil {
+		return e, err
+	}
+	tmp, err := ioutil.TempDir(r.Dir, relayTempPrefix)
+	if err != nil {
+		return e, err
+	}
+	defer os.RemoveAll(tmp)
+	err = os.Chmod(tmp, 0755)
+	if err != nil {
+		return e, err
+	}
+
+	for _, a := range release.Assets() {
+		name := a.Name()
+		if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+			r.logf("Skipping asset %q of release %v", name, release.Name())
+			continue
+		}
+
+		h := sha256.New()
+		err := writeAsset(ctx, a, filepath.Join(tmp, name), h)
+		if err != nil {
+			return e, fmt.Errorf("Could not download %v: %v", name, err)
+		}
+		e.Manifest.Assets = append(e.Manifest.Assets, updater.ManifestAsset{
+			Name:   name,
+			SHA256: hex.EncodeToString(h.Sum(nil)),
+		})
+	}
+
+	// Replace the leftovers of a release that was removed from the index
+	dir := filepath.Join(r.Dir, e.Dir)
+	err = os.RemoveAll(dir)
+	if err != nil {
+		return e, err
+	}
+	return e, os.Rename(tmp, dir)
+}
+
+// writeAsset writes a to a new file at path, and to h.
+func writeAsset(ctx context.Context, a updater.Asset, path string, h io.Writer) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+
+	w := io.MultiWriter(f, h)
+	if c, ok := a.(updater.ContextAsset); ok {
+		err = c.WriteContext(ctx, w)
+	} else {
+		err = a.Write(w)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// clean removes the directories of releases that are in neither index, and
+// the leftovers of failed downloads.
+func (r *Relay) clean(old, index *relayIndex) {
+	used := make(map[string]bool)
+	for _, e := range append(old.Releases, index.Releases...) {
+		used[e.Dir] = true
+	}
+
+	entries, err := ioutil.ReadDir(r.Dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if !e.IsDir() || used[name] {
+			continue
+		}
+		if _, err := hex.DecodeString(name); (err == nil && len(name) == 32) || strings.HasPrefix(name, relayTempPrefix) {
+			os.RemoveAll(filepath.Join(r.Dir, name))
+		}
+	}
+}
+
+// readIndex reads the index of the cached releases.
+func (r *Relay) readIndex() (*relayIndex, error) {
+	b, err := ioutil.ReadFile(filepath.Join(r.Dir, relayIndexName))
+	if err != nil {
+		return nil, err
+	}
+
+	index := &relayIndex{}
+	err = json.Unmarshal(b, index)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid relay index: %v", err)
+	}
+	return index, nil
+}
+
+// writeIndex replaces the index of the cached releases.
+func (r *Relay) writeIndex(index *relayIndex) error {
+	b, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(r.Dir, relayIndexName+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(b)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), filepath.Join(r.Dir, relayIndexName))
+}
+
+func (r *Relay) Query() error {
+	return r.QueryContext(context.Background())
+}
+
+// QueryContext reads the cached releases. It does not reach the upstream.
+func (r *Relay) QueryContext(ctx context.Context) error {
+	index, err := r.readIndex()
+	if os.IsNotExist(err) {
+		return errors.New("No releases synced yet.")
+	} else if err != nil {
+		return err
+	}
+
+	var latest updater.Release
+	releases := make([]updater.Release, len(index.Releases))
+	for i, e := range index.Releases {
+		release := &relayRelease{manifest: e.Manifest}
+		for _, a := range e.Manifest.Assets {
+			path := filepath.Join(r.Dir, e.Dir, a.Name)
+			fi, err := os.Stat(path)
+			if err != nil {
+				return err
+			}
+			release.assets = append(release.assets, &relayAsset{
+				name:   a.Name,
+				path:   path,
+				size:   fi.Size(),
+				sha256: a.SHA256,
+			})
+		}
+
+		releases[i] = release
+		if e.Manifest.Identifier == index.Latest {
+			latest = release
+		}
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.latest = latest
+	r.releases = releases
+	return nil
+}
+
+func (r *Relay) LatestRelease() updater.Release {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.latest
+}
+
+func (r *Relay) Releases() []updater.Release {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.releases
+}
+
+func (r *Relay) logf(format string, v ...interface{}) {
+	if r.Logger != nil {
+		r.Logger.Printf(format, v...)
+	}
+}
+
+func (r *relayRelease) Name() string {
+	return r.manifest.Version
+}
+
+func (r *relayRelease) Information() string {
+	return r.manifest.Notes
+}
+
+func (r *relayRelease) Identifier() string {
+	return r.manifest.Identifier
+}
+
+func (r *relayRelease) Assets() []updater.Asset {
+	return r.assets
+}
+
+func (r *relayRelease) Mandatory() bool {
+	return r.manifest.Mandatory
+}
+
+func (r *relayRelease) RolloutPercentage() int {
+	if r.manifest.Rollout == nil {
+		return 100
+	}
+	return *r.manifest.Rollout
+}
+
+func (r *relayAsset) Name() string {
+	return r.name
+}
+
+// Write copies the cached file of the asset to w.
+func (r *relayAsset) Write(w io.Writer) error {
+	f, err := os.Open(r.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+	return err
+}
+
+func (r *relayAsset) SHA256() string {
+	return r.sha256
+}
+
+func (r *relayAsset) Size() int64 {
+	return r.size
+}
+
+func (r *relayAsset) ContentType() string {
+	return ""
+}
+
+func (r *relayAsset) DownloadCount() int {
+	return -1
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	updater "github.com/hverr/go-updater"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testReleasesApp is an upstream with several releases, the first being the
+// latest.
+type testReleasesApp struct {
+	releases []updater.Release
+	err      error
+}
+
+func (app *testReleasesApp) Query() error {
+	return app.err
+}
+
+func (app *testReleasesApp) LatestRelease() updater.Release {
+	if len(app.releases) == 0 {
+		return nil
+	}
+	return app.releases[0]
+}
+
+func (app *testReleasesApp) Releases() []updater.Release {
+	return app.releases
+}
+
+func newTestRelease(name string, assets map[string]string) *testRelease {
+	r := &testRelease{name: name}
+	for n, contents := range assets {
+		contents := contents
+		r.assets = append(r.assets, &testAsset{
+			name: n,
+			write: func(w io.Writer) error {
+				_, err := io.WriteString(w, contents)
+				return err
+			},
+		})
+	}
+	return r
+}
+
+func TestRelay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "relay-")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	upstream := &testReleasesApp{releases: []updater.Release{
+		newTestRelease("v1.1.0", map[string]string{"myapp_linux_amd64": "Hello World!"}),
+		newTestRelease("v1.0.0", map[string]string{"myapp_linux_amd64": "Old"}),
+		newTestRelease("v0.9.0", map[string]string{"myapp_linux_amd64": "Older"}),
+	}}
+	relay := &Relay{Upstream: upstream, Dir: dir, Keep: 2}
+	dirs := func() int {
+		entries, err := ioutil.ReadDir(dir)
+		require.Nil(t, err)
+		n := 0
+		for _, e := range entries {
+			if e.IsDir() {
+				n++
+			}
+		}
+		return n
+	}
+
+	// Nothing synced yet
+	{
+		assert.NotNil(t, relay.Query())
+	}
+
+	// Latest releases cached
+	{
+		err := relay.Sync(context.Background())
+		require.Nil(t, err, "Could not sync: %v", err)
+		assert.Equal(t, 2, dirs())
+
+		require.Nil(t, relay.Query())
+		var names []string
+		for _, r := range relay.Releases() {
+			names = append(names, r.Name())
+		}
+		assert.Equal(t, []string{"v1.1.0", "v1.0.0"}, names)
+		assert.Equal(t, "v1.1.0", relay.LatestRelease().Name())
+	}
+
+	// Served with checksums without reaching the upstream
+	{
+		upstream.err = errors.New("Rate limited.")
+		ts := httptest.NewServer(&Handler{Channels: map[string]updater.App{"stable": relay}})
+		defer ts.Close()
+
+		resp, err := http.Get(ts.URL + "/manifest.json")
+		require.Nil(t, err)
+		defer resp.Body.Close()
+		var m updater.Manifest
+		require.Nil(t, json.NewDecoder(resp.Body).Decode(&m))
+		require.Equal(t, 1, len(m.Assets))
+		assert.Equal(t, "7f83b1657ff1fc53b92dc18148a1d65dfc2d4b1fa3d677284addd200126d9069", m.Assets[0].SHA256)
+
+		b := updater.NewAbortBuffer(nil)
+		u := &updater.Updater{
+			App:            updater.NewHTTPManifest(ts.URL+"/manifest.json", nil),
+			WriterForAsset: func(updater.Asset) (updater.AbortWriter, error) { return b, nil },
+		}
+		err = u.UpdateTo(nil)
+		assert.Nil(t, err, "Could not update: %v", err)
+		assert.Equal(t, "Hello World!", b.Buffer.String())
+	}
+
+	// Failing upstream keeps the cache
+	{
+		assert.NotNil(t, relay.Sync(context.Background()))
+		require.Nil(t, relay.Query())
+		assert.Equal(t, "v1.1.0", relay.LatestRelease().Name())
+		upstream.err = nil
+	}
+
+	// New release, with old files removed one sync later
+	{
+		upstream.releases = append([]updater.Release{
+			newTestRelease("v1.2.0", map[string]string{"myapp_linux_amd64": "New", "../evil": "Evil"}),
+		}, upstream.releases...)
+		require.Nil(t, relay.Sync(context.Background()))
+		assert.Equal(t, 3, dirs())
+
+		require.Nil(t, relay.Query())
+		assert.Equal(t, "v1.2.0", relay.LatestRelease().Name())
+		require.Equal(t, 1, len(relay.LatestRelease().Assets()))
+		assert.Equal(t, int64(3), relay.LatestRelease().Assets()[0].(updater.AssetMeta).Size())
+
+		require.Nil(t, relay.Sync(context.Background()))
+		assert.Equal(t, 2, dirs())
+	}
+
+	// Failed download
+	{
+		upstream.releases = append([]updater.Release{&testRelease{
+			name: "v1.3.0",
+			assets: []updater.Asset{&testAsset{
+				name:  "myapp_linux_amd64",
+				write: func(io.Writer) error { return errors.New("Broken.") },
+			}},
+		}}, upstream.releases...)
+		assert.NotNil(t, relay.Sync(context.Background()))
+		assert.Equal(t, 2, dirs())
+
+		require.Nil(t, relay.Query())
+		assert.Equal(t, "v1.2.0", relay.LatestRelease().Name())
+		_, err := os.Stat(filepath.Join(dir, relayIndexName))
+		assert.Nil(t, err)
+	}
+}
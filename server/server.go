@@ -0,0 +1,429 @@
+// Package server serves the manifests that the updater reads with
+// NewHTTPManifest, so that teams can host their own update endpoint.
+//
+// The releases of every channel come from an application, e.g. a directory
+// of releases or a GitHub repository whose assets are proxied:
+//
+//	h := &server.Handler{
+//		Channels: map[string]updater.App{
+//			"stable": updater.NewFileSystem("/srv/myapp"),
+//			"beta":   updater.NewGitHub("owner", "myapp", nil),
+//		},
+//	}
+//	err := http.ListenAndServe(":8080", h)
+//
+// Clients read the manifest of a channel, with the assets for their platform:
+//
+//	app := updater.NewHTTPManifest(
+//		"https://updates.example.com/stable/manifest.json?os=linux&arch=amd64",
+//		nil,
+//	)
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	updater "github.com/hverr/go-updater"
+)
+
+// DefaultChannel is the channel of the manifest at /manifest.json.
+const DefaultChannel = "stable"
+
+// DefaultRefreshInterval is the default time after which the releases of a
+// channel are queried again.
+const DefaultRefreshInterval = 5 * time.Minute
+
+// Name of the manifest file of a channel.
+const manifestName = "manifest.json"
+
+// Name of the endpoint to which clients post outcomes.
+const reportName = "report"
+
+// Maximum size of a posted outcome.
+const maxReportSize = 64 << 10
+
+// Known operating systems in asset names, see PlatformFilter of the updater.
+var knownOS = []string{
+	"linux", "darwin", "macos", "windows", "freebsd", "openbsd", "netbsd",
+	"dragonfly", "solaris", "illumos", "aix", "android", "ios",
+}
+
+// Handler serves the manifests of the latest releases of its channels, and
+// the assets of the releases.
+//
+// The manifest of a channel is served at /<channel>/manifest.json, and the
+// one of the DefaultChannel at /manifest.json as well. The manifest lists the
+// assets for the platform given by the os and arch query parameters, the
+// GOOS and GOARCH of the client, like updater.PlatformFilter, and the assets
+// that are not built for a specific platform, like a checksum file. All
+// assets are listed without these parameters.
+//
+// The assets are served by the handler at /<channel>/releases/<version>/<name>,
+// and the ones of the DefaultChannel at /releases/<version>/<name> as well.
+// Their URLs in the manifest are relative to the manifest. Manifests have
+// an ETag, so that repeated queries of NewHTTPManifest are cheap.
+//
+// Clients can post the outcomes of their checks and updates to the handler,
+// see OnReport.
+type Handler struct {
+	// Applications that provide the releases of every channel, by name.
+	Channels map[string]updater.App
+
+	// Time after which the releases of a channel are queried again. Defaults
+	// to DefaultRefreshInterval. Applications are queried when they are
+	// first needed, and the previous releases are served if a query fails,
+	// until the application is queried again after the next interval.
+	RefreshInterval time.Duration
+
+	// Logger for failed queries and downloads, optional.
+	Logger updater.Logger
+
+	// Function called with the outcomes that clients post to
+	// /<channel>/report, or /report for the DefaultChannel, with
+	// updater.NewHTTPReporter, e.g. to count the updates and failures of
+	// every release. Posted outcomes are refused when it is nil.
+	OnReport func(channel string, outcome *updater.Outcome)
+
+	mutex    sync.Mutex
+	channels map[string]*channel
+}
+
+// channel is the state of an application of a channel. The releases of the
+// last successful query are kept, so that they can be served while the
+// application is queried again. queried is the time of the last query, also
+// if it failed.
+type channel struct {
+	mutex    sync.Mutex
+	app      updater.App
+	queried  time.Time
+	latest   updater.Release
+	releases []updater.Release
+}
+
+// ServeHTTP serves a manifest or an asset.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var path []string
+	for _, s := range strings.Split(strings.Trim(r.URL.EscapedPath(), "/"), "/") {
+		p, err := url.PathUnescape(s)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		path = append(path, p)
+	}
+
+	// Outcomes are posted, everything else is read
+	report := path[len(path)-1] == reportName && len(path) <= 2 && h.OnReport != nil
+	if report && r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "Method not allowed.", http.StatusMethodNotAllowed)
+		return
+	} else if !report && r.Method != "GET" && r.Method != "HEAD" {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method not allowed.", http.StatusMethodNotAllowed)
+		return
+	}
+
+	switch {
+	case report && len(path) == 1:
+		h.serveReport(w, r, DefaultChannel)
+	case report:
+		h.serveReport(w, r, path[0])
+	case len(path) == 1 && path[0] == manifestName:
+		h.serveManifest(w, r, DefaultChannel)
+	case len(path) == 2 && path[1] == manifestName:
+		h.serveManifest(w, r, path[0])
+	case len(path) == 3 && path[0] == "releases":
+		h.serveAsset(w, r, DefaultChannel, path[1], path[2])
+	case len(path) == 4 && path[1] == "releases":
+		h.serveAsset(w, r, path[0], path[2], path[3])
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serveManifest serves the manifest of the latest release of a channel.
+func (h *Handler) serveManifest(w http.ResponseWriter, r *http.Request, name string) {
+	release, _, err := h.releases(r.Context(), name)
+	if err != nil {
+		h.fail(w, r, err)
+		return
+	}
+	if release == nil {
+		http.Error(w, "No release available.", http.StatusNotFound)
+		return
+	}
+
+	q := r.URL.Query()
+	b, err := json.Marshal(NewManifest(release, q.Get("os"), q.Get("arch")))
+	if err != nil {
+		h.fail(w, r, err)
+		return
+	}
+
+	sum := sha256.Sum256(b)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(b))
+}
+
+// serveAsset serves an asset of a release of a channel.
+func (h *Handler) serveAsset(w http.ResponseWriter, r *http.Request, name, version, asset string) {
+	_, releases, err := h.releases(r.Context(), name)
+	if err != nil {
+		h.fail(w, r, err)
+		return
+	}
+
+	a := findAsset(releases, version, asset)
+	if a == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if m, ok := a.(updater.AssetMeta); ok {
+		if m.ContentType() != "" {
+			w.Header().Set("Content-Type", m.ContentType())
+		}
+		if m.Size() > 0 {
+			w.Header().Set("Content-Length", strconv.FormatInt(m.Size(), 10))
+		}
+	}
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/octet-stream")
+	}
+	if r.Method == "HEAD" {
+		return
+	}
+
+	// Errors can only be reported before the asset is written, later the
+	// response is aborted
+	cw := &countingWriter{w: w}
+	if c, ok := a.(updater.ContextAsset); ok {
+		err = c.WriteContext(r.Context(), cw)
+	} else {
+		err = a.Write(cw)
+	}
+	if err != nil && cw.n == 0 {
+		w.Header().Del("Content-Length")
+		h.fail(w, r, err)
+	} else if err != nil {
+		h.logf("Could not serve %v: %v", r.URL.Path, err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// serveReport passes an outcome posted by a client to OnReport.
+func (h *Handler) serveReport(w http.ResponseWriter, r *http.Request, name string) {
+	if _, ok := h.Channels[name]; !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	var o updater.Outcome
+	err := json.NewDecoder(io.LimitReader(r.Body, maxReportSize)).Decode(&o)
+	if err != nil {
+		http.Error(w, "Invalid outcome.", http.StatusBadRequest)
+		return
+	}
+
+	h.OnReport(name, &o)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// releases returns the latest and all releases of a channel, after querying
+// its application if needed.
+func (h *Handler) releases(ctx context.Context, name string) (updater.Release, []updater.Release, error) {
+	c := h.channel(name)
+	if c == nil {
+		return nil, nil, errNotFound
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	interval := h.RefreshInterval
+	if interval <= 0 {
+		interval = DefaultRefreshInterval
+	}
+	if time.Since(c.queried) < interval {
+		return c.latest, c.releases, nil
+	}
+
+	var err error
+	if a, ok := c.app.(updater.ContextApp); ok {
+		err = a.QueryContext(ctx)
+	} else {
+		err = c.app.Query()
+	}
+	if err != nil && c.queried.IsZero() {
+		return nil, nil, err
+	} else if err != nil {
+		// Try again after the next interval
+		h.logf("Could not query channel %v, serving previous releases: %v", name, err)
+		c.queried = time.Now()
+		return c.latest, c.releases, nil
+	}
+
+	c.queried = time.Now()
+	c.latest = c.app.LatestRelease()
+	c.releases = []updater.Release{c.latest}
+	if a, ok := c.app.(updater.ReleasesApp); ok {
+		c.releases = a.Releases()
+	}
+	return c.latest, c.releases, nil
+}
+
+// channel returns the state of a channel, or nil if it does not exist.
+func (h *Handler) channel(name string) *channel {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if c, ok := h.channels[name]; ok {
+		return c
+	}
+	app, ok := h.Channels[name]
+	if !ok || app == nil {
+		return nil
+	}
+
+	if h.channels == nil {
+		h.channels = make(map[string]*channel)
+	}
+	c := &channel{app: app}
+	h.channels[name] = c
+	return c
+}
+
+// errNotFound is returned for unknown channels.
+var errNotFound = errors.New("Channel not found.")
+
+// fail reports err to the client.
+func (h *Handler) fail(w http.ResponseWriter, r *http.Request, err error) {
+	if err == errNotFound {
+		http.NotFound(w, r)
+		return
+	}
+
+	h.logf("Could not serve %v: %v", r.URL.Path, err)
+	http.Error(w, "Could not fetch releases.", http.StatusBadGateway)
+}
+
+func (h *Handler) logf(format string, v ...interface{}) {
+	if h.Logger != nil {
+		h.Logger.Printf(format, v...)
+	}
+}
+
+// NewManifest returns the manifest of release, with the assets for the given
+// platform, see Handler. All assets are included if goos and goarch are
+// empty.
+//
+// The asset URLs are relative to the manifest, as served by Handler. The
+// checksums of assets that implement updater.ChecksumAsset, like the assets of
+// a Relay, and the rollout percentage of the release are included.
+func NewManifest(release updater.Release, goos, goarch string) updater.Manifest {
+	m := updater.Manifest{
+		Version:    release.Name(),
+		Notes:      release.Information(),
+		Identifier: release.Identifier(),
+		Mandatory:  updater.IsMandatory(release),
+		Rollout:    rollout(release),
+		Assets:     []updater.ManifestAsset{},
+	}
+
+	filter := PlatformFilter(goos, goarch)
+	for _, a := range release.Assets() {
+		if !filter(a) {
+			continue
+		}
+		ma := updater.ManifestAsset{
+			Name: a.Name(),
+			URL:  "releases/" + url.PathEscape(release.Name()) + "/" + url.PathEscape(a.Name()),
+		}
+		if c, ok := a.(updater.ChecksumAsset); ok {
+			ma.SHA256 = c.SHA256()
+		}
+		m.Assets = append(m.Assets, ma)
+	}
+	return m
+}
+
+// rollout returns the rollout percentage of release for a manifest, or nil if
+// it is rolled out to all clients.
+func rollout(release updater.Release) *int {
+	if p := updater.RolloutPercentage(release); p < 100 {
+		return &p
+	}
+	return nil
+}
+
+// PlatformFilter returns an asset filter that selects the assets for the given
+// platform, and the assets that are not built for a specific platform. All
+// assets are selected if goos and goarch are empty.
+func PlatformFilter(goos, goarch string) func(updater.Asset) bool {
+	if goos == "" && goarch == "" {
+		return func(updater.Asset) bool { return true }
+	}
+
+	platform := updater.PlatformFilter(goos, goarch)
+	return func(a updater.Asset) bool {
+		return platform(a) || !hasPlatform(a.Name())
+	}
+}
+
+// hasPlatform reports whether an asset name contains an operating system as a
+// separate word.
+func hasPlatform(name string) bool {
+	words := strings.FieldsFunc(strings.ToLower(name), func(c rune) bool {
+		return c == '_' || c == '-' || c == '.' || c == ' '
+	})
+	for _, w := range words {
+		for _, s := range knownOS {
+			if w == s {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// findAsset returns the asset with the given name of the release with the
+// given version, or nil if there is none.
+func findAsset(releases []updater.Release, version, name string) updater.Asset {
+	for _, r := range releases {
+		if r == nil || r.Name() != version {
+			continue
+		}
+		for _, a := range r.Assets() {
+			if a.Name() == name {
+				return a
+			}
+		}
+	}
+	return nil
+}
+
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,283 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	updater "github.com/hverr/go-updater"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testApp is an application whose queries can fail.
+type testApp struct {
+	latest  updater.Release
+	queries int
+	err     error
+}
+
+func (app *testApp) Query() error {
+	app.queries++
+	return app.err
+}
+
+func (app *testApp) LatestRelease() updater.Release {
+	return app.latest
+}
+
+type testRelease struct {
+	name   string
+	assets []updater.Asset
+}
+
+func (r *testRelease) Name() string            { return r.name }
+func (r *testRelease) Information() string     { return "" }
+func (r *testRelease) Identifier() string      { return r.name }
+func (r *testRelease) Assets() []updater.Asset { return r.assets }
+
+type testAsset struct {
+	name  string
+	write func(w io.Writer) error
+}
+
+func (a *testAsset) Name() string            { return a.name }
+func (a *testAsset) Write(w io.Writer) error { return a.write(w) }
+
+func TestHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "server-")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	write := func(path, contents string) {
+		path = filepath.Join(dir, "releases", filepath.FromSlash(path))
+		require.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.Nil(t, ioutil.WriteFile(path, []byte(contents), 0644))
+	}
+	write("v1.0.0/myapp_linux_amd64", "Old")
+	write("v1.1.0/myapp_linux_amd64", "Hello Linux!")
+	write("v1.1.0/myapp_windows_amd64.exe", "Hello Windows!")
+	write("v1.1.0/SHA256SUMS", "2b1b1f3b1a9a8d4b0a5d0a9b1d9d0e4e0e1e2c6e5fe0c2e0a1c3a5e5f0b7e7c1  myapp_linux_amd64\n")
+	write("v1.1.0/manifest.json", `{"notes": "Bug fixes.", "mandatory": true}`)
+
+	beta := &testApp{latest: &testRelease{
+		name: "v1.2.0-beta.1",
+		assets: []updater.Asset{&testAsset{
+			name: "myapp_linux_amd64",
+			write: func(w io.Writer) error {
+				return errors.New("Broken.")
+			},
+		}},
+	}}
+	h := &Handler{
+		Channels: map[string]updater.App{
+			"stable": updater.NewFileSystem(dir),
+			"beta":   beta,
+		},
+	}
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+
+	// Manifest for a platform
+	{
+		resp, err := http.Get(ts.URL + "/stable/manifest.json?os=linux&arch=amd64")
+		require.Nil(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+		var m updater.Manifest
+		require.Nil(t, json.NewDecoder(resp.Body).Decode(&m))
+		assert.Equal(t, "v1.1.0", m.Version)
+		assert.Equal(t, "Bug fixes.", m.Notes)
+		assert.True(t, m.Mandatory)
+		assert.Equal(t, []updater.ManifestAsset{
+			{Name: "SHA256SUMS", URL: "releases/v1.1.0/SHA256SUMS"},
+			{Name: "myapp_linux_amd64", URL: "releases/v1.1.0/myapp_linux_amd64"},
+		}, m.Assets)
+
+		// Unchanged manifest
+		req, _ := http.NewRequest("GET", ts.URL+"/stable/manifest.json?os=linux&arch=amd64", nil)
+		req.Header.Set("If-None-Match", resp.Header.Get("ETag"))
+		resp2, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		resp2.Body.Close()
+		assert.Equal(t, 304, resp2.StatusCode)
+	}
+
+	// Default channel with all assets
+	{
+		resp, err := http.Get(ts.URL + "/manifest.json")
+		require.Nil(t, err)
+		defer resp.Body.Close()
+
+		var m updater.Manifest
+		require.Nil(t, json.NewDecoder(resp.Body).Decode(&m))
+		assert.Equal(t, 3, len(m.Assets))
+	}
+
+	// Update with the client
+	{
+		b := updater.NewAbortBuffer(nil)
+		u := &updater.Updater{
+			App:                      updater.NewHTTPManifest(ts.URL+"/stable/manifest.json?os=linux&arch=amd64", nil),
+			CurrentReleaseIdentifier: "v1.0.0",
+			AssetFilter:              updater.PlatformFilter("linux", "amd64"),
+			WriterForAsset:           func(updater.Asset) (updater.AbortWriter, error) { return b, nil },
+		}
+		err := u.UpdateTo(nil)
+		assert.Nil(t, err, "Could not update: %v", err)
+		assert.Equal(t, "Hello Linux!", b.Buffer.String())
+	}
+
+	// Asset of an older release
+	{
+		resp, err := http.Get(ts.URL + "/stable/releases/v1.0.0/myapp_linux_amd64")
+		require.Nil(t, err)
+		defer resp.Body.Close()
+		b, _ := ioutil.ReadAll(resp.Body)
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.Equal(t, "3", resp.Header.Get("Content-Length"))
+		assert.Equal(t, "Old", string(b))
+	}
+
+	// Missing channels, releases and assets
+	{
+		for _, p := range []string{
+			"/nightly/manifest.json",
+			"/stable/releases/v0.9.0/myapp_linux_amd64",
+			"/stable/releases/v1.1.0/myapp_darwin_arm64",
+			"/stable/other",
+		} {
+			resp, err := http.Get(ts.URL + p)
+			require.Nil(t, err)
+			resp.Body.Close()
+			assert.Equal(t, 404, resp.StatusCode, "Status of %v", p)
+		}
+	}
+
+	// Broken asset
+	{
+		resp, err := http.Get(ts.URL + "/beta/releases/v1.2.0-beta.1/myapp_linux_amd64")
+		require.Nil(t, err)
+		resp.Body.Close()
+		assert.Equal(t, 502, resp.StatusCode)
+	}
+
+	// Previous releases served when a query fails
+	{
+		assert.Equal(t, 1, beta.queries)
+		h.RefreshInterval = 1
+		beta.err = errors.New("Unavailable.")
+
+		resp, err := http.Get(ts.URL + "/beta/manifest.json")
+		require.Nil(t, err)
+		resp.Body.Close()
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.Equal(t, 2, beta.queries)
+	}
+
+	// Failed query not repeated before the next interval
+	{
+		h.RefreshInterval = time.Hour
+		h.channel("beta").queried = time.Now().Add(-2 * time.Hour)
+
+		for i := 0; i < 2; i++ {
+			resp, err := http.Get(ts.URL + "/beta/manifest.json")
+			require.Nil(t, err)
+			resp.Body.Close()
+			assert.Equal(t, 200, resp.StatusCode)
+			assert.Equal(t, 3, beta.queries)
+		}
+	}
+
+	// Failing first query
+	{
+		h := &Handler{Channels: map[string]updater.App{"stable": &testApp{err: errors.New("Unavailable.")}}}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/manifest.json", nil))
+		assert.Equal(t, 502, w.Code)
+
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("POST", "/manifest.json", bytes.NewReader(nil)))
+		assert.Equal(t, 405, w.Code)
+	}
+}
+
+func TestHandlerReport(t *testing.T) {
+	var reports []string
+	h := &Handler{
+		Channels: map[string]updater.App{"stable": &testApp{}, "beta": &testApp{}},
+		OnReport: func(channel string, o *updater.Outcome) {
+			reports = append(reports, channel+" "+o.Release)
+		},
+	}
+	post := func(path, body string) int {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("POST", path, strings.NewReader(body)))
+		return w.Code
+	}
+
+	// Posted outcomes
+	{
+		reporter := updater.NewHTTPReporter("http://updates.example.com/beta/report", &http.Client{
+			Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, r)
+				return w.Result(), nil
+			}),
+		})
+		err := reporter.Report(context.Background(), &updater.Outcome{Release: "v1.1.0"})
+		assert.Nil(t, err, "Could not report: %v", err)
+		assert.Equal(t, 204, post("/report", `{"release": "v1.0.0"}`))
+		assert.Equal(t, []string{"beta v1.1.0", "stable v1.0.0"}, reports)
+	}
+
+	// Invalid outcomes
+	{
+		assert.Equal(t, 400, post("/report", `invalid`))
+		assert.Equal(t, 404, post("/nightly/report", `{}`))
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/report", nil))
+		assert.Equal(t, 405, w.Code)
+	}
+
+	// Refused without OnReport
+	{
+		h.OnReport = nil
+		assert.Equal(t, 405, post("/report", `{}`))
+	}
+}
+
+type roundTripFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestPlatformFilter(t *testing.T) {
+	filter := PlatformFilter("darwin", "arm64")
+	for name, expected := range map[string]bool{
+		"myapp_darwin_arm64.tar.gz":     true,
+		"myapp_darwin_arm64.tar.gz.sig": true,
+		"myapp_linux_arm64.tar.gz":      false,
+		"myapp_windows_amd64.zip":       false,
+		"SHA256SUMS":                    true,
+		"README.md":                     true,
+	} {
+		assert.Equal(t, expected, filter(&testAsset{name: name}), "Asset %v", name)
+	}
+
+	assert.True(t, PlatformFilter("", "")(&testAsset{name: "myapp_linux_amd64"}))
+}
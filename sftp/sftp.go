@@ -0,0 +1,61 @@
+// Package sftp fetches the releases of an application from an SFTP server,
+// with the layout of updater.NewFileSystem:
+//
+//	conn, err := ssh.Dial("tcp", "files.example.com:22", config)
+//	if err != nil {
+//		return err
+//	}
+//	client, err := sftp.NewClient(conn)
+//	if err != nil {
+//		return err
+//	}
+//	defer client.Close()
+//	u := &updater.Updater{App: updatersftp.New(client, "/srv/myapp")}
+package sftp
+
+import (
+	"context"
+	"io"
+	"os"
+	"path"
+
+	updater "github.com/hverr/go-updater"
+	"github.com/pkg/sftp"
+)
+
+type tree struct {
+	client *sftp.Client
+	dir    string
+}
+
+// New creates an Application whose releases are stored on an SFTP server, in
+// directory dir and with the layout of updater.NewFileSystem.
+//
+// The releases are listed and the assets are downloaded with client, so they
+// share its SSH connection. Configure the connection, e.g. the credentials and
+// the host key callback, when creating the client, and close it when the
+// update is done.
+func New(client *sftp.Client, dir string) updater.App {
+	return updater.NewReleaseTree(&tree{
+		client: client,
+		dir:    dir,
+	})
+}
+
+func (t *tree) ReadDir(ctx context.Context, p string) ([]os.FileInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return t.client.ReadDir(path.Join(t.dir, p))
+}
+
+func (t *tree) Open(ctx context.Context, p string) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	f, err := t.client.Open(path.Join(t.dir, p))
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
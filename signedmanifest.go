@@ -0,0 +1,515 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// Version of the signed manifest format that is supported.
+const SignedManifestSpec = 1
+
+// Default name of the manifest asset of releases, see SignedManifestOptions.
+const DefaultManifestAssetName = "manifest.json"
+
+// SignedManifest describes a release in the signed manifest format.
+//
+// A signed manifest is a JSON document that carries the manifest and an
+// ed25519 signature of it:
+//
+//	{
+//		"manifest": {
+//			"spec": 1,
+//			"version": "v1.2.0",
+//			"channel": "stable",
+//			"notes": "Bug fixes and improvements.",
+//			"minimum_version": "v1.0.0",
+//			"rollout": 25,
+//			"assets": [
+//				{
+//					"name": "myapp_linux_amd64",
+//					"url": "https://example.com/myapp/v1.2.0/myapp_linux_amd64",
+//					"sha256": "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9",
+//					"size": 8388608,
+//					"os": "linux",
+//					"arch": "amd64"
+//				}
+//			]
+//		},
+//		"signature": "<base64 encoded ed25519 signature>"
+//	}
+//
+// The signature is made over the canonical form of the manifest object: the
+// JSON encoding without insignificant whitespace, with the keys of objects
+// sorted, numbers as they were written and without escaping of HTML
+// characters. The manifest can thus be formatted freely after signing. Use
+// SignManifest to create signed manifests.
+//
+// Every asset must have a checksum. Asset URLs may be relative to the URL of
+// the manifest. An asset without URL refers to the asset with the same name
+// in the release that carries the manifest, see NewReleaseManifest.
+type SignedManifest struct {
+	// Version of the format, SignedManifestSpec.
+	Spec int `json:"spec"`
+
+	// Version name of the release.
+	Version string `json:"version"`
+
+	// Release channel, e.g. "stable" or "beta".
+	Channel string `json:"channel,omitempty"`
+
+	// Identifier of the release. Defaults to the version.
+	Identifier string `json:"identifier,omitempty"`
+
+	// Human-readable release notes.
+	Notes string `json:"notes,omitempty"`
+
+	// Whether the release must be applied, see IsMandatory.
+	Mandatory bool `json:"mandatory,omitempty"`
+
+	// Oldest version of the application that can update to the release, e.g.
+	// because newer versions cannot migrate the data of older ones.
+	MinimumVersion string `json:"minimum_version,omitempty"`
+
+	// Version name of the release that versions older than MinimumVersion
+	// must update to first, see UpgradeRequirement.
+	UpgradeVia string `json:"upgrade_via,omitempty"`
+
+	// Percentage of the clients that should update to the release, see
+	// RolloutPercentage. The release is rolled out to all clients if it is
+	// not set.
+	Rollout *int `json:"rollout,omitempty"`
+
+	// Assets attached to the release.
+	Assets []SignedManifestAsset `json:"assets"`
+}
+
+// SignedManifestAsset describes a downloadable asset in a signed manifest.
+type SignedManifestAsset struct {
+	ManifestAsset
+
+	// Size of the asset in bytes.
+	Size int64 `json:"size"`
+
+	// Operating system and architecture the asset was built for, as GOOS
+	// and GOARCH, or empty if the asset is not specific to a platform. They
+	// are used by PlatformFilter instead of the name of the asset.
+	OS   string `json:"os,omitempty"`
+	Arch string `json:"arch,omitempty"`
+}
+
+// SignedManifestOptions configures an Application whose releases are
+// described by signed manifests.
+type SignedManifestOptions struct {
+	// Verifier of the signatures of manifests, e.g. NewEd25519Verifier with
+	// the public key of the release process, or a Keyring of such keys.
+	Verifier Verifier
+
+	// Channel that manifests must be published for, so that a manifest of
+	// e.g. a beta release cannot be served to stable clients. By default,
+	// the channel is not checked.
+	Channel string
+
+	// Version of the running application. When set, queries fail with an
+	// *UnsupportedVersionError if the latest release requires a newer
+	// MinimumVersion.
+	CurrentVersion string
+
+	// HTTP client used to download manifests and assets. Defaults to the
+	// HTTPClient of the Updater, or the default HTTP client.
+	HTTPClient *http.Client
+
+	// Name of the manifest asset in releases, see NewReleaseManifest.
+	// Defaults to DefaultManifestAssetName.
+	AssetName string
+}
+
+// signedEnvelope is the JSON document of a signed manifest.
+type signedEnvelope struct {
+	Manifest  json.RawMessage `json:"manifest"`
+	Signature string          `json:"signature"`
+}
+
+// SignManifest signs m with key and returns the signed manifest document.
+// The Spec of the manifest defaults to SignedManifestSpec.
+func SignManifest(m SignedManifest, key ed25519.PrivateKey) ([]byte, error) {
+	if m.Spec == 0 {
+		m.Spec = SignedManifestSpec
+	}
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	canonical, err := canonicalJSON(b)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.MarshalIndent(signedEnvelope{
+		Manifest:  canonical,
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(key, canonical)),
+	}, "", "  ")
+}
+
+// ParseSignedManifest verifies the signature of the signed manifest document
+// b with verifier, and returns the manifest.
+func ParseSignedManifest(b []byte, verifier Verifier) (*SignedManifest, error) {
+	if verifier == nil {
+		return nil, errors.New("No verifier for signed manifests.")
+	}
+
+	var env signedEnvelope
+	err := json.Unmarshal(b, &env)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid manifest: %v", err)
+	} else if len(env.Manifest) == 0 || env.Signature == "" {
+		return nil, errors.New("The manifest is not signed.")
+	}
+
+	canonical, err := canonicalJSON(env.Manifest)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid manifest: %v", err)
+	}
+	signature, err := base64.StdEncoding.DecodeString(env.Signature)
+	if err != nil {
+		return nil, errors.New("Invalid manifest signature encoding.")
+	}
+	err = verifier.Verify(canonical, signature)
+	if err != nil {
+		return nil, err
+	}
+
+	var m SignedManifest
+	err = json.Unmarshal(env.Manifest, &m)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid manifest: %v", err)
+	}
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// canonicalJSON returns the canonical form of the JSON value b, see
+// SignedManifest.
+func canonicalJSON(b []byte) ([]byte, error) {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	if _, err := d.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after the manifest")
+	}
+
+	buf := bytes.NewBuffer(nil)
+	e := json.NewEncoder(buf)
+	e.SetEscapeHTML(false)
+	if err := e.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// validate checks that m is a complete manifest of the supported version.
+func (m *SignedManifest) validate() error {
+	if m.Spec != SignedManifestSpec {
+		return fmt.Errorf("Unsupported manifest spec %v.", m.Spec)
+	} else if m.Version == "" {
+		return errors.New("The manifest has no version.")
+	} else if m.Rollout != nil && (*m.Rollout < 0 || *m.Rollout > 100) {
+		return fmt.Errorf("Invalid rollout percentage %v in manifest.", *m.Rollout)
+	}
+	if m.MinimumVersion != "" {
+		if _, err := parseVersion(m.MinimumVersion); err != nil {
+			return fmt.Errorf("Invalid minimum version in manifest: %v", err)
+		}
+	}
+
+	for _, a := range m.Assets {
+		if a.Name == "" {
+			return errors.New("The manifest has an asset without name.")
+		}
+		sum, err := hex.DecodeString(a.SHA256)
+		if err != nil || len(sum) != sha256.Size {
+			return fmt.Errorf("Invalid checksum for %v in manifest.", a.Name)
+		} else if a.Size < 0 {
+			return fmt.Errorf("Invalid size for %v in manifest.", a.Name)
+		}
+	}
+	return nil
+}
+
+// check checks that the manifest is published for the channel of opts, and
+// that the current version of opts can update to it.
+func (m *SignedManifest) check(opts *SignedManifestOptions) error {
+	if opts.Channel != "" && m.Channel != opts.Channel {
+		return fmt.Errorf("The manifest of %v is for channel %q instead of %q.", m.Version, m.Channel, opts.Channel)
+	}
+
+	if opts.CurrentVersion != "" && m.MinimumVersion != "" {
+		current, err := parseVersion(opts.CurrentVersion)
+		if err != nil {
+			return err
+		}
+		minimum, _ := parseVersion(m.MinimumVersion)
+		if current.compare(minimum) < 0 {
+			return &UnsupportedVersionError{
+				Release:        m.Version,
+				CurrentVersion: opts.CurrentVersion,
+				MinimumVersion: m.MinimumVersion,
+			}
+		}
+	}
+	return nil
+}
+
+// signedManifestRelease is a release described by a signed manifest.
+type signedManifestRelease struct {
+	Manifest SignedManifest
+
+	assets []Asset
+}
+
+// newSignedManifestRelease returns the release of m. The assets without URL
+// are looked up with source, the others are downloaded with client, relative
+// to base if it is not nil.
+func newSignedManifestRelease(m *SignedManifest, base *url.URL, client *http.Client, source func(name string) Asset) (*signedManifestRelease, error) {
+	s := make([]Asset, len(m.Assets))
+	for i, a := range m.Assets {
+		var download Asset
+		if a.URL == "" {
+			if source != nil {
+				download = source(a.Name)
+			}
+			if download == nil {
+				return nil, fmt.Errorf("No download URL for asset %v.", a.Name)
+			}
+		} else {
+			if base == nil {
+				base = &url.URL{}
+			}
+			m, err := newManifestAsset(ManifestAsset{Name: a.Name, URL: a.URL, Mirrors: a.Mirrors}, base, client)
+			if err != nil {
+				return nil, err
+			} else if u, _ := url.Parse(m.url); !u.IsAbs() {
+				return nil, fmt.Errorf("Invalid URL for asset %v: %v is not absolute", a.Name, a.URL)
+			}
+			download = m
+		}
+
+		s[i] = &signedManifestAsset{Asset: download, meta: a}
+	}
+
+	return &signedManifestRelease{
+		Manifest: *m,
+		assets:   s,
+	}, nil
+}
+
+func (r *signedManifestRelease) Name() string {
+	return r.Manifest.Version
+}
+
+func (r *signedManifestRelease) Information() string {
+	return r.Manifest.Notes
+}
+
+func (r *signedManifestRelease) Identifier() string {
+	if r.Manifest.Identifier != "" {
+		return r.Manifest.Identifier
+	}
+	return r.Manifest.Version
+}
+
+func (r *signedManifestRelease) Assets() []Asset {
+	return r.assets
+}
+
+func (r *signedManifestRelease) Mandatory() bool {
+	return r.Manifest.Mandatory
+}
+
+func (r *signedManifestRelease) MinimumVersion() string {
+	return r.Manifest.MinimumVersion
+}
+
+func (r *signedManifestRelease) UpgradeVia() string {
+	return r.Manifest.UpgradeVia
+}
+
+func (r *signedManifestRelease) RolloutPercentage() int {
+	if r.Manifest.Rollout != nil {
+		return *r.Manifest.Rollout
+	}
+	return 100
+}
+
+// signedManifestAsset is an asset of a signed manifest, downloaded from the
+// embedded asset and verified against the checksum of the manifest.
+type signedManifestAsset struct {
+	Asset
+
+	meta SignedManifestAsset
+}
+
+func (r *signedManifestAsset) Name() string {
+	return r.meta.Name
+}
+
+func (r *signedManifestAsset) SHA256() string {
+	return r.meta.SHA256
+}
+
+func (r *signedManifestAsset) Size() int64 {
+	return r.meta.Size
+}
+
+func (r *signedManifestAsset) ContentType() string {
+	return ""
+}
+
+func (r *signedManifestAsset) DownloadCount() int {
+	return -1
+}
+
+func (r *signedManifestAsset) Platform() (goos, goarch string) {
+	return r.meta.OS, r.meta.Arch
+}
+
+func (r *signedManifestAsset) Write(w io.Writer) error {
+	return r.WriteContext(context.Background(), w)
+}
+
+func (r *signedManifestAsset) WriteContext(ctx context.Context, w io.Writer) error {
+	expected, _ := hex.DecodeString(r.meta.SHA256)
+
+	h := sha256.New()
+	err := writeAsset(ctx, r.Asset, teeWriter(w, h))
+	if err != nil {
+		return err
+	}
+
+	return verifyChecksum(map[string][]byte{r.Name(): expected}, r, h.Sum(nil))
+}
+
+// NewSignedHTTPManifest creates an Application whose latest release is
+// described by a signed manifest at the given URL. See SignedManifest for the
+// format.
+//
+// The signature of the manifest is verified with the Verifier of opts, and
+// every asset is verified against its checksum in the manifest. Repeated
+// queries are conditional requests, like those of NewHTTPManifest.
+func NewSignedHTTPManifest(url string, opts SignedManifestOptions) App {
+	return &manifestApp{
+		url:    url,
+		client: opts.HTTPClient,
+		signed: &opts,
+	}
+}
+
+// parseSigned returns the release of the signed manifest b, downloaded from
+// base.
+func (app *manifestApp) parseSigned(b []byte, base *url.URL) (Release, error) {
+	m, err := ParseSignedManifest(b, app.signed.Verifier)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.check(app.signed); err != nil {
+		return nil, err
+	}
+	return newSignedManifestRelease(m, base, app.client, nil)
+}
+
+// releaseManifestApp is an application whose latest release is described by
+// a signed manifest that is an asset of the latest release of another
+// application.
+type releaseManifestApp struct {
+	app    App
+	opts   SignedManifestOptions
+	latest Release
+}
+
+// NewReleaseManifest creates an Application whose latest release is described
+// by the signed manifest attached to the latest release of app, e.g. a GitHub
+// release with a manifest.json asset. See SignedManifest for the format.
+//
+// Assets of the manifest without URL are downloaded from the asset with the
+// same name of the release of app, and are verified against their checksum in
+// the manifest. The manifest is the asset named by the AssetName of opts.
+func NewReleaseManifest(app App, opts SignedManifestOptions) App {
+	if opts.AssetName == "" {
+		opts.AssetName = DefaultManifestAssetName
+	}
+	return &releaseManifestApp{
+		app:  app,
+		opts: opts,
+	}
+}
+
+func (app *releaseManifestApp) Query() error {
+	return app.QueryContext(context.Background())
+}
+
+func (app *releaseManifestApp) QueryContext(ctx context.Context) error {
+	err := queryApp(ctx, app.app)
+	if err != nil {
+		return err
+	}
+
+	release := app.app.LatestRelease()
+	if release == nil {
+		app.latest = nil
+		return nil
+	}
+
+	ctx = withHTTPClient(ctx, app.opts.HTTPClient)
+	a := findAsset(release, app.opts.AssetName)
+	if a == nil {
+		return fmt.Errorf("Release %v has no manifest %v.", release.Name(), app.opts.AssetName)
+	}
+	buf := bytes.NewBuffer(nil)
+	err = writeAsset(ctx, a, buf)
+	if err != nil {
+		return err
+	}
+
+	m, err := ParseSignedManifest(buf.Bytes(), app.opts.Verifier)
+	if err != nil {
+		return err
+	}
+	if err := m.check(&app.opts); err != nil {
+		return err
+	}
+
+	source := func(name string) Asset {
+		if name == app.opts.AssetName {
+			return nil
+		}
+		return findAsset(release, name)
+	}
+	r, err := newSignedManifestRelease(m, nil, app.opts.HTTPClient, source)
+	if err != nil {
+		return err
+	}
+	app.latest = r
+	return nil
+}
+
+func (app *releaseManifestApp) LatestRelease() Release {
+	return app.latest
+}
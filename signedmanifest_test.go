@@ -0,0 +1,263 @@
+package updater
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSignedManifest(t *testing.T) (SignedManifest, ed25519.PublicKey, ed25519.PrivateKey) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.Nil(t, err)
+
+	sum := sha256.Sum256([]byte("Hello World!"))
+	rollout := 50
+	return SignedManifest{
+		Version:        "v1.2.0",
+		Channel:        "stable",
+		Notes:          "Bug fixes & <improvements>.",
+		MinimumVersion: "v1.0.0",
+		Rollout:        &rollout,
+		Assets: []SignedManifestAsset{
+			{
+				ManifestAsset: ManifestAsset{Name: "myapp_linux_amd64", URL: "myapp_linux_amd64", SHA256: hex.EncodeToString(sum[:])},
+				Size:          12,
+				OS:            "linux",
+				Arch:          "amd64",
+			},
+			{
+				ManifestAsset: ManifestAsset{Name: "myapp.exe", URL: "myapp.exe", SHA256: hex.EncodeToString(sum[:])},
+				Size:          12,
+				OS:            "windows",
+				Arch:          "amd64",
+			},
+		},
+	}, pub, priv
+}
+
+func TestSignedManifest(t *testing.T) {
+	m, pub, priv := newTestSignedManifest(t)
+	verifier := NewEd25519Verifier(pub)
+
+	b, err := SignManifest(m, priv)
+	require.Nil(t, err)
+
+	// Valid signature
+	{
+		parsed, err := ParseSignedManifest(b, verifier)
+		require.Nil(t, err, "Could not parse manifest: %v", err)
+		m.Spec = SignedManifestSpec
+		assert.Equal(t, &m, parsed)
+	}
+
+	// Formatted differently
+	{
+		buf := bytes.NewBuffer(nil)
+		require.Nil(t, json.Compact(buf, b))
+		_, err := ParseSignedManifest(buf.Bytes(), verifier)
+		assert.Nil(t, err)
+	}
+
+	// Tampered
+	{
+		tampered := bytes.Replace(b, []byte("v1.2.0"), []byte("v1.3.0"), 1)
+		_, err := ParseSignedManifest(tampered, verifier)
+		assert.Error(t, err)
+	}
+
+	// Other key
+	{
+		other, _, err := ed25519.GenerateKey(rand.Reader)
+		require.Nil(t, err)
+		_, err = ParseSignedManifest(b, NewEd25519Verifier(other))
+		assert.Error(t, err)
+	}
+
+	// Not signed
+	{
+		_, err := ParseSignedManifest([]byte(validManifestJSON), verifier)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "not signed")
+	}
+
+	// Unsupported spec
+	{
+		m := m
+		m.Spec = 2
+		_, err := SignManifest(m, priv)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Unsupported manifest spec")
+	}
+
+	// Asset without checksum
+	{
+		m := m
+		m.Assets = []SignedManifestAsset{{ManifestAsset: ManifestAsset{Name: "myapp"}}}
+		_, err := SignManifest(m, priv)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Invalid checksum")
+	}
+}
+
+func TestSignedHTTPManifest(t *testing.T) {
+	m, pub, priv := newTestSignedManifest(t)
+	b, err := SignManifest(m, priv)
+	require.Nil(t, err)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/releases/manifest.json":
+			w.Write(b)
+		case "/releases/myapp_linux_amd64":
+			w.Write([]byte("Hello World!"))
+		case "/releases/myapp.exe":
+			w.Write([]byte("Corrupt"))
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	defer ts.Close()
+
+	opts := SignedManifestOptions{
+		Verifier:       NewEd25519Verifier(pub),
+		Channel:        "stable",
+		CurrentVersion: "v1.1.0",
+	}
+
+	// Valid manifest
+	{
+		app := NewSignedHTTPManifest(ts.URL+"/releases/manifest.json", opts)
+		err := app.Query()
+		require.Nil(t, err, "Unexpected query error: %v", err)
+
+		r := app.LatestRelease()
+		require.NotNil(t, r)
+		assert.Equal(t, "v1.2.0", r.Name())
+		assert.Equal(t, "v1.2.0", r.Identifier())
+		assert.Equal(t, 50, RolloutPercentage(r))
+		assert.False(t, IsMandatory(r))
+		require.Equal(t, 2, len(r.Assets()))
+		assert.Equal(t, int64(12), r.Assets()[0].(AssetMeta).Size())
+
+		filter := PlatformFilter("windows", "amd64")
+		assert.False(t, filter(r.Assets()[0]))
+		assert.True(t, filter(r.Assets()[1]))
+
+		w := NewAbortBuffer(nil)
+		u := &Updater{
+			AssetFilter: PlatformFilter("linux", "amd64"),
+			WriterForAsset: func(Asset) (AbortWriter, error) {
+				return w, nil
+			},
+		}
+		err = u.UpdateTo(r)
+		assert.Nil(t, err)
+		assert.Equal(t, "Hello World!", w.Buffer.String())
+
+		// Invalid checksum
+		err = r.Assets()[1].Write(bytes.NewBuffer(nil))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "mismatch")
+	}
+
+	// Other channel
+	{
+		opts := opts
+		opts.Channel = "beta"
+		err := NewSignedHTTPManifest(ts.URL+"/releases/manifest.json", opts).Query()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "channel")
+	}
+
+	// Unsupported version
+	{
+		opts := opts
+		opts.CurrentVersion = "v0.9.0"
+		err := NewSignedHTTPManifest(ts.URL+"/releases/manifest.json", opts).Query()
+		var unsupported *UnsupportedVersionError
+		require.True(t, errors.As(err, &unsupported), "Unexpected error: %v", err)
+		assert.Equal(t, "v1.0.0", unsupported.MinimumVersion)
+	}
+
+	// Plain manifest
+	{
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(validManifestJSON))
+		}))
+		defer ts.Close()
+
+		err := NewSignedHTTPManifest(ts.URL, opts).Query()
+		assert.Error(t, err)
+	}
+}
+
+func TestReleaseManifest(t *testing.T) {
+	m, pub, priv := newTestSignedManifest(t)
+	m.Assets = m.Assets[:1]
+	m.Assets[0].URL = ""
+	b, err := SignManifest(m, priv)
+	require.Nil(t, err)
+
+	asset := func(name, contents string) *testAsset {
+		return &testAsset{name: name, write: func(w io.Writer) error {
+			_, err := io.WriteString(w, contents)
+			return err
+		}}
+	}
+	release := &testRelease{name: "v1.2.0", assets: []Asset{
+		asset(DefaultManifestAssetName, string(b)),
+		asset("myapp_linux_amd64", "Hello World!"),
+	}}
+	source := &testApp{FLatestRelease: func() Release { return release }}
+
+	// Valid manifest
+	{
+		app := NewReleaseManifest(source, SignedManifestOptions{Verifier: NewEd25519Verifier(pub)})
+		err := app.Query()
+		require.Nil(t, err, "Unexpected query error: %v", err)
+
+		r := app.LatestRelease()
+		require.NotNil(t, r)
+		assert.Equal(t, "v1.2.0", r.Name())
+		require.Equal(t, 1, len(r.Assets()))
+
+		buf := bytes.NewBuffer(nil)
+		err = r.Assets()[0].Write(buf)
+		assert.Nil(t, err)
+		assert.Equal(t, "Hello World!", buf.String())
+	}
+
+	// Asset that does not match the manifest
+	{
+		release.assets[1] = asset("myapp_linux_amd64", "Corrupt")
+		app := NewReleaseManifest(source, SignedManifestOptions{Verifier: NewEd25519Verifier(pub)})
+		require.Nil(t, app.Query())
+
+		err := app.LatestRelease().Assets()[0].Write(bytes.NewBuffer(nil))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "mismatch")
+	}
+
+	// Missing manifest
+	{
+		app := NewReleaseManifest(source, SignedManifestOptions{
+			Verifier:  NewEd25519Verifier(pub),
+			AssetName: "other.json",
+		})
+		err := app.Query()
+		require.Error(t, err)
+		assert.True(t, strings.Contains(err.Error(), "no manifest"))
+	}
+}
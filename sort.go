@@ -0,0 +1,56 @@
+package updater
+
+import (
+	"sort"
+	"time"
+)
+
+// ReleaseSorter sorts releases in place, the newest release first.
+//
+// Applications that list releases, like NewGitHubWithOptions, use it after
+// every query, so that their latest release is the newest one whatever the
+// order in which the API returns them.
+type ReleaseSorter func(releases []Release)
+
+// SortReleases is the default ReleaseSorter. It sorts releases by the
+// semantic versions of their names, e.g. the tag names of GitHub releases.
+// Releases whose names are no versions come after the others. Releases with
+// the same version and releases whose names are no versions are sorted by the
+// time they were published, if they implement ReleaseMeta, and releases
+// without publication time come after those with one. Releases that cannot be
+// compared keep their order.
+func SortReleases(releases []Release) {
+	versions := make(map[Release]*version, len(releases))
+	for _, r := range releases {
+		if v, err := parseVersion(r.Name()); err == nil {
+			versions[r] = &v
+		}
+	}
+
+	sort.SliceStable(releases, func(i, j int) bool {
+		a, b := releases[i], releases[j]
+		va, vb := versions[a], versions[b]
+		if (va == nil) != (vb == nil) {
+			return va != nil
+		} else if va != nil {
+			if c := va.compare(*vb); c != 0 {
+				return c > 0
+			}
+		}
+
+		ta, tb := publishedAt(a), publishedAt(b)
+		if ta.IsZero() != tb.IsZero() {
+			return !ta.IsZero()
+		}
+		return ta.After(tb)
+	})
+}
+
+// publishedAt returns the time at which r was published, or the zero time if
+// it is not known.
+func publishedAt(r Release) time.Time {
+	if m, ok := r.(ReleaseMeta); ok {
+		return m.PublishedAt()
+	}
+	return time.Time{}
+}
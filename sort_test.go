@@ -0,0 +1,104 @@
+package updater
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testPublishedRelease struct {
+	testRelease
+	published time.Time
+}
+
+func (r *testPublishedRelease) PublishedAt() time.Time { return r.published }
+func (r *testPublishedRelease) URL() string            { return "" }
+func (r *testPublishedRelease) Prerelease() bool       { return false }
+
+func TestSortReleases(t *testing.T) {
+	// Versions
+	{
+		v10 := &testRelease{name: "v1.10.0"}
+		v9 := &testRelease{name: "v1.9.0"}
+		v2 := &testRelease{name: "v2.0.0-beta.1"}
+		v2final := &testRelease{name: "v2.0.0"}
+		releases := []Release{v9, v2, v10, v2final}
+
+		SortReleases(releases)
+		assert.Equal(t, []Release{v2final, v2, v10, v9}, releases)
+	}
+
+	// Publication time
+	{
+		now := time.Now()
+		nightly := &testPublishedRelease{testRelease{name: "nightly"}, now}
+		older := &testPublishedRelease{testRelease{name: "nightly-old"}, now.Add(-time.Hour)}
+		releases := []Release{older, nightly}
+
+		SortReleases(releases)
+		assert.Equal(t, []Release{nightly, older}, releases)
+	}
+
+	// Versions and other names mixed
+	{
+		date := func(year int) time.Time { return time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC) }
+		v2 := &testPublishedRelease{testRelease{name: "v2.0.0"}, date(2020)}
+		v1 := &testPublishedRelease{testRelease{name: "v1.0.0"}, date(2022)}
+		v1old := &testRelease{name: "v1.0.0"}
+		nightly := &testPublishedRelease{testRelease{name: "nightly"}, date(2021)}
+		unknown := &testRelease{name: "unknown"}
+		expected := []Release{v2, v1, v1old, nightly, unknown}
+
+		for _, releases := range [][]Release{
+			{v1, nightly, v2, unknown, v1old},
+			{nightly, v2, v1old, unknown, v1},
+			{unknown, v1old, v1, nightly, v2},
+		} {
+			SortReleases(releases)
+			assert.Equal(t, expected, releases)
+		}
+	}
+}
+
+func TestGitHubReleaseSorter(t *testing.T) {
+	newServer := func() (func(), *GitHubOptions) {
+		ts, cl := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/repos/hverr/reponame/releases" {
+				w.Write([]byte(`[{"tag_name": "v0.9.0"}, {"tag_name": "v1.0.0"}, {"tag_name": "v0.8.0"}]`))
+			} else if strings.HasPrefix(r.URL.Path, "/repos/hverr/reponame/git/refs/tags/") {
+				strings.NewReader(validReferenceJSON).WriteTo(w)
+			} else {
+				require.True(t, false, "Unexpected URL path: %v", r.URL.Path)
+			}
+		})
+		opts := &GitHubOptions{Client: cl}
+		return ts.Close, opts
+	}
+
+	// Sorted by version
+	{
+		closeServer, opts := newServer()
+		defer closeServer()
+
+		app, err := NewGitHubWithOptions("hverr", "reponame", *opts)
+		require.Nil(t, err)
+		require.Nil(t, app.Query())
+		assert.Equal(t, "v1.0.0", app.LatestRelease().Name())
+	}
+
+	// Custom sorter
+	{
+		closeServer, opts := newServer()
+		defer closeServer()
+
+		opts.ReleaseSorter = func([]Release) {}
+		app, err := NewGitHubWithOptions("hverr", "reponame", *opts)
+		require.Nil(t, err)
+		require.Nil(t, app.Query())
+		assert.Equal(t, "v0.9.0", app.LatestRelease().Name())
+	}
+}
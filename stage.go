@@ -0,0 +1,203 @@
+package updater
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Name of the directory of the staged release in the StageDir, and of the
+// file describing it.
+const (
+	stagedDirName  = "release"
+	stagedFileName = "release.json"
+)
+
+// stagedInfo describes a staged release, and is stored with its assets.
+type stagedInfo struct {
+	Name        string            `json:"name"`
+	Identifier  string            `json:"identifier"`
+	Information string            `json:"information,omitempty"`
+	Mandatory   bool              `json:"mandatory,omitempty"`
+	Assets      []stagedAssetInfo `json:"assets"`
+}
+
+type stagedAssetInfo struct {
+	Name string `json:"name"`
+	File string `json:"file"`
+}
+
+// stagedRelease is a release that was staged with Download.
+type stagedRelease struct {
+	info   stagedInfo
+	assets []Asset
+}
+
+// stagedAsset is an asset of a staged release.
+type stagedAsset struct {
+	name string
+	path string
+}
+
+// Download downloads the assets of release that UpdateTo would write to the
+// StageDir, and verifies them, without applying the update. Use Apply to
+// apply the staged release later, e.g. when the application restarts.
+//
+// A release that was staged before is replaced.
+func (u *Updater) Download(release Release) error {
+	return u.DownloadContext(context.Background(), release)
+}
+
+// DownloadContext is like Download but aborts when ctx is cancelled.
+func (u *Updater) DownloadContext(ctx context.Context, release Release) error {
+	if u.StageDir == "" {
+		return errors.New("No stage directory configured.")
+	}
+
+	u.updating.Lock()
+	defer u.updating.Unlock()
+
+	err := os.MkdirAll(u.StageDir, 0755)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempDir(u.StageDir, ".tmp-")
+	if err != nil {
+		return err
+	}
+
+	r, err := u.predownload(ctx, release, tmp)
+	if err != nil {
+		return err
+	}
+	defer r.remove()
+
+	// Verify the checksums and signatures of the downloaded assets
+	_, err = u.writeAssets(ctx, r, u.AssetFilter, func(Asset) (AbortWriter, error) {
+		return NopAbortWriter(ioutil.Discard), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	info := stagedInfo{
+		Name:        release.Name(),
+		Identifier:  release.Identifier(),
+		Information: release.Information(),
+		Mandatory:   IsMandatory(release),
+	}
+	for _, a := range r.assets {
+		if d, ok := a.(*downloadedAsset); ok {
+			info.Assets = append(info.Assets, stagedAssetInfo{Name: a.Name(), File: filepath.Base(d.path)})
+		}
+	}
+	b, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(filepath.Join(tmp, stagedFileName), b, 0644)
+	if err != nil {
+		return err
+	}
+
+	// Replace the release that was staged before
+	dir := filepath.Join(u.StageDir, stagedDirName)
+	err = os.RemoveAll(dir)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmp, dir)
+	if err != nil {
+		return err
+	}
+	u.logf("Staged release %v in %v", release.Name(), dir)
+	return nil
+}
+
+// StagedRelease returns the release that was staged with Download, or nil if
+// there is none.
+func (u *Updater) StagedRelease() (Release, error) {
+	if u.StageDir == "" {
+		return nil, nil
+	}
+
+	dir := filepath.Join(u.StageDir, stagedDirName)
+	b, err := ioutil.ReadFile(filepath.Join(dir, stagedFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	r := &stagedRelease{}
+	err = json.Unmarshal(b, &r.info)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range r.info.Assets {
+		r.assets = append(r.assets, &stagedAsset{
+			name: a.Name,
+			path: filepath.Join(dir, filepath.Base(a.File)),
+		})
+	}
+	return r, nil
+}
+
+// Apply applies the release that was staged with Download, with UpdateTo.
+//
+// The release that was applied is returned. When no release is staged, or
+// the staged release is the current release, nil is returned. The staged
+// release is removed once it was applied, or if it could not be applied,
+// unless Apply was cancelled or another update was in progress.
+func (u *Updater) Apply() (Release, error) {
+	return u.ApplyContext(context.Background())
+}
+
+// ApplyContext is like Apply but aborts when ctx is cancelled.
+func (u *Updater) ApplyContext(ctx context.Context) (Release, error) {
+	r, err := u.StagedRelease()
+	if err != nil || r == nil {
+		return nil, err
+	}
+
+	if r.Identifier() == u.currentIdentifier() {
+		u.logf("Staged release %v is the current release", r.Name())
+		return nil, u.removeStaged()
+	}
+
+	err = u.UpdateToContext(ctx, r)
+	if err != nil {
+		if ctx.Err() == nil && err != ErrUpdateInProgress {
+			u.removeStaged()
+		}
+		return nil, err
+	}
+	return r, u.removeStaged()
+}
+
+// removeStaged removes the staged release.
+func (u *Updater) removeStaged() error {
+	return os.RemoveAll(filepath.Join(u.StageDir, stagedDirName))
+}
+
+func (r *stagedRelease) Name() string        { return r.info.Name }
+func (r *stagedRelease) Information() string { return r.info.Information }
+func (r *stagedRelease) Identifier() string  { return r.info.Identifier }
+func (r *stagedRelease) Assets() []Asset     { return r.assets }
+func (r *stagedRelease) Mandatory() bool     { return r.info.Mandatory }
+
+func (a *stagedAsset) Name() string {
+	return a.name
+}
+
+func (a *stagedAsset) Write(w io.Writer) error {
+	return writeFile(a.path, w)
+}
+
+func (a *stagedAsset) Open(ctx context.Context) (io.ReadCloser, int64, error) {
+	return openFile(a.path)
+}
@@ -0,0 +1,123 @@
+package updater
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdaterStage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testing-")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	sum := sha256.Sum256([]byte("Hello World!"))
+	writes := 0
+	release := &testRelease{
+		name:        "v2",
+		identifier:  "c2",
+		information: "Bug fixes. [mandatory]",
+		assets: []Asset{
+			&testAsset{name: "myapp", write: func(w io.Writer) error {
+				writes++
+				_, err := io.WriteString(w, "Hello World!")
+				return err
+			}},
+			&testAsset{name: "checksums.txt", write: func(w io.Writer) error {
+				_, err := fmt.Fprintf(w, "%x  myapp\n", sum)
+				return err
+			}},
+			&testAsset{name: "other"},
+		},
+	}
+
+	var b *AbortBuffer
+	newUpdater := func() *Updater {
+		return &Updater{
+			CurrentReleaseIdentifier: "c1",
+			StageDir:                 dir,
+			ChecksumAssetName:        "checksums.txt",
+			AssetFilter:              func(a Asset) bool { return a.Name() == "myapp" },
+			WriterForAsset: func(Asset) (AbortWriter, error) {
+				b = NewAbortBuffer(nil)
+				return b, nil
+			},
+		}
+	}
+
+	// Nothing staged
+	{
+		r, err := newUpdater().Apply()
+		assert.Nil(t, err)
+		assert.Nil(t, r)
+	}
+
+	// Staged and applied after a restart
+	{
+		err := newUpdater().Download(release)
+		require.Nil(t, err, "Could not download: %v", err)
+		assert.Equal(t, 1, writes)
+		assert.Nil(t, b)
+
+		u := newUpdater()
+		r, err := u.StagedRelease()
+		require.Nil(t, err)
+		require.NotNil(t, r)
+		assert.Equal(t, "v2", r.Name())
+		assert.Equal(t, "c2", r.Identifier())
+		assert.True(t, IsMandatory(r))
+		assert.Equal(t, 2, len(r.Assets()))
+
+		r, err = u.Apply()
+		require.Nil(t, err, "Could not apply: %v", err)
+		require.NotNil(t, r)
+		assert.Equal(t, "c2", r.Identifier())
+		require.NotNil(t, b)
+		assert.Equal(t, "Hello World!", b.Buffer.String())
+		assert.Equal(t, 1, writes)
+
+		r, err = u.StagedRelease()
+		assert.Nil(t, err)
+		assert.Nil(t, r)
+	}
+
+	// Corrupt staged files are removed
+	{
+		require.Nil(t, newUpdater().Download(release))
+		r, err := newUpdater().StagedRelease()
+		require.Nil(t, err)
+		p := r.Assets()[0].(*stagedAsset).path
+		require.Nil(t, ioutil.WriteFile(p, []byte("Corrupt"), 0644))
+
+		r, err = newUpdater().Apply()
+		assert.NotNil(t, err)
+		assert.Nil(t, r)
+		r, err = newUpdater().StagedRelease()
+		assert.Nil(t, err)
+		assert.Nil(t, r)
+	}
+
+	// Current release staged
+	{
+		require.Nil(t, newUpdater().Download(release))
+		u := newUpdater()
+		u.CurrentReleaseIdentifier = "c2"
+		r, err := u.Apply()
+		assert.Nil(t, err)
+		assert.Nil(t, r)
+		_, err = os.Stat(filepath.Join(dir, stagedDirName))
+		assert.True(t, os.IsNotExist(err))
+	}
+
+	// No stage directory
+	{
+		assert.NotNil(t, (&Updater{}).Download(release))
+	}
+}
@@ -0,0 +1,263 @@
+package updater
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// State is the information an updater remembers between runs.
+type State struct {
+	// Identifiers of the releases the user chose to skip.
+	SkippedReleases []string `json:"skipped_releases,omitempty"`
+
+	// Time of the last successful check for updates.
+	LastCheck time.Time `json:"last_check"`
+
+	// Identifier of the release found by the last check, if it found an
+	// update. Only recorded when the updater throttles checks, see
+	// MinCheckInterval.
+	LastCheckRelease string `json:"last_check_release,omitempty"`
+
+	// Identifier of the last release that was applied.
+	LastApplied string `json:"last_applied,omitempty"`
+
+	// Name of the last release that was applied.
+	LastAppliedName string `json:"last_applied_name,omitempty"`
+
+	// Time at which the last release was applied.
+	LastUpdate time.Time `json:"last_update"`
+
+	// Anonymous identifier of the installation, see LoadInstallationID.
+	MachineID string `json:"machine_id,omitempty"`
+}
+
+// Skipped reports whether the release with the given identifier is skipped.
+func (s *State) Skipped(identifier string) bool {
+	for _, id := range s.SkippedReleases {
+		if id == identifier {
+			return true
+		}
+	}
+	return false
+}
+
+// StateStore stores the state of an updater.
+type StateStore interface {
+	// Load should return the stored state, or an empty state if nothing was
+	// stored yet.
+	Load() (*State, error)
+
+	// Save should store the state.
+	Save(state *State) error
+}
+
+// FileStateStore stores the state of an updater in a JSON file.
+type FileStateStore struct {
+	// Path of the JSON file.
+	Path string
+}
+
+// NewFileStateStore creates a state store that uses the JSON file at path,
+// e.g. a file in the directory returned by os.UserConfigDir.
+func NewFileStateStore(path string) *FileStateStore {
+	return &FileStateStore{Path: path}
+}
+
+// Load reads the state from the file. If the file does not exist, an empty
+// state is returned.
+func (s *FileStateStore) Load() (*State, error) {
+	state := &State{}
+	b, err := ioutil.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return state, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(b, state)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid state file %v: %v", s.Path, err)
+	}
+	return state, nil
+}
+
+// Save writes the state to the file.
+//
+// The state is written to a temporary file first, which then replaces the
+// file, so a crash never leaves a partially written state behind.
+func (s *FileStateStore) Save(state *State) error {
+	b, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(s.Path), filepath.Base(s.Path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(b)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), s.Path)
+}
+
+// SkipRelease records that the user chose to skip release.
+//
+// Check and CheckContext will no longer propose the release, but will propose
+// newer releases. Mandatory releases are proposed anyway, see IsMandatory. It
+// returns an error if the updater has no StateStore.
+func (u *Updater) SkipRelease(release Release) error {
+	if u.State == nil {
+		return fmt.Errorf("No state store to skip release %v.", release.Name())
+	}
+
+	if !IsMandatory(release) {
+		u.forgetCheck(release.Identifier())
+	}
+	return u.updateState(func(s *State) {
+		if !s.Skipped(release.Identifier()) {
+			s.SkippedReleases = append(s.SkippedReleases, release.Identifier())
+		}
+		if s.LastCheckRelease == release.Identifier() && !IsMandatory(release) {
+			s.LastCheckRelease = ""
+		}
+	})
+}
+
+// updateState loads the state, calls f to modify it and saves it again. It
+// does nothing if the updater has no StateStore.
+func (u *Updater) updateState(f func(s *State)) error {
+	if u.State == nil {
+		return nil
+	}
+
+	s, err := u.State.Load()
+	if err != nil {
+		return err
+	}
+
+	f(s)
+	return u.State.Save(s)
+}
+
+// appliedRelease records that release was applied.
+//
+// The files of the release are already in place, so a failure to save the
+// state is only logged and does not fail the update.
+func (u *Updater) appliedRelease(release Release) {
+	now := time.Now()
+	u.mutex.Lock()
+	u.lastUpdate, u.lastRelease = now, release
+	u.mutex.Unlock()
+	u.forgetCheck(release.Identifier())
+
+	err := u.updateState(func(s *State) {
+		s.LastApplied = release.Identifier()
+		s.LastAppliedName = release.Name()
+		s.LastUpdate = now
+		if s.LastCheckRelease == release.Identifier() {
+			s.LastCheckRelease = ""
+		}
+	})
+	if err != nil {
+		u.logf("Could not record applied release %v in state: %v", release.Name(), err)
+	}
+}
+
+// LastCheck returns the time of the last successful check for updates, or
+// the zero time if there was none.
+//
+// Checks of previous runs are remembered in the StateStore, e.g. to show when
+// the application last checked for updates, or to skip a check at startup
+// when the last one is recent.
+func (u *Updater) LastCheck() time.Time {
+	u.mutex.Lock()
+	t := u.lastCheck
+	u.mutex.Unlock()
+	if !t.IsZero() {
+		return t
+	}
+
+	s := u.loadState()
+	if s == nil {
+		return time.Time{}
+	}
+	return s.LastCheck
+}
+
+// LastUpdate returns the last release that was applied and when, or nil and
+// the zero time if no release was applied.
+//
+// Updates of previous runs are remembered in the StateStore. Their release
+// only has a name and identifier, unless the last query of the application
+// found it.
+func (u *Updater) LastUpdate() (Release, time.Time) {
+	u.mutex.Lock()
+	r, t := u.lastRelease, u.lastUpdate
+	u.mutex.Unlock()
+	if r != nil {
+		return r, t
+	}
+
+	s := u.loadState()
+	if s == nil || s.LastApplied == "" {
+		return nil, time.Time{}
+	}
+	if app, ok := u.App.(ReleasesApp); ok {
+		for _, r := range app.Releases() {
+			if r.Identifier() == s.LastApplied {
+				return r, s.LastUpdate
+			}
+		}
+	}
+	return &stateRelease{name: s.LastAppliedName, identifier: s.LastApplied}, s.LastUpdate
+}
+
+// loadState returns the stored state, or nil if the updater has no
+// StateStore or it could not be loaded.
+func (u *Updater) loadState() *State {
+	if u.State == nil {
+		return nil
+	}
+
+	s, err := u.State.Load()
+	if err != nil {
+		u.logf("Could not load state: %v", err)
+		return nil
+	}
+	return s
+}
+
+// stateRelease is a release remembered in the State, of which only the name
+// and identifier are known.
+type stateRelease struct {
+	name       string
+	identifier string
+}
+
+func (r *stateRelease) Name() string {
+	return r.name
+}
+
+func (r *stateRelease) Information() string {
+	return ""
+}
+
+func (r *stateRelease) Identifier() string {
+	return r.identifier
+}
+
+func (r *stateRelease) Assets() []Asset {
+	return nil
+}
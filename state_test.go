@@ -0,0 +1,202 @@
+package updater
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStateStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testing-")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	s := NewFileStateStore(filepath.Join(dir, "state.json"))
+
+	// Missing file
+	{
+		state, err := s.Load()
+		assert.Nil(t, err)
+		assert.Equal(t, &State{}, state)
+	}
+
+	// Save and load
+	{
+		now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+		err := s.Save(&State{
+			SkippedReleases: []string{"1", "2"},
+			LastCheck:       now,
+			LastApplied:     "3",
+		})
+		assert.Nil(t, err, "Could not save: %v", err)
+
+		state, err := s.Load()
+		assert.Nil(t, err, "Could not load: %v", err)
+		assert.Equal(t, []string{"1", "2"}, state.SkippedReleases)
+		assert.True(t, now.Equal(state.LastCheck))
+		assert.Equal(t, "3", state.LastApplied)
+		assert.True(t, state.Skipped("2"))
+		assert.False(t, state.Skipped("3"))
+
+		files, err := ioutil.ReadDir(dir)
+		assert.Nil(t, err)
+		assert.Len(t, files, 1)
+	}
+
+	// Invalid file
+	{
+		err := ioutil.WriteFile(s.Path, []byte("{invalid"), 0644)
+		require.Nil(t, err)
+
+		_, err = s.Load()
+		assert.Error(t, err)
+	}
+}
+
+func TestUpdaterState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testing-")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	v2 := &testRelease{name: "v2", identifier: "2"}
+	v3 := &testRelease{name: "v3", identifier: "3"}
+	latest := Release(v2)
+	store := NewFileStateStore(filepath.Join(dir, "state.json"))
+	u := &Updater{
+		App:                      &testApp{FLatestRelease: func() Release { return latest }},
+		CurrentReleaseIdentifier: "1",
+		WriterForAsset: func(Asset) (AbortWriter, error) {
+			return NewAbortBuffer(nil), nil
+		},
+		State: store,
+	}
+
+	// Check records the time
+	{
+		start := time.Now()
+		r, err := u.Check()
+		assert.Nil(t, err)
+		assert.Equal(t, v2, r)
+
+		state, err := store.Load()
+		require.Nil(t, err)
+		assert.False(t, state.LastCheck.Before(start.Truncate(time.Second)))
+	}
+
+	// Skipped release
+	{
+		err := u.SkipRelease(v2)
+		assert.Nil(t, err, "Could not skip: %v", err)
+
+		r, err := u.Check()
+		assert.Nil(t, err)
+		assert.Nil(t, r)
+
+		err = u.UpdateTo(nil)
+		assert.Equal(t, ErrUpToDate, err)
+	}
+
+	// Newer release
+	{
+		latest = v3
+		err := u.UpdateTo(nil)
+		assert.Nil(t, err, "Could not update: %v", err)
+
+		state, err := store.Load()
+		require.Nil(t, err)
+		assert.Equal(t, "3", state.LastApplied)
+		assert.Equal(t, []string{"2"}, state.SkippedReleases)
+	}
+
+	// Without state store
+	{
+		err := (&Updater{}).SkipRelease(v2)
+		assert.Error(t, err)
+	}
+
+	// State that cannot be saved
+	{
+		v4 := &testRelease{name: "v4", identifier: "4"}
+		u.State = failingStateStore{}
+		err := u.UpdateTo(v4)
+		assert.Nil(t, err, "Could not update: %v", err)
+
+		r, _ := u.LastUpdate()
+		assert.Equal(t, v4, r)
+		assert.True(t, u.Report().Applied)
+	}
+}
+
+type failingStateStore struct{}
+
+func (failingStateStore) Load() (*State, error) { return &State{}, nil }
+func (failingStateStore) Save(*State) error     { return errors.New("Disk full.") }
+
+func TestUpdaterLastCheckAndUpdate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testing-")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	v2 := &testRelease{name: "v2", identifier: "2"}
+	store := NewFileStateStore(filepath.Join(dir, "state.json"))
+	newUpdater := func() *Updater {
+		return &Updater{
+			App:                      &testApp{FLatestRelease: func() Release { return v2 }},
+			CurrentReleaseIdentifier: "1",
+			WriterForAsset: func(Asset) (AbortWriter, error) {
+				return NewAbortBuffer(nil), nil
+			},
+			State: store,
+		}
+	}
+
+	// Nothing happened yet
+	{
+		u := newUpdater()
+		assert.True(t, u.LastCheck().IsZero())
+		r, at := u.LastUpdate()
+		assert.Nil(t, r)
+		assert.True(t, at.IsZero())
+	}
+
+	// Check and update
+	start := time.Now()
+	{
+		u := newUpdater()
+		err := u.UpdateTo(nil)
+		require.Nil(t, err, "Could not update: %v", err)
+
+		assert.False(t, u.LastCheck().Before(start))
+		r, at := u.LastUpdate()
+		assert.Equal(t, v2, r)
+		assert.False(t, at.Before(u.LastCheck()))
+	}
+
+	// Remembered by the next run
+	{
+		u := newUpdater()
+		assert.False(t, u.LastCheck().Before(start.Truncate(time.Second)))
+		r, at := u.LastUpdate()
+		require.NotNil(t, r)
+		assert.Equal(t, "v2", r.Name())
+		assert.Equal(t, "2", r.Identifier())
+		assert.False(t, at.Before(start.Truncate(time.Second)))
+	}
+
+	// Without state store
+	{
+		u := newUpdater()
+		u.State = nil
+		assert.True(t, u.LastCheck().IsZero())
+
+		_, err := u.Check()
+		require.Nil(t, err)
+		assert.False(t, u.LastCheck().IsZero())
+	}
+}
@@ -0,0 +1,64 @@
+package updater
+
+import "time"
+
+// throttledCheck returns the result of the last check, and true, if it is
+// more recent than the MinCheckInterval of the updater.
+//
+// A check of a previous run is only reused if it did not find an update,
+// because the release it found is not known.
+func (u *Updater) throttledCheck() (Release, bool) {
+	if u.MinCheckInterval <= 0 {
+		return nil, false
+	}
+
+	u.mutex.Lock()
+	last, cached, r := u.lastCheck, u.checkCached, u.checkResult
+	u.mutex.Unlock()
+	if cached {
+		if time.Since(last) >= u.MinCheckInterval {
+			return nil, false
+		}
+		u.logf("Last check was %v ago, not checking again", time.Since(last).Round(time.Second))
+		return r, true
+	}
+
+	s := u.loadState()
+	if s == nil || s.LastCheck.IsZero() || s.LastCheckRelease != "" || time.Since(s.LastCheck) >= u.MinCheckInterval {
+		return nil, false
+	}
+	u.logf("Last check was %v ago, not checking again", time.Since(s.LastCheck).Round(time.Second))
+	return nil, true
+}
+
+// rememberCheck remembers release, the result of a successful check, to
+// throttle the next checks.
+func (u *Updater) rememberCheck(release Release) {
+	if u.MinCheckInterval <= 0 {
+		return
+	}
+
+	u.mutex.Lock()
+	u.checkResult, u.checkCached = release, true
+	u.mutex.Unlock()
+
+	err := u.updateState(func(s *State) {
+		s.LastCheckRelease = ""
+		if release != nil {
+			s.LastCheckRelease = release.Identifier()
+		}
+	})
+	if err != nil {
+		u.logf("Could not remember the last check: %v", err)
+	}
+}
+
+// forgetCheck forgets the release of the last check if it has the given
+// identifier, e.g. because it was applied.
+func (u *Updater) forgetCheck(identifier string) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	if u.checkResult != nil && u.checkResult.Identifier() == identifier {
+		u.checkResult = nil
+	}
+}
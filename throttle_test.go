@@ -0,0 +1,106 @@
+package updater
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdaterMinCheckInterval(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testing-")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	queries := 0
+	v2 := &testRelease{name: "v2", identifier: "2"}
+	latest := Release(v2)
+	app := &testApp{
+		FQuery:         func() error { queries++; return nil },
+		FLatestRelease: func() Release { return latest },
+	}
+	store := NewFileStateStore(filepath.Join(dir, "state.json"))
+	newUpdater := func(current string) *Updater {
+		return &Updater{
+			App:                      app,
+			CurrentReleaseIdentifier: current,
+			WriterForAsset: func(Asset) (AbortWriter, error) {
+				return NewAbortBuffer(nil), nil
+			},
+			State:            store,
+			MinCheckInterval: time.Hour,
+		}
+	}
+
+	// Cached update
+	{
+		u := newUpdater("1")
+		for i := 0; i < 3; i++ {
+			r, err := u.Check()
+			require.Nil(t, err)
+			assert.Equal(t, v2, r)
+		}
+		assert.Equal(t, 1, queries)
+
+		// The release is queried again after a restart
+		r, err := newUpdater("1").Check()
+		require.Nil(t, err)
+		assert.Equal(t, v2, r)
+		assert.Equal(t, 2, queries)
+	}
+
+	// Applied update is forgotten
+	{
+		queries = 0
+		u := newUpdater("1")
+		err := u.UpdateTo(nil)
+		require.Nil(t, err, "Could not update: %v", err)
+
+		err = u.UpdateTo(nil)
+		assert.Equal(t, ErrUpToDate, err)
+		assert.Equal(t, 1, queries)
+	}
+
+	// Up to date after a restart
+	{
+		queries = 0
+		r, err := newUpdater("2").Check()
+		require.Nil(t, err)
+		assert.Nil(t, r)
+		assert.Equal(t, 0, queries)
+	}
+
+	// Interval elapsed
+	{
+		queries = 0
+		err := store.Save(&State{LastCheck: time.Now().Add(-2 * time.Hour)})
+		require.Nil(t, err)
+
+		u := newUpdater("1")
+		r, err := u.Check()
+		require.Nil(t, err)
+		assert.Equal(t, v2, r)
+		assert.Equal(t, 1, queries)
+
+		// Skipped release is forgotten
+		require.Nil(t, u.SkipRelease(v2))
+		r, err = u.Check()
+		require.Nil(t, err)
+		assert.Nil(t, r)
+		assert.Equal(t, 1, queries)
+	}
+
+	// Without throttling
+	{
+		queries = 0
+		u := newUpdater("1")
+		u.MinCheckInterval = 0
+		u.Check()
+		u.Check()
+		assert.Equal(t, 2, queries)
+	}
+}
@@ -0,0 +1,290 @@
+package updater
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+)
+
+// Transaction commits multiple delayed files together and keeps backups of the
+// files they replace.
+//
+// When a transaction is committed, every destination file that already exists
+// is backed up first. If one of the files cannot be committed, the files that
+// were already committed are restored. After a successful commit, Rollback
+// restores all original files, e.g. when the new version of the application
+// fails a health check.
+//
+// A transaction can only be committed once, unless it is the Transaction of
+// an Updater, which reuses it for every update.
+type Transaction struct {
+	// Suffix of backup file names. Defaults to ".bak".
+	BackupSuffix string
+
+	files     []*DelayedFile
+	backups   []string
+	committed bool
+	done      bool
+}
+
+// NewTransaction creates a new transaction.
+func NewTransaction() *Transaction {
+	return &Transaction{}
+}
+
+// File creates a new delayed file that is part of the transaction.
+//
+// The file is not committed when it is closed, but when the transaction is
+// committed.
+func (t *Transaction) File(path string) *DelayedFile {
+	f := NewDelayedFile(path)
+	f.tx = t
+	t.files = append(t.files, f)
+	return f
+}
+
+// Commit copies the contents of all files to their final destination.
+//
+// If any of the files was aborted, or one of the files cannot be committed,
+// all temporary files are deleted, the original files are restored and an
+// error is returned.
+func (t *Transaction) Commit() error {
+	if t.done {
+		return errors.New("The transaction was already finished.")
+	}
+	t.done = true
+
+	for _, f := range t.files {
+		if f.aborted {
+			t.discard(0)
+			return errors.New("The transaction contains aborted files.")
+		}
+	}
+
+	for i, f := range t.files {
+		backup, err := t.backup(f.path)
+		if err != nil {
+			t.restore()
+			t.discard(i)
+			return err
+		}
+		t.backups = append(t.backups, backup)
+
+		err = f.commit()
+		if err != nil {
+			t.restore()
+			t.discard(i + 1)
+			return err
+		}
+	}
+
+	t.committed = true
+	return nil
+}
+
+// Abort deletes all temporary files without committing them.
+func (t *Transaction) Abort() {
+	if t.done {
+		return
+	}
+	t.done = true
+
+	for _, f := range t.files {
+		f.Abort()
+	}
+	t.discard(0)
+}
+
+// Rollback restores the original files after the transaction was committed.
+//
+// Files that did not exist before the transaction are deleted.
+func (t *Transaction) Rollback() error {
+	if !t.committed {
+		return errors.New("The transaction was not committed.")
+	}
+	t.committed = false
+
+	return t.restore()
+}
+
+// RemoveBackups deletes the backups of the original files.
+//
+// Call this when the update was successful and no rollback is needed anymore.
+func (t *Transaction) RemoveBackups() error {
+	var firstErr error
+	for _, b := range t.backups {
+		if b == "" {
+			continue
+		}
+		if err := os.Remove(b); err != nil && !os.IsNotExist(err) && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	t.backups = nil
+	t.committed = false
+	return firstErr
+}
+
+// reset forgets the files of a finished transaction, so that it can be
+// committed again. The backups stay on disk until they are replaced or
+// removed.
+func (t *Transaction) reset() {
+	if !t.done {
+		return
+	}
+	t.files = nil
+	t.backups = nil
+	t.committed = false
+	t.done = false
+}
+
+// backup creates a backup of the file at path, if it exists.
+//
+// The path of the backup is returned, or an empty string if there was no file
+// to back up.
+func (t *Transaction) backup(path string) (string, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	backup := path + t.backupSuffix()
+	os.Remove(backup)
+
+	// A hard link is cheap, fall back to copying the file
+	if os.Link(path, backup) == nil {
+		return backup, nil
+	}
+
+	err = copyFile(path, backup, info.Mode())
+	if err != nil {
+		os.Remove(backup)
+		return "", err
+	}
+
+	return backup, nil
+}
+
+// restore moves all backups back in place, in reverse order.
+func (t *Transaction) restore() error {
+	var firstErr error
+	for i := len(t.backups) - 1; i >= 0; i-- {
+		f, backup := t.files[i], t.backups[i]
+
+		var err error
+		if backup == "" {
+			err = os.Remove(f.path)
+		} else {
+			err = f.renameFunc()(backup, f.path)
+		}
+
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	t.backups = nil
+	return firstErr
+}
+
+// discard deletes the temporary files of all files starting at index i.
+func (t *Transaction) discard(i int) {
+	for _, f := range t.files[i:] {
+		f.discard()
+	}
+}
+
+func (t *Transaction) backupSuffix() string {
+	if t.BackupSuffix != "" {
+		return t.BackupSuffix
+	}
+	return ".bak"
+}
+
+// AtomicGroup commits multiple delayed files together, when all of them are
+// closed.
+//
+// Closing a file of the group does not commit it. When the last file of the
+// group is closed, all files are committed, unless one of them was aborted.
+// If one of the files cannot be committed, the files that were already
+// committed are restored, so an update is never applied partially. Return the
+// files from WriterForAsset to only replace them after all assets were
+// downloaded and verified.
+//
+// Unlike a Transaction, the group does not keep backups after a successful
+// commit. A file can only be part of one group or transaction.
+type AtomicGroup struct {
+	tx     Transaction
+	mu     sync.Mutex
+	closed map[*DelayedFile]bool
+}
+
+// NewAtomicGroup creates a new group of files.
+func NewAtomicGroup() *AtomicGroup {
+	return &AtomicGroup{
+		tx:     Transaction{BackupSuffix: ".atomic-bak"},
+		closed: make(map[*DelayedFile]bool),
+	}
+}
+
+// Add adds delayed files to the group.
+//
+// Files must be added before any of the files of the group is closed.
+func (g *AtomicGroup) Add(files ...*DelayedFile) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, f := range files {
+		f.group = g
+		g.tx.files = append(g.tx.files, f)
+	}
+}
+
+// close marks f as closed, and commits or discards all files if it was the
+// last open file of the group.
+func (g *AtomicGroup) close(f *DelayedFile) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.closed[f] = true
+	if len(g.closed) < len(g.tx.files) || g.tx.done {
+		return nil
+	}
+
+	for _, f := range g.tx.files {
+		if f.aborted {
+			g.tx.Abort()
+			return nil
+		}
+	}
+
+	err := g.tx.Commit()
+	if err != nil {
+		return err
+	}
+	return g.tx.RemoveBackups()
+}
+
+// copyFile copies the file at src to dst, creating dst with the given mode.
+func copyFile(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,279 @@
+package updater
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readTestFile(t *testing.T, path string) string {
+	data, err := ioutil.ReadFile(path)
+	assert.Nil(t, err, "Could not read file: %v", err)
+	return string(data)
+}
+
+func TestTransaction(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testing-")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	existing := filepath.Join(dir, "existing")
+	created := filepath.Join(dir, "created")
+
+	// Commit and roll back
+	{
+		err := ioutil.WriteFile(existing, []byte("old contents"), 0755)
+		require.Nil(t, err)
+
+		tx := NewTransaction()
+		f1 := tx.File(existing)
+		f2 := tx.File(created)
+		f1.Write([]byte("new contents"))
+		f2.Write([]byte("created contents"))
+
+		// Closing does not commit
+		assert.Nil(t, f1.Close())
+		assert.Equal(t, "old contents", readTestFile(t, existing))
+
+		err = tx.Commit()
+		assert.Nil(t, err, "Could not commit: %v", err)
+		assert.Equal(t, "new contents", readTestFile(t, existing))
+		assert.Equal(t, "created contents", readTestFile(t, created))
+		assert.Equal(t, "old contents", readTestFile(t, existing+".bak"))
+
+		info, err := os.Stat(existing)
+		assert.Nil(t, err)
+		if info != nil {
+			assert.EqualValues(t, 0755, info.Mode())
+		}
+
+		err = tx.Commit()
+		assert.Error(t, err)
+
+		err = tx.Rollback()
+		assert.Nil(t, err, "Could not roll back: %v", err)
+		assert.Equal(t, "old contents", readTestFile(t, existing))
+		_, err = os.Stat(created)
+		assert.True(t, os.IsNotExist(err))
+		_, err = os.Stat(existing + ".bak")
+		assert.True(t, os.IsNotExist(err))
+
+		err = tx.Rollback()
+		assert.Error(t, err)
+	}
+
+	// Remove backups
+	{
+		tx := NewTransaction()
+		tx.BackupSuffix = ".old"
+		f := tx.File(existing)
+		f.Write([]byte("new contents"))
+
+		err := tx.Commit()
+		assert.Nil(t, err, "Could not commit: %v", err)
+		assert.Equal(t, "old contents", readTestFile(t, existing+".old"))
+
+		err = tx.RemoveBackups()
+		assert.Nil(t, err)
+		_, err = os.Stat(existing + ".old")
+		assert.True(t, os.IsNotExist(err))
+
+		err = tx.Rollback()
+		assert.Error(t, err)
+		assert.Equal(t, "new contents", readTestFile(t, existing))
+	}
+
+	// Aborted file
+	{
+		tx := NewTransaction()
+		f1 := tx.File(existing)
+		f2 := tx.File(created)
+		f1.Write([]byte("newer contents"))
+		f2.Write([]byte("created contents"))
+		f2.Abort()
+
+		err := tx.Commit()
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "aborted")
+		assert.Equal(t, "new contents", readTestFile(t, existing))
+		_, err = os.Stat(created)
+		assert.True(t, os.IsNotExist(err))
+		_, err = os.Stat(f1.buffer.Path)
+		assert.True(t, os.IsNotExist(err))
+	}
+
+	// Failing commit restores committed files
+	{
+		tx := NewTransaction()
+		f1 := tx.File(existing)
+		f2 := tx.File(filepath.Join(dir, "nonexisting", "file"))
+		f1.Write([]byte("newer contents"))
+		f2.Write([]byte("invalid destination"))
+
+		err := tx.Commit()
+		assert.Error(t, err)
+		assert.Equal(t, "new contents", readTestFile(t, existing))
+		_, err = os.Stat(f2.buffer.Path)
+		assert.True(t, os.IsNotExist(err))
+	}
+}
+
+func TestUpdaterRollback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testing-")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "myapp")
+	err = ioutil.WriteFile(path, []byte("old contents"), 0755)
+	require.Nil(t, err)
+
+	tx := NewTransaction()
+	u := &Updater{
+		Transaction: tx,
+		WriterForAsset: func(a Asset) (AbortWriter, error) {
+			return tx.File(path), nil
+		},
+	}
+
+	a := &testAsset{
+		write: func(w io.Writer) error {
+			_, err := w.Write([]byte("new contents"))
+			return err
+		},
+	}
+	err = u.UpdateTo(&testRelease{assets: []Asset{a}})
+	assert.Nil(t, err, "Could not update: %v", err)
+	assert.Equal(t, "new contents", readTestFile(t, path))
+
+	err = u.Rollback()
+	assert.Nil(t, err, "Could not roll back: %v", err)
+	assert.Equal(t, "old contents", readTestFile(t, path))
+
+	// Consecutive updates reuse the transaction
+	err = u.UpdateTo(&testRelease{assets: []Asset{a}})
+	assert.Nil(t, err, "Could not update: %v", err)
+	assert.Equal(t, "new contents", readTestFile(t, path))
+
+	a.write = func(w io.Writer) error {
+		_, err := w.Write([]byte("newer contents"))
+		return err
+	}
+	err = u.UpdateTo(&testRelease{assets: []Asset{a}})
+	assert.Nil(t, err, "Could not update again: %v", err)
+	assert.Equal(t, "newer contents", readTestFile(t, path))
+
+	err = u.Rollback()
+	assert.Nil(t, err, "Could not roll back: %v", err)
+	assert.Equal(t, "new contents", readTestFile(t, path))
+
+	// Without transaction
+	err = (&Updater{}).Rollback()
+	assert.Error(t, err)
+}
+
+func TestAtomicGroup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testing-")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	binary := filepath.Join(dir, "myapp")
+	config := filepath.Join(dir, "config.json")
+	err = ioutil.WriteFile(binary, []byte("old binary"), 0755)
+	require.Nil(t, err)
+
+	// Commit when the last file is closed
+	{
+		g := NewAtomicGroup()
+		f1, f2 := NewDelayedFile(binary), NewDelayedFile(config)
+		g.Add(f1, f2)
+		f1.Write([]byte("new binary"))
+		f2.Write([]byte("new config"))
+
+		assert.Nil(t, f1.Close())
+		assert.Equal(t, "old binary", readTestFile(t, binary))
+		_, err := os.Stat(config)
+		assert.True(t, os.IsNotExist(err))
+
+		err = f2.Close()
+		assert.Nil(t, err, "Could not commit: %v", err)
+		assert.Equal(t, "new binary", readTestFile(t, binary))
+		assert.Equal(t, "new config", readTestFile(t, config))
+		_, err = os.Stat(binary + ".atomic-bak")
+		assert.True(t, os.IsNotExist(err))
+
+		// Closing again does nothing
+		assert.Nil(t, f1.Close())
+	}
+
+	// Aborted file
+	{
+		g := NewAtomicGroup()
+		f1, f2 := NewDelayedFile(binary), NewDelayedFile(config)
+		g.Add(f1, f2)
+		f1.Write([]byte("newer binary"))
+		f2.Write([]byte("newer config"))
+		f2.Abort()
+
+		assert.Nil(t, f2.Close())
+		assert.Nil(t, f1.Close())
+		assert.Equal(t, "new binary", readTestFile(t, binary))
+		assert.Equal(t, "new config", readTestFile(t, config))
+		_, err = os.Stat(f1.buffer.Path)
+		assert.True(t, os.IsNotExist(err))
+	}
+
+	// Failing commit restores committed files
+	{
+		g := NewAtomicGroup()
+		f1 := NewDelayedFile(binary)
+		f2 := NewDelayedFile(filepath.Join(dir, "nonexisting", "file"))
+		g.Add(f1, f2)
+		f1.Write([]byte("newer binary"))
+		f2.Write([]byte("invalid destination"))
+
+		assert.Nil(t, f1.Close())
+		assert.Error(t, f2.Close())
+		assert.Equal(t, "new binary", readTestFile(t, binary))
+	}
+
+	// Updater with a failing asset
+	{
+		g := NewAtomicGroup()
+		var files []*DelayedFile
+		u := &Updater{
+			WriterForAsset: func(a Asset) (AbortWriter, error) {
+				f := NewDelayedFile(filepath.Join(dir, a.Name()))
+				g.Add(f)
+				files = append(files, f)
+				return f, nil
+			},
+		}
+
+		writeErr := errors.New("Connection reset.")
+		a1 := &testAsset{
+			name: "myapp",
+			write: func(w io.Writer) error {
+				_, err := w.Write([]byte("newer binary"))
+				return err
+			},
+		}
+		a2 := &testAsset{
+			name:  "config.json",
+			write: func(w io.Writer) error { return writeErr },
+		}
+		err := u.UpdateTo(&testRelease{assets: []Asset{a1, a2}})
+		assert.Error(t, err)
+
+		for _, f := range files {
+			assert.Nil(t, f.Close())
+		}
+		assert.Equal(t, "new binary", readTestFile(t, binary))
+		assert.Equal(t, "new config", readTestFile(t, config))
+	}
+}
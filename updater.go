@@ -29,9 +29,21 @@
 //
 package updater
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+)
 
 // Updater is used to directly update the application.
+//
+// An updater is safe for concurrent use once it is configured. Concurrent
+// checks share a single query of the application, and updates are applied one
+// at a time.
 type Updater struct {
 	// Application to update.
 	App App
@@ -39,9 +51,23 @@ type Updater struct {
 	// Identifier of the current release.
 	//
 	// If the identifier of the latest release differs the current release
-	// identifier, the updater will update the application.
+	// identifier, the updater will update the application. Use
+	// BuildIdentifier to take it from the build information of the
+	// executable.
 	CurrentReleaseIdentifier string
 
+	// Whether the current release is identified by the checksum of the
+	// running executable, see CurrentChecksum.
+	//
+	// When set, every check looks for the release whose executable asset,
+	// the asset that SelfUpdate would install, has the same SHA-256 checksum
+	// as the running executable, so no identifier needs to be built into the
+	// application. The checksums are taken from assets that implement
+	// ChecksumAsset, like the assets of a manifest, or from the checksum
+	// asset of the latest release, see ChecksumAssetName. If no release
+	// matches, the CurrentReleaseIdentifier is used.
+	IdentifyByChecksum bool
+
 	// Function to map assets to a writer.
 	//
 	// When the app is updated, this function will be called for each asset
@@ -49,7 +75,324 @@ type Updater struct {
 	// io.Writer.
 	//
 	// You can return nil to ignore the asset.
+	//
+	// If the update fails, e.g. because a download fails halfway or the
+	// context is cancelled, all writers are aborted and closed if they
+	// implement io.Closer, so that closing a DelayedFile afterwards does not
+	// commit a partially written file.
 	WriterForAsset func(Asset) (AbortWriter, error)
+
+	// Function to map assets to a writer, given the release they belong to.
+	//
+	// It is used instead of WriterForAsset when set, so that the destination
+	// of an asset can depend on the release, e.g. to store every version in a
+	// directory named after the release. Like WriterForAsset, it can return nil
+	// to ignore the asset.
+	WriterForReleaseAsset func(release Release, asset Asset) (AbortWriter, error)
+
+	// Function to select the assets to update.
+	//
+	// When set, only assets for which this function returns true are passed
+	// to WriterForAsset. Use DefaultPlatformFilter to select the assets built
+	// for the current platform.
+	AssetFilter func(Asset) bool
+
+	// Name of the asset containing SHA-256 checksums of the other assets.
+	//
+	// When set, the updater first downloads this asset, which should be in the
+	// format produced by sha256sum (e.g. checksums.txt or SHA256SUMS). Every
+	// asset that is written is then verified against it. If an asset has no
+	// checksum or its checksum does not match, all writers are aborted and the
+	// update fails.
+	ChecksumAssetName string
+
+	// Verifier used to verify detached signatures of assets.
+	//
+	// When set, every asset that is written must have a signature asset in the
+	// release, named after the asset with SignatureSuffix appended. If a
+	// signature is missing or invalid, all writers are aborted and the update
+	// fails. The checksum asset is verified as well.
+	Verifier Verifier
+
+	// Suffix of signature asset names. Defaults to ".sig".
+	SignatureSuffix string
+
+	// Name of the asset introducing a new signing key, e.g. "signing-key.pub".
+	//
+	// When set and a release contains this asset, its signature must be made
+	// with a key that is already trusted. The key is then added to the
+	// Verifier, which must be a *Keyring, before the other assets are
+	// verified. This allows to sign releases with a new key while older
+	// versions of the application only know the old one.
+	KeyAssetName string
+
+	// Function called to report the progress of writing an asset.
+	//
+	// It is called whenever data of an asset is written, with the number of
+	// bytes written so far. The total is the size of the asset, or -1 if the
+	// size is not known.
+	Progress func(asset Asset, written, total int64)
+
+	// Function to validate the written assets before they are committed.
+	//
+	// It is called after all assets were written successfully, with the paths
+	// of the temporary files that the writers staged, e.g. the temporary file
+	// of a DelayedFile. The staged files already have the permissions of
+	// their destination, so this can be used to check that a new executable
+	// runs. If it returns an error, all writers are aborted and the update
+	// fails.
+	Validate func(release Release, paths []string) error
+
+	// Custom verification steps for every asset, see AssetVerification.
+	//
+	// The verifications receive a copy of every asset while it is written,
+	// and verify it after the checksum and signature, before the release is
+	// validated and committed. Use BufferCheck to verify assets in memory.
+	Checks []AssetCheck
+
+	// Transaction used to commit the written files.
+	//
+	// When set, the transaction is committed after all assets have been
+	// written successfully, and aborted otherwise. Return files created with
+	// Transaction.File from WriterForAsset. Use Rollback to restore the
+	// original files afterwards.
+	//
+	// The transaction is reused for every update: its files are forgotten
+	// when the next update starts, so Rollback only restores the files of
+	// the last update.
+	Transaction *Transaction
+
+	// Policy for retrying failed asset downloads. By default, a failed
+	// download fails the update.
+	Retry RetryPolicy
+
+	// Name of patch assets used by SelfUpdate for delta updates.
+	//
+	// The placeholders {old} and {new} are replaced by the identifiers of the
+	// current and the new release, e.g. "myapp_{old}_{new}.patch". When the
+	// release contains such a patch in the bsdiff format, it is applied to the
+	// running executable instead of downloading the full executable. The
+	// result is verified against the checksum of the full executable, so
+	// ChecksumAssetName must be set too. If the patch cannot be applied, the
+	// full executable is downloaded.
+	PatchAssetName string
+
+	// Maximum number of assets that are written at the same time.
+	//
+	// By default, assets are written one after another. When assets are
+	// written concurrently, WriterForAsset must return a different writer for
+	// every asset, and Progress may be called concurrently. If one asset
+	// fails, the others are cancelled and all writers are aborted.
+	Concurrency int
+
+	// HTTP client used to download assets, e.g. to use a proxy or custom
+	// certificate authorities. Defaults to http.DefaultClient.
+	//
+	// It is also used to query applications that do not have a client of
+	// their own. Applications and assets that use an API client, like the
+	// client passed to NewGitHub, keep using that client.
+	HTTPClient *http.Client
+
+	// Maximum number of bytes per second used to write assets.
+	//
+	// When set, writing assets is slowed down so that background updates do
+	// not saturate the connection of the user. The limit applies to all assets
+	// together. By default, assets are written as fast as possible.
+	RateLimit int64
+
+	// Number of connections used to download large assets.
+	//
+	// When larger than one, assets downloaded over HTTP that are larger than
+	// DownloadChunkSize are downloaded in chunks with ranged requests over
+	// this many connections, which is much faster on high-latency links. The
+	// chunks are stored in a temporary file and written to the writer of the
+	// asset once they have all been downloaded. Assets on servers that do not
+	// support ranged requests are downloaded over a single connection.
+	// Ignored when RateLimit is set.
+	DownloadConnections int
+
+	// Size of the chunks of assets downloaded over multiple connections.
+	// Defaults to 8 MiB.
+	DownloadChunkSize int64
+
+	// Maximum size of an asset in bytes.
+	//
+	// When set, an asset whose declared size, e.g. the Content-Length of its
+	// download, exceeds the limit fails the update before it is written, and
+	// writing fails as soon as more data than the limit is received. By
+	// default, the size of assets is not limited.
+	MaxAssetSize int64
+
+	// Whether to check that the destinations have enough free space for the
+	// assets before writing them.
+	//
+	// When set, the sizes of the assets that implement AssetMeta are added up
+	// per directory that the writers store their data in, e.g. the temporary
+	// directory of a DelayedFile, and the update fails with an
+	// *InsufficientSpaceError before anything is downloaded if a file system
+	// has less space available. Destinations whose free space cannot be
+	// determined are not checked.
+	CheckDiskSpace bool
+
+	// Observer notified of the stages of checking for and applying updates.
+	Observer Observer
+
+	// Logger that receives debug messages, e.g. the URLs that are
+	// downloaded. By default, nothing is logged.
+	Logger Logger
+
+	// Range of versions to update to, e.g. ">=1.2.0 <2.0.0" or "^1.2.0".
+	//
+	// When set, the updater proposes the highest release whose name is a
+	// semantic version in the range, instead of the latest release. Releases
+	// that are not newer than the current release are never proposed. Set it
+	// to prevent automatic updates to a new major version. All releases are
+	// only considered if the app implements ReleasesApp.
+	Constraint string
+
+	// Allow UpdateToVersion to apply a release that is older than the
+	// current release, e.g. to roll a fleet back to a previous version. By
+	// default, such an update fails with ErrDowngrade.
+	AllowDowngrade bool
+
+	// Function returning mirror URLs of an asset, e.g. on a CDN.
+	//
+	// When it returns URLs for an asset, the asset is downloaded from the
+	// first URL that works, in order. If all of them fail, the asset is
+	// downloaded from its own source. When a download fails halfway, the next
+	// one continues where it stopped, so make sure all mirrors serve the same
+	// file, e.g. with ChecksumAssetName.
+	MirrorResolver func(Asset) []string
+
+	// Store for the state of the updater, e.g. a FileStateStore.
+	//
+	// When set, the updater records the time of every successful check and
+	// the last release that was applied, and releases skipped with
+	// SkipRelease are no longer proposed. An update whose files were
+	// installed succeeds even if the state cannot be saved afterwards.
+	State StateStore
+
+	// Minimum time between two checks for updates, e.g. to stay within the
+	// rate limit of an API. By default, every check queries the application.
+	//
+	// When Check is called sooner, it returns the result of the last check
+	// without querying the application. The last check is remembered in the
+	// StateStore, so that a check right after a restart is throttled too,
+	// unless the last check found an update: its release is queried again.
+	MinCheckInterval time.Duration
+
+	// Cache of verified assets, so that assets whose checksum is known are
+	// not downloaded again, e.g. when a release is applied again after a
+	// rollback. By default, assets are not cached.
+	AssetCache *AssetCache
+
+	// Elevator used by SelfUpdate to replace an executable in a directory the
+	// current user cannot write to, e.g. DefaultElevator().
+	//
+	// When nil, SelfUpdate fails if the directory of the executable is not
+	// writable.
+	Elevator Elevator
+
+	// Whether Check and UpdateTo refuse to update an application that was
+	// installed by a package manager, e.g. apt or Homebrew.
+	//
+	// When set, they return an *ErrExternallyManaged if the running
+	// executable is managed by a package manager, so the application can ask
+	// the user to update it with that package manager instead.
+	DetectPackageManager bool
+
+	// Installer used by UpdateTo instead of WriterForAsset, for applications
+	// distributed as installers, e.g. MSI packages.
+	//
+	// The assets are downloaded to a temporary directory, and the installer
+	// is launched once all of them were written and validated successfully.
+	Installer *Installer
+
+	// Installer used by UpdateTo instead of WriterForAsset, for applications
+	// that consist of several files, see DirInstaller.
+	//
+	// Every release is installed in a directory of its own, and the current
+	// release is switched when all assets were written and validated. The
+	// Installer and Transaction are not used.
+	DirInstaller *DirInstaller
+
+	// Stable identifier of the machine, used for staged rollouts.
+	//
+	// When a release is rolled out to a part of the clients only, see
+	// RolloutPercentage, the identifier is hashed to decide whether the
+	// client is one of them. Check returns nil for clients that are not, as
+	// if they were up to date. Defaults to a random identifier that is kept
+	// in the State, see InstallationID. Clients without either only update
+	// to releases that are rolled out to all clients.
+	MachineID string
+
+	// Whether the installation identifier is sent to the update server, e.g.
+	// to count the installations that update.
+	//
+	// When set, the HTTP requests of the updater and of applications like
+	// NewHTTPManifest send the identifier in the InstallationIDHeader, and
+	// applications can read it with InstallationIDFromContext. Check fails if
+	// there is no identifier, see InstallationID.
+	SendInstallationID bool
+
+	// Reporter that receives the outcome of every check and update, e.g. a
+	// reporter created with NewHTTPReporter.
+	Reporter Reporter
+
+	// Metrics that measure every check and update, e.g. a Collector of the
+	// metrics package to export them to Prometheus.
+	Metrics Metrics
+
+	// Strategy used to restart the application after SelfUpdate replaced its
+	// executable, e.g. a SystemdRestart for a daemon.
+	//
+	// By default, SelfUpdate does not restart the application, and
+	// ApplyStagedIfAny restarts it with ExecRestart.
+	Restart RestartStrategy
+
+	// Directory in which Download stages releases, so that Apply can apply
+	// them later, also after the application was restarted. Download fails
+	// when it is not set.
+	StageDir string
+
+	// Path of the file that is locked while an update is applied, so that
+	// two processes, e.g. two instances of the application, do not apply
+	// updates to the same destination at once. ErrUpdateInProgress is
+	// returned if another process holds the lock.
+	//
+	// Defaults to a file next to the destination: next to the executable for
+	// SelfUpdate, in the directory of the DirInstaller or of SyncBundle, and
+	// next to the destination of every DelayedFile that WriterForAsset or an
+	// Installer returns, with the .lock extension. These are held while the
+	// assets are written and validated, a DelayedFile that is closed after
+	// UpdateTo returned is committed without the lock. The file is removed
+	// when the lock is released. When it cannot be created, a file in a
+	// directory private to the current user is locked instead.
+	LockFile string
+
+	mutex              sync.Mutex
+	report             *UpdateReport
+	checking           *checkCall
+	checksumIdentifier string
+	lastCheck          time.Time
+	checkResult        Release
+	checkCached        bool
+	lastUpdate         time.Time
+	lastRelease        Release
+
+	// updating is held while an update is applied.
+	updating sync.Mutex
+}
+
+// checkCall is a check in progress, shared by concurrent calls to Check.
+type checkCall struct {
+	done    chan struct{}
+	release Release
+	err     error
+
+	// Whether the check failed because its caller was cancelled, or did not
+	// finish because it panicked. Waiting callers check again.
+	abandoned bool
 }
 
 // Check will check for updates.
@@ -57,69 +400,556 @@ type Updater struct {
 // When an update is available, it will return the release for this update. You
 // can use it to inform the user about the update.
 //
-// When the application is already up to date, nil is returned.
+// When the application is already up to date, nil is returned. When the
+// application has no releases, ErrNoRelease is returned.
 func (u *Updater) Check() (Release, error) {
+	return u.CheckContext(context.Background())
+}
+
+// CheckContext is like Check but aborts when ctx is cancelled.
+//
+// If another check is in progress, CheckContext waits for it and returns its
+// result instead of querying the application again, unless that check fails
+// because its own context was cancelled. The same goes for the last check if
+// it is more recent than MinCheckInterval.
+func (u *Updater) CheckContext(ctx context.Context) (Release, error) {
+	if r, ok := u.throttledCheck(); ok {
+		return r, nil
+	}
+
+	u.mutex.Lock()
+	for u.checking != nil {
+		c := u.checking
+		u.mutex.Unlock()
+		select {
+		case <-c.done:
+			if !c.abandoned {
+				return c.release, c.err
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		u.mutex.Lock()
+	}
+	c := &checkCall{done: make(chan struct{}), abandoned: true}
+	u.checking = c
+	u.mutex.Unlock()
+	defer func() {
+		u.mutex.Lock()
+		u.checking = nil
+		u.mutex.Unlock()
+		close(c.done)
+	}()
+
+	started := time.Now()
+	c.release, c.err = u.check(ctx)
+	c.abandoned = c.err != nil && ctx.Err() != nil
+	if c.err == nil {
+		u.rememberCheck(c.release)
+	}
+	u.reportOutcome(ctx, OutcomeCheck, started, c.release, nil, c.err)
+	return c.release, c.err
+}
+
+// check checks for updates, see CheckContext.
+func (u *Updater) check(ctx context.Context) (Release, error) {
+	ctx = withLogger(withHTTPClient(ctx, u.HTTPClient), u.Logger)
+	u.observer().OnCheckStart()
+
+	ctx, err := u.withInstallationID(ctx)
+	if err != nil {
+		return nil, u.reportError(err)
+	}
+
+	err = u.checkManaged()
+	if err != nil {
+		return nil, u.reportError(err)
+	}
+
 	// Query app information
-	err := u.App.Query()
+	u.logf("Querying releases")
+	err = queryApp(ctx, u.App)
 	if err != nil {
-		return nil, err
+		u.logf("Could not query releases: %v", err)
+		return nil, u.reportError(err)
+	}
+
+	// Remember when the releases were checked
+	now := time.Now()
+	u.mutex.Lock()
+	u.lastCheck = now
+	u.mutex.Unlock()
+	var state *State
+	err = u.updateState(func(s *State) {
+		s.LastCheck = now
+		state = s
+	})
+	if err != nil {
+		return nil, u.reportError(err)
 	}
 
 	// Get the latest available release
 	r := u.App.LatestRelease()
 	if r == nil {
-		return nil, errors.New("No release information was found.")
+		return nil, u.reportError(ErrNoRelease)
+	}
+
+	err = u.identifyCurrent(ctx)
+	if err != nil {
+		return nil, u.reportError(err)
+	}
+	current := u.currentIdentifier()
+
+	// Only consider releases allowed by the constraint
+	if u.Constraint != "" {
+		r, err = u.constrainedRelease()
+		if err != nil {
+			return nil, u.reportError(err)
+		} else if r == nil {
+			u.logf("No newer release matches %v", u.Constraint)
+			return nil, nil
+		}
+	}
+	u.logf("Latest release is %v (%v), current release is %v", r.Name(), r.Identifier(), current)
+
+	// Older versions may have to update to an intermediate release first
+	r, err = u.upgradePath(r)
+	if err != nil {
+		return nil, u.reportError(err)
 	}
 
 	// Check if the release is newer
-	if r.Identifier() == u.CurrentReleaseIdentifier {
+	if r.Identifier() == current {
+		return nil, nil
+	}
+
+	if state != nil && state.Skipped(r.Identifier()) && !IsMandatory(r) {
+		u.logf("Release %v was skipped", r.Name())
+		return nil, nil
+	}
+
+	if !u.inRollout(r) {
+		u.logf("Release %v is not rolled out to this client yet", r.Name())
 		return nil, nil
 	}
 
 	// Return the latest release
+	u.observer().OnReleaseFound(r)
 	return r, nil
 }
 
 // UpdateTo will update the application.
 //
 // If you don't specify a release, the updater will first fetch all releases and
-// try to update to the most recent one.
+// try to update to the most recent one. ErrUpToDate is returned if there is no
+// newer release.
+//
+// If an asset cannot be written, an *AssetDownloadError is returned. Use
+// Report to find out what the update did, also when it failed.
 func (u *Updater) UpdateTo(release Release) error {
+	return u.UpdateToContext(context.Background(), release)
+}
+
+// UpdateToContext is like UpdateTo but aborts when ctx is cancelled.
+//
+// When ctx is cancelled while an asset is being written, all writers are
+// aborted and the context error is returned. If another update is being
+// applied, UpdateToContext waits for it to finish first.
+func (u *Updater) UpdateToContext(ctx context.Context, release Release) error {
+	u.updating.Lock()
+	defer u.updating.Unlock()
+
 	if release == nil {
 		var err error
-		release, err = u.Check()
+		release, err = u.CheckContext(ctx)
 		if err != nil {
 			return err
 		}
 		if release == nil {
-			return errors.New("The application is already up to date.")
+			return ErrUpToDate
 		}
+	} else if err := u.checkManaged(); err != nil {
+		u.reportOutcome(ctx, OutcomeApply, time.Now(), release, nil, err)
+		return u.reportError(err)
 	}
 
-	writers := make([]AbortWriter, 0)
-	var abort = func() {
-		for _, w := range writers {
-			w.Abort()
+	rec := newReportRecorder(u, release)
+	err := u.updateTo(withReportRecorder(ctx, rec), release)
+	report := rec.finish(err)
+	u.setReport(report)
+	u.reportOutcome(ctx, OutcomeApply, report.StartedAt, release, report, err)
+	return err
+}
+
+// updateTo writes the assets of release and commits them.
+func (u *Updater) updateTo(ctx context.Context, release Release) error {
+	var lockPath string
+	if u.DirInstaller != nil {
+		lockPath = filepath.Join(u.DirInstaller.Dir, lockFileName)
+	}
+	unlock, err := u.lock(lockPath)
+	if err != nil {
+		return u.reportError(err)
+	}
+	defer unlock()
+
+	if u.Transaction != nil {
+		u.Transaction.reset()
+	}
+
+	if u.DirInstaller != nil {
+		err := u.installDir(ctx, release, u.DirInstaller)
+		if err != nil {
+			return u.reportError(err)
+		}
+		u.appliedRelease(release)
+		return nil
+	}
+
+	writerFor := u.WriterForAsset
+	if u.Installer != nil {
+		writerFor = u.Installer.WriterForAsset
+	} else if u.WriterForReleaseAsset != nil {
+		writerFor = func(a Asset) (AbortWriter, error) {
+			return u.WriterForReleaseAsset(release, a)
+		}
+	}
+	if u.LockFile == "" {
+		locks := &destinationLocks{u: u}
+		defer locks.unlock()
+		writerFor = locks.writerFor(writerFor)
+	}
+
+	// Keep track of all writers, also those of a failed update, which
+	// writeAssets does not return
+	var opened []AbortWriter
+	writers, err := u.writeAssets(ctx, release, u.AssetFilter, func(a Asset) (AbortWriter, error) {
+		w, err := writerFor(a)
+		if w != nil {
+			opened = append(opened, w)
+		}
+		return w, err
+	})
+	if err == nil {
+		err = u.validate(release, writers)
+	}
+	if err == nil {
+		u.observer().OnApply(release)
+	}
+
+	if u.Installer != nil && err == nil {
+		// Launch the installers, but no more after one failed
+		for i, w := range writers {
+			if err = w.(io.Closer).Close(); err != nil {
+				discardWriters(writers[i+1:])
+				break
+			}
+		}
+	}
+
+	if err != nil && u.Installer == nil {
+		discardWriters(opened)
+	}
+
+	if u.Transaction != nil {
+		if err != nil {
+			u.logf("Aborting transaction: %v", err)
+			u.Transaction.Abort()
+			return u.reportError(err)
 		}
+		u.logf("Committing %v files of transaction", len(u.Transaction.files))
+		err = u.Transaction.Commit()
+	}
+
+	if err != nil {
+		return u.reportError(err)
+	}
+	u.appliedRelease(release)
+	return nil
+}
+
+// Rollback restores the files replaced by the last update.
+//
+// It can only be used if the update was applied with a Transaction.
+func (u *Updater) Rollback() error {
+	if u.Transaction == nil {
+		return errors.New("No transaction to roll back.")
 	}
 
+	return u.Transaction.Rollback()
+}
+
+// writeAssets writes all assets of release accepted by filter to the writers
+// returned by writerFor, verifying them as configured.
+//
+// The writers are returned when all assets were written successfully. On
+// failure, all writers are aborted.
+func (u *Updater) writeAssets(
+	ctx context.Context,
+	release Release,
+	filter func(Asset) bool,
+	writerFor func(Asset) (AbortWriter, error),
+) ([]AbortWriter, error) {
+	ctx = withLogger(withHTTPClient(ctx, u.HTTPClient), u.Logger)
+	ctx, err := u.withInstallationID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if u.RateLimit <= 0 {
+		ctx = withParallelDownload(ctx, u.DownloadConnections, u.DownloadChunkSize)
+	}
+
+	if u.Verifier != nil && u.KeyAssetName != "" {
+		err := u.introduceKey(ctx, release)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var checksums map[string][]byte
+	if u.ChecksumAssetName != "" {
+		var err error
+		checksums, err = u.fetchChecksums(ctx, release)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Get a writer for every asset
+	var assets []Asset
+	writers := make([]AbortWriter, 0)
 	for _, a := range release.Assets() {
-		w, err := u.WriterForAsset(a)
+		if filter != nil && !filter(a) {
+			continue
+		}
+
+		w, err := writerFor(a)
 		if err != nil {
-			abort()
-			return err
+			abortWriters(writers)
+			return nil, err
 		}
 
+		if w == nil {
+			continue
+		}
+		assets = append(assets, a)
 		writers = append(writers, w)
+	}
 
-		if w != nil {
-			err := a.Write(w)
+	recorder(ctx).selectAssets(assets)
+
+	err = u.checkSizes(assets, writers)
+	if err != nil {
+		abortWriters(writers)
+		return nil, err
+	}
+
+	// Write the assets
+	var limiter *rateLimiter
+	if u.RateLimit > 0 {
+		limiter = newRateLimiter(u.RateLimit)
+	}
+	err = forEach(ctx, len(assets), u.Concurrency, func(ctx context.Context, i int) error {
+		return u.writeVerified(ctx, release, checksums, limiter, assets[i], writers[i])
+	})
+	if err != nil {
+		abortWriters(writers)
+		return nil, err
+	}
+
+	return writers, nil
+}
+
+// writeVerified writes asset a of release to w and verifies it as
+// configured. Writing is limited by limiter if it is not nil.
+func (u *Updater) writeVerified(
+	ctx context.Context,
+	release Release,
+	checksums map[string][]byte,
+	limiter *rateLimiter,
+	a Asset,
+	w io.Writer,
+) error {
+	// Stream the asset to the writer and its verifications
+	verifications := u.verifications(ctx, release, checksums, a)
+	dst := []io.Writer{w}
+	for _, v := range verifications {
+		dst = append(dst, v)
+	}
+
+	counter := &countingWriter{w: io.MultiWriter(dst...)}
+	var out io.Writer = counter
+	if limiter != nil {
+		out = limiter.writer(ctx, out)
+	}
+	if u.Progress != nil {
+		out = newProgressWriter(a, out, u.Progress)
+	}
+	if u.MaxAssetSize > 0 {
+		out = newSizeLimitWriter(a, out, u.MaxAssetSize)
+	}
+
+	u.observer().OnAssetStart(a)
+	u.logf("Writing asset %v", a.Name())
+	ctx = limitParallelDownload(ctx, a, u.MaxAssetSize)
+	commit, err := u.writeCached(ctx, checksums, a, out)
+	var results []VerificationReport
+	for _, v := range verifications {
+		if err != nil {
+			break
+		}
+		err = v.Verify()
+		results = append(results, VerificationReport{
+			Name:   verificationName(v),
+			Passed: err == nil,
+			Error:  errorString(err),
+		})
+	}
+	commit(err)
+	if err != nil {
+		u.logf("Could not write asset %v: %v", a.Name(), err)
+	}
+	recorder(ctx).finishAsset(a, counter.n, results, err)
+	u.observer().OnAssetFinish(a, err)
+
+	return err
+}
+
+// forEach calls f for the numbers 0 to n-1, running at most limit calls at the
+// same time, or one if limit is smaller than one.
+//
+// When a call fails, no new calls are started and the context of the running
+// calls is cancelled. The first error is returned.
+func forEach(ctx context.Context, n, limit int, f func(ctx context.Context, i int) error) error {
+	if limit < 1 {
+		limit = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+	sem := make(chan struct{}, limit)
+	for i := 0; i < n; i++ {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			err := f(ctx, i)
 			if err != nil {
-				abort()
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+				cancel()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if firstErr == nil {
+		firstErr = ctx.Err()
+	}
+	return firstErr
+}
+
+// validate calls the validation function with the staged files of writers and
+// aborts the writers if the validation fails.
+func (u *Updater) validate(release Release, writers []AbortWriter) error {
+	if u.Validate == nil {
+		return nil
+	}
+
+	var paths []string
+	for _, w := range writers {
+		if s, ok := w.(stager); ok {
+			if err := s.stage(); err != nil {
+				abortWriters(writers)
 				return err
 			}
 		}
+		if s, ok := w.(interface{ TempPath() string }); ok && s.TempPath() != "" {
+			paths = append(paths, s.TempPath())
+		}
 	}
 
-	return nil
+	err := u.Validate(release, paths)
+	if err != nil {
+		abortWriters(writers)
+	}
+	return err
+}
+
+// abortWriters aborts all writers.
+func abortWriters(writers []AbortWriter) {
+	for _, w := range writers {
+		w.Abort()
+	}
+}
+
+// discardWriters aborts all writers and closes those that can be closed, so
+// that a writer that is closed by the caller afterwards, like a DelayedFile,
+// does not commit a partially written asset, and its temporary files are
+// removed right away.
+func discardWriters(writers []AbortWriter) {
+	abortWriters(writers)
+	for _, w := range writers {
+		if c, ok := w.(io.Closer); ok {
+			c.Close()
+		}
+	}
+}
+
+// queryApp queries app, using the context when the app supports it.
+func queryApp(ctx context.Context, app App) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if c, ok := app.(ContextApp); ok {
+		return c.QueryContext(ctx)
+	}
+	return app.Query()
+}
+
+// writeAsset writes a to w, using the context when the asset supports it.
+//
+// Assets that implement AssetReader are read, unless they may be downloaded
+// over multiple connections.
+func writeAsset(ctx context.Context, a Asset, w io.Writer) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	_, parallel := ctx.Value(parallelDownloadKey{}).(parallelDownload)
+	if r, ok := a.(AssetReader); ok && !(parallel && isContextAsset(a)) {
+		rc, size, err := r.Open(ctx)
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+
+		if t, ok := w.(totalSetter); ok && size >= 0 {
+			t.setTotal(size)
+		}
+		_, err = io.Copy(w, rc)
+		return err
+	}
+
+	if c, ok := a.(ContextAsset); ok {
+		return c.WriteContext(ctx, w)
+	}
+	return a.Write(w)
 }
@@ -1,9 +1,15 @@
 package updater
 
 import (
+	"context"
 	"errors"
 	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -36,8 +42,7 @@ func TestUpdaterCheck(t *testing.T) {
 
 		r, err := u.Check()
 		assert.Nil(t, r)
-		assert.Error(t, err)
-		assert.Contains(t, err.Error(), "No release info")
+		assert.Equal(t, ErrNoRelease, err)
 	}
 
 	// Release identifiers match or differ
@@ -83,7 +88,7 @@ func TestUpdaterUpdateWithoutRelease(t *testing.T) {
 		u.CurrentReleaseIdentifier = "new-release"
 		err := u.UpdateTo(nil)
 		assert.Error(t, err)
-		assert.Contains(t, err.Error(), "already up to date")
+		assert.Equal(t, ErrUpToDate, err)
 	}
 
 	// Without error
@@ -160,7 +165,7 @@ func TestUpdaterUpdateWithRelease(t *testing.T) {
 	// Error writer
 	{
 		err := u.UpdateTo(&testRelease{assets: []Asset{a3}})
-		assert.Equal(t, writeErr, err)
+		assert.Equal(t, &AssetDownloadError{Asset: a3, Cause: writeErr}, err)
 		assert.Equal(t, 0, errorWriter.Buffer.Len())
 		assert.True(t, errorWriter.aborted)
 	}
@@ -172,6 +177,212 @@ func TestUpdaterUpdateWithRelease(t *testing.T) {
 	}
 }
 
+func TestUpdaterAssetFilter(t *testing.T) {
+	a1 := &testAsset{name: "myapp_linux_amd64"}
+	a2 := &testAsset{name: "myapp_windows_amd64.exe"}
+
+	var seen []Asset
+	u := &Updater{
+		AssetFilter: PlatformFilter("linux", "amd64"),
+		WriterForAsset: func(a Asset) (AbortWriter, error) {
+			seen = append(seen, a)
+			return nil, nil
+		},
+	}
+
+	err := u.UpdateTo(&testRelease{assets: []Asset{a1, a2}})
+	assert.Nil(t, err)
+	assert.Equal(t, []Asset{a1}, seen)
+}
+
+func TestUpdaterWriterForReleaseAsset(t *testing.T) {
+	a := &testAsset{
+		name: "myapp",
+		write: func(w io.Writer) error {
+			_, err := io.WriteString(w, "Hello World!")
+			return err
+		},
+	}
+	r := &testRelease{name: "v1.2.0", assets: []Asset{a}}
+
+	writers := make(map[string]*AbortBuffer)
+	u := &Updater{
+		WriterForAsset: func(Asset) (AbortWriter, error) {
+			return nil, errors.New("Not used.")
+		},
+		WriterForReleaseAsset: func(release Release, asset Asset) (AbortWriter, error) {
+			w := NewAbortBuffer(nil)
+			writers[release.Name()+"/"+asset.Name()] = w
+			return w, nil
+		},
+	}
+
+	err := u.UpdateTo(r)
+	assert.Nil(t, err)
+	require.Contains(t, writers, "v1.2.0/myapp")
+	assert.Equal(t, "Hello World!", writers["v1.2.0/myapp"].Buffer.String())
+}
+
+func TestUpdaterValidate(t *testing.T) {
+	a := &testAsset{
+		name: "asset",
+		write: func(w io.Writer) error {
+			_, err := w.Write([]byte("Hello World!"))
+			return err
+		},
+	}
+
+	// Valid update
+	{
+		f := NewDelayedFile("/n/o/n/e/x/i/s/t/i/n/g/file")
+		defer f.discard()
+
+		var validated []string
+		u := &Updater{
+			WriterForAsset: func(Asset) (AbortWriter, error) {
+				return f, nil
+			},
+			Validate: func(r Release, paths []string) error {
+				validated = paths
+				return nil
+			},
+		}
+
+		err := u.UpdateTo(&testRelease{assets: []Asset{a}})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{f.TempPath()}, validated)
+		assert.False(t, f.aborted)
+	}
+
+	// Invalid update
+	{
+		validateErr := errors.New("Test validation error")
+		b := NewAbortBuffer(nil)
+		u := &Updater{
+			WriterForAsset: func(Asset) (AbortWriter, error) {
+				return b, nil
+			},
+			Validate: func(r Release, paths []string) error {
+				assert.Empty(t, paths)
+				return validateErr
+			},
+		}
+
+		err := u.UpdateTo(&testRelease{assets: []Asset{a}})
+		assert.Equal(t, validateErr, err)
+		assert.True(t, b.aborted)
+	}
+}
+
+func TestUpdaterContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Cancelled check
+	{
+		queried := false
+		app := &testApp{
+			FQuery: func() error {
+				queried = true
+				return nil
+			},
+		}
+		u := &Updater{App: app}
+
+		r, err := u.CheckContext(ctx)
+		assert.Nil(t, r)
+		assert.Equal(t, context.Canceled, err)
+		assert.False(t, queried)
+	}
+
+	// Cancelled update
+	{
+		written := false
+		a := &testAsset{
+			write: func(io.Writer) error {
+				written = true
+				return nil
+			},
+		}
+		w := NewAbortBuffer(nil)
+		u := &Updater{
+			WriterForAsset: func(Asset) (AbortWriter, error) {
+				return w, nil
+			},
+		}
+
+		err := u.UpdateToContext(ctx, &testRelease{assets: []Asset{a}})
+		assert.Equal(t, context.Canceled, err)
+		assert.False(t, written)
+		assert.True(t, w.aborted)
+	}
+}
+
+func TestUpdaterPartialWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-updater-test")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "myapp")
+	downloadErr := errors.New("Connection reset.")
+	a := &testAsset{
+		name: "myapp",
+		write: func(w io.Writer) error {
+			if _, err := io.WriteString(w, "Hello"); err != nil {
+				return err
+			}
+			return downloadErr
+		},
+	}
+
+	// Download fails halfway
+	{
+		f := NewDelayedFile(path)
+		u := &Updater{
+			WriterForAsset: func(Asset) (AbortWriter, error) {
+				return f, nil
+			},
+		}
+
+		err := u.UpdateTo(&testRelease{assets: []Asset{a}})
+		assert.Equal(t, &AssetDownloadError{Asset: a, Cause: downloadErr}, err)
+		assert.True(t, f.aborted)
+		assert.NotEmpty(t, f.TempPath())
+		_, err = os.Stat(f.TempPath())
+		assert.True(t, os.IsNotExist(err), "Temporary file not removed: %v", err)
+
+		// Closed by the caller
+		assert.Nil(t, f.Close())
+		_, err = os.Stat(path)
+		assert.True(t, os.IsNotExist(err), "Partial file committed: %v", err)
+	}
+
+	// Context cancelled halfway
+	{
+		ctx, cancel := context.WithCancel(context.Background())
+		a := &testAsset{
+			name: "myapp",
+			write: func(w io.Writer) error {
+				io.WriteString(w, "Hello")
+				cancel()
+				return nil
+			},
+		}
+		f := NewDelayedFile(path)
+		u := &Updater{
+			WriterForAsset: func(Asset) (AbortWriter, error) {
+				return f, nil
+			},
+		}
+
+		err := u.UpdateToContext(ctx, &testRelease{assets: []Asset{a}})
+		assert.Equal(t, context.Canceled, err)
+		assert.Nil(t, f.Close())
+		_, err = os.Stat(path)
+		assert.True(t, os.IsNotExist(err), "Partial file committed: %v", err)
+	}
+}
+
 type testApp struct {
 	FQuery         func() error
 	FLatestRelease func() Release
@@ -216,3 +427,217 @@ func (a *testAsset) Write(w io.Writer) error {
 	}
 	return nil
 }
+
+func TestUpdaterConcurrency(t *testing.T) {
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	newAsset := func(name string, err error) *testAsset {
+		return &testAsset{
+			name: name,
+			write: func(w io.Writer) error {
+				mu.Lock()
+				running++
+				if running > maxRunning {
+					maxRunning = running
+				}
+				mu.Unlock()
+
+				time.Sleep(10 * time.Millisecond)
+				w.Write([]byte(name))
+
+				mu.Lock()
+				running--
+				mu.Unlock()
+				return err
+			},
+		}
+	}
+
+	var writers []*AbortBuffer
+	u := &Updater{
+		Concurrency: 2,
+		WriterForAsset: func(Asset) (AbortWriter, error) {
+			w := NewAbortBuffer(nil)
+			writers = append(writers, w)
+			return w, nil
+		},
+	}
+
+	// Concurrent writes
+	{
+		var assets []Asset
+		for _, name := range []string{"a1", "a2", "a3", "a4"} {
+			assets = append(assets, newAsset(name, nil))
+		}
+
+		err := u.UpdateTo(&testRelease{assets: assets})
+		assert.Nil(t, err, "Unexpected error: %v", err)
+		assert.Equal(t, 2, maxRunning)
+		require.Equal(t, 4, len(writers))
+		for i, w := range writers {
+			assert.Equal(t, assets[i].Name(), w.Buffer.String())
+			assert.False(t, w.aborted)
+		}
+	}
+
+	// Failing write
+	{
+		writers = nil
+		writeErr := errors.New("Write error.")
+		assets := []Asset{
+			newAsset("a1", nil),
+			newAsset("a2", writeErr),
+			newAsset("a3", nil),
+		}
+
+		err := u.UpdateTo(&testRelease{assets: assets})
+		assert.Equal(t, writeErr, errors.Unwrap(err))
+		for _, w := range writers {
+			assert.True(t, w.aborted)
+		}
+	}
+}
+
+func TestUpdaterConcurrentUse(t *testing.T) {
+	// Concurrent checks share a query
+	{
+		var mu sync.Mutex
+		queries := 0
+		release := make(chan struct{})
+		app := &testApp{
+			FQuery: func() error {
+				mu.Lock()
+				queries++
+				mu.Unlock()
+				<-release
+				return nil
+			},
+			FLatestRelease: func() Release {
+				return &testRelease{name: "v2", identifier: "v2"}
+			},
+		}
+		u := &Updater{App: app, CurrentReleaseIdentifier: "v1"}
+
+		var wg sync.WaitGroup
+		results := make([]Release, 4)
+		for i := range results {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				r, err := u.Check()
+				assert.Nil(t, err, "Could not check: %v", err)
+				results[i] = r
+			}(i)
+		}
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		assert.Equal(t, 1, queries)
+		for _, r := range results {
+			require.NotNil(t, r)
+			assert.Equal(t, "v2", r.Name())
+		}
+	}
+
+	// Waiters check again when the shared check is cancelled or panics
+	for _, fail := range []func(cancel context.CancelFunc){
+		func(cancel context.CancelFunc) { cancel() },
+		func(cancel context.CancelFunc) { panic("Test panic") },
+	} {
+		var mu sync.Mutex
+		queries := 0
+		started := make(chan struct{})
+		release := make(chan struct{})
+		ctx, cancel := context.WithCancel(context.Background())
+		app := &testApp{
+			FQuery: func() error {
+				mu.Lock()
+				queries++
+				first := queries == 1
+				mu.Unlock()
+				if first {
+					close(started)
+					<-release
+					fail(cancel)
+					return ctx.Err()
+				}
+				return nil
+			},
+			FLatestRelease: func() Release {
+				return &testRelease{name: "v2", identifier: "v2"}
+			},
+		}
+		u := &Updater{App: app, CurrentReleaseIdentifier: "v1"}
+
+		first := make(chan error, 1)
+		go func() {
+			defer func() {
+				if recover() != nil {
+					first <- errors.New("Test panic")
+				}
+			}()
+			_, err := u.CheckContext(ctx)
+			first <- err
+		}()
+		<-started
+
+		waiter := make(chan Release, 1)
+		go func() {
+			r, err := u.Check()
+			assert.Nil(t, err, "Could not check: %v", err)
+			waiter <- r
+		}()
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+
+		assert.Error(t, <-first)
+		r := <-waiter
+		require.NotNil(t, r)
+		assert.Equal(t, "v2", r.Name())
+		assert.Equal(t, 2, queries)
+		cancel()
+	}
+
+	// Concurrent updates are applied one at a time
+	{
+		var mu sync.Mutex
+		running, maxRunning := 0, 0
+		asset := &testAsset{
+			name: "a",
+			write: func(io.Writer) error {
+				mu.Lock()
+				running++
+				if running > maxRunning {
+					maxRunning = running
+				}
+				mu.Unlock()
+
+				time.Sleep(10 * time.Millisecond)
+
+				mu.Lock()
+				running--
+				mu.Unlock()
+				return nil
+			},
+		}
+		u := &Updater{
+			WriterForAsset: func(Asset) (AbortWriter, error) {
+				return NewAbortBuffer(nil), nil
+			},
+		}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 3; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := u.UpdateTo(&testRelease{assets: []Asset{asset}})
+				assert.Nil(t, err, "Could not update: %v", err)
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, 1, maxRunning)
+	}
+}
@@ -0,0 +1,234 @@
+package updatertest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/github"
+	updater "github.com/hverr/go-updater"
+)
+
+// GitHubServer is a fake GitHub API that serves releases of a single
+// repository, for applications created with updater.NewGitHub.
+//
+// It serves the releases, the references of their tags, whose commit SHA is
+// the identifier of the release, and the assets, both from the API and from
+// their browser download URLs. Assets whose Err is set are cut off after
+// FailAfter bytes. Other requests fail with 404 Not Found.
+type GitHubServer struct {
+	*httptest.Server
+
+	owner      string
+	repository string
+
+	mutex    sync.Mutex
+	releases []*Release
+	status   int
+	requests int
+}
+
+// NewGitHubServer starts a server for the repository of owner with the given
+// releases, the most recent one first. Close it when the test is done.
+func NewGitHubServer(owner, repository string, releases ...*Release) *GitHubServer {
+	s := &GitHubServer{
+		owner:      owner,
+		repository: repository,
+		releases:   releases,
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Client returns a GitHub client that sends its requests to the server.
+func (s *GitHubServer) Client() *github.Client {
+	client := github.NewClient(s.Server.Client())
+	client.BaseURL, _ = url.Parse(s.URL + "/")
+	client.UploadURL = client.BaseURL
+	return client
+}
+
+// App returns an application for the repository that uses the server.
+func (s *GitHubServer) App() updater.App {
+	return updater.NewGitHub(s.owner, s.repository, s.Client())
+}
+
+// SetReleases replaces the releases of the repository, the most recent one
+// first.
+func (s *GitHubServer) SetReleases(releases ...*Release) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.releases = releases
+}
+
+// FailRequests makes all following requests fail with the given HTTP status
+// code, until it is called with 0.
+func (s *GitHubServer) FailRequests(status int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.status = status
+}
+
+// Requests returns how many requests the server received.
+func (s *GitHubServer) Requests() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.requests
+}
+
+func (s *GitHubServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	s.requests++
+	status, releases := s.status, s.releases
+	s.mutex.Unlock()
+
+	if status != 0 {
+		writeGitHubError(w, status)
+		return
+	}
+
+	repo := "/repos/" + s.owner + "/" + s.repository + "/"
+	if !strings.HasPrefix(r.URL.Path, repo) && !strings.HasPrefix(r.URL.Path, "/download/") {
+		writeGitHubError(w, http.StatusNotFound)
+		return
+	}
+	p := strings.TrimPrefix(r.URL.Path, repo)
+
+	switch {
+	case p == "releases":
+		writeJSON(w, s.githubReleases(releases))
+	case strings.HasPrefix(p, "releases/tags/"):
+		if release := findRelease(releases, strings.TrimPrefix(p, "releases/tags/")); release != nil {
+			writeJSON(w, s.githubRelease(releases, release))
+		} else {
+			writeGitHubError(w, http.StatusNotFound)
+		}
+	case p == "git/refs/tags":
+		refs := make([]interface{}, len(releases))
+		for i, release := range releases {
+			refs[i] = githubReference(release)
+		}
+		writeJSON(w, refs)
+	case strings.HasPrefix(p, "git/refs/tags/"):
+		if release := findRelease(releases, strings.TrimPrefix(p, "git/refs/tags/")); release != nil {
+			writeJSON(w, githubReference(release))
+		} else {
+			writeGitHubError(w, http.StatusNotFound)
+		}
+	case strings.HasPrefix(p, "releases/assets/"):
+		id, _ := strconv.Atoi(strings.TrimPrefix(p, "releases/assets/"))
+		writeAsset(w, findAsset(releases, id))
+	case strings.HasPrefix(r.URL.Path, "/download/"):
+		id, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/download/"))
+		writeAsset(w, findAsset(releases, id))
+	default:
+		writeGitHubError(w, http.StatusNotFound)
+	}
+}
+
+// githubReleases returns the JSON representation of all releases.
+func (s *GitHubServer) githubReleases(releases []*Release) []interface{} {
+	v := make([]interface{}, len(releases))
+	for i, release := range releases {
+		v[i] = s.githubRelease(releases, release)
+	}
+	return v
+}
+
+// githubRelease returns the JSON representation of release.
+//
+// Releases and assets are numbered by their position, starting at 1.
+func (s *GitHubServer) githubRelease(releases []*Release, release *Release) interface{} {
+	var assets []interface{}
+	id := 0
+	for i, r := range releases {
+		for _, a := range r.Files {
+			id++
+			if r != release {
+				continue
+			}
+			assets = append(assets, map[string]interface{}{
+				"id":                   id,
+				"name":                 a.FileName,
+				"size":                 len(a.Content),
+				"download_count":       a.DownloadCount(),
+				"browser_download_url": s.URL + "/download/" + strconv.Itoa(id),
+			})
+		}
+		if r == release {
+			return map[string]interface{}{
+				"id":       i + 1,
+				"tag_name": release.Version,
+				"name":     release.Version,
+				"body":     release.Notes,
+				"assets":   assets,
+			}
+		}
+	}
+	return nil
+}
+
+// githubReference returns the JSON representation of the reference of the
+// tag of release.
+func githubReference(release *Release) interface{} {
+	return map[string]interface{}{
+		"ref": "refs/tags/" + release.Version,
+		"object": map[string]interface{}{
+			"type": "commit",
+			"sha":  release.Identifier(),
+		},
+	}
+}
+
+// findRelease returns the release with the given version name, or nil.
+func findRelease(releases []*Release, name string) *Release {
+	for _, r := range releases {
+		if r.Version == name {
+			return r
+		}
+	}
+	return nil
+}
+
+// findAsset returns the asset with the given number, or nil.
+func findAsset(releases []*Release, id int) *Asset {
+	for _, r := range releases {
+		for _, a := range r.Files {
+			id--
+			if id == 0 {
+				return a
+			}
+		}
+	}
+	return nil
+}
+
+// writeAsset writes the contents of a to w, with the length of the complete
+// contents, so that a failing asset is cut off.
+func writeAsset(w http.ResponseWriter, a *Asset) {
+	if a == nil {
+		writeGitHubError(w, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.Itoa(len(a.Content)))
+	a.Write(w)
+}
+
+// writeJSON writes v to w as JSON.
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeGitHubError writes an error response of the GitHub API with status.
+func writeGitHubError(w http.ResponseWriter, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"message": http.StatusText(status)})
+}
@@ -0,0 +1,75 @@
+package updatertest
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	updater "github.com/hverr/go-updater"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitHubServer(t *testing.T) {
+	current := NewRelease("v1.0.0", NewAsset("myapp", []byte("old")))
+	latest := &Release{Version: "v1.1.0", Notes: "Bug fixes.", Files: []*Asset{
+		NewAsset("myapp.exe", []byte("windows")),
+		NewAsset("myapp", []byte("new")),
+	}}
+	s := NewGitHubServer("owner", "myapp", latest, current)
+	defer s.Close()
+
+	w := NewWriters()
+	u := &updater.Updater{
+		App:                      s.App(),
+		CurrentReleaseIdentifier: current.Identifier(),
+		AssetFilter: func(a updater.Asset) bool {
+			return a.Name() == "myapp"
+		},
+		WriterForAsset: w.WriterForAsset,
+	}
+
+	// Update to the latest release
+	{
+		r, err := u.Check()
+		require.Nil(t, err, "Unexpected error: %v", err)
+		require.NotNil(t, r)
+		assert.Equal(t, "v1.1.0", r.Name())
+		assert.Equal(t, "Bug fixes.", r.Information())
+		assert.Equal(t, latest.Identifier(), r.Identifier())
+
+		err = u.UpdateTo(r)
+		require.Nil(t, err, "Unexpected error: %v", err)
+		assert.Equal(t, []byte("new"), w.Bytes("myapp"))
+		assert.Nil(t, w.Bytes("myapp.exe"))
+	}
+
+	// Release by name
+	{
+		r, err := u.ReleaseByName("v1.0.0")
+		require.Nil(t, err, "Unexpected error: %v", err)
+		assert.Equal(t, current.Identifier(), r.Identifier())
+	}
+
+	// Cut off asset
+	{
+		latest.Files[1].Err = errors.New("connection reset")
+		latest.Files[1].FailAfter = 1
+		defer func() { latest.Files[1].Err = nil }()
+
+		err := u.UpdateTo(nil)
+		assert.Error(t, err)
+	}
+
+	// Failing requests
+	{
+		s.FailRequests(http.StatusInternalServerError)
+		_, err := u.Check()
+		assert.Error(t, err)
+
+		s.FailRequests(0)
+		_, err = u.Check()
+		assert.Nil(t, err)
+		assert.True(t, s.Requests() > 0)
+	}
+}
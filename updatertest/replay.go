@@ -0,0 +1,158 @@
+package updatertest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ReplayTransport is an http.RoundTripper that answers requests with
+// responses recorded in a directory of fixtures, e.g. to test against real
+// GitHub responses without network access.
+//
+// Every fixture is a JSON file with the status, headers and body of the
+// response to a method and URL. The headers and bodies of requests are not
+// recorded, so credentials in headers do not end up in fixtures; credentials
+// in URLs do.
+type ReplayTransport struct {
+	// Directory with the fixtures.
+	Dir string
+
+	// Transport that performs requests without a fixture and records their
+	// responses. If it is nil, requests without a fixture fail.
+	Record http.RoundTripper
+}
+
+// fixture is a recorded response.
+type fixture struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+// NewReplayClient returns a client that answers all requests with the
+// fixtures in dir, see ReplayTransport. Requests without a fixture fail.
+//
+// Use it as the HTTPClient of updater.GitHubOptions, or create a GitHub
+// client with it:
+//
+//	client := github.NewClient(updatertest.NewReplayClient("testdata/github"))
+func NewReplayClient(dir string) *http.Client {
+	return &http.Client{Transport: &ReplayTransport{Dir: dir}}
+}
+
+// NewRecordingClient returns a client that answers requests with the fixtures
+// in dir, and performs requests without a fixture with transport and records
+// their responses in dir. If transport is nil, http.DefaultTransport is used.
+//
+// Use it once to record the fixtures, and NewReplayClient afterwards.
+func NewRecordingClient(dir string, transport http.RoundTripper) *http.Client {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &http.Client{Transport: &ReplayTransport{Dir: dir, Record: transport}}
+}
+
+// RoundTrip returns the recorded response to req.
+func (t *ReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	path := filepath.Join(t.Dir, fixtureName(req))
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) && t.Record != nil {
+		return t.record(req, path)
+	} else if os.IsNotExist(err) {
+		return nil, fmt.Errorf("No fixture for %v %v in %v.", req.Method, req.URL, t.Dir)
+	} else if err != nil {
+		return nil, err
+	}
+
+	var f fixture
+	err = json.Unmarshal(b, &f)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid fixture %v: %v", path, err)
+	}
+	return f.response(req), nil
+}
+
+// record performs req with the recording transport and writes its response
+// to the fixture at path.
+func (t *ReplayTransport) record(req *http.Request, path string) (*http.Response, error) {
+	resp, err := t.Record.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	f := fixture{
+		Method: req.Method,
+		URL:    req.URL.String(),
+		Status: resp.StatusCode,
+		Header: resp.Header,
+		Body:   body,
+	}
+	b, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.MkdirAll(t.Dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	err = ioutil.WriteFile(path, b, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return f.response(req), nil
+}
+
+// response returns the recorded response as a response to req.
+func (f *fixture) response(req *http.Request) *http.Response {
+	header := f.Header
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", f.Status, http.StatusText(f.Status)),
+		StatusCode:    f.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(f.Body)),
+		ContentLength: int64(len(f.Body)),
+		Request:       req,
+	}
+}
+
+// fixtureName returns the name of the fixture of req: its method and path,
+// for readability, and a hash of its URL.
+func fixtureName(req *http.Request) string {
+	sum := sha256.Sum256([]byte(req.Method + " " + req.URL.String()))
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' {
+			return r
+		}
+		return '_'
+	}, strings.Trim(req.URL.Path, "/"))
+	if len(name) > 64 {
+		name = name[len(name)-64:]
+	}
+	return fmt.Sprintf("%v_%v_%x.json", strings.ToLower(req.Method), name, sum[:6])
+}
@@ -0,0 +1,66 @@
+package updatertest
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/google/go-github/github"
+	updater "github.com/hverr/go-updater"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplayClient(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go-updater-replay-")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	current := NewRelease("v1.0.0", NewAsset("myapp", []byte("old")))
+	latest := NewRelease("v1.1.0", NewAsset("myapp", []byte("new")))
+	s := NewGitHubServer("owner", "myapp", latest, current)
+	baseURL, err := url.Parse(s.URL + "/")
+	require.Nil(t, err)
+
+	update := func(client *github.Client) ([]byte, error) {
+		client.BaseURL = baseURL
+		w := NewWriters()
+		u := &updater.Updater{
+			App:                      updater.NewGitHub("owner", "myapp", client),
+			CurrentReleaseIdentifier: current.Identifier(),
+			WriterForAsset:           w.WriterForAsset,
+		}
+		err := u.UpdateTo(nil)
+		return w.Bytes("myapp"), err
+	}
+
+	// Missing fixtures
+	{
+		_, err := update(github.NewClient(NewReplayClient(dir)))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "No fixture")
+	}
+
+	// Record
+	{
+		b, err := update(github.NewClient(NewRecordingClient(dir, nil)))
+		require.Nil(t, err, "Unexpected error: %v", err)
+		assert.Equal(t, []byte("new"), b)
+
+		files, err := ioutil.ReadDir(dir)
+		require.Nil(t, err)
+		assert.NotEmpty(t, files)
+	}
+
+	// Replay without the server
+	{
+		requests := s.Requests()
+		s.Close()
+
+		b, err := update(github.NewClient(NewReplayClient(dir)))
+		require.Nil(t, err, "Unexpected error: %v", err)
+		assert.Equal(t, []byte("new"), b)
+		assert.Equal(t, requests, s.Requests())
+	}
+}
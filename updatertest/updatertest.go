@@ -0,0 +1,353 @@
+// Package updatertest provides test doubles for applications that use the
+// updater, so that update flows can be tested without a real release source.
+//
+// App is an in-memory application whose releases and failures are scripted
+// by the test, and Writers records the assets that an update writes:
+//
+//	current := updatertest.NewRelease("v1.0.0", updatertest.NewAsset("myapp", []byte("old")))
+//	app := updatertest.NewApp(
+//		updatertest.NewRelease("v1.1.0", updatertest.NewAsset("myapp", []byte("new"))),
+//		current,
+//	)
+//	w := updatertest.NewWriters()
+//	u := &updater.Updater{
+//		App:                      app,
+//		CurrentReleaseIdentifier: current.Identifier(),
+//		WriterForAsset:           w.WriterForAsset,
+//	}
+//	err := u.UpdateTo(nil)
+//	// w.Bytes("myapp") is now "new"
+//
+// GitHubServer serves the same releases over a fake GitHub API, to test
+// applications created with updater.NewGitHub end to end. To test against
+// real responses instead, record them once with NewRecordingClient and replay
+// them with NewReplayClient.
+package updatertest
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io"
+	"sync"
+
+	updater "github.com/hverr/go-updater"
+)
+
+// App is an in-memory application whose releases can be changed and whose
+// queries can be made to fail while a test runs.
+//
+// It implements updater.ReleasesApp and updater.NamedReleaseApp. The
+// releases are only visible after a query, like those of a real application.
+type App struct {
+	mutex    sync.Mutex
+	next     []*Release
+	releases []*Release
+	err      error
+	queries  int
+}
+
+// NewApp creates an application with the given releases, the most recent one
+// first.
+func NewApp(releases ...*Release) *App {
+	return &App{next: releases}
+}
+
+// SetReleases replaces the releases that the next query returns, the most
+// recent one first, e.g. to publish a new release.
+func (app *App) SetReleases(releases ...*Release) {
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	app.next = releases
+}
+
+// FailQueries makes all following queries fail with err, until it is called
+// with nil.
+func (app *App) FailQueries(err error) {
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	app.err = err
+}
+
+// Queries returns how many times the application was queried.
+func (app *App) Queries() int {
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	return app.queries
+}
+
+// Query makes the releases of SetReleases available, or fails with the error
+// of FailQueries.
+func (app *App) Query() error {
+	return app.QueryContext(context.Background())
+}
+
+// QueryContext is like Query but fails when ctx is cancelled.
+func (app *App) QueryContext(ctx context.Context) error {
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	app.queries++
+
+	if err := ctx.Err(); err != nil {
+		return err
+	} else if app.err != nil {
+		return app.err
+	}
+	app.releases = app.next
+	return nil
+}
+
+// LatestRelease returns the first release of the last query, or nil if there
+// is none.
+func (app *App) LatestRelease() updater.Release {
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	if len(app.releases) == 0 {
+		return nil
+	}
+	return app.releases[0]
+}
+
+// Releases returns the releases of the last query.
+func (app *App) Releases() []updater.Release {
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	s := make([]updater.Release, len(app.releases))
+	for i, r := range app.releases {
+		s[i] = r
+	}
+	return s
+}
+
+// ReleaseByName returns the release of the last query with the given version
+// name, or updater.ErrReleaseNotFound.
+func (app *App) ReleaseByName(name string) (updater.Release, error) {
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	for _, r := range app.releases {
+		if r.Version == name {
+			return r, nil
+		}
+	}
+	return nil, updater.ErrReleaseNotFound
+}
+
+// Release is a release of an App or a GitHubServer.
+type Release struct {
+	// Version name of the release, e.g. v1.2.0.
+	Version string
+
+	// Release notes.
+	Notes string
+
+	// Identifier of the release. If it is empty, the SHA-1 checksum of the
+	// version is used, like the commit SHA of a GitHub release.
+	ID string
+
+	// Assets of the release.
+	Files []*Asset
+}
+
+// NewRelease creates a release with the given version name and assets.
+func NewRelease(version string, assets ...*Asset) *Release {
+	return &Release{Version: version, Files: assets}
+}
+
+// Name returns the version name of the release.
+func (r *Release) Name() string {
+	return r.Version
+}
+
+// Information returns the release notes.
+func (r *Release) Information() string {
+	return r.Notes
+}
+
+// Identifier returns the identifier of the release.
+func (r *Release) Identifier() string {
+	if r.ID != "" {
+		return r.ID
+	}
+	sum := sha1.Sum([]byte(r.Version))
+	return hex.EncodeToString(sum[:])
+}
+
+// Assets returns the assets of the release.
+func (r *Release) Assets() []updater.Asset {
+	s := make([]updater.Asset, len(r.Files))
+	for i, a := range r.Files {
+		s[i] = a
+	}
+	return s
+}
+
+// Asset is an asset whose contents are kept in memory.
+//
+// It implements updater.ContextAsset and updater.AssetMeta. The download
+// count is the number of times it was written.
+type Asset struct {
+	// Name of the asset.
+	FileName string
+
+	// Contents of the asset.
+	Content []byte
+
+	// Error that writes of the asset fail with, after writing the first
+	// FailAfter bytes of the contents. Writes succeed if it is nil.
+	Err       error
+	FailAfter int
+
+	mutex  sync.Mutex
+	writes int
+}
+
+// NewAsset creates an asset with the given name and contents.
+func NewAsset(name string, content []byte) *Asset {
+	return &Asset{FileName: name, Content: content}
+}
+
+// Name returns the name of the asset.
+func (a *Asset) Name() string {
+	return a.FileName
+}
+
+// Write writes the contents of the asset to w.
+func (a *Asset) Write(w io.Writer) error {
+	return a.WriteContext(context.Background(), w)
+}
+
+// WriteContext writes the contents of the asset to w, or fails when ctx is
+// cancelled.
+func (a *Asset) WriteContext(ctx context.Context, w io.Writer) error {
+	a.mutex.Lock()
+	a.writes++
+	a.mutex.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	content := a.Content
+	if a.Err != nil && a.FailAfter < len(content) {
+		content = content[:a.FailAfter]
+	}
+	if _, err := w.Write(content); err != nil {
+		return err
+	}
+	return a.Err
+}
+
+// Size returns the length of the contents.
+func (a *Asset) Size() int64 {
+	return int64(len(a.Content))
+}
+
+// ContentType returns an empty string, the type is not known.
+func (a *Asset) ContentType() string {
+	return ""
+}
+
+// DownloadCount returns how many times the asset was written.
+func (a *Asset) DownloadCount() int {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.writes
+}
+
+// Writers records the assets written by an updater in memory.
+//
+// Use WriterForAsset as the WriterForAsset of an updater.Updater.
+type Writers struct {
+	mutex   sync.Mutex
+	buffers map[string]*Buffer
+}
+
+// NewWriters creates writers without any written assets.
+func NewWriters() *Writers {
+	return &Writers{buffers: make(map[string]*Buffer)}
+}
+
+// WriterForAsset returns a new buffer for the asset, replacing the buffer of
+// a previous update.
+func (w *Writers) WriterForAsset(a updater.Asset) (updater.AbortWriter, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	b := &Buffer{}
+	w.buffers[a.Name()] = b
+	return b, nil
+}
+
+// Buffer returns the buffer of the asset with the given name, or nil if it
+// was not written.
+func (w *Writers) Buffer(name string) *Buffer {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.buffers[name]
+}
+
+// Bytes returns the contents written for the asset with the given name, or
+// nil if it was not written.
+func (w *Writers) Bytes(name string) []byte {
+	b := w.Buffer(name)
+	if b == nil {
+		return nil
+	}
+	return b.Bytes()
+}
+
+// Buffer is an updater.AbortWriter that remembers whether it was aborted or
+// closed.
+type Buffer struct {
+	mutex   sync.Mutex
+	buffer  bytes.Buffer
+	aborted bool
+	closed  bool
+}
+
+// Write appends p to the buffer, or fails if it was aborted.
+func (b *Buffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.aborted {
+		return 0, errors.New("Write operations are aborted.")
+	}
+	return b.buffer.Write(p)
+}
+
+// Abort blocks all subsequent writes.
+func (b *Buffer) Abort() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.aborted = true
+}
+
+// Close marks the buffer as closed.
+func (b *Buffer) Close() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.closed = true
+	return nil
+}
+
+// Bytes returns a copy of the contents written so far.
+func (b *Buffer) Bytes() []byte {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return append([]byte(nil), b.buffer.Bytes()...)
+}
+
+// Aborted returns whether the buffer was aborted.
+func (b *Buffer) Aborted() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.aborted
+}
+
+// Closed returns whether the buffer was closed.
+func (b *Buffer) Closed() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.closed
+}
@@ -0,0 +1,101 @@
+package updatertest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	updater "github.com/hverr/go-updater"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApp(t *testing.T) {
+	current := NewRelease("v1.0.0", NewAsset("myapp", []byte("old")))
+	latest := NewRelease("v1.1.0", NewAsset("myapp", []byte("new")))
+	app := NewApp(current)
+
+	w := NewWriters()
+	u := &updater.Updater{
+		App:                      app,
+		CurrentReleaseIdentifier: current.Identifier(),
+		WriterForAsset:           w.WriterForAsset,
+	}
+
+	// Up to date
+	{
+		r, err := u.Check()
+		assert.Nil(t, err)
+		assert.Nil(t, r)
+		assert.Equal(t, 1, app.Queries())
+	}
+
+	// New release
+	{
+		app.SetReleases(latest, current)
+		err := u.UpdateTo(nil)
+		require.Nil(t, err, "Unexpected error: %v", err)
+		assert.Equal(t, []byte("new"), w.Bytes("myapp"))
+		assert.Equal(t, 1, latest.Files[0].DownloadCount())
+	}
+
+	// Release by name
+	{
+		r, err := app.ReleaseByName("v1.0.0")
+		require.Nil(t, err)
+		assert.Equal(t, current, r)
+
+		_, err = app.ReleaseByName("v2.0.0")
+		assert.Equal(t, updater.ErrReleaseNotFound, err)
+	}
+
+	// Failing queries
+	{
+		queryErr := errors.New("offline")
+		app.FailQueries(queryErr)
+		_, err := u.Check()
+		assert.Equal(t, queryErr, err)
+
+		app.FailQueries(nil)
+		_, err = u.Check()
+		assert.Nil(t, err)
+	}
+
+	// Cancelled query
+	{
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		assert.Equal(t, context.Canceled, app.QueryContext(ctx))
+	}
+}
+
+func TestAsset(t *testing.T) {
+	// Failing asset
+	{
+		assetErr := errors.New("connection reset")
+		a := &Asset{FileName: "myapp", Content: []byte("Hello World!"), Err: assetErr, FailAfter: 5}
+		w := NewWriters()
+		u := &updater.Updater{WriterForAsset: w.WriterForAsset}
+
+		err := u.UpdateTo(NewRelease("v1.0.0", a))
+		require.True(t, errors.Is(err, assetErr), "Unexpected error: %v", err)
+		assert.Equal(t, []byte("Hello"), w.Bytes("myapp"))
+		assert.True(t, w.Buffer("myapp").Aborted())
+		assert.True(t, w.Buffer("myapp").Closed())
+	}
+
+	// Metadata
+	{
+		a := NewAsset("myapp", []byte("Hello World!"))
+		assert.Equal(t, int64(12), a.Size())
+		assert.Equal(t, 0, a.DownloadCount())
+	}
+
+	// Aborted buffer
+	{
+		b := &Buffer{}
+		b.Abort()
+		_, err := b.Write([]byte("Hello"))
+		assert.Error(t, err)
+	}
+}
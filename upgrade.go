@@ -0,0 +1,226 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+// Environment variables that tell a process started by UpgradeInPlace about
+// the files it inherited.
+const (
+	listenFDsEnv = "UPDATER_LISTEN_FDS"
+	readyFDEnv   = "UPDATER_READY_FD"
+)
+
+// Time that UpgradeInPlace waits for the new process to call UpgradeReady.
+const upgradeReadyTimeout = time.Minute
+
+// fileListener is a listener whose socket can be passed to another process,
+// like *net.TCPListener and *net.UnixListener.
+type fileListener interface {
+	File() (*os.File, error)
+}
+
+// UpgradeInPlace updates a network server without dropping connections.
+//
+// It replaces the running executable with the latest release like
+// SelfUpdate, and starts it with the same arguments and environment. The new
+// process inherits the sockets of listeners, which it takes over with
+// InheritedListeners, and calls UpgradeReady once it serves them. The current
+// process keeps accepting connections until then, and should then stop
+// accepting connections, e.g. with http.Server.Shutdown, finish the requests
+// that are in progress and exit.
+//
+// If the new process exits or does not call UpgradeReady within a minute, it
+// is killed, the previous executable is put back and an error is returned.
+// The release that was installed is returned. When the application is already
+// up to date, nil is returned. Upgrading in place is not supported on
+// Windows.
+func (u *Updater) UpgradeInPlace(listeners ...net.Listener) (Release, error) {
+	return u.UpgradeInPlaceContext(context.Background(), listeners...)
+}
+
+// UpgradeInPlaceContext is like UpgradeInPlace but aborts when ctx is
+// cancelled.
+func (u *Updater) UpgradeInPlaceContext(ctx context.Context, listeners ...net.Listener) (Release, error) {
+	if runtime.GOOS == "windows" {
+		return nil, errors.New("Upgrading in place is not supported on Windows.")
+	}
+	for _, l := range listeners {
+		if _, ok := l.(fileListener); !ok {
+			return nil, fmt.Errorf("Listener on %v cannot be passed to another process.", l.Addr())
+		}
+	}
+
+	exe, err := osExecutable()
+	if err != nil {
+		return nil, err
+	}
+	exe, err = filepath.EvalSymlinks(exe)
+	if err != nil {
+		return nil, err
+	}
+
+	u.updating.Lock()
+	defer u.updating.Unlock()
+
+	release, err := u.CheckContext(ctx)
+	if err != nil || release == nil {
+		return nil, err
+	}
+
+	// Keep the previous executable in case the new one does not start
+	info, err := os.Stat(exe)
+	if err != nil {
+		return nil, err
+	}
+	backup := exe + ".previous"
+	err = copyFile(exe, backup, info.Mode())
+	if err != nil {
+		os.Remove(backup)
+		return nil, err
+	}
+	rolledBack := false
+	defer func() {
+		if rolledBack {
+			return
+		}
+		if err := os.Remove(backup); err != nil && !os.IsNotExist(err) {
+			u.logf("Could not remove %v: %v", backup, err)
+		}
+	}()
+
+	// The release is only recorded as applied once the new process is ready
+	err = u.selfUpdateReported(ctx, release, exe, func() error {
+		err := u.handOver(ctx, exe, os.Args[1:], listeners)
+		if err != nil {
+			u.logf("Restoring %v: %v", exe, err)
+			if rerr := os.Rename(backup, exe); rerr != nil {
+				u.logf("Could not restore %v, the previous executable is kept at %v: %v", exe, backup, rerr)
+			}
+			rolledBack = true
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return release, nil
+}
+
+// handOver starts exe with args, passes the sockets of listeners to it, and
+// waits until it calls UpgradeReady. The process is killed if it does not.
+func (u *Updater) handOver(ctx context.Context, exe string, args []string, listeners []net.Listener) error {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	// The ready pipe is file descriptor 3, the listeners follow
+	files := []*os.File{w}
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	for _, l := range listeners {
+		f, err := l.(fileListener).File()
+		if err != nil {
+			return err
+		}
+		files = append(files, f)
+	}
+
+	cmd := exec.Command(exe, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(),
+		readyFDEnv+"=3",
+		listenFDsEnv+"="+strconv.Itoa(len(listeners)),
+	)
+	cmd.ExtraFiles = files
+
+	u.logf("Starting %v with %v listeners", exe, len(listeners))
+	err = cmd.Start()
+	if err != nil {
+		return err
+	}
+	w.Close()
+
+	// Reading fails when the process exits before it is ready
+	ready := make(chan error, 1)
+	go func() {
+		b := make([]byte, 1)
+		_, err := r.Read(b)
+		ready <- err
+	}()
+
+	t := time.NewTimer(upgradeReadyTimeout)
+	defer t.Stop()
+	select {
+	case err = <-ready:
+		if err != nil {
+			err = errors.New("The new process exited before it was ready.")
+		}
+	case <-t.C:
+		err = errors.New("The new process did not become ready in time.")
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return err
+	}
+	cmd.Process.Release()
+	return nil
+}
+
+// InheritedListeners returns the listeners passed to the process by
+// UpgradeInPlace, in the order in which they were passed, or nil if the
+// process was not started by UpgradeInPlace. Call it at startup, instead of
+// creating the listeners.
+func InheritedListeners() ([]net.Listener, error) {
+	n, _ := strconv.Atoi(os.Getenv(listenFDsEnv))
+	os.Unsetenv(listenFDsEnv)
+
+	var listeners []net.Listener
+	for i := 0; i < n; i++ {
+		f := os.NewFile(uintptr(4+i), "listener-"+strconv.Itoa(i))
+		l, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("Could not inherit listener %v: %v", i, err)
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners, nil
+}
+
+// UpgradeReady tells the process that started the current process with
+// UpgradeInPlace that it serves the inherited listeners, so that the old
+// process can stop. It does nothing if the process was not started by
+// UpgradeInPlace.
+func UpgradeReady() error {
+	fd, err := strconv.Atoi(os.Getenv(readyFDEnv))
+	os.Unsetenv(readyFDEnv)
+	if err != nil {
+		return nil
+	}
+
+	f := os.NewFile(uintptr(fd), "ready")
+	defer f.Close()
+	_, err = f.Write([]byte{1})
+	return err
+}
@@ -0,0 +1,154 @@
+package updater
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestUpgradeHelperProcess is the process started by TestHandOver.
+func TestUpgradeHelperProcess(t *testing.T) {
+	if os.Getenv("UPDATER_TEST_HELPER") != "1" {
+		return
+	}
+	defer os.Exit(0)
+
+	listeners, err := InheritedListeners()
+	if err != nil || len(listeners) != 1 {
+		os.Exit(1)
+	}
+	if os.Getenv("UPDATER_TEST_FAIL") == "1" {
+		os.Exit(1)
+	}
+	UpgradeReady()
+
+	conn, err := listeners[0].Accept()
+	if err != nil {
+		os.Exit(1)
+	}
+	io.WriteString(conn, "Hello from the new process!")
+	conn.Close()
+}
+
+func TestHandOver(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Upgrading in place is not supported on Windows.")
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer l.Close()
+
+	defer os.Unsetenv("UPDATER_TEST_HELPER")
+	os.Setenv("UPDATER_TEST_HELPER", "1")
+	u := &Updater{}
+	args := []string{"-test.run=^TestUpgradeHelperProcess$"}
+
+	// New process takes over
+	{
+		err := u.handOver(context.Background(), os.Args[0], args, []net.Listener{l})
+		require.Nil(t, err, "Could not hand over: %v", err)
+		l.Close()
+
+		conn, err := net.Dial("tcp", l.Addr().String())
+		require.Nil(t, err)
+		defer conn.Close()
+		b, err := ioutil.ReadAll(conn)
+		assert.Nil(t, err)
+		assert.Equal(t, "Hello from the new process!", string(b))
+	}
+
+	// New process fails
+	{
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		require.Nil(t, err)
+		defer l.Close()
+
+		defer os.Unsetenv("UPDATER_TEST_FAIL")
+		os.Setenv("UPDATER_TEST_FAIL", "1")
+		err = u.handOver(context.Background(), os.Args[0], args, []net.Listener{l})
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "exited")
+	}
+
+	// Listeners that cannot be passed
+	{
+		_, err := u.UpgradeInPlace(&testListener{})
+		assert.NotNil(t, err)
+	}
+}
+
+type testListener struct {
+	net.Listener
+}
+
+func (l *testListener) Addr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}
+}
+
+func TestUpdaterUpgradeInPlaceRollback(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Upgrading in place is not supported on Windows.")
+	}
+
+	dir, err := ioutil.TempDir("", "testing-")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// Fake executable
+	exe := filepath.Join(dir, "myapp")
+	err = ioutil.WriteFile(exe, []byte("old executable"), 0755)
+	require.Nil(t, err)
+
+	defer func() { osExecutable = os.Executable }()
+	osExecutable = func() (string, error) { return exe, nil }
+
+	// The new executable exits before it is ready
+	release := &testRelease{
+		identifier: "v2",
+		assets: []Asset{&testAsset{
+			name: "myapp_" + runtime.GOOS + "_" + runtime.GOARCH,
+			write: func(w io.Writer) error {
+				_, err := io.WriteString(w, "#!/bin/sh\nexit 1\n")
+				return err
+			},
+		}},
+	}
+	u := &Updater{
+		App:                      &testApp{FLatestRelease: func() Release { return release }},
+		CurrentReleaseIdentifier: "v1",
+		State:                    NewFileStateStore(filepath.Join(dir, "state.json")),
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer l.Close()
+
+	r, err := u.UpgradeInPlace(l)
+	assert.Nil(t, r)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "exited")
+
+	// The previous executable is back and the release is not recorded
+	data, err := ioutil.ReadFile(exe)
+	assert.Nil(t, err)
+	assert.Equal(t, "old executable", string(data))
+	_, err = os.Stat(exe + ".previous")
+	assert.True(t, os.IsNotExist(err))
+
+	applied, _ := u.LastUpdate()
+	assert.Nil(t, applied)
+	state, err := u.State.Load()
+	require.Nil(t, err)
+	assert.Equal(t, "", state.LastApplied)
+	require.NotNil(t, u.Report())
+	assert.False(t, u.Report().Applied)
+}
@@ -0,0 +1,183 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// Verifier verifies detached signatures of assets.
+//
+// When a verifier is set on the Updater, assets are buffered in memory while
+// they are written, so that their signature can be verified before the update
+// is finished.
+type Verifier interface {
+	// Verify should return an error if signature is not a valid signature of
+	// message.
+	Verify(message, signature []byte) error
+}
+
+type ed25519Verifier struct {
+	publicKey ed25519.PublicKey
+}
+
+// NewEd25519Verifier creates a verifier for plain ed25519 signatures.
+//
+// Signatures may be stored as the raw 64 signature bytes or base64 encoded.
+func NewEd25519Verifier(publicKey ed25519.PublicKey) Verifier {
+	return &ed25519Verifier{
+		publicKey: publicKey,
+	}
+}
+
+func (v *ed25519Verifier) Verify(message, signature []byte) error {
+	if len(signature) != ed25519.SignatureSize {
+		s := strings.TrimSpace(string(signature))
+		decoded, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return errors.New("Invalid signature encoding.")
+		}
+		signature = decoded
+	}
+
+	if len(v.publicKey) != ed25519.PublicKeySize ||
+		len(signature) != ed25519.SignatureSize ||
+		!ed25519.Verify(v.publicKey, message, signature) {
+		return errors.New("Invalid signature.")
+	}
+
+	return nil
+}
+
+type minisignVerifier struct {
+	keyID     []byte
+	publicKey ed25519.PublicKey
+}
+
+// NewMinisignVerifier creates a verifier for minisign signatures.
+//
+// The public key should be the base64 encoded key as printed by minisign. The
+// contents of a minisign.pub file, including the untrusted comment, are
+// accepted as well.
+//
+// Both legacy and pre-hashed signatures are supported. The trusted comment of
+// every signature is verified too.
+func NewMinisignVerifier(publicKey string) (Verifier, error) {
+	lines := nonEmptyLines(publicKey)
+	if len(lines) == 0 {
+		return nil, errors.New("Empty minisign public key.")
+	}
+
+	key, err := base64.StdEncoding.DecodeString(lines[len(lines)-1])
+	if err != nil || len(key) != 2+8+ed25519.PublicKeySize || string(key[:2]) != "Ed" {
+		return nil, errors.New("Invalid minisign public key.")
+	}
+
+	return &minisignVerifier{
+		keyID:     key[2:10],
+		publicKey: ed25519.PublicKey(key[10:]),
+	}, nil
+}
+
+func (v *minisignVerifier) Verify(message, signature []byte) error {
+	lines := nonEmptyLines(string(signature))
+	if len(lines) != 4 || !strings.HasPrefix(lines[2], "trusted comment: ") {
+		return errors.New("Invalid minisign signature format.")
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil || len(sig) != 2+8+ed25519.SignatureSize {
+		return errors.New("Invalid minisign signature format.")
+	}
+
+	globalSig, err := base64.StdEncoding.DecodeString(lines[3])
+	if err != nil || len(globalSig) != ed25519.SignatureSize {
+		return errors.New("Invalid minisign signature format.")
+	}
+
+	if !bytes.Equal(sig[2:10], v.keyID) {
+		return fmt.Errorf("Signature was created with unknown key %X.", sig[2:10])
+	}
+
+	switch string(sig[:2]) {
+	case "Ed":
+	case "ED":
+		h := blake2b.Sum512(message)
+		message = h[:]
+	default:
+		return fmt.Errorf("Unsupported minisign signature algorithm %q.", sig[:2])
+	}
+
+	if !ed25519.Verify(v.publicKey, message, sig[10:]) {
+		return errors.New("Invalid signature.")
+	}
+
+	comment := strings.TrimPrefix(lines[2], "trusted comment: ")
+	if !ed25519.Verify(v.publicKey, append(sig[10:], comment...), globalSig) {
+		return errors.New("Invalid trusted comment signature.")
+	}
+
+	return nil
+}
+
+// verifySignature verifies data of asset a against its detached signature in
+// release.
+func (u *Updater) verifySignature(ctx context.Context, release Release, a Asset, data []byte) error {
+	name := a.Name() + u.signatureSuffix()
+	s := findAsset(release, name)
+	if s == nil {
+		return fmt.Errorf("No signature %v available for %v.", name, a.Name())
+	}
+
+	sig := bytes.NewBuffer(nil)
+	err := u.writeAsset(ctx, s, sig)
+	if err != nil {
+		return err
+	}
+
+	err = u.Verifier.Verify(data, sig.Bytes())
+	if err != nil {
+		return fmt.Errorf("Could not verify %v: %v", a.Name(), err)
+	}
+
+	return nil
+}
+
+// signatureSuffix returns the configured signature suffix or the default.
+func (u *Updater) signatureSuffix() string {
+	if u.SignatureSuffix != "" {
+		return u.SignatureSuffix
+	}
+	return ".sig"
+}
+
+// isSignature reports whether a is a signature of another asset.
+func (u *Updater) isSignature(a Asset) bool {
+	return strings.HasSuffix(a.Name(), u.signatureSuffix())
+}
+
+// findAsset returns the asset named name in release, or nil.
+func findAsset(release Release, name string) Asset {
+	for _, a := range release.Assets() {
+		if a.Name() == name {
+			return a
+		}
+	}
+	return nil
+}
+
+func nonEmptyLines(s string) []string {
+	var lines []string
+	for _, l := range strings.Split(s, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,158 @@
+package updater
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/blake2b"
+)
+
+func TestEd25519Verifier(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.Nil(t, err)
+
+	message := []byte("Hello World!")
+	sig := ed25519.Sign(priv, message)
+	v := NewEd25519Verifier(pub)
+
+	// Raw signature
+	assert.Nil(t, v.Verify(message, sig))
+
+	// Base64 signature
+	encoded := base64.StdEncoding.EncodeToString(sig) + "\n"
+	assert.Nil(t, v.Verify(message, []byte(encoded)))
+
+	// Invalid signatures
+	assert.Error(t, v.Verify([]byte("Other message"), sig))
+	assert.Error(t, v.Verify(message, []byte("not a signature")))
+}
+
+func TestMinisignVerifier(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.Nil(t, err)
+
+	keyID := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	publicKey := "untrusted comment: minisign public key\n" +
+		base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), keyID...), pub...))
+
+	message := []byte("Hello World!")
+	sign := func(alg string, id []byte, comment string) []byte {
+		m := message
+		if alg == "ED" {
+			h := blake2b.Sum512(message)
+			m = h[:]
+		}
+
+		sig := append(append([]byte(alg), id...), ed25519.Sign(priv, m)...)
+		global := ed25519.Sign(priv, append(sig[10:], "timestamp:1"...))
+		return []byte("untrusted comment: signature\n" +
+			base64.StdEncoding.EncodeToString(sig) + "\n" +
+			"trusted comment: " + comment + "\n" +
+			base64.StdEncoding.EncodeToString(global) + "\n")
+	}
+
+	v, err := NewMinisignVerifier(publicKey)
+	require.Nil(t, err, "Could not parse public key: %v", err)
+
+	// Legacy and pre-hashed signatures
+	assert.Nil(t, v.Verify(message, sign("Ed", keyID, "timestamp:1")))
+	assert.Nil(t, v.Verify(message, sign("ED", keyID, "timestamp:1")))
+
+	// Tampered message
+	err = v.Verify([]byte("Other message"), sign("ED", keyID, "timestamp:1"))
+	assert.Error(t, err)
+
+	// Tampered trusted comment
+	err = v.Verify(message, sign("ED", keyID, "timestamp:2"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "trusted comment")
+
+	// Unknown key
+	err = v.Verify(message, sign("ED", []byte{8, 7, 6, 5, 4, 3, 2, 1}, "timestamp:1"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown key")
+
+	// Invalid formats
+	assert.Error(t, v.Verify(message, []byte("garbage")))
+	_, err = NewMinisignVerifier("garbage")
+	assert.Error(t, err)
+}
+
+func TestUpdaterVerifier(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.Nil(t, err)
+
+	data := []byte("Hello World!")
+	newAsset := func(name string, contents []byte) *testAsset {
+		return &testAsset{
+			name: name,
+			write: func(w io.Writer) error {
+				_, err := w.Write(contents)
+				return err
+			},
+		}
+	}
+
+	// Valid signature
+	{
+		b := NewAbortBuffer(nil)
+		u := &Updater{
+			Verifier: NewEd25519Verifier(pub),
+			WriterForAsset: func(a Asset) (AbortWriter, error) {
+				if a.Name() == "myapp" {
+					return b, nil
+				}
+				return nil, nil
+			},
+		}
+
+		r := &testRelease{assets: []Asset{
+			newAsset("myapp", data),
+			newAsset("myapp.sig", ed25519.Sign(priv, data)),
+		}}
+		err := u.UpdateTo(r)
+		assert.Nil(t, err)
+		assert.False(t, b.aborted)
+	}
+
+	// Invalid signature
+	{
+		b := NewAbortBuffer(nil)
+		u := &Updater{
+			Verifier:        NewEd25519Verifier(pub),
+			SignatureSuffix: ".ed25519",
+			WriterForAsset: func(a Asset) (AbortWriter, error) {
+				return b, nil
+			},
+		}
+
+		r := &testRelease{assets: []Asset{
+			newAsset("myapp", data),
+			newAsset("myapp.ed25519", ed25519.Sign(priv, []byte("Other data"))),
+		}}
+		err := u.UpdateTo(r)
+		assert.Error(t, err)
+		assert.True(t, b.aborted)
+	}
+
+	// Missing signature
+	{
+		b := NewAbortBuffer(nil)
+		u := &Updater{
+			Verifier: NewEd25519Verifier(pub),
+			WriterForAsset: func(a Asset) (AbortWriter, error) {
+				return b, nil
+			},
+		}
+
+		err := u.UpdateTo(&testRelease{assets: []Asset{newAsset("myapp", data)}})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "No signature")
+		assert.True(t, b.aborted)
+	}
+}
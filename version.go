@@ -0,0 +1,288 @@
+package updater
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// version is a semantic version.
+type version struct {
+	major, minor, patch int64
+
+	// Dot separated identifiers of the pre-release, if any.
+	pre []string
+}
+
+// parseVersion parses a semantic version, like the tag name v1.2.3-beta.1.
+//
+// A leading "v" is ignored, as is build metadata. Missing minor and patch
+// numbers are zero.
+func parseVersion(s string) (version, error) {
+	var v version
+	t := strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(s), "v"), "V")
+	if i := strings.IndexByte(t, '+'); i >= 0 {
+		t = t[:i]
+	}
+	if i := strings.IndexByte(t, '-'); i >= 0 {
+		v.pre = strings.Split(t[i+1:], ".")
+		t = t[:i]
+		for _, p := range v.pre {
+			if p == "" {
+				return v, fmt.Errorf("Invalid version %v.", s)
+			}
+		}
+	}
+
+	parts := strings.Split(t, ".")
+	if len(parts) > 3 {
+		return v, fmt.Errorf("Invalid version %v.", s)
+	}
+	numbers := []*int64{&v.major, &v.minor, &v.patch}
+	for i, p := range parts {
+		n, err := strconv.ParseInt(p, 10, 64)
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("Invalid version %v.", s)
+		}
+		*numbers[i] = n
+	}
+
+	return v, nil
+}
+
+// compare returns -1, 0 or 1 if v is lower than, equal to or higher than o,
+// using the precedence rules of semantic versioning.
+func (v version) compare(o version) int {
+	for _, d := range []int64{v.major - o.major, v.minor - o.minor, v.patch - o.patch} {
+		if d < 0 {
+			return -1
+		} else if d > 0 {
+			return 1
+		}
+	}
+
+	// A pre-release is lower than the release itself
+	switch {
+	case len(v.pre) == 0 && len(o.pre) == 0:
+		return 0
+	case len(v.pre) == 0:
+		return 1
+	case len(o.pre) == 0:
+		return -1
+	}
+
+	for i := 0; i < len(v.pre) && i < len(o.pre); i++ {
+		if c := comparePrerelease(v.pre[i], o.pre[i]); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case len(v.pre) < len(o.pre):
+		return -1
+	case len(v.pre) > len(o.pre):
+		return 1
+	}
+	return 0
+}
+
+// comparePrerelease compares two pre-release identifiers. Numeric identifiers
+// are lower than alphanumeric ones.
+func comparePrerelease(a, b string) int {
+	x, errA := strconv.ParseUint(a, 10, 64)
+	y, errB := strconv.ParseUint(b, 10, 64)
+	switch {
+	case errA == nil && errB == nil && x < y:
+		return -1
+	case errA == nil && errB == nil && x > y:
+		return 1
+	case errA == nil && errB == nil:
+		return 0
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// versionConstraint is a set of alternatives, of which at least one must
+// match. Every alternative is a list of comparators that must all match.
+type versionConstraint [][]versionComparator
+
+type versionComparator struct {
+	op string
+	v  version
+}
+
+// parseConstraint parses a version range, e.g. ">=1.2.0 <2.0.0".
+//
+// Comparators separated by spaces or commas must all match, alternatives are
+// separated by "||". The operators =, !=, >, >=, < and <= are supported, as
+// well as the tilde and caret ranges of npm: ~1.2.3 (>=1.2.3 <1.3.0), ~1
+// (>=1.0.0 <2.0.0), ^1.2.3 (>=1.2.3 <2.0.0), ^0.2.3 (>=0.2.3 <0.3.0) and
+// ^0.0.3 (>=0.0.3 <0.0.4).
+func parseConstraint(s string) (versionConstraint, error) {
+	var c versionConstraint
+	for _, alt := range strings.Split(s, "||") {
+		fields := strings.FieldsFunc(alt, func(r rune) bool {
+			return r == ' ' || r == ','
+		})
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("Invalid version constraint %v.", s)
+		}
+
+		var comparators []versionComparator
+		for _, f := range fields {
+			cmp, err := parseComparator(f)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid version constraint %v: %v", s, err)
+			}
+			comparators = append(comparators, cmp...)
+		}
+		c = append(c, comparators)
+	}
+
+	return c, nil
+}
+
+// parseComparator parses a single comparator. Tilde and caret ranges are
+// expanded into two comparators.
+func parseComparator(s string) ([]versionComparator, error) {
+	op := ""
+	for _, o := range []string{">=", "<=", "!=", ">", "<", "=", "~", "^"} {
+		if strings.HasPrefix(s, o) {
+			op = o
+			break
+		}
+	}
+	if strings.TrimPrefix(s, op) == "" {
+		return nil, errors.New("Missing version.")
+	}
+
+	v, err := parseVersion(strings.TrimPrefix(s, op))
+	if err != nil {
+		return nil, err
+	}
+
+	switch op {
+	case "~":
+		// Allow patch updates, or minor updates if only a major is given
+		upper := version{major: v.major, minor: v.minor + 1}
+		if versionParts(strings.TrimPrefix(s, op)) == 1 {
+			upper = version{major: v.major + 1}
+		}
+		return []versionComparator{{">=", v}, {"<", upper}}, nil
+	case "^":
+		// Allow updates that do not change the leftmost non-zero number
+		// that is given
+		n := versionParts(strings.TrimPrefix(s, op))
+		switch {
+		case v.major != 0 || n == 1:
+			return []versionComparator{{">=", v}, {"<", version{major: v.major + 1}}}, nil
+		case v.minor != 0 || n == 2:
+			return []versionComparator{{">=", v}, {"<", version{minor: v.minor + 1}}}, nil
+		}
+		return []versionComparator{{">=", v}, {"<", version{patch: v.patch + 1}}}, nil
+	case "":
+		op = "="
+	}
+
+	return []versionComparator{{op, v}}, nil
+}
+
+// versionParts returns how many of the major, minor and patch numbers are
+// given in the version s.
+func versionParts(s string) int {
+	t := strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(s), "v"), "V")
+	if i := strings.IndexAny(t, "-+"); i >= 0 {
+		t = t[:i]
+	}
+	return strings.Count(t, ".") + 1
+}
+
+// match reports whether v satisfies the constraint.
+//
+// Pre-releases only match an alternative that contains a comparator with a
+// pre-release of the same version, so that >=1.0.0 does not match 2.0.0-rc.1.
+func (c versionConstraint) match(v version) bool {
+	for _, comparators := range c {
+		if matchAll(comparators, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAll(comparators []versionComparator, v version) bool {
+	allowPre := len(v.pre) == 0
+	for _, cmp := range comparators {
+		if !cmp.match(v) {
+			return false
+		}
+
+		w := cmp.v
+		if len(w.pre) != 0 && w.major == v.major && w.minor == v.minor && w.patch == v.patch {
+			allowPre = true
+		}
+	}
+	return allowPre
+}
+
+func (c versionComparator) match(v version) bool {
+	d := v.compare(c.v)
+	switch c.op {
+	case "!=":
+		return d != 0
+	case ">":
+		return d > 0
+	case ">=":
+		return d >= 0
+	case "<":
+		return d < 0
+	case "<=":
+		return d <= 0
+	}
+	return d == 0
+}
+
+// constrainedRelease returns the highest release allowed by the version
+// constraint of the updater, or nil if there is no such release that is newer
+// than the current release.
+//
+// The versions are parsed from the names of the releases. Releases whose name
+// is not a version are ignored. The current version is found as described for
+// UpgradeRequirement, so that the updater does not downgrade even if the
+// application no longer lists the current release.
+func (u *Updater) constrainedRelease() (Release, error) {
+	c, err := parseConstraint(u.Constraint)
+	if err != nil {
+		return nil, err
+	}
+
+	releases := []Release{u.App.LatestRelease()}
+	if app, ok := u.App.(ReleasesApp); ok {
+		releases = app.Releases()
+	}
+
+	var best Release
+	var bestVersion version
+	for _, r := range releases {
+		v, err := parseVersion(r.Name())
+		if err != nil {
+			continue
+		}
+
+		if c.match(v) && (best == nil || v.compare(bestVersion) > 0) {
+			best, bestVersion = r, v
+		}
+	}
+
+	// Never go back to an older version
+	if best == nil {
+		return nil, nil
+	} else if current, _, ok := u.currentVersion(); ok && bestVersion.compare(current) <= 0 {
+		return nil, nil
+	}
+	return best, nil
+}
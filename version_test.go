@@ -0,0 +1,150 @@
+package updater
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseVersion(t *testing.T) {
+	v, err := parseVersion("v1.2.3-beta.1+build.5")
+	assert.Nil(t, err)
+	assert.Equal(t, version{major: 1, minor: 2, patch: 3, pre: []string{"beta", "1"}}, v)
+
+	v, err = parseVersion("2.1")
+	assert.Nil(t, err)
+	assert.Equal(t, version{major: 2, minor: 1}, v)
+
+	for _, s := range []string{"", "latest", "v1.2.3.4", "1.x", "1.2.3-", "1.2.3-beta..1"} {
+		_, err := parseVersion(s)
+		assert.Error(t, err, s)
+	}
+
+	// Precedence
+	ordered := []string{
+		"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta",
+		"1.0.0-beta.2", "1.0.0-beta.11", "1.0.0-rc.1", "1.0.0", "1.0.1",
+		"1.2.0", "1.10.0", "2.0.0",
+	}
+	for i := 1; i < len(ordered); i++ {
+		a, err := parseVersion(ordered[i-1])
+		require.Nil(t, err)
+		b, err := parseVersion(ordered[i])
+		require.Nil(t, err)
+		assert.Equal(t, -1, a.compare(b), "%v < %v", ordered[i-1], ordered[i])
+		assert.Equal(t, 1, b.compare(a), "%v > %v", ordered[i], ordered[i-1])
+		assert.Equal(t, 0, a.compare(a))
+	}
+}
+
+func TestVersionConstraint(t *testing.T) {
+	tests := map[string]map[string]bool{
+		">=1.2.0 <2.0.0": {"1.2.0": true, "1.9.9": true, "2.0.0": false, "1.1.9": false, "2.0.0-rc.1": false},
+		">=1.2.0, <2":    {"1.5.0": true, "2.0.0": false},
+		"^1.2.3":         {"1.2.3": true, "1.9.0": true, "2.0.0": false, "1.2.2": false},
+		"^0.2.3":         {"0.2.9": true, "0.3.0": false},
+		"^0.0.3":         {"0.0.3": true, "0.0.4": false, "0.1.0": false},
+		"^0.0":           {"0.0.9": true, "0.1.0": false},
+		"^0":             {"0.9.0": true, "1.0.0": false},
+		"^1.2":           {"1.2.0": true, "1.9.0": true, "2.0.0": false},
+		"~1.2.3":         {"1.2.9": true, "1.3.0": false},
+		"~1.2":           {"1.2.0": true, "1.2.9": true, "1.3.0": false},
+		"~1":             {"1.0.0": true, "1.9.0": true, "2.0.0": false},
+		"1.2.3":          {"1.2.3": true, "1.2.4": false},
+		"!=1.2.3":        {"1.2.3": false, "1.2.4": true},
+		"<1.0.0 || >=3":  {"0.9.0": true, "2.0.0": false, "3.1.0": true},
+		">=2.0.0-rc.1":   {"2.0.0-rc.2": true, "2.0.1-rc.1": false, "2.0.1": true},
+	}
+
+	for s, versions := range tests {
+		c, err := parseConstraint(s)
+		require.Nil(t, err, "Could not parse %v: %v", s, err)
+		for v, ok := range versions {
+			parsed, err := parseVersion(v)
+			require.Nil(t, err)
+			assert.Equal(t, ok, c.match(parsed), "%v matches %v", v, s)
+		}
+	}
+
+	for _, s := range []string{"", ">=", "1.2.3 ||", ">=latest"} {
+		_, err := parseConstraint(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestUpdaterConstraint(t *testing.T) {
+	releases := []Release{
+		&testRelease{name: "v2.0.0", identifier: "5"},
+		&testRelease{name: "v1.3.0", identifier: "4"},
+		&testRelease{name: "nightly", identifier: "3"},
+		&testRelease{name: "v1.2.0", identifier: "2"},
+		&testRelease{name: "v1.1.0", identifier: "1"},
+	}
+	app := &testReleasesApp{releases: releases}
+	app.FLatestRelease = func() Release { return releases[0] }
+
+	// Highest matching release
+	{
+		u := &Updater{App: app, CurrentReleaseIdentifier: "1", Constraint: ">=1.2.0 <2.0.0"}
+		r, err := u.Check()
+		assert.Nil(t, err)
+		assert.Equal(t, releases[1], r)
+	}
+
+	// Already on the highest matching release
+	{
+		u := &Updater{App: app, CurrentReleaseIdentifier: "4", Constraint: "^1.0.0"}
+		r, err := u.Check()
+		assert.Nil(t, err)
+		assert.Nil(t, r)
+	}
+
+	// Never downgrade
+	{
+		u := &Updater{App: app, CurrentReleaseIdentifier: "5", Constraint: "^1.0.0"}
+		r, err := u.Check()
+		assert.Nil(t, err)
+		assert.Nil(t, r)
+	}
+
+	// Never downgrade when the current release is no longer listed
+	{
+		u := &Updater{App: app, CurrentReleaseIdentifier: "v1.5.0", Constraint: "^1.0.0"}
+		r, err := u.Check()
+		assert.Nil(t, err)
+		assert.Nil(t, r)
+	}
+
+	// Never downgrade to a release older than the last applied one
+	{
+		dir, err := ioutil.TempDir("", "testing-")
+		require.Nil(t, err)
+		defer os.RemoveAll(dir)
+
+		store := NewFileStateStore(filepath.Join(dir, "state.json"))
+		require.Nil(t, store.Save(&State{LastApplied: "6", LastAppliedName: "v1.5.0"}))
+		u := &Updater{App: app, CurrentReleaseIdentifier: "6", Constraint: "^1.0.0", State: store}
+		r, err := u.Check()
+		assert.Nil(t, err)
+		assert.Nil(t, r)
+	}
+
+	// No matching release
+	{
+		u := &Updater{App: app, CurrentReleaseIdentifier: "1", Constraint: ">=3.0.0"}
+		r, err := u.Check()
+		assert.Nil(t, err)
+		assert.Nil(t, r)
+	}
+
+	// Invalid constraint
+	{
+		u := &Updater{App: app, CurrentReleaseIdentifier: "1", Constraint: ">=one"}
+		_, err := u.Check()
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,169 @@
+package updater
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MaintenanceWindow is a time range in which updates may be applied, e.g.
+// every night between 02:00 and 04:00, see AutoUpdater.
+type MaintenanceWindow struct {
+	// Days of the week on which the window starts. Every day if empty.
+	Days []time.Weekday
+
+	// Start of the window, as the time since midnight.
+	Start time.Duration
+
+	// End of the window, as the time since midnight.
+	//
+	// If it is not after Start, the window ends on the next day, e.g. for a
+	// window from 22:00 to 06:00.
+	End time.Duration
+
+	// Location of the times. Defaults to the local time zone.
+	Location *time.Location
+}
+
+// weekdays are the abbreviated names of the days of the week, as accepted by
+// ParseMaintenanceWindow.
+var weekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// ParseMaintenanceWindow parses a window in the local time zone, like
+// "02:00-04:00" for every day, or "Mon-Fri 22:00-06:00" and "Sat,Sun
+// 00:00-24:00" for some days of the week.
+//
+// The days are abbreviated names of the days of the week, separated by
+// commas, or ranges of days.
+func ParseMaintenanceWindow(s string) (MaintenanceWindow, error) {
+	var w MaintenanceWindow
+	fields := strings.Fields(s)
+	if len(fields) == 0 || len(fields) > 2 {
+		return w, fmt.Errorf("Invalid maintenance window: %v", s)
+	}
+
+	if len(fields) == 2 {
+		for _, d := range strings.Split(fields[0], ",") {
+			days, err := parseWeekdays(d)
+			if err != nil {
+				return w, fmt.Errorf("Invalid maintenance window %v: %v", s, err)
+			}
+			w.Days = append(w.Days, days...)
+		}
+	}
+
+	times := strings.Split(fields[len(fields)-1], "-")
+	if len(times) != 2 {
+		return w, fmt.Errorf("Invalid maintenance window: %v", s)
+	}
+	var err error
+	if w.Start, err = parseTimeOfDay(times[0]); err != nil {
+		return w, fmt.Errorf("Invalid maintenance window %v: %v", s, err)
+	}
+	if w.End, err = parseTimeOfDay(times[1]); err != nil {
+		return w, fmt.Errorf("Invalid maintenance window %v: %v", s, err)
+	}
+	return w, nil
+}
+
+// parseWeekdays parses a day of the week, or a range of days like Mon-Fri.
+func parseWeekdays(s string) ([]time.Weekday, error) {
+	parts := strings.Split(s, "-")
+	if len(parts) > 2 {
+		return nil, fmt.Errorf("Invalid days: %v", s)
+	}
+
+	var bounds []time.Weekday
+	for _, p := range parts {
+		d := -1
+		for i, name := range weekdays {
+			if strings.EqualFold(p, name) {
+				d = i
+			}
+		}
+		if d < 0 {
+			return nil, fmt.Errorf("Invalid day: %v", p)
+		}
+		bounds = append(bounds, time.Weekday(d))
+	}
+
+	days := []time.Weekday{bounds[0]}
+	for d := bounds[0]; d != bounds[len(bounds)-1]; {
+		d = (d + 1) % 7
+		days = append(days, d)
+	}
+	return days, nil
+}
+
+// parseTimeOfDay parses a time like 22:30 as the time since midnight. The end
+// of the day is 24:00.
+func parseTimeOfDay(s string) (time.Duration, error) {
+	var h, m int
+	_, err := fmt.Sscanf(s, "%d:%d", &h, &m)
+	if err != nil || len(s) != 5 || h < 0 || m < 0 || m > 59 || h*60+m > 24*60 {
+		return 0, fmt.Errorf("Invalid time: %v", s)
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute, nil
+}
+
+// Contains reports whether t is within the window.
+func (w MaintenanceWindow) Contains(t time.Time) bool {
+	t = t.In(w.location())
+
+	// The window may have started yesterday
+	for i := 0; i >= -1; i-- {
+		start, ok := w.startOn(t, i)
+		if ok && !t.Before(start) && t.Before(start.Add(w.length())) {
+			return true
+		}
+	}
+	return false
+}
+
+// next returns t if it is within the window, or else the time at which the
+// window opens next.
+func (w MaintenanceWindow) next(t time.Time) time.Time {
+	if w.Contains(t) {
+		return t
+	}
+
+	t = t.In(w.location())
+	for i := 0; i <= 7; i++ {
+		start, ok := w.startOn(t, i)
+		if ok && start.After(t) {
+			return start
+		}
+	}
+	return time.Time{}
+}
+
+// startOn returns the start of the window on the day that is days after the
+// day of t, and whether the window starts on that day.
+func (w MaintenanceWindow) startOn(t time.Time, days int) (time.Time, bool) {
+	y, m, d := t.Date()
+	midnight := time.Date(y, m, d+days, 0, 0, 0, 0, t.Location())
+	if len(w.Days) == 0 {
+		return midnight.Add(w.Start), true
+	}
+	for _, day := range w.Days {
+		if day == midnight.Weekday() {
+			return midnight.Add(w.Start), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// length returns the duration of the window.
+func (w MaintenanceWindow) length() time.Duration {
+	if w.End > w.Start {
+		return w.End - w.Start
+	}
+	return w.End - w.Start + 24*time.Hour
+}
+
+func (w MaintenanceWindow) location() *time.Location {
+	if w.Location != nil {
+		return w.Location
+	}
+	return time.Local
+}
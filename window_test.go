@@ -0,0 +1,66 @@
+package updater
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaintenanceWindow(t *testing.T) {
+	at := func(s string) time.Time {
+		// 2024-01-01 is a Monday
+		tm, err := time.ParseInLocation("2006-01-02 15:04", s, time.UTC)
+		require.Nil(t, err)
+		return tm
+	}
+
+	// Parse windows
+	{
+		w, err := ParseMaintenanceWindow("02:00-04:30")
+		require.Nil(t, err)
+		assert.Nil(t, w.Days)
+		assert.Equal(t, 2*time.Hour, w.Start)
+		assert.Equal(t, 4*time.Hour+30*time.Minute, w.End)
+
+		w, err = ParseMaintenanceWindow("Fri-Mon,wed 22:00-24:00")
+		require.Nil(t, err)
+		assert.Equal(t, []time.Weekday{time.Friday, time.Saturday, time.Sunday, time.Monday, time.Wednesday}, w.Days)
+		assert.Equal(t, 24*time.Hour, w.End)
+
+		for _, s := range []string{"", "02:00", "Mon", "Mon 2:00-04:00", "Mon 02:00-24:01", "Someday 02:00-04:00", "Mon Tue 02:00-04:00"} {
+			_, err := ParseMaintenanceWindow(s)
+			assert.NotNil(t, err, "Parsed %q", s)
+		}
+	}
+
+	// Window within a day
+	{
+		w := MaintenanceWindow{Days: []time.Weekday{time.Monday}, Start: 2 * time.Hour, End: 4 * time.Hour, Location: time.UTC}
+		assert.False(t, w.Contains(at("2024-01-01 01:59")))
+		assert.True(t, w.Contains(at("2024-01-01 02:00")))
+		assert.True(t, w.Contains(at("2024-01-01 03:59")))
+		assert.False(t, w.Contains(at("2024-01-01 04:00")))
+		assert.False(t, w.Contains(at("2024-01-02 03:00")))
+
+		assert.Equal(t, at("2024-01-01 02:00"), w.next(at("2024-01-01 01:00")))
+		assert.Equal(t, at("2024-01-01 03:00"), w.next(at("2024-01-01 03:00")))
+		assert.Equal(t, at("2024-01-08 02:00"), w.next(at("2024-01-01 05:00")))
+	}
+
+	// Window spanning midnight
+	{
+		w := MaintenanceWindow{Days: []time.Weekday{time.Friday}, Start: 22 * time.Hour, End: 6 * time.Hour, Location: time.UTC}
+		assert.True(t, w.Contains(at("2024-01-05 23:00")))
+		assert.True(t, w.Contains(at("2024-01-06 05:00")))
+		assert.False(t, w.Contains(at("2024-01-06 23:00")))
+		assert.False(t, w.Contains(at("2024-01-05 05:00")))
+	}
+
+	// Every day
+	{
+		w := MaintenanceWindow{Start: 2 * time.Hour, End: 4 * time.Hour, Location: time.UTC}
+		assert.Equal(t, at("2024-01-02 02:00"), w.next(at("2024-01-01 05:00")))
+	}
+}
@@ -6,10 +6,30 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"sync"
 )
 
-const atomicFilePrefix = "atomic-"
+const atomicFilePrefix = tempPrefix + "atomic-"
+
+// Durability is how hard a DelayedFile tries to survive a crash or power loss
+// right after it is committed.
+type Durability int
+
+const (
+	// DurabilityFull syncs the file to disk before it is renamed to its
+	// destination, and syncs the directory of the destination afterwards, so
+	// that the rename itself is durable. This is the default.
+	DurabilityFull Durability = iota
+
+	// DurabilityFile only syncs the file before it is renamed. After a crash,
+	// the destination is either the old or the complete new file.
+	DurabilityFile
+
+	// DurabilityNone leaves syncing to the operating system. This is the
+	// fastest, but a crash can leave an empty or partially written file.
+	DurabilityNone
+)
 
 // AbortWriter is a writer that can be aborted.
 type AbortWriter interface {
@@ -25,6 +45,21 @@ type AbortWriter interface {
 type FileBuffer struct {
 	Path string
 
+	// Directory of the temporary file. Defaults to the temporary directory.
+	//
+	// A file can only be renamed within a file system, so use a directory on
+	// the file system of the destination when the file is renamed, e.g. the
+	// directory of the destination.
+	Dir string
+
+	// Prefix of the name of the temporary file. Defaults to "atomic-".
+	Prefix string
+
+	// Permissions of the file. When zero, temporary files are only accessible
+	// by the current user, and other files are created with mode 0666 before
+	// the umask.
+	Mode os.FileMode
+
 	opener    sync.Once
 	openError error
 	handle    *os.File
@@ -40,11 +75,20 @@ func (a *FileBuffer) Write(b []byte) (int, error) {
 	// Open the file
 	a.opener.Do(func() {
 		if a.Path == "" {
-			a.handle, a.openError = ioutil.TempFile("", "atomic-")
-			a.Path = a.handle.Name()
+			prefix := a.Prefix
+			if prefix == "" {
+				prefix = atomicFilePrefix
+			}
+			a.handle, a.openError = ioutil.TempFile(a.Dir, prefix)
+			if a.openError == nil {
+				a.Path = a.handle.Name()
+			}
 		} else {
 			a.handle, a.openError = os.Create(a.Path)
 		}
+		if a.openError == nil && a.Mode != 0 {
+			a.openError = a.handle.Chmod(a.Mode)
+		}
 	})
 	if a.openError != nil {
 		return 0, a.openError
@@ -77,24 +121,134 @@ func (a *FileBuffer) Close() error {
 // This file type can be used to assure that all data is correctly received from
 // an unreliable source, before the final destination file is written to.
 type DelayedFile struct {
+	// Permissions of the destination file.
+	//
+	// When zero, the permissions of an existing destination file are kept.
+	// New files get mode 0644, or 0755 when created with NewExecutableFile.
+	Mode os.FileMode
+
+	// Owner of the destination file, only supported on Unix.
+	//
+	// When nil, the owner of an existing destination file is kept if
+	// possible.
+	Owner *FileOwner
+
+	// Elevator used to move the file to its destination when the current
+	// user cannot write to the destination directory.
+	//
+	// The temporary file is then created in the temporary directory, unless
+	// TempDir is set. The Owner is not applied to elevated files.
+	Elevator Elevator
+
+	// Directory of the temporary file. Defaults to the directory of the
+	// destination, so that the temporary file can be renamed to the
+	// destination, or to the temporary directory if the current user cannot
+	// write to the directory of the destination, e.g. when the Elevator is
+	// used.
+	TempDir string
+
+	// Durability of the committed file. Defaults to DurabilityFull.
+	Durability Durability
+
 	path string
 
-	buffer  FileBuffer
-	aborted bool
+	buffer      FileBuffer
+	aborted     bool
+	staged      bool
+	rename      func(src, dst string) error
+	tx          *Transaction
+	group       *AtomicGroup
+	defaultMode os.FileMode
+}
+
+// FileOwner is the user and group owning a file.
+type FileOwner struct {
+	UID int
+	GID int
 }
 
 // NewDelayedFile creates a new delayed file.
 func NewDelayedFile(path string) *DelayedFile {
 	return &DelayedFile{
-		path: path,
+		path:        path,
+		defaultMode: 0644,
 	}
 }
 
 // Write data to the temporary file.
+//
+// The temporary file gets the permissions of the destination file, so that
+// e.g. a new executable can be run before it is committed.
 func (f *DelayedFile) Write(b []byte) (int, error) {
+	if f.buffer.handle == nil {
+		if f.buffer.Path == "" && f.buffer.Dir == "" {
+			f.buffer.Dir = f.tempDir()
+			f.buffer.Prefix = "." + filepath.Base(f.path) + ".tmp-"
+		}
+		f.buffer.Mode, _ = f.mode()
+	}
 	return f.buffer.Write(b)
 }
 
+// mode returns the permissions of the destination file and the owner of an
+// existing destination file, see Mode.
+func (f *DelayedFile) mode() (os.FileMode, *FileOwner) {
+	mode := f.defaultMode
+	var owner *FileOwner
+	if info, _ := os.Stat(f.path); info != nil {
+		mode = info.Mode()
+		owner = fileOwner(info)
+	}
+	if f.Mode != 0 {
+		mode = f.Mode
+	}
+	return mode, owner
+}
+
+// tempDir returns the directory of the temporary file, see TempDir.
+func (f *DelayedFile) tempDir() string {
+	if f.TempDir != "" {
+		return f.TempDir
+	}
+
+	dir := filepath.Dir(f.path)
+	if !dirWritable(dir) {
+		return os.TempDir()
+	}
+	return dir
+}
+
+// stager is a writer whose staged file must be completed before it can be
+// validated, e.g. closed so that it can be executed.
+type stager interface {
+	stage() error
+}
+
+// stage syncs and closes the temporary file, so that it can be validated
+// before it is committed.
+func (f *DelayedFile) stage() error {
+	if f.buffer.handle == nil || f.staged {
+		return nil
+	}
+	f.staged = true
+
+	var err error
+	if f.Durability != DurabilityNone {
+		err = f.buffer.handle.Sync()
+	}
+	if cerr := f.buffer.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// TempPath returns the path of the temporary file.
+//
+// It is empty until data has been written to the file.
+func (f *DelayedFile) TempPath() string {
+	return f.buffer.Path
+}
+
 // Abort will stop the file from copying its contents to the final destination
 // when the file is closed.
 func (f *DelayedFile) Abort() {
@@ -105,37 +259,123 @@ func (f *DelayedFile) Abort() {
 //
 // If Abort was called before closing the file, the contents will not be copied
 // to the final destination.
+//
+// Closing a file that is part of a transaction does nothing, the transaction
+// will copy or discard its contents. Closing the last file of an AtomicGroup
+// commits all files of the group.
 func (f *DelayedFile) Close() error {
+	if f.tx != nil {
+		return nil
+	} else if f.group != nil {
+		return f.group.close(f)
+	}
+
+	return f.commit()
+}
+
+// commit closes the temporary file and moves it to the final destination.
+func (f *DelayedFile) commit() error {
 	// Delete the temporary file
 	defer os.Remove(f.buffer.Path)
 
-	// Close the temporary file
-	f.buffer.Close()
+	// Sync and close the temporary file, unless it was staged already
+	var err error
+	if !f.staged {
+		if f.buffer.handle != nil && !f.aborted && f.Durability != DurabilityNone {
+			err = f.buffer.handle.Sync()
+		}
+		f.buffer.Close()
+	}
 
 	// Don't copy if aborted
 	if f.aborted {
 		return nil
+	} else if err != nil {
+		return err
 	}
 
+	// Keep the permissions and owner of the existing file
+	mode, owner := f.mode()
+
 	// Rename
-	var mode *os.FileMode
-	if info, _ := os.Stat(f.path); info != nil {
-		m := info.Mode()
-		mode = &m
+	err = f.renameFunc()(f.buffer.Path, f.path)
+	if err != nil && f.Elevator != nil && !dirWritable(filepath.Dir(f.path)) {
+		return f.Elevator.Install(f.buffer.Path, f.path, mode)
+	} else if err != nil && filepath.Dir(f.buffer.Path) != filepath.Dir(f.path) {
+		// The temporary file may be on another file system
+		if cerr := f.copyRename(f.buffer.Path); cerr != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
 	}
 
-	err := os.Rename(f.buffer.Path, f.path)
+	err = os.Chmod(f.path, mode)
 	if err != nil {
 		return err
 	}
 
-	if mode != nil {
-		return os.Chmod(f.path, *mode)
+	if f.Owner != nil {
+		err = os.Chown(f.path, f.Owner.UID, f.Owner.GID)
+		if err != nil {
+			return err
+		}
+	} else if owner != nil {
+		// Only privileged users can give files away
+		os.Chown(f.path, owner.UID, owner.GID)
 	}
 
+	if f.Durability == DurabilityFull {
+		return syncDir(filepath.Dir(f.path))
+	}
 	return nil
 }
 
+// copyRename copies src to a temporary file in the directory of the
+// destination and renames it to the destination, for when src is on another
+// file system. The copy is synced first, unless the durability is
+// DurabilityNone, so the destination is still replaced atomically.
+func (f *DelayedFile) copyRename(src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := ioutil.TempFile(filepath.Dir(f.path), "."+filepath.Base(f.path)+".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name())
+
+	_, err = io.Copy(out, in)
+	if err == nil && f.Durability != DurabilityNone {
+		err = out.Sync()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	return f.renameFunc()(out.Name(), f.path)
+}
+
+// discard closes and deletes the temporary file.
+func (f *DelayedFile) discard() {
+	f.buffer.Close()
+	os.Remove(f.buffer.Path)
+}
+
+// renameFunc returns the function used to move files to the destination.
+func (f *DelayedFile) renameFunc() func(src, dst string) error {
+	if f.rename != nil {
+		return f.rename
+	}
+	return os.Rename
+}
+
 // AbortBuffer is a buffer that can be aborted.
 type AbortBuffer struct {
 	Buffer *bytes.Buffer
@@ -165,3 +405,101 @@ func (a *AbortBuffer) Write(b []byte) (int, error) {
 func (a *AbortBuffer) Abort() {
 	a.aborted = true
 }
+
+// TeeAbortWriter duplicates its writes to multiple abort writers, like
+// io.MultiWriter.
+//
+// Use it to write an asset to a DelayedFile and compute its hash or report its
+// progress at the same time. Aborting the tee aborts all writers, and closing
+// it closes all writers that implement io.Closer.
+type TeeAbortWriter struct {
+	writers []AbortWriter
+}
+
+// NewTeeAbortWriter creates a writer that duplicates its writes to all
+// writers. Wrap plain writers, like a hash, with NopAbortWriter.
+func NewTeeAbortWriter(writers ...AbortWriter) *TeeAbortWriter {
+	return &TeeAbortWriter{
+		writers: append([]AbortWriter(nil), writers...),
+	}
+}
+
+// Write writes b to all writers, in order. It stops at the first writer that
+// fails and returns its error.
+func (t *TeeAbortWriter) Write(b []byte) (int, error) {
+	for _, w := range t.writers {
+		n, err := w.Write(b)
+		if err != nil {
+			return n, err
+		} else if n != len(b) {
+			return n, io.ErrShortWrite
+		}
+	}
+	return len(b), nil
+}
+
+// Abort aborts all writers.
+func (t *TeeAbortWriter) Abort() {
+	for _, w := range t.writers {
+		w.Abort()
+	}
+}
+
+// Close closes all writers that implement io.Closer, and returns the first
+// error.
+func (t *TeeAbortWriter) Close() error {
+	var firstErr error
+	for _, w := range t.writers {
+		if c, ok := w.(io.Closer); ok {
+			if err := c.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// TempPath returns the temporary path of the first writer that has one, e.g.
+// a DelayedFile, so the file can be validated before it is committed.
+func (t *TeeAbortWriter) TempPath() string {
+	for _, w := range t.writers {
+		if s, ok := w.(interface{ TempPath() string }); ok && s.TempPath() != "" {
+			return s.TempPath()
+		}
+	}
+	return ""
+}
+
+// stage stages the writers that must complete their staged file before it is
+// validated.
+func (t *TeeAbortWriter) stage() error {
+	for _, w := range t.writers {
+		if s, ok := w.(stager); ok {
+			if err := s.stage(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// setTotal informs the writers that want to know the total size of the asset.
+func (t *TeeAbortWriter) setTotal(total int64) {
+	for _, w := range t.writers {
+		if s, ok := w.(totalSetter); ok {
+			s.setTotal(total)
+		}
+	}
+}
+
+// NopAbortWriter returns an AbortWriter that writes to w and ignores aborts,
+// e.g. to add a hash to a TeeAbortWriter.
+func NopAbortWriter(w io.Writer) AbortWriter {
+	return nopAbortWriter{w}
+}
+
+type nopAbortWriter struct {
+	io.Writer
+}
+
+func (nopAbortWriter) Abort() {}
@@ -0,0 +1,16 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package updater
+
+import "os"
+
+// syncDir does nothing, directories cannot be synced on this platform.
+func syncDir(dir string) error {
+	return nil
+}
+
+// fileOwner returns nil, file owners are not supported on this platform.
+func fileOwner(info os.FileInfo) *FileOwner {
+	return nil
+}
@@ -1,8 +1,14 @@
 package updater
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -118,12 +124,44 @@ func TestFileBuffer(t *testing.T) {
 		assert.Error(t, err)
 	}
 
+	// Mode
+	{
+		fb := &FileBuffer{Mode: 0640}
+		_, err := fb.Write([]byte("hello world"))
+		assert.Nil(t, err, "Could not write: %v", err)
+		fb.Close()
+		defer os.Remove(fb.Path)
+
+		info, err := os.Stat(fb.Path)
+		require.Nil(t, err)
+		assert.EqualValues(t, 0640, info.Mode())
+	}
+
 	// Close unopend file
 	{
 		b := &FileBuffer{}
 		err := b.Close()
 		assert.Nil(t, err)
 	}
+
+	// Directory and prefix
+	{
+		dir, err := ioutil.TempDir("", "testing-")
+		require.Nil(t, err)
+		defer os.RemoveAll(dir)
+
+		fb := &FileBuffer{Dir: dir, Prefix: "myapp-"}
+		_, err = fb.Write([]byte("hello world"))
+		require.Nil(t, err)
+		fb.Close()
+		assert.Equal(t, dir, filepath.Dir(fb.Path))
+		assert.True(t, strings.HasPrefix(filepath.Base(fb.Path), "myapp-"))
+
+		fb = &FileBuffer{Dir: filepath.Join(dir, "nonexisting")}
+		_, err = fb.Write([]byte("hello world"))
+		assert.NotNil(t, err)
+		assert.Equal(t, "", fb.Path)
+	}
 }
 
 func TestDelayedFile(t *testing.T) {
@@ -158,6 +196,79 @@ func TestDelayedFile(t *testing.T) {
 		assert.Nil(t, err, "Could not clean up: %v", err)
 	}
 
+	// Temporary file next to the destination
+	{
+		dir, err := ioutil.TempDir("", "testing-")
+		require.Nil(t, err)
+		defer os.RemoveAll(dir)
+
+		df := NewDelayedFile(filepath.Join(dir, "file"))
+		_, err = df.Write([]byte("hello world"))
+		require.Nil(t, err)
+		assert.Equal(t, dir, filepath.Dir(df.TempPath()))
+		assert.Nil(t, df.Close())
+
+		other, err := ioutil.TempDir("", "testing-")
+		require.Nil(t, err)
+		defer os.RemoveAll(other)
+		df = NewDelayedFile(filepath.Join(dir, "file"))
+		df.TempDir = other
+		_, err = df.Write([]byte("hello world"))
+		require.Nil(t, err)
+		assert.Equal(t, other, filepath.Dir(df.TempPath()))
+		assert.Nil(t, df.Close())
+	}
+
+	// Durability levels
+	for _, durability := range []Durability{DurabilityFull, DurabilityFile, DurabilityNone} {
+		dir, err := ioutil.TempDir("", "testing-")
+		require.Nil(t, err)
+		defer os.RemoveAll(dir)
+
+		df := NewDelayedFile(filepath.Join(dir, "file"))
+		df.Durability = durability
+		_, err = df.Write([]byte("hello world"))
+		require.Nil(t, err)
+		err = df.Close()
+		require.Nil(t, err, "Could not close file with durability %v: %v", durability, err)
+
+		b, err := ioutil.ReadFile(filepath.Join(dir, "file"))
+		require.Nil(t, err)
+		assert.Equal(t, "hello world", string(b))
+	}
+
+	// Temporary file on another file system
+	{
+		dir, err := ioutil.TempDir("", "testing-")
+		require.Nil(t, err)
+		defer os.RemoveAll(dir)
+		other, err := ioutil.TempDir("", "testing-")
+		require.Nil(t, err)
+		defer os.RemoveAll(other)
+
+		df := NewDelayedFile(filepath.Join(dir, "file"))
+		df.TempDir = other
+		df.rename = func(src, dst string) error {
+			// The error of the rename depends on the operating system
+			if filepath.Dir(src) != filepath.Dir(dst) {
+				return &os.LinkError{Op: "rename", Old: src, New: dst, Err: errors.New("cross-device link")}
+			}
+			return os.Rename(src, dst)
+		}
+		_, err = df.Write([]byte("hello world"))
+		require.Nil(t, err)
+		err = df.Close()
+		require.Nil(t, err, "Could not close file: %v", err)
+
+		b, err := ioutil.ReadFile(filepath.Join(dir, "file"))
+		require.Nil(t, err)
+		assert.Equal(t, "hello world", string(b))
+		entries, _ := ioutil.ReadDir(dir)
+		assert.Equal(t, 1, len(entries))
+		entries, _ = ioutil.ReadDir(other)
+		assert.Equal(t, 0, len(entries))
+	}
+
 	// Invalid destination file
 	{
 		// Write
@@ -227,6 +338,63 @@ func TestDelayedFile(t *testing.T) {
 		_, err = os.Stat(path)
 		assert.True(t, os.IsNotExist(err))
 	}
+
+	// Permissions
+	{
+		dir, err := ioutil.TempDir("", "testing-")
+		require.Nil(t, err)
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "file")
+
+		// New file
+		df := NewDelayedFile(path)
+		df.Write([]byte("hello world"))
+		err = df.Close()
+		assert.Nil(t, err, "Could not close file: %v", err)
+		info, err := os.Stat(path)
+		require.Nil(t, err)
+		assert.EqualValues(t, 0644, info.Mode())
+
+		// Existing file
+		err = os.Chmod(path, 0600)
+		require.Nil(t, err)
+		df = NewDelayedFile(path)
+		df.Write([]byte("hello world"))
+		err = df.Close()
+		assert.Nil(t, err, "Could not close file: %v", err)
+		info, err = os.Stat(path)
+		require.Nil(t, err)
+		assert.EqualValues(t, 0600, info.Mode())
+
+		// Configured mode
+		df = NewDelayedFile(path)
+		df.Mode = 0750
+		df.Write([]byte("hello world"))
+		err = df.Close()
+		assert.Nil(t, err, "Could not close file: %v", err)
+		info, err = os.Stat(path)
+		require.Nil(t, err)
+		assert.EqualValues(t, 0750, info.Mode())
+
+		// New executable
+		exe := filepath.Join(dir, "exe")
+		df = NewExecutableFile(exe)
+		df.Write([]byte("hello world"))
+		err = df.Close()
+		assert.Nil(t, err, "Could not close file: %v", err)
+		info, err = os.Stat(exe)
+		require.Nil(t, err)
+		assert.EqualValues(t, 0755, info.Mode())
+
+		// Owner
+		if runtime.GOOS != "windows" {
+			df = NewDelayedFile(path)
+			df.Owner = &FileOwner{UID: os.Getuid(), GID: os.Getgid()}
+			df.Write([]byte("hello world"))
+			err = df.Close()
+			assert.Nil(t, err, "Could not close file: %v", err)
+		}
+	}
 }
 
 func TestAbortBuffer(t *testing.T) {
@@ -297,3 +465,43 @@ func ExampleAbortBuffer() {
 		panic(err)
 	}
 }
+
+func TestTeeAbortWriter(t *testing.T) {
+	// Write to all writers
+	{
+		dir, err := ioutil.TempDir("", "tee-")
+		require.Nil(t, err)
+		defer os.RemoveAll(dir)
+
+		f := NewDelayedFile(filepath.Join(dir, "myapp"))
+		b := NewAbortBuffer(nil)
+		h := sha256.New()
+		tee := NewTeeAbortWriter(f, b, NopAbortWriter(h))
+
+		n, err := tee.Write([]byte("Hello World!"))
+		assert.Nil(t, err)
+		assert.Equal(t, 12, n)
+		assert.Equal(t, f.TempPath(), tee.TempPath())
+		assert.Nil(t, tee.Close())
+
+		data, err := ioutil.ReadFile(filepath.Join(dir, "myapp"))
+		assert.Nil(t, err)
+		assert.Equal(t, "Hello World!", string(data))
+		assert.Equal(t, "Hello World!", b.Buffer.String())
+		assert.Equal(t, "7f83b1657ff1fc53b92dc18148a1d65dfc2d4b1fa3d677284addd200126d9069", hex.EncodeToString(h.Sum(nil)))
+	}
+
+	// Abort all writers
+	{
+		b1, b2 := NewAbortBuffer(nil), NewAbortBuffer(nil)
+		tee := NewTeeAbortWriter(b1, b2)
+		tee.Abort()
+		assert.True(t, b1.aborted)
+		assert.True(t, b2.aborted)
+
+		_, err := tee.Write([]byte("Hello World!"))
+		assert.NotNil(t, err)
+		assert.Equal(t, "", tee.TempPath())
+		assert.Nil(t, tee.Close())
+	}
+}
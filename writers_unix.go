@@ -0,0 +1,34 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package updater
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// syncDir syncs the directory dir to disk, making renames in it durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	err = d.Sync()
+	if errors.Is(err, syscall.EINVAL) {
+		// The file system does not support syncing directories
+		return nil
+	}
+	return err
+}
+
+// fileOwner returns the owner of the file described by info.
+func fileOwner(info os.FileInfo) *FileOwner {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return &FileOwner{UID: int(st.Uid), GID: int(st.Gid)}
+	}
+	return nil
+}